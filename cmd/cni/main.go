@@ -16,12 +16,23 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
 	"github.com/containernetworking/cni/pkg/skel"
 	cniversion "github.com/containernetworking/cni/pkg/version"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/cni"
 )
 
 func main() {
+	// the container runtime runs the CNI without arguments, passing everything through env vars and stdin
+	if len(os.Args) > 1 {
+		os.Exit(runCommandLine())
+	}
+
 	skel.PluginMain(
 		func(args *skel.CmdArgs) error {
 			err := cni.CmdAdd(args)
@@ -36,3 +47,66 @@ func main() {
 		func(args *skel.CmdArgs) error { return cni.CmdDel(args) },
 		cniversion.All, "AF_XDP CNI Plugin")
 }
+
+/*
+runCommandLine handles the flags of the CNI when run by hand, rather than by the container
+runtime, and returns the exit code.
+*/
+func runCommandLine() int {
+	var configFile string
+	var validate bool
+	var version bool
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.StringVar(&configFile, "config", "-", "Location of a network configuration to validate, - for stdin")
+	flags.BoolVar(&validate, "validate", false, "Validate the network configuration and exit")
+	flags.BoolVar(&version, "version", false, "Print the version and the supported CNI spec versions and exit")
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "AF_XDP CNI Plugin, run by the container runtime without arguments.\n\n")
+		fmt.Fprintf(out, "Usage:\n  %s [flags]\n\nFlags:\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	switch {
+	case version:
+		fmt.Printf("%s\ncommit: %s\nbuilt: %s\nCNI spec versions: %v\n", constants.Plugins.Version, constants.Plugins.Commit,
+			constants.Plugins.BuildDate, cniversion.All.SupportedVersions())
+		return 0
+	case validate:
+		return validateConf(configFile)
+	default:
+		flags.Usage()
+		return 2
+	}
+}
+
+/*
+validateConf validates a network configuration and prints the result, returning the exit code.
+*/
+func validateConf(configFile string) int {
+	var conf []byte
+	var err error
+	if configFile == "-" {
+		conf, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		conf, err = ioutil.ReadFile(configFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading network configuration: %v\n", err)
+		return 1
+	}
+
+	if _, err := cni.ParseConf(conf); err != nil {
+		fmt.Fprintf(os.Stderr, "Network configuration %s is invalid: %v\n", configFile, err)
+		return 1
+	}
+
+	fmt.Printf("Network configuration %s is valid\n", configFile)
+	return 0
+}
@@ -16,38 +16,149 @@
 package main
 
 import (
+	"compress/gzip"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/audit"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpfhelper"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpfsig"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/cleanup"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/deviceplugin"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/featuregates"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/health"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logfile"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logformats"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/nodecheck"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/prereq"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/profiling"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/selinux"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/singleton"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/status"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/systemd"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tracing"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
 	logging "github.com/sirupsen/logrus"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
 var (
 	hostHandler = host.NewHandler()
 	netHandler  = networking.NewHandler()
 	deviceFile  = constants.DeviceFile.Directory + constants.DeviceFile.Name
+
+	shutdownTimeout = time.Duration(constants.Plugins.DevicePlugin.ShutdownTimeout) * time.Second
 )
 
 type devicePlugin struct {
-	pools map[string]deviceplugin.PoolManager
+	pools map[string]*deviceplugin.PoolManager
+	lock  *singleton.Lock
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "status" {
+		os.Exit(printStatus(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "bpf-helper" {
+		os.Exit(runBpfHelper(os.Args[2:]))
+	}
+
 	var configFile string
+	var pprofAddr string
+	var logLevel string
+	var profile string
+	var featureGates string
+	var metricsAddr string
+	var validate bool
+	var checkNodeOnly bool
+	var cleanupOnly bool
+	var verifyAudit string
+	var version bool
 	flag.StringVar(&configFile, "config", constants.Plugins.DevicePlugin.DefaultConfigFile, "Location of the device plugin configuration file")
+	flag.StringVar(&pprofAddr, "pprof", "", "Serve pprof profiles on a UDS path or a localhost:port address, disabled if unset")
+	flag.StringVar(&logLevel, "log-level", "", fmt.Sprintf("Log level, one of %v, overriding the config file and env vars", constants.Logging.Levels))
+	flag.StringVar(&profile, "profile", "", fmt.Sprintf("Config profile, one of %v, giving defaults for fields the config file does not set", constants.ConfigFile.Profiles))
+	flag.StringVar(&featureGates, "feature-gates", "", fmt.Sprintf("Comma separated name=true|false pairs enabling or disabling experimental features, of %v, over the config file", featuregates.Names()))
+	flag.StringVar(&metricsAddr, "metrics-addr", "", "Serve metrics on a host:port or :port address, overriding the config file and env vars")
+	flag.BoolVar(&validate, "validate", false, "Validate the configuration file, including env var and command line overrides, and exit")
+	flag.BoolVar(&checkNodeOnly, "check-node", false, "Check the node can run the device plugin, print a pass or fail report and exit")
+	flag.BoolVar(&cleanupOnly, "cleanup", false, "Remove everything the plugins have created on the node, releasing devices from pods, and exit")
+	flag.StringVar(&verifyAudit, "verify-audit", "", "Verify the hash chain of comma separated audit files, oldest first, and exit")
+	flag.BoolVar(&version, "version", false, "Print the version and exit")
+	flag.Usage = usage
 	flag.Parse()
+
+	if version {
+		fmt.Printf("%s\ncommit: %s\nbuilt: %s\n", constants.Plugins.Version, constants.Plugins.Commit, constants.Plugins.BuildDate)
+		os.Exit(constants.Plugins.DevicePlugin.ExitNormal)
+	}
+
+	overrides := make(map[string]string)
+	if logLevel != "" {
+		overrides["logLevel"] = logLevel
+	}
+	if metricsAddr != "" {
+		overrides["metricsAddr"] = metricsAddr
+	}
+	flagGates, err := featuregates.Parse(featureGates)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting feature gates: %v\n", err)
+		os.Exit(constants.Plugins.DevicePlugin.ExitConfigError)
+	}
+	if err := deviceplugin.SetProfile(profile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting config profile: %v\n", err)
+		os.Exit(constants.Plugins.DevicePlugin.ExitConfigError)
+	}
+	if err := deviceplugin.SetOverrides(overrides); err != nil {
+		fmt.Fprintf(os.Stderr, "Error setting command line overrides: %v\n", err)
+		os.Exit(constants.Plugins.DevicePlugin.ExitConfigError)
+	}
+
+	// node metadata, for config files templated on the node
+	if nodeName, err := getNodeName(); err == nil {
+		deviceplugin.SetNodeSource(apiserver.NewHandler(nodeName))
+	}
+
+	if validate {
+		os.Exit(validateConfig(configFile))
+	}
+
+	if checkNodeOnly {
+		os.Exit(checkNode(configFile))
+	}
+
+	if cleanupOnly {
+		os.Exit(cleanupNode())
+	}
+
+	if verifyAudit != "" {
+		os.Exit(verifyAuditFiles(strings.Split(verifyAudit, ",")))
+	}
+
 	logging.SetReportCaller(true)
 	logging.SetFormatter(logformats.Default)
+	logging.Infof("Device plugin version %s, commit %s, built %s", constants.Plugins.Version, constants.Plugins.Commit, constants.Plugins.BuildDate)
 
 	// overall config
 	cfg, err := deviceplugin.GetPluginConfig(configFile)
@@ -56,12 +167,250 @@ func main() {
 		exit(constants.Plugins.DevicePlugin.ExitConfigError)
 	}
 
+	// BPF helper, loading BPF programs on behalf of the device plugin so it needs no BPF capabilities
+	if cfg.BpfHelper != "" {
+		logging.Infof("Loading BPF programs through the BPF helper at %s", cfg.BpfHelper)
+		bpf.UseHelper(cfg.BpfHelper)
+		privileges.Delegate(privileges.Bpf)
+	}
+
+	// BPF signatures, so only vetted object files are loaded onto devices
+	if cfg.BpfPublicKey != "" {
+		if err := bpfsig.SetPublicKey(cfg.BpfPublicKey); err != nil {
+			logging.Errorf("Error setting BPF public key: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+		logging.Infof("Verifying BPF object files against signatures by the key in %s", cfg.BpfPublicKey)
+	}
+
+	// capabilities, disabling features the device plugin lacks the capabilities for
+	features, err := privileges.Check(hostHandler)
+	if err != nil {
+		logging.Errorf("Device plugin lacks the capabilities it needs: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitHostError)
+	}
+	deviceplugin.SetFeatures(features)
+
+	// SELinux, labelling the sockets and directories created for pods so confined pods can use them
+	if cfg.SelinuxLabel != "" {
+		if selinux.Enabled() {
+			logging.Infof("Labelling pod sockets and directories with SELinux context %s", cfg.SelinuxLabel)
+			selinux.SetLabel(cfg.SelinuxLabel)
+		} else {
+			logging.Infof("SELinux is not enabled, not labelling pod sockets and directories")
+		}
+	}
+
+	// single instance, so two device plugins never register the same resources or race over devices,
+	// acquired before the runtime directories are changed, as they are in use by a running instance.
+	// Only a missing directory of the lock file is created, its owner and mode are set with the others
+	if err := os.MkdirAll(filepath.Dir(constants.Plugins.DevicePlugin.LockFile), os.FileMode(constants.Uds.DirFileMode)); err != nil {
+		logging.Errorf("Error creating lock file directory: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitHostError)
+	}
+	lock, err := singleton.Acquire(constants.Plugins.DevicePlugin.LockFile)
+	if err != nil {
+		logging.Errorf("Device plugin cannot start: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitLockError)
+	}
+
+	// runtime directories, created before anything is written to them
+	if err := createRuntimeDirs(cfg, features); err != nil {
+		logging.Errorf("Error creating runtime directories: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitHostError)
+	}
+
 	// logging
 	if err := configureLogging(cfg); err != nil {
 		logging.Errorf("Error configuring logging: %v", err)
 		exit(constants.Plugins.DevicePlugin.ExitLogError)
 	}
 
+	// feature gates, the command line taking precedence over the config file
+	for _, gates := range []map[string]bool{cfg.FeatureGates, flagGates} {
+		if err := featuregates.Set(gates); err != nil {
+			logging.Errorf("Error setting feature gates: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+	}
+
+	// audit
+	if cfg.AuditFile != "" {
+		if err := configureAudit(cfg); err != nil {
+			logging.Errorf("Error configuring audit file: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitLogError)
+		}
+	}
+
+	// sockets passed by systemd socket activation, used in place of those configured
+	activated, err := systemd.Listeners()
+	if err != nil {
+		logging.Errorf("Error getting sockets passed by systemd: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitConfigError)
+	}
+
+	// metrics
+	if cfg.MetricsMaxSeries != 0 {
+		metrics.SetMaxSeries(cfg.MetricsMaxSeries)
+	}
+	if len(cfg.MetricsDropLabels) > 0 {
+		if err := metrics.DropLabels(cfg.MetricsDropLabels...); err != nil {
+			logging.Errorf("Error configuring metrics: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+	}
+	if cfg.MinimalSyscalls {
+		logging.Infof("Minimal syscalls, not serving metrics")
+	} else if listener, ok := activated[constants.Systemd.MetricsSocket]; ok {
+		metrics.ServeListener(listener)
+	} else if cfg.MetricsAddr != "" {
+		if err := metrics.Serve(cfg.MetricsAddr); err != nil {
+			logging.Errorf("Error starting metrics server: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitMetricsError)
+		}
+	}
+
+	// profiling
+	if pprofAddr != "" && cfg.MinimalSyscalls {
+		logging.Infof("Minimal syscalls, not serving pprof profiles")
+	} else if pprofAddr != "" {
+		if err := profiling.Serve(pprofAddr); err != nil {
+			logging.Errorf("Error starting pprof server: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitProfileError)
+		}
+	}
+
+	// health
+	var poolsReady int32
+	health.Readiness.Add("pools", func() error {
+		if atomic.LoadInt32(&poolsReady) == 0 {
+			return errors.New("device pools not yet discovered and registered with the kubelet")
+		}
+		return nil
+	})
+	if cfg.MinimalSyscalls {
+		logging.Infof("Minimal syscalls, not serving health checks")
+	} else if listener, ok := activated[constants.Systemd.HealthSocket]; ok {
+		health.ServeListener(listener)
+	} else if cfg.HealthAddr != "" {
+		if err := health.Serve(cfg.HealthAddr); err != nil {
+			logging.Errorf("Error starting health server: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitHealthError)
+		}
+	}
+
+	// tracing
+	if cfg.TracingEndpoint != "" {
+		nodeName, err := getNodeName()
+		if err != nil {
+			logging.Errorf("Error getting node name for tracing: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+		if err := tracing.Enable(cfg.TracingEndpoint, nodeName); err != nil {
+			logging.Errorf("Error enabling tracing: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+	}
+
+	// timeouts
+	if cfg.PodCheckInterval != 0 {
+		logging.Infof("Checking connected pods exist every %ds", cfg.PodCheckInterval)
+		udsserver.SetPodCheckInterval(time.Duration(cfg.PodCheckInterval) * time.Second)
+	}
+	if cfg.ApiServerTimeout != 0 {
+		logging.Infof("Using API server timeout of %ds", cfg.ApiServerTimeout)
+		apiserver.SetTimeout(time.Duration(cfg.ApiServerTimeout) * time.Second)
+	}
+	if cfg.PodResCacheTTL != 0 {
+		logging.Infof("Caching pod resources for %ds", cfg.PodResCacheTTL)
+		resourcesapi.SetCacheTTL(time.Duration(cfg.PodResCacheTTL) * time.Second)
+	}
+	if cfg.PodResTimeout != 0 {
+		logging.Infof("Using pod resources API timeout of %ds", cfg.PodResTimeout)
+		resourcesapi.SetTimeout(time.Duration(cfg.PodResTimeout) * time.Second)
+	}
+	if cfg.KubeletTimeout != 0 {
+		logging.Infof("Using kubelet timeout of %ds", cfg.KubeletTimeout)
+		deviceplugin.SetKubeletTimeout(time.Duration(cfg.KubeletTimeout) * time.Second)
+	}
+	if cfg.ShutdownTimeout != 0 {
+		logging.Infof("Using shutdown timeout of %ds", cfg.ShutdownTimeout)
+		shutdownTimeout = time.Duration(cfg.ShutdownTimeout) * time.Second
+		deviceplugin.SetShutdownTimeout(shutdownTimeout)
+	}
+
+	// pod resources
+	logging.Infof("Using kubelet pod resources socket %s", cfg.PodResSock)
+	resourcesapi.SetSocketPath(cfg.PodResSock)
+	if cfg.ApiFallback {
+		nodeName, err := getNodeName()
+		if err != nil {
+			logging.Errorf("Error getting node name for API server fallback: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+		logging.Infof("API server fallback enabled for node %s", nodeName)
+		udsserver.SetApiServerFallback(apiserver.NewHandler(nodeName))
+	}
+
+	// connected process verification
+	if cfg.RequirePeerCgroup {
+		logging.Infof("Refusing pods whose connected process cannot be verified to run in the pod")
+		udsserver.SetRequirePeerCgroup(true)
+	}
+	if cfg.RequireToken {
+		logging.Infof("Refusing pods that do not send the handshake token of their allocation")
+		udsserver.SetRequireToken(true)
+	}
+	if cfg.RequireChallenge {
+		logging.Infof("Refusing pods that do not answer a handshake challenge")
+		udsserver.SetRequireChallenge(true)
+	}
+	if cfg.RequireSigned {
+		logging.Infof("Refusing pods that do not sign their messages with the handshake token")
+		udsserver.SetRequireSigned(true)
+	}
+	if cfg.RevalidateFds {
+		logging.Infof("Checking pods still hold the device of each file descriptor request")
+		udsserver.SetRevalidateFdRequests(true)
+	}
+	if cfg.SingleUseFds {
+		logging.Infof("Passing the file descriptor of each device at most once per connection")
+		udsserver.SetSingleUseFds(true)
+	}
+	if cfg.MinimalSyscalls {
+		logging.Infof("Minimal syscalls, not verifying the process connected to the UDS")
+		udsserver.SetMinimalSyscalls(true)
+	}
+
+	// handshake privileges
+	if cfg.DropPrivileges {
+		if err := privileges.CheckDrop(hostHandler); err != nil {
+			logging.Errorf("Unable to drop the privileges of the UDS handshake: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitHostError)
+		}
+		logging.Infof("Running the UDS handshake as user %d, group %d, without the capabilities it does not need", cfg.HandshakeUid, cfg.HandshakeGid)
+		udsserver.SetDropPrivileges(true, cfg.HandshakeUid, cfg.HandshakeGid)
+	}
+
+	// shutdown
+	if cfg.DetachXdp {
+		logging.Infof("Detaching XDP programs from pool devices on shutdown")
+		deviceplugin.SetDetachXdpOnShutdown(true)
+	}
+
+	// kubernetes events
+	if cfg.Events {
+		nodeName, err := getNodeName()
+		if err != nil {
+			logging.Errorf("Error getting node name for Kubernetes events: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitConfigError)
+		}
+		logging.Infof("Kubernetes events enabled for node %s", nodeName)
+		recorder := apiserver.NewHandler(nodeName)
+		udsserver.SetEventRecorder(recorder)
+		deviceplugin.SetEventRecorder(recorder)
+	}
+
 	// configure a set of veths and a bridge as a secondary kind network.
 	if cfg.KindCluster {
 		if err := configureKindSecondaryNetwork(); err != nil {
@@ -97,6 +446,25 @@ func main() {
 	}
 	logging.Infof("Host meets requirements")
 
+	// prerequisites, failing before registering with the kubelet rather than on a pod's allocation
+	if cfg.SkipPrereqs {
+		logging.Warningf("Skipping prerequisite checks")
+	} else {
+		logging.Infof("Checking prerequisites")
+		checks := prereq.Checks(hostHandler, netHandler, bpf.NewHandler(), resourcesapi.Probe)
+		if err := prereq.Verify(prereq.Enabled(checks, features)); err != nil {
+			logging.Errorf("Device plugin cannot serve pods: %v", err)
+			exit(constants.Plugins.DevicePlugin.ExitPrereqError)
+		}
+		logging.Infof("Prerequisites met")
+	}
+
+	// roll back host changes left unfinished by a crash
+	deviceplugin.RollbackJournal(netHandler, bpf.NewHandler())
+
+	// remove sockets left by a previous instance, they cannot be served again
+	deviceplugin.RecoverSockets(netHandler)
+
 	// pool configs
 	logging.Infof("Getting device pools")
 	poolConfigs, err := deviceplugin.GetPoolConfigs(configFile, netHandler, hostHandler)
@@ -107,7 +475,8 @@ func main() {
 	logging.Infof("Found %d poolConfigs", len(poolConfigs))
 
 	dp := devicePlugin{
-		pools: make(map[string]deviceplugin.PoolManager),
+		pools: make(map[string]*deviceplugin.PoolManager),
+		lock:  lock,
 	}
 
 	if cfg.KindCluster && len(poolConfigs) > 1 {
@@ -122,41 +491,342 @@ func main() {
 			logging.Errorf("Error initializing pool %v: %v", poolManager.Name, err)
 			continue
 		}
-		dp.pools[poolConfig.Name] = poolManager
+		dp.pools[poolConfig.Name] = &poolManager
 	}
 
+	// keep pod resources current for UDS servers validating pods
+	stopTracking := make(chan struct{})
+	resourcesapi.StartPodTracking(stopTracking)
+
+	// ready once every pool is registered, alive while pod tracking progresses and the kubelet is reachable
+	health.Readiness.Add("kubelet-registration", func() error {
+		if len(dp.pools) == 0 {
+			return errors.New("no device pools registered with the kubelet")
+		}
+		for _, pm := range dp.pools {
+			if err := pm.CheckRegistered(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	health.Liveness.Add("pod-resources", resourcesapi.CheckHealth)
+	atomic.StoreInt32(&poolsReady, 1)
+
+	// status
+	statusSource := status.Source{
+		Pools: func() []status.Pool {
+			pools := make([]status.Pool, 0, len(dp.pools))
+			for _, pm := range dp.pools {
+				pools = append(pools, pm.Status())
+			}
+			sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+			return pools
+		},
+		Connections: udsserver.Connections,
+		Net:         netHandler,
+	}
+	if listener, ok := activated[constants.Systemd.StatusSocket]; ok {
+		status.ServeListener(listener, statusSource)
+	} else if err := status.Serve(constants.Status.SocketPath, statusSource); err != nil {
+		logging.Errorf("Error starting control socket: %v", err)
+		exit(constants.Plugins.DevicePlugin.ExitStatusError)
+	}
+
+	// reload runtime settings as the config file changes
+	deviceplugin.WatchConfigFile(configFile, time.Duration(constants.ConfigFile.WatchInterval)*time.Second, stopTracking, func() {
+		reloadConfig(configFile)
+	})
+
+	// running as a systemd service, startup is complete and the watchdog is notified while alive
+	if _, err := systemd.Notify(systemd.Ready); err != nil {
+		logging.Warningf("Error notifying systemd of readiness: %v", err)
+	}
+	systemd.Watchdog(stopTracking, func() error {
+		if report, healthy := health.Liveness.Run(); !healthy {
+			return errors.New(strings.TrimSpace(report))
+		}
+		return nil
+	})
+
 	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	signal.Notify(sigs, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	s := <-sigs
-	logging.Infof("Received signal \"%v\"", s)
+	for s == syscall.SIGHUP || s == syscall.SIGUSR1 {
+		if s == syscall.SIGHUP {
+			logging.Infof("Received signal \"%v\", reloading config file", s)
+			reloadConfig(configFile)
+		} else {
+			logging.Infof("Received signal \"%v\", dumping state", s)
+			if path, err := status.Dump(statusSource, constants.Logging.Directory); err == nil {
+				logging.Infof("State dumped to %s", path)
+			}
+		}
+		s = <-sigs
+	}
+	logging.Infof("Received signal \"%v\", shutting down", s)
+	shutdown(dp, stopTracking)
+
+}
+
+/*
+shutdown stops the device plugin, cleaning up after it. The pools are terminated, reporting their
+devices unhealthy to the kubelet and removing their device plugin sockets, the UDS servers are
+stopped and their sockets removed, the connection to the kubelet pod resources api and the control
+socket are closed, and the node-level lock is released. Pods keep the AF_XDP sockets already
+passed to them. If cleaning up hangs, such as on an unresponsive kubelet, the device plugin exits
+regardless.
+*/
+func shutdown(dp devicePlugin, stopTracking chan struct{}) {
+	guard := time.AfterFunc(2*shutdownTimeout, func() {
+		logging.Errorf("Shutdown did not complete within %v", 2*shutdownTimeout)
+		exit(constants.Plugins.DevicePlugin.ExitNormal)
+	})
+	defer guard.Stop()
+
+	if _, err := systemd.Notify(systemd.Stopping); err != nil {
+		logging.Warningf("Error notifying systemd of shutdown: %v", err)
+	}
+	close(stopTracking)
+
+	var wg sync.WaitGroup
 	for _, pm := range dp.pools {
-		logging.Infof("Terminating %v", pm.Name)
-		if err := pm.Terminate(); err != nil {
-			logging.Errorf("Termination error: %v", err)
+		wg.Add(1)
+		go func(pm *deviceplugin.PoolManager) {
+			defer wg.Done()
+			logging.Infof("Terminating %v", pm.Name)
+			if err := pm.Terminate(); err != nil {
+				logging.Errorf("Termination error: %v", err)
+			}
+		}(pm)
+	}
+	wg.Wait()
+
+	udsserver.StopAll()
+	resourcesapi.Close()
+	status.Stop()
+	tracing.Shutdown()
+	audit.SetWriter(nil)
+	if err := dp.lock.Release(); err != nil {
+		logging.Warningf("Error releasing device plugin lock: %v", err)
+	}
+	logging.Infof("Device plugin shut down")
+}
+
+/*
+usage prints the help output of the device plugin.
+*/
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage:\n")
+	fmt.Fprintf(out, "  %s [flags]             run the device plugin\n", os.Args[0])
+	fmt.Fprintf(out, "  %s status [flags]      print the status of the device plugin running on the node\n", os.Args[0])
+	fmt.Fprintf(out, "  %s bpf-helper [flags]  run the privileged helper loading BPF programs for the device plugin\n", os.Args[0])
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+/*
+validateConfig validates the config file and prints the result, returning the exit code. The
+pools are not discovered, so devices are not checked to exist on the node.
+*/
+func validateConfig(configFile string) int {
+	logging.SetOutput(ioutil.Discard)
+
+	if _, err := deviceplugin.GetPluginConfig(configFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Config file %s is invalid: %v\n", configFile, err)
+		return constants.Plugins.DevicePlugin.ExitConfigError
+	}
+
+	fmt.Printf("Config file %s is valid\n", configFile)
+	return constants.Plugins.DevicePlugin.ExitNormal
+}
+
+/*
+checkNode checks the node can run the device plugin and prints a pass or fail report, returning
+the exit code. Each driver of the pools selected for this node in the config file is checked to
+have devices on the node, with at least the minimum firmware version set for it.
+*/
+func checkNode(configFile string) int {
+	logging.SetOutput(ioutil.Discard)
+
+	drivers, err := deviceplugin.GetDriverRequirements(configFile, hostHandler)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Config file %s is invalid: %v\n", configFile, err)
+		return constants.Plugins.DevicePlugin.ExitConfigError
+	}
+
+	if !nodecheck.Print(os.Stdout, nodecheck.Run(hostHandler, netHandler, drivers)) {
+		return constants.Plugins.DevicePlugin.ExitHostError
+	}
+
+	return constants.Plugins.DevicePlugin.ExitNormal
+}
+
+/*
+cleanupNode removes everything the plugins have created on the node, returning the exit code.
+Unfinished host changes are rolled back first. If a device plugin is running, as when run from
+its preStop hook, its lock file is kept and it cleans up its own sockets as it shuts down.
+*/
+func cleanupNode() int {
+	logging.SetReportCaller(true)
+	logging.SetFormatter(logformats.Default)
+	logging.Infof("Removing everything the plugins have created on the node")
+
+	paths := cleanup.Paths{
+		StateDir:       constants.DeviceFile.Directory,
+		BpfPinDir:      constants.Afxdp.BpfPinDir,
+		KubeletSockets: pluginapi.DevicePluginPath + constants.Plugins.DevicePlugin.DevicePrefix + "-*.sock",
+	}
+	lock, err := singleton.Acquire(constants.Plugins.DevicePlugin.LockFile)
+	if err != nil {
+		logging.Warningf("Keeping lock file: %v", err)
+		paths.Keep = append(paths.Keep, constants.Plugins.DevicePlugin.LockFile)
+	} else {
+		defer lock.Release()
+	}
+
+	deviceplugin.RollbackJournal(netHandler, bpf.NewHandler())
+	if err := cleanup.Run(netHandler, bpf.NewHandler(), paths); err != nil {
+		logging.Errorf("Error cleaning up node: %v", err)
+		return constants.Plugins.DevicePlugin.ExitHostError
+	}
+
+	logging.Infof("Node cleaned up")
+	return constants.Plugins.DevicePlugin.ExitNormal
+}
+
+/*
+verifyAuditFiles verifies the hash chain of the audit records in the files at paths, oldest first,
+and prints the result, returning the exit code. Files rotated with compression are decompressed.
+*/
+func verifyAuditFiles(paths []string) int {
+	logging.SetOutput(ioutil.Discard)
+
+	var readers []io.Reader
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening audit file: %v\n", err)
+			return constants.Plugins.DevicePlugin.ExitLogError
 		}
+		defer file.Close()
+
+		var reader io.Reader = file
+		if strings.HasSuffix(path, ".gz") {
+			if reader, err = gzip.NewReader(file); err != nil {
+				fmt.Fprintf(os.Stderr, "Error decompressing audit file %s: %v\n", path, err)
+				return constants.Plugins.DevicePlugin.ExitLogError
+			}
+		}
+		readers = append(readers, reader)
 	}
 
+	verified, last, err := audit.Verify(io.MultiReader(readers...))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Audit records are not intact after %d records: %v\n", verified, err)
+		return constants.Plugins.DevicePlugin.ExitLogError
+	}
+
+	fmt.Printf("%d audit records are intact, the last with hash %s\n", verified, last)
+	return constants.Plugins.DevicePlugin.ExitNormal
+}
+
+/*
+printStatus runs the status subcommand, printing the status of the device plugin running on the
+node, and returns the exit code.
+*/
+func printStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	format := flags.String("o", "table", fmt.Sprintf("Output format, one of %v", status.Formats))
+	socket := flags.String("socket", constants.Status.SocketPath, "Location of the device plugin control socket")
+	flags.Parse(args)
+
+	if !tools.ArrayContains(status.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown output format %s, must be one of %v\n", *format, status.Formats)
+		return constants.Plugins.DevicePlugin.ExitStatusError
+	}
+
+	st, err := status.Get(*socket)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting device plugin status: %v\n", err)
+		return constants.Plugins.DevicePlugin.ExitStatusError
+	}
+
+	if err := status.Print(os.Stdout, st, *format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error printing device plugin status: %v\n", err)
+		return constants.Plugins.DevicePlugin.ExitStatusError
+	}
+
+	return constants.Plugins.DevicePlugin.ExitNormal
+}
+
+/*
+runBpfHelper runs the bpf-helper subcommand, serving BPF requests of the device plugin on the
+helper socket until terminated, and returns the exit code. The helper is the only process that
+needs BPF capabilities, so it can run in a container of its own.
+*/
+func runBpfHelper(args []string) int {
+	flags := flag.NewFlagSet("bpf-helper", flag.ExitOnError)
+	socket := flags.String("socket", constants.BpfHelper.DefaultSocket, "Location of the BPF helper socket")
+	publicKey := flags.String("public-key", "", "Location of the Ed25519 public key BPF object files are verified with, not verified if unset")
+	flags.Parse(args)
+
+	logging.SetReportCaller(true)
+	logging.SetFormatter(logformats.Default)
+	logging.Infof("BPF helper version %s, listening on %s", constants.Plugins.Version, *socket)
+
+	if err := bpfsig.SetPublicKey(*publicKey); err != nil {
+		logging.Errorf("Error setting BPF public key: %v", err)
+		return constants.Plugins.DevicePlugin.ExitConfigError
+	}
+
+	listener, err := bpfhelper.Listen(*socket)
+	if err != nil {
+		logging.Errorf("Error starting BPF helper: %v", err)
+		return constants.Plugins.DevicePlugin.ExitHostError
+	}
+
+	stopping := make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigs
+		logging.Infof("Received signal %v, stopping BPF helper", sig)
+		close(stopping)
+		listener.Close()
+	}()
+
+	err = bpfhelper.Serve(listener, bpf.NewHandler())
+	select {
+	case <-stopping:
+		return constants.Plugins.DevicePlugin.ExitNormal
+	default:
+		logging.Errorf("BPF helper stopped: %v", err)
+		return constants.Plugins.DevicePlugin.ExitHostError
+	}
+}
+
+/*
+getNodeName returns the node name set through the downward API, or the hostname if unset.
+*/
+func getNodeName() (string, error) {
+	if nodeName, exists := os.LookupEnv(constants.ApiServer.NodeNameEnvVar); exists && nodeName != "" {
+		return nodeName, nil
+	}
+
+	return hostHandler.Hostname()
 }
 
 func configureLogging(cfg deviceplugin.PluginConfig) error {
 	var (
-		logDir      = constants.Logging.Directory
-		logDirPerm  = os.FileMode(constants.Logging.DirectoryPermissions)
-		logFile     = cfg.LogFile
-		logFilePerm = os.FileMode(constants.Logging.FilePermissions)
-		logLevel    = cfg.LogLevel
+		logFile  = cfg.LogFile
+		logLevel = cfg.LogLevel
 	)
 
 	if logFile != "" {
-		logging.Infof("Setting log directory: %s", logDir)
-		err := os.MkdirAll(logDir, logDirPerm)
-		if err != nil {
-			logging.Errorf("Error setting log directory: %v", err)
-			return err
-		}
-
 		logging.Infof("Setting log file: %s", logFile)
-		fp, err := os.OpenFile(logDir+logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, logFilePerm)
+		fp, err := logfile.Open(logFile, cfg.LogFileMaxSize, cfg.LogFileBackups, cfg.LogFileCompress)
 		if err != nil {
 			logging.Errorf("Error setting log file: %v", err)
 			return err
@@ -164,19 +834,151 @@ func configureLogging(cfg deviceplugin.PluginConfig) error {
 		logging.SetOutput(io.MultiWriter(fp, os.Stdout))
 	}
 
-	if logLevel != "" {
-		logging.Infof("Setting log level: %s", logLevel)
-		level, err := logging.ParseLevel(logLevel)
-		if err != nil {
-			logging.Errorf("Error setting log level: %v", err)
+	if cfg.LogFormat == "json" {
+		logging.Infof("Logging in JSON format")
+		logformats.SetJSON()
+	}
+
+	if cfg.LogBackend == "syslog" {
+		logging.Infof("Logging to syslog with tag %s", constants.Logging.SyslogTag)
+		if err := logformats.SetSyslog(constants.Logging.SyslogTag); err != nil {
+			logging.Errorf("Error opening syslog: %v", err)
 			return err
 		}
-		logging.SetLevel(level)
+	}
 
-		if logLevel == "debug" {
-			logging.Infof("Switching to debug log format")
-			logging.SetFormatter(logformats.Debug)
+	if cfg.LogDedup != -1 {
+		interval := cfg.LogDedup
+		if interval == 0 {
+			interval = constants.Logging.DedupInterval
 		}
+		logging.Infof("Suppressing repeated log lines for %ds", interval)
+		logformats.SetDedup(time.Duration(interval) * time.Second)
+	}
+
+	if logLevel != "" || len(cfg.LogLevels) > 0 {
+		return setLogLevel(logLevel, cfg.LogLevels)
+	}
+
+	return nil
+}
+
+/*
+createRuntimeDirs creates the UDS socket, BPF pin and log directories with the owner, group and
+permissions of the config, correcting them if the directories already exist. The UDS socket
+directory is given the SELinux context of the config, if set. The BPF pin directory is only
+created if a BPF filesystem is mounted. Without the chown feature, directories keep the owner and
+group of the device plugin user.
+*/
+func createRuntimeDirs(cfg deviceplugin.PluginConfig, features map[string]bool) error {
+	dirs := []deviceplugin.RuntimeDir{cfg.SocketDir, cfg.LogDir}
+
+	mounted, err := hostHandler.HasBpffs(constants.Afxdp.BpffsPath)
+	if err != nil {
+		logging.Warningf("Error checking for a BPF filesystem at %s: %v", constants.Afxdp.BpffsPath, err)
+	}
+	if mounted {
+		dirs = append(dirs, cfg.BpfPinDir)
+	} else {
+		logging.Infof("No BPF filesystem mounted at %s, not creating %s", constants.Afxdp.BpffsPath, cfg.BpfPinDir.Path)
+	}
+
+	for _, dir := range dirs {
+		if !features[privileges.Chown] && (dir.Owner != os.Getuid() || dir.Group != os.Getgid()) {
+			logging.Warningf("Directory %s cannot be given owner %d, group %d without CAP_CHOWN, keeping the device plugin user", dir.Path, dir.Owner, dir.Group)
+			dir.Owner, dir.Group = os.Getuid(), os.Getgid()
+		}
+		logging.Infof("Creating directory %s, owner %d, group %d, mode %04o", dir.Path, dir.Owner, dir.Group, dir.Mode)
+		if err := tools.EnsureDirectory(dir.Path, dir.Owner, dir.Group, dir.Mode); err != nil {
+			return fmt.Errorf("directory %s: %v", dir.Path, err)
+		}
+	}
+
+	if err := selinux.Apply(cfg.SocketDir.Path); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+/*
+configureAudit opens the audit file recording the file descriptors passed to pods. The audit
+file is kept in the log directory and rotated as the log file is.
+*/
+func configureAudit(cfg deviceplugin.PluginConfig) error {
+	logging.Infof("Setting audit file: %s", cfg.AuditFile)
+	return audit.Open(cfg.AuditFile, cfg.LogFileMaxSize, cfg.LogFileBackups, cfg.LogFileCompress)
+}
+
+/*
+reloadLock serialises config reloads, triggered both by SIGHUP and by changes to the config file.
+*/
+var reloadLock sync.Mutex
+
+/*
+reloadConfig rereads the config file and the environment and applies the settings that can be
+changed while running: the log levels, log deduplication and the pod check and pod resources
+cache timeouts. Unset settings fall back to their defaults. Changes to any other setting,
+including the devices and capacity of pools, are reported and only take effect on restart. If the config file is invalid the current settings
+are kept.
+*/
+func reloadConfig(configFile string) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	reload, err := deviceplugin.ReloadConfig(configFile)
+	if err != nil {
+		logging.Errorf("Error reloading config file, keeping current config: %v", err)
+		return
+	}
+	if len(reload.Restart) > 0 {
+		logging.Warningf("Config file changes to %v are not applied until the device plugin is restarted", reload.Restart)
+	}
+	if len(reload.Pools) > 0 {
+		logging.Warningf("Pools %v changed in the config file, their devices and capacity are unchanged until the device plugin is restarted", reload.Pools)
+	}
+	cfg := reload.Config
+
+	dedup := cfg.LogDedup
+	if dedup == 0 {
+		dedup = constants.Logging.DedupInterval
+	}
+	if dedup == -1 {
+		dedup = 0
+	}
+	logformats.SetDedup(time.Duration(dedup) * time.Second)
+
+	if err := setLogLevel(cfg.LogLevel, cfg.LogLevels); err != nil {
+		logging.Errorf("Error reloading log level, keeping level %s: %v", logging.GetLevel(), err)
+	}
+
+	podCheckInterval := cfg.PodCheckInterval
+	if podCheckInterval == 0 {
+		podCheckInterval = constants.Uds.PodCheckInterval
+	}
+	udsserver.SetPodCheckInterval(time.Duration(podCheckInterval) * time.Second)
+
+	podResCacheTTL := cfg.PodResCacheTTL
+	if podResCacheTTL == 0 {
+		podResCacheTTL = constants.PodResources.CacheTTL
+	}
+	resourcesapi.SetCacheTTL(time.Duration(podResCacheTTL) * time.Second)
+
+	logging.Infof("Config file reloaded")
+}
+
+func setLogLevel(logLevel string, subsystemLevels map[string]string) error {
+	if logLevel == "" {
+		logLevel = constants.Logging.DefaultLevel
+	}
+
+	logging.Infof("Setting log level: %s", logLevel)
+	for subsystem, level := range subsystemLevels {
+		logging.Infof("Setting %s log level: %s", subsystem, level)
+	}
+	if err := logformats.SetLevel(logLevel, subsystemLevels); err != nil {
+		logging.Errorf("Error setting log level: %v", err)
+		return err
 	}
 
 	return nil
@@ -206,7 +1008,7 @@ func configureKindSecondaryNetwork() error {
 	numVeths := 4
 	offset := 6
 
-	err := networking.CreateKindNetwork(numVeths, offset)
+	err := netHandler.CreateKindNetwork(numVeths, offset)
 	if err != nil {
 		logging.Errorf("Error Creating CreateKindNetwork %s", err.Error())
 		return err
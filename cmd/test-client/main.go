@@ -0,0 +1,242 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+The test client performs the full UDS handshake with the device plugin from inside a pod, as a
+CNDP application would, and reports the outcome as JSON on stdout. It connects with the identity
+and handshake token the device plugin gave the container, requests the XSK map file descriptor of
+each device and optionally binds an AF_XDP socket to each device, inserting it into the XSK map.
+It exits with 0 if every step passed and 1 otherwise, so it can be run by the e2e tests and by
+operators validating new nodes.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/pkg/uds"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+result is the report of a test client run, printed as JSON.
+*/
+type result struct {
+	Socket    string         `json:"socket"`
+	Version   string         `json:"version,omitempty"`
+	Connected bool           `json:"connected"`
+	Devices   []deviceResult `json:"devices"`
+	Passed    bool           `json:"passed"`
+	Error     string         `json:"error,omitempty"`
+}
+
+/*
+deviceResult is the report of the requests made for a device.
+*/
+type deviceResult struct {
+	Name     string `json:"name"`
+	Response string `json:"response,omitempty"`
+	Fd       bool   `json:"fd"`
+	Bound    bool   `json:"bound,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+/*
+client is a connection to the device plugin UDS, signing requests and verifying responses if
+signing is enabled.
+*/
+type client struct {
+	handler  uds.Handler
+	token    string
+	sign     bool
+	sequence int
+}
+
+func main() {
+	var socket string
+	var devices string
+	var timeout int
+	var challenge bool
+	var sign bool
+	var bind bool
+	var queue int
+	flag.StringVar(&socket, "socket", constants.Uds.PodDir+constants.Uds.SockName, "Location of the device plugin UDS in the container")
+	flag.StringVar(&devices, "devices", os.Getenv(constants.Devices.EnvVarList), "Space separated devices to request, the devices given to the container by default")
+	flag.IntVar(&timeout, "timeout", 10, "Seconds to wait for each response of the device plugin")
+	flag.BoolVar(&challenge, "challenge", false, "Request a challenge before the connection request")
+	flag.BoolVar(&sign, "sign", false, "Sign every message with the handshake token")
+	flag.BoolVar(&bind, "bind", false, "Bind an AF_XDP socket to each device and insert it into the XSK map")
+	flag.IntVar(&queue, "queue", 0, "Queue of each device the AF_XDP socket is bound to")
+	flag.Parse()
+
+	logging.SetOutput(ioutil.Discard)
+
+	res := run(socket, strings.Fields(devices), time.Duration(timeout)*time.Second, challenge, sign, bind, queue)
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	if !res.Passed {
+		os.Exit(1)
+	}
+}
+
+/*
+run performs the handshake and returns its result.
+*/
+func run(socket string, devices []string, timeout time.Duration, challenge bool, sign bool, bind bool, queue int) result {
+	res := result{Socket: socket, Devices: []deviceResult{}}
+	fail := func(err error) result {
+		res.Error = err.Error()
+		return res
+	}
+
+	if len(devices) == 0 {
+		return fail(errors.New("no devices, " + constants.Devices.EnvVarList + " is not set"))
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fail(fmt.Errorf("error getting hostname: %v", err))
+	}
+	token := os.Getenv(constants.Uds.TokenEnvVar)
+	if sign && token == "" {
+		return fail(errors.New("cannot sign without a handshake token, " + constants.Uds.TokenEnvVar + " is not set"))
+	}
+
+	c := &client{handler: uds.NewHandler(), token: token, sign: sign}
+	if err := c.handler.Init(socket, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, timeout, ""); err != nil {
+		return fail(fmt.Errorf("error initialising UDS: %v", err))
+	}
+	cleanup, err := c.handler.Dial()
+	defer cleanup()
+	if err != nil {
+		return fail(fmt.Errorf("error connecting to the device plugin: %v", err))
+	}
+
+	// connect, answering a challenge with the proof of the token if one is requested
+	request := constants.Uds.Handshake.RequestConnect + ", " + hostname
+	for _, env := range []struct{ field, name string }{
+		{constants.Uds.Handshake.ConnectName, constants.Uds.PodNameEnvVar},
+		{constants.Uds.Handshake.ConnectNamespace, constants.Uds.PodNamespaceEnvVar},
+		{constants.Uds.Handshake.ConnectUid, constants.Uds.PodUidEnvVar},
+	} {
+		if value := os.Getenv(env.name); value != "" {
+			request += ", " + env.field + value
+		}
+	}
+	if challenge {
+		response, _, err := c.call(constants.Uds.Handshake.RequestChallenge, -1)
+		if err != nil {
+			return fail(err)
+		}
+		nonce := strings.TrimPrefix(response, constants.Uds.Handshake.ResponseChallenge+", ")
+		if nonce == response {
+			return fail(fmt.Errorf("challenge refused: %s", response))
+		}
+		if token != "" {
+			request += ", " + constants.Uds.Handshake.ConnectProof + uds.ChallengeProof(token, nonce)
+		} else {
+			request += ", " + constants.Uds.Handshake.ConnectNonce + nonce
+		}
+	} else if token != "" {
+		request += ", " + constants.Uds.Handshake.ConnectToken + token
+	}
+	response, _, err := c.call(request, -1)
+	if err != nil {
+		return fail(err)
+	}
+	if response != constants.Uds.Handshake.ResponseHostOk {
+		return fail(fmt.Errorf("connection refused: %s", response))
+	}
+	res.Connected = true
+
+	if res.Version, _, err = c.call(constants.Uds.Handshake.RequestVersion+", "+constants.Uds.Handshake.VersionBuild, -1); err != nil {
+		return fail(err)
+	}
+
+	passed := true
+	for _, device := range devices {
+		dev := deviceResult{Name: device}
+		response, fd, err := c.call(constants.Uds.Handshake.RequestFd+", "+device, -1)
+		dev.Response = response
+		dev.Fd = fd > 0
+		switch {
+		case err != nil:
+			dev.Error = err.Error()
+		case response != constants.Uds.Handshake.ResponseFdAck || fd <= 0:
+			dev.Error = "file descriptor not given"
+		case bind:
+			if err := bindXsk(device, queue, fd); err != nil {
+				dev.Error = err.Error()
+			} else {
+				dev.Bound = true
+			}
+		}
+		if fd > 0 {
+			syscall.Close(fd)
+		}
+		passed = passed && dev.Error == ""
+		res.Devices = append(res.Devices, dev)
+	}
+
+	if response, _, err := c.call(constants.Uds.Handshake.RequestFin, -1); err != nil {
+		return fail(err)
+	} else if response != constants.Uds.Handshake.ResponseFinAck {
+		return fail(fmt.Errorf("connection not closed: %s", response))
+	}
+
+	res.Passed = passed
+	return res
+}
+
+/*
+call writes a request and reads its response, signing and verifying them if signing is enabled.
+*/
+func (c *client) call(request string, fd int) (string, int, error) {
+	if c.sign {
+		request = uds.Sign(c.token, uds.SignRequest, c.sequence, request)
+	}
+	if err := c.handler.Write(request, fd); err != nil {
+		return "", 0, fmt.Errorf("error writing request: %v", err)
+	}
+	c.sequence++
+
+	response, responseFd, err := c.handler.Read()
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading response: %v", err)
+	}
+	if c.sign {
+		message, sig := uds.SplitSignature(response)
+		if !uds.VerifySignature(c.token, uds.SignResponse, c.sequence-1, message, sig) {
+			return "", 0, fmt.Errorf("response signature does not verify: %s", response)
+		}
+		response = message
+	}
+
+	return response, responseFd, nil
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+AF_XDP and BPF values from the kernel headers, not in the syscall package.
+*/
+const (
+	afXdp                 = 44  // AF_XDP
+	solXdp                = 283 // SOL_XDP
+	xdpRxRing             = 2   // XDP_RX_RING
+	xdpUmemReg            = 4   // XDP_UMEM_REG
+	xdpUmemFillRing       = 5   // XDP_UMEM_FILL_RING
+	xdpUmemCompletionRing = 6   // XDP_UMEM_COMPLETION_RING
+	bpfMapUpdateElem      = 2   // BPF_MAP_UPDATE_ELEM
+	sysBpf                = 321 // bpf syscall number on amd64, the architecture the plugins are built for
+)
+
+/*
+Size of the UMEM and rings of the test socket. The socket carries no traffic, so they are small.
+*/
+const (
+	xskFrames   = 64
+	xskRingSize = 64
+)
+
+/*
+xdpUmemRegV1 is struct xdp_umem_reg without the flags added in later kernels, accepted by every
+kernel with AF_XDP.
+*/
+type xdpUmemRegV1 struct {
+	addr      uint64
+	len       uint64
+	chunkSize uint32
+	headroom  uint32
+}
+
+/*
+sockaddrXdp is struct sockaddr_xdp.
+*/
+type sockaddrXdp struct {
+	family       uint16
+	flags        uint16
+	ifindex      uint32
+	queueId      uint32
+	sharedUmemFd uint32
+}
+
+/*
+bpfMapElemAttr is the part of union bpf_attr used by BPF_MAP_UPDATE_ELEM.
+*/
+type bpfMapElemAttr struct {
+	mapFd uint32
+	_     uint32
+	key   uint64
+	value uint64
+	flags uint64
+}
+
+/*
+bindXsk creates an AF_XDP socket with a UMEM, binds it to the queue of the device and inserts it
+into the XSK map, proving the file descriptor given by the device plugin is usable. The socket is
+closed before returning, removing it from the map.
+*/
+func bindXsk(device string, queue int, mapFd int) error {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return fmt.Errorf("error finding device: %v", err)
+	}
+
+	fd, err := syscall.Socket(afXdp, syscall.SOCK_RAW, 0)
+	if err != nil {
+		return fmt.Errorf("error creating AF_XDP socket: %v", err)
+	}
+	defer syscall.Close(fd)
+
+	frameSize := constants.Afxdp.FrameSizeDefault
+	umem, err := syscall.Mmap(-1, 0, xskFrames*frameSize, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS)
+	if err != nil {
+		return fmt.Errorf("error allocating UMEM: %v", err)
+	}
+	defer syscall.Munmap(umem)
+
+	reg := xdpUmemRegV1{
+		addr:      uint64(uintptr(unsafe.Pointer(&umem[0]))),
+		len:       uint64(len(umem)),
+		chunkSize: uint32(frameSize),
+	}
+	if err := setsockopt(fd, xdpUmemReg, unsafe.Pointer(&reg), unsafe.Sizeof(reg)); err != nil {
+		return fmt.Errorf("error registering UMEM: %v", err)
+	}
+	for _, ring := range []int{xdpUmemFillRing, xdpUmemCompletionRing, xdpRxRing} {
+		size := uint32(xskRingSize)
+		if err := setsockopt(fd, ring, unsafe.Pointer(&size), unsafe.Sizeof(size)); err != nil {
+			return fmt.Errorf("error sizing ring %d: %v", ring, err)
+		}
+	}
+
+	addr := sockaddrXdp{
+		family:  afXdp,
+		ifindex: uint32(iface.Index),
+		queueId: uint32(queue),
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(fd), uintptr(unsafe.Pointer(&addr)), unsafe.Sizeof(addr)); errno != 0 {
+		return fmt.Errorf("error binding AF_XDP socket to queue %d: %v", queue, errno)
+	}
+
+	key := uint32(queue)
+	value := uint32(fd)
+	attr := bpfMapElemAttr{
+		mapFd: uint32(mapFd),
+		key:   uint64(uintptr(unsafe.Pointer(&key))),
+		value: uint64(uintptr(unsafe.Pointer(&value))),
+	}
+	if _, _, errno := syscall.Syscall(sysBpf, bpfMapUpdateElem, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr)); errno != 0 {
+		return fmt.Errorf("error inserting AF_XDP socket into the XSK map: %v", errno)
+	}
+
+	return nil
+}
+
+/*
+setsockopt sets an SOL_XDP option of the socket.
+*/
+func setsockopt(fd int, option int, value unsafe.Pointer, size uintptr) error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), solXdp, uintptr(option), uintptr(value), size, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
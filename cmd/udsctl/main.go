@@ -0,0 +1,331 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+afxdp-udsctl is a debugging tool for the device plugin UDS. It connects to the socket of a pod,
+from the node or from inside the pod, and sends the handshake requests given to it, printing each
+raw response and the number of any file descriptor received, so the cause of a NAK can be found
+without writing a client. Requests are given as arguments, each sent in turn, or read line by line
+from stdin, interactively if it is a terminal. A request is either a raw message starting with /,
+sent as it is, or a command, see usage.
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/pkg/uds"
+	logging "github.com/sirupsen/logrus"
+)
+
+const usage = `Commands:
+  version [build] [client=<library>/<version>]
+  challenge
+  connect [hostname] [name=<pod>] [namespace=<namespace>] [uid=<uid>]
+  fd <device>
+  config <device>
+  busypoll <budget> <timeout>
+  fin
+  /<request>    sent as it is, e.g. /xsk_map_fd, ens801f2
+  help
+  quit
+The connection request is sent with the hostname if none is given, and with the handshake token,
+or the proof of it if a challenge was answered, unless a token, nonce or proof is given.`
+
+/*
+commands map the commands to the requests they send.
+*/
+var commands = map[string]string{
+	"version":   constants.Uds.Handshake.RequestVersion,
+	"challenge": constants.Uds.Handshake.RequestChallenge,
+	"connect":   constants.Uds.Handshake.RequestConnect,
+	"fd":        constants.Uds.Handshake.RequestFd,
+	"config":    constants.Uds.Handshake.RequestConfig,
+	"busypoll":  constants.Uds.Handshake.RequestBusyPoll,
+	"fin":       constants.Uds.Handshake.RequestFin,
+}
+
+/*
+session is a connection to the device plugin UDS, signing requests and verifying responses if
+signing is enabled.
+*/
+type session struct {
+	handler  uds.Handler
+	out      io.Writer
+	token    string
+	sign     bool
+	sequence int
+	nonce    string // nonce of the last challenge response, answered by the next connection request
+}
+
+func main() {
+	var socket string
+	var timeout int
+	var token string
+	var sign bool
+	flag.StringVar(&socket, "socket", constants.Uds.PodPath, "Location of the device plugin UDS, e.g. "+constants.Uds.SockDir+"<pool>/<dir>/"+constants.Uds.SockName+" on the node")
+	flag.IntVar(&timeout, "timeout", 10, "Seconds to wait for each response of the device plugin")
+	flag.StringVar(&token, "token", os.Getenv(constants.Uds.TokenEnvVar), "Handshake token sent in the connection request, the token given to the container by default")
+	flag.BoolVar(&sign, "sign", false, "Sign every message with the handshake token")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [request...]\n", os.Args[0])
+		flag.PrintDefaults()
+		fmt.Fprintln(flag.CommandLine.Output(), usage)
+	}
+	flag.Parse()
+
+	logging.SetOutput(ioutil.Discard)
+
+	if sign && token == "" {
+		fmt.Fprintln(os.Stderr, "Cannot sign without a handshake token, set -token or "+constants.Uds.TokenEnvVar)
+		os.Exit(1)
+	}
+
+	s := &session{handler: uds.NewHandler(), out: os.Stdout, token: token, sign: sign}
+	if err := s.handler.Init(socket, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, time.Duration(timeout)*time.Second, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "Error initialising UDS: %v\n", err)
+		os.Exit(1)
+	}
+	cleanup, err := s.handler.Dial()
+	defer cleanup()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error connecting to %s: %v\n", socket, err)
+		os.Exit(1)
+	}
+
+	var lines []string
+	interactive := false
+	if flag.NArg() > 0 {
+		lines = flag.Args()
+	} else if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+		fmt.Fprintln(s.out, "Connected to "+socket+", type help for the commands")
+	}
+
+	next := func() (string, bool) {
+		if len(lines) == 0 {
+			return "", false
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, true
+	}
+	if flag.NArg() == 0 {
+		scanner := bufio.NewScanner(os.Stdin)
+		next = func() (string, bool) {
+			if interactive {
+				fmt.Fprint(s.out, "udsctl> ")
+			}
+			if !scanner.Scan() {
+				return "", false
+			}
+			return scanner.Text(), true
+		}
+	}
+
+	for {
+		line, ok := next()
+		if !ok {
+			break
+		}
+		done, err := s.run(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if done {
+			break
+		}
+	}
+}
+
+/*
+run runs a command or raw request, returning true once the session is over.
+*/
+func (s *session) run(line string) (bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false, nil
+	}
+
+	switch fields[0] {
+	case "help":
+		fmt.Fprintln(s.out, usage)
+		return false, nil
+	case "quit", "exit":
+		return true, nil
+	}
+
+	request, err := s.request(line)
+	if err != nil {
+		fmt.Fprintln(s.out, err)
+		return false, nil
+	}
+
+	fmt.Fprintln(s.out, "> "+request)
+	response, fd, err := s.call(request)
+	if err != nil {
+		return true, err
+	}
+	fmt.Fprintln(s.out, "< "+response)
+	if fd > 0 {
+		fmt.Fprintln(s.out, "< fd "+strconv.Itoa(fd)+describeFd(fd))
+		syscall.Close(fd)
+	}
+
+	if nonce := strings.TrimPrefix(response, constants.Uds.Handshake.ResponseChallenge+", "); nonce != response {
+		s.nonce = nonce
+	}
+	if request == constants.Uds.Handshake.RequestFin {
+		return true, nil
+	}
+	return false, nil
+}
+
+/*
+request returns the request a line sends, the line itself if it is a raw request.
+*/
+func (s *session) request(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "/") {
+		return line, nil
+	}
+
+	fields := strings.Fields(line)
+	request, ok := commands[fields[0]]
+	if !ok {
+		return "", fmt.Errorf("unknown command %s, type help for the commands", fields[0])
+	}
+	args := fields[1:]
+
+	switch fields[0] {
+	case "fd", "config":
+		if len(args) != 1 {
+			return "", fmt.Errorf("%s takes a device", fields[0])
+		}
+	case "busypoll":
+		if len(args) != 2 {
+			return "", fmt.Errorf("busypoll takes a budget and a timeout")
+		}
+	case "connect":
+		if len(args) == 0 || strings.Contains(args[0], "=") {
+			hostname, err := os.Hostname()
+			if err != nil {
+				return "", fmt.Errorf("error getting hostname: %v", err)
+			}
+			args = append([]string{hostname}, args...)
+		}
+		args = append(args, s.proof(args)...)
+	}
+
+	for _, arg := range args {
+		request += ", " + arg
+	}
+	return request, nil
+}
+
+/*
+proof returns the fields proving the session holds the handshake token, unless the connection
+request already has them: the proof of the token if a challenge was answered, the nonce if there
+is no token, or the token.
+*/
+func (s *session) proof(args []string) []string {
+	for _, arg := range args {
+		for _, field := range []string{constants.Uds.Handshake.ConnectToken, constants.Uds.Handshake.ConnectNonce, constants.Uds.Handshake.ConnectProof} {
+			if strings.HasPrefix(arg, field) {
+				return nil
+			}
+		}
+	}
+
+	switch {
+	case s.nonce != "" && s.token != "":
+		return []string{constants.Uds.Handshake.ConnectProof + uds.ChallengeProof(s.token, s.nonce)}
+	case s.nonce != "":
+		return []string{constants.Uds.Handshake.ConnectNonce + s.nonce}
+	case s.token != "":
+		return []string{constants.Uds.Handshake.ConnectToken + s.token}
+	default:
+		return nil
+	}
+}
+
+/*
+call writes a request and reads its response, signing and verifying them if signing is enabled.
+*/
+func (s *session) call(request string) (string, int, error) {
+	if s.sign {
+		request = uds.Sign(s.token, uds.SignRequest, s.sequence, request)
+	}
+	if err := s.handler.Write(request, -1); err != nil {
+		return "", 0, fmt.Errorf("error writing request: %v", err)
+	}
+	s.sequence++
+
+	response, fd, err := s.handler.Read()
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading response, the device plugin may have closed the connection: %v", err)
+	}
+	if response == "" {
+		return "", 0, fmt.Errorf("connection closed by the device plugin")
+	}
+	if s.sign {
+		message, sig := uds.SplitSignature(response)
+		if !uds.VerifySignature(s.token, uds.SignResponse, s.sequence-1, message, sig) {
+			fmt.Fprintln(s.out, "< "+response)
+			return "", 0, fmt.Errorf("response signature does not verify")
+		}
+		response = message
+	}
+
+	return response, fd, nil
+}
+
+/*
+describeFd describes a file descriptor received, the BPF map it refers to if it is one.
+*/
+func describeFd(fd int) string {
+	target, err := os.Readlink("/proc/self/fd/" + strconv.Itoa(fd))
+	if err != nil {
+		return ""
+	}
+	description := " (" + target
+
+	info, err := ioutil.ReadFile("/proc/self/fdinfo/" + strconv.Itoa(fd))
+	if err == nil {
+		for _, line := range strings.Split(string(info), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			switch strings.TrimSuffix(fields[0], ":") {
+			case "map_type", "map_id", "max_entries":
+				description += ", " + strings.TrimSuffix(fields[0], ":") + "=" + fields[1]
+			}
+		}
+	}
+
+	return description + ")"
+}
@@ -18,44 +18,76 @@ package constants
 
 var (
 	/* Plugins */
-	pluginModes                   = []string{"primary", "cdq"} // accepted plugin modes
-	devicePluginDefaultConfigFile = "./config.json"            // device plugin default config file if none explicitly provided
-	devicePluginDevicePrefix      = "afxdp"                    // devive name prefix that the device plugin gives to devices, devices will be of type prefix/poolName
-	devicePluginExitNormal        = 0                          // device plugin normal exit code
-	devicePluginExitConfigError   = 1                          // device plugin config error exit code, problem with the provided config
-	devicePluginExitLogError      = 2                          // device plugin logging error exit code, error creating log file, bad log level, etc.
-	devicePluginExitHostError     = 3                          // device plugin host check exit code, error occurred checking some attribute of the host
-	devicePluginExitPoolError     = 4                          // device plugin device pool exit code, error occurred while building a device pool
-	devicePluginExitKindError     = 5                          // device plugin Kind exit code, error occurred while creating a kind secondary network
+	pluginsVersion                = "dev"                             // version of the plugins, set at build time with -ldflags "-X github.com/intel/afxdp-plugins-for-kubernetes/constants.pluginsVersion=<version>"
+	pluginsCommit                 = "unknown"                         // git commit the plugins were built from, set at build time with -ldflags "-X github.com/intel/afxdp-plugins-for-kubernetes/constants.pluginsCommit=<commit>"
+	pluginsBuildDate              = "unknown"                         // date the plugins were built, set at build time with -ldflags "-X github.com/intel/afxdp-plugins-for-kubernetes/constants.pluginsBuildDate=<date>"
+	pluginModes                   = []string{"primary", "cdq", "tap"} // accepted plugin modes
+	devicePluginDefaultConfigFile = "./config.json"                   // device plugin default config file if none explicitly provided
+	devicePluginDevicePrefix      = "afxdp"                           // devive name prefix that the device plugin gives to devices, devices will be of type prefix/poolName
+	devicePluginExitNormal        = 0                                 // device plugin normal exit code
+	devicePluginExitConfigError   = 1                                 // device plugin config error exit code, problem with the provided config
+	devicePluginExitLogError      = 2                                 // device plugin logging error exit code, error creating log file, bad log level, etc.
+	devicePluginExitHostError     = 3                                 // device plugin host check exit code, error occurred checking some attribute of the host
+	devicePluginExitPoolError     = 4                                 // device plugin device pool exit code, error occurred while building a device pool
+	devicePluginExitKindError     = 5                                 // device plugin Kind exit code, error occurred while creating a kind secondary network
+	devicePluginExitMetricsError  = 6                                 // device plugin metrics exit code, error occurred while starting the metrics server
+	devicePluginExitHealthError   = 7                                 // device plugin health exit code, error occurred while starting the health server
+	devicePluginExitProfileError  = 8                                 // device plugin profiling exit code, error occurred while starting the pprof server
+	devicePluginExitStatusError   = 9                                 // device plugin status exit code, error occurred while serving or getting the device plugin status
+	devicePluginExitPrereqError   = 10                                // device plugin prerequisite exit code, the device plugin failed a check that it can serve pods before registering with the kubelet
+	devicePluginExitLockError     = 11                                // device plugin lock exit code, another device plugin is running on the node
+	devicePluginLockFile          = "/tmp/afxdp_dp/afxdp-dp.lock"     // host location of the lock file held by the running device plugin, so only one runs per node
+	devicePluginShutdownTimeout   = 10                                // seconds the device plugin waits for kubelet calls in progress to finish when shutting down
+	devicePluginKubeletTimeout    = 5                                 // seconds to wait for the kubelet device plugin gRPC, connecting to and registering with the kubelet and test dialling a pool's gRPC server
+	devicePluginRegisterCheck     = 5                                 // seconds between checks that the device plugin socket of each pool still exists, a pool registers with the kubelet again once it is removed
+	devicePluginBuildAnnotation   = "afxdp.intel.com/plugin-build"    // container annotation holding the version, git commit and build date of the device plugin that allocated its devices
 
 	/* Kind Cluster */
 	kindCluster = false
 
 	/* Logging */
-	logLevels          = []string{"debug", "info", "warning", "error"} // accepted log levels
-	logDirectory       = "/var/log/afxdp-k8s-plugins/"                 // log file directory
-	logDirPermissions  = 0744                                          // permissions for log directory
-	logFilePermissions = 0644                                          // permissions for log file
-	logValidFileRegex  = `^[a-zA-Z0-9_-]+(\.log|\.txt)$`               // regex to check if a string is a valid log filename
+	logLevels          = []string{"trace", "debug", "info", "warn", "warning", "error"} // accepted log levels
+	logSubsystems      = []string{"uds", "deviceplugin", "cni", "bpf", "networking"}    // subsystems whose log level can be set individually
+	logLevelDefault    = "info"                                                         // log level used when none is configured
+	logLevelEnvVar     = "AFXDP_LOG_LEVEL"                                              // env var overriding the log level set in the config file
+	logDirectory       = "/var/log/afxdp-k8s-plugins/"                                  // log file directory
+	logDirPermissions  = 0744                                                           // permissions for log directory
+	logFilePermissions = 0644                                                           // permissions for log file
+	logValidFileRegex  = `^[a-zA-Z0-9_-]+(\.log|\.txt)$`                                // regex to check if a string is a valid log filename
+	logFileMaxSize     = 10                                                             // default size in MB at which a log file is rotated
+	logFileMaxSizeMax  = 1024                                                           // maximum configurable size in MB at which a log file is rotated
+	logFileBackups     = 3                                                              // default number of rotated log files kept
+	logFileBackupsMax  = 20                                                             // maximum configurable number of rotated log files kept
+	logDedupInterval   = 10                                                             // default interval in seconds within which identical log lines are suppressed
+	logDedupMax        = 3600                                                           // maximum configurable interval in seconds within which identical log lines are suppressed
+	logBackends        = []string{"stdout", "syslog"}                                   // where logs can be written, stdout is the default
+	logSyslogTag       = "afxdp-dp"                                                     // tag of the device plugin entries in the local syslog
+	logFormats         = []string{"text", "json"}                                       // formats logs can be written in, text is the default
 
 	/* Devices */
-	devicesProhibited    = []string{"eno", "eth", "lo", "docker", "flannel", "cni"} // interfaces we never add to a pool
-	devicesEnvVar        = "AFXDP_DEVICES"                                          // env var set in the end user application pod, lists AF_XDP devices attached
-	deviceValidNameRegex = `^[a-zA-Z0-9_-]+$`                                       // regex to check if a string is a valid device name
-	deviceValidNameMin   = 1                                                        // minimum length of a device name
-	deviceValidNameMax   = 50                                                       // maximum length of a device name
-	deviceValidPciRegex  = `[0-9a-f]{4}:[0-9a-f]{2,4}:[0-9a-f]{2}\.[0-9a-f]`        // regex to check if a string is a valid pci address
-	deviceSecondaryMin   = 1                                                        // minimum number of secondary devices that can be created on top of a primary device
-	deviceSecondaryMax   = 64                                                       // maximum number of secondary devices that can be created on top of a primary device
+	devicesProhibited     = []string{"eno", "eth", "lo", "docker", "flannel", "cni"} // interfaces we never add to a pool
+	devicesEnvVar         = "AFXDP_DEVICES"                                          // env var set in the end user application pod, lists AF_XDP devices attached
+	deviceValidNameRegex  = `^[a-zA-Z0-9_-]+$`                                       // regex to check if a string is a valid device name
+	deviceValidNameMin    = 1                                                        // minimum length of a device name
+	deviceValidNameMax    = 50                                                       // maximum length of a device name
+	deviceValidPciRegex   = `[0-9a-f]{4}:[0-9a-f]{2,4}:[0-9a-f]{2}\.[0-9a-f]`        // regex to check if a string is a valid pci address
+	deviceValidPciIdRegex = `^[0-9a-fA-F]{4}:[0-9a-fA-F]{4}$`                        // regex to check if a string is a valid pci vendor:device id
+	deviceSecondaryMin    = 1                                                        // minimum number of secondary devices that can be created on top of a primary device
+	deviceSecondaryMax    = 64                                                       // maximum number of secondary devices that can be created on top of a primary device
+	deviceHostManaged     = []string{"warn", "unmanage", "exclude"}                  // accepted actions for devices managed by NetworkManager or systemd-networkd
+	deviceHostManagedDef  = "warn"                                                   // default action for devices managed by NetworkManager or systemd-networkd
 
 	/* Drivers */
-	driversZeroCopy      = []string{"i40e", "E810", "ice", "veth"} // drivers that support zero copy AF_XDP
-	driversCdq           = []string{"ice"}                         // drivers that support CDQ subfunctions
-	driverValidNameRegex = `^[a-zA-Z0-9_-]+$`                      // regex to check if a string is a valid driver name
-	driverValidNameMin   = 1                                       // minimum length of a driver name
-	driverValidNameMax   = 50                                      // maximum length of a deiver name
-	driverPrimaryMin     = 1                                       // minimum number of primary devices a driver can take from a node
-	driverPrimaryMax     = 10                                      // maximum number of primary devices a driver can take from a node
+	driversZeroCopy          = []string{"i40e", "E810", "ice", "veth"}                                                                 // drivers that support zero copy AF_XDP
+	driversCdq               = []string{"ice"}                                                                                         // drivers that support CDQ subfunctions
+	driversNativeXdp         = []string{"i40e", "ice", "ixgbe", "igb", "igc", "mlx5_core", "mlx4_en", "bnxt_en", "virtio_net", "veth"} // drivers that support native (driver mode) XDP
+	driversVirtio            = []string{"virtio_net"}                                                                                  // paravirtual drivers that take queues from the device for XDP transmit
+	driverValidNameRegex     = `^[a-zA-Z0-9_-]+$`                                                                                      // regex to check if a string is a valid driver name
+	driverValidNameMin       = 1                                                                                                       // minimum length of a driver name
+	driverValidNameMax       = 50                                                                                                      // maximum length of a deiver name
+	driverPrimaryMin         = 1                                                                                                       // minimum number of primary devices a driver can take from a node
+	driverPrimaryMax         = 10                                                                                                      // maximum number of primary devices a driver can take from a node
+	driverValidFirmwareRegex = `^[0-9]+(\.[0-9]+)*$`                                                                                   // regex to check if a string is a valid minimum firmware version
 
 	/* Nodes */
 	nodeValidHostRegex = `^[a-zA-Z0-9-]+$` // regex to check if a string is a valid node name
@@ -66,36 +98,97 @@ var (
 	poolValidNameMin = 1  // minimum length of a pool name
 	poolValidNameMax = 20 // maximum length of a pool name
 
+	/* IRQ affinity */
+	irqAffinityPod           = "pod"                                    // pin queue IRQs to the exclusive CPUs of the pod, taken from the pod resources API
+	irqAffinityValidCpuRegex = `^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$` // regex to check if a string is a valid CPU list
+
+	/* Tap */
+	tapPrefix     = "afxdptap"   // name prefix of the tap devices the device plugin creates for tap mode pools
+	tapDevicesMin = 1            // minimum number of tap devices a tap mode pool can create
+	tapDevicesMax = 32           // maximum number of tap devices a tap mode pool can create
+	tapProbeName  = "afxdpprobe" // name of the tap device the device plugin attaches a test XDP program to at startup
+
 	/* UID */
 	uidMaximum = 256000 // maximum UID supported by BusyBox adduser
 	uidMinimum = 1000   // minimum non-reserved UID in Alpine
 
 	/* AF_XDP */
-	afxdpMinimumLinux = "4.18.0" // minimum Linux version for AF_XDP support
+	afxdpMinimumLinux     = "4.18.0"             // minimum Linux version for AF_XDP support
+	afxdpFrameSizes       = []int{2048, 4096}    // valid UMEM frame (chunk) sizes, frames cannot exceed 4K without multi-buffer support
+	afxdpFrameSizeDefault = 4096                 // default UMEM frame size
+	afxdpFrameHeadroom    = 256                  // XDP_PACKET_HEADROOM, reserved at the start of every UMEM frame
+	afxdpFrameL2Overhead  = 26                   // ethernet header, FCS and two VLAN tags, not counted in the MTU but stored in the frame
+	afxdpBpffsPath        = "/sys/fs/bpf"        // where the BPF filesystem is expected to be mounted
+	afxdpBpfPinDir        = "/sys/fs/bpf/afxdp/" // directory on the BPF filesystem for BPF objects pinned by the device plugin
+	afxdpBpfPinDirMode    = 0700                 // permissions for the BPF pin directory
+	afxdpMemcgKernel      = "5.11.0"             // Linux version from which BPF memory is charged to the memory cgroup rather than the locked memory limit
+	afxdpMemlockMin       = 16 << 20             // minimum locked memory limit in bytes for BPF maps on kernels before afxdpMemcgKernel
+	afxdpXdpPassObject    = "/afxdp/xdp_pass.o"  // object file of the XDP pass program, loaded onto the devices of the kind secondary network
 
 	/* UDS*/
 	udsMaxTimeout = 300               // maximum configurable uds timeout in seconds
 	udsMinTimeout = 30                // minimum (and default) uds timeout in seconds
-	udsMsgBufSize = 64                // uds message buffer size
+	udsMsgBufSize = 512               // uds message buffer size, holding a connection request with the pod name, namespace, UID and handshake token
 	udsCtlBufSize = 4                 // uds control buffer size
 	udsProtocol   = "unixpacket"      // uds protocol: "unix"=SOCK_STREAM, "unixdomain"=SOCK_DGRAM, "unixpacket"=SOCK_SEQPACKET
 	udsSockDir    = "/tmp/afxdp_dp/"  // host location where we place our uds sockets. If changing location remember to update daemonset mount point
 	udsPodPath    = "/tmp/afxdp.sock" // the uds filepath as it will appear in the end user application pod
 
-	udsDirFileMode = 0700 // permissions for the directory in which we create our uds sockets
+	udsPodNameEnvVar      = "AFXDP_POD_NAME"      // env var set in the end user application pod through the downward API, holds the pod name sent in the connection request
+	udsPodNamespaceEnvVar = "AFXDP_POD_NAMESPACE" // env var set in the end user application pod through the downward API, holds the pod namespace sent in the connection request
+	udsPodUidEnvVar       = "AFXDP_POD_UID"       // env var set in the end user application pod through the downward API, holds the pod UID sent in the connection request
+	udsTokenEnvVar        = "AFXDP_UDS_TOKEN"     // env var set in the end user application pod by the device plugin at allocation, holds the handshake token sent in the connection request
+	udsTokenBytes         = 16                    // number of random bytes of a handshake token, sent hex encoded
+	udsNonceBytes         = 16                    // number of random bytes of a challenge nonce, sent hex encoded
+
+	udsDirFileMode = 0700          // permissions for the directory in which we create our uds sockets
+	udsSockName    = "afxdp.sock"  // name of the uds socket file, within the directory created for each pod
+	udsPodDir      = "/run/afxdp/" // the directory holding the uds socket of the pod, as it will appear in the end user application pod
+
+	udsProfileCndp = "cndp"                                   // uds protocol profile of CNDP and the Go client library, the default
+	udsProfileDpdk = "dpdk"                                   // uds protocol profile of the DPDK af_xdp PMD, which sends no handshake token, challenge or signature
+	udsProfiles    = []string{udsProfileCndp, udsProfileDpdk} // accepted uds protocol profiles of a pool
+	udsDpdkPodDir  = "/tmp/afxdp_dp/"                         // the directory under which the DPDK af_xdp PMD looks for the uds of each interface, at <interface>/afxdp.sock
+
+	udsPodCheckInterval = 5 // interval in seconds at which a connected pod is checked to still exist, the connection is dropped once the pod is deleted
+
+	udsLockoutThreshold  = 5  // failed handshakes on a UDS after which it stops responding, until the pod is restarted
+	udsLockoutBackoff    = 1  // seconds after the first failed handshake on a UDS during which a further handshake is refused without validation, doubling with each failure
+	udsLockoutBackoffMax = 30 // maximum seconds after a failed handshake during which a further handshake is refused without validation
+
+	udsFaultDelayMax = 60000 // maximum milliseconds a response is delayed by fault injection
+
+	udsHandshakeUid = 65534 // user the thread serving the handshake switches to when its privileges are dropped, nobody
+	udsHandshakeGid = 65534 // group the thread serving the handshake switches to when its privileges are dropped, nogroup
 
 	/* Handshake*/
-	handshakeHandshakeVersion    = "0.1"                   // increase this version if changes are made to the protocol below
+	handshakeHandshakeVersion    = "0.4"                   // increase this version if changes are made to the protocol below
 	handshakeRequestVersion      = "/version"              // used to request the handshake version
+	handshakeVersionBuild        = "build"                 // optionally combined with the version request, the response then also gives the version, git commit and build date of the plugins
+	handshakeVersionClient       = "client="               // optionally combined with the version request, followed by the client library and its version, e.g. cndp/22.08.0, checked against the compatibility table
+	handshakeResponseVersionNak  = "/version_nak"          // the response given if the client library is known to be incompatible with the handshake version, combined with the reason, the connection is then closed
 	handshakeRequestConnect      = "/connect"              // used to request a new connection, this request will be combined with the podname
+	handshakeConnectName         = "name="                 // optionally combined with the connection request, followed by the pod name where it differs from the hostname
+	handshakeConnectNamespace    = "namespace="            // optionally combined with the connection request, followed by the pod namespace
+	handshakeConnectUid          = "uid="                  // optionally combined with the connection request, followed by the pod UID
+	handshakeConnectToken        = "token="                // optionally combined with the connection request, followed by the handshake token given to the container at allocation
+	handshakeConnectNonce        = "nonce="                // optionally combined with the connection request, followed by the nonce of the challenge response, echoed back
+	handshakeConnectProof        = "proof="                // optionally combined with the connection request in place of the token, followed by the hex HMAC-SHA256 of the nonce keyed with the handshake token
+	handshakeSignature           = "sig="                  // optionally appended to every message once the first request of the connection has it, followed by the hex HMAC-SHA256 of the message keyed with the handshake token
+	handshakeRequestChallenge    = "/challenge"            // optionally sent before the connection request, to obtain a nonce the connection request must answer, so it cannot be replayed
+	handshakeResponseChallenge   = "/challenge_ack"        // the response given to a challenge request, combined with the nonce
 	handshakeResponseHostOk      = "/host_ok"              // the response given if a valid podname was sent along with the connection request
 	handshakeResponseHostNak     = "/host_nak"             // the response given if an invalid podname was sent with the connection request
 	handshakeRequestFd           = "/xsk_map_fd"           // used to request the xsk map file descriptor for a network device, this request will be combined with the device name
 	handshakeResponseFdAck       = "/fd_ack"               // the response given if the xsk map file descriptor for a device can be provided, the file descriptor will be in the response control buffer
 	handshakeResponseFdNak       = "/fd_nak"               // the response given if there was a problem providing the xsk map file descriptor for a device, there will be no file descriptor included
+	handshakeResponseFdServed    = "/fd_nak_served"        // the response given if the xsk map file descriptor for a device was already provided on the connection, under the single use policy
 	handshakeRequestBusyPoll     = "/config_busy_poll"     // used to request configuration of busy poll, this request will be combined with busy budget and timeout values and a file descriptor in the rerquest control buffer
 	handshakeResponseBusyPollAck = "/config_busy_poll_ack" // the response given if busy poll was successfully configured
 	handshakeResponseBusyPollNak = "/config_busy_poll_nak" // the response given if there was a problem configuring busy poll
+	handshakeRequestConfig       = "/config"               // used to request the configuration of a network device, this request will be combined with the device name
+	handshakeResponseConfigAck   = "/config_ack"           // the response given if the device is known, this response will be combined with the device configuration, such as its bond peer
+	handshakeResponseConfigNak   = "/config_nak"           // the response given if the device is not known
 	handshakeRequestFin          = "/fin"                  // used to request connection termination
 	handshakeResponseFinAck      = "/fin_ack"              // the response given to acknowledge the connection termination request
 	handshakeResponseBadRequest  = "/nak"                  // general non-acknowledgement response, usually indicates a bad request
@@ -106,8 +199,139 @@ var (
 	directory       = "/tmp/afxdp_dp/" // host location where deviceFile file is placed.
 	filePermissions = 0600             // permissions for device file.
 
+	/*FlowRules*/
+	flowRulesFileName        = "flow_rules.json" // file recording which ntuple flow rules are owned by which allocation, placed in the deviceFile directory.
+	flowRulesFilePermissions = 0600              // permissions for the flow rules file.
+
+	/*Promiscuous*/
+	promiscuousFileName        = "promiscuous.json" // file recording the promiscuous state of devices prior to allocation, placed in the deviceFile directory.
+	promiscuousFilePermissions = 0600               // permissions for the promiscuous state file.
+
+	/*Allocations*/
+	allocationsFileName        = "allocations.json" // file recording which pod each device has been attached to by the CNI, placed in the deviceFile directory.
+	allocationsFilePermissions = 0600               // permissions for the allocations file.
+
+	/*Sockets*/
+	socketsFileName        = "sockets.json" // registry of the UDS sockets served by the device plugin, placed in the deviceFile directory.
+	socketsFilePermissions = 0600           // permissions for the socket registry file.
+
+	/*Journal*/
+	journalFileName        = "journal.json" // write-ahead journal of in-progress host networking changes, placed in the deviceFile directory.
+	journalFilePermissions = 0600           // permissions for the journal file.
+	journalStaleAfter      = 60             // seconds after which an unfinished CNI journal entry is considered abandoned and rolled back.
+
+	/*PodResources*/
+	podResourcesAllocatableInterval = 60                                                            // interval in seconds at which the devices advertised by a pool are cross-checked against the kubelet allocatable devices
+	podResourcesAllocatableSettle   = 10                                                            // delay in seconds before the first cross-check, giving the kubelet time to process the advertised devices
+	podResourcesCacheTTL            = 5                                                             // seconds for which pod resources are cached and shared by UDS servers validating pods, when pods are not tracked
+	podResourcesReconcileInterval   = 30                                                            // interval in seconds at which tracked pod resources are reconciled with the kubelet
+	podResourcesCheckpointPoll      = 1                                                             // interval in seconds at which the kubelet device checkpoint is checked for device assignment changes
+	podResourcesCheckpointFile      = "/var/lib/kubelet/device-plugins/kubelet_internal_checkpoint" // kubelet device manager checkpoint, rewritten whenever devices are assigned to or released from pods
+	podResourcesHealthCheckInterval = 10                                                            // interval in seconds at which the shared pod resources API connection is checked, and reopened if broken
+	podResourcesStallAfter          = 60                                                            // seconds without progress after which pod tracking is reported stalled to the liveness probe
+	podResourcesRestartWait         = 20                                                            // seconds to wait for a missing pod resources socket to be recreated by a restarting kubelet
+	podResourcesRestartPoll         = 100                                                           // interval in milliseconds at which a missing pod resources socket is checked for
+	podResourcesTimeout             = 5                                                             // seconds to wait for a pod resources API call, including connecting to the kubelet
+	podResourcesRetryAttempts       = 4                                                             // attempts at a pod resources API call before giving up, absorbing brief kubelet unavailability
+	podResourcesRetryBaseDelay      = 100                                                           // milliseconds before the first retry, doubling with each further retry
+	podResourcesRetryMaxDelay       = 1000                                                          // maximum milliseconds between retries
+	podResourcesDefaultSocket       = "/var/lib/kubelet/pod-resources/kubelet.sock"                 // kubelet pod resources socket, unless overridden in the config file or env var
+	podResourcesSocketEnvVar        = "AFXDP_POD_RESOURCES_SOCKET"                                  // env var overriding the pod resources socket, taking precedence over the config file
+	podResourcesValidSocketRegex    = `^(/[a-zA-Z0-9_.-]+)+\.sock$`                                 // regex to check if a string is a valid absolute socket path
+
+	/*ApiServer*/
+	apiServerHostEnvVar     = "KUBERNETES_SERVICE_HOST"                              // env var set in every pod, holds the Kubernetes API server address
+	apiServerPortEnvVar     = "KUBERNETES_SERVICE_PORT"                              // env var set in every pod, holds the Kubernetes API server port
+	apiServerNodeNameEnvVar = "AFXDP_NODE_NAME"                                      // env var set in the device plugin pod through the downward API, holds the node name if it differs from the hostname
+	apiServerTokenFile      = "/var/run/secrets/kubernetes.io/serviceaccount/token"  // service account token of the device plugin pod
+	apiServerCaFile         = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt" // CA certificate of the Kubernetes API server
+	apiServerTimeout        = 5                                                      // seconds to wait for a response from the Kubernetes API server
+
+	/* Events */
+	eventsComponent        = "afxdp-device-plugin"   // component reported as the source of Kubernetes Events
+	eventsNodeNamespace    = "default"               // namespace of Kubernetes Events about the node
+	eventsHandshakeRefused = "AfxdpHandshakeRefused" // reason of the event on a pod refused by the UDS server
+	eventsHandshakeLockout = "AfxdpHandshakeLockout" // reason of the event on a pod whose UDS stopped responding after repeated failed handshakes
+	eventsAllocateFailed   = "AfxdpAllocateFailed"   // reason of the event on the node when a pool fails to allocate devices
+	eventsDeviceLinkDown   = "AfxdpDeviceLinkDown"   // reason of the event on the node when the link of a pool device goes down
+
+	/*Metrics*/
+	metricsNamespace          = "afxdp"                             // prefix applied to all metric names
+	metricsPath               = "/metrics"                          // HTTP path on which metrics are served
+	metricsValidAddrRegex     = `^[a-zA-Z0-9.\-\[\]:]*:[0-9]{1,5}$` // regex to validate a metrics listen address, host:port or :port
+	metricsQueueStatsInterval = 15                                  // interval in seconds at which per-queue device statistics are collected
+	metricsMaxSeries          = 10000                               // default maximum number of series of a single metric, new series beyond this are dropped
+	metricsMaxSeriesMin       = 100                                 // minimum configurable maximum number of series of a single metric
+	metricsMaxSeriesMax       = 1000000                             // maximum configurable maximum number of series of a single metric
+	metricsDroppableLabels    = []string{"pci", "pod", "namespace"} // schema labels that may be dropped from all metrics to limit cardinality
+
+	/*Health*/
+	healthLivenessPath  = "/healthz" // HTTP path on which the liveness checks are served
+	healthReadinessPath = "/readyz"  // HTTP path on which the readiness checks are served
+
+	/*Profiling*/
+	profilingPath              = "/debug/pprof/" // HTTP path under which pprof profiles are served
+	profilingSocketPermissions = 0600            // permissions for the pprof UDS, only root may capture profiles
+
+	/*Status*/
+	statusSocketPath        = "/tmp/afxdp_dp/control.sock" // host location of the control socket on which the device plugin serves its status
+	statusSocketPermissions = 0600                         // permissions for the control socket, only root may read the status
+	statusPath              = "/status"                    // HTTP path on which the status is served over the control socket
+	statusTimeout           = 5                            // timeout in seconds of the status subcommand waiting for the device plugin
+	statusDumpFilePrefix    = "afxdp-dp-state-"            // prefix of the state dump files written to the log directory on SIGUSR1
+	statusDumpPermissions   = 0600                         // permissions for state dump files, only root may read them
+
+	/*Systemd*/
+	systemdListenFdsStart = 3         // first file descriptor of the sockets passed by systemd socket activation
+	systemdMetricsSocket  = "metrics" // FileDescriptorName= of a socket activated metrics socket
+	systemdHealthSocket   = "health"  // FileDescriptorName= of a socket activated health checks socket
+	systemdStatusSocket   = "status"  // FileDescriptorName= of a socket activated control socket
+
+	/*Crash*/
+	crashMaxRestarts  = 5 // number of times a component is restarted after a panic before it is left stopped
+	crashRestartDelay = 1 // delay in seconds before a component is restarted after a panic
+
+	/*ConfigFile*/
+	configFileVersions      = []string{"v1"} // versions of the config file format, versioned config files are strictly validated, rejecting unknown fields
+	configFileTimeoutMax    = 3600           // maximum configurable value in seconds of the timeouts section of the config file
+	configFileWatchInterval = 5              // interval in seconds at which the config file is checked for changes to reload, if it cannot be watched
+	configFileWatchSettle   = 100            // milliseconds without further events on the config file directory before the config file is reread
+	configFileEnvVarPrefix  = "AFXDP_DP_"    // prefix of the env vars overriding fields of the config file, e.g. AFXDP_DP_LOG_LEVEL
+	configFileDirModeRegex  = `^0?[0-7]{3}$` // regex to check if a string is a valid octal directory mode, e.g. 0750
+
+	configFileProfiles = []string{"dev", "prod", "ci"} // built in profiles, selected with the --profile flag, giving defaults for fields the config file does not set
+
+	/*Audit*/
+	auditFilePermissions = 0600 // permissions for the audit file, readable only by root as it names the workloads granted XSK map access
+
+	/*Tracing*/
+	tracingEndpointEnvVar     = "OTEL_EXPORTER_OTLP_ENDPOINT"               // standard OpenTelemetry env var, overrides the OTLP endpoint of the config file
+	tracingTracesPath         = "/v1/traces"                                // path appended to the OTLP endpoint to export spans
+	tracingServiceName        = "afxdp-device-plugin"                       // service name reported with exported spans
+	tracingTraceIdAnnotation  = "afxdp.intel.com/trace-id"                  // container annotation holding the trace ID of the allocation of its devices
+	tracingExportInterval     = 5                                           // interval in seconds at which finished spans are exported
+	tracingBatchSize          = 256                                         // maximum number of spans exported in one request
+	tracingQueueSize          = 2048                                        // maximum number of finished spans waiting to be exported, further spans are dropped
+	tracingTimeout            = 10                                          // seconds to wait for the OTLP endpoint to accept spans
+	tracingValidEndpointRegex = `^https?://[a-zA-Z0-9.\-\[\]:]+(/[^\s]*)?$` // regex to validate an OTLP/HTTP endpoint URL
+
+	/*Selinux*/
+	selinuxFsPath          = "/sys/fs/selinux"                                                                  // where the SELinux filesystem is mounted when SELinux is enabled
+	selinuxXattr           = "security.selinux"                                                                 // extended attribute holding the SELinux context of a file
+	selinuxValidLabelRegex = `^[a-zA-Z0-9_]+:[a-zA-Z0-9_]+:[a-zA-Z0-9_]+(:s[0-9]+(-s[0-9]+)?(:[a-z0-9.,]+)?)?$` // regex to validate an SELinux context, user:role:type with an optional MLS/MCS level
+
+	/*BpfSignature*/
+	bpfSignatureSuffix        = ".sig"                  // suffix of the detached signature of a BPF object file, kept next to the object file
+	bpfSignatureValidKeyRegex = `^(/[a-zA-Z0-9_.-]+)+$` // regex to validate the absolute path of the public key BPF object files are verified with
+
+	/*BpfHelper*/
+	bpfHelperDefaultSocket = "/run/afxdp-bpf-helper/helper.sock" // socket the BPF helper listens on, unless overridden on its command line
+	bpfHelperMsgBufSize    = 256                                 // size in bytes of the buffer for requests to and responses from the BPF helper
+	bpfHelperTimeout       = 10                                  // seconds to wait for the BPF helper to answer a request
+
 	/*EthtoolFilters*/
-	ethtoolFilterRegex = `^[a-zA-Z0-9-:.-/\s/g]+$` // regex to validate ethtool filter commands.
+	ethtoolFilterRegex = `^[a-zA-Z0-9-:.-/\s/g]+$`            // regex to validate ethtool filter commands.
+	rssHashKeyRegex    = `^[0-9a-fA-F]{2}(:[0-9a-fA-F]{2})*$` // regex to validate an RSS hash key, colon separated hex bytes.
 )
 
 /* Public variables and types */
@@ -128,12 +352,56 @@ var (
 	Nodes nodes
 	/* Pools contains constants related to device pools */
 	Pools pools
+	/* IrqAffinity contains constants related to pinning the queue IRQs of allocated devices */
+	IrqAffinity irqAffinity
+	/* Tap contains constants related to the tap devices of tap mode pools */
+	Tap tap
 	/* Uds contains constants related to the Unix domain sockets */
 	Uds uds
 	/* DeviceFile contains constants related to the devicefile */
 	DeviceFile deviceFile
 	/* DeviceFile contains constants related to the devicefile */
 	EthtoolFilter ethtoolFilter
+	/* FlowRules contains constants related to ntuple flow rule tracking */
+	FlowRules flowRules
+	/* Promiscuous contains constants related to promiscuous mode tracking */
+	Promiscuous promiscuous
+	/* Allocations contains constants related to tracking device to pod allocations */
+	Allocations allocations
+	/* Sockets contains constants related to the registry of UDS sockets */
+	Sockets sockets
+	/* Journal contains constants related to the journal of in-progress host networking changes */
+	Journal journal
+	/* PodResources contains constants related to the kubelet pod resources API */
+	PodResources podResources
+	/* ApiServer contains constants related to the Kubernetes API server, used when the pod resources API is unavailable */
+	ApiServer apiServer
+	/* Events contains constants related to the Kubernetes Events reported by the device plugin */
+	Events events
+	/* Metrics contains constants related to the metrics endpoint */
+	Metrics metrics
+	/* Health contains constants related to the liveness and readiness endpoints */
+	Health health
+	/* Profiling contains constants related to the pprof endpoint */
+	Profiling profiling
+	/* Status contains constants related to the control socket and status subcommand */
+	Status status
+	/* Systemd contains constants related to running the device plugin as a systemd service */
+	Systemd systemd
+	/* Crash contains constants related to recovering from panics in Go routines */
+	Crash crash
+	/* ConfigFile contains constants related to the format of the device plugin config file */
+	ConfigFile configFile
+	/* Audit contains constants related to the audit file of file descriptors passed to pods */
+	Audit audit
+	/* Tracing contains constants related to exporting OpenTelemetry traces */
+	Tracing tracing
+	/* Selinux contains constants related to labelling the sockets and directories created for pods */
+	Selinux selinux
+	/* BpfSignature contains constants related to verifying the signatures of BPF object files */
+	BpfSignature bpfSignature
+	/* BpfHelper contains constants related to the privileged helper loading BPF programs for the device plugin */
+	BpfHelper bpfHelper
 )
 
 type cni struct {
@@ -148,9 +416,23 @@ type devicePlugin struct {
 	ExitHostError     int
 	ExitPoolError     int
 	ExitKindError     int
+	ExitMetricsError  int
+	ExitHealthError   int
+	ExitProfileError  int
+	ExitStatusError   int
+	ExitPrereqError   int
+	ExitLockError     int
+	LockFile          string
+	ShutdownTimeout   int
+	KubeletTimeout    int
+	RegisterCheck     int
+	BuildAnnotation   string
 }
 
 type plugins struct {
+	Version      string
+	Commit       string
+	BuildDate    string
 	Modes        []string
 	Cni          cni
 	DevicePlugin devicePlugin
@@ -158,28 +440,44 @@ type plugins struct {
 }
 
 type afxdp struct {
-	MinumumKernel string
+	MinumumKernel    string
+	FrameSizes       []int
+	FrameSizeDefault int
+	FrameHeadroom    int
+	FrameL2Overhead  int
+	BpffsPath        string
+	BpfPinDir        string
+	BpfPinDirMode    int
+	MemcgKernel      string
+	MemlockMin       uint64
+	XdpPassObject    string
 }
 
 type drivers struct {
-	ZeroCopy       []string
-	Cdq            []string
-	ValidNameRegex string
-	ValidNameMin   int
-	ValidNameMax   int
-	PrimaryMin     int
-	PrimaryMax     int
+	ZeroCopy           []string
+	Cdq                []string
+	NativeXdp          []string
+	Virtio             []string
+	ValidNameRegex     string
+	ValidNameMin       int
+	ValidNameMax       int
+	PrimaryMin         int
+	PrimaryMax         int
+	ValidFirmwareRegex string
 }
 
 type devices struct {
-	Prohibited     []string
-	EnvVarList     string
-	ValidNameRegex string
-	ValidNameMin   int
-	ValidNameMax   int
-	ValidPciRegex  string
-	SecondaryMin   int
-	SecondaryMax   int
+	Prohibited      []string
+	EnvVarList      string
+	ValidNameRegex  string
+	ValidNameMin    int
+	ValidNameMax    int
+	ValidPciRegex   string
+	ValidPciIdRegex string
+	SecondaryMin    int
+	SecondaryMax    int
+	HostManaged     []string
+	HostManagedDef  string
 }
 
 type nodes struct {
@@ -200,10 +498,22 @@ type uid struct {
 
 type logging struct {
 	Levels               []string
+	Subsystems           []string
+	DefaultLevel         string
+	LevelEnvVar          string
 	Directory            string
 	DirectoryPermissions int
 	FilePermissions      int
 	ValidFileRegex       string
+	FileMaxSize          int
+	FileMaxSizeMax       int
+	FileBackups          int
+	FileBackupsMax       int
+	DedupInterval        int
+	DedupIntervalMax     int
+	Backends             []string
+	SyslogTag            string
+	Formats              []string
 }
 
 type uds struct {
@@ -215,21 +525,62 @@ type uds struct {
 	SockDir     string
 	DirFileMode int
 	PodPath     string
+	PodDir      string
+	SockName    string
 	Handshake   handshake
+
+	Profiles    []string
+	ProfileCndp string
+	ProfileDpdk string
+	DpdkPodDir  string
+
+	PodNameEnvVar      string
+	PodNamespaceEnvVar string
+	PodUidEnvVar       string
+	TokenEnvVar        string
+	TokenBytes         int
+	NonceBytes         int
+
+	PodCheckInterval int
+
+	LockoutThreshold  int
+	LockoutBackoff    int
+	LockoutBackoffMax int
+
+	FaultDelayMax int
+
+	HandshakeUid int
+	HandshakeGid int
 }
 
 type handshake struct {
 	Version             string
 	RequestVersion      string
+	VersionBuild        string
+	VersionClient       string
+	ResponseVersionNak  string
 	RequestConnect      string
+	ConnectName         string
+	ConnectNamespace    string
+	ConnectUid          string
+	ConnectToken        string
+	ConnectNonce        string
+	ConnectProof        string
+	Signature           string
+	RequestChallenge    string
+	ResponseChallenge   string
 	ResponseHostOk      string
 	ResponseHostNak     string
 	RequestFd           string
 	ResponseFdAck       string
 	ResponseFdNak       string
+	ResponseFdServed    string
 	RequestBusyPoll     string
 	ResponseBusyPollAck string
 	ResponseBusyPollNak string
+	RequestConfig       string
+	ResponseConfigAck   string
+	ResponseConfigNak   string
 	RequestFin          string
 	ResponseFinAck      string
 	ResponseBadRequest  string
@@ -242,12 +593,177 @@ type deviceFile struct {
 	Directory       string
 }
 
+type flowRules struct {
+	FileName        string
+	FilePermissions int
+}
+
+type promiscuous struct {
+	FileName        string
+	FilePermissions int
+}
+
+type allocations struct {
+	FileName        string
+	FilePermissions int
+}
+
+type sockets struct {
+	FileName        string
+	FilePermissions int
+}
+
+type journal struct {
+	FileName        string
+	FilePermissions int
+	StaleAfter      int
+}
+
+type apiServer struct {
+	HostEnvVar     string
+	PortEnvVar     string
+	NodeNameEnvVar string
+	TokenFile      string
+	CaFile         string
+	Timeout        int
+}
+
+type events struct {
+	Component        string
+	NodeNamespace    string
+	HandshakeRefused string
+	HandshakeLockout string
+	AllocateFailed   string
+	DeviceLinkDown   string
+}
+
+type podResources struct {
+	AllocatableInterval int
+	AllocatableSettle   int
+	CacheTTL            int
+	ReconcileInterval   int
+	CheckpointPoll      int
+	CheckpointFile      string
+	HealthCheckInterval int
+	StallAfter          int
+	RestartWait         int
+	RestartPoll         int
+	Timeout             int
+	RetryAttempts       int
+	RetryBaseDelay      int
+	RetryMaxDelay       int
+	DefaultSocket       string
+	SocketEnvVar        string
+	ValidSocketRegex    string
+}
+
+type metrics struct {
+	Namespace          string
+	Path               string
+	ValidAddrRegex     string
+	QueueStatsInterval int
+	MaxSeries          int
+	MaxSeriesMin       int
+	MaxSeriesMax       int
+	DroppableLabels    []string
+}
+
+type health struct {
+	LivenessPath  string
+	ReadinessPath string
+}
+
+type profiling struct {
+	Path              string
+	SocketPermissions int
+}
+
+type status struct {
+	SocketPath        string
+	SocketPermissions int
+	Path              string
+	Timeout           int
+	DumpFilePrefix    string
+	DumpPermissions   int
+}
+
+type systemd struct {
+	ListenFdsStart int
+	MetricsSocket  string
+	HealthSocket   string
+	StatusSocket   string
+}
+
+type crash struct {
+	MaxRestarts  int
+	RestartDelay int
+}
+
+type configFile struct {
+	Versions      []string
+	TimeoutMax    int
+	WatchInterval int
+	WatchSettle   int
+	EnvVarPrefix  string
+	DirModeRegex  string
+	Profiles      []string
+}
+
+type audit struct {
+	FilePermissions int
+}
+
+type tracing struct {
+	EndpointEnvVar     string
+	TracesPath         string
+	ServiceName        string
+	TraceIdAnnotation  string
+	ExportInterval     int
+	BatchSize          int
+	QueueSize          int
+	Timeout            int
+	ValidEndpointRegex string
+}
+
+type selinux struct {
+	FsPath          string
+	Xattr           string
+	ValidLabelRegex string
+}
+
+type bpfSignature struct {
+	Suffix        string
+	ValidKeyRegex string
+}
+
+type bpfHelper struct {
+	DefaultSocket string
+	MsgBufSize    int
+	Timeout       int
+}
+
+type irqAffinity struct {
+	Pod               string
+	ValidCpuListRegex string
+}
+
+type tap struct {
+	Prefix     string
+	DevicesMin int
+	DevicesMax int
+	ProbeName  string
+}
+
 type ethtoolFilter struct {
 	EthtoolFilterRegex string
+	RssHashKeyRegex    string
 }
 
 func init() {
 	Plugins = plugins{
+		Version:     pluginsVersion,
+		Commit:      pluginsCommit,
+		BuildDate:   pluginsBuildDate,
 		Modes:       pluginModes,
 		KindCluster: kindCluster,
 		DevicePlugin: devicePlugin{
@@ -259,32 +775,59 @@ func init() {
 			ExitHostError:     devicePluginExitHostError,
 			ExitPoolError:     devicePluginExitPoolError,
 			ExitKindError:     devicePluginExitKindError,
+			ExitMetricsError:  devicePluginExitMetricsError,
+			ExitHealthError:   devicePluginExitHealthError,
+			ExitProfileError:  devicePluginExitProfileError,
+			ExitStatusError:   devicePluginExitStatusError,
+			ExitPrereqError:   devicePluginExitPrereqError,
+			ExitLockError:     devicePluginExitLockError,
+			LockFile:          devicePluginLockFile,
+			ShutdownTimeout:   devicePluginShutdownTimeout,
+			KubeletTimeout:    devicePluginKubeletTimeout,
+			RegisterCheck:     devicePluginRegisterCheck,
+			BuildAnnotation:   devicePluginBuildAnnotation,
 		},
 	}
 
 	Afxdp = afxdp{
-		MinumumKernel: afxdpMinimumLinux,
+		MinumumKernel:    afxdpMinimumLinux,
+		FrameSizes:       afxdpFrameSizes,
+		FrameSizeDefault: afxdpFrameSizeDefault,
+		FrameHeadroom:    afxdpFrameHeadroom,
+		FrameL2Overhead:  afxdpFrameL2Overhead,
+		BpffsPath:        afxdpBpffsPath,
+		BpfPinDir:        afxdpBpfPinDir,
+		BpfPinDirMode:    afxdpBpfPinDirMode,
+		MemcgKernel:      afxdpMemcgKernel,
+		MemlockMin:       uint64(afxdpMemlockMin),
+		XdpPassObject:    afxdpXdpPassObject,
 	}
 
 	Drivers = drivers{
-		ZeroCopy:       driversZeroCopy,
-		Cdq:            driversCdq,
-		ValidNameRegex: driverValidNameRegex,
-		ValidNameMin:   driverValidNameMin,
-		ValidNameMax:   driverValidNameMax,
-		PrimaryMin:     driverPrimaryMin,
-		PrimaryMax:     driverPrimaryMax,
+		ZeroCopy:           driversZeroCopy,
+		Cdq:                driversCdq,
+		NativeXdp:          driversNativeXdp,
+		Virtio:             driversVirtio,
+		ValidNameRegex:     driverValidNameRegex,
+		ValidNameMin:       driverValidNameMin,
+		ValidNameMax:       driverValidNameMax,
+		PrimaryMin:         driverPrimaryMin,
+		PrimaryMax:         driverPrimaryMax,
+		ValidFirmwareRegex: driverValidFirmwareRegex,
 	}
 
 	Devices = devices{
-		Prohibited:     devicesProhibited,
-		EnvVarList:     devicesEnvVar,
-		ValidNameRegex: deviceValidNameRegex,
-		ValidNameMin:   deviceValidNameMin,
-		ValidNameMax:   deviceValidNameMax,
-		ValidPciRegex:  deviceValidPciRegex,
-		SecondaryMin:   deviceSecondaryMin,
-		SecondaryMax:   deviceSecondaryMax,
+		Prohibited:      devicesProhibited,
+		EnvVarList:      devicesEnvVar,
+		ValidNameRegex:  deviceValidNameRegex,
+		ValidNameMin:    deviceValidNameMin,
+		ValidNameMax:    deviceValidNameMax,
+		ValidPciRegex:   deviceValidPciRegex,
+		ValidPciIdRegex: deviceValidPciIdRegex,
+		SecondaryMin:    deviceSecondaryMin,
+		SecondaryMax:    deviceSecondaryMax,
+		HostManaged:     deviceHostManaged,
+		HostManagedDef:  deviceHostManagedDef,
 	}
 
 	Nodes = nodes{
@@ -305,10 +848,22 @@ func init() {
 
 	Logging = logging{
 		Levels:               logLevels,
+		Subsystems:           logSubsystems,
+		DefaultLevel:         logLevelDefault,
+		LevelEnvVar:          logLevelEnvVar,
 		Directory:            logDirectory,
 		DirectoryPermissions: logDirPermissions,
 		FilePermissions:      logFilePermissions,
 		ValidFileRegex:       logValidFileRegex,
+		FileMaxSize:          logFileMaxSize,
+		FileMaxSizeMax:       logFileMaxSizeMax,
+		FileBackups:          logFileBackups,
+		FileBackupsMax:       logFileBackupsMax,
+		DedupInterval:        logDedupInterval,
+		DedupIntervalMax:     logDedupMax,
+		Backends:             logBackends,
+		SyslogTag:            logSyslogTag,
+		Formats:              logFormats,
 	}
 
 	Uds = uds{
@@ -320,23 +875,62 @@ func init() {
 		SockDir:     udsSockDir,
 		DirFileMode: udsDirFileMode,
 		PodPath:     udsPodPath,
+		PodDir:      udsPodDir,
+		SockName:    udsSockName,
+		Profiles:    udsProfiles,
+		ProfileCndp: udsProfileCndp,
+		ProfileDpdk: udsProfileDpdk,
+		DpdkPodDir:  udsDpdkPodDir,
 		Handshake: handshake{
 			Version:             handshakeHandshakeVersion,
 			RequestVersion:      handshakeRequestVersion,
+			VersionBuild:        handshakeVersionBuild,
+			VersionClient:       handshakeVersionClient,
+			ResponseVersionNak:  handshakeResponseVersionNak,
 			RequestConnect:      handshakeRequestConnect,
+			ConnectName:         handshakeConnectName,
+			ConnectNamespace:    handshakeConnectNamespace,
+			ConnectUid:          handshakeConnectUid,
+			ConnectToken:        handshakeConnectToken,
+			ConnectNonce:        handshakeConnectNonce,
+			ConnectProof:        handshakeConnectProof,
+			Signature:           handshakeSignature,
+			RequestChallenge:    handshakeRequestChallenge,
+			ResponseChallenge:   handshakeResponseChallenge,
 			ResponseHostOk:      handshakeResponseHostOk,
 			ResponseHostNak:     handshakeResponseHostNak,
 			RequestFd:           handshakeRequestFd,
 			ResponseFdAck:       handshakeResponseFdAck,
 			ResponseFdNak:       handshakeResponseFdNak,
+			ResponseFdServed:    handshakeResponseFdServed,
 			RequestBusyPoll:     handshakeRequestBusyPoll,
 			ResponseBusyPollAck: handshakeResponseBusyPollAck,
 			ResponseBusyPollNak: handshakeResponseBusyPollNak,
+			RequestConfig:       handshakeRequestConfig,
+			ResponseConfigAck:   handshakeResponseConfigAck,
+			ResponseConfigNak:   handshakeResponseConfigNak,
 			RequestFin:          handshakeRequestFin,
 			ResponseFinAck:      handshakeResponseFinAck,
 			ResponseBadRequest:  handshakeResponseBadRequest,
 			ResponseError:       handshakeResponseError,
 		},
+		PodNameEnvVar:      udsPodNameEnvVar,
+		PodNamespaceEnvVar: udsPodNamespaceEnvVar,
+		PodUidEnvVar:       udsPodUidEnvVar,
+		TokenEnvVar:        udsTokenEnvVar,
+		TokenBytes:         udsTokenBytes,
+		NonceBytes:         udsNonceBytes,
+
+		PodCheckInterval: udsPodCheckInterval,
+
+		LockoutThreshold:  udsLockoutThreshold,
+		LockoutBackoff:    udsLockoutBackoff,
+		LockoutBackoffMax: udsLockoutBackoffMax,
+
+		FaultDelayMax: udsFaultDelayMax,
+
+		HandshakeUid: udsHandshakeUid,
+		HandshakeGid: udsHandshakeGid,
 	}
 
 	DeviceFile = deviceFile{
@@ -345,7 +939,169 @@ func init() {
 		Directory:       directory,
 	}
 
+	FlowRules = flowRules{
+		FileName:        flowRulesFileName,
+		FilePermissions: flowRulesFilePermissions,
+	}
+
+	Promiscuous = promiscuous{
+		FileName:        promiscuousFileName,
+		FilePermissions: promiscuousFilePermissions,
+	}
+
+	Allocations = allocations{
+		FileName:        allocationsFileName,
+		FilePermissions: allocationsFilePermissions,
+	}
+
+	Sockets = sockets{
+		FileName:        socketsFileName,
+		FilePermissions: socketsFilePermissions,
+	}
+
+	Journal = journal{
+		FileName:        journalFileName,
+		FilePermissions: journalFilePermissions,
+		StaleAfter:      journalStaleAfter,
+	}
+
+	PodResources = podResources{
+		AllocatableInterval: podResourcesAllocatableInterval,
+		AllocatableSettle:   podResourcesAllocatableSettle,
+		CacheTTL:            podResourcesCacheTTL,
+		ReconcileInterval:   podResourcesReconcileInterval,
+		CheckpointPoll:      podResourcesCheckpointPoll,
+		CheckpointFile:      podResourcesCheckpointFile,
+		HealthCheckInterval: podResourcesHealthCheckInterval,
+		StallAfter:          podResourcesStallAfter,
+		RestartWait:         podResourcesRestartWait,
+		RestartPoll:         podResourcesRestartPoll,
+		Timeout:             podResourcesTimeout,
+		RetryAttempts:       podResourcesRetryAttempts,
+		RetryBaseDelay:      podResourcesRetryBaseDelay,
+		RetryMaxDelay:       podResourcesRetryMaxDelay,
+		DefaultSocket:       podResourcesDefaultSocket,
+		SocketEnvVar:        podResourcesSocketEnvVar,
+		ValidSocketRegex:    podResourcesValidSocketRegex,
+	}
+
+	ApiServer = apiServer{
+		HostEnvVar:     apiServerHostEnvVar,
+		PortEnvVar:     apiServerPortEnvVar,
+		NodeNameEnvVar: apiServerNodeNameEnvVar,
+		TokenFile:      apiServerTokenFile,
+		CaFile:         apiServerCaFile,
+		Timeout:        apiServerTimeout,
+	}
+
+	Events = events{
+		Component:        eventsComponent,
+		NodeNamespace:    eventsNodeNamespace,
+		HandshakeRefused: eventsHandshakeRefused,
+		HandshakeLockout: eventsHandshakeLockout,
+		AllocateFailed:   eventsAllocateFailed,
+		DeviceLinkDown:   eventsDeviceLinkDown,
+	}
+
+	Metrics = metrics{
+		Namespace:          metricsNamespace,
+		Path:               metricsPath,
+		ValidAddrRegex:     metricsValidAddrRegex,
+		QueueStatsInterval: metricsQueueStatsInterval,
+		MaxSeries:          metricsMaxSeries,
+		MaxSeriesMin:       metricsMaxSeriesMin,
+		MaxSeriesMax:       metricsMaxSeriesMax,
+		DroppableLabels:    metricsDroppableLabels,
+	}
+
+	Health = health{
+		LivenessPath:  healthLivenessPath,
+		ReadinessPath: healthReadinessPath,
+	}
+
+	Profiling = profiling{
+		Path:              profilingPath,
+		SocketPermissions: profilingSocketPermissions,
+	}
+
+	Status = status{
+		SocketPath:        statusSocketPath,
+		SocketPermissions: statusSocketPermissions,
+		Path:              statusPath,
+		Timeout:           statusTimeout,
+		DumpFilePrefix:    statusDumpFilePrefix,
+		DumpPermissions:   statusDumpPermissions,
+	}
+
+	Systemd = systemd{
+		ListenFdsStart: systemdListenFdsStart,
+		MetricsSocket:  systemdMetricsSocket,
+		HealthSocket:   systemdHealthSocket,
+		StatusSocket:   systemdStatusSocket,
+	}
+
+	Crash = crash{
+		MaxRestarts:  crashMaxRestarts,
+		RestartDelay: crashRestartDelay,
+	}
+
+	ConfigFile = configFile{
+		Versions:      configFileVersions,
+		TimeoutMax:    configFileTimeoutMax,
+		WatchInterval: configFileWatchInterval,
+		WatchSettle:   configFileWatchSettle,
+		EnvVarPrefix:  configFileEnvVarPrefix,
+		DirModeRegex:  configFileDirModeRegex,
+		Profiles:      configFileProfiles,
+	}
+
+	Audit = audit{
+		FilePermissions: auditFilePermissions,
+	}
+
+	Tracing = tracing{
+		EndpointEnvVar:     tracingEndpointEnvVar,
+		TracesPath:         tracingTracesPath,
+		ServiceName:        tracingServiceName,
+		TraceIdAnnotation:  tracingTraceIdAnnotation,
+		ExportInterval:     tracingExportInterval,
+		BatchSize:          tracingBatchSize,
+		QueueSize:          tracingQueueSize,
+		Timeout:            tracingTimeout,
+		ValidEndpointRegex: tracingValidEndpointRegex,
+	}
+
+	Selinux = selinux{
+		FsPath:          selinuxFsPath,
+		Xattr:           selinuxXattr,
+		ValidLabelRegex: selinuxValidLabelRegex,
+	}
+
+	BpfSignature = bpfSignature{
+		Suffix:        bpfSignatureSuffix,
+		ValidKeyRegex: bpfSignatureValidKeyRegex,
+	}
+
+	BpfHelper = bpfHelper{
+		DefaultSocket: bpfHelperDefaultSocket,
+		MsgBufSize:    bpfHelperMsgBufSize,
+		Timeout:       bpfHelperTimeout,
+	}
+
+	IrqAffinity = irqAffinity{
+		Pod:               irqAffinityPod,
+		ValidCpuListRegex: irqAffinityValidCpuRegex,
+	}
+
+	Tap = tap{
+		Prefix:     tapPrefix,
+		DevicesMin: tapDevicesMin,
+		DevicesMax: tapDevicesMax,
+		ProbeName:  tapProbeName,
+	}
+
 	EthtoolFilter = ethtoolFilter{
 		EthtoolFilterRegex: ethtoolFilterRegex,
+		RssHashKeyRegex:    rssHashKeyRegex,
 	}
 }
@@ -0,0 +1,112 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+The AF_XDP echo application is an example of a Go application using AF_XDP devices given to its
+pod by the device plugin. It fetches the XSK map file descriptor of a device through the Go client
+library, binds an AF_XDP socket to a queue of the device, inserts it into the XSK map, and sends
+every packet it receives back out of the device with its Ethernet addresses swapped. It logs the
+packets echoed until it is stopped, or for a given duration, as a smoke test of a pod's devices.
+*/
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/pkg/goclient"
+)
+
+func main() {
+	var device string
+	var queue int
+	var frames int
+	var duration time.Duration
+	devices := strings.Fields(os.Getenv(constants.Devices.EnvVarList))
+	if len(devices) > 0 {
+		device = devices[0]
+	}
+	flag.StringVar(&device, "device", device, "Device to echo packets on, the first device given to the container by default")
+	flag.IntVar(&queue, "queue", 0, "Queue of the device the AF_XDP socket is bound to")
+	flag.IntVar(&frames, "frames", 4096, "Number of frames in the UMEM, a power of two")
+	flag.DurationVar(&duration, "duration", 0, "How long to echo packets for, until stopped by default")
+	flag.Parse()
+
+	if device == "" {
+		log.Fatalf("No device, %s is not set", constants.Devices.EnvVarList)
+	}
+	if frames < 2 || frames&(frames-1) != 0 {
+		log.Fatalf("Frames must be a power of two, not %d", frames)
+	}
+
+	version, cleanup, err := goclient.GetServerVersion()
+	defer cleanup()
+	if err != nil {
+		log.Fatalf("Error connecting to the device plugin: %v", err)
+	}
+	log.Printf("Connected to the device plugin, handshake version %s", version)
+
+	mapFd, _, err := goclient.RequestXSKmapFD(device)
+	if err != nil {
+		log.Fatalf("Error requesting the XSK map of %s: %v", device, err)
+	}
+	defer syscall.Close(mapFd)
+
+	x, err := newXsk(device, queue, frames, constants.Afxdp.FrameSizeDefault)
+	if err != nil {
+		log.Fatalf("Error creating AF_XDP socket on %s: %v", device, err)
+	}
+	defer x.close()
+	if err := x.insert(mapFd, queue); err != nil {
+		log.Fatalf("Error on %s: %v", device, err)
+	}
+	log.Printf("Echoing packets on %s queue %d", device, queue)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	var end <-chan time.Time
+	if duration > 0 {
+		end = time.After(duration)
+	}
+	report := time.NewTicker(10 * time.Second)
+	defer report.Stop()
+
+	echoed := 0
+	dropped := 0
+	for {
+		select {
+		case <-stop:
+			log.Printf("Stopped, %d packets echoed, %d dropped", echoed, dropped)
+			return
+		case <-end:
+			log.Printf("Done, %d packets echoed, %d dropped", echoed, dropped)
+			return
+		case <-report.C:
+			log.Printf("%d packets echoed, %d dropped", echoed, dropped)
+		default:
+		}
+
+		x.wait(100)
+		e, d := x.echo()
+		echoed += e
+		dropped += d
+	}
+}
@@ -0,0 +1,415 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+/*
+AF_XDP and BPF values from the kernel headers, not in the syscall package.
+*/
+const (
+	afXdp                      = 44          // AF_XDP
+	solXdp                     = 283         // SOL_XDP
+	xdpMmapOffsets             = 1           // XDP_MMAP_OFFSETS
+	xdpRxRing                  = 2           // XDP_RX_RING
+	xdpTxRing                  = 3           // XDP_TX_RING
+	xdpUmemReg                 = 4           // XDP_UMEM_REG
+	xdpUmemFillRing            = 5           // XDP_UMEM_FILL_RING
+	xdpUmemCompletionRing      = 6           // XDP_UMEM_COMPLETION_RING
+	xdpPgoffRxRing             = 0           // XDP_PGOFF_RX_RING
+	xdpPgoffTxRing             = 0x80000000  // XDP_PGOFF_TX_RING
+	xdpUmemPgoffFillRing       = 0x100000000 // XDP_UMEM_PGOFF_FILL_RING
+	xdpUmemPgoffCompletionRing = 0x180000000 // XDP_UMEM_PGOFF_COMPLETION_RING
+	bpfMapUpdateElem           = 2           // BPF_MAP_UPDATE_ELEM
+	sysBpf                     = 321         // bpf syscall number on amd64, the architecture the plugins are built for
+)
+
+/*
+xdpUmemRegV1 is struct xdp_umem_reg without the flags added in later kernels, accepted by every
+kernel with AF_XDP.
+*/
+type xdpUmemRegV1 struct {
+	addr      uint64
+	len       uint64
+	chunkSize uint32
+	headroom  uint32
+}
+
+/*
+sockaddrXdp is struct sockaddr_xdp.
+*/
+type sockaddrXdp struct {
+	family       uint16
+	flags        uint16
+	ifindex      uint32
+	queueId      uint32
+	sharedUmemFd uint32
+}
+
+/*
+bpfMapElemAttr is the part of union bpf_attr used by BPF_MAP_UPDATE_ELEM.
+*/
+type bpfMapElemAttr struct {
+	mapFd uint32
+	_     uint32
+	key   uint64
+	value uint64
+	flags uint64
+}
+
+/*
+xdpDesc is struct xdp_desc, an entry of the RX and TX rings: a packet in the UMEM.
+*/
+type xdpDesc struct {
+	addr    uint64
+	len     uint32
+	options uint32
+}
+
+/*
+ringOffsets are the offsets of the producer, consumer and entries of a ring in its mapping, from
+struct xdp_ring_offset.
+*/
+type ringOffsets struct {
+	producer uint64
+	consumer uint64
+	desc     uint64
+}
+
+/*
+ring is a ring shared with the kernel. Entries are produced and consumed by moving the producer and
+consumer indexes, which only ever increase and are masked to index the entries.
+*/
+type ring struct {
+	mem      []byte
+	producer *uint32
+	consumer *uint32
+	size     uint32
+	addrs    []uint64  // entries of the fill and completion rings
+	descs    []xdpDesc // entries of the RX and TX rings
+}
+
+/*
+xsk is an AF_XDP socket bound to a queue of a device, with a UMEM of its own.
+*/
+type xsk struct {
+	fd         int
+	umem       []byte
+	frameSize  uint64
+	free       []uint64 // frames in no ring, available to the fill ring
+	fill       ring
+	completion ring
+	rx         ring
+	tx         ring
+}
+
+/*
+newXsk creates an AF_XDP socket with a UMEM of frames frames of frameSize bytes, maps its rings,
+hands half the frames to the kernel to receive packets in, and binds it to the queue of the
+device.
+*/
+func newXsk(device string, queue int, frames int, frameSize int) (*xsk, error) {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return nil, fmt.Errorf("error finding device: %v", err)
+	}
+
+	fd, err := syscall.Socket(afXdp, syscall.SOCK_RAW, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AF_XDP socket: %v", err)
+	}
+	x := &xsk{fd: fd, frameSize: uint64(frameSize)}
+
+	if err := x.setup(iface.Index, queue, frames); err != nil {
+		x.close()
+		return nil, err
+	}
+
+	return x, nil
+}
+
+func (x *xsk) setup(ifindex int, queue int, frames int) error {
+	var err error
+	x.umem, err = syscall.Mmap(-1, 0, frames*int(x.frameSize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANONYMOUS)
+	if err != nil {
+		return fmt.Errorf("error allocating UMEM: %v", err)
+	}
+	reg := xdpUmemRegV1{
+		addr:      uint64(uintptr(unsafe.Pointer(&x.umem[0]))),
+		len:       uint64(len(x.umem)),
+		chunkSize: uint32(x.frameSize),
+	}
+	if err := setsockopt(x.fd, xdpUmemReg, unsafe.Pointer(&reg), unsafe.Sizeof(reg)); err != nil {
+		return fmt.Errorf("error registering UMEM: %v", err)
+	}
+
+	// each ring holds half the frames, so the frames being received and being sent fit
+	size := uint32(frames / 2)
+	for _, option := range []int{xdpUmemFillRing, xdpUmemCompletionRing, xdpRxRing, xdpTxRing} {
+		if err := setsockopt(x.fd, option, unsafe.Pointer(&size), unsafe.Sizeof(size)); err != nil {
+			return fmt.Errorf("error sizing ring %d: %v", option, err)
+		}
+	}
+
+	offsets, err := mmapOffsets(x.fd)
+	if err != nil {
+		return err
+	}
+	for _, r := range []struct {
+		ring    *ring
+		offsets ringOffsets
+		pgoff   int64
+		desc    bool
+	}{
+		{&x.rx, offsets[0], xdpPgoffRxRing, true},
+		{&x.tx, offsets[1], xdpPgoffTxRing, true},
+		{&x.fill, offsets[2], xdpUmemPgoffFillRing, false},
+		{&x.completion, offsets[3], xdpUmemPgoffCompletionRing, false},
+	} {
+		if err := r.ring.mmap(x.fd, r.offsets, r.pgoff, size, r.desc); err != nil {
+			return err
+		}
+	}
+
+	for frame := 0; frame < frames; frame++ {
+		x.free = append(x.free, uint64(frame)*x.frameSize)
+	}
+	x.refill()
+
+	addr := sockaddrXdp{
+		family:  afXdp,
+		ifindex: uint32(ifindex),
+		queueId: uint32(queue),
+	}
+	if _, _, errno := syscall.Syscall(syscall.SYS_BIND, uintptr(x.fd), uintptr(unsafe.Pointer(&addr)), unsafe.Sizeof(addr)); errno != 0 {
+		return fmt.Errorf("error binding AF_XDP socket to queue %d: %v", queue, errno)
+	}
+
+	return nil
+}
+
+/*
+insert inserts the socket into the XSK map given by the device plugin, at the key of its queue,
+so the XDP program attached to the device redirects the packets of the queue to it.
+*/
+func (x *xsk) insert(mapFd int, queue int) error {
+	key := uint32(queue)
+	value := uint32(x.fd)
+	attr := bpfMapElemAttr{
+		mapFd: uint32(mapFd),
+		key:   uint64(uintptr(unsafe.Pointer(&key))),
+		value: uint64(uintptr(unsafe.Pointer(&value))),
+	}
+	if _, _, errno := syscall.Syscall(sysBpf, bpfMapUpdateElem, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr)); errno != 0 {
+		return fmt.Errorf("error inserting AF_XDP socket into the XSK map: %v", errno)
+	}
+
+	return nil
+}
+
+/*
+close closes the socket, removing it from the XSK map, and unmaps its rings and UMEM.
+*/
+func (x *xsk) close() {
+	for _, r := range []*ring{&x.rx, &x.tx, &x.fill, &x.completion} {
+		if r.mem != nil {
+			syscall.Munmap(r.mem)
+		}
+	}
+	syscall.Close(x.fd)
+	if x.umem != nil {
+		syscall.Munmap(x.umem)
+	}
+}
+
+/*
+wait waits up to timeout milliseconds for packets to be received.
+*/
+func (x *xsk) wait(timeout int) {
+	fds := []struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{{fd: int32(x.fd), events: 0x1}} // POLLIN
+	syscall.Syscall(syscall.SYS_POLL, uintptr(unsafe.Pointer(&fds[0])), 1, uintptr(timeout))
+}
+
+/*
+echo sends every packet received back out of the queue it came in on, with its Ethernet source and
+destination swapped. The frame a packet is received in is sent as it is, without copying. Packets
+that do not fit the TX ring are dropped. It returns the number of packets echoed and dropped.
+*/
+func (x *xsk) echo() (int, int) {
+	x.complete()
+
+	received := x.rx.consumable()
+	sendable := x.tx.producible()
+	echoed := 0
+	dropped := 0
+	for i := uint32(0); i < received; i++ {
+		desc := x.rx.descs[(*x.rx.consumer+i)&(x.rx.size-1)]
+		if uint32(echoed) == sendable {
+			x.free = append(x.free, desc.addr-desc.addr%x.frameSize)
+			dropped++
+			continue
+		}
+
+		packet := x.umem[desc.addr : desc.addr+uint64(desc.len)]
+		if len(packet) >= 12 {
+			var mac [6]byte
+			copy(mac[:], packet[0:6])
+			copy(packet[0:6], packet[6:12])
+			copy(packet[6:12], mac[:])
+		}
+		x.tx.descs[(*x.tx.producer+uint32(echoed))&(x.tx.size-1)] = desc
+		echoed++
+	}
+	x.rx.consume(received)
+	x.tx.produce(uint32(echoed))
+
+	if echoed > 0 {
+		// the kernel sends the TX ring when woken by a send, which only fails if it is busy
+		syscall.Syscall6(syscall.SYS_SENDTO, uintptr(x.fd), 0, 0, syscall.MSG_DONTWAIT, 0, 0)
+	}
+	x.refill()
+
+	return echoed, dropped
+}
+
+/*
+complete takes back the frames the kernel has finished sending.
+*/
+func (x *xsk) complete() {
+	n := x.completion.consumable()
+	for i := uint32(0); i < n; i++ {
+		addr := x.completion.addrs[(*x.completion.consumer+i)&(x.completion.size-1)]
+		x.free = append(x.free, addr-addr%x.frameSize)
+	}
+	x.completion.consume(n)
+}
+
+/*
+refill hands free frames to the kernel to receive packets in.
+*/
+func (x *xsk) refill() {
+	n := x.fill.producible()
+	if n > uint32(len(x.free)) {
+		n = uint32(len(x.free))
+	}
+	for i := uint32(0); i < n; i++ {
+		x.fill.addrs[(*x.fill.producer+i)&(x.fill.size-1)] = x.free[len(x.free)-1]
+		x.free = x.free[:len(x.free)-1]
+	}
+	x.fill.produce(n)
+}
+
+/*
+mmap maps a ring of size entries, of descriptors or of UMEM addresses.
+*/
+func (r *ring) mmap(fd int, offsets ringOffsets, pgoff int64, size uint32, desc bool) error {
+	entrySize := unsafe.Sizeof(uint64(0))
+	if desc {
+		entrySize = unsafe.Sizeof(xdpDesc{})
+	}
+
+	mem, err := syscall.Mmap(fd, pgoff, int(uintptr(offsets.desc)+uintptr(size)*entrySize), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED|syscall.MAP_POPULATE)
+	if err != nil {
+		return fmt.Errorf("error mapping ring: %v", err)
+	}
+
+	r.mem = mem
+	r.size = size
+	r.producer = (*uint32)(unsafe.Pointer(&mem[offsets.producer]))
+	r.consumer = (*uint32)(unsafe.Pointer(&mem[offsets.consumer]))
+	if desc {
+		r.descs = (*[1 << 24]xdpDesc)(unsafe.Pointer(&mem[offsets.desc]))[:size:size]
+	} else {
+		r.addrs = (*[1 << 24]uint64)(unsafe.Pointer(&mem[offsets.desc]))[:size:size]
+	}
+
+	return nil
+}
+
+/*
+consumable returns the number of entries produced by the kernel and not yet consumed.
+*/
+func (r *ring) consumable() uint32 {
+	return atomic.LoadUint32(r.producer) - *r.consumer
+}
+
+/*
+consume releases n entries back to the kernel, once they are read.
+*/
+func (r *ring) consume(n uint32) {
+	atomic.StoreUint32(r.consumer, *r.consumer+n)
+}
+
+/*
+producible returns the number of entries that can be produced before the ring is full.
+*/
+func (r *ring) producible() uint32 {
+	return r.size - (*r.producer - atomic.LoadUint32(r.consumer))
+}
+
+/*
+produce passes n entries to the kernel, once they are written.
+*/
+func (r *ring) produce(n uint32) {
+	atomic.StoreUint32(r.producer, *r.producer+n)
+}
+
+/*
+mmapOffsets returns the offsets of the RX, TX, fill and completion rings in their mappings. Kernels
+before 5.4 give them without the flags field, so each is three fields rather than four.
+*/
+func mmapOffsets(fd int) ([4]ringOffsets, error) {
+	var offsets [4]ringOffsets
+	var raw [16]uint64
+	size := uint32(unsafe.Sizeof(raw))
+	if _, _, errno := syscall.Syscall6(syscall.SYS_GETSOCKOPT, uintptr(fd), solXdp, xdpMmapOffsets, uintptr(unsafe.Pointer(&raw)), uintptr(unsafe.Pointer(&size)), 0); errno != 0 {
+		return offsets, fmt.Errorf("error getting ring offsets: %v", errno)
+	}
+
+	fields := 4
+	if size == uint32(unsafe.Sizeof(raw))*3/4 {
+		fields = 3
+	}
+	for i := range offsets {
+		offsets[i] = ringOffsets{
+			producer: raw[i*fields],
+			consumer: raw[i*fields+1],
+			desc:     raw[i*fields+2],
+		}
+	}
+
+	return offsets, nil
+}
+
+/*
+setsockopt sets an SOL_XDP option of the socket.
+*/
+func setsockopt(fd int, option int, value unsafe.Pointer, size uintptr) error {
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETSOCKOPT, uintptr(fd), solXdp, uintptr(option), uintptr(value), size, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
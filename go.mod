@@ -5,9 +5,11 @@ go 1.13
 require (
 	github.com/containernetworking/cni v1.1.2
 	github.com/containernetworking/plugins v1.1.1
+	github.com/fsnotify/fsnotify v1.4.9
 	github.com/go-ozzo/ozzo-validation/v4 v4.3.0
 	github.com/google/gofuzz v1.1.0
 	github.com/google/uuid v1.3.0
+	github.com/intel/afxdp-plugins-for-kubernetes/pkg/goclient v0.0.0
 	github.com/intel/afxdp-plugins-for-kubernetes/pkg/subfunctions v0.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/safchain/ethtool v0.0.0-20210803160452-9aa261dae9b1
@@ -22,3 +24,5 @@ require (
 )
 
 replace github.com/intel/afxdp-plugins-for-kubernetes/pkg/subfunctions => ./pkg/subfunctions
+
+replace github.com/intel/afxdp-plugins-for-kubernetes/pkg/goclient => ./pkg/goclient
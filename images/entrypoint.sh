@@ -22,4 +22,4 @@ CNI_BIN="afxdp"
 CNI_BIN_DIR="/opt/cni/bin"
 
 cp -f $BINS_DIR/$CNI_BIN $CNI_BIN_DIR/$CNI_BIN
-exec $BINS_DIR/$DP_BIN -config $DP_CONFIG_FILE
+exec $BINS_DIR/$DP_BIN -config $DP_CONFIG_FILE "$@"
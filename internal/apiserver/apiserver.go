@@ -0,0 +1,425 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apiserver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Handler is the device plugins interface to the Kubernetes API server.
+It is used to validate pods when the kubelet pod resources API is unavailable,
+to report failures as Kubernetes Events, and to get the node metadata that
+templates in the config file are resolved with.
+The interface exists for testing purposes, allowing unit tests to test
+against a fake API.
+*/
+type Handler interface {
+	GetNodePods() ([]*Pod, error)
+	GetNode() (*Node, error)
+	CreateEvent(event *Event) error
+}
+
+/*
+Node is the metadata of this node, as seen by the API server.
+*/
+type Node struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+/*
+Pod is a pod scheduled to this node, as seen by the API server.
+*/
+type Pod struct {
+	Name       string
+	Namespace  string
+	Uid        string
+	Containers []*Container
+}
+
+/*
+Container is a container of a Pod. Resources holds the extended resources the container
+limits, such as device plugin pool resources, and their counts.
+*/
+type Container struct {
+	Name      string
+	Resources map[string]int
+}
+
+/*
+Event is a Warning Event about a pod or, if Pod is empty, about this node.
+Reason is a short CamelCase reason, and Message the actionable detail shown by kubectl describe.
+*/
+type Event struct {
+	Pod       string
+	Namespace string
+	Uid       string
+	Reason    string
+	Message   string
+}
+
+/*
+handler implements the Handler interface.
+*/
+type handler struct {
+	nodeName string
+}
+
+/*
+NewHandler returns an implementation of the Handler interface, for the pods of nodeName.
+*/
+func NewHandler(nodeName string) Handler {
+	return &handler{nodeName: nodeName}
+}
+
+/*
+GetNodePods lists the pods scheduled to this node, using the service account of the device plugin.
+*/
+func (r *handler) GetNodePods() ([]*Pod, error) {
+	query := url.Values{"fieldSelector": {"spec.nodeName=" + r.nodeName}}
+
+	logging.Debugf("Requesting pods of node %s from the API server", r.nodeName)
+	body, err := r.request(http.MethodGet, "/api/v1/pods?"+query.Encode(), nil, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pods: %w", err)
+	}
+
+	return parsePodList(body)
+}
+
+/*
+GetNode gets the metadata of this node, using the service account of the device plugin.
+*/
+func (r *handler) GetNode() (*Node, error) {
+	logging.Debugf("Requesting node %s from the API server", r.nodeName)
+	body, err := r.request(http.MethodGet, "/api/v1/nodes/"+url.PathEscape(r.nodeName), nil, http.StatusOK)
+	if err != nil {
+		return nil, fmt.Errorf("error getting node %s: %w", r.nodeName, err)
+	}
+
+	return parseNode(body)
+}
+
+/*
+CreateEvent creates a Warning Event about a pod, or about this node, using the service account
+of the device plugin.
+*/
+func (r *handler) CreateEvent(event *Event) error {
+	if event.Pod != "" && event.Uid == "" {
+		r.resolvePod(event)
+	}
+
+	body, err := r.eventBody(event, time.Now())
+	if err != nil {
+		return err
+	}
+
+	namespace := event.Namespace
+	if event.Pod == "" {
+		namespace = constants.Events.NodeNamespace
+	}
+
+	logging.Debugf("Creating event %s in namespace %s", event.Reason, namespace)
+	if _, err := r.request(http.MethodPost, "/api/v1/namespaces/"+url.PathEscape(namespace)+"/events", body, http.StatusCreated); err != nil {
+		return fmt.Errorf("error creating event %s: %w", event.Reason, err)
+	}
+
+	return nil
+}
+
+/*
+resolvePod sets the UID of the pod of the event, as kubectl describe only shows events of a pod
+that carry its UID. An event about a pod not found on this node is made about the node instead.
+*/
+func (r *handler) resolvePod(event *Event) {
+	pods, err := r.GetNodePods()
+	if err != nil {
+		logging.Debugf("Unable to find UID of pod %s for event %s: %v", event.Pod, event.Reason, err)
+		return
+	}
+
+	for _, pod := range pods {
+		if pod.Name == event.Pod && (event.Namespace == "" || pod.Namespace == event.Namespace) {
+			event.Namespace = pod.Namespace
+			event.Uid = pod.Uid
+			return
+		}
+	}
+
+	event.Message = "Pod " + event.Pod + ": " + event.Message
+	event.Pod = ""
+	event.Namespace = ""
+}
+
+/*
+eventBody returns the Event object to create for event.
+*/
+func (r *handler) eventBody(event *Event, now time.Time) ([]byte, error) {
+	involved := objectReference{
+		ApiVersion: "v1",
+		Kind:       "Pod",
+		Name:       event.Pod,
+		Namespace:  event.Namespace,
+		Uid:        event.Uid,
+	}
+	if event.Pod == "" {
+		involved = objectReference{
+			ApiVersion: "v1",
+			Kind:       "Node",
+			Name:       r.nodeName,
+			Uid:        r.nodeName,
+		}
+	}
+
+	obj := eventObject{
+		ApiVersion:     "v1",
+		Kind:           "Event",
+		InvolvedObject: involved,
+		Reason:         event.Reason,
+		Message:        event.Message,
+		Type:           "Warning",
+		Count:          1,
+		Source:         eventSource{Component: constants.Events.Component, Host: r.nodeName},
+		FirstTimestamp: now.UTC().Format(time.RFC3339),
+		LastTimestamp:  now.UTC().Format(time.RFC3339),
+	}
+	obj.Metadata.GenerateName = involved.Name + "."
+	obj.Metadata.Namespace = involved.Namespace
+	if event.Pod == "" {
+		obj.Metadata.Namespace = constants.Events.NodeNamespace
+	}
+
+	return json.Marshal(obj)
+}
+
+/*
+request sends a request to the API server, returning the response body if the response
+status is expStatus.
+*/
+func (r *handler) request(method string, path string, body []byte, expStatus int) ([]byte, error) {
+	host, hostExists := os.LookupEnv(constants.ApiServer.HostEnvVar)
+	port, portExists := os.LookupEnv(constants.ApiServer.PortEnvVar)
+	if !hostExists || !portExists {
+		return nil, fmt.Errorf("not running in a pod, %s and %s are not set", constants.ApiServer.HostEnvVar, constants.ApiServer.PortEnvVar)
+	}
+
+	token, err := ioutil.ReadFile(constants.ApiServer.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading service account token: %w", err)
+	}
+
+	client, err := newClient(constants.ApiServer.CaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(method, "https://"+net.JoinHostPort(host, port)+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response: %w", err)
+	}
+	if resp.StatusCode != expStatus {
+		return nil, fmt.Errorf("API server responded %s", resp.Status)
+	}
+
+	return respBody, nil
+}
+
+/*
+timeout is how long to wait for a response from the API server.
+*/
+var timeout = time.Duration(constants.ApiServer.Timeout) * time.Second
+
+/*
+SetTimeout sets how long to wait for a response from the API server.
+It must be called before any handler is created.
+*/
+func SetTimeout(t time.Duration) {
+	timeout = t
+}
+
+func newClient(caFile string) (*http.Client, error) {
+	ca, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading API server CA certificate: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12},
+		},
+	}, nil
+}
+
+/*
+eventObject holds the fields of a core v1 Event that are set when creating an Event.
+*/
+type eventObject struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Metadata   struct {
+		GenerateName string `json:"generateName"`
+		Namespace    string `json:"namespace"`
+	} `json:"metadata"`
+	InvolvedObject objectReference `json:"involvedObject"`
+	Reason         string          `json:"reason"`
+	Message        string          `json:"message"`
+	Type           string          `json:"type"`
+	Count          int             `json:"count"`
+	Source         eventSource     `json:"source"`
+	FirstTimestamp string          `json:"firstTimestamp"`
+	LastTimestamp  string          `json:"lastTimestamp"`
+}
+
+type objectReference struct {
+	ApiVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Uid        string `json:"uid,omitempty"`
+}
+
+type eventSource struct {
+	Component string `json:"component"`
+	Host      string `json:"host"`
+}
+
+/*
+nodeObject holds the fields of a Node response that templates in the config file can use.
+*/
+type nodeObject struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Labels      map[string]string `json:"labels"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+/*
+parseNode parses a Node response. Missing labels and annotations are returned as empty maps.
+*/
+func parseNode(body []byte) (*Node, error) {
+	var object nodeObject
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil, fmt.Errorf("error parsing node: %w", err)
+	}
+
+	node := &Node{
+		Name:        object.Metadata.Name,
+		Labels:      object.Metadata.Labels,
+		Annotations: object.Metadata.Annotations,
+	}
+	if node.Labels == nil {
+		node.Labels = make(map[string]string)
+	}
+	if node.Annotations == nil {
+		node.Annotations = make(map[string]string)
+	}
+
+	return node, nil
+}
+
+/*
+podList holds the fields of a PodList response that are needed to validate pods.
+*/
+type podList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+			Uid       string `json:"uid"`
+		} `json:"metadata"`
+		Spec struct {
+			Containers []struct {
+				Name      string `json:"name"`
+				Resources struct {
+					Limits map[string]string `json:"limits"`
+				} `json:"resources"`
+			} `json:"containers"`
+		} `json:"spec"`
+	} `json:"items"`
+}
+
+/*
+parsePodList parses a PodList response. Only integer resource limits are kept, as extended
+resources are always integers, while quantities such as "500m" CPU or "1Gi" memory are not
+needed to validate pods.
+*/
+func parsePodList(body []byte) ([]*Pod, error) {
+	var list podList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, fmt.Errorf("error parsing pod list: %w", err)
+	}
+
+	var pods []*Pod
+	for _, item := range list.Items {
+		pod := &Pod{
+			Name:      item.Metadata.Name,
+			Namespace: item.Metadata.Namespace,
+			Uid:       item.Metadata.Uid,
+		}
+		for _, c := range item.Spec.Containers {
+			container := &Container{Name: c.Name, Resources: make(map[string]int)}
+			for resource, quantity := range c.Resources.Limits {
+				if count, err := strconv.Atoi(quantity); err == nil {
+					container.Resources[resource] = count
+				}
+			}
+			pod.Containers = append(pod.Containers, container)
+		}
+		pods = append(pods, pod)
+	}
+
+	return pods, nil
+}
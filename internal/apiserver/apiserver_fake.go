@@ -0,0 +1,116 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apiserver
+
+import (
+	"errors"
+	"sync"
+)
+
+/*
+FakeHandler interface extends the Handler interface to provide additional testing methods.
+*/
+type FakeHandler interface {
+	Handler
+	AddFakePod(pod *Pod)
+	SetFakeNode(node *Node)
+	SetError(err error)
+	Events() []*Event
+}
+
+/*
+fakeHandler implements the FakeHandler interface.
+*/
+type fakeHandler struct {
+	pods   []*Pod
+	node   *Node
+	err    error
+	lock   sync.Mutex
+	events []*Event
+}
+
+/*
+NewFakeHandler returns an implementation of the FakeHandler interface.
+*/
+func NewFakeHandler() FakeHandler {
+	return &fakeHandler{}
+}
+
+/*
+GetNodePods lists the pods scheduled to this node.
+In this FakeHandler, it returns the pods added by AddFakePod, or the error set by SetError.
+*/
+func (f *fakeHandler) GetNodePods() ([]*Pod, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return f.pods, nil
+}
+
+/*
+AddFakePod adds a pod to those returned by GetNodePods.
+*/
+func (f *fakeHandler) AddFakePod(pod *Pod) {
+	f.pods = append(f.pods, pod)
+}
+
+/*
+GetNode gets the metadata of this node.
+In this FakeHandler, it returns the node set by SetFakeNode, or the error set by SetError.
+*/
+func (f *fakeHandler) GetNode() (*Node, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.node == nil {
+		return nil, errors.New("node not found")
+	}
+	return f.node, nil
+}
+
+/*
+SetFakeNode sets the node returned by GetNode.
+*/
+func (f *fakeHandler) SetFakeNode(node *Node) {
+	f.node = node
+}
+
+/*
+SetError sets an error to be returned by GetNodePods and GetNode.
+*/
+func (f *fakeHandler) SetError(err error) {
+	f.err = err
+}
+
+/*
+CreateEvent creates an Event.
+In this FakeHandler, the event is recorded and returned by Events.
+*/
+func (f *fakeHandler) CreateEvent(event *Event) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.events = append(f.events, event)
+	return nil
+}
+
+/*
+Events returns the events created by CreateEvent.
+*/
+func (f *fakeHandler) Events() []*Event {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.events
+}
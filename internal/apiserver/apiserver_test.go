@@ -0,0 +1,196 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package apiserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePodList(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		expPods []*Pod
+		expErr  bool
+	}{
+		{
+			name: "pod with device resources",
+			body: `{
+				"kind": "PodList",
+				"items": [
+					{
+						"metadata": {"name": "podA", "namespace": "default", "uid": "1234-abcd"},
+						"spec": {
+							"nodeName": "node1",
+							"containers": [
+								{
+									"name": "afxdp",
+									"resources": {
+										"limits": {"afxdp/myPool": "2", "cpu": "500m", "memory": "1Gi"},
+										"requests": {"afxdp/myPool": "2", "cpu": "500m", "memory": "1Gi"}
+									}
+								},
+								{
+									"name": "sidecar"
+								}
+							]
+						}
+					}
+				]
+			}`,
+			expPods: []*Pod{
+				{
+					Name:      "podA",
+					Namespace: "default",
+					Uid:       "1234-abcd",
+					Containers: []*Container{
+						{Name: "afxdp", Resources: map[string]int{"afxdp/myPool": 2}},
+						{Name: "sidecar", Resources: map[string]int{}},
+					},
+				},
+			},
+		},
+		{
+			name:    "no pods",
+			body:    `{"kind": "PodList", "items": []}`,
+			expPods: nil,
+		},
+		{
+			name:   "invalid json",
+			body:   `{"kind": "PodList", "items": [`,
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pods, err := parsePodList([]byte(tc.body))
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expPods, pods, "Unexpected pods")
+		})
+	}
+}
+
+func TestParseNode(t *testing.T) {
+	testCases := []struct {
+		name    string
+		body    string
+		expNode *Node
+		expErr  bool
+	}{
+		{
+			name: "node with labels and annotations",
+			body: `{
+				"kind": "Node",
+				"metadata": {
+					"name": "node1",
+					"labels": {"kubernetes.io/hostname": "node1", "nic": "cvl"},
+					"annotations": {"afxdp.intel.com/devices": "ens801f0"}
+				},
+				"spec": {"podCIDR": "10.244.1.0/24"}
+			}`,
+			expNode: &Node{
+				Name:        "node1",
+				Labels:      map[string]string{"kubernetes.io/hostname": "node1", "nic": "cvl"},
+				Annotations: map[string]string{"afxdp.intel.com/devices": "ens801f0"},
+			},
+		},
+		{
+			name:    "node without labels or annotations",
+			body:    `{"kind": "Node", "metadata": {"name": "node1"}}`,
+			expNode: &Node{Name: "node1", Labels: map[string]string{}, Annotations: map[string]string{}},
+		},
+		{
+			name:   "invalid json",
+			body:   `{"kind": "Node", "metadata": {`,
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			node, err := parseNode([]byte(tc.body))
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expNode, node, "Unexpected node")
+		})
+	}
+}
+
+func TestEventBody(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name    string
+		event   *Event
+		expBody string
+	}{
+		{
+			name:  "pod event",
+			event: &Event{Pod: "podA", Namespace: "test", Uid: "1234-abcd", Reason: "AfxdpHandshakeRefused", Message: "refused"},
+			expBody: `{
+				"apiVersion": "v1",
+				"kind": "Event",
+				"metadata": {"generateName": "podA.", "namespace": "test"},
+				"involvedObject": {"apiVersion": "v1", "kind": "Pod", "name": "podA", "namespace": "test", "uid": "1234-abcd"},
+				"reason": "AfxdpHandshakeRefused",
+				"message": "refused",
+				"type": "Warning",
+				"count": 1,
+				"source": {"component": "afxdp-device-plugin", "host": "node1"},
+				"firstTimestamp": "2022-06-01T12:00:00Z",
+				"lastTimestamp": "2022-06-01T12:00:00Z"
+			}`,
+		},
+		{
+			name:  "node event",
+			event: &Event{Reason: "AfxdpDeviceLinkDown", Message: "link down"},
+			expBody: `{
+				"apiVersion": "v1",
+				"kind": "Event",
+				"metadata": {"generateName": "node1.", "namespace": "default"},
+				"involvedObject": {"apiVersion": "v1", "kind": "Node", "name": "node1", "uid": "node1"},
+				"reason": "AfxdpDeviceLinkDown",
+				"message": "link down",
+				"type": "Warning",
+				"count": 1,
+				"source": {"component": "afxdp-device-plugin", "host": "node1"},
+				"firstTimestamp": "2022-06-01T12:00:00Z",
+				"lastTimestamp": "2022-06-01T12:00:00Z"
+			}`,
+		},
+	}
+
+	r := &handler{nodeName: "node1"}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body, err := r.eventBody(tc.event, now)
+			require.NoError(t, err, "Unexpected error")
+			assert.JSONEq(t, tc.expBody, string(body), "Unexpected event body")
+		})
+	}
+}
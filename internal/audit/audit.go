@@ -0,0 +1,248 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logfile"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Outcomes of a request for a file descriptor.
+*/
+const (
+	Granted = "granted" // the file descriptor was passed to the pod
+	Denied  = "denied"  // the device was not allocated to the pod, no file descriptor was passed
+	Failed  = "error"   // the file descriptor could not be passed to the pod
+)
+
+var (
+	writer io.WriteCloser
+	last   string // hash of the last record written, chained to by the next
+	lock   sync.Mutex
+)
+
+/*
+hashSuffix matches the hash ending a record line, which is the hex encoded SHA-256 of the line
+before it, closed with a brace.
+*/
+var hashSuffix = regexp.MustCompile(`,"hash":"([0-9a-f]{64})"}$`)
+
+/*
+Record is an entry of the audit file, written as a single line of JSON.
+*/
+type Record struct {
+	Time      string `json:"time"`
+	Pool      string `json:"pool"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace,omitempty"`
+	Device    string `json:"device"`
+	Peer      *Peer  `json:"peer,omitempty"`
+	Outcome   string `json:"outcome"`
+	Error     string `json:"error,omitempty"`
+	Prev      string `json:"prev,omitempty"`
+	Hash      string `json:"hash,omitempty"`
+}
+
+/*
+Peer holds the credentials of the process connected to the UDS, as recorded by the kernel.
+*/
+type Peer struct {
+	Pid int32  `json:"pid"`
+	Uid uint32 `json:"uid"`
+	Gid uint32 `json:"gid"`
+}
+
+/*
+Open starts recording to the named audit file in the log directory. The file is only ever
+appended to, and is rotated as log files are, see logfile.Open. Records are chained to the last
+record already in the file.
+*/
+func Open(name string, maxSize int, backups int, compress bool) error {
+	prev, err := lastHash(constants.Logging.Directory + name)
+	if err != nil {
+		return fmt.Errorf("error reading audit file %s: %w", name, err)
+	}
+	w, err := logfile.OpenPerm(name, os.FileMode(constants.Audit.FilePermissions), maxSize, backups, compress)
+	if err != nil {
+		return fmt.Errorf("error opening audit file %s: %w", name, err)
+	}
+	SetWriter(w)
+	if prev != "" {
+		logging.Infof("Chaining audit records to record %s", prev)
+		lock.Lock()
+		last = prev
+		lock.Unlock()
+	}
+
+	return nil
+}
+
+/*
+lastHash returns the hash of the last record of the file at path, empty if the file does not
+exist, is empty or its last record has no hash.
+*/
+func lastHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var line string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if scanner.Text() != "" {
+			line = scanner.Text()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	if match := hashSuffix.FindStringSubmatch(line); match != nil {
+		return match[1], nil
+	}
+	return "", nil
+}
+
+/*
+SetWriter sets where records are written, replacing and closing any previous writer, and starts
+a new chain of records. A nil writer stops recording.
+*/
+func SetWriter(w io.WriteCloser) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if writer != nil {
+		writer.Close()
+	}
+	writer = w
+	last = ""
+}
+
+/*
+Enabled returns true if records are being written.
+*/
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return writer != nil
+}
+
+/*
+Write writes a record to the audit file, timestamped now if it has no time. The record is
+chained to the previous record by its hash, see Verify. A record that cannot be written is
+logged as an error, as the operation audited has already happened.
+*/
+func Write(record Record) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if writer == nil {
+		return
+	}
+	if record.Time == "" {
+		record.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	record.Prev = last
+	record.Hash = ""
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		logging.Errorf("Error encoding audit record for pod %s device %s: %v", record.Pod, record.Device, err)
+		return
+	}
+	sum := sha256.Sum256(line)
+	hash := hex.EncodeToString(sum[:])
+	line = append(line[:len(line)-1], `,"hash":"`+hash+`"}`...)
+
+	if _, err := writer.Write(append(line, '\n')); err != nil {
+		logging.Errorf("Error writing audit record for pod %s device %s: %v", record.Pod, record.Device, err)
+		return
+	}
+	last = hash
+}
+
+/*
+Verify reads audit records from r, oldest first, and checks each is unchanged and chained to the
+record before it, returning the number of records verified and the hash of the last. The first
+record may chain to a record not read, e.g. one in a rotated file since removed. An edited,
+inserted or removed record is reported by its line number. Records written before records were
+hashed are skipped, but none may follow the first hashed record.
+*/
+func Verify(r io.Reader) (int, string, error) {
+	var prev string
+	verified := 0
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		match := hashSuffix.FindStringSubmatchIndex(line)
+		if match == nil {
+			if verified == 0 {
+				continue
+			}
+			return verified, prev, fmt.Errorf("line %d: record has no hash", lineNum)
+		}
+		hash := line[match[2]:match[3]]
+		hashed := line[:match[0]] + "}"
+
+		sum := sha256.Sum256([]byte(hashed))
+		if hex.EncodeToString(sum[:]) != hash {
+			return verified, prev, fmt.Errorf("line %d: record does not match its hash", lineNum)
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(hashed), &record); err != nil {
+			return verified, prev, fmt.Errorf("line %d: error decoding record: %v", lineNum, err)
+		}
+		if verified > 0 && record.Prev != prev {
+			return verified, prev, fmt.Errorf("line %d: record is not chained to the record before it", lineNum)
+		}
+
+		prev = hash
+		verified++
+	}
+	if err := scanner.Err(); err != nil {
+		return verified, prev, err
+	}
+	if verified == 0 {
+		return 0, "", errors.New("no hashed records")
+	}
+
+	return verified, prev, nil
+}
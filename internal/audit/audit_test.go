@@ -0,0 +1,229 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type bufferCloser struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *bufferCloser) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	testCases := []struct {
+		name    string
+		record  Record
+		expLine string
+	}{
+		{
+			name: "granted",
+			record: Record{
+				Time:      "2022-06-01T12:00:00Z",
+				Pool:      "afxdp/myPool",
+				Pod:       "podA",
+				Namespace: "default",
+				Device:    "ens785f0",
+				Peer:      &Peer{Pid: 4321, Uid: 1500, Gid: 1500},
+				Outcome:   Granted,
+			},
+			expLine: `{"time":"2022-06-01T12:00:00Z","pool":"afxdp/myPool","pod":"podA","namespace":"default","device":"ens785f0",` +
+				`"peer":{"pid":4321,"uid":1500,"gid":1500},"outcome":"granted",` +
+				`"hash":"afe3dfdb5ce9548cf2cd4d92504af4f1e2d243bf6521f9e2794145de3c2a2803"}` + "\n",
+		},
+		{
+			name: "failed without peer",
+			record: Record{
+				Time:    "2022-06-01T12:00:00Z",
+				Pool:    "afxdp/myPool",
+				Pod:     "podA",
+				Device:  "ens785f0",
+				Outcome: Failed,
+				Error:   "broken pipe",
+			},
+			expLine: `{"time":"2022-06-01T12:00:00Z","pool":"afxdp/myPool","pod":"podA","device":"ens785f0",` +
+				`"outcome":"error","error":"broken pipe",` +
+				`"hash":"4e369892769c8e7bb590b20693b2b53eabfad8fa28a22778baa0471eceb09f3c"}` + "\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bufferCloser{}
+			SetWriter(buf)
+			defer SetWriter(nil)
+
+			Write(tc.record)
+			assert.Equal(t, tc.expLine, buf.String(), "Unexpected audit record")
+		})
+	}
+}
+
+func TestWriteDisabled(t *testing.T) {
+	buf := &bufferCloser{}
+	SetWriter(buf)
+	SetWriter(nil)
+
+	assert.True(t, buf.closed, "Previous writer should be closed")
+	assert.False(t, Enabled(), "Audit should be disabled")
+	Write(Record{Pod: "podA", Device: "ens785f0", Outcome: Granted})
+	assert.Equal(t, "", buf.String(), "Nothing should be written when disabled")
+}
+
+func TestWriteTimestamp(t *testing.T) {
+	buf := &bufferCloser{}
+	SetWriter(buf)
+	defer SetWriter(nil)
+
+	Write(Record{Pod: "podA", Device: "ens785f0", Outcome: Granted})
+	assert.Regexp(t, `^\{"time":"\d{4}-\d{2}-\d{2}T[0-9:.]+Z",`, buf.String(), "Record should be timestamped")
+}
+
+func TestChain(t *testing.T) {
+	buf := &bufferCloser{}
+	SetWriter(buf)
+	defer SetWriter(nil)
+
+	Write(Record{Pod: "podA", Device: "ens785f0", Outcome: Granted})
+	Write(Record{Pod: "podB", Device: "ens785f1", Outcome: Denied})
+	Write(Record{Pod: "podC", Device: "ens785f2", Outcome: Granted})
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 3, "Unexpected number of records")
+	assert.NotContains(t, lines[0], `"prev"`, "First record should not be chained")
+	for i := 1; i < len(lines); i++ {
+		prev := hashSuffix.FindStringSubmatch(lines[i-1])[1]
+		assert.Contains(t, lines[i], `"prev":"`+prev+`"`, "Record should be chained to the record before it")
+	}
+
+	verified, last, err := Verify(strings.NewReader(buf.String()))
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3, verified, "Unexpected number of records verified")
+	assert.Equal(t, hashSuffix.FindStringSubmatch(lines[2])[1], last, "Unexpected last hash")
+}
+
+func TestVerify(t *testing.T) {
+	buf := &bufferCloser{}
+	SetWriter(buf)
+	Write(Record{Pod: "podA", Device: "ens785f0", Outcome: Granted})
+	Write(Record{Pod: "podB", Device: "ens785f1", Outcome: Denied})
+	Write(Record{Pod: "podC", Device: "ens785f2", Outcome: Granted})
+	SetWriter(nil)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	unhashed := `{"time":"2022-06-01T12:00:00Z","pool":"afxdp/myPool","pod":"podZ","device":"ens785f0","outcome":"granted"}`
+
+	testCases := []struct {
+		name        string
+		lines       []string
+		expVerified int
+		expErr      string
+	}{
+		{
+			name:        "intact",
+			lines:       lines,
+			expVerified: 3,
+		},
+		{
+			name:        "oldest removed",
+			lines:       lines[1:],
+			expVerified: 2,
+		},
+		{
+			name:        "records before hashing",
+			lines:       append([]string{unhashed}, lines...),
+			expVerified: 3,
+		},
+		{
+			name:        "edited",
+			lines:       []string{lines[0], strings.Replace(lines[1], "denied", "granted", 1), lines[2]},
+			expVerified: 1,
+			expErr:      "line 2: record does not match its hash",
+		},
+		{
+			name:        "removed",
+			lines:       []string{lines[0], lines[2]},
+			expVerified: 1,
+			expErr:      "line 2: record is not chained to the record before it",
+		},
+		{
+			name:        "reordered",
+			lines:       []string{lines[1], lines[0], lines[2]},
+			expVerified: 1,
+			expErr:      "line 2: record is not chained to the record before it",
+		},
+		{
+			name:        "unhashed record inserted",
+			lines:       []string{lines[0], unhashed, lines[1], lines[2]},
+			expVerified: 1,
+			expErr:      "line 2: record has no hash",
+		},
+		{
+			name:   "no hashed records",
+			lines:  []string{unhashed},
+			expErr: "no hashed records",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			verified, _, err := Verify(strings.NewReader(strings.Join(tc.lines, "\n") + "\n"))
+			if tc.expErr != "" {
+				if assert.Error(t, err, "Error was expected") {
+					assert.Equal(t, tc.expErr, err.Error(), "Unexpected error")
+				}
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+			assert.Equal(t, tc.expVerified, verified, "Unexpected number of records verified")
+		})
+	}
+}
+
+func TestLastHash(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	assert.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+
+	hash, err := lastHash(path)
+	assert.NoError(t, err, "Missing file should not be an error")
+	assert.Equal(t, "", hash, "Missing file should have no last hash")
+
+	buf := &bufferCloser{}
+	SetWriter(buf)
+	Write(Record{Pod: "podA", Device: "ens785f0", Outcome: Granted})
+	Write(Record{Pod: "podB", Device: "ens785f1", Outcome: Granted})
+	SetWriter(nil)
+	assert.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0600), "Unexpected error writing audit file")
+
+	_, expHash, _ := Verify(bytes.NewReader(buf.Bytes()))
+	hash, err = lastHash(path)
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, expHash, hash, "Unexpected last hash")
+}
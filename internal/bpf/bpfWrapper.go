@@ -27,6 +27,10 @@ import "C"
 import (
 	"errors"
 
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpfhelper"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpfsig"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -48,9 +52,28 @@ handler implements the Handler interface.
 type handler struct{}
 
 /*
-NewHandler returns an implementation of the Handler interface.
+helperSocket is the socket of the privileged helper BPF programs are loaded through, empty if
+they are loaded by this process.
+*/
+var helperSocket string
+
+/*
+UseHelper sets Handlers returned by NewHandler to load BPF programs through the privileged helper
+listening on the socket, so this process needs no BPF capabilities. It must be called before any
+Handler is created.
+*/
+func UseHelper(socket string) {
+	helperSocket = socket
+}
+
+/*
+NewHandler returns an implementation of the Handler interface, loading BPF programs through the
+privileged helper if one is set by UseHelper.
 */
 func NewHandler() Handler {
+	if helperSocket != "" {
+		return bpfhelper.NewClient(helperSocket)
+	}
 	return &handler{}
 }
 
@@ -61,7 +84,7 @@ func (r *handler) LoadBpfSendXskMap(ifname string) (int, error) {
 	fd := int(C.Load_bpf_send_xsk_map(C.CString(ifname)))
 
 	if fd <= 0 {
-		return fd, errors.New("error loading BPF program onto interface")
+		return fd, errdefs.Wrap(errdefs.ErrXDPAttach, errors.New("error loading BPF program onto interface"))
 	}
 
 	return fd, nil
@@ -69,12 +92,19 @@ func (r *handler) LoadBpfSendXskMap(ifname string) (int, error) {
 
 /*
 LoadBpfXdpPass is the GoLang wrapper for the C function Load_bpf_send_xsk_map
+The object file is verified against its signature first, if bpfsig has a public key set.
 */
 func (r *handler) LoadAttachBpfXdpPass(ifname string) error {
-	err := int(C.Load_attach_bpf_xdp_pass(C.CString(ifname)))
+	object, remove, verifyErr := bpfsig.Open(constants.Afxdp.XdpPassObject)
+	if verifyErr != nil {
+		return errdefs.Wrap(errdefs.ErrXDPAttach, verifyErr)
+	}
+	defer remove()
+
+	err := int(C.Load_attach_bpf_xdp_pass(C.CString(ifname), C.CString(object)))
 
 	if err < 0 {
-		return errors.New("error loading BPF program onto interface")
+		return errdefs.Wrap(errdefs.ErrXDPAttach, errors.New("error loading BPF program onto interface"))
 	}
 
 	return nil
@@ -100,7 +130,7 @@ func (r *handler) Cleanbpf(ifname string) error {
 	ret := C.Clean_bpf(C.CString(ifname))
 
 	if ret != 0 {
-		return errors.New("error removing BPF program from interface")
+		return errdefs.Wrap(errdefs.ErrXDPAttach, errors.New("error removing BPF program from interface"))
 	}
 
 	return nil
@@ -0,0 +1,372 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package bpfhelper splits loading BPF programs out of the device plugin into a small privileged
+helper process, so the long running device plugin needs neither CAP_BPF nor CAP_SYS_ADMIN. The
+helper serves a narrow API on a Unix domain socket: loading the XSK map program onto a device,
+loading the XDP pass program, configuring busy poll on an XSK and removing the programs of a
+device. Nothing else can be asked of it. File descriptors are passed over the socket.
+*/
+package bpfhelper
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Requests served by the helper. A request is its name followed by its arguments, separated by
+commas. The helper answers with responseOk, with a file descriptor attached if the request
+returns one, or responseError followed by the error.
+*/
+const (
+	requestLoadXskMap  = "load_xsk_map"  // ifname, answered with the XSK map file descriptor
+	requestLoadXdpPass = "load_xdp_pass" // ifname
+	requestBusyPoll    = "busy_poll"     // timeout,budget, sent with the XSK file descriptor attached
+	requestClean       = "clean"         // ifname
+	responseOk         = "ok"
+	responseError      = "error,"
+)
+
+/*
+ifnameMax is the longest name of a network device, IFNAMSIZ less the terminating null.
+*/
+const ifnameMax = 15
+
+var validIfname = regexp.MustCompile(constants.Devices.ValidNameRegex)
+
+/*
+Loader is the set of BPF operations the helper performs, matching the bpf package Handler. It is
+declared here so the helper can be tested without the BPF libraries.
+*/
+type Loader interface {
+	LoadBpfSendXskMap(ifname string) (int, error)
+	LoadAttachBpfXdpPass(ifname string) error
+	ConfigureBusyPoll(fd int, busyTimeout int, busyBudget int) error
+	Cleanbpf(ifname string) error
+}
+
+/*
+Listen creates the socket of the helper at path, replacing any socket left by a previous helper.
+Only the owner of the socket file can connect.
+*/
+func Listen(path string) (*net.UnixListener, error) {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error removing stale socket %s: %v", path, err)
+	}
+
+	listener, err := net.ListenUnix("unixpacket", &net.UnixAddr{Name: path, Net: "unixpacket"})
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %v", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("error setting permissions of %s: %v", path, err)
+	}
+
+	return listener, nil
+}
+
+/*
+Serve accepts connections on the listener and serves their requests with the loader, until the
+listener is closed. Connections from processes running as another user than the helper are
+refused, on top of the permissions of the socket file.
+*/
+func Serve(listener *net.UnixListener, loader Loader) error {
+	for {
+		conn, err := listener.AcceptUnix()
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, loader)
+	}
+}
+
+/*
+serveConn serves the requests of a connection until the client closes it.
+*/
+func serveConn(conn *net.UnixConn, loader Loader) {
+	defer conn.Close()
+
+	if uid, err := peerUid(conn); err != nil {
+		logging.Errorf("Unable to get credentials of the BPF helper client: %v", err)
+		return
+	} else if uid != uint32(os.Getuid()) {
+		logging.Warningf("BPF helper connection refused from UID %d", uid)
+		return
+	}
+
+	for {
+		request, fd, err := readMsg(conn, constants.BpfHelper.MsgBufSize)
+		if err != nil {
+			return
+		}
+
+		response, responseFd := handle(loader, request, fd)
+		if fd > 0 {
+			syscall.Close(fd)
+		}
+		err = writeMsg(conn, response, responseFd)
+		if responseFd > 0 {
+			syscall.Close(responseFd)
+		}
+		if err != nil {
+			logging.Errorf("BPF helper write error: %v", err)
+			return
+		}
+	}
+}
+
+/*
+handle performs a request, returning the response and the file descriptor to attach to it, or 0.
+*/
+func handle(loader Loader, request string, fd int) (string, int) {
+	words := strings.Split(request, ",")
+	logging.Debugf("BPF helper request: %s", request)
+
+	fail := func(err error) (string, int) {
+		logging.Errorf("BPF helper request %s failed: %v", request, err)
+		return responseError + err.Error(), 0
+	}
+
+	switch {
+	case words[0] == requestBusyPoll && len(words) == 3:
+		timeout, err := strconv.Atoi(words[1])
+		if err != nil {
+			return fail(fmt.Errorf("invalid busy poll timeout %q", words[1]))
+		}
+		budget, err := strconv.Atoi(words[2])
+		if err != nil {
+			return fail(fmt.Errorf("invalid busy poll budget %q", words[2]))
+		}
+		if fd <= 0 {
+			return fail(errors.New("no XSK file descriptor attached"))
+		}
+		if err := loader.ConfigureBusyPoll(fd, timeout, budget); err != nil {
+			return fail(err)
+		}
+		return responseOk, 0
+	case len(words) != 2:
+		return fail(fmt.Errorf("unknown request %q", request))
+	case len(words[1]) > ifnameMax || !validIfname.MatchString(words[1]):
+		return fail(fmt.Errorf("invalid interface name %q", words[1]))
+	}
+
+	ifname := words[1]
+	switch words[0] {
+	case requestLoadXskMap:
+		mapFd, err := loader.LoadBpfSendXskMap(ifname)
+		if err != nil {
+			return fail(err)
+		}
+		return responseOk, mapFd
+	case requestLoadXdpPass:
+		if err := loader.LoadAttachBpfXdpPass(ifname); err != nil {
+			return fail(err)
+		}
+		return responseOk, 0
+	case requestClean:
+		if err := loader.Cleanbpf(ifname); err != nil {
+			return fail(err)
+		}
+		return responseOk, 0
+	}
+
+	return fail(fmt.Errorf("unknown request %q", request))
+}
+
+/*
+Client implements the bpf package Handler by asking the helper to perform each operation. Each
+operation is a connection of its own, so a Client can be shared by goroutines.
+*/
+type Client struct {
+	path    string
+	timeout time.Duration
+}
+
+/*
+NewClient returns a Client of the helper listening on the socket at path.
+*/
+func NewClient(path string) *Client {
+	return &Client{
+		path:    path,
+		timeout: time.Duration(constants.BpfHelper.Timeout) * time.Second,
+	}
+}
+
+/*
+LoadBpfSendXskMap asks the helper to load the XSK map program onto the device, returning the file
+descriptor of the XSK map.
+*/
+func (c *Client) LoadBpfSendXskMap(ifname string) (int, error) {
+	fd, err := c.call(requestLoadXskMap+","+ifname, 0)
+	if err != nil {
+		return 0, errdefs.Wrap(errdefs.ErrXDPAttach, err)
+	}
+	if fd <= 0 {
+		return 0, errdefs.Wrap(errdefs.ErrXDPAttach, errors.New("BPF helper returned no XSK map file descriptor"))
+	}
+	return fd, nil
+}
+
+/*
+LoadAttachBpfXdpPass asks the helper to load the XDP pass program onto the device.
+*/
+func (c *Client) LoadAttachBpfXdpPass(ifname string) error {
+	if _, err := c.call(requestLoadXdpPass+","+ifname, 0); err != nil {
+		return errdefs.Wrap(errdefs.ErrXDPAttach, err)
+	}
+	return nil
+}
+
+/*
+ConfigureBusyPoll asks the helper to configure busy poll on the XSK, passing it the file
+descriptor of the XSK.
+*/
+func (c *Client) ConfigureBusyPoll(fd int, busyTimeout int, busyBudget int) error {
+	_, err := c.call(requestBusyPoll+","+strconv.Itoa(busyTimeout)+","+strconv.Itoa(busyBudget), fd)
+	return err
+}
+
+/*
+Cleanbpf asks the helper to remove the BPF programs of the device.
+*/
+func (c *Client) Cleanbpf(ifname string) error {
+	if _, err := c.call(requestClean+","+ifname, 0); err != nil {
+		return errdefs.Wrap(errdefs.ErrXDPAttach, err)
+	}
+	return nil
+}
+
+/*
+call sends a request to the helper, with a file descriptor attached if fd is above 0, and returns
+the file descriptor attached to the response, or 0.
+*/
+func (c *Client) call(request string, fd int) (int, error) {
+	conn, err := net.DialUnix("unixpacket", nil, &net.UnixAddr{Name: c.path, Net: "unixpacket"})
+	if err != nil {
+		return 0, fmt.Errorf("error connecting to the BPF helper: %v", err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, err
+	}
+	if err := writeMsg(conn, request, fd); err != nil {
+		return 0, fmt.Errorf("error sending request to the BPF helper: %v", err)
+	}
+	response, responseFd, err := readMsg(conn, constants.BpfHelper.MsgBufSize)
+	if err != nil {
+		return 0, fmt.Errorf("error reading response of the BPF helper: %v", err)
+	}
+
+	switch {
+	case response == responseOk:
+		return responseFd, nil
+	case strings.HasPrefix(response, responseError):
+		return 0, errors.New(strings.TrimPrefix(response, responseError))
+	}
+	if responseFd > 0 {
+		syscall.Close(responseFd)
+	}
+	return 0, fmt.Errorf("unexpected response from the BPF helper: %q", response)
+}
+
+/*
+peerUid returns the UID of the process at the other end of the connection.
+*/
+func peerUid(conn *net.UnixConn) (uint32, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return 0, err
+	}
+	if credErr != nil {
+		return 0, credErr
+	}
+
+	return cred.Uid, nil
+}
+
+/*
+readMsg reads a message and the file descriptor attached to it, or 0 if none is.
+*/
+func readMsg(conn *net.UnixConn, bufSize int) (string, int, error) {
+	msgBuf := make([]byte, bufSize)
+	oobBuf := make([]byte, syscall.CmsgSpace(4))
+
+	n, oobn, _, _, err := conn.ReadMsgUnix(msgBuf, oobBuf)
+	if err != nil {
+		return "", 0, err
+	}
+	if n == 0 {
+		return "", 0, errors.New("connection closed")
+	}
+
+	fd := 0
+	if oobn > 0 {
+		msgs, err := syscall.ParseSocketControlMessage(oobBuf[:oobn])
+		if err != nil {
+			return "", 0, err
+		}
+		for _, msg := range msgs {
+			fds, err := syscall.ParseUnixRights(&msg)
+			if err != nil {
+				continue
+			}
+			for _, f := range fds {
+				if fd == 0 {
+					fd = f
+				} else {
+					syscall.Close(f)
+				}
+			}
+		}
+	}
+
+	return string(msgBuf[:n]), fd, nil
+}
+
+/*
+writeMsg writes a message, with the file descriptor attached if fd is above 0.
+*/
+func writeMsg(conn *net.UnixConn, msg string, fd int) error {
+	var oob []byte
+	if fd > 0 {
+		oob = syscall.UnixRights(fd)
+	}
+
+	_, _, err := conn.WriteMsgUnix([]byte(msg), oob, nil)
+	return err
+}
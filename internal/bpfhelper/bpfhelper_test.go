@@ -0,0 +1,179 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bpfhelper
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLoader struct {
+	lock   sync.Mutex
+	calls  []string
+	err    error
+	mapFd  int
+	pollFd bool // set if ConfigureBusyPoll was given an open file descriptor
+}
+
+func (f *fakeLoader) record(call string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls = append(f.calls, call)
+	return f.err
+}
+
+func (f *fakeLoader) LoadBpfSendXskMap(ifname string) (int, error) {
+	if err := f.record("LoadBpfSendXskMap " + ifname); err != nil {
+		return 0, err
+	}
+	fd, err := syscall.Dup(f.mapFd)
+	return fd, err
+}
+
+func (f *fakeLoader) LoadAttachBpfXdpPass(ifname string) error {
+	return f.record("LoadAttachBpfXdpPass " + ifname)
+}
+
+func (f *fakeLoader) ConfigureBusyPoll(fd int, busyTimeout int, busyBudget int) error {
+	var stat syscall.Stat_t
+	f.pollFd = syscall.Fstat(fd, &stat) == nil
+	return f.record("ConfigureBusyPoll")
+}
+
+func (f *fakeLoader) Cleanbpf(ifname string) error {
+	return f.record("Cleanbpf " + ifname)
+}
+
+func startHelper(t *testing.T, loader Loader) *Client {
+	dir, err := ioutil.TempDir("", "bpfhelper")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "helper.sock")
+	listener, err := Listen(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go Serve(listener, loader)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	return NewClient(path)
+}
+
+func TestClient(t *testing.T) {
+	file, err := ioutil.TempFile("", "xskmap")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	loader := &fakeLoader{mapFd: int(file.Fd())}
+	client := startHelper(t, loader)
+
+	fd, err := client.LoadBpfSendXskMap("ens801f0")
+	require.NoError(t, err)
+	assert.Greater(t, fd, 0)
+	var mapStat, fileStat syscall.Stat_t
+	require.NoError(t, syscall.Fstat(fd, &mapStat))
+	require.NoError(t, syscall.Fstat(int(file.Fd()), &fileStat))
+	assert.Equal(t, fileStat.Ino, mapStat.Ino, "file descriptor passed is not the XSK map")
+
+	assert.NoError(t, client.LoadAttachBpfXdpPass("ens801f1"))
+	assert.NoError(t, client.ConfigureBusyPoll(fd, 20, 64))
+	assert.True(t, loader.pollFd)
+	assert.NoError(t, client.Cleanbpf("ens801f0"))
+	syscall.Close(fd)
+
+	assert.Equal(t, []string{
+		"LoadBpfSendXskMap ens801f0",
+		"LoadAttachBpfXdpPass ens801f1",
+		"ConfigureBusyPoll",
+		"Cleanbpf ens801f0",
+	}, loader.calls)
+}
+
+func TestClientErrors(t *testing.T) {
+	loader := &fakeLoader{}
+	client := startHelper(t, loader)
+
+	testCases := []struct {
+		name   string
+		call   func() error
+		expErr string
+	}{
+		{
+			name:   "interface name with a comma",
+			call:   func() error { return client.LoadAttachBpfXdpPass("ens801f0,ens801f1") },
+			expErr: "unknown request",
+		},
+		{
+			name:   "interface name with a path",
+			call:   func() error { return client.Cleanbpf("../ens801f0") },
+			expErr: "invalid interface name",
+		},
+		{
+			name:   "interface name too long",
+			call:   func() error { return client.Cleanbpf("ens801f0ens801f0") },
+			expErr: "invalid interface name",
+		},
+		{
+			name: "busy poll without a file descriptor",
+			call: func() error {
+				_, err := client.call(requestBusyPoll+",20,64", 0)
+				return err
+			},
+			expErr: "no XSK file descriptor attached",
+		},
+		{
+			name: "unknown request",
+			call: func() error {
+				_, err := client.call("load_program,/tmp/prog.o", 0)
+				return err
+			},
+			expErr: "invalid interface name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.call()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+		})
+	}
+	assert.Empty(t, loader.calls)
+
+	loader.err = errors.New("error loading BPF program onto interface")
+	_, err := client.LoadBpfSendXskMap("ens801f0")
+	if assert.Error(t, err) {
+		assert.True(t, errors.Is(err, errdefs.ErrXDPAttach))
+		assert.Contains(t, err.Error(), "error loading BPF program onto interface")
+	}
+
+	_, err = NewClient("/tmp/afxdp-no-such-helper.sock").call(requestClean+",ens801f0", 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package bpfsig verifies BPF object files against a detached Ed25519 signature before they are
+loaded, so only vetted programs are attached to devices. The signature of an object file is kept
+next to it, named after it with the signature suffix, e.g. xdp_pass.o.sig, and holds the raw
+64 byte signature of the whole file.
+*/
+package bpfsig
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+var (
+	lock      sync.Mutex
+	publicKey ed25519.PublicKey // key object files are verified with, nil if they are not verified
+)
+
+/*
+SetPublicKey reads the Ed25519 public key, PEM encoded, that object files are verified with from
+then on. An empty path stops object files being verified.
+*/
+func SetPublicKey(path string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if path == "" {
+		publicKey = nil
+		return nil
+	}
+
+	key, err := readPublicKey(path)
+	if err != nil {
+		return err
+	}
+	publicKey = key
+
+	return nil
+}
+
+/*
+Enabled returns true if object files are verified before they are loaded.
+*/
+func Enabled() bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	return publicKey != nil
+}
+
+/*
+Open returns the path of the object file to load and a function removing it once loaded. If
+object files are verified, the file is read and verified against its signature, and the path
+returned is of a private copy of the verified content, so the file cannot be swapped between
+being verified and being loaded. Otherwise path is returned as it is.
+*/
+func Open(path string) (string, func(), error) {
+	lock.Lock()
+	key := publicKey
+	lock.Unlock()
+
+	if key == nil {
+		return path, func() {}, nil
+	}
+
+	object, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading BPF object file: %v", err)
+	}
+	signature, err := ioutil.ReadFile(path + constants.BpfSignature.Suffix)
+	if err != nil {
+		return "", nil, fmt.Errorf("error reading signature of BPF object file %s: %v", path, err)
+	}
+	if err := verify(key, object, signature); err != nil {
+		return "", nil, fmt.Errorf("BPF object file %s is not trusted: %v", path, err)
+	}
+	logging.Debugf("BPF object file %s matches its signature", path)
+
+	copied, err := ioutil.TempFile("", "afxdp-bpf-*.o")
+	if err != nil {
+		return "", nil, fmt.Errorf("error copying verified BPF object file: %v", err)
+	}
+	remove := func() { os.Remove(copied.Name()) }
+	if _, err := copied.Write(object); err != nil {
+		copied.Close()
+		remove()
+		return "", nil, fmt.Errorf("error copying verified BPF object file: %v", err)
+	}
+	if err := copied.Close(); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("error copying verified BPF object file: %v", err)
+	}
+
+	return copied.Name(), remove, nil
+}
+
+/*
+verify checks the signature is of the object, by the holder of the private key of key.
+*/
+func verify(key ed25519.PublicKey, object []byte, signature []byte) error {
+	if len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("signature is %d bytes, an Ed25519 signature is %d", len(signature), ed25519.SignatureSize)
+	}
+	if !ed25519.Verify(key, object, signature) {
+		return errors.New("signature does not match the object file")
+	}
+
+	return nil
+}
+
+/*
+readPublicKey reads an Ed25519 public key in a PEM encoded PKIX block, as written by
+openssl pkey -pubout.
+*/
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading BPF public key: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("BPF public key %s is not a PEM encoded public key", path)
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing BPF public key %s: %v", path, err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("BPF public key %s is not an Ed25519 key", path)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,177 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package bpfsig
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeKey(t *testing.T, dir string, key interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "bpf.pem")
+	require.NoError(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+	return path
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpfsig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	keyPath := writeKey(t, dir, public)
+
+	object := []byte("\x7fELF xdp program")
+	tampered := []byte("\x7fELF xdp program, changed")
+
+	testCases := []struct {
+		name      string
+		object    []byte
+		signature []byte // not written if nil
+		expErr    string
+	}{
+		{
+			name:      "signed object",
+			object:    object,
+			signature: ed25519.Sign(private, object),
+		},
+		{
+			name:      "tampered object",
+			object:    tampered,
+			signature: ed25519.Sign(private, object),
+			expErr:    "signature does not match the object file",
+		},
+		{
+			name:      "signed by another key",
+			object:    object,
+			signature: ed25519.Sign(otherPrivate, object),
+			expErr:    "signature does not match the object file",
+		},
+		{
+			name:   "no signature",
+			object: object,
+			expErr: "error reading signature of BPF object file",
+		},
+		{
+			name:      "truncated signature",
+			object:    object,
+			signature: ed25519.Sign(private, object)[:32],
+			expErr:    "signature is 32 bytes",
+		},
+	}
+
+	require.NoError(t, SetPublicKey(keyPath))
+	defer SetPublicKey("")
+	assert.True(t, Enabled())
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			objectPath := filepath.Join(dir, "xdp_pass.o")
+			require.NoError(t, ioutil.WriteFile(objectPath, tc.object, 0644))
+			os.Remove(objectPath + ".sig")
+			if tc.signature != nil {
+				require.NoError(t, ioutil.WriteFile(objectPath+".sig", tc.signature, 0644))
+			}
+
+			path, remove, err := Open(objectPath)
+			if tc.expErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEqual(t, objectPath, path, "verified object was not copied")
+			loaded, err := ioutil.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.object, loaded)
+
+			remove()
+			_, err = os.Stat(path)
+			assert.True(t, os.IsNotExist(err), "copy of the verified object was not removed")
+		})
+	}
+}
+
+func TestOpenUnverified(t *testing.T) {
+	require.NoError(t, SetPublicKey(""))
+	assert.False(t, Enabled())
+
+	path, remove, err := Open("/afxdp/xdp_pass.o")
+	assert.NoError(t, err)
+	assert.Equal(t, "/afxdp/xdp_pass.o", path)
+	remove()
+}
+
+func TestSetPublicKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bpfsig")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer SetPublicKey("")
+
+	notPem := filepath.Join(dir, "key.txt")
+	require.NoError(t, ioutil.WriteFile(notPem, []byte("not a key"), 0600))
+
+	privateBlock := filepath.Join(dir, "private.pem")
+	require.NoError(t, ioutil.WriteFile(privateBlock, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{1, 2, 3}}), 0600))
+
+	testCases := []struct {
+		name   string
+		path   string
+		expErr string
+	}{
+		{
+			name:   "missing key",
+			path:   filepath.Join(dir, "missing.pem"),
+			expErr: "error reading BPF public key",
+		},
+		{
+			name:   "not pem",
+			path:   notPem,
+			expErr: "is not a PEM encoded public key",
+		},
+		{
+			name:   "private key block",
+			path:   privateBlock,
+			expErr: "is not a PEM encoded public key",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := SetPublicKey(tc.path)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.expErr)
+			}
+			assert.False(t, Enabled())
+		})
+	}
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cgroups
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/*
+podUidRegex matches the pod UID in the cgroup path of a container process. The kubelet names
+pod cgroups pod<uid> with the cgroupfs driver, e.g. /kubepods/burstable/pod<uid>/<container>,
+and kubepods-<qos>-pod<uid>.slice, with the dashes of the UID replaced by underscores, with the
+systemd driver.
+*/
+var podUidRegex = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+
+/*
+PodUid returns the UID of the pod the process with the given PID runs in, read from its cgroups
+under procRoot, normally /proc. An empty UID is returned for a process that is not in a pod.
+The PID must be as seen from the PID namespace of procRoot.
+*/
+func PodUid(procRoot string, pid int32) (string, error) {
+	if pid <= 0 {
+		return "", fmt.Errorf("process %d is not visible from this PID namespace", pid)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(int(pid)), "cgroup"))
+	if err != nil {
+		return "", fmt.Errorf("error reading cgroups of process %d: %w", pid, err)
+	}
+
+	return parsePodUid(string(raw)), nil
+}
+
+/*
+parsePodUid returns the pod UID in the cgroup paths of a /proc/<pid>/cgroup file, one
+hierarchy-ID:controllers:path entry per line, or an empty string if there is none.
+*/
+func parsePodUid(cgroups string) string {
+	for _, line := range strings.Split(cgroups, "\n") {
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if match := podUidRegex.FindStringSubmatch(fields[2]); match != nil {
+			return strings.ReplaceAll(match[1], "_", "-")
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePodUid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cgroups string
+		expUid  string
+	}{
+		{
+			name:    "cgroup v1, cgroupfs driver",
+			cgroups: "12:memory:/kubepods/burstable/pod8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21/3f2c1d\n11:cpu,cpuacct:/kubepods/burstable/pod8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21/3f2c1d\n",
+			expUid:  "8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21",
+		},
+		{
+			name:    "cgroup v2, systemd driver",
+			cgroups: "0::/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod8f4a2a3e_64c9_4d1c_9b1a_0c8b0a5e4f21.slice/cri-containerd-3f2c1d.scope\n",
+			expUid:  "8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21",
+		},
+		{
+			name:    "guaranteed pod",
+			cgroups: "0::/kubepods/pod8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21/3f2c1d\n",
+			expUid:  "8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21",
+		},
+		{
+			name:    "host process",
+			cgroups: "0::/system.slice/sshd.service\n",
+			expUid:  "",
+		},
+		{
+			name:    "empty",
+			cgroups: "",
+			expUid:  "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expUid, parsePodUid(tc.cgroups), "Unexpected pod UID")
+		})
+	}
+}
+
+func TestPodUid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroups")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "42"), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "42", "cgroup"),
+		[]byte("0::/kubepods/pod8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21/3f2c1d\n"), 0600))
+
+	uid, err := PodUid(dir, 42)
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "8f4a2a3e-64c9-4d1c-9b1a-0c8b0a5e4f21", uid, "Unexpected pod UID")
+
+	_, err = PodUid(dir, 43)
+	assert.Error(t, err, "Missing process should be an error")
+
+	_, err = PodUid(dir, 0)
+	assert.Error(t, err, "Process outside the PID namespace should be an error")
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cleanup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Paths are the host locations of the files the plugins create. StateDir holds the UDS sockets,
+control socket and state files, BpfPinDir the pinned BPF objects, and KubeletSockets is a glob
+of the device plugin sockets registered with the kubelet. Keep are files left in place, such as
+the lock file of a device plugin that is still running.
+*/
+type Paths struct {
+	StateDir       string
+	BpfPinDir      string
+	KubeletSockets string
+	Keep           []string
+}
+
+/*
+Run removes everything the plugins have created on the node. Devices attached to pods are moved
+back to the host network namespace, their flow rules, ethtool filters, promiscuous mode and
+XDP programs are removed, and their RSS hash keys and channel counts restored. Tap devices are deleted, and then the files in paths. Every step is
+attempted, errors are collected and returned together.
+*/
+func Run(net networking.Handler, bpf bpf.Handler, paths Paths) error {
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		err := fmt.Sprintf(format, args...)
+		logging.Errorf("Cleanup error: %s", err)
+		errs = append(errs, err)
+	}
+
+	// devices attached to pods
+	allocations, err := net.GetAllocations()
+	if err != nil {
+		fail("error reading device allocations: %v", err)
+	}
+	devices := make([]string, 0, len(allocations))
+	for device := range allocations {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+	for _, device := range devices {
+		allocation := allocations[device]
+		logging.Infof("Releasing device %s from pod %s/%s", device, allocation.Namespace, allocation.Pod)
+		for _, name := range []string{allocation.Device, allocation.Peer} {
+			if name == "" || allocation.Netns == "" {
+				continue
+			}
+			if err := net.MoveToHostNs(name, allocation.Netns); err != nil {
+				fail("error moving device %s out of %s: %v", name, allocation.Netns, err)
+			}
+		}
+		if err := net.DeleteFlowRules(device, allocation.Owner); err != nil {
+			fail("error deleting flow rules of device %s: %v", device, err)
+		}
+		if err := net.DeleteEthtool(device, allocation.Owner); err != nil {
+			fail("error deleting ethtool filters of device %s: %v", device, err)
+		}
+		if err := net.RestorePromiscuous(device, allocation.Owner); err != nil {
+			fail("error restoring promiscuous mode of device %s: %v", device, err)
+		}
+		if allocation.RssHashKey != "" {
+			if err := net.RestoreRss(device, allocation.RssHashKey); err != nil {
+				fail("error restoring RSS of device %s: %v", device, err)
+			}
+		}
+		if allocation.Channels > 0 {
+			if err := net.SetChannels(device, &networking.Channels{Combined: allocation.Channels}); err != nil {
+				fail("error restoring channels of device %s: %v", device, err)
+			}
+		}
+		if err := bpf.Cleanbpf(device); err != nil {
+			fail("error detaching XDP program from device %s: %v", device, err)
+		}
+		if allocation.Mtu > 0 {
+			if err := net.SetMtu(device, allocation.Mtu); err != nil {
+				fail("error restoring MTU of device %s: %v", device, err)
+			}
+		}
+		if err := net.RemoveAllocation(device, allocation.Owner); err != nil {
+			fail("error removing allocation of device %s: %v", device, err)
+		}
+	}
+
+	// tap devices
+	hostDevices, err := net.GetHostDevices()
+	if err != nil {
+		fail("error getting host devices: %v", err)
+	}
+	for name := range hostDevices {
+		if !strings.HasPrefix(name, constants.Tap.Prefix) && name != constants.Tap.ProbeName {
+			continue
+		}
+		logging.Infof("Deleting tap device %s", name)
+		if err := net.DeleteTap(name); err != nil {
+			fail("error deleting tap device %s: %v", name, err)
+		}
+	}
+
+	// files
+	for _, err := range removeFiles(paths) {
+		fail("%v", err)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%d artifacts could not be removed: %s", len(errs), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+/*
+removeFiles removes the kubelet device plugin sockets, the BPF pin directory and the contents of
+the state directory, and the state directory itself unless it keeps a file.
+*/
+func removeFiles(paths Paths) []error {
+	var errs []error
+
+	sockets, err := filepath.Glob(paths.KubeletSockets)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("error listing kubelet sockets: %v", err))
+	}
+	for _, socket := range sockets {
+		logging.Infof("Removing %s", socket)
+		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	logging.Infof("Removing %s", paths.BpfPinDir)
+	if err := os.RemoveAll(paths.BpfPinDir); err != nil {
+		errs = append(errs, err)
+	}
+
+	entries, err := ioutil.ReadDir(paths.StateDir)
+	if err != nil && !os.IsNotExist(err) {
+		errs = append(errs, err)
+	}
+	kept := false
+	for _, entry := range entries {
+		path := filepath.Join(paths.StateDir, entry.Name())
+		if keep(paths.Keep, path) {
+			kept = true
+			continue
+		}
+		logging.Infof("Removing %s", path)
+		if err := os.RemoveAll(path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if !kept {
+		if err := os.Remove(paths.StateDir); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+func keep(kept []string, path string) bool {
+	for _, k := range kept {
+		if filepath.Clean(k) == filepath.Clean(path) {
+			return true
+		}
+	}
+	return false
+}
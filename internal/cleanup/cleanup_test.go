@@ -0,0 +1,82 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cleanup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	root, err := ioutil.TempDir("", "cleanup")
+	require.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(root)
+
+	paths := Paths{
+		StateDir:       filepath.Join(root, "afxdp_dp"),
+		BpfPinDir:      filepath.Join(root, "bpf", "afxdp"),
+		KubeletSockets: filepath.Join(root, "device-plugins", "afxdp-*.sock"),
+		Keep:           []string{filepath.Join(root, "afxdp_dp", "afxdp-dp.lock")},
+	}
+	files := []string{
+		filepath.Join(root, "afxdp_dp", "afxdp-dp.lock"),
+		filepath.Join(root, "afxdp_dp", "allocations.json"),
+		filepath.Join(root, "afxdp_dp", "pod1", "afxdp.sock"),
+		filepath.Join(root, "bpf", "afxdp", "xsks_map"),
+		filepath.Join(root, "device-plugins", "afxdp-pool1.sock"),
+		filepath.Join(root, "device-plugins", "kubelet.sock"),
+	}
+	for _, file := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0700), "Unexpected error creating directory")
+		require.NoError(t, ioutil.WriteFile(file, nil, 0600), "Unexpected error creating file")
+	}
+
+	fakeNet := networking.NewFakeHandler()
+	fakeNet.SetHostDevices(map[string][]string{"i40e": {"dev1"}, "tun": {"afxdptap0"}})
+	require.NoError(t, fakeNet.SetChannels("dev1", &networking.Channels{Combined: 4}))
+	require.NoError(t, fakeNet.RecordAllocation(&networking.Allocation{Device: "dev1", Owner: "container1", Netns: "/var/run/netns/pod1", Channels: 16, Mtu: 9000}))
+
+	assert.NoError(t, Run(fakeNet, bpf.NewFakeHandler(), paths), "Unexpected error cleaning up")
+
+	allocations, err := fakeNet.GetAllocations()
+	require.NoError(t, err, "Unexpected error getting allocations")
+	assert.Empty(t, allocations, "Allocations should be removed")
+	channels, err := fakeNet.GetChannels("dev1")
+	require.NoError(t, err, "Unexpected error getting channels")
+	assert.Equal(t, 16, channels.Combined, "Channels should be restored")
+	mtu, err := fakeNet.GetMtu("dev1")
+	require.NoError(t, err, "Unexpected error getting MTU")
+	assert.Equal(t, 9000, mtu, "MTU should be restored")
+
+	for file, expExists := range map[string]bool{
+		filepath.Join(root, "afxdp_dp", "afxdp-dp.lock"):          true,
+		filepath.Join(root, "afxdp_dp", "allocations.json"):       false,
+		filepath.Join(root, "afxdp_dp", "pod1"):                   false,
+		filepath.Join(root, "bpf", "afxdp"):                       false,
+		filepath.Join(root, "device-plugins", "afxdp-pool1.sock"): false,
+		filepath.Join(root, "device-plugins", "kubelet.sock"):     true,
+	} {
+		_, err := os.Stat(file)
+		assert.Equal(t, expExists, err == nil, "Unexpected existence of %s", file)
+	}
+}
@@ -24,33 +24,68 @@ import (
 	"github.com/containernetworking/plugins/pkg/ipam"
 	"github.com/containernetworking/plugins/pkg/ns"
 	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logfile"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logformats"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
 	logging "github.com/sirupsen/logrus"
-	"github.com/vishvananda/netlink"
-	"os"
 	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 )
 
-var bpfHandler = bpf.NewHandler()
+/*
+Handlers of the host operations made by the CNI, replaced by fake handlers in unit tests.
+*/
+var (
+	bpfHandler  = bpf.NewHandler()
+	netHandler  = networking.NewHandler()
+	hostHandler = host.NewHandler()
+)
 
 /*
 NetConfig holds the config passed via stdin
 */
 type NetConfig struct {
 	types.NetConf
-	Device        string `json:"deviceID"`
-	Mode          string `json:"mode"`
-	SkipUnloadBpf bool   `json:"skipUnloadBpf,omitempty"`
-	Queues        string `json:"queues,omitempty"`
-	LogFile       string `json:"logFile,omitempty"`
-	LogLevel      string `json:"logLevel,omitempty"`
+	Device        string            `json:"deviceID"`
+	Mode          string            `json:"mode"`
+	SkipUnloadBpf bool              `json:"skipUnloadBpf,omitempty"`
+	Queues        string            `json:"queues,omitempty"`
+	Rss           *RssConfig        `json:"rss,omitempty"`
+	Promiscuous   bool              `json:"promiscuous,omitempty"`
+	LogFile       string            `json:"logFile,omitempty"`
+	LogMaxSize    int               `json:"logFileMaxSize,omitempty"`
+	LogBackups    int               `json:"logFileBackups,omitempty"`
+	LogCompress   bool              `json:"logFileCompress,omitempty"`
+	LogLevel      string            `json:"logLevel,omitempty"`
+	LogLevels     map[string]string `json:"logLevels,omitempty"`
+}
+
+/*
+RssConfig holds the optional RSS config passed via stdin. Traffic is spread
+equally across Equal queues beginning at queue Start.
+*/
+type RssConfig struct {
+	Start   int    `json:"start"`
+	Equal   int    `json:"equal"`
+	HashKey string `json:"hashKey,omitempty"`
+}
+
+/*
+K8sArgs holds the Kubernetes specific arguments passed via CNI_ARGS
+*/
+type K8sArgs struct {
+	types.CommonArgs
+	K8S_POD_NAME      types.UnmarshallableString
+	K8S_POD_NAMESPACE types.UnmarshallableString
+	K8S_POD_UID       types.UnmarshallableString
 }
 
 func init() {
@@ -66,6 +101,8 @@ func (n NetConfig) Validate() error {
 		allowedModes                   = constants.Plugins.Modes
 		logLevels        []interface{} = make([]interface{}, len(allowedLogLevels))
 		modes            []interface{} = make([]interface{}, len(allowedModes))
+		logMaxSizeError                = "validate(): log file max size must be -1, 0, or between 1 and " + strconv.Itoa(constants.Logging.FileMaxSizeMax) + " MB"
+		logBackupsError                = "validate(): log file backups must be -1, 0, or between 1 and " + strconv.Itoa(constants.Logging.FileBackupsMax)
 	)
 
 	for i, logLevel := range allowedLogLevels {
@@ -75,6 +112,11 @@ func (n NetConfig) Validate() error {
 		modes[i] = mode
 	}
 
+	subsystemLevels := make([]*validation.KeyRules, len(constants.Logging.Subsystems))
+	for i, subsystem := range constants.Logging.Subsystems {
+		subsystemLevels[i] = validation.Key(subsystem, validation.In(logLevels...).Error("validate(): must be "+fmt.Sprintf("%v", logLevels))).Optional()
+	}
+
 	return validation.ValidateStruct(&n,
 		validation.Field(
 			&n.Device,
@@ -85,47 +127,82 @@ func (n NetConfig) Validate() error {
 			&n.LogFile,
 			validation.Match(regexp.MustCompile(constants.Logging.ValidFileRegex)).Error("must be a valid filename"),
 		),
+		validation.Field(
+			&n.LogMaxSize,
+			validation.When(
+				n.LogMaxSize != -1,
+				validation.Min(0).Error(logMaxSizeError),
+				validation.Max(constants.Logging.FileMaxSizeMax).Error(logMaxSizeError),
+			),
+		),
+		validation.Field(
+			&n.LogBackups,
+			validation.When(
+				n.LogBackups != -1,
+				validation.Min(0).Error(logBackupsError),
+				validation.Max(constants.Logging.FileBackupsMax).Error(logBackupsError),
+			),
+		),
 		validation.Field(
 			&n.LogLevel,
 			validation.In(logLevels...).Error("validate(): must be "+fmt.Sprintf("%v", logLevels)),
 		),
+		validation.Field(
+			&n.LogLevels,
+			validation.Map(subsystemLevels...),
+		),
 		validation.Field(
 			&n.Mode,
 			validation.In(modes...).Error("validate(): must be "+fmt.Sprintf("%v", modes)),
 		),
+		validation.Field(
+			&n.Queues,
+			is.Int.Error("validate(): queues must be a number"),
+		),
 	)
 }
 
-func loadConf(bytes []byte) (*NetConfig, error) {
+/*
+ParseConf decodes and validates a network configuration, without acting on it.
+*/
+func ParseConf(bytes []byte) (*NetConfig, error) {
 	n := &NetConfig{}
-	logging.SetReportCaller(true)
-	logging.SetFormatter(logformats.Default)
 
 	if err := json.Unmarshal(bytes, n); err != nil {
-		return nil, fmt.Errorf("loadConf(): failed to load network configuration: %w", err)
+		return nil, errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("loadConf(): failed to load network configuration: %w", err))
 	}
 
 	if err := n.Validate(); err != nil {
-		return nil, fmt.Errorf("loadConf(): Config validation error: %v", err)
+		return nil, errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("loadConf(): Config validation error: %v", err))
+	}
+
+	return n, nil
+}
+
+func loadConf(bytes []byte) (*NetConfig, error) {
+	logging.SetReportCaller(true)
+	logging.SetFormatter(logformats.Default)
+
+	n, err := ParseConf(bytes)
+	if err != nil {
+		return nil, err
 	}
 
 	if n.LogFile != "" {
-		fp, err := os.OpenFile(constants.Logging.Directory+n.LogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.FileMode(constants.Logging.FilePermissions))
+		fp, err := logfile.Open(n.LogFile, n.LogMaxSize, n.LogBackups, n.LogCompress)
 		if err != nil {
 			return nil, fmt.Errorf("loadConf(): cannot open logfile %s: %w", n.LogFile, err)
 		}
 		logging.SetOutput(fp)
 	}
 
-	if n.LogLevel != "" {
-		level, err := logging.ParseLevel(n.LogLevel)
-		if err != nil {
-			return nil, fmt.Errorf("loadConf(): cannot set log level: %w", err)
+	if n.LogLevel != "" || len(n.LogLevels) > 0 {
+		logLevel := n.LogLevel
+		if logLevel == "" {
+			logLevel = constants.Logging.DefaultLevel
 		}
-		logging.SetLevel(level)
-
-		if n.LogLevel == "debug" {
-			logging.SetFormatter(logformats.Debug)
+		if err := logformats.SetLevel(logLevel, n.LogLevels); err != nil {
+			return nil, fmt.Errorf("loadConf(): cannot set log level: %w", err)
 		}
 	}
 
@@ -139,11 +216,12 @@ func loadConf(bytes []byte) (*NetConfig, error) {
 /*
 CmdAdd is called by kubelet during pod create
 */
-func CmdAdd(args *skel.CmdArgs) error {
-	host := host.NewHandler()
+func CmdAdd(args *skel.CmdArgs) (err error) {
 	var result *current.Result
 	var deviceDetails *networking.Device
-	netHandler := networking.NewHandler()
+	var journalEntries []*networking.JournalEntry
+	defer func() { journalFinish(err, journalEntries, netHandler) }()
+	setLogFields(args)
 
 	cfg, err := loadConf(args.StdinData)
 	if err != nil {
@@ -154,18 +232,19 @@ func CmdAdd(args *skel.CmdArgs) error {
 	}
 
 	logging.Debugf("cmdAdd(): loaded config: %+v", cfg)
+	allocation := &networking.Allocation{Device: cfg.Device}
+	peerAllocation := &networking.Allocation{}
+
 	logging.Infof("cmdAdd(): getting container network namespace")
-	containerNs, err := ns.GetNS(args.Netns)
-	if err != nil {
+	if err := netHandler.CheckNetns(args.Netns); err != nil {
 		err = fmt.Errorf("cmdAdd(): failed to open container netns %q: %w", args.Netns, err)
 		logging.Errorf(err.Error())
 
 		return err
 	}
-	defer containerNs.Close()
 
 	logging.Infof("cmdAdd(): getting device from name")
-	device, err := netlink.LinkByName(cfg.Device)
+	mac, err := netHandler.GetMacAddress(cfg.Device)
 	if err != nil {
 		err = fmt.Errorf("cmdAdd(): failed to find device: %w", err)
 		logging.Errorf(err.Error())
@@ -173,41 +252,34 @@ func CmdAdd(args *skel.CmdArgs) error {
 		return err
 	}
 
-	logging.Infof("cmdAdd(): getting default network namespace")
-	defaultNs, err := ns.GetCurrentNS()
-	if err != nil {
-		err = fmt.Errorf("cmdDel(): failed to open default netns %q: %w", args.Netns, err)
-		logging.Errorf(err.Error())
-
-		return err
-	}
-	defer defaultNs.Close()
-
 	logging.Infof("cmdAdd(): checking if IPAM is required")
 	if cfg.IPAM.Type != "" {
-		result, err = getIPAM(args, cfg, device, defaultNs)
+		result, err = getIPAM(args, cfg, mac)
 		if err != nil {
-			err = fmt.Errorf("cmdAdd(): error configuring IPAM on device %q: %w", device.Attrs().Name, err)
+			err = fmt.Errorf("cmdAdd(): error configuring IPAM on device %q: %w", cfg.Device, err)
 			logging.Errorf(err.Error())
 
 			return err
 		}
 	}
 
-	if cfg.Mode == "primary" {
-		deviceFile, err := tools.FilePathExists(constants.DeviceFile.Directory + constants.DeviceFile.Name)
+	deviceFile, err := tools.FilePathExists(constants.DeviceFile.Directory + constants.DeviceFile.Name)
+	if err != nil {
+		logging.Errorf("cmdAdd(): Failed to locate deviceFile: %v", err)
+	}
+
+	if deviceFile {
+		deviceDetails, err = netHandler.GetDeviceFromFile(cfg.Device, constants.DeviceFile.Directory+constants.DeviceFile.Name)
 		if err != nil {
-			logging.Errorf("cmdAdd(): Failed to locate deviceFile: %v", err)
+			logging.Errorf("cmdAdd():- Failed to extract device map values: %v", err)
+			return err
+		}
+		if deviceDetails != nil {
+			allocation.Mtu = deviceDetails.RestoreMtu()
 		}
 
-		if deviceFile {
-			deviceDetails, err = netHandler.GetDeviceFromFile(cfg.Device, constants.DeviceFile.Directory+constants.DeviceFile.Name)
-			if err != nil {
-				logging.Errorf("cmdAdd():- Failed to extract device map values: %v", err)
-				return err
-			}
-
-			ethInstalled, version, err := host.HasEthtool()
+		if cfg.Mode == "primary" {
+			ethInstalled, version, err := hostHandler.HasEthtool()
 			if err != nil {
 				logging.Warningf("cmdAdd(): failed to discover ethtool on host: %v", err)
 			}
@@ -224,7 +296,8 @@ func CmdAdd(args *skel.CmdArgs) error {
 							logging.Errorf("cmdAdd(): Error extracting IP from result interface %v", err)
 							return err
 						}
-						err = netHandler.SetEthtool(ethtoolCommand, cfg.Device, iPAddr)
+						journalBegin(&networking.JournalEntry{Op: networking.JournalEthtool, Device: cfg.Device, Owner: args.ContainerID}, &journalEntries, netHandler)
+						err = netHandler.SetEthtool(ethtoolCommand, cfg.Device, iPAddr, args.ContainerID)
 						if err != nil {
 							logging.Errorf("cmdAdd(): unable to executed ethtool filter: %v", err)
 							return err
@@ -237,42 +310,86 @@ func CmdAdd(args *skel.CmdArgs) error {
 		}
 	}
 
-	logging.Infof("cmdAdd(): moving device from default to container network namespace")
-	if err := netlink.LinkSetNsFd(device, int(containerNs.Fd())); err != nil {
-		err = fmt.Errorf("cmdAdd(): failed to move device %q to container netns: %w", device.Attrs().Name, err)
-		logging.Errorf(err.Error())
+	if cfg.Queues != "" && cfg.Mode == "primary" {
+		queues, err := strconv.Atoi(cfg.Queues)
+		if err != nil {
+			err = fmt.Errorf("cmdAdd(): invalid queue count %q: %w", cfg.Queues, err)
+			logging.Errorf(err.Error())
 
-		return err
+			return err
+		}
+
+		logging.Infof("cmdAdd(): setting %d combined channels on device %s", queues, cfg.Device)
+		allocation.Channels = journalChannels(cfg.Device, &journalEntries, netHandler)
+		if err := netHandler.SetChannels(cfg.Device, &networking.Channels{Combined: queues}); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to set channels on device %q: %w", cfg.Device, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
 	}
 
-	logging.Infof("cmdAdd(): executing within container network namespace:")
-	if err := containerNs.Do(func(_ ns.NetNS) error {
+	if cfg.Rss != nil && cfg.Mode == "primary" {
+		logging.Infof("cmdAdd(): spreading RSS across %d queues from queue %d on device %s", cfg.Rss.Equal, cfg.Rss.Start, cfg.Device)
+		allocation.RssHashKey = journalRss(cfg.Device, cfg.Rss.HashKey, &journalEntries, netHandler)
+		if err := netHandler.SetRss(cfg.Device, cfg.Rss.Start, cfg.Rss.Equal, cfg.Rss.HashKey); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to set RSS on device %q: %w", cfg.Device, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
+	}
 
-		logging.Infof("cmdAdd(): set device to UP state")
-		if err := netlink.LinkSetUp(device); err != nil {
-			err = fmt.Errorf("cmdAdd(): failed to set device %q to UP state: %w", device.Attrs().Name, err)
+	if cfg.Mode == "primary" && (cfg.Promiscuous || (deviceDetails != nil && deviceDetails.Promiscuous())) {
+		logging.Infof("cmdAdd(): enabling promiscuous mode on device %s", cfg.Device)
+		journalBegin(&networking.JournalEntry{Op: networking.JournalPromiscuous, Device: cfg.Device, Owner: args.ContainerID}, &journalEntries, netHandler)
+		if err := netHandler.SetPromiscuous(cfg.Device, args.ContainerID); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to enable promiscuous mode on device %q: %w", cfg.Device, err)
 			logging.Errorf(err.Error())
 
 			return err
 		}
+	}
+
+	peer := ""
+	if cfg.Mode == "primary" && deviceDetails != nil {
+		peer = deviceDetails.Peer()
+	}
+
+	if peer != "" {
+		peerAllocation.Device = peer
+		if err := addPeer(args, cfg, peerAllocation, deviceDetails, result, netHandler, &journalEntries); err != nil {
+			return err
+		}
+	}
+
+	logging.Infof("cmdAdd(): moving device from default to container network namespace")
+	journalBegin(&networking.JournalEntry{Op: networking.JournalNetnsMove, Device: cfg.Device, Owner: args.ContainerID, Netns: args.Netns}, &journalEntries, netHandler)
+	if err := netHandler.MoveToNetns(cfg.Device, args.Netns); err != nil {
+		err = fmt.Errorf("cmdAdd(): failed to move device %q to container netns: %w", cfg.Device, err)
+		logging.Errorf(err.Error())
 
-		return nil
-	}); err != nil {
 		return err
 	}
 
 	if cfg.IPAM.Type != "" {
-		result, err = setIPAM(cfg, result, device, containerNs)
+		result, err = setIPAM(cfg, result, args.Netns)
 		if err != nil {
-			err = fmt.Errorf("cmdAdd(): error configuring IPAM on device netns %q: %w", device.Attrs().Name, err)
+			err = fmt.Errorf("cmdAdd(): error configuring IPAM on device netns %q: %w", cfg.Device, err)
 			logging.Errorf(err.Error())
 
 			return err
 		}
 	}
 
+	allocation.Peer = peer
+	recordAllocation(args, allocation, netHandler)
+	if peer != "" {
+		recordAllocation(args, peerAllocation, netHandler)
+	}
+
 	if result == nil {
-		return printLink(device, cfg.CNIVersion, containerNs)
+		return printLink(cfg.Device, mac, cfg.CNIVersion, args.Netns)
 	}
 
 	return types.PrintResult(result, cfg.CNIVersion)
@@ -282,8 +399,7 @@ func CmdAdd(args *skel.CmdArgs) error {
 CmdDel is called by kublet during pod delete
 */
 func CmdDel(args *skel.CmdArgs) error {
-	host := host.NewHandler()
-	netHandler := networking.NewHandler()
+	setLogFields(args)
 
 	cfg, err := loadConf(args.StdinData)
 	if err != nil {
@@ -294,48 +410,42 @@ func CmdDel(args *skel.CmdArgs) error {
 	}
 
 	logging.Infof("cmdDel(): getting container network namespace")
-	containerNs, err := ns.GetNS(args.Netns)
-	if err != nil {
+	if err := netHandler.CheckNetns(args.Netns); err != nil {
 		err = fmt.Errorf("cmdDel(): failed to open container netns %q: %w", args.Netns, err)
 		logging.Errorf(err.Error())
 
 		return err
 	}
-	defer containerNs.Close()
 
-	logging.Infof("cmdDel(): getting default network namespace")
-	defaultNs, err := ns.GetCurrentNS()
-	if err != nil {
-		err = fmt.Errorf("cmdDel(): failed to open default netns %q: %w", args.Netns, err)
+	peer := ""
+	allocation := &networking.Allocation{Device: cfg.Device}
+	peerAllocation := &networking.Allocation{}
+	if allocations, err := netHandler.GetAllocations(); err != nil {
+		logging.Warningf("cmdDel(): failed to read allocation records, unable to find bond peer or restore device state: %v", err)
+	} else if recorded, ok := allocations[cfg.Device]; ok && recorded.Owner == args.ContainerID {
+		allocation = recorded
+		peer = recorded.Peer
+		if recorded, ok := allocations[peer]; ok && recorded.Owner == args.ContainerID {
+			peerAllocation = recorded
+		}
+	}
+
+	logging.Infof("cmdDel(): moving device from container to default network namespace")
+	if err := netHandler.MoveFromNetns(cfg.Device, args.Netns); err != nil {
+		err = fmt.Errorf("cmdDel(): failed to move %q to host netns: %w", cfg.Device, err)
 		logging.Errorf(err.Error())
 
 		return err
 	}
-	defer defaultNs.Close()
 
-	logging.Infof("cmdDel(): executing within container network namespace:")
-	if err := containerNs.Do(func(_ ns.NetNS) error {
-
-		logging.Infof("cmdDel(): getting device from name")
-		device, err := netlink.LinkByName(cfg.Device)
-		if err != nil {
-			err = fmt.Errorf("cmdDel(): failed to find device %q in containerNS: %w", cfg.Device, err)
-			logging.Errorf(err.Error())
-
-			return err
-		}
-
-		logging.Infof("cmdDel(): moving device from container to default network namespace")
-		if err = netlink.LinkSetNsFd(device, int(defaultNs.Fd())); err != nil {
-			err = fmt.Errorf("cmdDel(): failed to move %q to host netns: %w", device.Attrs().Alias, err)
-			logging.Errorf(err.Error())
-
-			return err
-		}
+	logging.Infof("cmdDel(): removing allocation record of device: %s", cfg.Device)
+	if err := netHandler.RemoveAllocation(cfg.Device, args.ContainerID); err != nil {
+		logging.Warningf("cmdDel(): failed to remove allocation record: %v", err)
+	}
 
-		return nil
-	}); err != nil {
-		return err
+	if peer != "" {
+		peerAllocation.Device = peer
+		delPeer(args, cfg, peerAllocation, netHandler)
 	}
 
 	logging.Infof("cmdDel(): cleaning IPAM config on device")
@@ -355,20 +465,30 @@ func CmdDel(args *skel.CmdArgs) error {
 		}
 	}
 
+	restoreMtu(allocation, netHandler)
+
 	if cfg.Mode == "primary" {
 		logging.Debugf("cmdDel: checking host for Ethtool")
-		ethInstalled, _, err := host.HasEthtool()
+		ethInstalled, _, err := hostHandler.HasEthtool()
 		if err != nil {
 			logging.Errorf("cmdDel(): error checking if Ethtool is present on host: %v", err)
 			return err
 		}
 		if ethInstalled {
 			logging.Infof("cmdDel(): Removing ethtool filters on device: %s", cfg.Device)
-			err := netHandler.DeleteEthtool(cfg.Device)
+			err := netHandler.DeleteEthtool(cfg.Device, args.ContainerID)
 			if err != nil {
 				logging.Warningf("cmdDel(): failed to remove ethtool filter: %v", err)
 			}
 		}
+
+		logging.Infof("cmdDel(): restoring promiscuous state of device: %s", cfg.Device)
+		if err := netHandler.RestorePromiscuous(cfg.Device, args.ContainerID); err != nil {
+			logging.Warningf("cmdDel(): failed to restore promiscuous state: %v", err)
+		}
+
+		restoreRss(allocation, netHandler)
+		restoreChannels(allocation, netHandler)
 	}
 
 	if cfg.Mode == "cdq" {
@@ -395,21 +515,316 @@ func CmdDel(args *skel.CmdArgs) error {
 	return nil
 }
 
-func printLink(dev netlink.Link, cniVersion string, containerNs ns.NetNS) error {
+/*
+addPeer applies the configuration of a device to its bond peer and moves the peer into the
+container network namespace, so XDP and queue configuration is consistent across failover.
+The state of the peer to restore on release is set in peerAllocation.
+*/
+func addPeer(args *skel.CmdArgs, cfg *NetConfig, peerAllocation *networking.Allocation, deviceDetails *networking.Device,
+	result *current.Result, netHandler networking.Handler, journalEntries *[]*networking.JournalEntry) error {
+	peer := peerAllocation.Device
+	peerAllocation.Mtu = deviceDetails.PeerRestoreMtu()
+
+	logging.Infof("cmdAdd(): getting bond peer %s of device %s", peer, cfg.Device)
+	exists, err := netHandler.NetDevExists(peer)
+	if err == nil && !exists {
+		err = fmt.Errorf("device %q not found", peer)
+	}
+	if err != nil {
+		err = fmt.Errorf("cmdAdd(): failed to find bond peer: %w", err)
+		logging.Errorf(err.Error())
+
+		return err
+	}
+
+	if ethtoolCommand := deviceDetails.GetEthtoolFilters(); ethtoolCommand != nil {
+		logging.Infof("cmdAdd(): applying ethtool filters on bond peer: %s", peer)
+		iPAddr, err := extractIP(result)
+		if err != nil {
+			logging.Errorf("cmdAdd(): Error extracting IP from result interface %v", err)
+			return err
+		}
+		journalBegin(&networking.JournalEntry{Op: networking.JournalEthtool, Device: peer, Owner: args.ContainerID}, journalEntries, netHandler)
+		if err := netHandler.SetEthtool(ethtoolCommand, peer, iPAddr, args.ContainerID); err != nil {
+			logging.Errorf("cmdAdd(): unable to executed ethtool filter on bond peer: %v", err)
+			return err
+		}
+	}
+
+	if cfg.Queues != "" {
+		queues, err := strconv.Atoi(cfg.Queues)
+		if err != nil {
+			err = fmt.Errorf("cmdAdd(): invalid queue count %q: %w", cfg.Queues, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
+
+		logging.Infof("cmdAdd(): setting %d combined channels on bond peer %s", queues, peer)
+		peerAllocation.Channels = journalChannels(peer, journalEntries, netHandler)
+		if err := netHandler.SetChannels(peer, &networking.Channels{Combined: queues}); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to set channels on bond peer %q: %w", peer, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
+	}
+
+	if cfg.Rss != nil {
+		logging.Infof("cmdAdd(): spreading RSS across %d queues from queue %d on bond peer %s", cfg.Rss.Equal, cfg.Rss.Start, peer)
+		peerAllocation.RssHashKey = journalRss(peer, cfg.Rss.HashKey, journalEntries, netHandler)
+		if err := netHandler.SetRss(peer, cfg.Rss.Start, cfg.Rss.Equal, cfg.Rss.HashKey); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to set RSS on bond peer %q: %w", peer, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
+	}
+
+	if cfg.Promiscuous || deviceDetails.Promiscuous() {
+		logging.Infof("cmdAdd(): enabling promiscuous mode on bond peer %s", peer)
+		journalBegin(&networking.JournalEntry{Op: networking.JournalPromiscuous, Device: peer, Owner: args.ContainerID}, journalEntries, netHandler)
+		if err := netHandler.SetPromiscuous(peer, args.ContainerID); err != nil {
+			err = fmt.Errorf("cmdAdd(): failed to enable promiscuous mode on bond peer %q: %w", peer, err)
+			logging.Errorf(err.Error())
+
+			return err
+		}
+	}
+
+	logging.Infof("cmdAdd(): moving bond peer from default to container network namespace")
+	journalBegin(&networking.JournalEntry{Op: networking.JournalNetnsMove, Device: peer, Owner: args.ContainerID, Netns: args.Netns}, journalEntries, netHandler)
+	if err := netHandler.MoveToNetns(peer, args.Netns); err != nil {
+		err = fmt.Errorf("cmdAdd(): failed to move bond peer %q to container netns: %w", peer, err)
+		logging.Errorf(err.Error())
+
+		return err
+	}
+
+	return nil
+}
+
+/*
+delPeer returns the bond peer of a device to the default network namespace and removes the
+configuration applied to it by addPeer. Failures are logged but do not fail the delete, the
+peer is released on a best effort basis once the device itself has been released.
+The state of the peer is restored as recorded in peerAllocation.
+*/
+func delPeer(args *skel.CmdArgs, cfg *NetConfig, peerAllocation *networking.Allocation, netHandler networking.Handler) {
+	peer := peerAllocation.Device
+
+	logging.Infof("cmdDel(): moving bond peer %s from container to default network namespace", peer)
+	if err := netHandler.MoveFromNetns(peer, args.Netns); err != nil {
+		logging.Warningf("cmdDel(): failed to move bond peer %q to host netns: %v", peer, err)
+	}
+
+	if err := netHandler.RemoveAllocation(peer, args.ContainerID); err != nil {
+		logging.Warningf("cmdDel(): failed to remove allocation record of bond peer: %v", err)
+	}
+
+	if !cfg.SkipUnloadBpf {
+		logging.Infof("cmdDel(): removing BPF program from bond peer")
+		if err := bpfHandler.Cleanbpf(peer); err != nil {
+			logging.Warningf("cmdDel(): error removing BPF program from bond peer: %v", err)
+		}
+	}
+
+	restoreMtu(peerAllocation, netHandler)
+
+	if err := netHandler.DeleteEthtool(peer, args.ContainerID); err != nil {
+		logging.Warningf("cmdDel(): failed to remove ethtool filter from bond peer: %v", err)
+	}
+
+	if err := netHandler.RestorePromiscuous(peer, args.ContainerID); err != nil {
+		logging.Warningf("cmdDel(): failed to restore promiscuous state of bond peer: %v", err)
+	}
+
+	restoreRss(peerAllocation, netHandler)
+	restoreChannels(peerAllocation, netHandler)
+}
+
+/*
+restoreRss restores the RSS hash key a device had before CmdAdd changed it, as recorded with its
+allocation. The indirection table is reset along with it. Failing to restore the hash key does
+not fail the delete. The RSS of a CmdAdd that failed is restored by rolling back its journal
+entries instead, see journalFinish.
+*/
+func restoreRss(allocation *networking.Allocation, netHandler networking.Handler) {
+	if allocation.RssHashKey == "" {
+		return
+	}
+
+	logging.Infof("cmdDel(): restoring RSS hash key of device %s", allocation.Device)
+	if err := netHandler.RestoreRss(allocation.Device, allocation.RssHashKey); err != nil {
+		logging.Warningf("cmdDel(): failed to restore RSS hash key of device %s: %v", allocation.Device, err)
+	}
+}
+
+/*
+restoreChannels restores the combined channel count a device had before CmdAdd changed it, as
+recorded with its allocation. The RSS indirection table must be reset first, by restoreRss, as
+drivers refuse fewer channels than a configured indirection table spreads traffic across.
+Failing to restore the channels does not fail the delete. Channels changed by a CmdAdd that
+failed are restored by rolling back its journal entries instead, see journalFinish.
+*/
+func restoreChannels(allocation *networking.Allocation, netHandler networking.Handler) {
+	if allocation.Channels == 0 {
+		return
+	}
+
+	logging.Infof("cmdDel(): restoring %d combined channels on device %s", allocation.Channels, allocation.Device)
+	if err := netHandler.SetChannels(allocation.Device, &networking.Channels{Combined: allocation.Channels}); err != nil {
+		logging.Warningf("cmdDel(): failed to restore channels of device %s: %v", allocation.Device, err)
+	}
+}
+
+/*
+restoreMtu restores the MTU a device had before the device plugin lowered it to fit the UMEM
+frame size, as recorded with its allocation. Drivers limit the MTU while an XDP program is
+attached, so the program must be removed first. Failing to restore the MTU does not fail the
+delete.
+*/
+func restoreMtu(allocation *networking.Allocation, netHandler networking.Handler) {
+	if allocation.Mtu == 0 {
+		return
+	}
+
+	logging.Infof("cmdDel(): restoring MTU %d on device %s", allocation.Mtu, allocation.Device)
+	if err := netHandler.SetMtu(allocation.Device, allocation.Mtu); err != nil {
+		logging.Warningf("cmdDel(): failed to restore MTU of device %s: %v", allocation.Device, err)
+	}
+}
+
+/*
+setLogFields tags every line logged by this invocation with the pod, its UID and the container ID,
+so the lines of an invocation can be told apart from those of invocations for other pods.
+Fields missing from the CNI args are left out.
+*/
+func setLogFields(args *skel.CmdArgs) {
+	k8sArgs := K8sArgs{}
+	_ = types.LoadArgs(args.Args, &k8sArgs)
+
+	logformats.SetFields(logformats.Workload(string(k8sArgs.K8S_POD_NAMESPACE), string(k8sArgs.K8S_POD_NAME),
+		string(k8sArgs.K8S_POD_UID), args.ContainerID))
+}
+
+/*
+recordAllocation records which pod the device has been attached to, enabling the
+device plugin to label per-device statistics with the pod, along with the device
+state to restore on release. Failing to record the allocation does not fail the
+attachment.
+*/
+func recordAllocation(args *skel.CmdArgs, allocation *networking.Allocation, netHandler networking.Handler) {
+	k8sArgs := K8sArgs{}
+	if err := types.LoadArgs(args.Args, &k8sArgs); err != nil {
+		logging.Warningf("cmdAdd(): unable to parse CNI args: %v", err)
+	}
+
+	allocation.Owner = args.ContainerID
+	allocation.Pod = string(k8sArgs.K8S_POD_NAME)
+	allocation.Namespace = string(k8sArgs.K8S_POD_NAMESPACE)
+	allocation.PodUid = string(k8sArgs.K8S_POD_UID)
+	allocation.Netns = args.Netns
+
+	logging.Infof("cmdAdd(): recording allocation of device %s to pod %s/%s", allocation.Device, allocation.Namespace, allocation.Pod)
+	if err := netHandler.RecordAllocation(allocation); err != nil {
+		logging.Warningf("cmdAdd(): failed to record allocation: %v", err)
+	}
+}
+
+/*
+journalBegin writes a host networking change to the journal ahead of making it, so the device
+plugin can roll the change back should the CNI crash before finishing. The entry is appended to
+entries, which are ended by journalFinish once the CNI has finished, or rolled back if it failed.
+Failing to write the journal does not fail the attachment, the entry is still rolled back.
+*/
+func journalBegin(entry *networking.JournalEntry, entries *[]*networking.JournalEntry, netHandler networking.Handler) {
+	entry.Source = networking.JournalSourceCni
+	*entries = append(*entries, entry)
+	if _, err := netHandler.JournalBegin(entry); err != nil {
+		logging.Warningf("cmdAdd(): failed to write %s of device %s to journal: %v", entry.Op, entry.Device, err)
+	}
+}
+
+/*
+journalChannels writes a channels change to the journal, recording the current combined
+channel count so it can be restored. The count is returned, to be recorded with the
+allocation, 0 if it could not be read.
+*/
+func journalChannels(deviceName string, entries *[]*networking.JournalEntry, netHandler networking.Handler) int {
+	channels, err := netHandler.GetChannels(deviceName)
+	if err != nil {
+		logging.Warningf("cmdAdd(): failed to get channels of device %s, change will not be journaled or restored: %v", deviceName, err)
+		return 0
+	}
+	journalBegin(&networking.JournalEntry{Op: networking.JournalChannels, Device: deviceName, Channels: channels.Combined}, entries, netHandler)
+	return channels.Combined
+}
+
+/*
+journalRss writes an RSS change to the journal, recording the current hash key if the change
+sets a new one, so it can be restored. The hash key is returned, to be recorded with the
+allocation, empty if the change keeps the hash key or it could not be read.
+*/
+func journalRss(deviceName string, hashKey string, entries *[]*networking.JournalEntry, netHandler networking.Handler) string {
+	entry := &networking.JournalEntry{Op: networking.JournalRss, Device: deviceName}
+	if hashKey != "" {
+		rss, err := netHandler.GetRss(deviceName)
+		if err != nil {
+			logging.Warningf("cmdAdd(): failed to get RSS hash key of device %s, it will not be restored: %v", deviceName, err)
+		} else {
+			entry.RssHashKey = rss.HashKey
+		}
+	}
+	journalBegin(entry, entries, netHandler)
+	return entry.RssHashKey
+}
+
+/*
+journalFinish ends the journal entries written by journalBegin once the CNI has finished. If the
+CNI failed with err, the changes they record are rolled back instead, most recent first, as the
+allocation is not recorded and CmdDel cannot restore them.
+*/
+func journalFinish(err error, entries []*networking.JournalEntry, netHandler networking.Handler) {
+	if len(entries) == 0 {
+		return
+	}
+
+	if err != nil {
+		logging.Warningf("cmdAdd(): rolling back host changes after failure: %v", err)
+		networking.RollbackJournalEntries(entries, netHandler, bpfHandler.Cleanbpf)
+		return
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		if entry.Id != 0 {
+			ids = append(ids, entry.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := netHandler.JournalEnd(ids...); err != nil {
+		logging.Warningf("cmdAdd(): failed to end journal entries: %v", err)
+	}
+}
+
+func printLink(deviceName string, mac string, cniVersion string, netnsPath string) error {
 	result := current.Result{
 		CNIVersion: current.ImplementedSpecVersion,
 		Interfaces: []*current.Interface{
 			{
-				Name:    dev.Attrs().Name,
-				Mac:     dev.Attrs().HardwareAddr.String(),
-				Sandbox: containerNs.Path(),
+				Name:    deviceName,
+				Mac:     mac,
+				Sandbox: netnsPath,
 			},
 		},
 	}
 	return types.PrintResult(&result, cniVersion)
 }
 
-func getIPAM(args *skel.CmdArgs, cfg *NetConfig, device netlink.Link, netns ns.NetNS) (*current.Result, error) {
+func getIPAM(args *skel.CmdArgs, cfg *NetConfig, mac string) (*current.Result, error) {
 	var result *current.Result
 
 	logging.Infof("configureIPAM(): running IPAM plugin: " + cfg.IPAM.Type)
@@ -448,9 +863,9 @@ func getIPAM(args *skel.CmdArgs, cfg *NetConfig, device netlink.Link, netns ns.N
 	}
 
 	result.Interfaces = []*current.Interface{{
-		Name:    device.Attrs().Name,
-		Mac:     device.Attrs().HardwareAddr.String(),
-		Sandbox: netns.Path(),
+		Name:    cfg.Device,
+		Mac:     mac,
+		Sandbox: args.Netns,
 	}}
 	for _, ipc := range result.IPs {
 		logging.Debugf("configureIPAM(): setting IPConfig interface")
@@ -460,13 +875,19 @@ func getIPAM(args *skel.CmdArgs, cfg *NetConfig, device netlink.Link, netns ns.N
 	return result, nil
 }
 
-func setIPAM(cfg *NetConfig, result *current.Result, device netlink.Link, netns ns.NetNS) (*current.Result, error) {
-	logging.Infof("configureIPAM(): executing within host netns")
+func setIPAM(cfg *NetConfig, result *current.Result, netnsPath string) (*current.Result, error) {
+	netns, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return result, err
+	}
+	defer netns.Close()
+
+	logging.Infof("configureIPAM(): executing within container netns")
 	if err := netns.Do(func(_ ns.NetNS) error {
 
 		logging.Infof("configureIPAM(): setting device IP")
-		if err := ipam.ConfigureIface(device.Attrs().Name, result); err != nil {
-			err = fmt.Errorf("configureIPAM(): Error setting IPAM on device %q: %w", device.Attrs().Name, err)
+		if err := ipam.ConfigureIface(cfg.Device, result); err != nil {
+			err = fmt.Errorf("configureIPAM(): Error setting IPAM on device %q: %w", cfg.Device, err)
 			logging.Errorf(err.Error())
 
 			return err
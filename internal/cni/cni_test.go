@@ -20,6 +20,9 @@ import (
 	"github.com/containernetworking/cni/pkg/skel"
 	"github.com/containernetworking/cni/pkg/types"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -113,6 +116,7 @@ func TestGetConfig(t *testing.T) {
 			} else {
 				require.Error(t, tc.expErr, "Unexpected error returned")
 				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected error returned")
+				assert.True(t, errors.Is(err, errdefs.ErrValidationFailed), "Config errors should be validation errors")
 			}
 			assert.Equal(t, tc.expConfig, cfg, "Returned unexpected config")
 
@@ -229,3 +233,246 @@ func TestCmdDel(t *testing.T) {
 		})
 	}
 }
+
+func TestCmdAddDelFake(t *testing.T) {
+	netConf := `{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","skipUnloadBpf":true}`
+	podNetns := "/var/run/netns/pod-1"
+
+	testCases := []struct {
+		name      string
+		netConf   string
+		setup     func(fake networking.FakeHandler)
+		expAddErr string
+		expDelErr string
+		expNetns  string // netns of dev1 after CmdAdd
+	}{
+		{
+			name:     "add and delete device",
+			netConf:  netConf,
+			expNetns: podNetns,
+		},
+		{
+			name:      "device not on host",
+			netConf:   `{"cniVersion":"0.3.0","deviceID":"dev9","name":"test-network","type":"afxdp","skipUnloadBpf":true}`,
+			expAddErr: "cmdAdd(): failed to move device \"dev9\" to container netns",
+			expDelErr: "cmdDel(): failed to move \"dev9\" to host netns",
+		},
+		{
+			name:    "fail to open netns",
+			netConf: netConf,
+			setup: func(fake networking.FakeHandler) {
+				fake.SetError("CheckNetns", errors.New("no such file or directory"))
+			},
+			expAddErr: "cmdAdd(): failed to open container netns \"/var/run/netns/pod-1\": no such file or directory",
+			expDelErr: "cmdDel(): failed to open container netns \"/var/run/netns/pod-1\": no such file or directory",
+		},
+		{
+			name:    "fail to move device to netns",
+			netConf: netConf,
+			setup: func(fake networking.FakeHandler) {
+				fake.SetError("MoveToNetns", errors.New("operation not permitted"))
+			},
+			expAddErr: "cmdAdd(): failed to move device \"dev1\" to container netns: operation not permitted",
+			expDelErr: "cmdDel(): failed to move \"dev1\" to host netns",
+		},
+		{
+			name:    "fail to move device from netns",
+			netConf: netConf,
+			setup: func(fake networking.FakeHandler) {
+				fake.SetError("MoveFromNetns", errors.New("operation not permitted"))
+			},
+			expNetns:  podNetns,
+			expDelErr: "cmdDel(): failed to move \"dev1\" to host netns: operation not permitted",
+		},
+	}
+
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := networking.NewFakeHandler()
+			fake.SetHostDevices(map[string][]string{"i40e": {"dev1", "dev2"}})
+			if tc.setup != nil {
+				tc.setup(fake)
+			}
+			bpfHandler = bpf.NewFakeHandler()
+			netHandler = fake
+			hostHandler = host.NewFakeHandler()
+
+			args := &skel.CmdArgs{ContainerID: "container-1", Netns: podNetns, StdinData: []byte(tc.netConf)}
+
+			err := CmdAdd(args)
+			if tc.expAddErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expAddErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.expNetns, fake.GetNetns("dev1"), "device in wrong netns after CmdAdd")
+
+			err = CmdDel(args)
+			if tc.expDelErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expDelErr)
+			} else {
+				require.NoError(t, err)
+				assert.Empty(t, fake.GetNetns("dev1"), "device not returned to host netns by CmdDel")
+			}
+		})
+	}
+}
+
+func TestCmdDelRestoresChannels(t *testing.T) {
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	fake := networking.NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	require.NoError(t, fake.SetChannels("dev1", &networking.Channels{Combined: 16}))
+	bpfHandler = bpf.NewFakeHandler()
+	netHandler = fake
+	hostHandler = host.NewFakeHandler()
+
+	args := &skel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/pod-1",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","mode":"primary","queues":"4","skipUnloadBpf":true}`),
+	}
+
+	require.NoError(t, CmdAdd(args))
+	channels, err := fake.GetChannels("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, channels.Combined, "channels not set by CmdAdd")
+	allocations, err := fake.GetAllocations()
+	require.NoError(t, err)
+	require.Contains(t, allocations, "dev1")
+	assert.Equal(t, 16, allocations["dev1"].Channels, "previous channels not recorded with the allocation")
+
+	require.NoError(t, CmdDel(args))
+	channels, err = fake.GetChannels("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, 16, channels.Combined, "channels not restored by CmdDel")
+}
+
+func TestCmdAddFailureRestoresChannels(t *testing.T) {
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	fake := networking.NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	require.NoError(t, fake.SetChannels("dev1", &networking.Channels{Combined: 16}))
+	fake.SetError("MoveToNetns", errors.New("fake error"))
+	bpfHandler = bpf.NewFakeHandler()
+	netHandler = fake
+	hostHandler = host.NewFakeHandler()
+
+	args := &skel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/pod-1",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","mode":"primary","queues":"4","skipUnloadBpf":true}`),
+	}
+
+	require.Error(t, CmdAdd(args))
+	channels, err := fake.GetChannels("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, 16, channels.Combined, "channels not restored after CmdAdd failed")
+	pending, err := fake.GetJournal()
+	require.NoError(t, err)
+	assert.Empty(t, pending, "rolled back entries left in the journal")
+}
+
+func TestCmdDelRestoresRss(t *testing.T) {
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	fake := networking.NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	bpfHandler = bpf.NewFakeHandler()
+	netHandler = fake
+	hostHandler = host.NewFakeHandler()
+
+	rss, err := fake.GetRss("dev1")
+	require.NoError(t, err)
+	hostKey := rss.HashKey
+	podKey := "01:02:03:04:05:06:07:08"
+
+	args := &skel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/pod-1",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","mode":"primary","rss":{"start":0,"equal":4,"hashKey":"` + podKey + `"},"skipUnloadBpf":true}`),
+	}
+
+	require.NoError(t, CmdAdd(args))
+	rss, err = fake.GetRss("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, podKey, rss.HashKey, "hash key not set by CmdAdd")
+	allocations, err := fake.GetAllocations()
+	require.NoError(t, err)
+	require.Contains(t, allocations, "dev1")
+	assert.Equal(t, hostKey, allocations["dev1"].RssHashKey, "previous hash key not recorded with the allocation")
+
+	require.NoError(t, CmdDel(args))
+	rss, err = fake.GetRss("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, hostKey, rss.HashKey, "hash key not restored by CmdDel")
+}
+
+func TestCmdAddFailureRestoresRss(t *testing.T) {
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	fake := networking.NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	fake.SetError("MoveToNetns", errors.New("fake error"))
+	bpfHandler = bpf.NewFakeHandler()
+	netHandler = fake
+	hostHandler = host.NewFakeHandler()
+
+	rss, err := fake.GetRss("dev1")
+	require.NoError(t, err)
+	hostKey := rss.HashKey
+
+	args := &skel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/pod-1",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","mode":"primary","rss":{"start":0,"equal":4,"hashKey":"01:02:03:04:05:06:07:08"},"skipUnloadBpf":true}`),
+	}
+
+	require.Error(t, CmdAdd(args))
+	rss, err = fake.GetRss("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, hostKey, rss.HashKey, "hash key not restored after CmdAdd failed")
+}
+
+func TestCmdDelRestoresMtu(t *testing.T) {
+	defer func(b bpf.Handler, n networking.Handler, h host.Handler) {
+		bpfHandler, netHandler, hostHandler = b, n, h
+	}(bpfHandler, netHandler, hostHandler)
+
+	fake := networking.NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	bpfHandler = bpf.NewFakeHandler()
+	netHandler = fake
+	hostHandler = host.NewFakeHandler()
+
+	// the device plugin lowered the MTU on allocation, the CNI recorded the previous one
+	require.NoError(t, fake.SetMtu("dev1", networking.MaxMtu(4096)))
+	require.NoError(t, fake.RecordAllocation(&networking.Allocation{Device: "dev1", Owner: "container-1", Mtu: 9000}))
+
+	args := &skel.CmdArgs{
+		ContainerID: "container-1",
+		Netns:       "/var/run/netns/pod-1",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","deviceID":"dev1","name":"test-network","type":"afxdp","mode":"primary"}`),
+	}
+
+	require.NoError(t, CmdDel(args))
+	mtu, err := fake.GetMtu("dev1")
+	require.NoError(t, err)
+	assert.Equal(t, 9000, mtu, "MTU not restored by CmdDel")
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package crash recovers panics of the long running Go routines of the device plugin. A panic on a
+Go routine other than main kills the whole process, losing the pools of every pod on the node.
+Recovered panics are logged with the stack trace of the Go routine that panicked, and counted by
+afxdp_crashes_total, so crashes are not silent.
+*/
+package crash
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+)
+
+var crashes = metrics.NewCounterVec("crashes_total",
+	"Number of panics recovered, by component.", "component")
+
+/*
+restartDelay is the delay before restarting a component after a panic, variable for testing.
+*/
+var restartDelay = time.Duration(constants.Crash.RestartDelay) * time.Second
+
+/*
+Go runs fn on a new Go routine as the named component. If fn panics the panic is recovered and
+reported, and fn is run again, up to constants.Crash.MaxRestarts times. The component is left
+stopped after that, as it is crashing on every run. Go routines started by fn are not covered.
+*/
+func Go(component string, fn func()) {
+	go run(component, fn)
+}
+
+func run(component string, fn func()) {
+	for restarts := 0; ; restarts++ {
+		if !panics(component, fn) {
+			return
+		}
+		if restarts >= constants.Crash.MaxRestarts {
+			logging.Errorf("Component %s crashed %d times, not restarting", component, restarts+1)
+			return
+		}
+		logging.Warningf("Restarting component %s", component)
+		time.Sleep(restartDelay)
+	}
+}
+
+/*
+panics runs fn, and returns true if fn panicked.
+*/
+func panics(component string, fn func()) (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			report(component, r)
+			panicked = true
+		}
+	}()
+
+	fn()
+	return false
+}
+
+/*
+Recover recovers and reports a panic of the calling Go routine, which then returns normally from
+the function deferring Recover. Recover must be deferred directly, as in defer crash.Recover("x").
+It is for functions that are restarted by their caller, such as a request handler.
+*/
+func Recover(component string) {
+	if r := recover(); r != nil {
+		report(component, r)
+	}
+}
+
+func report(component string, r interface{}) {
+	crashes.Add(1, component)
+	logging.Errorf("Panic in component %s: %v\n%s", component, r, debug.Stack())
+}
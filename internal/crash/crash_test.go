@@ -0,0 +1,111 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crash
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGo(t *testing.T) {
+	restartDelay = 0
+
+	testCases := []struct {
+		name       string
+		component  string
+		panics     int
+		expRuns    int
+		expCrashes string
+	}{
+		{
+			name:      "no panic",
+			component: "steady",
+			expRuns:   1,
+		},
+		{
+			name:       "panic once",
+			component:  "flaky",
+			panics:     1,
+			expRuns:    2,
+			expCrashes: `afxdp_crashes_total{component="flaky"} 1`,
+		},
+		{
+			name:       "panic on every run",
+			component:  "broken",
+			panics:     100,
+			expRuns:    constants.Crash.MaxRestarts + 1,
+			expCrashes: `afxdp_crashes_total{component="broken"} 6`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			runs := 0
+			done := make(chan struct{})
+
+			go func() {
+				run(tc.component, func() {
+					runs++
+					if runs <= tc.panics {
+						panic("malformed request")
+					}
+				})
+				close(done)
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				require.FailNow(t, "Component did not stop")
+			}
+			assert.Equal(t, tc.expRuns, runs, "Unexpected number of runs")
+
+			var out bytes.Buffer
+			require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+			if tc.expCrashes != "" {
+				assert.Contains(t, out.String(), tc.expCrashes, "Crashes should be counted")
+			} else {
+				assert.NotContains(t, out.String(), `component="`+tc.component+`"`, "Unexpected crash")
+			}
+		})
+	}
+}
+
+func TestRecover(t *testing.T) {
+	handled := 0
+	handle := func(request string) {
+		defer Recover("handler")
+		if request == "" {
+			panic("empty request")
+		}
+		handled++
+	}
+
+	for _, request := range []string{"/connect", "", "/fin"} {
+		handle(request)
+	}
+	assert.Equal(t, 2, handled, "Requests after a panic should be handled")
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+	assert.Contains(t, out.String(), `afxdp_crashes_total{component="handler"} 1`, "Crashes should be counted")
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"sort"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	logging "github.com/sirupsen/logrus"
+)
+
+var (
+	poolDevices = metrics.NewGaugeVec("pool_devices",
+		"Number of devices in a pool, as advertised by the device plugin or as allocatable according to the kubelet.", metrics.LabelPool, "source")
+	poolDeviceDrift = metrics.NewGaugeVec("pool_device_drift",
+		"Number of devices advertised by the device plugin but not allocatable according to the kubelet, or the reverse.", metrics.LabelPool)
+)
+
+/*
+watchAllocatable periodically cross-checks the devices advertised by the pool against the
+devices the kubelet considers allocatable for the pool resource, until the StopSignal channel
+is closed. Drift between the two means pods may be scheduled against devices the pool does not
+have, or devices go unused. Kubelets without the GetAllocatableResources endpoint are skipped.
+*/
+func (pm *PoolManager) watchAllocatable() {
+	go func() {
+		select {
+		case <-pm.StopSignal:
+			return
+		case <-time.After(time.Duration(constants.PodResources.AllocatableSettle) * time.Second):
+		}
+
+		ticker := time.NewTicker(time.Duration(constants.PodResources.AllocatableInterval) * time.Second)
+		defer ticker.Stop()
+
+		for {
+			if err := pm.checkAllocatable(); err != nil {
+				if resourcesapi.IsUnimplemented(err) {
+					logging.Infof("Pool %s: kubelet does not implement GetAllocatableResources, advertised devices will not be cross-checked", pm.Name)
+					return
+				}
+				logging.Warningf("Pool %s: unable to cross-check advertised devices with the kubelet: %v", pm.Name, err)
+			}
+
+			select {
+			case <-pm.StopSignal:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+/*
+checkAllocatable compares the devices advertised by the pool with the kubelet allocatable devices,
+logging and publishing any drift.
+*/
+func (pm *PoolManager) checkAllocatable() error {
+	allocatable, err := pm.PodResHandler.GetAllocatableDevices()
+	if err != nil {
+		return err
+	}
+
+	var advertised []string
+	for name := range pm.Devices {
+		advertised = append(advertised, name)
+	}
+
+	kubelet := allocatable[pm.DevicePrefix+"/"+pm.Name]
+	missing, unexpected := deviceDrift(advertised, kubelet)
+
+	poolDevices.Set(float64(len(advertised)), pm.Name, "plugin")
+	poolDevices.Set(float64(len(kubelet)), pm.Name, "kubelet")
+	poolDeviceDrift.Set(float64(len(missing)+len(unexpected)), pm.Name)
+
+	if len(missing) > 0 {
+		logging.Warningf("Pool %s: devices %v are advertised but not allocatable according to the kubelet", pm.Name, missing)
+	}
+	if len(unexpected) > 0 {
+		logging.Warningf("Pool %s: devices %v are allocatable according to the kubelet but not advertised", pm.Name, unexpected)
+	}
+	if len(missing) == 0 && len(unexpected) == 0 {
+		logging.Debugf("Pool %s: kubelet allocatable devices match the %d advertised devices", pm.Name, len(advertised))
+	}
+
+	return nil
+}
+
+/*
+deviceDrift returns the advertised devices that are not allocatable, and the allocatable
+devices that are not advertised, each sorted.
+*/
+func deviceDrift(advertised []string, allocatable []string) ([]string, []string) {
+	inAllocatable := make(map[string]bool)
+	for _, dev := range allocatable {
+		inAllocatable[dev] = true
+	}
+	inAdvertised := make(map[string]bool)
+	for _, dev := range advertised {
+		inAdvertised[dev] = true
+	}
+
+	var missing, unexpected []string
+	for _, dev := range advertised {
+		if !inAllocatable[dev] {
+			missing = append(missing, dev)
+		}
+	}
+	for _, dev := range allocatable {
+		if !inAdvertised[dev] {
+			unexpected = append(unexpected, dev)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+
+	return missing, unexpected
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAllocatable(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+
+	testCases := []struct {
+		name          string
+		advertised    []string
+		allocatable   []string
+		expDriftValue string
+	}{
+		{
+			name:          "no drift",
+			advertised:    []string{"dev_1", "dev_2"},
+			allocatable:   []string{"dev_2", "dev_1"},
+			expDriftValue: `afxdp_pool_device_drift{pool="myPool"} 0`,
+		},
+		{
+			name:          "device not allocatable",
+			advertised:    []string{"dev_1", "dev_2"},
+			allocatable:   []string{"dev_1"},
+			expDriftValue: `afxdp_pool_device_drift{pool="myPool"} 1`,
+		},
+		{
+			name:          "stale and missing devices",
+			advertised:    []string{"dev_1", "dev_2"},
+			allocatable:   []string{"dev_1", "dev_3", "dev_4"},
+			expDriftValue: `afxdp_pool_device_drift{pool="myPool"} 3`,
+		},
+		{
+			name:          "resource unknown to kubelet",
+			advertised:    []string{"dev_1"},
+			expDriftValue: `afxdp_pool_device_drift{pool="myPool"} 1`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			podRes := resourcesapi.NewFakeHandler()
+			if tc.allocatable != nil {
+				podRes.SetAllocatableDevices("afxdp/myPool", tc.allocatable)
+			}
+
+			devices := make(map[string]*networking.Device)
+			for _, name := range tc.advertised {
+				devices[name] = networking.CreateTestDevice(name, "primary", "ice", "", "", netHandler)
+			}
+
+			pm := NewPoolManager(PoolConfig{Name: "myPool", Mode: "primary", Devices: devices})
+			pm.PodResHandler = podRes
+
+			require.NoError(t, pm.checkAllocatable(), "Unexpected error")
+
+			var out bytes.Buffer
+			require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+			assert.Contains(t, out.String(), tc.expDriftValue, "Device drift metric does not match")
+		})
+	}
+}
+
+func TestDeviceDrift(t *testing.T) {
+	missing, unexpected := deviceDrift([]string{"dev_3", "dev_1", "dev_2"}, []string{"dev_2", "dev_5", "dev_4"})
+	assert.Equal(t, []string{"dev_1", "dev_3"}, missing, "Missing devices do not match")
+	assert.Equal(t, []string{"dev_4", "dev_5"}, unexpected, "Unexpected devices do not match")
+}
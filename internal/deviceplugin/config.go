@@ -17,15 +17,22 @@
 package deviceplugin
 
 import (
-	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"os"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
 	logging "github.com/sirupsen/logrus"
 )
 
@@ -34,6 +41,7 @@ var (
 	node        host.Handler
 	cfgFile     *configFile
 	hostDevices map[string]*networking.Device
+	tapCount    int
 )
 
 /*
@@ -41,9 +49,63 @@ PluginConfig is the object that represents the overall plugin config.
 Global configurations such as log levels are contained here.
 */
 type PluginConfig struct {
-	LogFile     string
-	LogLevel    string
-	KindCluster bool
+	Version           string
+	PodCheckInterval  int // seconds between checks that a pod connected to a UDS still exists, 0 if not set
+	ApiServerTimeout  int // seconds to wait for the API server, 0 if not set
+	PodResCacheTTL    int // seconds for which pod resources are cached, 0 if not set
+	PodResTimeout     int // seconds to wait for a pod resources API call, 0 if not set
+	KubeletTimeout    int // seconds to wait for the kubelet device plugin gRPC, 0 if not set
+	UdsIdleTimeout    int // seconds a UDS connection may be idle in pools not setting UdsTimeout, 0 if not set
+	ShutdownTimeout   int // seconds gRPC calls in progress are given to finish on shutdown, 0 if not set
+	LogFile           string
+	LogFileMaxSize    int
+	LogFileBackups    int
+	LogFileCompress   bool
+	LogDedup          int
+	LogBackend        string
+	LogFormat         string
+	AuditFile         string
+	LogLevel          string
+	LogLevels         map[string]string
+	KindCluster       bool
+	MetricsAddr       string
+	MetricsMaxSeries  int
+	MetricsDropLabels []string
+	HealthAddr        string
+	PodResSock        string
+	ApiFallback       bool
+	Events            bool
+	RequirePeerCgroup bool // refuse pods whose connected process cannot be verified to run in the pod
+	RequireToken      bool // refuse pods that do not send the handshake token of the allocation
+	RequireChallenge  bool // refuse pods that do not request a challenge before their connection request
+	RequireSigned     bool // refuse pods that do not sign their messages with the handshake token
+	RevalidateFds     bool // check the pod still holds the device of each file descriptor request
+	SingleUseFds      bool // pass the file descriptor of each device at most once per connection
+	DropPrivileges    bool // run the UDS handshake as HandshakeUid and HandshakeGid, without the capabilities it does not need
+	HandshakeUid      int
+	HandshakeGid      int
+	MinimalSyscalls   bool // avoid optional syscalls, for strict seccomp and AppArmor profiles
+	TracingEndpoint   string
+	SelinuxLabel      string
+	BpfHelper         string
+	BpfPublicKey      string
+	DetachXdp         bool // detach XDP programs from pool devices left in the host network namespace on shutdown
+	SkipPrereqs       bool // skip verifying the device plugin can serve pods before registering with the kubelet
+	FeatureGates      map[string]bool
+	SocketDir         RuntimeDir
+	BpfPinDir         RuntimeDir
+	LogDir            RuntimeDir
+}
+
+/*
+RuntimeDir is a directory the device plugin creates at startup, with the owner, group and
+permissions it is given.
+*/
+type RuntimeDir struct {
+	Path  string
+	Owner int
+	Group int
+	Mode  os.FileMode
 }
 
 /*
@@ -62,6 +124,16 @@ type PoolConfig struct {
 	RequiresUnprivilegedBpf bool                          // a boolean to say if this pool requires unprivileged BPF
 	UID                     int                           // the id of the pod user, we give this user ACL access to the UDS socket
 	EthtoolCmds             []string                      // list of ethtool filters to apply to the netdev
+	Promiscuous             bool                          // a boolean to say if devices from this pool are put into promiscuous mode on allocation
+	UmemFrameSize           int                           // the UMEM frame size pods of this pool use, device MTUs are validated against it
+	AdjustMtu               bool                          // a boolean to say if device MTUs too large for the UMEM frame size are lowered rather than refused
+	IrqCpus                 []int                         // the CPUs the queue IRQs of allocated devices are pinned to
+	IrqPodCpus              bool                          // a boolean to say if the queue IRQs of allocated devices are pinned to the exclusive CPUs of the pod
+	AllowedUids             []int                         // the UIDs a process connecting to the UDS may run as, any if empty
+	AllowedGids             []int                         // the GIDs a process connecting to the UDS may run as, any if empty
+	UdsProfile              string                        // the UDS protocol profile of the applications of the pool, CNDP or DPDK
+	UdsLegacyPath           bool                          // a boolean to say if the socket is also mounted at the legacy path, for applications that do not look in the socket directory
+	UdsFaults               *udsserver.Faults             // faults injected into the UDS responses, nil if none are, has no use outside of development and testing
 }
 
 /*
@@ -69,24 +141,155 @@ GetPluginConfig returns the global config for the device plugin.
 This config is returned in a PluginConfig object
 */
 func GetPluginConfig(configFile string) (PluginConfig, error) {
-	var pluginConfig PluginConfig
-
 	if cfgFile == nil {
 		if err := readConfigFile(configFile); err != nil {
 			logging.Errorf("Error reading config file: %v", err)
-			return pluginConfig, err
+			return PluginConfig{}, err
 		}
 	}
 
-	pluginConfig = PluginConfig{
-		LogFile:     cfgFile.LogFile,
-		LogLevel:    cfgFile.LogLevel,
-		KindCluster: cfgFile.KindCluster,
+	return pluginConfigOf(cfgFile)
+}
+
+/*
+pluginConfigOf returns the global config of the config file, with any overriding env vars applied.
+*/
+func pluginConfigOf(cfgFile *configFile) (PluginConfig, error) {
+	pluginConfig := PluginConfig{
+		Version:           cfgFile.Version,
+		PodCheckInterval:  cfgFile.Timeouts.PodCheckInterval,
+		ApiServerTimeout:  cfgFile.Timeouts.ApiServer,
+		PodResCacheTTL:    cfgFile.Timeouts.PodResourcesCacheTTL,
+		PodResTimeout:     cfgFile.Timeouts.PodResources,
+		KubeletTimeout:    cfgFile.Timeouts.Kubelet,
+		UdsIdleTimeout:    cfgFile.Timeouts.UdsIdle,
+		ShutdownTimeout:   cfgFile.Timeouts.Shutdown,
+		LogFile:           cfgFile.LogFile,
+		LogFileMaxSize:    cfgFile.LogFileMaxSize,
+		LogFileBackups:    cfgFile.LogFileBackups,
+		LogFileCompress:   cfgFile.LogFileCompress,
+		LogDedup:          cfgFile.LogDedup,
+		LogBackend:        cfgFile.LogBackend,
+		LogFormat:         cfgFile.LogFormat,
+		AuditFile:         cfgFile.AuditFile,
+		LogLevel:          cfgFile.LogLevel,
+		LogLevels:         cfgFile.LogLevels,
+		KindCluster:       cfgFile.KindCluster,
+		MetricsAddr:       cfgFile.MetricsAddr,
+		MetricsMaxSeries:  cfgFile.MetricsMaxSeries,
+		MetricsDropLabels: cfgFile.MetricsDropLabels,
+		HealthAddr:        cfgFile.HealthAddr,
+		PodResSock:        constants.PodResources.DefaultSocket,
+		ApiFallback:       cfgFile.ApiFallback,
+		Events:            cfgFile.Events,
+		RequirePeerCgroup: cfgFile.RequirePeerCgroup,
+		RequireToken:      cfgFile.RequireToken,
+		RequireChallenge:  cfgFile.RequireChallenge,
+		RequireSigned:     cfgFile.RequireSigned,
+		RevalidateFds:     cfgFile.RevalidateFds,
+		SingleUseFds:      cfgFile.SingleUseFds,
+		DropPrivileges:    cfgFile.DropPrivileges,
+		HandshakeUid:      cfgFile.HandshakeUid,
+		HandshakeGid:      cfgFile.HandshakeGid,
+		MinimalSyscalls:   cfgFile.MinimalSyscalls,
+		TracingEndpoint:   cfgFile.TracingEndpoint,
+		SelinuxLabel:      cfgFile.SelinuxLabel,
+		DetachXdp:         cfgFile.DetachXdp,
+		SkipPrereqs:       cfgFile.SkipPrereqs,
+		BpfHelper:         cfgFile.BpfHelper,
+		BpfPublicKey:      cfgFile.BpfPublicKey,
+		FeatureGates:      cfgFile.FeatureGates,
+		SocketDir:         runtimeDir(constants.Uds.SockDir, cfgFile.Directories.Sockets, constants.Uds.DirFileMode),
+		BpfPinDir:         runtimeDir(constants.Afxdp.BpfPinDir, cfgFile.Directories.BpfPins, constants.Afxdp.BpfPinDirMode),
+		LogDir:            runtimeDir(constants.Logging.Directory, cfgFile.Directories.Logs, constants.Logging.DirectoryPermissions),
 	}
 
+	if cfgFile.PodResSock != "" {
+		pluginConfig.PodResSock = cfgFile.PodResSock
+	}
+	// the handshake is never run as root, so 0 is taken as unset
+	if pluginConfig.HandshakeUid == 0 {
+		pluginConfig.HandshakeUid = constants.Uds.HandshakeUid
+	}
+	if pluginConfig.HandshakeGid == 0 {
+		pluginConfig.HandshakeGid = constants.Uds.HandshakeGid
+	}
+	if envSock, exists := os.LookupEnv(constants.PodResources.SocketEnvVar); exists && envSock != "" {
+		if !regexp.MustCompile(constants.PodResources.ValidSocketRegex).MatchString(envSock) {
+			return pluginConfig, errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("%s %s", constants.PodResources.SocketEnvVar, podResSocketValidError))
+		}
+		pluginConfig.PodResSock = envSock
+	}
+	if envEndpoint, exists := os.LookupEnv(constants.Tracing.EndpointEnvVar); exists && envEndpoint != "" {
+		if !regexp.MustCompile(constants.Tracing.ValidEndpointRegex).MatchString(envEndpoint) {
+			return pluginConfig, errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("%s %s", constants.Tracing.EndpointEnvVar, tracingEndpointValidError))
+		}
+		pluginConfig.TracingEndpoint = envEndpoint
+	}
+
+	level, err := logLevel(cfgFile.LogLevel)
+	if err != nil {
+		return pluginConfig, err
+	}
+	pluginConfig.LogLevel = level
+
 	return pluginConfig, nil
 }
 
+/*
+runtimeDir returns the runtime directory at path with the owner, group and mode of the config
+file, or the default mode if not set. The mode is validated as octal with the config file.
+*/
+func runtimeDir(path string, dir configFile_Dir, defaultMode int) RuntimeDir {
+	mode := os.FileMode(defaultMode)
+	if parsed, err := strconv.ParseUint(dir.Mode, 8, 32); dir.Mode != "" && err == nil {
+		mode = os.FileMode(parsed)
+	}
+
+	return RuntimeDir{Path: path, Owner: dir.Owner, Group: dir.Group, Mode: mode}
+}
+
+/*
+GetLogLevel rereads the config file and returns the log level and the subsystem log levels,
+allowing the log levels of a running device plugin to be changed. The pools are not reconfigured.
+*/
+func GetLogLevel(configFile string) (string, map[string]string, error) {
+	cfg, err := parseConfigFile(configFile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	level, err := logLevel(cfg.LogLevel)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return level, cfg.LogLevels, nil
+}
+
+/*
+logLevel returns the log level, the env var taking precedence over the level from the config file
+unless the level is set on the command line.
+*/
+func logLevel(cfgLevel string) (string, error) {
+	if _, ok := flagOverrides[envKey("logLevel")]; ok {
+		return cfgLevel, nil
+	}
+
+	envLevel, exists := os.LookupEnv(constants.Logging.LevelEnvVar)
+	if !exists || envLevel == "" {
+		return cfgLevel, nil
+	}
+
+	for _, level := range constants.Logging.Levels {
+		if envLevel == level {
+			return envLevel, nil
+		}
+	}
+
+	return "", errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("%s must be %v", constants.Logging.LevelEnvVar, constants.Logging.Levels))
+}
+
 /*
 GetPoolConfigs returns a slice of PoolConfig objects.
 Each object containing the config and device list for one pool.
@@ -125,7 +328,7 @@ func GetPoolConfigs(configFile string, net networking.Handler, host host.Handler
 		return poolConfigs, err
 	}
 
-	kindSecondaryNetwork, err := networking.CheckKindNetworkExists()
+	kindSecondaryNetwork, err := network.KindNetworkExists()
 	if err != nil {
 		logging.Errorf("Error checking if host has Kind secondary network: %v", err)
 	}
@@ -176,23 +379,67 @@ func GetPoolConfigs(configFile string, net networking.Handler, host host.Handler
 	for _, pool := range cfgFile.Pools {
 		logging.Infof("Processing Pool: %s", pool.Name)
 
+		// check if the pool is selected for this node by its labels
+		selected, err := nodeSelected(pool)
+		if err != nil {
+			logging.Errorf("Error checking node selector of pool %s: %v", pool.Name, err)
+			return poolConfigs, err
+		}
+		if !selected {
+			logging.Infof("Pool %s is not selected for this node by its node selector", pool.Name)
+			continue
+		}
+
 		// check if pool requires unprivileged BPF and if the host allows it
 		if pool.RequiresUnprivilegedBpf && !unprivBpfAllowed {
 			logging.Warningf("Pool %s requires unprivileged BPF which is not allowed on this node", pool.Name)
 			continue
 		}
 
+		// check if pool loads BPF programs and the device plugin has the capabilities to
+		if !pool.UdsServerDisable && !featureEnabled(privileges.Bpf) {
+			logging.Warningf("Pool %s loads BPF programs for its UDS server, which the device plugin lacks the capabilities for", pool.Name)
+			continue
+		}
+
 		// uds timeout - user disabled, user did not set, user set
 		if pool.UdsTimeout == -1 {
 			pool.UdsTimeout = 0
 			logging.Debugf("UDS timeout is disabled: %d seconds", pool.UdsTimeout)
 		} else if pool.UdsTimeout == 0 {
-			pool.UdsTimeout = constants.Uds.MinTimeout
+			pool.UdsTimeout = cfgFile.Timeouts.withDefaults().UdsIdle
 			logging.Debugf("Using default UDS timeout: %d seconds", pool.UdsTimeout)
 		} else {
 			logging.Debugf("UDS timeout is set to: %d seconds", pool.UdsTimeout)
 		}
 
+		// umem frame size - user did not set, user set
+		if pool.UmemFrameSize == 0 {
+			pool.UmemFrameSize = constants.Afxdp.FrameSizeDefault
+			logging.Debugf("Using default UMEM frame size: %d bytes", pool.UmemFrameSize)
+		} else {
+			logging.Debugf("UMEM frame size is set to: %d bytes", pool.UmemFrameSize)
+		}
+
+		// host managed action - user did not set, user set
+		if pool.HostManaged == "" {
+			pool.HostManaged = constants.Devices.HostManagedDef
+			logging.Debugf("Using default host managed device action: %s", pool.HostManaged)
+		} else {
+			logging.Debugf("Host managed device action is set to: %s", pool.HostManaged)
+		}
+
+		// irq affinity - user did not set, pod CPUs, CPU list
+		var irqCpus []int
+		if pool.IrqAffinity != "" && pool.IrqAffinity != constants.IrqAffinity.Pod {
+			irqCpus, err = tools.ParseCpuList(pool.IrqAffinity)
+			if err != nil {
+				logging.Errorf("Pool %s has an invalid IRQ affinity: %v", pool.Name, err)
+				return poolConfigs, err
+			}
+			logging.Debugf("Queue IRQs will be pinned to CPUs %v", irqCpus)
+		}
+
 		// check if we have specific config for this node
 		for _, node := range pool.Nodes {
 			if node.Hostname == hostname {
@@ -239,8 +486,39 @@ func GetPoolConfigs(configFile string, net networking.Handler, host host.Handler
 			getSecondaryDevices will take these objects and process them
 			what is returned is a map of fully functional device objects from the networking package
 			our devices become "real" at this point
+			tap mode pools have no configured devices, their tap devices are created here instead
 		*/
-		devices := getSecondaryDevices(pool)
+		var devices map[string]*networking.Device
+		if pool.Mode == "tap" {
+			devices = getTapDevices(pool)
+		} else {
+			devices = getSecondaryDevices(pool)
+		}
+
+		if pool.BondPairs {
+			devices = pairBondedDevices(devices)
+		}
+
+		udsProfile := pool.UdsProfile
+		if udsProfile == "" {
+			udsProfile = constants.Uds.ProfileCndp
+		}
+		if udsProfile == constants.Uds.ProfileDpdk && (cfgFile.RequireToken || cfgFile.RequireChallenge || cfgFile.RequireSigned) {
+			logging.Warningf("Pool %s uses the DPDK UDS profile, its pods are not required to send a handshake token, challenge or signature", pool.Name)
+		}
+
+		var udsFaults *udsserver.Faults
+		if pool.UdsFaults != nil {
+			logging.Warningf("Pool %s injects faults into its UDS responses, for testing only", pool.Name)
+			udsFaults = &udsserver.Faults{
+				Delay:        time.Duration(pool.UdsFaults.Delay) * time.Millisecond,
+				DelayRate:    pool.UdsFaults.DelayRate,
+				NakRate:      pool.UdsFaults.NakRate,
+				DropRate:     pool.UdsFaults.DropRate,
+				TruncateRate: pool.UdsFaults.TruncateRate,
+				Seed:         pool.UdsFaults.Seed,
+			}
+		}
 
 		if len(devices) != 0 {
 			poolConfigs = append(poolConfigs, PoolConfig{
@@ -253,6 +531,16 @@ func GetPoolConfigs(configFile string, net networking.Handler, host host.Handler
 				RequiresUnprivilegedBpf: pool.RequiresUnprivilegedBpf,
 				UID:                     pool.UID,
 				EthtoolCmds:             pool.EthtoolCmds,
+				Promiscuous:             pool.Promiscuous,
+				UmemFrameSize:           pool.UmemFrameSize,
+				AdjustMtu:               pool.AdjustMtu,
+				IrqCpus:                 irqCpus,
+				IrqPodCpus:              pool.IrqAffinity == constants.IrqAffinity.Pod,
+				AllowedUids:             pool.AllowedUids,
+				AllowedGids:             pool.AllowedGids,
+				UdsProfile:              udsProfile,
+				UdsLegacyPath:           pool.UdsLegacyPath,
+				UdsFaults:               udsFaults,
 			})
 		}
 
@@ -261,6 +549,138 @@ func GetPoolConfigs(configFile string, net networking.Handler, host host.Handler
 	return poolConfigs, nil
 }
 
+/*
+GetDriverRequirements returns the drivers the pools selected for this node use, taking any
+specific config for this node, each with the minimum firmware version of its devices, empty if
+not set. If several pools use a driver, the highest minimum firmware version is returned.
+*/
+func GetDriverRequirements(configFile string, host host.Handler) (map[string]string, error) {
+	requirements := make(map[string]string)
+
+	if cfgFile == nil {
+		if err := readConfigFile(configFile); err != nil {
+			logging.Errorf("Error reading config file: %v", err)
+			return requirements, err
+		}
+	}
+
+	hostname, err := host.Hostname()
+	if err != nil {
+		logging.Errorf("Error getting node hostname: %v", err)
+		return requirements, err
+	}
+
+	for _, pool := range cfgFile.Pools {
+		selected, err := nodeSelected(pool)
+		if err != nil {
+			return requirements, err
+		}
+		if !selected {
+			continue
+		}
+
+		drivers := pool.Drivers
+		for _, node := range pool.Nodes {
+			if node.Hostname == hostname {
+				drivers = node.Drivers
+				break
+			}
+		}
+
+		for _, driver := range drivers {
+			minFirmware, ok := requirements[driver.Name]
+			if !ok || minFirmware == "" {
+				requirements[driver.Name] = driver.MinFirmware
+				continue
+			}
+			if driver.MinFirmware == "" {
+				continue
+			}
+			if newer, err := networking.FirmwareAtLeast(driver.MinFirmware, minFirmware); err == nil && newer {
+				requirements[driver.Name] = driver.MinFirmware
+			}
+		}
+	}
+
+	return requirements, nil
+}
+
+/*
+getTapDevices creates the tap devices of a tap mode pool.
+Tap devices are numbered across all pools so each pool is given its own devices.
+*/
+func getTapDevices(pool *configFile_Pool) map[string]*networking.Device {
+	tapDevices := make(map[string]*networking.Device)
+
+	for i := 0; i < pool.TapDevices; i++ {
+		name := constants.Tap.Prefix + strconv.Itoa(tapCount)
+		tapCount++
+
+		dev, err := network.CreateTap(name)
+		if err != nil {
+			logging.Errorf("Error creating tap device %s: %v", name, err)
+			continue
+		}
+		tapDevices[dev.Name()] = dev
+	}
+
+	return tapDevices
+}
+
+/*
+pairBondedDevices pairs pool devices that are the two ports of the same active-backup bond.
+The pair is advertised as a single logical device, named after the first port, with the
+second port set as its peer. Devices that are not bonded, or whose bond peer is not in the
+pool, are advertised unpaired.
+*/
+func pairBondedDevices(devices map[string]*networking.Device) map[string]*networking.Device {
+	paired := make(map[string]*networking.Device)
+	peers := make(map[string]bool)
+
+	var names []string
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if peers[name] {
+			continue
+		}
+		device := devices[name]
+		paired[name] = device
+
+		bond, err := network.GetActiveBackupBond(name)
+		if err != nil {
+			logging.Warningf("Unable to get bond of device %s: %v", name, err)
+			continue
+		}
+		if bond == nil {
+			continue
+		}
+		if len(bond.Slaves) != 2 {
+			logging.Warningf("Bond %s has %d ports, only bonds of 2 ports are paired", bond.Name, len(bond.Slaves))
+			continue
+		}
+
+		peer := bond.Slaves[0]
+		if peer == name {
+			peer = bond.Slaves[1]
+		}
+		if _, ok := devices[peer]; !ok || peers[peer] {
+			logging.Warningf("Device %s is bonded to %s which is not available in this pool, device will not be paired", name, peer)
+			continue
+		}
+
+		device.SetPeer(peer)
+		peers[peer] = true
+		delete(paired, peer)
+		logging.Infof("Device %s paired with %s of bond %s", name, peer, bond.Name)
+	}
+
+	return paired
+}
+
 func getDeviceListOfDriverType(driver *configFile_Driver, pool *configFile_Pool) []*configFile_Device {
 	var devices []*configFile_Device
 	var counting bool
@@ -370,6 +790,23 @@ func validateDevice(device *networking.Device, driver *configFile_Driver, pool *
 			logging.Debugf("IPs on %s driver are excluded; Device %s has IP %s", driver.Name, device.Name(), ip)
 			return false
 		}
+
+		if driver.MinFirmware != "" {
+			firmware, err := device.Firmware()
+			if err != nil {
+				logging.Errorf("error obtaining firmware version of device %s: %v", device.Name(), err)
+				return false
+			}
+			ok, err := networking.FirmwareAtLeast(firmware, driver.MinFirmware)
+			if err != nil {
+				logging.Warningf("Unable to compare firmware version of device %s: %v", device.Name(), err)
+				return false
+			}
+			if !ok {
+				logging.Warningf("Device %s firmware %s is below the minimum %s for %s driver", device.Name(), firmware, driver.MinFirmware, driver.Name)
+				return false
+			}
+		}
 	}
 
 	if (device.Mode() != "") && (device.Mode() != pool.Mode) {
@@ -377,27 +814,109 @@ func validateDevice(device *networking.Device, driver *configFile_Driver, pool *
 		return false
 	}
 
+	if len(pool.PciIds) > 0 || len(pool.ExcludePciIds) > 0 {
+		pciId, err := network.GetDevicePciId(device.Name())
+		if err != nil {
+			logging.Errorf("error obtaining PCI ID of device %s: %v", device.Name(), err)
+			return false
+		}
+		if !pciIdAllowed(pciId, pool.PciIds, pool.ExcludePciIds) {
+			logging.Debugf("Device %s with PCI ID %s is not allowed in pool %s", device.Name(), pciId, pool.Name)
+			return false
+		}
+	}
+
+	return checkHostManaged(device.Name(), pool)
+}
+
+/*
+checkHostManaged checks whether a device is managed by NetworkManager or systemd-networkd, which may
+reconfigure it while it is allocated to a pod. Depending on the hostManaged action of the pool the
+device is included with a warning, marked unmanaged, or excluded from the pool.
+*/
+func checkHostManaged(deviceName string, pool *configFile_Pool) bool {
+	managers, err := network.GetHostNetworkManagers(deviceName)
+	if err != nil {
+		logging.Warningf("Unable to determine if device %s is managed by the host: %v", deviceName, err)
+		return true
+	}
+	if len(managers) == 0 {
+		return true
+	}
+
+	switch pool.HostManaged {
+	case "exclude":
+		logging.Infof("Device %s is managed by %v, excluding from pool %s", deviceName, managers, pool.Name)
+		return false
+	case "unmanage":
+		if err := network.SetUnmanaged(deviceName, managers); err != nil {
+			logging.Warningf("Unable to mark device %s unmanaged by %v, it may be reconfigured while allocated: %v", deviceName, managers, err)
+		}
+	default:
+		logging.Warningf("Device %s is managed by %v and may be reconfigured while allocated", deviceName, managers)
+	}
+
 	return true
 }
 
+/*
+pciIdAllowed checks a PCI vendor:device ID against the allow-list and deny-list of a pool.
+An empty allow-list allows all IDs. Devices without a PCI ID are only allowed when there is no allow-list.
+*/
+func pciIdAllowed(pciId string, allowed []string, excluded []string) bool {
+	pciId = strings.ToLower(pciId)
+
+	contains := func(ids []string) bool {
+		for _, id := range ids {
+			if strings.ToLower(id) == pciId {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(allowed) > 0 && !contains(allowed) {
+		return false
+	}
+
+	return !contains(excluded)
+}
+
 func readConfigFile(file string) error {
-	cfgFile = &configFile{}
+	var err error
+	cfgFile, err = parseConfigFile(file)
+	return err
+}
 
+func parseConfigFile(file string) (*configFile, error) {
 	logging.Infof("Reading config file: %s", file)
 	raw, err := ioutil.ReadFile(file)
 	if err != nil {
 		logging.Errorf("Error reading config file: %v", err)
-		return err
+		return &configFile{}, err
 	}
 
 	logging.Infof("Unmarshalling config data")
-	if err := json.Unmarshal(raw, &cfgFile); err != nil {
+	cfg, err := decodeConfigFile(raw)
+	if err != nil {
 		logging.Errorf("Error unmarshalling config data: %v", err)
-		return err
+		return cfg, errdefs.Wrap(errdefs.ErrValidationFailed, err)
+	}
+
+	applyProfilePools(cfg)
+
+	if err := applyEnvOverrides(cfg); err != nil {
+		logging.Errorf("Error overriding config data from env vars: %v", err)
+		return cfg, errdefs.Wrap(errdefs.ErrValidationFailed, err)
+	}
+
+	if err := resolveNodeTemplates(cfg); err != nil {
+		logging.Errorf("Error resolving config data for this node: %v", err)
+		return cfg, errdefs.Wrap(errdefs.ErrValidationFailed, err)
 	}
 
-	if cfgFile.LogLevel == "debug" {
-		pretty, err := tools.PrettyString(cfgFile)
+	if cfg.LogLevel == "debug" || cfg.LogLevel == "trace" {
+		pretty, err := tools.PrettyString(cfg)
 		if err != nil {
 			logging.Errorf("Error printing config data: %v", err)
 		} else {
@@ -406,11 +925,12 @@ func readConfigFile(file string) error {
 	}
 
 	logging.Infof("Validating config data")
-	if err := cfgFile.Validate(); err != nil {
+	if err := cfg.Validate(); err != nil {
+		err = fieldErrors(raw, err)
 		logging.Errorf("Config validation error: %v", err)
-		return err
+		return cfg, errdefs.Wrap(errdefs.ErrValidationFailed, err)
 	}
-	return nil
+	return cfg, nil
 }
 
 func getDeviceName(device *configFile_Device) string {
@@ -0,0 +1,290 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+decodeConfigFile decodes the raw config file. Config files of a supported version are decoded
+strictly, rejecting fields unknown to the version. Unversioned config files are migrated from
+the formats of earlier releases, with a deprecation warning for each, and decoded as they were
+before versioning, ignoring unknown fields. Errors give the line and column in the config
+file at which decoding failed. The config file is decoded onto the defaults of the selected
+profile, if any.
+*/
+func decodeConfigFile(raw []byte) (*configFile, error) {
+	cfg := profileConfig()
+
+	var header struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return cfg, positionError(raw, err)
+	}
+
+	if header.Version == "" {
+		migrated, warnings, err := migrateConfigFile(raw)
+		if err != nil {
+			return cfg, err
+		}
+		logDeprecations(warnings)
+		raw = migrated
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	for _, version := range constants.ConfigFile.Versions {
+		if header.Version == version {
+			decoder.DisallowUnknownFields()
+		}
+	}
+	if err := decoder.Decode(cfg); err != nil {
+		return cfg, positionError(raw, err)
+	}
+
+	return cfg, nil
+}
+
+/*
+positionError prefixes a decoding error with the line and column in raw at which it occurred,
+where known.
+*/
+func positionError(raw []byte, err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	unknownField := "json: unknown field "
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("%s: %w", position(raw, syntaxErr.Offset-1), err)
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("%s: %w", position(raw, typeErr.Offset-1), err)
+	case strings.HasPrefix(err.Error(), unknownField):
+		field := strings.TrimPrefix(err.Error(), unknownField)
+		if index := bytes.Index(raw, []byte(field)); index >= 0 {
+			return fmt.Errorf("%s: %w", position(raw, int64(index)+1), err)
+		}
+	}
+
+	return err
+}
+
+/*
+position returns the line and column of the byte at index in raw, counted from 1.
+*/
+func position(raw []byte, index int64) string {
+	if index < 0 {
+		index = 0
+	}
+	if index > int64(len(raw)) {
+		index = int64(len(raw))
+	}
+	before := raw[:index]
+
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(index) - bytes.LastIndexByte(before, '\n')
+
+	return fmt.Sprintf("line %d, column %d", line, column)
+}
+
+/*
+fieldPosition is the name of a field as written in the config file, e.g. pools[0].mode, and
+the line it is on.
+*/
+type fieldPosition struct {
+	name string
+	line int
+}
+
+/*
+fieldErrors lists each error of a failed validation on its own, with the name of the field it is
+about and the line of the field in the config file, ordered by line. Fields missing from the
+config file are given the line of the closest enclosing field.
+*/
+func fieldErrors(raw []byte, err error) error {
+	errs, ok := err.(validation.Errors)
+	if !ok {
+		return err
+	}
+
+	type fieldError struct {
+		fieldPosition
+		err error
+	}
+	var fields []fieldError
+	positions := fieldPositions(raw)
+	flattenErrors("", errs, func(path string, err error) {
+		fields = append(fields, fieldError{fieldPosition: locate(positions, path), err: err})
+	})
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].line != fields[j].line {
+			return fields[i].line < fields[j].line
+		}
+		return fields[i].name < fields[j].name
+	})
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		if field.line > 0 {
+			messages[i] = fmt.Sprintf("line %d: %s: %v", field.line, field.name, field.err)
+		} else {
+			messages[i] = fmt.Sprintf("%s: %v", field.name, field.err)
+		}
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
+
+/*
+flattenErrors calls add with each error nested in err and its path, the keys of the enclosing
+validation errors joined by dots, e.g. Pools.0.Mode.
+*/
+func flattenErrors(path string, err error, add func(string, error)) {
+	errs, ok := err.(validation.Errors)
+	if !ok {
+		add(path, err)
+		return
+	}
+
+	for key, err := range errs {
+		flattenErrors(joinPath(path, key), err, add)
+	}
+}
+
+/*
+fieldPositions returns the position of each field and array element in the config file, keyed
+on its lower case path, e.g. pools.0.mode. Field names are matched case insensitively, as they
+are when decoding the config file.
+*/
+func fieldPositions(raw []byte) map[string]fieldPosition {
+	type container struct {
+		path    string
+		name    string
+		object  bool
+		key     string // path of the current field of an object
+		keyName string // name of the current field of an object
+		index   int    // index of the next element of an array
+	}
+
+	positions := make(map[string]fieldPosition)
+	var stack []*container
+	expectKey := false
+
+	if header.Version == "" {
+		migrated, warnings, err := migrateConfigFile(raw)
+		if err != nil {
+			return cfg, err
+		}
+		logDeprecations(warnings)
+		raw = migrated
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return positions
+		}
+		line := bytes.Count(raw[:decoder.InputOffset()], []byte("\n")) + 1
+
+		if delim, ok := token.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			expectKey = len(stack) > 0 && stack[len(stack)-1].object
+			continue
+		}
+
+		var top *container
+		if len(stack) > 0 {
+			top = stack[len(stack)-1]
+		}
+
+		if top != nil && top.object && expectKey {
+			key, _ := token.(string)
+			top.key = joinPath(top.path, strings.ToLower(key))
+			top.keyName = joinPath(top.name, key)
+			positions[top.key] = fieldPosition{name: top.keyName, line: line}
+			expectKey = false
+			continue
+		}
+
+		var path, name string
+		if top != nil && top.object {
+			path, name = top.key, top.keyName
+		} else if top != nil {
+			path, name = joinPath(top.path, strconv.Itoa(top.index)), fmt.Sprintf("%s[%d]", top.name, top.index)
+			positions[path] = fieldPosition{name: name, line: line}
+			top.index++
+		}
+
+		if delim, ok := token.(json.Delim); ok {
+			stack = append(stack, &container{path: path, name: name, object: delim == '{'})
+			expectKey = delim == '{'
+			continue
+		}
+		expectKey = top != nil && top.object
+	}
+}
+
+/*
+locate returns the position of the field at the validation error path, or of the closest
+enclosing field in the config file if the field is missing from it.
+*/
+func locate(positions map[string]fieldPosition, path string) fieldPosition {
+	segments := strings.Split(path, ".")
+	for i := len(segments); i > 0; i-- {
+		if pos, ok := positions[strings.ToLower(strings.Join(segments[:i], "."))]; ok {
+			return fieldPosition{name: fieldName(pos.name, segments[i:]), line: pos.line}
+		}
+	}
+
+	return fieldPosition{name: fieldName("", segments)}
+}
+
+/*
+fieldName appends the segments of a validation error path to name, indexing arrays and
+lower casing the first letter of fields as in the README.
+*/
+func fieldName(name string, segments []string) string {
+	for _, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			name += "[" + segment + "]"
+			continue
+		}
+		if segment != "" {
+			segment = strings.ToLower(segment[:1]) + segment[1:]
+		}
+		name = joinPath(name, segment)
+	}
+
+	return name
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"errors"
+	"testing"
+
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeConfigFile(t *testing.T) {
+	testCases := []struct {
+		name   string
+		raw    string
+		expErr string
+	}{
+		{
+			name: "syntax error",
+			raw: `{
+  "pools":[
+    {"name":"testPool",}
+  ]
+}`,
+			expErr: "line 3, column 24: invalid character '}' looking for beginning of object key string",
+		},
+		{
+			name: "wrong type",
+			raw: `{
+  "logFileMaxSize":"big"
+}`,
+			expErr: "line 2, column 24: json: cannot unmarshal string into Go struct field",
+		},
+		{
+			name: "unknown field",
+			raw: `{
+  "version":"v1",
+  "logFil":"afxdp.log"
+}`,
+			expErr: `line 3, column 4: json: unknown field "logFil"`,
+		},
+		{
+			name: "unknown field unversioned",
+			raw: `{
+  "logFil":"afxdp.log"
+}`,
+		},
+		{
+			name: "unknown field unsupported version",
+			raw: `{
+  "version":"v0",
+  "logFil":"afxdp.log"
+}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeConfigFile([]byte(tc.raw))
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+				return
+			}
+			require.Error(t, err, "Error was expected")
+			assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	raw := `{
+  "logFile":"afxdp",
+  "Pools":[
+    {
+      "name":"pool1",
+      "mode":"primary"
+    },
+    {
+      "name":"pool2",
+      "drivers":[
+        {"name":"i40e", "primary":20}
+      ]
+    }
+  ]
+}`
+	err := validation.Errors{
+		"LogFile":    errors.New("must be a valid .log or .txt filename"),
+		"LogBackend": errors.New("Log backend must be one of [stdout syslog]"),
+		"Pools": validation.Errors{
+			"1": validation.Errors{
+				"Mode": errors.New("Plugin must have a mode"),
+				"Drivers": validation.Errors{
+					"0": validation.Errors{
+						"Primary": errors.New("Driver primary must be between 1 and 10"),
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, "logBackend: Log backend must be one of [stdout syslog]; "+
+		"line 2: logFile: must be a valid .log or .txt filename; "+
+		"line 8: Pools[1].mode: Plugin must have a mode; "+
+		"line 11: Pools[1].drivers[0].primary: Driver primary must be between 1 and 10",
+		fieldErrors([]byte(raw), err).Error(), "Unexpected error")
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+flagOverrides are the values of config fields set on the command line, keyed as matched by envKey.
+*/
+var flagOverrides = make(map[string]string)
+
+/*
+SetOverrides overrides fields of the config file, named as in the config file, e.g. logLevel,
+with values set on the command line. They take precedence over the config file and env vars,
+and are validated as the config file is each time it is read.
+*/
+func SetOverrides(fields map[string]string) error {
+	known := envOverrides(&configFile{})
+	for field, value := range fields {
+		if _, ok := known[envKey(field)]; !ok {
+			return errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("%s: %s", field, envUnknownFieldError))
+		}
+		flagOverrides[envKey(field)] = value
+	}
+
+	return nil
+}
+
+/*
+applyEnvOverrides overrides fields of the config file with env vars named after them, prefixed
+with constants.ConfigFile.EnvVarPrefix, e.g. AFXDP_DP_LOG_LEVEL for logLevel and
+AFXDP_DP_TIMEOUTS_API_SERVER for timeouts.apiServer. Names are matched ignoring case and
+underscores, so AFXDP_DP_LOGLEVEL also overrides logLevel. Env vars named after a pool field,
+e.g. AFXDP_DP_UDS_TIMEOUT, override that field in every pool. String fields take the value as
+is, other fields take it as JSON, e.g. 30, true or ["a","b"]. Unknown env vars are rejected in
+a versioned config file and ignored with a warning otherwise, as unknown fields are. Fields set
+with SetOverrides are then overridden.
+*/
+func applyEnvOverrides(cfg *configFile) error {
+	overrides := envOverrides(cfg)
+
+	var names []string
+	for _, env := range os.Environ() {
+		name := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(name, constants.ConfigFile.EnvVarPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		override, ok := overrides[envKey(strings.TrimPrefix(name, constants.ConfigFile.EnvVarPrefix))]
+		if !ok {
+			if versioned(cfg) {
+				return fmt.Errorf("%s: %s", name, envUnknownFieldError)
+			}
+			logging.Warningf("Ignoring env var %s: %s", name, envUnknownFieldError)
+			continue
+		}
+
+		logging.Infof("Overriding config file with env var %s", name)
+		if err := override(os.Getenv(name)); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	var keys []string
+	for key := range flagOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := overrides[key](flagOverrides[key]); err != nil {
+			return fmt.Errorf("command line: %v", err)
+		}
+	}
+
+	return nil
+}
+
+/*
+envOverrides returns functions overriding each field of cfg with a value, keyed on the field
+path as matched by envKey. Pool fields are overridden in every pool.
+*/
+func envOverrides(cfg *configFile) map[string]func(string) error {
+	overrides := make(map[string]func(string) error)
+
+	var addFields func(prefix string, value reflect.Value)
+	addFields = func(prefix string, value reflect.Value) {
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Field(i)
+			key := prefix + envKey(strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0])
+
+			switch {
+			case field.Type() == reflect.TypeOf(cfg.Pools):
+				continue
+			case field.Kind() == reflect.Struct:
+				addFields(key, field)
+			default:
+				overrides[key] = func(v string) error { return setEnvValue(field, v) }
+			}
+		}
+	}
+	addFields("", reflect.ValueOf(cfg).Elem())
+
+	poolType := reflect.TypeOf(configFile_Pool{})
+	for i := 0; i < poolType.NumField(); i++ {
+		index := i
+		key := envKey(strings.Split(poolType.Field(i).Tag.Get("json"), ",")[0])
+		overrides[key] = func(v string) error {
+			for _, pool := range cfg.Pools {
+				if err := setEnvValue(reflect.ValueOf(pool).Elem().Field(index), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	return overrides
+}
+
+/*
+setEnvValue sets field to value, as is for a string field and decoded as JSON otherwise.
+*/
+func setEnvValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+
+	decoded := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(value), decoded.Interface()); err != nil {
+		return fmt.Errorf("%s: %v", envValueError, err)
+	}
+	field.Set(decoded.Elem())
+
+	return nil
+}
+
+/*
+envKey normalises an env var name or field name for matching, ignoring case and underscores.
+*/
+func envKey(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "_", ""))
+}
+
+/*
+versioned returns true if the config file is of a supported version, and so strictly validated.
+*/
+func versioned(cfg *configFile) bool {
+	for _, version := range constants.ConfigFile.Versions {
+		if cfg.Version == version {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEnvOverrides(t *testing.T) {
+	testCases := []struct {
+		name       string
+		configFile string
+		env        map[string]string
+		flags      map[string]string
+		check      func(t *testing.T, cfg *configFile)
+		expErr     bool
+	}{
+		{
+			name:       "no env vars",
+			configFile: `{"logLevel":"info"}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "info", cfg.LogLevel, "Unexpected log level")
+			},
+		},
+		{
+			name:       "string field",
+			configFile: `{"logLevel":"info"}`,
+			env:        map[string]string{"AFXDP_DP_LOG_LEVEL": "debug"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "debug", cfg.LogLevel, "Unexpected log level")
+			},
+		},
+		{
+			name:       "name without underscores",
+			configFile: `{"logLevel":"info"}`,
+			env:        map[string]string{"AFXDP_DP_LOGLEVEL": "warning"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "warning", cfg.LogLevel, "Unexpected log level")
+			},
+		},
+		{
+			name:       "int and bool fields",
+			configFile: `{}`,
+			env:        map[string]string{"AFXDP_DP_LOG_FILE_MAX_SIZE": "10", "AFXDP_DP_KIND_CLUSTER": "true"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, 10, cfg.LogFileMaxSize, "Unexpected log file max size")
+				assert.True(t, cfg.KindCluster, "Unexpected kind cluster")
+			},
+		},
+		{
+			name:       "nested field",
+			configFile: `{"timeouts":{"podCheckInterval":10,"apiServer":20}}`,
+			env:        map[string]string{"AFXDP_DP_TIMEOUTS_POD_CHECK_INTERVAL": "30"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, 30, cfg.Timeouts.PodCheckInterval, "Unexpected pod check interval")
+				assert.Equal(t, 20, cfg.Timeouts.ApiServer, "Unexpected API server timeout")
+			},
+		},
+		{
+			name:       "map and list fields replaced",
+			configFile: `{"logLevels":{"bpf":"info"},"metricsDropLabels":["device"]}`,
+			env:        map[string]string{"AFXDP_DP_LOG_LEVELS": `{"udsserver":"debug"}`, "AFXDP_DP_METRICS_DROP_LABELS": `["pod"]`},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, map[string]string{"udsserver": "debug"}, cfg.LogLevels, "Unexpected log levels")
+				assert.Equal(t, []string{"pod"}, cfg.MetricsDropLabels, "Unexpected metrics drop labels")
+			},
+		},
+		{
+			name:       "pool field overridden in every pool",
+			configFile: `{"pools":[{"name":"pool1","udsTimeout":30},{"name":"pool2"}]}`,
+			env:        map[string]string{"AFXDP_DP_UDS_TIMEOUT": "60"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, 60, cfg.Pools[0].UdsTimeout, "Unexpected UDS timeout of pool1")
+				assert.Equal(t, 60, cfg.Pools[1].UdsTimeout, "Unexpected UDS timeout of pool2")
+				assert.Equal(t, "pool1", cfg.Pools[0].Name, "Unexpected pool name")
+			},
+		},
+		{
+			name:       "command line takes precedence over env var",
+			configFile: `{"logLevel":"info","metricsAddr":":9100"}`,
+			env:        map[string]string{"AFXDP_DP_LOG_LEVEL": "debug"},
+			flags:      map[string]string{"logLevel": "error", "metricsAddr": "localhost:9200"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "error", cfg.LogLevel, "Unexpected log level")
+				assert.Equal(t, "localhost:9200", cfg.MetricsAddr, "Unexpected metrics address")
+			},
+		},
+		{
+			name:       "unknown command line field",
+			configFile: `{"logLevel":"info"}`,
+			flags:      map[string]string{"logLevle": "debug"},
+			expErr:     true,
+		},
+		{
+			name:       "invalid value",
+			configFile: `{"pools":[{"name":"pool1"}]}`,
+			env:        map[string]string{"AFXDP_DP_UDS_TIMEOUT": "sixty"},
+			expErr:     true,
+		},
+		{
+			name:       "unknown env var ignored when unversioned",
+			configFile: `{"logLevel":"info"}`,
+			env:        map[string]string{"AFXDP_DP_LOG_LEVLE": "debug"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "info", cfg.LogLevel, "Unexpected log level")
+			},
+		},
+		{
+			name:       "unknown env var rejected when versioned",
+			configFile: `{"version":"v1","logLevel":"info"}`,
+			env:        map[string]string{"AFXDP_DP_LOG_LEVLE": "debug"},
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &configFile{}
+			require.NoError(t, json.Unmarshal([]byte(tc.configFile), cfg), "Can't decode config file")
+
+			for name, value := range tc.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			defer func() { flagOverrides = make(map[string]string) }()
+			err := SetOverrides(tc.flags)
+			if err == nil {
+				err = applyEnvOverrides(cfg)
+			}
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			tc.check(t, cfg)
+		})
+	}
+}
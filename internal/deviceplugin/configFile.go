@@ -17,12 +17,14 @@
 package deviceplugin
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	"github.com/go-ozzo/ozzo-validation/v4/is"
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/featuregates"
 )
 
 const (
@@ -40,6 +42,7 @@ const (
 	driverNameLengthError = "Driver name must be between 1 and 50 characters"
 	driverMustHaveIdError = "Driver must have a name"
 	driverPrimaryError    = "Number of primary devices must be between 1 and 100"
+	driverFirmwareError   = "Minimum firmware version must be a dot separated numeric version, e.g. 8.30"
 
 	// node errors
 	nodeValidHostError    = "Node hostname must be a valid Linux hostname"
@@ -57,9 +60,73 @@ const (
 	poolModeMustBeError   = "Plugin mode must be one of "
 	poolEthtoolNotEmpty   = "Ethtool commands cannot be empty"
 	poolEthtoolCharacters = "Ethtool commands must be alphanumeric or contain only approved charaters"
+	poolFrameSizeError    = "UMEM frame size must be one of "
+	poolBondPairsError    = "Bond pairs are only supported in primary mode"
+	poolTapDevicesError   = "Number of tap devices must be between 1 and 32"
+	poolTapModeError      = "Tap devices are only supported in tap mode"
+	poolPciIdError        = "PCI IDs must be of the form vendor:device, e.g. 8086:158b"
+	poolHostManagedError  = "Host managed action must be one of "
+	poolIrqAffinityError  = "IRQ affinity must be \"pod\" or a CPU list, e.g. 2-5,8"
+	poolIrqAffinityUds    = "IRQ affinity \"pod\" requires the UDS server"
+	poolPeerIdError       = "Allowed UIDs and GIDs must be non-negative IDs"
+	poolPeerIdUds         = "Allowed UIDs and GIDs require the UDS server"
+	poolUdsProfileError   = "UDS profile must be one of "
+	poolUdsProfileUds     = "UDS profile \"dpdk\" requires the UDS server"
+	poolUdsFaultsUds      = "UDS fault injection requires the UDS server"
+	poolUdsLegacyPathUds  = "UDS legacy path requires the UDS server"
+
+	// fault injection errors
+	faultDelayError = "Fault delay must be between 0 and 60000 milliseconds"
+	faultRateError  = "Fault rates must be between 0 and 1"
 
 	// logging errors
-	filenameValidError = "must be a valid .log or .txt filename"
+	filenameValidError  = "must be a valid .log or .txt filename"
+	logFileMaxSizeError = "Log file max size must be -1, 0, or between 1 and 1024 MB"
+	logFileBackupsError = "Log file backups must be -1, 0, or between 1 and 20"
+	logDedupError       = "Log dedup interval must be -1, 0, or between 1 and 3600 seconds"
+	auditFileError      = "Audit file must differ from the log file"
+	logBackendError     = "Log backend must be one of "
+	logBackendFileError = "Log file cannot be set with the syslog backend"
+	logFormatError      = "Log format must be one of "
+
+	// config file errors
+	versionError         = "Config version must be one of "
+	timeoutError         = "Timeouts must be 0, or between 1 and 3600 seconds"
+	timeoutUdsIdleError  = "UDS idle timeout must be 0, or between 30 and 300 seconds"
+	timeoutOrderError    = "must not exceed the "
+	timeoutUdsOrderError = "must be less than the "
+	dirOwnerError        = "Directory owner and group must be a non-negative ID"
+	handshakeIdError     = "Handshake user and group must be a non-negative ID"
+	dirModeError         = "Directory mode must be an octal mode, e.g. 0750"
+	envUnknownFieldError = "not named after a config field"
+	envValueError        = "invalid value"
+	profileError         = "profile must be one of"
+	nodeSourceError      = "node metadata is not available without access to the API server"
+	nodeTemplateError    = "invalid node template"
+
+	// metrics errors
+	metricsAddrValidError  = "must be a valid listen address, host:port or :port"
+	metricsMaxSeriesError  = "Metrics max series must be 0, or between 100 and 1000000"
+	metricsDropLabelsError = "Metric labels that can be dropped are "
+
+	// health errors
+	healthAddrValidError   = "must be a valid listen address, host:port or :port"
+	healthAddrMetricsError = "must differ from the metrics address"
+
+	// pod resources errors
+	podResSocketValidError = "must be a valid absolute path to a .sock file"
+
+	// tracing errors
+	tracingEndpointValidError = "must be a valid http or https URL, e.g. http://otel-collector:4318"
+
+	// selinux errors
+	selinuxLabelValidError = "must be an SELinux context, e.g. system_u:object_r:container_file_t:s0"
+
+	// syscall errors
+	minimalSyscallsError = "cannot be set with minimalSyscalls"
+
+	// bpf signature errors
+	bpfPublicKeyValidError = "must be a valid absolute path, e.g. /etc/afxdp/bpf.pem"
 )
 
 type configFile_Device struct {
@@ -75,6 +142,7 @@ type configFile_Driver struct {
 	Secondary        int                  `json:"Secondary"`
 	ExcludeDevices   []*configFile_Device `json:"ExcludeDevices"`
 	ExcludeAddressed bool                 `json:"ExcludeAddressed"`
+	MinFirmware      string               `json:"MinFirmware"`
 }
 
 type configFile_Node struct {
@@ -83,6 +151,15 @@ type configFile_Node struct {
 	Devices  []*configFile_Device `json:"Devices"`
 }
 
+type configFile_Faults struct {
+	Delay        int     `json:"delay"`
+	DelayRate    float64 `json:"delayRate"`
+	NakRate      float64 `json:"nakRate"`
+	DropRate     float64 `json:"dropRate"`
+	TruncateRate float64 `json:"truncateRate"`
+	Seed         int64   `json:"seed"`
+}
+
 type configFile_Pool struct {
 	Name                    string               `json:"Name"`
 	Mode                    string               `json:"Mode"`
@@ -95,13 +172,85 @@ type configFile_Pool struct {
 	RequiresUnprivilegedBpf bool                 `json:"RequiresUnprivilegedBpf"`
 	UID                     int                  `json:"uid"`
 	EthtoolCmds             []string             `json:"ethtoolCmds"`
+	Promiscuous             bool                 `json:"promiscuous"`
+	UmemFrameSize           int                  `json:"umemFrameSize"`
+	AdjustMtu               bool                 `json:"adjustMtu"`
+	BondPairs               bool                 `json:"bondPairs"`
+	TapDevices              int                  `json:"tapDevices"`
+	PciIds                  []string             `json:"pciIds"`
+	ExcludePciIds           []string             `json:"excludePciIds"`
+	HostManaged             string               `json:"hostManaged"`
+	IrqAffinity             string               `json:"irqAffinity"`
+	AllowedUids             []int                `json:"allowedUids"`
+	AllowedGids             []int                `json:"allowedGids"`
+	UdsProfile              string               `json:"udsProfile"`
+	UdsLegacyPath           bool                 `json:"udsLegacyPath"`
+	UdsFaults               *configFile_Faults   `json:"udsFaults"`
+	NodeSelector            map[string]string    `json:"nodeSelector"`
+}
+
+type configFile_Timeouts struct {
+	PodCheckInterval     int `json:"podCheckInterval"`
+	ApiServer            int `json:"apiServer"`
+	PodResourcesCacheTTL int `json:"podResourcesCacheTTL"`
+	PodResources         int `json:"podResources"`
+	Kubelet              int `json:"kubelet"`
+	UdsIdle              int `json:"udsIdle"`
+	Shutdown             int `json:"shutdown"`
+}
+
+type configFile_Dir struct {
+	Owner int    `json:"owner"`
+	Group int    `json:"group"`
+	Mode  string `json:"mode"`
+}
+
+type configFile_Dirs struct {
+	Sockets configFile_Dir `json:"sockets"`
+	BpfPins configFile_Dir `json:"bpfPins"`
+	Logs    configFile_Dir `json:"logs"`
 }
 
 type configFile struct {
-	Pools       []*configFile_Pool `json:"Pools"`
-	LogFile     string             `json:"LogFile"`
-	LogLevel    string             `json:"LogLevel"`
-	KindCluster bool               `json:"kindCluster"`
+	Version           string              `json:"version"`
+	Timeouts          configFile_Timeouts `json:"timeouts"`
+	Directories       configFile_Dirs     `json:"directories"`
+	Pools             []*configFile_Pool  `json:"Pools"`
+	LogFile           string              `json:"LogFile"`
+	LogFileMaxSize    int                 `json:"logFileMaxSize"`
+	LogFileBackups    int                 `json:"logFileBackups"`
+	LogFileCompress   bool                `json:"logFileCompress"`
+	LogDedup          int                 `json:"logDedupInterval"`
+	LogBackend        string              `json:"logBackend"`
+	LogFormat         string              `json:"logFormat"`
+	AuditFile         string              `json:"auditFile"`
+	LogLevel          string              `json:"LogLevel"`
+	LogLevels         map[string]string   `json:"logLevels"`
+	KindCluster       bool                `json:"kindCluster"`
+	MetricsAddr       string              `json:"metricsAddr"`
+	MetricsMaxSeries  int                 `json:"metricsMaxSeries"`
+	MetricsDropLabels []string            `json:"metricsDropLabels"`
+	HealthAddr        string              `json:"healthAddr"`
+	PodResSock        string              `json:"podResourcesSocket"`
+	ApiFallback       bool                `json:"apiServerFallback"`
+	Events            bool                `json:"kubernetesEvents"`
+	RequirePeerCgroup bool                `json:"requirePeerCgroup"`
+	RequireToken      bool                `json:"requireHandshakeToken"`
+	RequireChallenge  bool                `json:"requireHandshakeChallenge"`
+	RequireSigned     bool                `json:"requireSignedMessages"`
+	RevalidateFds     bool                `json:"revalidateFdRequests"`
+	SingleUseFds      bool                `json:"singleUseFds"`
+	DropPrivileges    bool                `json:"dropHandshakePrivileges"`
+	HandshakeUid      int                 `json:"handshakeUid"`
+	HandshakeGid      int                 `json:"handshakeGid"`
+	MinimalSyscalls   bool                `json:"minimalSyscalls"`
+	TracingEndpoint   string              `json:"tracingEndpoint"`
+	SelinuxLabel      string              `json:"selinuxLabel"`
+	DetachXdp         bool                `json:"detachXdpOnShutdown"`
+	SkipPrereqs       bool                `json:"skipPrerequisites"`
+	BpfHelper         string              `json:"bpfHelperSocket"`
+	BpfPublicKey      string              `json:"bpfPublicKey"`
+	FeatureGates      map[string]bool     `json:"featureGates"`
 }
 
 func (c configFile_Device) Validate() error {
@@ -163,6 +312,10 @@ func (c configFile_Driver) Validate() error {
 		validation.Field(
 			&c.ExcludeDevices,
 		),
+		validation.Field(
+			&c.MinFirmware,
+			validation.Match(regexp.MustCompile(constants.Drivers.ValidFirmwareRegex)).Error(driverFirmwareError),
+		),
 	)
 }
 
@@ -187,10 +340,22 @@ func (c configFile_Node) Validate() error {
 
 func (c configFile_Pool) Validate() error {
 	var iModes []interface{} = make([]interface{}, len(constants.Plugins.Modes))
+	var iFrameSizes []interface{} = make([]interface{}, len(constants.Afxdp.FrameSizes))
+	var iHostManaged []interface{} = make([]interface{}, len(constants.Devices.HostManaged))
+	var iUdsProfiles []interface{} = make([]interface{}, len(constants.Uds.Profiles))
 
 	for i, mode := range constants.Plugins.Modes {
 		iModes[i] = mode
 	}
+	for i, frameSize := range constants.Afxdp.FrameSizes {
+		iFrameSizes[i] = frameSize
+	}
+	for i, action := range constants.Devices.HostManaged {
+		iHostManaged[i] = action
+	}
+	for i, profile := range constants.Uds.Profiles {
+		iUdsProfiles[i] = profile
+	}
 
 	return validation.ValidateStruct(&c,
 		validation.Field(
@@ -206,15 +371,15 @@ func (c configFile_Pool) Validate() error {
 		),
 		validation.Field(
 			&c.Drivers,
-			validation.Required.When(len(c.Devices) == 0 && len(c.Nodes) == 0).Error(poolMustHaveDevsError),
+			validation.Required.When(len(c.Devices) == 0 && len(c.Nodes) == 0 && c.Mode != "tap").Error(poolMustHaveDevsError),
 		),
 		validation.Field(
 			&c.Devices,
-			validation.Required.When(len(c.Drivers) == 0 && len(c.Nodes) == 0).Error(poolMustHaveDevsError),
+			validation.Required.When(len(c.Drivers) == 0 && len(c.Nodes) == 0 && c.Mode != "tap").Error(poolMustHaveDevsError),
 		),
 		validation.Field(
 			&c.Nodes,
-			validation.Required.When(len(c.Drivers) == 0 && len(c.Devices) == 0).Error(poolMustHaveDevsError),
+			validation.Required.When(len(c.Drivers) == 0 && len(c.Devices) == 0 && c.Mode != "tap").Error(poolMustHaveDevsError),
 		),
 		validation.Field(
 			&c.UdsTimeout,
@@ -229,6 +394,47 @@ func (c configFile_Pool) Validate() error {
 			validation.When(!(c.UID == 0), validation.Max(constants.UID.Maximum)),
 			validation.When(!(c.UID == 0), validation.Min(constants.UID.Minimum)),
 		),
+		validation.Field(
+			&c.UmemFrameSize,
+			validation.When(c.UmemFrameSize != 0, validation.In(iFrameSizes...).Error(poolFrameSizeError+fmt.Sprintf("%v", iFrameSizes))),
+		),
+		validation.Field(
+			&c.TapDevices,
+			validation.When(
+				c.Mode == "tap",
+				validation.Required.Error(poolTapDevicesError),
+				validation.Min(constants.Tap.DevicesMin).Error(poolTapDevicesError),
+				validation.Max(constants.Tap.DevicesMax).Error(poolTapDevicesError),
+			),
+			validation.When(c.Mode != "tap", validation.Empty.Error(poolTapModeError)),
+		),
+		validation.Field(
+			&c.PciIds,
+			validation.Each(validation.Match(regexp.MustCompile(constants.Devices.ValidPciIdRegex)).Error(poolPciIdError)),
+		),
+		validation.Field(
+			&c.ExcludePciIds,
+			validation.Each(validation.Match(regexp.MustCompile(constants.Devices.ValidPciIdRegex)).Error(poolPciIdError)),
+		),
+		validation.Field(
+			&c.HostManaged,
+			validation.In(iHostManaged...).Error(poolHostManagedError+fmt.Sprintf("%v", iHostManaged)),
+		),
+		validation.Field(
+			&c.IrqAffinity,
+			validation.When(
+				c.IrqAffinity != constants.IrqAffinity.Pod,
+				validation.Match(regexp.MustCompile(constants.IrqAffinity.ValidCpuListRegex)).Error(poolIrqAffinityError),
+			),
+			validation.When(
+				c.IrqAffinity == constants.IrqAffinity.Pod && c.UdsServerDisable,
+				validation.In("").Error(poolIrqAffinityUds),
+			),
+		),
+		validation.Field(
+			&c.BondPairs,
+			validation.When(c.Mode != "primary", validation.Empty.Error(poolBondPairsError)),
+		),
 		validation.Field(
 			&c.EthtoolCmds,
 			validation.Each(
@@ -236,6 +442,184 @@ func (c configFile_Pool) Validate() error {
 				validation.Match(regexp.MustCompile(constants.EthtoolFilter.EthtoolFilterRegex)).Error(poolEthtoolCharacters),
 			),
 		),
+		validation.Field(
+			&c.AllowedUids,
+			validation.Each(validation.Min(0).Error(poolPeerIdError)),
+			validation.When(c.UdsServerDisable, validation.Empty.Error(poolPeerIdUds)),
+		),
+		validation.Field(
+			&c.AllowedGids,
+			validation.Each(validation.Min(0).Error(poolPeerIdError)),
+			validation.When(c.UdsServerDisable, validation.Empty.Error(poolPeerIdUds)),
+		),
+		validation.Field(
+			&c.UdsProfile,
+			validation.In(iUdsProfiles...).Error(poolUdsProfileError+fmt.Sprintf("%v", iUdsProfiles)),
+			validation.When(
+				c.UdsProfile == constants.Uds.ProfileDpdk && c.UdsServerDisable,
+				validation.In("").Error(poolUdsProfileUds),
+			),
+		),
+		validation.Field(
+			&c.UdsLegacyPath,
+			validation.When(c.UdsServerDisable, validation.Empty.Error(poolUdsLegacyPathUds)),
+		),
+		validation.Field(
+			&c.UdsFaults,
+			validation.When(c.UdsServerDisable, validation.Nil.Error(poolUdsFaultsUds)),
+		),
+	)
+}
+
+func (c configFile_Faults) Validate() error {
+	rate := []validation.Rule{
+		validation.Min(0.0).Error(faultRateError),
+		validation.Max(1.0).Error(faultRateError),
+	}
+
+	return validation.ValidateStruct(&c,
+		validation.Field(
+			&c.Delay,
+			validation.Min(0).Error(faultDelayError),
+			validation.Max(constants.Uds.FaultDelayMax).Error(faultDelayError),
+		),
+		validation.Field(&c.DelayRate, rate...),
+		validation.Field(&c.NakRate, rate...),
+		validation.Field(&c.DropRate, rate...),
+		validation.Field(&c.TruncateRate, rate...),
+	)
+}
+
+func (c configFile_Timeouts) Validate() error {
+	timeout := []validation.Rule{
+		validation.Min(0).Error(timeoutError),
+		validation.Max(constants.ConfigFile.TimeoutMax).Error(timeoutError),
+	}
+
+	err := validation.ValidateStruct(&c,
+		validation.Field(&c.PodCheckInterval, timeout...),
+		validation.Field(&c.ApiServer, timeout...),
+		validation.Field(&c.PodResourcesCacheTTL, timeout...),
+		validation.Field(&c.PodResources, timeout...),
+		validation.Field(&c.Kubelet, timeout...),
+		validation.Field(
+			&c.UdsIdle,
+			validation.When(
+				c.UdsIdle != 0,
+				validation.Min(constants.Uds.MinTimeout).Error(timeoutUdsIdleError),
+				validation.Max(constants.Uds.MaxTimeout).Error(timeoutUdsIdleError),
+			),
+		),
+		validation.Field(&c.Shutdown, timeout...),
+	)
+	if err != nil {
+		return err
+	}
+
+	return c.withDefaults().validateOrder()
+}
+
+/*
+withDefaults returns the timeouts with those unset replaced by their defaults.
+*/
+func (c configFile_Timeouts) withDefaults() configFile_Timeouts {
+	set := func(value *int, def int) {
+		if *value == 0 {
+			*value = def
+		}
+	}
+	set(&c.PodCheckInterval, constants.Uds.PodCheckInterval)
+	set(&c.ApiServer, constants.ApiServer.Timeout)
+	set(&c.PodResourcesCacheTTL, constants.PodResources.CacheTTL)
+	set(&c.PodResources, constants.PodResources.Timeout)
+	set(&c.Kubelet, constants.Plugins.DevicePlugin.KubeletTimeout)
+	set(&c.UdsIdle, constants.Uds.MinTimeout)
+	set(&c.Shutdown, constants.Plugins.DevicePlugin.ShutdownTimeout)
+
+	return c
+}
+
+/*
+validatePoolUdsTimeout checks the UDS timeout set for a pool leaves time for the calls made during
+a UDS handshake, as the timeouts section does for the UDS idle timeout.
+*/
+func (c configFile) validatePoolUdsTimeout(value interface{}) error {
+	pool, ok := value.(*configFile_Pool)
+	if !ok || pool == nil || pool.UdsTimeout <= 0 {
+		return nil
+	}
+
+	timeouts := c.Timeouts.withDefaults()
+	if pool.UdsTimeout <= timeouts.PodResources || pool.UdsTimeout <= timeouts.ApiServer {
+		return validation.Errors{
+			"UdsTimeout": fmt.Errorf("must be greater than the pod resources and API server timeouts, %ds and %ds", timeouts.PodResources, timeouts.ApiServer),
+		}
+	}
+
+	return nil
+}
+
+/*
+validatePoolPeerIds checks a pool restricting the UIDs or GIDs of the processes connecting to
+its UDS does not run with minimal syscalls, which leaves the credentials of the connected
+process unknown.
+*/
+func (c configFile) validatePoolPeerIds(value interface{}) error {
+	pool, ok := value.(*configFile_Pool)
+	if !ok || pool == nil || !c.MinimalSyscalls {
+		return nil
+	}
+
+	if len(pool.AllowedUids) != 0 {
+		return validation.Errors{"AllowedUids": errors.New(minimalSyscallsError)}
+	}
+	if len(pool.AllowedGids) != 0 {
+		return validation.Errors{"AllowedGids": errors.New(minimalSyscallsError)}
+	}
+
+	return nil
+}
+
+/*
+validateOrder checks the timeouts, with defaults in place of those unset, are ordered relative
+to each other. Pod resources are not cached for longer than the interval at which connected pods
+are checked, calls made during a UDS handshake finish before the UDS connection is idle for long
+enough to be closed, and calls in progress at shutdown are given as long as they can take.
+*/
+func (c configFile_Timeouts) validateOrder() error {
+	errs := validation.Errors{}
+
+	if c.PodResourcesCacheTTL > c.PodCheckInterval {
+		errs["podResourcesCacheTTL"] = fmt.Errorf("%spod check interval, %ds", timeoutOrderError, c.PodCheckInterval)
+	}
+	if c.PodResources >= c.UdsIdle {
+		errs["podResources"] = fmt.Errorf("%sUDS idle timeout, %ds", timeoutUdsOrderError, c.UdsIdle)
+	} else if c.PodResources > c.Shutdown {
+		errs["podResources"] = fmt.Errorf("%sshutdown timeout, %ds", timeoutOrderError, c.Shutdown)
+	}
+	if c.ApiServer >= c.UdsIdle {
+		errs["apiServer"] = fmt.Errorf("%sUDS idle timeout, %ds", timeoutUdsOrderError, c.UdsIdle)
+	}
+	if c.Kubelet > c.Shutdown {
+		errs["kubelet"] = fmt.Errorf("%sshutdown timeout, %ds", timeoutOrderError, c.Shutdown)
+	}
+
+	return errs.Filter()
+}
+
+func (c configFile_Dir) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Owner, validation.Min(0).Error(dirOwnerError)),
+		validation.Field(&c.Group, validation.Min(0).Error(dirOwnerError)),
+		validation.Field(&c.Mode, validation.Match(regexp.MustCompile(constants.ConfigFile.DirModeRegex)).Error(dirModeError)),
+	)
+}
+
+func (c configFile_Dirs) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.Sockets),
+		validation.Field(&c.BpfPins),
+		validation.Field(&c.Logs),
 	)
 }
 
@@ -246,22 +630,166 @@ func (c configFile) Validate() error {
 		iLogLevels[i] = logLevel
 	}
 
+	var iLogBackends []interface{} = make([]interface{}, len(constants.Logging.Backends))
+
+	for i, backend := range constants.Logging.Backends {
+		iLogBackends[i] = backend
+	}
+
+	var iLogFormats []interface{} = make([]interface{}, len(constants.Logging.Formats))
+
+	for i, format := range constants.Logging.Formats {
+		iLogFormats[i] = format
+	}
+
+	var iVersions []interface{} = make([]interface{}, len(constants.ConfigFile.Versions))
+
+	for i, version := range constants.ConfigFile.Versions {
+		iVersions[i] = version
+	}
+
+	var iDroppableLabels []interface{} = make([]interface{}, len(constants.Metrics.DroppableLabels))
+
+	for i, label := range constants.Metrics.DroppableLabels {
+		iDroppableLabels[i] = label
+	}
+
+	subsystemLevels := make([]*validation.KeyRules, len(constants.Logging.Subsystems))
+	for i, subsystem := range constants.Logging.Subsystems {
+		subsystemLevels[i] = validation.Key(subsystem, validation.In(iLogLevels...).Error("must be "+fmt.Sprintf("%v", iLogLevels))).Optional()
+	}
+
 	return validation.ValidateStruct(&c,
 
+		validation.Field(
+			&c.Version,
+			validation.In(iVersions...).Error(versionError+fmt.Sprintf("%v", constants.ConfigFile.Versions)),
+		),
+		validation.Field(
+			&c.Timeouts,
+		),
+		validation.Field(
+			&c.Directories,
+		),
 		validation.Field(
 			&c.Pools,
 			validation.Each(
 				validation.NotNil.Error("cannot be null"),
+				validation.By(c.validatePoolUdsTimeout),
+				validation.By(c.validatePoolPeerIds),
 			),
 		),
 		validation.Field(
 			&c.LogFile,
 			validation.Match(regexp.MustCompile(constants.Logging.ValidFileRegex)).Error(filenameValidError),
+			validation.When(c.LogBackend == "syslog", validation.Empty.Error(logBackendFileError)),
+		),
+		validation.Field(
+			&c.AuditFile,
+			validation.Match(regexp.MustCompile(constants.Logging.ValidFileRegex)).Error(filenameValidError),
+			validation.When(c.LogFile != "", validation.NotIn(c.LogFile).Error(auditFileError)),
+		),
+		validation.Field(
+			&c.LogFileMaxSize,
+			validation.When(
+				c.LogFileMaxSize != -1 && c.LogFileMaxSize != 0,
+				validation.Min(1).Error(logFileMaxSizeError),
+				validation.Max(constants.Logging.FileMaxSizeMax).Error(logFileMaxSizeError),
+			),
+		),
+		validation.Field(
+			&c.LogFileBackups,
+			validation.When(
+				c.LogFileBackups != -1 && c.LogFileBackups != 0,
+				validation.Min(1).Error(logFileBackupsError),
+				validation.Max(constants.Logging.FileBackupsMax).Error(logFileBackupsError),
+			),
+		),
+		validation.Field(
+			&c.LogDedup,
+			validation.When(
+				c.LogDedup != -1 && c.LogDedup != 0,
+				validation.Min(1).Error(logDedupError),
+				validation.Max(constants.Logging.DedupIntervalMax).Error(logDedupError),
+			),
+		),
+		validation.Field(
+			&c.LogBackend,
+			validation.In(iLogBackends...).Error(logBackendError+fmt.Sprintf("%v", constants.Logging.Backends)),
+		),
+		validation.Field(
+			&c.LogFormat,
+			validation.In(iLogFormats...).Error(logFormatError+fmt.Sprintf("%v", constants.Logging.Formats)),
 		),
 		validation.Field(
 			&c.LogLevel,
 			validation.In(iLogLevels...).Error("must be "+fmt.Sprintf("%v", iLogLevels)),
 		),
+		validation.Field(
+			&c.LogLevels,
+			validation.Map(subsystemLevels...),
+		),
+		validation.Field(
+			&c.MetricsAddr,
+			validation.Match(regexp.MustCompile(constants.Metrics.ValidAddrRegex)).Error(metricsAddrValidError),
+		),
+		validation.Field(
+			&c.MetricsMaxSeries,
+			validation.When(
+				c.MetricsMaxSeries != 0,
+				validation.Min(constants.Metrics.MaxSeriesMin).Error(metricsMaxSeriesError),
+				validation.Max(constants.Metrics.MaxSeriesMax).Error(metricsMaxSeriesError),
+			),
+		),
+		validation.Field(
+			&c.MetricsDropLabels,
+			validation.Each(
+				validation.In(iDroppableLabels...).Error(metricsDropLabelsError+fmt.Sprintf("%v", iDroppableLabels)),
+			),
+		),
+		validation.Field(
+			&c.HealthAddr,
+			validation.Match(regexp.MustCompile(constants.Metrics.ValidAddrRegex)).Error(healthAddrValidError),
+			validation.When(c.MetricsAddr != "", validation.NotIn(c.MetricsAddr).Error(healthAddrMetricsError)),
+		),
+		validation.Field(
+			&c.PodResSock,
+			validation.Match(regexp.MustCompile(constants.PodResources.ValidSocketRegex)).Error(podResSocketValidError),
+		),
+		validation.Field(
+			&c.BpfHelper,
+			validation.Match(regexp.MustCompile(constants.PodResources.ValidSocketRegex)).Error(podResSocketValidError),
+		),
+		validation.Field(
+			&c.BpfPublicKey,
+			validation.Match(regexp.MustCompile(constants.BpfSignature.ValidKeyRegex)).Error(bpfPublicKeyValidError),
+		),
+		validation.Field(
+			&c.TracingEndpoint,
+			validation.Match(regexp.MustCompile(constants.Tracing.ValidEndpointRegex)).Error(tracingEndpointValidError),
+		),
+		validation.Field(
+			&c.SelinuxLabel,
+			validation.Match(regexp.MustCompile(constants.Selinux.ValidLabelRegex)).Error(selinuxLabelValidError),
+			validation.When(c.MinimalSyscalls, validation.Empty.Error(minimalSyscallsError)),
+		),
+		validation.Field(
+			&c.RequirePeerCgroup,
+			validation.When(c.MinimalSyscalls, validation.Empty.Error(minimalSyscallsError)),
+		),
+		validation.Field(
+			&c.DropPrivileges,
+			validation.When(c.MinimalSyscalls, validation.Empty.Error(minimalSyscallsError)),
+		),
+		validation.Field(&c.HandshakeUid, validation.Min(0).Error(handshakeIdError)),
+		validation.Field(&c.HandshakeGid, validation.Min(0).Error(handshakeIdError)),
+		validation.Field(
+			&c.FeatureGates,
+			validation.By(func(value interface{}) error {
+				gates, _ := value.(map[string]bool)
+				return featuregates.Validate(gates)
+			}),
+		),
 	)
 }
 
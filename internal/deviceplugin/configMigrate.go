@@ -0,0 +1,202 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+)
+
+var configDeprecations = metrics.NewCounterVec("config_deprecations_total",
+	"Deprecated formats found in the config file, by format, migrated when the config file is read.", "format")
+
+/*
+configMigration migrates the decoded JSON of a config file from a format that predates
+versioning, changing it in place. It returns a deprecation warning for each change, none if
+the config file does not use the format.
+*/
+type configMigration struct {
+	format  string
+	migrate func(doc map[string]interface{}) []string
+}
+
+/*
+configMigrations are applied in order to config files without a version, so that config files
+written for earlier releases keep working.
+*/
+var configMigrations = []configMigration{
+	{format: "global_mode", migrate: migrateGlobalMode},
+	{format: "name_lists", migrate: migrateNameLists},
+}
+
+/*
+logDeprecations logs the deprecation warnings of the config file being read.
+*/
+func logDeprecations(warnings []string) {
+	for _, warning := range warnings {
+		logging.Warningf("Deprecated config: %s", warning)
+	}
+}
+
+/*
+migrateConfigFile applies the config migrations to raw, returning the migrated config file and
+a deprecation warning for each change. raw is returned unchanged if no migration applies, so
+errors keep the positions of fields in the config file as written.
+*/
+func migrateConfigFile(raw []byte) ([]byte, []string, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return raw, nil, positionError(raw, err)
+	}
+
+	var warnings []string
+	for _, migration := range configMigrations {
+		migrated := migration.migrate(doc)
+		if len(migrated) > 0 {
+			configDeprecations.Add(float64(len(migrated)), migration.format)
+		}
+		warnings = append(warnings, migrated...)
+	}
+	if len(warnings) == 0 {
+		return raw, nil, nil
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return raw, nil, err
+	}
+
+	return migrated, warnings, nil
+}
+
+/*
+migrateGlobalMode moves the mode set for all pools at the top level of the config file, as
+before pools had a mode of their own, into each pool without a mode.
+*/
+func migrateGlobalMode(doc map[string]interface{}) []string {
+	key, ok := docKey(doc, "mode")
+	if !ok {
+		return nil
+	}
+	mode := doc[key]
+	delete(doc, key)
+
+	for _, pool := range docPools(doc) {
+		if _, ok := docKey(pool, "mode"); !ok {
+			pool["mode"] = mode
+		}
+	}
+
+	return []string{fmt.Sprintf("%s: a mode for all pools is deprecated, set the mode of each pool", key)}
+}
+
+/*
+migrateNameLists replaces the lists of driver and device names of pools, and of the nodes of
+pools, with lists of objects with a name, as used since drivers and devices took settings.
+*/
+func migrateNameLists(doc map[string]interface{}) []string {
+	var warnings []string
+
+	migrate := func(path string, object map[string]interface{}) {
+		for _, field := range []string{"drivers", "devices", "excludeDevices"} {
+			key, ok := docKey(object, field)
+			if !ok {
+				continue
+			}
+			list, ok := object[key].([]interface{})
+			if !ok {
+				continue
+			}
+
+			migrated := false
+			for i, item := range list {
+				if name, ok := item.(string); ok {
+					list[i] = map[string]interface{}{"name": name}
+					migrated = true
+				}
+			}
+			if migrated {
+				warnings = append(warnings, fmt.Sprintf("%s%s: a list of names is deprecated, use a list of objects with a name", path, key))
+			}
+		}
+	}
+
+	for i, pool := range docPools(doc) {
+		path := fmt.Sprintf("pools[%d].", i)
+		migrate(path, pool)
+		for _, driver := range docObjects(pool, "drivers") {
+			migrate(path+"drivers[].", driver)
+		}
+		for j, node := range docObjects(pool, "nodes") {
+			nodePath := fmt.Sprintf("%snodes[%d].", path, j)
+			migrate(nodePath, node)
+			for _, driver := range docObjects(node, "drivers") {
+				migrate(nodePath+"drivers[].", driver)
+			}
+		}
+	}
+
+	return warnings
+}
+
+/*
+docKey returns the key of a field of a decoded JSON object, matched ignoring case as fields
+are when decoded.
+*/
+func docKey(object map[string]interface{}, field string) (string, bool) {
+	for key := range object {
+		if strings.EqualFold(key, field) {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+/*
+docPools returns the pools of a decoded config file.
+*/
+func docPools(doc map[string]interface{}) []map[string]interface{} {
+	return docObjects(doc, "pools")
+}
+
+/*
+docObjects returns the objects of a list field of a decoded JSON object, skipping anything
+other than objects, which is left for decoding to reject.
+*/
+func docObjects(object map[string]interface{}, field string) []map[string]interface{} {
+	key, ok := docKey(object, field)
+	if !ok {
+		return nil
+	}
+	list, ok := object[key].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var objects []map[string]interface{}
+	for _, item := range list {
+		if obj, ok := item.(map[string]interface{}); ok {
+			objects = append(objects, obj)
+		}
+	}
+
+	return objects
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateConfigFile(t *testing.T) {
+	testCases := []struct {
+		name        string
+		raw         string
+		expWarnings []string
+		expPools    []*configFile_Pool
+	}{
+		{
+			name: "current format",
+			raw: `{
+  "pools":[
+    {"name":"testPool", "mode":"primary", "drivers":[{"name":"i40e"}]}
+  ]
+}`,
+			expPools: []*configFile_Pool{
+				{Name: "testPool", Mode: "primary", Drivers: []*configFile_Driver{{Name: "i40e"}}},
+			},
+		},
+		{
+			name: "global mode",
+			raw: `{
+  "mode":"cdq",
+  "pools":[
+    {"name":"testPool1", "drivers":[{"name":"i40e"}]},
+    {"name":"testPool2", "mode":"primary", "drivers":[{"name":"ice"}]}
+  ]
+}`,
+			expWarnings: []string{"mode: a mode for all pools is deprecated, set the mode of each pool"},
+			expPools: []*configFile_Pool{
+				{Name: "testPool1", Mode: "cdq", Drivers: []*configFile_Driver{{Name: "i40e"}}},
+				{Name: "testPool2", Mode: "primary", Drivers: []*configFile_Driver{{Name: "ice"}}},
+			},
+		},
+		{
+			name: "name lists",
+			raw: `{
+  "Pools":[
+    {
+      "name":"testPool",
+      "mode":"primary",
+      "Drivers":["i40e", {"name":"ice", "excludeDevices":["ens801f0"]}],
+      "nodes":[{"hostname":"k8snode1", "devices":["ens785f0"]}]
+    }
+  ]
+}`,
+			expWarnings: []string{
+				"pools[0].Drivers: a list of names is deprecated, use a list of objects with a name",
+				"pools[0].drivers[].excludeDevices: a list of names is deprecated, use a list of objects with a name",
+				"pools[0].nodes[0].devices: a list of names is deprecated, use a list of objects with a name",
+			},
+			expPools: []*configFile_Pool{
+				{
+					Name: "testPool",
+					Mode: "primary",
+					Drivers: []*configFile_Driver{
+						{Name: "i40e"},
+						{Name: "ice", ExcludeDevices: []*configFile_Device{{Name: "ens801f0"}}},
+					},
+					Nodes: []*configFile_Node{
+						{Hostname: "k8snode1", Devices: []*configFile_Device{{Name: "ens785f0"}}},
+					},
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			migrated, warnings, err := migrateConfigFile([]byte(tc.raw))
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expWarnings, warnings, "Unexpected deprecation warnings")
+			if len(tc.expWarnings) == 0 {
+				assert.Equal(t, tc.raw, string(migrated), "Config file should be unchanged")
+			}
+
+			cfg, err := decodeConfigFile([]byte(tc.raw))
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expPools, cfg.Pools, "Unexpected pools")
+		})
+	}
+}
+
+func TestMigrateVersionedConfigFile(t *testing.T) {
+	raw := `{
+  "version":"v1",
+  "pools":[
+    {"name":"testPool", "mode":"primary", "drivers":["i40e"]}
+  ]
+}`
+
+	_, err := decodeConfigFile([]byte(raw))
+	require.Error(t, err, "A versioned config file should not be migrated")
+	assert.Contains(t, err.Error(), "line 3", "Unexpected error")
+}
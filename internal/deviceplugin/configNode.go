@@ -0,0 +1,214 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+nodeSource gets the metadata of this node from the API server, nil if unavailable.
+The metadata is got once, on first use, and kept for the lifetime of the device plugin.
+*/
+var nodeSource = struct {
+	sync.Mutex
+	handler apiserver.Handler
+	node    *apiserver.Node
+}{}
+
+/*
+SetNodeSource sets the API server handler used to get the metadata of this node, the name,
+labels and annotations that templates and pool node selectors in the config file are resolved
+with. It must be called before the config file is read. Without it, a config file using node
+metadata is rejected.
+*/
+func SetNodeSource(handler apiserver.Handler) {
+	nodeSource.Lock()
+	defer nodeSource.Unlock()
+
+	nodeSource.handler = handler
+	nodeSource.node = nil
+}
+
+/*
+getNode returns the metadata of this node, getting it from the API server on first use.
+*/
+func getNode() (*apiserver.Node, error) {
+	nodeSource.Lock()
+	defer nodeSource.Unlock()
+
+	if nodeSource.node != nil {
+		return nodeSource.node, nil
+	}
+	if nodeSource.handler == nil {
+		return nil, errors.New(nodeSourceError)
+	}
+
+	node, err := nodeSource.handler.GetNode()
+	if err != nil {
+		return nil, err
+	}
+	logging.Infof("Resolving config file with the metadata of node %s", node.Name)
+	nodeSource.node = node
+
+	return node, nil
+}
+
+/*
+resolveNodeTemplates renders the string fields of the config file that hold a Go template, such
+as {{ .Name }} or {{ index .Labels "nic" }}, with the metadata of this node. Missing labels and
+annotations render as empty strings. The node is only got from the API server if the config file
+has templates or pool node selectors, so other config files do not depend on the API server.
+*/
+func resolveNodeTemplates(cfg *configFile) error {
+	if !usesNode(cfg) {
+		return nil
+	}
+
+	node, err := getNode()
+	if err != nil {
+		return fmt.Errorf("error getting node metadata: %w", err)
+	}
+
+	return renderTemplates("", reflect.ValueOf(cfg).Elem(), node)
+}
+
+/*
+usesNode returns true if the config file has templates or pool node selectors.
+*/
+func usesNode(cfg *configFile) bool {
+	for _, pool := range cfg.Pools {
+		if pool != nil && len(pool.NodeSelector) > 0 {
+			return true
+		}
+	}
+
+	used := false
+	walkStrings("", reflect.ValueOf(cfg).Elem(), func(path string, value reflect.Value) error {
+		if strings.Contains(value.String(), "{{") {
+			used = true
+		}
+		return nil
+	})
+
+	return used
+}
+
+/*
+renderTemplates renders each string under value that holds a template, naming the field by its
+path in the config file in errors.
+*/
+func renderTemplates(prefix string, value reflect.Value, node *apiserver.Node) error {
+	return walkStrings(prefix, value, func(path string, field reflect.Value) error {
+		text := field.String()
+		if !strings.Contains(text, "{{") {
+			return nil
+		}
+
+		tmpl, err := template.New(path).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return fmt.Errorf("%s: %s: %v", path, nodeTemplateError, err)
+		}
+		var rendered bytes.Buffer
+		if err := tmpl.Execute(&rendered, node); err != nil {
+			return fmt.Errorf("%s: %s: %v", path, nodeTemplateError, err)
+		}
+
+		logging.Debugf("Resolved %s to %q for node %s", path, rendered.String(), node.Name)
+		field.SetString(rendered.String())
+		return nil
+	})
+}
+
+/*
+walkStrings calls fn on each settable string under value, in structs, pointers, slices and maps
+of strings, with its path in the config file, stopping at the first error.
+*/
+func walkStrings(prefix string, value reflect.Value, fn func(path string, value reflect.Value) error) error {
+	switch value.Kind() {
+	case reflect.String:
+		return fn(prefix, value)
+
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		return walkStrings(prefix, value.Elem(), fn)
+
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			if err := walkStrings(joinPath(prefix, name), value.Field(i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			if err := walkStrings(joinPath(prefix, strconv.Itoa(i)), value.Index(i), fn); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		if value.Type().Elem().Kind() != reflect.String {
+			return nil
+		}
+		for _, key := range value.MapKeys() {
+			// map values are not addressable, so are set through a copy
+			elem := reflect.New(value.Type().Elem()).Elem()
+			elem.Set(value.MapIndex(key))
+			if err := walkStrings(joinPath(prefix, key.String()), elem, fn); err != nil {
+				return err
+			}
+			value.SetMapIndex(key, elem)
+		}
+	}
+
+	return nil
+}
+
+/*
+nodeSelected returns true if the pool is to be created on this node, that is if the pool has no
+node selector or this node has every label of the selector.
+*/
+func nodeSelected(pool *configFile_Pool) (bool, error) {
+	if len(pool.NodeSelector) == 0 {
+		return true, nil
+	}
+
+	node, err := getNode()
+	if err != nil {
+		return false, fmt.Errorf("error getting node metadata: %w", err)
+	}
+	for label, value := range pool.NodeSelector {
+		if nodeValue, ok := node.Labels[label]; !ok || nodeValue != value {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveNodeTemplates(t *testing.T) {
+	node := &apiserver.Node{
+		Name:        "node1",
+		Labels:      map[string]string{"nic": "cvl", "driver": "ice"},
+		Annotations: map[string]string{"afxdp.intel.com/device": "ens801f0"},
+	}
+
+	testCases := []struct {
+		name       string
+		configFile string
+		node       *apiserver.Node
+		nodeErr    error
+		check      func(t *testing.T, cfg *configFile)
+		expErr     bool
+	}{
+		{
+			name:       "no templates, node not needed",
+			configFile: `{"pools":[{"name":"pool1","drivers":[{"name":"ice"}]}]}`,
+			nodeErr:    errors.New("API server unavailable"),
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "ice", cfg.Pools[0].Drivers[0].Name, "Unexpected driver")
+			},
+		},
+		{
+			name:       "label and annotation",
+			configFile: `{"pools":[{"name":"pool1","drivers":[{"name":"{{ .Labels.driver }}"}],"devices":[{"name":"{{ index .Annotations \"afxdp.intel.com/device\" }}"}]}]}`,
+			node:       node,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "ice", cfg.Pools[0].Drivers[0].Name, "Unexpected driver")
+				assert.Equal(t, "ens801f0", cfg.Pools[0].Devices[0].Name, "Unexpected device")
+			},
+		},
+		{
+			name:       "node name in map and list",
+			configFile: `{"logLevels":{"udsserver":"{{ if eq .Name \"node1\" }}debug{{ else }}info{{ end }}"},"metricsDropLabels":["{{ .Labels.missing }}pod"]}`,
+			node:       node,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "debug", cfg.LogLevels["udsserver"], "Unexpected log level")
+				assert.Equal(t, []string{"pod"}, cfg.MetricsDropLabels, "Missing labels should render empty")
+			},
+		},
+		{
+			name:       "invalid template",
+			configFile: `{"pools":[{"name":"pool1","drivers":[{"name":"{{ .Labels.driver "}]}]}`,
+			node:       node,
+			expErr:     true,
+		},
+		{
+			name:       "node unavailable",
+			configFile: `{"pools":[{"name":"pool1","drivers":[{"name":"{{ .Labels.driver }}"}]}]}`,
+			nodeErr:    errors.New("API server unavailable"),
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeApi := apiserver.NewFakeHandler()
+			fakeApi.SetFakeNode(tc.node)
+			fakeApi.SetError(tc.nodeErr)
+			SetNodeSource(fakeApi)
+			defer SetNodeSource(nil)
+
+			cfg := &configFile{}
+			require.NoError(t, json.Unmarshal([]byte(tc.configFile), cfg), "Can't decode config file")
+
+			err := resolveNodeTemplates(cfg)
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			tc.check(t, cfg)
+		})
+	}
+}
+
+func TestNodeSelected(t *testing.T) {
+	fakeApi := apiserver.NewFakeHandler()
+	fakeApi.SetFakeNode(&apiserver.Node{Name: "node1", Labels: map[string]string{"nic": "cvl", "zone": "a"}})
+	SetNodeSource(fakeApi)
+	defer SetNodeSource(nil)
+
+	testCases := []struct {
+		name     string
+		selector map[string]string
+		expected bool
+	}{
+		{
+			name:     "no selector",
+			expected: true,
+		},
+		{
+			name:     "all labels match",
+			selector: map[string]string{"nic": "cvl", "zone": "a"},
+			expected: true,
+		},
+		{
+			name:     "label value differs",
+			selector: map[string]string{"nic": "fvl"},
+			expected: false,
+		},
+		{
+			name:     "label missing",
+			selector: map[string]string{"nic": "cvl", "rack": "1"},
+			expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			selected, err := nodeSelected(&configFile_Pool{Name: "pool1", NodeSelector: tc.selector})
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expected, selected, "Unexpected selection")
+		})
+	}
+}
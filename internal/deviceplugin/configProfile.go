@@ -0,0 +1,143 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+configProfile gives defaults for the fields of the config file, and of each pool, that a config
+file does not set.
+*/
+type configProfile struct {
+	config configFile
+	pool   func(pool *configFile_Pool) // sets the fields of a pool that are not set, nil if none
+}
+
+/*
+profiles are the built in profiles, named in constants.ConfigFile.Profiles.
+The dev profile suits trying the device plugin out on nodes without AF_XDP capable devices:
+pools without devices create tap devices, logs are verbose and UDS connections never time out.
+The prod profile sets strict timeouts, so that a slow kubelet or API server fails a UDS
+handshake early, and logs JSON for log collectors.
+The ci profile runs the whole plugin, registration, allocation, the CNI and the UDS handshake,
+inside a kind cluster without AF_XDP capable NICs, as the e2e tests do in CI. The device plugin
+creates the kind secondary network, a bridge with veth pairs on each node, and pools without
+devices are primary mode pools of its veths. Veths take XDP programs without driver support and
+AF_XDP sockets bind to them in copy mode, so no lab hardware is needed.
+*/
+var profiles = map[string]configProfile{
+	"dev": {
+		config: configFile{
+			LogLevel: "debug",
+			LogDedup: -1,
+		},
+		pool: func(pool *configFile_Pool) {
+			if pool.Mode == "" && len(pool.Drivers) == 0 && len(pool.Devices) == 0 && len(pool.Nodes) == 0 {
+				pool.Mode = "tap"
+			}
+			if pool.Mode == "tap" && pool.TapDevices == 0 {
+				pool.TapDevices = 4 // enough for a few test pods
+			}
+			if pool.UdsTimeout == 0 {
+				pool.UdsTimeout = -1
+			}
+		},
+	},
+	"prod": {
+		config: configFile{
+			LogFormat: "json",
+			Timeouts: configFile_Timeouts{
+				ApiServer:    3,
+				PodResources: 3,
+				Kubelet:      3,
+				UdsIdle:      constants.Uds.MinTimeout,
+				Shutdown:     constants.Plugins.DevicePlugin.ShutdownTimeout,
+			},
+		},
+	},
+	"ci": {
+		config: configFile{
+			KindCluster: true,
+			LogLevel:    "debug",
+		},
+		pool: func(pool *configFile_Pool) {
+			if pool.Mode == "" && len(pool.Drivers) == 0 && len(pool.Devices) == 0 && len(pool.Nodes) == 0 {
+				pool.Mode = "primary"
+				pool.Drivers = []*configFile_Driver{{Name: "veth"}} // the veths of the kind secondary network
+			}
+		},
+	},
+}
+
+/*
+profile is the name of the profile selected with SetProfile, empty if none.
+*/
+var profile string
+
+/*
+SetProfile selects a built in profile, one of constants.ConfigFile.Profiles, giving defaults for
+the fields the config file does not set. Env vars and command line overrides still take
+precedence. An empty name selects no profile.
+*/
+func SetProfile(name string) error {
+	if _, ok := profiles[name]; name != "" && !ok {
+		return errdefs.Wrap(errdefs.ErrValidationFailed, fmt.Errorf("%s %v", profileError, constants.ConfigFile.Profiles))
+	}
+	profile = name
+
+	return nil
+}
+
+/*
+profileConfig returns the config file the selected profile gives, for the config file to be
+decoded onto, so that only the fields the config file sets replace those of the profile.
+*/
+func profileConfig() *configFile {
+	selected, ok := profiles[profile]
+	if !ok {
+		return &configFile{}
+	}
+
+	cfg := selected.config
+	return &cfg
+}
+
+/*
+applyProfilePools sets the fields of each pool that the config file does not set to the value
+given by the selected profile.
+*/
+func applyProfilePools(cfg *configFile) {
+	selected, ok := profiles[profile]
+	if !ok {
+		return
+	}
+
+	logging.Infof("Applying config profile %s", profile)
+	if selected.pool == nil {
+		return
+	}
+	for _, pool := range cfg.Pools {
+		if pool != nil {
+			selected.pool(pool)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"os"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles(t *testing.T) {
+	for _, name := range constants.ConfigFile.Profiles {
+		assert.Contains(t, profiles, name, "Profile should be defined")
+	}
+	assert.Len(t, profiles, len(constants.ConfigFile.Profiles), "Profile should be named in constants")
+
+	assert.Error(t, SetProfile("test"), "Unknown profile should be rejected")
+}
+
+func TestApplyProfile(t *testing.T) {
+	testCases := []struct {
+		name       string
+		profile    string
+		configFile string
+		env        map[string]string
+		check      func(t *testing.T, cfg *configFile)
+	}{
+		{
+			name:       "no profile",
+			configFile: `{"pools":[{"name":"pool1","mode":"primary","drivers":[{"name":"i40e"}]}]}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Empty(t, cfg.LogLevel, "Unexpected log level")
+				assert.Equal(t, 0, cfg.Pools[0].UdsTimeout, "Unexpected UDS timeout")
+			},
+		},
+		{
+			name:       "dev profile",
+			profile:    "dev",
+			configFile: `{"pools":[{"name":"pool1"},{"name":"pool2","mode":"primary","drivers":[{"name":"i40e"}],"udsTimeout":60}]}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "debug", cfg.LogLevel, "Unexpected log level")
+				assert.Equal(t, -1, cfg.LogDedup, "Unexpected log dedup interval")
+				assert.Equal(t, "tap", cfg.Pools[0].Mode, "Pool without devices should be a tap pool")
+				assert.Equal(t, 4, cfg.Pools[0].TapDevices, "Unexpected tap devices")
+				assert.Equal(t, -1, cfg.Pools[0].UdsTimeout, "UDS timeout should be disabled")
+				assert.Equal(t, "primary", cfg.Pools[1].Mode, "Unexpected mode")
+				assert.Equal(t, 0, cfg.Pools[1].TapDevices, "Unexpected tap devices")
+				assert.Equal(t, 60, cfg.Pools[1].UdsTimeout, "UDS timeout set in the config file should be kept")
+			},
+		},
+		{
+			name:       "prod profile",
+			profile:    "prod",
+			configFile: `{"timeouts":{"apiServer":10},"pools":[{"name":"pool1","mode":"primary","drivers":[{"name":"i40e"}]}]}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "json", cfg.LogFormat, "Unexpected log format")
+				assert.Equal(t, 10, cfg.Timeouts.ApiServer, "Timeout set in the config file should be kept")
+				assert.Equal(t, 3, cfg.Timeouts.PodResources, "Unexpected pod resources timeout")
+				assert.Equal(t, 0, cfg.Pools[0].UdsTimeout, "Unexpected UDS timeout")
+				assert.NoError(t, cfg.Validate(), "Profile should be valid")
+			},
+		},
+		{
+			name:       "ci profile",
+			profile:    "ci",
+			configFile: `{"pools":[{"name":"pool1","uid":1500}]}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.True(t, cfg.KindCluster, "Kind secondary network should be created")
+				assert.Equal(t, "debug", cfg.LogLevel, "Unexpected log level")
+				assert.Equal(t, "primary", cfg.Pools[0].Mode, "Pool without devices should be a primary pool")
+				if assert.Len(t, cfg.Pools[0].Drivers, 1, "Unexpected drivers") {
+					assert.Equal(t, "veth", cfg.Pools[0].Drivers[0].Name, "Pool should take the veths of the kind network")
+				}
+				assert.Equal(t, 1500, cfg.Pools[0].UID, "UID set in the config file should be kept")
+				assert.NoError(t, cfg.Validate(), "Profile should be valid")
+			},
+		},
+		{
+			name:       "ci profile pool with devices",
+			profile:    "ci",
+			configFile: `{"kindCluster":false,"pools":[{"name":"pool1","mode":"primary","drivers":[{"name":"i40e"}]}]}`,
+			check: func(t *testing.T, cfg *configFile) {
+				assert.False(t, cfg.KindCluster, "Kind cluster set in the config file should be kept")
+				if assert.Len(t, cfg.Pools[0].Drivers, 1, "Unexpected drivers") {
+					assert.Equal(t, "i40e", cfg.Pools[0].Drivers[0].Name, "Drivers set in the config file should be kept")
+				}
+			},
+		},
+		{
+			name:       "profile overridden",
+			profile:    "prod",
+			configFile: `{"logFormat":"text","pools":[{"name":"pool1","mode":"primary","drivers":[{"name":"i40e"}]}]}`,
+			env:        map[string]string{"AFXDP_DP_TIMEOUTS_KUBELET": "5"},
+			check: func(t *testing.T, cfg *configFile) {
+				assert.Equal(t, "text", cfg.LogFormat, "Log format set in the config file should be kept")
+				assert.Equal(t, 5, cfg.Timeouts.Kubelet, "Timeout set by env var should be kept")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.NoError(t, SetProfile(tc.profile), "Unexpected error")
+			defer SetProfile("")
+
+			for name, value := range tc.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+
+			cfg, err := decodeConfigFile([]byte(tc.configFile))
+			require.NoError(t, err, "Unexpected error")
+			applyProfilePools(cfg)
+			require.NoError(t, applyEnvOverrides(cfg), "Unexpected error")
+			tc.check(t, cfg)
+		})
+	}
+}
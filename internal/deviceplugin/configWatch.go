@@ -0,0 +1,250 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/crash"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	logging "github.com/sirupsen/logrus"
+)
+
+var configReloads = metrics.NewCounterVec("config_reloads_total",
+	"Reloads of the config file, by result: applied, restart_required if some changes were not applied, or invalid.", "result")
+
+/*
+runtimeFields are the fields of the config file, named as in the config file, whose changes are
+applied to a running device plugin. Changes to any other field, including the pools, only take
+effect on restart: devices allocated to pods have been moved into the pod network namespace and
+cannot be rediscovered, and the API server client and servers are created once at startup.
+*/
+var runtimeFields = []string{
+	"LogLevel",
+	"logLevels",
+	"logDedupInterval",
+	"timeouts.podCheckInterval",
+	"timeouts.podResourcesCacheTTL",
+}
+
+/*
+ConfigReload is the result of rereading the config file of a running device plugin.
+*/
+type ConfigReload struct {
+	Config  PluginConfig // the reread config, of which only the runtime fields are to be applied
+	Restart []string     // the changed fields that only take effect on restart, named as in the config file
+	Pools   []string     // the pools added, removed or changed, whose devices and capacity only change on restart
+}
+
+/*
+ReloadConfig rereads and validates the config file, returning the reread config and the fields
+changed since startup that cannot be applied while running. An invalid config file is rejected
+whole. The config read at startup is kept, so that changes requiring a restart keep being
+reported until the device plugin is restarted.
+*/
+func ReloadConfig(configFile string) (*ConfigReload, error) {
+	cfg, err := parseConfigFile(configFile)
+	if err != nil {
+		configReloads.Add(1, "invalid")
+		return nil, err
+	}
+
+	pluginConfig, err := pluginConfigOf(cfg)
+	if err != nil {
+		configReloads.Add(1, "invalid")
+		return nil, err
+	}
+
+	reload := &ConfigReload{Config: pluginConfig}
+	if cfgFile != nil {
+		for _, field := range changedFields("", reflect.ValueOf(*cfgFile), reflect.ValueOf(*cfg)) {
+			if !tools.ArrayContains(runtimeFields, field) {
+				reload.Restart = append(reload.Restart, field)
+			}
+		}
+		reload.Pools = changedPools(cfgFile.Pools, cfg.Pools)
+	}
+
+	if len(reload.Restart) > 0 {
+		configReloads.Add(1, "restart_required")
+	} else {
+		configReloads.Add(1, "applied")
+	}
+
+	return reload, nil
+}
+
+/*
+changedFields returns the names, as in the config file, of the fields that differ between the
+structs running and reread. Nested structs are compared field by field, anything else as a whole.
+*/
+func changedFields(prefix string, running reflect.Value, reread reflect.Value) []string {
+	var changed []string
+
+	for i := 0; i < running.NumField(); i++ {
+		field := running.Type().Field(i)
+		name := joinPath(prefix, strings.Split(field.Tag.Get("json"), ",")[0])
+
+		if field.Type.Kind() == reflect.Struct {
+			changed = append(changed, changedFields(name, running.Field(i), reread.Field(i))...)
+			continue
+		}
+		if !reflect.DeepEqual(running.Field(i).Interface(), reread.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+
+	return changed
+}
+
+/*
+changedPools returns the names of the pools added, removed or changed between the pools running and
+reread, sorted.
+*/
+func changedPools(running []*configFile_Pool, reread []*configFile_Pool) []string {
+	pools := make(map[string]*configFile_Pool)
+	for _, pool := range running {
+		pools[pool.Name] = pool
+	}
+
+	var changed []string
+	for _, pool := range reread {
+		if !reflect.DeepEqual(pools[pool.Name], pool) {
+			changed = append(changed, pool.Name)
+		}
+		delete(pools, pool.Name)
+	}
+	for name := range pools {
+		changed = append(changed, name)
+	}
+	sort.Strings(changed)
+
+	return changed
+}
+
+/*
+WatchConfigFile watches the config file for changes, calling reload when its contents change,
+until stop is closed. The directory of the config file is watched rather than the file, as a
+mounted ConfigMap is updated by swapping a symlink to a new directory rather than by writing to
+the file, and the contents are compared so only real changes are reloaded. Events are left to
+settle before the file is reread, as an editor or kubelet update produces several. If the config
+file cannot be watched, it is checked for changes every interval instead. A config file that
+cannot be read is skipped until it can be read again.
+*/
+func WatchConfigFile(file string, interval time.Duration, stop <-chan struct{}, reload func()) {
+	last, err := ioutil.ReadFile(file)
+	if err != nil {
+		logging.Warningf("Error reading config file %s to watch: %v", file, err)
+	}
+
+	changed := func() {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			logging.Debugf("Error reading config file %s, skipping: %v", file, err)
+			return
+		}
+		if bytes.Equal(raw, last) {
+			return
+		}
+		last = raw
+
+		logging.Infof("Config file %s changed, reloading", file)
+		reload()
+	}
+
+	crash.Go("config watch", func() {
+		watcher, err := watchConfigDirs(file)
+		if err != nil {
+			logging.Warningf("Error watching config file %s, checking it every %v instead: %v", file, interval, err)
+			pollConfigFile(interval, stop, changed)
+			return
+		}
+		defer watcher.Close()
+
+		settle := time.Duration(constants.ConfigFile.WatchSettle) * time.Millisecond
+		var settled <-chan time.Time
+		for {
+			select {
+			case <-stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				logging.Debugf("Config file directory event: %v", event)
+				settled = time.After(settle)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logging.Warningf("Error watching config file %s: %v", file, err)
+			case <-settled:
+				settled = nil
+				changed()
+			}
+		}
+	})
+}
+
+/*
+watchConfigDirs returns a watcher of the directory of the config file and, if the config file is a
+symlink to another directory, of the directory it resolves to.
+*/
+func watchConfigDirs(file string) (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs := []string{filepath.Dir(file)}
+	if resolved, err := filepath.EvalSymlinks(file); err == nil && filepath.Dir(resolved) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(resolved))
+	}
+	for _, dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, err
+		}
+	}
+
+	return watcher, nil
+}
+
+/*
+pollConfigFile calls changed every interval until stop is closed.
+*/
+func pollConfigFile(interval time.Duration, stop <-chan struct{}, changed func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed()
+		}
+	}
+}
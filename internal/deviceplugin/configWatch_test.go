@@ -0,0 +1,180 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloadConfig(t *testing.T) {
+	running := `{"logLevel":"info","timeouts":{"podCheckInterval":10},"pools":[{"name":"pool1","mode":"primary","devices":[{"name":"dev1"}]}]}`
+
+	testCases := []struct {
+		name       string
+		configFile string
+		expLevel   string
+		expCheck   int
+		expRestart []string
+		expPools   []string
+		expErr     bool
+	}{
+		{
+			name:       "unchanged",
+			configFile: running,
+			expLevel:   "info",
+			expCheck:   10,
+		},
+		{
+			name:       "runtime fields changed",
+			configFile: `{"logLevel":"debug","logLevels":{"udsserver":"trace"},"logDedupInterval":-1,"timeouts":{"podCheckInterval":30,"podResourcesCacheTTL":5},"pools":[{"name":"pool1","mode":"primary","devices":[{"name":"dev1"}]}]}`,
+			expLevel:   "debug",
+			expCheck:   30,
+		},
+		{
+			name:       "pools changed",
+			configFile: `{"logLevel":"debug","timeouts":{"podCheckInterval":10},"pools":[{"name":"pool1","mode":"primary","devices":[{"name":"dev1"},{"name":"dev2"}]}]}`,
+			expLevel:   "debug",
+			expCheck:   10,
+			expRestart: []string{"Pools"},
+			expPools:   []string{"pool1"},
+		},
+		{
+			name:       "pools added and removed",
+			configFile: `{"logLevel":"info","timeouts":{"podCheckInterval":10},"pools":[{"name":"pool2","mode":"primary","devices":[{"name":"dev1"}]}]}`,
+			expLevel:   "info",
+			expCheck:   10,
+			expRestart: []string{"Pools"},
+			expPools:   []string{"pool1", "pool2"},
+		},
+		{
+			name:       "restart fields changed",
+			configFile: `{"logLevel":"info","metricsAddr":":9100","timeouts":{"podCheckInterval":10,"apiServer":20},"pools":[{"name":"pool1","mode":"primary","devices":[{"name":"dev1"}]}]}`,
+			expLevel:   "info",
+			expCheck:   10,
+			expRestart: []string{"timeouts.apiServer", "metricsAddr"},
+		},
+		{
+			name:       "invalid",
+			configFile: `{"logLevel":"verbose","timeouts":{"podCheckInterval":10},"pools":[{"name":"pool1","mode":"primary","devices":[{"name":"dev1"}]}]}`,
+			expErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgFile = nil
+			dir, dirErr := ioutil.TempDir("/tmp", "test-afxdp-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			defer os.RemoveAll(dir)
+
+			testFile := filepath.Join(dir, "tmpfile")
+			require.NoError(t, ioutil.WriteFile(testFile, []byte(running), 0666), "Can't create temporary file")
+			require.NoError(t, readConfigFile(testFile), "Can't read running config file")
+			started := cfgFile
+
+			require.NoError(t, ioutil.WriteFile(testFile, []byte(tc.configFile), 0666), "Can't update temporary file")
+			reload, err := ReloadConfig(testFile)
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expLevel, reload.Config.LogLevel, "Unexpected log level")
+			assert.Equal(t, tc.expCheck, reload.Config.PodCheckInterval, "Unexpected pod check interval")
+			assert.Equal(t, tc.expRestart, reload.Restart, "Unexpected fields requiring restart")
+			assert.Equal(t, tc.expPools, reload.Pools, "Unexpected pools requiring restart")
+			assert.Same(t, started, cfgFile, "Reloading should not replace the config read at startup")
+		})
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, dirErr, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+
+	testFile := filepath.Join(dir, "tmpfile")
+	require.NoError(t, ioutil.WriteFile(testFile, []byte(`{"logLevel":"info"}`), 0666), "Can't create temporary file")
+
+	reloads := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+	WatchConfigFile(testFile, 10*time.Millisecond, stop, func() { reloads <- struct{}{} })
+
+	select {
+	case <-reloads:
+		t.Fatal("Reloaded an unchanged config file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, os.Remove(testFile), "Can't remove temporary file")
+	select {
+	case <-reloads:
+		t.Fatal("Reloaded a missing config file")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, ioutil.WriteFile(testFile, []byte(`{"logLevel":"debug"}`), 0666), "Can't update temporary file")
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("Changed config file not reloaded")
+	}
+
+	select {
+	case <-reloads:
+		t.Fatal("Reloaded a config file changed once more than once")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWatchConfigFileSymlinkSwap(t *testing.T) {
+	dir, dirErr := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, dirErr, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+
+	// laid out as the kubelet mounts a ConfigMap, updated by swapping the ..data symlink
+	for i, contents := range []string{`{"logLevel":"info"}`, `{"logLevel":"debug"}`} {
+		data := filepath.Join(dir, "..data_"+strconv.Itoa(i))
+		require.NoError(t, os.Mkdir(data, 0755), "Can't create data directory")
+		require.NoError(t, ioutil.WriteFile(filepath.Join(data, "config.json"), []byte(contents), 0644), "Can't create temporary file")
+	}
+	require.NoError(t, os.Symlink("..data_0", filepath.Join(dir, "..data")), "Can't create data symlink")
+	testFile := filepath.Join(dir, "config.json")
+	require.NoError(t, os.Symlink("..data/config.json", testFile), "Can't create config symlink")
+
+	reloads := make(chan struct{}, 10)
+	stop := make(chan struct{})
+	defer close(stop)
+	WatchConfigFile(testFile, time.Hour, stop, func() { reloads <- struct{}{} })
+	time.Sleep(50 * time.Millisecond)
+
+	require.NoError(t, os.Symlink("..data_1", filepath.Join(dir, "..data_tmp")), "Can't create data symlink")
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")), "Can't swap data symlink")
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		t.Fatal("Config file changed by a symlink swap not reloaded")
+	}
+}
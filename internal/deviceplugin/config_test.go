@@ -17,6 +17,9 @@ package deviceplugin
 
 import (
 	"errors"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"io/ioutil"
@@ -1182,6 +1185,1368 @@ func TestReadConfigFile(t *testing.T) {
 						}`,
 			expErr: errors.New(poolEthtoolCharacters),
 		},
+		{
+			name: "tap pool",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"tap",
+									"tapDevices":4
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "tap pool must have tap devices",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"tap"
+								}
+							]
+						}`,
+			expErr: errors.New(poolTapDevicesError),
+		},
+		{
+			name: "tap pool tap devices too high",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"tap",
+									"tapDevices":33
+								}
+							]
+						}`,
+			expErr: errors.New(poolTapDevicesError),
+		},
+		{
+			name: "tap devices only in tap mode",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"tapDevices":4,
+									"drivers":[
+										{
+											"name":"ice"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolTapModeError),
+		},
+		{
+			name: "pci id lists",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"pciIds":["8086:158b", "8086:1592"],
+									"excludePciIds":["8086:1593"],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "pci id must be vendor:device",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"pciIds":["8086"],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolPciIdError),
+		},
+		{
+			name: "excluded pci id must be vendor:device",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"excludePciIds":["0000:81:00.1"],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolPciIdError),
+		},
+		{
+			name: "driver minimum firmware must be numeric",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e",
+											"minFirmware":"v8.30"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(driverFirmwareError),
+		},
+		{
+			name: "host managed action",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"hostManaged":"unmanage",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "host managed action must be known",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"hostManaged":"ignore",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolHostManagedError),
+		},
+		{
+			name: "irq affinity cpu list",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"irqAffinity":"2-5,8",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "irq affinity pod",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"irqAffinity":"pod",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "irq affinity must be a cpu list",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"irqAffinity":"two",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolIrqAffinityError),
+		},
+		{
+			name: "irq affinity pod requires uds server",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"irqAffinity":"pod",
+									"udsServerDisable":true,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolIrqAffinityUds),
+		},
+		{
+			name: "allowed uids and gids",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"allowedUids":[1000,1500],
+									"allowedGids":[1500],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "negative allowed uid",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"allowedUids":[-1],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolPeerIdError),
+		},
+		{
+			name: "negative allowed gid",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"allowedGids":[-1],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolPeerIdError),
+		},
+		{
+			name: "allowed uids require uds server",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"allowedUids":[1500],
+									"udsServerDisable":true,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolPeerIdUds),
+		},
+		{
+			name: "dpdk uds profile",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsProfile":"dpdk",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "cndp uds profile",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsProfile":"cndp",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "invalid uds profile",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsProfile":"vpp",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolUdsProfileError),
+		},
+		{
+			name: "dpdk uds profile requires uds server",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsProfile":"dpdk",
+									"udsServerDisable":true,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolUdsProfileUds),
+		},
+		{
+			name: "uds legacy path",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsLegacyPath":true,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "uds legacy path requires uds server",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsLegacyPath":true,
+									"udsServerDisable":true,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolUdsLegacyPathUds),
+		},
+		{
+			name: "uds faults",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsFaults":{"delay":500,"delayRate":0.1,"nakRate":0.05,"dropRate":0.01,"truncateRate":0.05,"seed":42},
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "uds fault rate above 1",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsFaults":{"nakRate":1.5},
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(faultRateError),
+		},
+		{
+			name: "negative uds fault delay",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsFaults":{"delay":-1,"delayRate":1},
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(faultDelayError),
+		},
+		{
+			name: "uds faults require uds server",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"udsServerDisable":true,
+									"udsFaults":{"dropRate":0.5},
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(poolUdsFaultsUds),
+		},
+		{
+			name: "pod resources socket",
+			configFile: `{
+							"podResourcesSocket":"/var/lib/rancher/k3s/agent/kubelet/pod-resources/kubelet.sock",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "pod resources socket must be absolute",
+			configFile: `{
+							"podResourcesSocket":"kubelet/pod-resources/kubelet.sock",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(podResSocketValidError),
+		},
+		{
+			name: "pod resources socket must be a socket file",
+			configFile: `{
+							"podResourcesSocket":"/var/lib/kubelet/pod-resources/",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(podResSocketValidError),
+		},
+		{
+			name: "bpf helper socket",
+			configFile: `{
+							"bpfHelperSocket":"/run/afxdp-bpf-helper/helper.sock",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "bpf helper socket must be absolute",
+			configFile: `{
+							"bpfHelperSocket":"helper.sock",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(podResSocketValidError),
+		},
+		{
+			name: "bpf public key",
+			configFile: `{
+							"bpfPublicKey":"/etc/afxdp/bpf.pem",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "bpf public key must be absolute",
+			configFile: `{
+							"bpfPublicKey":"bpf.pem",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(bpfPublicKeyValidError),
+		},
+		{
+			name: "tracing endpoint",
+			configFile: `{
+							"tracingEndpoint":"http://otel-collector.observability:4318",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "tracing endpoint must be a URL",
+			configFile: `{
+							"tracingEndpoint":"otel-collector:4318",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(tracingEndpointValidError),
+		},
+		{
+			name: "selinux label",
+			configFile: `{
+							"selinuxLabel":"system_u:object_r:container_file_t:s0:c1,c2",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "selinux label must be a context",
+			configFile: `{
+							"selinuxLabel":"container_file_t",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(selinuxLabelValidError),
+		},
+		{
+			name: "minimal syscalls",
+			configFile: `{
+							"minimalSyscalls":true,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "minimal syscalls with peer cgroup verification",
+			configFile: `{
+							"minimalSyscalls":true,
+							"requirePeerCgroup":true,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(minimalSyscallsError),
+		},
+		{
+			name: "negative handshake uid",
+			configFile: `{
+							"dropHandshakePrivileges":true,
+							"handshakeUid":-1,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(handshakeIdError),
+		},
+		{
+			name: "minimal syscalls with selinux label",
+			configFile: `{
+							"minimalSyscalls":true,
+							"selinuxLabel":"system_u:object_r:container_file_t:s0",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(minimalSyscallsError),
+		},
+		{
+			name: "minimal syscalls with allowed uids",
+			configFile: `{
+							"minimalSyscalls":true,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"allowedUids":[1500],
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(minimalSyscallsError),
+		},
+		{
+			name: "feature gates",
+			configFile: `{
+							"featureGates":{"QueuePools":true,"JsonUdsProtocol":false},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "unknown feature gate",
+			configFile: `{
+							"featureGates":{"Teleport":true},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("unknown feature gates [Teleport]"),
+		},
+		{
+			name: "audit file",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"auditFile":"afxdp-audit.log",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "audit file must differ from log file",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"auditFile":"afxdp-dp.log",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(auditFileError),
+		},
+		{
+			name: "metrics cardinality",
+			configFile: `{
+							"metricsAddr":":8081",
+							"metricsMaxSeries":5000,
+							"metricsDropLabels":["pod","namespace"],
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "metrics max series too low",
+			configFile: `{
+							"metricsMaxSeries":10,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(metricsMaxSeriesError),
+		},
+		{
+			name: "metrics drop device label",
+			configFile: `{
+							"metricsDropLabels":["device"],
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(metricsDropLabelsError),
+		},
+		{
+			name: "health address",
+			configFile: `{
+							"metricsAddr":":8081",
+							"healthAddr":":8082",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "health address invalid",
+			configFile: `{
+							"healthAddr":"localhost",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(healthAddrValidError),
+		},
+		{
+			name: "health address same as metrics address",
+			configFile: `{
+							"metricsAddr":":8081",
+							"healthAddr":":8081",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(healthAddrMetricsError),
+		},
+		{
+			name: "log file rotation",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"logFileMaxSize":100,
+							"logFileBackups":5,
+							"logFileCompress":true,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "log file rotation disabled",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"logFileMaxSize":-1,
+							"logFileBackups":-1,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "log file max size too large",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"logFileMaxSize":2048,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(logFileMaxSizeError),
+		},
+		{
+			name: "log file backups negative",
+			configFile: `{
+							"logFile":"afxdp-dp.log",
+							"logFileBackups":-2,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(logFileBackupsError),
+		},
+		{
+			name: "log dedup interval",
+			configFile: `{
+							"logDedupInterval":60,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+		},
+		{
+			name: "log dedup interval too long",
+			configFile: `{
+							"logDedupInterval":5000,
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(logDedupError),
+		},
+		{
+			name: "syslog log backend",
+			configFile: `{
+							"logBackend":"syslog",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+		},
+		{
+			name: "invalid log backend",
+			configFile: `{
+							"logBackend":"journal",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(logBackendError),
+		},
+		{
+			name: "log file with syslog log backend",
+			configFile: `{
+							"logBackend":"syslog",
+							"logFile":"afxdp.log",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(logBackendFileError),
+		},
+		{
+			name: "subsystem log levels",
+			configFile: `{
+							"logLevel":"warning",
+							"logLevels":{
+								"uds":"trace",
+								"networking":"error"
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: nil,
+		},
+		{
+			name: "unknown log subsystem",
+			configFile: `{
+							"logLevels":{
+								"handshake":"debug"
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("key not expected"),
+		},
+		{
+			name: "invalid subsystem log level",
+			configFile: `{
+							"logLevels":{
+								"uds":"verbose"
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("must be [trace debug info warn warning error]"),
+		},
+		{
+			name: "versioned config",
+			configFile: `{
+							"version":"v1",
+							"timeouts":{
+								"podCheckInterval":10,
+								"apiServer":15,
+								"podResourcesCacheTTL":2
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+		},
+		{
+			name: "unsupported config version",
+			configFile: `{
+							"version":"v9",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(versionError),
+		},
+		{
+			name: "unknown field in versioned config",
+			configFile: `{
+							"version":"v1",
+							"logFil":"afxdp.log",
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New(`line 3, column 9: json: unknown field "logFil"`),
+		},
+		{
+			name: "timeout too long",
+			configFile: `{
+							"timeouts":{
+								"apiServer":7200
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("line 3: timeouts.apiServer: " + timeoutError),
+		},
+		{
+			name: "timeouts out of order",
+			configFile: `{
+							"timeouts":{
+								"podResourcesCacheTTL":10
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("line 3: timeouts.podResourcesCacheTTL: " + timeoutOrderError + "pod check interval, 5s"),
+		},
+		{
+			name: "timeout exceeds uds idle timeout",
+			configFile: `{
+							"timeouts":{
+								"podResources":30,
+								"shutdown":30
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("line 3: timeouts.podResources: " + timeoutUdsOrderError + "UDS idle timeout, 30s"),
+		},
+		{
+			name: "pool uds timeout within pod resources timeout",
+			configFile: `{
+							"timeouts":{
+								"podResources":40,
+								"udsIdle":60,
+								"shutdown":60
+							},
+							"pools":[
+								{
+									"name":"testPool",
+									"mode":"primary",
+									"UdsTimeout":30,
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("must be greater than the pod resources and API server timeouts, 40s and 5s"),
+		},
+		{
+			name: "pool error with line",
+			configFile: `{
+							"pools":[
+								{
+									"name":"testPool",
+									"drivers":[
+										{
+											"name":"i40e"
+										}
+									]
+								}
+							]
+						}`,
+			expErr: errors.New("line 3: pools[0].mode: " + poolModeRequiredError),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -1202,6 +2567,7 @@ func TestReadConfigFile(t *testing.T) {
 			} else {
 				require.Error(t, tc.expErr, "Unexpected error returned")
 				assert.Contains(t, err.Error(), tc.expErr.Error(), "Unexpected error returned")
+				assert.True(t, errors.Is(err, errdefs.ErrValidationFailed), "Config errors should be validation errors")
 			}
 		})
 	}
@@ -1243,3 +2609,289 @@ func FuzzReadConfigFile(f *testing.F) {
 		readConfigFile(testDir)
 	})
 }
+
+func TestPairBondedDevices(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	network = netHandler
+
+	testCases := []struct {
+		name     string
+		devices  []string
+		bonds    []*networking.Bond
+		expPeers map[string]string
+	}{
+		{
+			name:     "no bonds",
+			devices:  []string{"ens1", "ens2"},
+			expPeers: map[string]string{"ens1": "", "ens2": ""},
+		},
+		{
+			name:    "active-backup bond in pool",
+			devices: []string{"ens1", "ens2", "ens3"},
+			bonds: []*networking.Bond{
+				{Name: "bond0", Mode: "active-backup", Slaves: []string{"ens2", "ens1"}, ActiveSlave: "ens2"},
+			},
+			expPeers: map[string]string{"ens1": "ens2", "ens3": ""},
+		},
+		{
+			name:    "bond peer not in pool",
+			devices: []string{"ens1", "ens3"},
+			bonds: []*networking.Bond{
+				{Name: "bond0", Mode: "active-backup", Slaves: []string{"ens1", "ens2"}},
+			},
+			expPeers: map[string]string{"ens1": "", "ens3": ""},
+		},
+		{
+			name:    "balance-rr bond not paired",
+			devices: []string{"ens1", "ens2"},
+			bonds: []*networking.Bond{
+				{Name: "bond0", Mode: "balance-rr", Slaves: []string{"ens1", "ens2"}},
+			},
+			expPeers: map[string]string{"ens1": "", "ens2": ""},
+		},
+		{
+			name:    "two bonds",
+			devices: []string{"ens1", "ens2", "ens3", "ens4"},
+			bonds: []*networking.Bond{
+				{Name: "bond0", Mode: "active-backup", Slaves: []string{"ens1", "ens3"}},
+				{Name: "bond1", Mode: "active-backup", Slaves: []string{"ens4", "ens2"}},
+			},
+			expPeers: map[string]string{"ens1": "ens3", "ens2": "ens4"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			devices := make(map[string]*networking.Device)
+			for _, name := range tc.devices {
+				devices[name] = networking.CreateTestDevice(name, "primary", "ice", "", "", netHandler)
+			}
+			for _, bond := range tc.bonds {
+				netHandler.SetBond(bond)
+			}
+
+			paired := pairBondedDevices(devices)
+
+			peers := make(map[string]string)
+			for name, device := range paired {
+				peers[name] = device.Peer()
+			}
+			assert.Equal(t, tc.expPeers, peers, "Device pairing does not match")
+
+			for _, bond := range tc.bonds {
+				netHandler.SetBond(&networking.Bond{Name: bond.Name})
+			}
+		})
+	}
+}
+
+func TestPciIdAllowed(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pciId    string
+		allowed  []string
+		excluded []string
+		expAllow bool
+	}{
+		{name: "no lists", pciId: "8086:158b", expAllow: true},
+		{name: "allowed", pciId: "8086:158b", allowed: []string{"8086:158b"}, expAllow: true},
+		{name: "allowed ignoring case", pciId: "8086:158b", allowed: []string{"8086:158B"}, expAllow: true},
+		{name: "not allowed", pciId: "8086:1592", allowed: []string{"8086:158b"}, expAllow: false},
+		{name: "excluded", pciId: "8086:1592", excluded: []string{"8086:1592"}, expAllow: false},
+		{name: "not excluded", pciId: "8086:158b", excluded: []string{"8086:1592"}, expAllow: true},
+		{name: "allowed and excluded", pciId: "8086:158b", allowed: []string{"8086:158b"}, excluded: []string{"8086:158b"}, expAllow: false},
+		{name: "no pci id with allow-list", pciId: "", allowed: []string{"8086:158b"}, expAllow: false},
+		{name: "no pci id with deny-list", pciId: "", excluded: []string{"8086:158b"}, expAllow: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expAllow, pciIdAllowed(tc.pciId, tc.allowed, tc.excluded), "Unexpected result")
+		})
+	}
+}
+
+func TestCheckHostManaged(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	network = netHandler
+
+	testCases := []struct {
+		name         string
+		managers     []string
+		action       string
+		expIncluded  bool
+		expManagedBy []string
+	}{
+		{
+			name:        "unmanaged device",
+			action:      "exclude",
+			expIncluded: true,
+		},
+		{
+			name:         "managed device warn",
+			managers:     []string{networking.NetworkManager},
+			action:       "warn",
+			expIncluded:  true,
+			expManagedBy: []string{networking.NetworkManager},
+		},
+		{
+			name:        "managed device unmanage",
+			managers:    []string{networking.NetworkManager, networking.SystemdNetworkd},
+			action:      "unmanage",
+			expIncluded: true,
+		},
+		{
+			name:         "managed device exclude",
+			managers:     []string{networking.SystemdNetworkd},
+			action:       "exclude",
+			expIncluded:  false,
+			expManagedBy: []string{networking.SystemdNetworkd},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			netHandler.SetHostNetworkManagers("ens1", tc.managers)
+			pool := &configFile_Pool{Name: "pool1", HostManaged: tc.action}
+
+			included := checkHostManaged("ens1", pool)
+			assert.Equal(t, tc.expIncluded, included, "Device inclusion does not match")
+
+			managers, err := netHandler.GetHostNetworkManagers("ens1")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expManagedBy, managers, "Host network managers do not match")
+
+			netHandler.SetHostNetworkManagers("ens1", nil)
+		})
+	}
+}
+
+func TestGetPluginConfigPodResSock(t *testing.T) {
+	testCases := []struct {
+		name    string
+		cfgSock string
+		envSock string
+		expSock string
+		expErr  bool
+	}{
+		{
+			name:    "default",
+			expSock: constants.PodResources.DefaultSocket,
+		},
+		{
+			name:    "config file",
+			cfgSock: "/var/snap/microk8s/common/var/lib/kubelet/pod-resources/kubelet.sock",
+			expSock: "/var/snap/microk8s/common/var/lib/kubelet/pod-resources/kubelet.sock",
+		},
+		{
+			name:    "env var overrides config file",
+			cfgSock: "/var/snap/microk8s/common/var/lib/kubelet/pod-resources/kubelet.sock",
+			envSock: "/var/lib/rancher/rke2/agent/kubelet/pod-resources/kubelet.sock",
+			expSock: "/var/lib/rancher/rke2/agent/kubelet/pod-resources/kubelet.sock",
+		},
+		{
+			name:    "invalid env var",
+			envSock: "kubelet.sock",
+			expErr:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgFile = &configFile{PodResSock: tc.cfgSock}
+			defer func() { cfgFile = nil }()
+
+			if tc.envSock != "" {
+				os.Setenv(constants.PodResources.SocketEnvVar, tc.envSock)
+				defer os.Unsetenv(constants.PodResources.SocketEnvVar)
+			}
+
+			cfg, err := GetPluginConfig("")
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expSock, cfg.PodResSock, "Unexpected pod resources socket")
+		})
+	}
+}
+
+func TestGetPluginConfigHandshakeIds(t *testing.T) {
+	cfgFile = &configFile{DropPrivileges: true}
+	defer func() { cfgFile = nil }()
+
+	cfg, err := GetPluginConfig("")
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, constants.Uds.HandshakeUid, cfg.HandshakeUid, "Unset handshake user should default")
+	assert.Equal(t, constants.Uds.HandshakeGid, cfg.HandshakeGid, "Unset handshake group should default")
+
+	cfgFile = &configFile{DropPrivileges: true, HandshakeUid: 1000, HandshakeGid: 2000}
+	cfg, err = GetPluginConfig("")
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1000, cfg.HandshakeUid, "Unexpected handshake user")
+	assert.Equal(t, 2000, cfg.HandshakeGid, "Unexpected handshake group")
+}
+
+func TestGetLogLevel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		cfgLevel string
+		envLevel string
+		expLevel string
+		expErr   bool
+	}{
+		{
+			name: "not set",
+		},
+		{
+			name:     "config file",
+			cfgLevel: "warn",
+			expLevel: "warn",
+		},
+		{
+			name:     "env var overrides config file",
+			cfgLevel: "info",
+			envLevel: "trace",
+			expLevel: "trace",
+		},
+		{
+			name:     "invalid env var",
+			cfgLevel: "info",
+			envLevel: "verbose",
+			expErr:   true,
+		},
+		{
+			name:     "invalid config file",
+			cfgLevel: "verbose",
+			expErr:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfgFile = nil
+			dir, dirErr := ioutil.TempDir("/tmp", "test-afxdp-")
+			require.NoError(t, dirErr, "Can't create temporary directory")
+			defer os.RemoveAll(dir)
+
+			testFile := filepath.Join(dir, "tmpfile")
+			content := []byte(`{"logLevel":"` + tc.cfgLevel + `","pools":[]}`)
+			require.NoError(t, ioutil.WriteFile(testFile, content, 0666), "Can't create temporary file")
+
+			if tc.envLevel != "" {
+				os.Setenv(constants.Logging.LevelEnvVar, tc.envLevel)
+				defer os.Unsetenv(constants.Logging.LevelEnvVar)
+			}
+
+			level, _, err := GetLogLevel(testFile)
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expLevel, level, "Unexpected log level")
+			assert.Nil(t, cfgFile, "Reloading the log level should not replace the config")
+		})
+	}
+}
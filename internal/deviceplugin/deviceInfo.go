@@ -0,0 +1,48 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+)
+
+var deviceInfo = metrics.NewGaugeVec("device_info",
+	"Driver and firmware version of a pool device, the value is always 1.", metrics.LabelPool, metrics.LabelDevice, metrics.LabelPci, "driver", "firmware")
+
+/*
+reportDeviceInfo publishes the driver and firmware version of each pool device.
+Several AF_XDP zero copy issues are firmware dependent, so the firmware version is
+made visible alongside the other per-device metrics.
+*/
+func (pm *PoolManager) reportDeviceInfo() {
+	deviceInfo.DeleteMatching(metrics.LabelPool, pm.Name)
+
+	for name, device := range pm.Devices {
+		driver, err := device.Driver()
+		if err != nil {
+			logging.Warningf("Pool %s: unable to get driver of device %s: %v", pm.Name, name, err)
+		}
+
+		firmware, err := device.Firmware()
+		if err != nil {
+			logging.Warningf("Pool %s: unable to get firmware version of device %s: %v", pm.Name, name, err)
+		}
+
+		logging.Debugf("Pool %s: device %s driver %s firmware %s", pm.Name, name, driver, firmware)
+		deviceInfo.Set(1, pm.Name, name, pm.devicePci(name), driver, firmware)
+	}
+}
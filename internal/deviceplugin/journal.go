@@ -0,0 +1,138 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+RollbackJournal undoes the host networking changes of operations that never finished, such as
+an allocation interrupted by a crash of the device plugin or the CNI, so the node is not left in
+a state the plugins cannot recover from. Entries are undone most recent first. It is called once
+at device plugin startup, before devices are discovered.
+*/
+func RollbackJournal(netHandler networking.Handler, bpfHandler bpf.Handler) {
+	entries, err := netHandler.GetJournal()
+	if err != nil {
+		logging.Errorf("Error reading journal, unfinished host changes cannot be rolled back: %v", err)
+		return
+	}
+
+	var rolledBack []int
+	now := time.Now()
+
+	for _, entry := range entries {
+		if !journalStale(entry, now) {
+			logging.Infof("Journal entry %d (%s of device %s) may still be in progress, not rolling back", entry.Id, entry.Op, entry.Device)
+			continue
+		}
+
+		logging.Warningf("Rolling back unfinished %s of device %s, started by %s at %s", entry.Op, entry.Device, entry.Source, entry.Started.Format(time.RFC3339))
+		if err := rollbackEntry(entry, netHandler, bpfHandler); err != nil {
+			logging.Errorf("Error rolling back %s of device %s, manual intervention may be required: %v", entry.Op, entry.Device, err)
+		}
+		rolledBack = append(rolledBack, entry.Id)
+	}
+
+	if len(rolledBack) == 0 {
+		return
+	}
+	if err := netHandler.JournalEnd(rolledBack...); err != nil {
+		logging.Errorf("Error removing rolled back entries from journal: %v", err)
+	}
+}
+
+/*
+journalStale returns true if an unfinished journal entry can be rolled back. Entries written by
+the device plugin belong to a previous instance, as only one runs per node. Entries written by the
+CNI are only stale once older than any CNI invocation could run, as the CNI may still be running.
+*/
+func journalStale(entry *networking.JournalEntry, now time.Time) bool {
+	if entry.Source == networking.JournalSourceDevicePlugin {
+		return true
+	}
+	return now.Sub(entry.Started) > time.Duration(constants.Journal.StaleAfter)*time.Second
+}
+
+func rollbackEntry(entry *networking.JournalEntry, netHandler networking.Handler, bpfHandler bpf.Handler) error {
+	if entry.Op == networking.JournalNetnsMove && !featureEnabled(privileges.PodNetns) {
+		return fmt.Errorf("moving device out of pod network namespace %s needs CAP_SYS_ADMIN", entry.Netns)
+	}
+	return networking.RollbackJournalEntry(entry, netHandler, bpfHandler.Cleanbpf)
+}
+
+/*
+journal collects the journal entries written during an allocation. Once the allocation has
+finished they are ended together, or, if it failed, the changes they record are rolled back.
+Journal errors are logged rather than failing the allocation, as they only affect recovery
+from a crash.
+*/
+type journal struct {
+	netHandler networking.Handler
+	bpfHandler bpf.Handler
+	entries    []*networking.JournalEntry
+}
+
+/*
+begin writes a host networking change to the journal ahead of making it. The entry is kept
+even if it could not be written, so the change is still rolled back if the allocation fails.
+*/
+func (j *journal) begin(entry *networking.JournalEntry) {
+	entry.Source = networking.JournalSourceDevicePlugin
+	j.entries = append(j.entries, entry)
+	if _, err := j.netHandler.JournalBegin(entry); err != nil {
+		logging.Warningf("Error writing %s of device %s to journal: %v", entry.Op, entry.Device, err)
+	}
+}
+
+/*
+finish ends the journal entries of a successful allocation, or rolls back the changes they
+record, most recent first, if the allocation failed with err.
+*/
+func (j *journal) finish(err error) {
+	entries := j.entries
+	j.entries = nil
+	if len(entries) == 0 {
+		return
+	}
+
+	if err != nil {
+		logging.Warningf("Allocation failed, rolling back its host changes: %v", err)
+		networking.RollbackJournalEntries(entries, j.netHandler, j.bpfHandler.Cleanbpf)
+		return
+	}
+
+	var ids []int
+	for _, entry := range entries {
+		if entry.Id != 0 {
+			ids = append(ids, entry.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := j.netHandler.JournalEnd(ids...); err != nil {
+		logging.Warningf("Error ending journal entries: %v", err)
+	}
+}
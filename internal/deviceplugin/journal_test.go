@@ -0,0 +1,125 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackJournal(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	now := time.Now()
+
+	testCases := []struct {
+		name       string
+		entries    []*networking.JournalEntry
+		expPending []string
+	}{
+		{
+			name: "empty journal",
+		},
+		{
+			name: "device plugin entries always rolled back",
+			entries: []*networking.JournalEntry{
+				{Op: networking.JournalChannels, Source: networking.JournalSourceDevicePlugin, Device: "ens1", Channels: 8, Started: now},
+				{Op: networking.JournalXdpAttach, Source: networking.JournalSourceDevicePlugin, Device: "ens1", Started: now},
+			},
+		},
+		{
+			name: "recent cni entries left in progress",
+			entries: []*networking.JournalEntry{
+				{Op: networking.JournalEthtool, Source: networking.JournalSourceCni, Device: "ens1", Owner: "abc", Started: now.Add(-time.Hour)},
+				{Op: networking.JournalPromiscuous, Source: networking.JournalSourceCni, Device: "ens2", Owner: "def", Started: now},
+				{Op: networking.JournalNetnsMove, Source: networking.JournalSourceCni, Device: "ens2", Owner: "def", Netns: "/var/run/netns/cni-1", Started: now},
+			},
+			expPending: []string{"ens2", "ens2"},
+		},
+		{
+			name: "unknown operation removed",
+			entries: []*networking.JournalEntry{
+				{Op: "rename", Source: networking.JournalSourceDevicePlugin, Device: "ens1", Started: now},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, entry := range tc.entries {
+				_, err := netHandler.JournalBegin(entry)
+				require.NoError(t, err, "Unexpected error")
+			}
+
+			RollbackJournal(netHandler, bpf.NewFakeHandler())
+
+			pending, err := netHandler.GetJournal()
+			require.NoError(t, err, "Unexpected error")
+
+			var pendingDevices []string
+			var ids []int
+			for _, entry := range pending {
+				pendingDevices = append(pendingDevices, entry.Device)
+				ids = append(ids, entry.Id)
+			}
+			assert.Equal(t, tc.expPending, pendingDevices, "Entries left in the journal do not match")
+
+			require.NoError(t, netHandler.JournalEnd(ids...), "Unexpected error")
+		})
+	}
+}
+
+func TestRollbackJournalRestoresRss(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	netHandler.SetHostDevices(map[string][]string{"i40e": {"ens1"}})
+	require.NoError(t, netHandler.SetRss("ens1", 0, 4, "01:02:03:04:05:06:07:08"), "Unexpected error")
+
+	_, err := netHandler.JournalBegin(&networking.JournalEntry{Op: networking.JournalRss, Source: networking.JournalSourceDevicePlugin,
+		Device: "ens1", RssHashKey: "7d:3c:f6:24:5a:9b:0e:11", Started: time.Now()})
+	require.NoError(t, err, "Unexpected error")
+
+	RollbackJournal(netHandler, bpf.NewFakeHandler())
+
+	rss, err := netHandler.GetRss("ens1")
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "7d:3c:f6:24:5a:9b:0e:11", rss.HashKey, "RSS hash key not restored")
+	pending, err := netHandler.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	assert.Empty(t, pending, "RSS entry left in the journal")
+}
+
+func TestRollbackJournalRestoresMtu(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	netHandler.SetHostDevices(map[string][]string{"i40e": {"ens1"}})
+	require.NoError(t, netHandler.SetMtu("ens1", networking.MaxMtu(4096)), "Unexpected error")
+
+	_, err := netHandler.JournalBegin(&networking.JournalEntry{Op: networking.JournalMtu, Source: networking.JournalSourceDevicePlugin,
+		Device: "ens1", Mtu: 9000, Started: time.Now()})
+	require.NoError(t, err, "Unexpected error")
+
+	RollbackJournal(netHandler, bpf.NewFakeHandler())
+
+	mtu, err := netHandler.GetMtu("ens1")
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 9000, mtu, "MTU not restored")
+	pending, err := netHandler.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	assert.Empty(t, pending, "MTU entry left in the journal")
+}
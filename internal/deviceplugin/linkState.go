@@ -0,0 +1,82 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	logging "github.com/sirupsen/logrus"
+)
+
+var deviceLinkUp = metrics.NewGaugeVec("device_link_up",
+	"Whether a pool device in the host network namespace is up (1) or down (0).", metrics.LabelPool, metrics.LabelDevice, metrics.LabelPci)
+
+/*
+watchLinkState subscribes to link events for the pool devices and tracks their link state
+until the StopSignal channel is closed. Devices moved into a pod network namespace are no
+longer visible on the host and drop out of the link state metric until they are returned.
+*/
+func (pm *PoolManager) watchLinkState() {
+	sub, err := pm.NetHandler.SubscribeLinkEvents(pm.deviceNames()...)
+	if err != nil {
+		logging.Warningf("Pool %s: unable to watch device link state: %v", pm.Name, err)
+		return
+	}
+
+	go func() {
+		defer sub.Close()
+		for {
+			select {
+			case <-pm.StopSignal:
+				return
+			case event, ok := <-sub.Events:
+				if !ok {
+					return
+				}
+				pm.handleLinkEvent(event)
+			}
+		}
+	}()
+}
+
+func (pm *PoolManager) handleLinkEvent(event networking.LinkEvent) {
+	if event.Deleted {
+		logging.Debugf("Pool %s: device %s left the host network namespace", pm.Name, event.Device)
+		deviceLinkUp.Delete(pm.Name, event.Device, pm.devicePci(event.Device))
+		delete(pm.linkDown, event.Device)
+		return
+	}
+
+	if event.Up() {
+		logging.Debugf("Pool %s: device %s link is up", pm.Name, event.Device)
+		deviceLinkUp.Set(1, pm.Name, event.Device, pm.devicePci(event.Device))
+		delete(pm.linkDown, event.Device)
+	} else {
+		logging.Infof("Pool %s: device %s link is down, admin up: %t, oper state: %s", pm.Name, event.Device, event.AdminUp, event.OperState)
+		deviceLinkUp.Set(0, pm.Name, event.Device, pm.devicePci(event.Device))
+		if !pm.linkDown[event.Device] {
+			if pm.linkDown == nil {
+				pm.linkDown = make(map[string]bool)
+			}
+			pm.linkDown[event.Device] = true
+			recordNodeEvent(constants.Events.DeviceLinkDown, fmt.Sprintf("Device %s of pool %s link is down, admin up: %t, oper state: %s. Pods allocated the device will not receive traffic",
+				event.Device, pm.DevicePrefix+"/"+pm.Name, event.AdminUp, event.OperState))
+		}
+	}
+}
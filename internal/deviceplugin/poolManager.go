@@ -20,14 +20,25 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/crash"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/status"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tracing"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
 	logging "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
@@ -36,6 +47,107 @@ import (
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
+var (
+	poolInfo = metrics.NewGaugeVec("pool_info",
+		"Mode and resource name of a pool, the value is always 1.", metrics.LabelPool, "mode", "resource")
+	poolAllocations = metrics.NewCounterVec("pool_allocations_total",
+		"Number of allocate requests handled by a pool, by outcome.", metrics.LabelPool, "outcome")
+	poolAllocatedDevices = metrics.NewCounterVec("pool_allocated_devices_total",
+		"Number of devices allocated to containers by a pool.", metrics.LabelPool)
+	poolAllocateDuration = metrics.NewHistogramVec("pool_allocate_duration_seconds",
+		"Duration of allocate requests handled by a pool, by outcome.", metrics.DurationBuckets, metrics.LabelPool, "outcome")
+	poolRegistrations = metrics.NewCounterVec("pool_registrations_total",
+		"Number of times a pool has registered with the kubelet, by outcome.", metrics.LabelPool, "outcome")
+)
+
+/*
+eventRecorder reports allocation failures and devices going down as Kubernetes Events, nil if disabled.
+*/
+var eventRecorder apiserver.Handler
+
+/*
+SetEventRecorder enables reporting allocation failures and devices going down as Kubernetes
+Events on the node.
+*/
+func SetEventRecorder(handler apiserver.Handler) {
+	eventRecorder = handler
+}
+
+/*
+detachXdpOnShutdown is set if XDP programs are detached from pool devices left in the host network
+namespace when the device plugin shuts down.
+*/
+var detachXdpOnShutdown bool
+
+/*
+SetDetachXdpOnShutdown sets whether XDP programs are detached from pool devices left in the host
+network namespace when a PoolManager is terminated. Devices allocated to running pods have been
+moved into the pod network namespace and are left untouched.
+*/
+func SetDetachXdpOnShutdown(detach bool) {
+	detachXdpOnShutdown = detach
+}
+
+/*
+features are the features of the privileges package the device plugin has the capabilities for.
+Until SetFeatures is called every feature is enabled.
+*/
+var features map[string]bool
+
+/*
+SetFeatures sets the features the device plugin has the capabilities for, as returned by
+privileges.Check. Pools loading BPF programs are skipped without the bpf feature, and per-queue
+metrics are not collected without the podNetns feature.
+*/
+func SetFeatures(enabled map[string]bool) {
+	features = enabled
+}
+
+/*
+featureEnabled returns true unless the named feature has been disabled by SetFeatures.
+*/
+func featureEnabled(name string) bool {
+	enabled, ok := features[name]
+	return !ok || enabled
+}
+
+/*
+kubeletTimeout is how long to wait for the kubelet device plugin gRPC, and shutdownTimeout how
+long calls in progress are given to finish when a PoolManager is terminated.
+*/
+var (
+	kubeletTimeout  = time.Duration(constants.Plugins.DevicePlugin.KubeletTimeout) * time.Second
+	shutdownTimeout = time.Duration(constants.Plugins.DevicePlugin.ShutdownTimeout) * time.Second
+)
+
+/*
+SetKubeletTimeout sets how long to wait connecting to and registering with the kubelet, and for
+the test connection to a pool's gRPC server. It must be called before any PoolManager is started.
+*/
+func SetKubeletTimeout(t time.Duration) {
+	kubeletTimeout = t
+}
+
+/*
+SetShutdownTimeout sets how long gRPC calls in progress are given to finish when a PoolManager is
+terminated. It must be called before any PoolManager is started.
+*/
+func SetShutdownTimeout(t time.Duration) {
+	shutdownTimeout = t
+}
+
+/*
+recordNodeEvent reports a Kubernetes Event on the node, if events are enabled.
+*/
+func recordNodeEvent(reason string, message string) {
+	if eventRecorder == nil {
+		return
+	}
+	if err := eventRecorder.CreateEvent(&apiserver.Event{Reason: reason, Message: message}); err != nil {
+		logging.Warningf("Unable to report %s event: %v", reason, err)
+	}
+}
+
 /*
 PoolManager represents an manages the pool of devices.
 Each PoolManager registers with Kubernetes as a different device type.
@@ -45,6 +157,7 @@ type PoolManager struct {
 	Mode             string
 	Devices          map[string]*networking.Device
 	UpdateSignal     chan bool
+	StopSignal       chan bool
 	DpAPISocket      string
 	DpAPIEndpoint    string
 	UdsServerDisable bool
@@ -53,18 +166,36 @@ type PoolManager struct {
 	UdsFuzz          bool
 	UID              string
 	EthtoolFilters   []string
+	Promiscuous      bool
+	UmemFrameSize    int
+	AdjustMtu        bool
+	IrqCpus          []int
+	IrqPodCpus       bool
+	AllowedUids      []int
+	AllowedGids      []int
+	UdsProfile       string
+	UdsLegacyPath    bool
+	UdsFaults        *udsserver.Faults
 	DpAPIServer      *grpc.Server
 	ServerFactory    udsserver.ServerFactory
 	BpfHandler       bpf.Handler
 	NetHandler       networking.Handler
+	PodResHandler    resourcesapi.Handler
+	linkDown         map[string]bool
+	lifecycle        *sync.Mutex // serialises restarting the gRPC server of the pool with terminating the pool
 }
 
 func NewPoolManager(config PoolConfig) PoolManager {
+	if config.UmemFrameSize == 0 {
+		config.UmemFrameSize = constants.Afxdp.FrameSizeDefault
+	}
+
 	return PoolManager{
 		Name:             config.Name,
 		Mode:             config.Mode,
 		Devices:          config.Devices,
 		UpdateSignal:     make(chan bool),
+		StopSignal:       make(chan bool),
 		DpAPISocket:      pluginapi.DevicePluginPath + constants.Plugins.DevicePlugin.DevicePrefix + "-" + config.Name + ".sock",
 		DpAPIEndpoint:    constants.Plugins.DevicePlugin.DevicePrefix + "-" + config.Name + ".sock",
 		UdsServerDisable: config.UdsServerDisable,
@@ -73,6 +204,17 @@ func NewPoolManager(config PoolConfig) PoolManager {
 		UdsFuzz:          config.UdsFuzz,
 		UID:              strconv.Itoa(config.UID),
 		EthtoolFilters:   config.EthtoolCmds,
+		Promiscuous:      config.Promiscuous,
+		UmemFrameSize:    config.UmemFrameSize,
+		AdjustMtu:        config.AdjustMtu,
+		IrqCpus:          config.IrqCpus,
+		IrqPodCpus:       config.IrqPodCpus,
+		AllowedUids:      config.AllowedUids,
+		AllowedGids:      config.AllowedGids,
+		UdsProfile:       config.UdsProfile,
+		UdsLegacyPath:    config.UdsLegacyPath,
+		UdsFaults:        config.UdsFaults,
+		lifecycle:        &sync.Mutex{},
 	}
 }
 
@@ -83,29 +225,49 @@ func (pm *PoolManager) Init(config PoolConfig) error {
 	pm.ServerFactory = udsserver.NewServerFactory()
 	pm.BpfHandler = bpf.NewHandler()
 	pm.NetHandler = networking.NewHandler()
+	pm.PodResHandler = resourcesapi.NewHandler()
 
 	if err := pm.startGRPC(); err != nil {
 		return err
 	}
 	logging.Infof("Pool "+pm.DevicePrefix+"/%s started serving", pm.Name)
 
-	if err := pm.registerWithKubelet(); err != nil {
+	if err := pm.register(); err != nil {
 		return err
 	}
-	logging.Infof("Pool "+pm.DevicePrefix+"/%s registered with Kubelet", pm.Name)
 
 	if len(pm.Devices) > 0 {
 		pm.UpdateSignal <- true
 	}
 
+	poolInfo.Set(1, pm.Name, pm.Mode, pm.DevicePrefix+"/"+pm.Name)
+	pm.watchLinkState()
+	pm.reportDeviceInfo()
+	pm.watchAllocatable()
+	pm.watchRegistration(time.Duration(constants.Plugins.DevicePlugin.RegisterCheck) * time.Second)
+
+	if metrics.Enabled() && featureEnabled(privileges.PodNetns) {
+		go pm.collectQueueStats()
+	}
+
 	return nil
 }
 
 /*
-Terminate is called it terminate the PoolManager.
+Terminate is called it terminate the PoolManager. The devices are reported unhealthy to the
+kubelet, so no more pods are scheduled to the pool, and allocations in progress are given time
+to finish before the gRPC server is stopped.
 */
 func (pm *PoolManager) Terminate() error {
+	pm.lifecycle.Lock()
+	defer pm.lifecycle.Unlock()
+
+	close(pm.StopSignal)
+	poolInfo.DeleteMatching(metrics.LabelPool, pm.Name)
 	pm.stopGRPC()
+	if detachXdpOnShutdown {
+		pm.detachXdp()
+	}
 	if err := pm.cleanup(); err != nil {
 		logging.Infof("Cleanup error: %v", err)
 	}
@@ -125,16 +287,35 @@ func (pm *PoolManager) ListAndWatch(empty *pluginapi.Empty,
 	logging.Debugf("Pool "+pm.DevicePrefix+"/%s ListAndWatch started", pm.Name)
 
 	for {
-		<-pm.UpdateSignal
-		resp := new(pluginapi.ListAndWatchResponse)
-
-		for devName := range pm.Devices {
-			resp.Devices = append(resp.Devices, &pluginapi.Device{ID: devName, Health: pluginapi.Healthy})
+		select {
+		case <-pm.UpdateSignal:
+			pm.sendDevices(stream, pluginapi.Healthy)
+		case <-pm.StopSignal:
+			pm.sendDevices(stream, pluginapi.Unhealthy)
+			logging.Debugf("Pool "+pm.DevicePrefix+"/%s ListAndWatch stopped", pm.Name)
+			return nil
+		case <-stream.Context().Done():
+			logging.Debugf("Pool "+pm.DevicePrefix+"/%s ListAndWatch stream closed by the kubelet", pm.Name)
+			return nil
 		}
+	}
+}
 
-		if err := stream.Send(resp); err != nil {
-			logging.Errorf("Failed to send stream to kubelet: %v", err)
-		}
+/*
+sendDevices sends the list of devices to the kubelet, with the given health. A panic is recovered,
+so the stream carries on with the next update rather than taking down the device plugin.
+*/
+func (pm *PoolManager) sendDevices(stream pluginapi.DevicePlugin_ListAndWatchServer, health string) {
+	defer crash.Recover("list and watch")
+
+	resp := new(pluginapi.ListAndWatchResponse)
+
+	for devName := range pm.Devices {
+		resp.Devices = append(resp.Devices, &pluginapi.Device{ID: devName, Health: health})
+	}
+
+	if err := stream.Send(resp); err != nil {
+		logging.Errorf("Failed to send stream to kubelet: %v", err)
 	}
 }
 
@@ -146,13 +327,60 @@ the Device available in the container.
 */
 func (pm *PoolManager) Allocate(ctx context.Context,
 	rqt *pluginapi.AllocateRequest) (*pluginapi.AllocateResponse, error) {
+	start := time.Now()
+	var devices []string
+	for _, crqt := range rqt.ContainerRequests {
+		devices = append(devices, crqt.DevicesIDs...)
+	}
+
+	span := tracing.Start("Allocate", nil)
+	span.SetAttribute("pool", pm.DevicePrefix+"/"+pm.Name)
+	span.SetAttribute("devices", strings.Join(devices, ","))
+	response, err := pm.allocate(rqt, span)
+	span.End(err)
+
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+		recordNodeEvent(constants.Events.AllocateFailed, fmt.Sprintf("Pool %s failed to allocate devices %s, the pod will not start: %v",
+			pm.DevicePrefix+"/"+pm.Name, strings.Join(devices, ", "), err))
+	} else {
+		for _, crqt := range rqt.ContainerRequests {
+			poolAllocatedDevices.Add(float64(len(crqt.DevicesIDs)), pm.Name)
+		}
+	}
+	poolAllocations.Add(1, pm.Name, outcome)
+	poolAllocateDuration.Observe(time.Since(start).Seconds(), pm.Name, outcome)
+
+	return response, err
+}
+
+/*
+allocate prepares the requested devices and creates the UDS server of the pod. If the allocation
+fails, the host changes made so far are rolled back and the socket directory created for the pod
+is removed, as the server is never started to remove it.
+*/
+func (pm *PoolManager) allocate(rqt *pluginapi.AllocateRequest, span *tracing.Span) (_ *pluginapi.AllocateResponse, err error) {
 	response := pluginapi.AllocateResponse{}
 	var udsServer udsserver.Server
 	var udsPath string
-	var err error
+	var udsDevices []string
 
 	logging.Debugf("New allocate request on pool %s", pm.Name)
 
+	j := &journal{netHandler: pm.NetHandler, bpfHandler: pm.BpfHandler}
+	defer func() { j.finish(err) }()
+
+	defer func() {
+		if err == nil || udsPath == "" {
+			return
+		}
+		dir := filepath.Dir(udsPath)
+		if rmErr := os.Remove(dir); rmErr != nil && !os.IsNotExist(rmErr) {
+			logging.Warningf("Error removing socket directory %s: %v", dir, rmErr)
+		}
+	}()
+
 	if !pm.UdsServerDisable {
 		logging.Infof("Creating new UDS server")
 		udsServer, udsPath, err = pm.ServerFactory.CreateServer(pm.DevicePrefix+"/"+pm.Name, pm.UID, pm.UdsTimeout, pm.UdsFuzz)
@@ -160,6 +388,19 @@ func (pm *PoolManager) Allocate(ctx context.Context,
 			logging.Errorf("Error Creating new UDS server: %v", err)
 			return &response, err
 		}
+		if pm.IrqPodCpus {
+			udsServer.SetPodIrqAffinity()
+		}
+		if len(pm.AllowedUids) != 0 || len(pm.AllowedGids) != 0 {
+			udsServer.SetAllowedPeers(pm.AllowedUids, pm.AllowedGids)
+		}
+		if pm.UdsProfile == constants.Uds.ProfileDpdk {
+			udsServer.SetDpdkProfile()
+		}
+		if pm.UdsFaults != nil {
+			udsServer.SetFaults(*pm.UdsFaults)
+		}
+		udsServer.SetTrace(span)
 	}
 
 	//loop each container request
@@ -167,12 +408,21 @@ func (pm *PoolManager) Allocate(ctx context.Context,
 		cresp := new(pluginapi.ContainerAllocateResponse)
 		envs := make(map[string]string)
 
+		// the socket directory holds only the socket of this pod, and survives the socket being
+		// recreated, the socket itself is only mounted at the legacy path if the pool asks for it
 		if !pm.UdsServerDisable {
 			cresp.Mounts = append(cresp.Mounts, &pluginapi.Mount{
-				HostPath:      udsPath,
-				ContainerPath: constants.Uds.PodPath,
+				HostPath:      filepath.Dir(udsPath),
+				ContainerPath: constants.Uds.PodDir,
 				ReadOnly:      false,
 			})
+			if pm.UdsLegacyPath {
+				cresp.Mounts = append(cresp.Mounts, &pluginapi.Mount{
+					HostPath:      udsPath,
+					ContainerPath: constants.Uds.PodPath,
+					ReadOnly:      false,
+				})
+			}
 		}
 
 		//loop each device request per container
@@ -195,12 +445,35 @@ func (pm *PoolManager) Allocate(ctx context.Context,
 					logging.Errorf("Error creating CDQ subfunction: %v", err)
 					return &response, err
 				}
+			case "tap":
+				logging.Debugf("Tap mode")
 			default:
 				err := fmt.Errorf("unsupported pool mode: %s", pm.Mode)
 				logging.Errorf("%v", err)
 				return &response, err
 			}
 
+			driver, err := device.Driver()
+			if err != nil {
+				logging.Errorf("Error getting driver of device %s: %v", device.Name(), err)
+				return &response, err
+			}
+
+			if err := pm.configureDriver(device.Name(), driver, j); err != nil {
+				logging.Errorf("%v", err)
+				return &response, err
+			}
+
+			restoreMtu, err := pm.checkMtu(device.Name(), j)
+			if err != nil {
+				logging.Errorf("%v", err)
+				return &response, err
+			}
+			// the original MTU is kept should the MTU not have been restored since it was lowered
+			if restoreMtu != 0 {
+				device.SetRestoreMtu(restoreMtu)
+			}
+
 			logging.Debugf("Cycling state of device %s", device.Name())
 			if err := device.Cycle(); err != nil {
 				logging.Errorf("Error cycling the state of device %s: %v", device.Name(), err)
@@ -209,17 +482,44 @@ func (pm *PoolManager) Allocate(ctx context.Context,
 
 			if !pm.UdsServerDisable {
 				logging.Infof("Loading BPF program on device: %s", device.Name())
-				fd, err := pm.BpfHandler.LoadBpfSendXskMap(device.Name())
+				j.begin(&networking.JournalEntry{Op: networking.JournalXdpAttach, Device: device.Name()})
+				fd, err := pm.loadBpf(device.Name(), span)
 				if err != nil {
 					logging.Errorf("Error loading BPF Program on interface %s: %v", device.Name(), err)
 					return &response, err
 				}
 				logging.Infof("BPF program loaded on: %s File descriptor: %s", device.Name(), strconv.Itoa(fd))
 				udsServer.AddDevice(device.Name(), fd)
+				udsDevices = append(udsDevices, device.Name())
+
+				// the DPDK af_xdp PMD looks for a socket for each interface, in a directory named after it
+				if pm.UdsProfile == constants.Uds.ProfileDpdk {
+					cresp.Mounts = append(cresp.Mounts, &pluginapi.Mount{
+						HostPath:      filepath.Dir(udsPath),
+						ContainerPath: constants.Uds.DpdkPodDir + device.Name() + "/",
+						ReadOnly:      false,
+					})
+				}
 			}
 
-			if pm.EthtoolFilters != nil {
+			if peer := device.Peer(); peer != "" {
+				peerRestoreMtu, err := pm.allocatePeer(device.Name(), peer, udsServer, j, span)
+				if err != nil {
+					logging.Errorf("Error allocating bond peer %s of device %s: %v", peer, device.Name(), err)
+					return &response, err
+				}
+				if peerRestoreMtu != 0 {
+					device.SetPeerRestoreMtu(peerRestoreMtu)
+				}
+			}
+
+			if len(pm.IrqCpus) > 0 {
+				pm.pinIrqs(device)
+			}
+
+			if pm.EthtoolFilters != nil || pm.Promiscuous || device.Peer() != "" || device.RestoreMtu() != 0 {
 				device.SetEthtoolFilter(pm.EthtoolFilters)
+				device.SetPromiscuous(pm.Promiscuous)
 				if err = pm.NetHandler.WriteDeviceFile(device, constants.DeviceFile.Directory+constants.DeviceFile.Name); err != nil {
 					logging.Debugf("Error writing to device file %v", err)
 					return &response, err
@@ -234,18 +534,45 @@ func (pm *PoolManager) Allocate(ctx context.Context,
 		} else {
 			logging.Debugf("Container environment variables: %s", envsPrint)
 		}
+		// the token is added once the environment is logged, so it never appears in the logs
+		if !pm.UdsServerDisable {
+			envs[constants.Uds.TokenEnvVar] = udsServer.Token()
+		}
 		cresp.Envs = envs
+		cresp.Annotations = map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()}
+		if traceID := span.TraceID(); traceID != "" {
+			cresp.Annotations[constants.Tracing.TraceIdAnnotation] = traceID
+		}
 		response.ContainerResponses = append(response.ContainerResponses, cresp)
 
 	}
 
 	if !pm.UdsServerDisable {
+		// the socket is registered so a restarted device plugin can find and remove it, the server
+		// removes the record again when it stops
+		socket := &networking.Socket{Path: udsPath, Pool: pm.DevicePrefix + "/" + pm.Name, Devices: udsDevices}
+		if err := pm.NetHandler.RecordSocket(socket); err != nil {
+			logging.Warningf("Error recording socket %s: %v", udsPath, err)
+		}
 		udsServer.Start()
 	}
 
 	return &response, nil
 }
 
+/*
+CheckRegistered returns an error if the pool is no longer registered with the kubelet. The kubelet
+removes the sockets of all device plugins when it restarts, so a missing socket means the pool is
+unknown to the kubelet.
+*/
+func (pm *PoolManager) CheckRegistered() error {
+	if _, err := os.Stat(pm.DpAPISocket); err != nil {
+		return errdefs.Wrap(errdefs.ErrKubeletUnavailable, fmt.Errorf("pool %s is not registered with the kubelet: %w", pm.DevicePrefix+"/"+pm.Name, err))
+	}
+
+	return nil
+}
+
 /*
 GetDevicePluginOptions is part of the device plugin API.
 Unused.
@@ -270,14 +597,96 @@ func (pm *PoolManager) GetPreferredAllocation(context.Context, *pluginapi.Prefer
 	return &pluginapi.PreferredAllocationResponse{}, nil
 }
 
+/*
+register registers the pool with the kubelet, counting the registration.
+*/
+func (pm *PoolManager) register() error {
+	if err := pm.registerWithKubelet(); err != nil {
+		poolRegistrations.Add(1, pm.Name, "error")
+		return err
+	}
+	poolRegistrations.Add(1, pm.Name, "success")
+	logging.Infof("Pool "+pm.DevicePrefix+"/%s registered with Kubelet, plugin %s", pm.Name, buildInfo())
+
+	return nil
+}
+
+/*
+watchRegistration checks every interval that the device plugin socket of the pool still exists,
+until the pool is terminated. The kubelet removes the sockets of all device plugins when it
+restarts, and once the socket is gone the pool restarts its gRPC server and registers again. Each
+pool is watched on its own, so pools sharing the device plugin process come and go independently,
+and a pool failing to register again is retried without affecting the others.
+*/
+func (pm *PoolManager) watchRegistration(interval time.Duration) {
+	go func() {
+		defer crash.Recover("registration watch")
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-pm.StopSignal:
+				return
+			case <-ticker.C:
+			}
+
+			if pm.CheckRegistered() == nil {
+				continue
+			}
+			logging.Warningf("Pool "+pm.DevicePrefix+"/%s device plugin socket removed, registering with Kubelet again", pm.Name)
+			if err := pm.restart(); err != nil {
+				logging.Errorf("Pool "+pm.DevicePrefix+"/%s failed to register with Kubelet again, retrying in %v: %v", pm.Name, interval, err)
+				continue
+			}
+
+			// the devices are sent once the kubelet opens a new ListAndWatch stream
+			if len(pm.Devices) > 0 {
+				select {
+				case pm.UpdateSignal <- true:
+				case <-pm.StopSignal:
+					return
+				}
+			}
+		}
+	}()
+}
+
+/*
+restart restarts the gRPC server of the pool on a new socket and registers it with the kubelet,
+unless the pool has been terminated. The pool devices are left as they are.
+*/
+func (pm *PoolManager) restart() error {
+	pm.lifecycle.Lock()
+	defer pm.lifecycle.Unlock()
+
+	select {
+	case <-pm.StopSignal:
+		return nil
+	default:
+	}
+
+	pm.stopGRPC()
+	if err := os.Remove(pm.DpAPISocket); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := pm.serveGRPC(); err != nil {
+		return err
+	}
+
+	return pm.register()
+}
+
 func (pm *PoolManager) registerWithKubelet() error {
-	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(context.Background(), kubeletTimeout)
+	defer cancel()
 	conn, err := grpc.DialContext(ctx, pluginapi.KubeletSocket, grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
 			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
 		}))
 	if err != nil {
-		return fmt.Errorf("error connecting to Kubelet: %w", err)
+		return errdefs.Wrap(errdefs.ErrKubeletUnavailable, fmt.Errorf("error connecting to Kubelet: %w", err))
 	}
 	defer conn.Close()
 
@@ -289,19 +698,34 @@ func (pm *PoolManager) registerWithKubelet() error {
 		ResourceName: pm.DevicePrefix + "/" + pm.Name,
 	}
 
-	_, err = client.Register(context.Background(), reqt)
+	_, err = client.Register(ctx, reqt)
 	if err != nil {
-		return fmt.Errorf("error registering with Kubelet: %w", err)
+		return errdefs.Wrap(errdefs.ErrKubeletUnavailable, fmt.Errorf("error registering with Kubelet: %w", err))
 	}
 
 	return nil
 }
 
+/*
+buildInfo returns the version, git commit and build date of the device plugin, as given to the
+kubelet in allocate responses.
+*/
+func buildInfo() string {
+	return "version=" + constants.Plugins.Version + ", commit=" + constants.Plugins.Commit + ", built=" + constants.Plugins.BuildDate
+}
+
 func (pm *PoolManager) startGRPC() error {
 	if err := pm.cleanup(); err != nil {
 		return err
 	}
 
+	return pm.serveGRPC()
+}
+
+/*
+serveGRPC serves the device plugin API of the pool on its socket, checking it can be connected to.
+*/
+func (pm *PoolManager) serveGRPC() error {
 	sock, err := net.Listen("unix", pm.DpAPISocket)
 	if err != nil {
 		return err
@@ -314,7 +738,7 @@ func (pm *PoolManager) startGRPC() error {
 			logging.Errorf("API Server socket error: %v", err)
 		}
 	}()
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), kubeletTimeout)
 	defer cancel()
 	conn, err := grpc.DialContext(ctx, pm.DpAPISocket, grpc.WithTransportCredentials(insecure.NewCredentials()),
 		grpc.WithBlock(), grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
@@ -331,16 +755,235 @@ func (pm *PoolManager) startGRPC() error {
 	return nil
 }
 
+/*
+stopGRPC stops the gRPC server, waiting for calls in progress to finish for up to the shutdown
+timeout before closing their connections.
+*/
 func (pm *PoolManager) stopGRPC() {
-	if pm.DpAPIServer != nil {
+	if pm.DpAPIServer == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func(server *grpc.Server) {
+		server.GracefulStop()
+		close(stopped)
+	}(pm.DpAPIServer)
+
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		logging.Warningf("Pool "+pm.DevicePrefix+"/%s gRPC calls still in progress after %v, stopping", pm.Name, shutdownTimeout)
 		pm.DpAPIServer.Stop()
-		pm.DpAPIServer = nil
+	}
+	pm.DpAPIServer = nil
+}
+
+/*
+detachXdp detaches XDP programs from the pool devices still in the host network namespace.
+*/
+func (pm *PoolManager) detachXdp() {
+	for name := range pm.Devices {
+		exists, err := pm.NetHandler.NetDevExists(name)
+		if err != nil || !exists {
+			continue
+		}
+		if err := pm.BpfHandler.Cleanbpf(name); err != nil {
+			logging.Debugf("Unable to detach XDP program from device %s: %v", name, err)
+			continue
+		}
+		logging.Infof("Detached XDP program from device %s", name)
 	}
 }
 
 func (pm *PoolManager) cleanup() error {
+	if pm.Mode == "tap" {
+		for name := range pm.Devices {
+			if err := pm.NetHandler.DeleteTap(name); err != nil {
+				logging.Warningf("Error deleting tap device %s: %v", name, err)
+			}
+		}
+	}
+
 	if err := os.Remove(pm.DpAPISocket); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 	return nil
 }
+
+/*
+allocatePeer prepares the bond peer of a device the same way as the device itself, so XDP
+and queue configuration is consistent whichever port of the bond is active. The MTU the peer
+had before it was lowered to fit the UMEM frame size is returned, 0 if it was not changed.
+*/
+func (pm *PoolManager) allocatePeer(name string, peer string, udsServer udsserver.Server, j *journal, span *tracing.Span) (int, error) {
+	driver, err := pm.NetHandler.GetDeviceDriver(peer)
+	if err != nil {
+		return 0, fmt.Errorf("error getting driver of device %s: %w", peer, err)
+	}
+
+	if err := pm.configureDriver(peer, driver, j); err != nil {
+		return 0, err
+	}
+
+	restoreMtu, err := pm.checkMtu(peer, j)
+	if err != nil {
+		return 0, err
+	}
+
+	logging.Debugf("Cycling state of bond peer %s", peer)
+	if err := pm.NetHandler.CycleDevice(peer); err != nil {
+		return 0, fmt.Errorf("error cycling the state of device %s: %w", peer, err)
+	}
+
+	if !pm.UdsServerDisable {
+		logging.Infof("Loading BPF program on bond peer: %s", peer)
+		j.begin(&networking.JournalEntry{Op: networking.JournalXdpAttach, Device: peer})
+		fd, err := pm.loadBpf(peer, span)
+		if err != nil {
+			return 0, fmt.Errorf("error loading BPF program on interface %s: %w", peer, err)
+		}
+		logging.Infof("BPF program loaded on: %s File descriptor: %s", peer, strconv.Itoa(fd))
+		udsServer.AddDevicePeer(name, peer, fd)
+	}
+
+	return restoreMtu, nil
+}
+
+/*
+loadBpf loads the BPF program on a device, traced as a child of the allocation span.
+*/
+func (pm *PoolManager) loadBpf(name string, span *tracing.Span) (int, error) {
+	bpfSpan := tracing.Start("Load BPF program", span)
+	bpfSpan.SetAttribute("device", name)
+	fd, err := pm.BpfHandler.LoadBpfSendXskMap(name)
+	bpfSpan.End(err)
+
+	return fd, err
+}
+
+/*
+pinIrqs pins the queue IRQs of a device, and of its bond peer, to the CPUs of the pool.
+Failing to pin IRQs affects latency rather than function, so it does not fail the allocation.
+*/
+func (pm *PoolManager) pinIrqs(device *networking.Device) {
+	names := []string{device.Name()}
+	if peer := device.Peer(); peer != "" {
+		names = append(names, peer)
+	}
+
+	for _, name := range names {
+		if err := pm.NetHandler.SetIrqAffinity(name, pm.IrqCpus); err != nil {
+			logging.Warningf("Unable to pin queue IRQs of device %s to CPUs %v: %v", name, pm.IrqCpus, err)
+		}
+	}
+}
+
+/*
+deviceNames returns the names of the pool devices, including the bond peers of paired devices.
+*/
+func (pm *PoolManager) deviceNames() []string {
+	var names []string
+	for name, device := range pm.Devices {
+		names = append(names, name)
+		if peer := device.Peer(); peer != "" {
+			names = append(names, peer)
+		}
+	}
+	return names
+}
+
+/*
+Status returns the pool and the devices it advertises, sorted by name, for the device plugin status.
+*/
+func (pm *PoolManager) Status() status.Pool {
+	pool := status.Pool{
+		Name:     pm.Name,
+		Mode:     pm.Mode,
+		Resource: pm.DevicePrefix + "/" + pm.Name,
+		Devices:  []status.Device{},
+	}
+
+	for name, device := range pm.Devices {
+		driver, err := device.Driver()
+		if err != nil {
+			logging.Debugf("Error getting driver of device %s for status: %v", name, err)
+		}
+		pool.Devices = append(pool.Devices, status.Device{
+			Name:   name,
+			Pci:    pm.devicePci(name),
+			Driver: driver,
+			Peer:   device.Peer(),
+		})
+	}
+	sort.Slice(pool.Devices, func(i, j int) bool { return pool.Devices[i].Name < pool.Devices[j].Name })
+
+	return pool
+}
+
+/*
+devicePci returns the PCI address of a pool device for the pci metric label, or an empty
+string for bond peers and devices without one.
+*/
+func (pm *PoolManager) devicePci(name string) string {
+	device, ok := pm.Devices[name]
+	if !ok {
+		return ""
+	}
+	pci, err := device.Pci()
+	if err != nil {
+		return ""
+	}
+	return pci
+}
+
+/*
+configureDriver applies driver specific preparation to a device before XDP is attached.
+*/
+func (pm *PoolManager) configureDriver(name string, driver string, j *journal) error {
+	if tools.ArrayContains(constants.Drivers.Virtio, driver) {
+		logging.Debugf("Configuring virtio device %s for XDP", name)
+		channels, err := pm.NetHandler.GetChannels(name)
+		if err != nil {
+			return fmt.Errorf("error getting channels of virtio device %s: %w", name, err)
+		}
+		j.begin(&networking.JournalEntry{Op: networking.JournalChannels, Device: name, Channels: channels.Combined})
+		if err := pm.NetHandler.ConfigureVirtio(name); err != nil {
+			return fmt.Errorf("error configuring virtio device %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+/*
+checkMtu validates the MTU of a device against the UMEM frame size of the pool.
+If the MTU is too large for a frame and the pool allows it, the MTU is lowered to
+the largest that fits, otherwise the allocation is refused. The change is journaled
+and the previous MTU returned, so it can be restored when the device is released.
+0 is returned if the MTU was not changed.
+*/
+func (pm *PoolManager) checkMtu(name string, j *journal) (int, error) {
+	mtu, err := pm.NetHandler.GetMtu(name)
+	if err != nil {
+		return 0, fmt.Errorf("error getting MTU of device %s: %w", name, err)
+	}
+
+	err = networking.ValidateMtu(mtu, pm.UmemFrameSize)
+	if err == nil {
+		return 0, nil
+	}
+
+	if !pm.AdjustMtu {
+		return 0, fmt.Errorf("device %s: %v, lower the device MTU or enable adjustMtu on pool %s", name, err, pm.Name)
+	}
+
+	maxMtu := networking.MaxMtu(pm.UmemFrameSize)
+	logging.Warningf("Device %s: %v, lowering MTU to %d", name, err, maxMtu)
+	j.begin(&networking.JournalEntry{Op: networking.JournalMtu, Device: name, Mtu: mtu})
+	if err := pm.NetHandler.SetMtu(name, maxMtu); err != nil {
+		return 0, fmt.Errorf("error setting MTU of device %s to %d: %w", name, maxMtu, err)
+	}
+
+	return mtu, nil
+}
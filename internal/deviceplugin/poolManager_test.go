@@ -16,15 +16,25 @@
 package deviceplugin
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
 )
 
@@ -55,6 +65,7 @@ func TestAllocate(t *testing.T) {
 	pm := NewPoolManager(config)
 	pm.ServerFactory = udsserver.NewFakeServerFactory()
 	pm.BpfHandler = bpf.NewFakeHandler()
+	pm.NetHandler = netHandler
 
 	testCases := []struct {
 		name                  string
@@ -68,16 +79,16 @@ func TestAllocate(t *testing.T) {
 			},
 			expContainerResponses: []*pluginapi.ContainerAllocateResponse{
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 			},
 		},
@@ -89,16 +100,16 @@ func TestAllocate(t *testing.T) {
 			},
 			expContainerResponses: []*pluginapi.ContainerAllocateResponse{
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1 dev_2 dev_3"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1 dev_2 dev_3", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 			},
 		},
@@ -111,28 +122,28 @@ func TestAllocate(t *testing.T) {
 			},
 			expContainerResponses: []*pluginapi.ContainerAllocateResponse{
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_2"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_2", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 			},
 		},
@@ -145,28 +156,28 @@ func TestAllocate(t *testing.T) {
 			},
 			expContainerResponses: []*pluginapi.ContainerAllocateResponse{
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1 dev_2 dev_3"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_1 dev_2 dev_3", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: "dev_4 dev_5 dev_6"},
+					Envs: map[string]string{constants.Devices.EnvVarList: "dev_4 dev_5 dev_6", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 			},
 		},
@@ -178,16 +189,16 @@ func TestAllocate(t *testing.T) {
 			},
 			expContainerResponses: []*pluginapi.ContainerAllocateResponse{
 				{
-					Envs: map[string]string{constants.Devices.EnvVarList: ""},
+					Envs: map[string]string{constants.Devices.EnvVarList: "", constants.Uds.TokenEnvVar: "fake-token"},
 					Mounts: []*pluginapi.Mount{
 						{
-							ContainerPath: constants.Uds.PodPath,
-							HostPath:      "/tmp/fake-socket.sock",
+							ContainerPath: constants.Uds.PodDir,
+							HostPath:      "/tmp/fake-socket",
 							ReadOnly:      false,
 						},
 					},
 					Devices:     []*pluginapi.DeviceSpec{},
-					Annotations: map[string]string{},
+					Annotations: map[string]string{constants.Plugins.DevicePlugin.BuildAnnotation: buildInfo()},
 				},
 			},
 		},
@@ -223,4 +234,448 @@ func TestAllocate(t *testing.T) {
 
 		})
 	}
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+	assert.Contains(t, out.String(), `afxdp_pool_allocations_total{pool="myPool",outcome="success"}`, "Allocations should be counted")
+	assert.Contains(t, out.String(), `afxdp_pool_allocate_duration_seconds_count{pool="myPool",outcome="success"}`, "Allocations should be timed")
+}
+
+func TestAllocateDpdkProfile(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+
+	pm := NewPoolManager(PoolConfig{
+		Name: "dpdkPool",
+		Mode: "primary",
+		Devices: map[string]*networking.Device{
+			"dev_1": networking.CreateTestDevice("dev_1", "primary", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler),
+			"dev_2": networking.CreateTestDevice("dev_2", "primary", "ice", "0000:81:00.2", "68:05:ca:2d:e9:02", netHandler),
+		},
+		UdsProfile: constants.Uds.ProfileDpdk,
+	})
+	pm.ServerFactory = udsserver.NewFakeServerFactory()
+	pm.BpfHandler = bpf.NewFakeHandler()
+	pm.NetHandler = netHandler
+
+	response, err := pm.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"dev_1", "dev_2"}},
+		},
+	})
+	require.NoError(t, err, "Unexpected error during Allocate")
+	require.Len(t, response.ContainerResponses, 1)
+
+	assert.Equal(t, []*pluginapi.Mount{
+		{ContainerPath: constants.Uds.PodDir, HostPath: "/tmp/fake-socket"},
+		{ContainerPath: constants.Uds.DpdkPodDir + "dev_1/", HostPath: "/tmp/fake-socket"},
+		{ContainerPath: constants.Uds.DpdkPodDir + "dev_2/", HostPath: "/tmp/fake-socket"},
+	}, response.ContainerResponses[0].Mounts, "The socket should be mounted where DPDK looks for it for each device")
+}
+
+func TestAllocateUdsLegacyPath(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+
+	pm := NewPoolManager(PoolConfig{
+		Name: "legacyPool",
+		Mode: "primary",
+		Devices: map[string]*networking.Device{
+			"dev_1": networking.CreateTestDevice("dev_1", "primary", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler),
+		},
+		UdsLegacyPath: true,
+	})
+	pm.ServerFactory = udsserver.NewFakeServerFactory()
+	pm.BpfHandler = bpf.NewFakeHandler()
+	pm.NetHandler = netHandler
+
+	response, err := pm.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"dev_1"}},
+		},
+	})
+	require.NoError(t, err, "Unexpected error during Allocate")
+	require.Len(t, response.ContainerResponses, 1)
+
+	assert.Equal(t, []*pluginapi.Mount{
+		{ContainerPath: constants.Uds.PodDir, HostPath: "/tmp/fake-socket"},
+		{ContainerPath: constants.Uds.PodPath, HostPath: "/tmp/fake-socket/afxdp.sock"},
+	}, response.ContainerResponses[0].Mounts, "The socket should also be mounted at the legacy path")
+}
+
+/*
+socketDirFactory serves fake UDS servers from a socket directory created by the test.
+*/
+type socketDirFactory struct {
+	udsserver.ServerFactory
+	dir string
+}
+
+func (f *socketDirFactory) CreateServer(deviceType, user string, timeout int, udsFuzz bool) (udsserver.Server, string, error) {
+	server, _, err := f.ServerFactory.CreateServer(deviceType, user, timeout, udsFuzz)
+	return server, filepath.Join(f.dir, constants.Uds.SockName), err
+}
+
+func TestAllocateFailureRemovesSocketDir(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	root, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Unexpected error creating directory")
+	defer os.RemoveAll(root)
+	dir := filepath.Join(root, "pod")
+	require.NoError(t, os.Mkdir(dir, 0700), "Unexpected error creating socket directory")
+
+	pm := NewPoolManager(PoolConfig{
+		Name: "myPool",
+		Mode: "primary",
+		Devices: map[string]*networking.Device{
+			"dev_1": networking.CreateTestDevice("dev_1", "cdq", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler),
+		},
+	})
+	pm.ServerFactory = &socketDirFactory{ServerFactory: udsserver.NewFakeServerFactory(), dir: dir}
+	pm.BpfHandler = bpf.NewFakeHandler()
+	pm.NetHandler = netHandler
+
+	_, err = pm.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"dev_1"}},
+		},
+	})
+	require.Error(t, err, "Allocate should fail on a device mode mismatch")
+
+	_, err = os.Stat(dir)
+	assert.True(t, os.IsNotExist(err), "Socket directory should be removed when Allocate fails")
+}
+
+/*
+cleanRecorder is a fake BPF handler recording the devices XDP programs are removed from.
+*/
+type cleanRecorder struct {
+	bpf.Handler
+	cleaned []string
+}
+
+func (r *cleanRecorder) Cleanbpf(ifname string) error {
+	r.cleaned = append(r.cleaned, ifname)
+	return r.Handler.Cleanbpf(ifname)
+}
+
+func TestAllocateFailureRollsBack(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	netHandler.SetError("WriteDeviceFile", errors.New("fake error"))
+	defer netHandler.SetError("WriteDeviceFile", nil)
+	bpfHandler := &cleanRecorder{Handler: bpf.NewFakeHandler()}
+
+	pm := NewPoolManager(PoolConfig{
+		Name: "myPool",
+		Mode: "primary",
+		Devices: map[string]*networking.Device{
+			"dev_1": networking.CreateTestDevice("dev_1", "primary", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler),
+		},
+		Promiscuous: true,
+	})
+	pm.ServerFactory = udsserver.NewFakeServerFactory()
+	pm.BpfHandler = bpfHandler
+	pm.NetHandler = netHandler
+
+	_, err := pm.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"dev_1"}},
+		},
+	})
+	require.Error(t, err, "Allocate should fail writing the device file")
+
+	assert.Equal(t, []string{"dev_1"}, bpfHandler.cleaned, "XDP program should be removed when Allocate fails")
+	pending, err := netHandler.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	assert.Empty(t, pending, "Rolled back entries left in the journal")
+}
+
+func TestAllocateFailureRestoresMtu(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	require.NoError(t, netHandler.SetMtu("mtu_dev_5", 9000), "Unexpected error setting MTU")
+	device := networking.CreateTestDevice("mtu_dev_5", "primary", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler)
+
+	pm := NewPoolManager(PoolConfig{
+		Name:          "myPool",
+		Mode:          "primary",
+		Devices:       map[string]*networking.Device{"mtu_dev_5": device},
+		UmemFrameSize: 4096,
+		AdjustMtu:     true,
+	})
+	pm.ServerFactory = udsserver.NewFakeServerFactory()
+	pm.BpfHandler = bpf.NewFakeHandler()
+	pm.NetHandler = netHandler
+	rqt := &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{
+			{DevicesIDs: []string{"mtu_dev_5"}},
+		},
+	}
+
+	netHandler.SetError("WriteDeviceFile", errors.New("fake error"))
+	_, err := pm.Allocate(context.Background(), rqt)
+	netHandler.SetError("WriteDeviceFile", nil)
+	require.Error(t, err, "Allocate should fail writing the device file")
+
+	mtu, err := netHandler.GetMtu("mtu_dev_5")
+	require.NoError(t, err, "Unexpected error getting MTU")
+	assert.Equal(t, 9000, mtu, "MTU should be restored when Allocate fails")
+
+	_, err = pm.Allocate(context.Background(), rqt)
+	require.NoError(t, err, "Unexpected error during Allocate")
+	assert.Equal(t, 9000, device.RestoreMtu(), "A retried Allocate should record the original MTU")
+}
+
+func TestCheckMtu(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+
+	testCases := []struct {
+		name       string
+		device     string
+		mtu        int
+		frameSize  int
+		adjustMtu  bool
+		expMtu     int
+		expRestore int
+		expErr     bool
+	}{
+		{
+			name:      "standard MTU",
+			device:    "mtu_dev_1",
+			mtu:       1500,
+			frameSize: 4096,
+			expMtu:    1500,
+		},
+		{
+			name:      "jumbo MTU refused",
+			device:    "mtu_dev_2",
+			mtu:       9000,
+			frameSize: 4096,
+			expMtu:    9000,
+			expErr:    true,
+		},
+		{
+			name:       "jumbo MTU adjusted",
+			device:     "mtu_dev_3",
+			mtu:        9000,
+			frameSize:  4096,
+			adjustMtu:  true,
+			expMtu:     networking.MaxMtu(4096),
+			expRestore: 9000,
+		},
+		{
+			name:       "standard MTU adjusted for 2K frames",
+			device:     "mtu_dev_4",
+			mtu:        1800,
+			frameSize:  2048,
+			adjustMtu:  true,
+			expMtu:     networking.MaxMtu(2048),
+			expRestore: 1800,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pm := NewPoolManager(PoolConfig{Name: "myPool", Mode: "primary", UmemFrameSize: tc.frameSize, AdjustMtu: tc.adjustMtu})
+			pm.NetHandler = netHandler
+
+			assert.NoError(t, netHandler.SetMtu(tc.device, tc.mtu), "Unexpected error setting MTU")
+
+			restoreMtu, err := pm.checkMtu(tc.device, &journal{netHandler: netHandler})
+			if tc.expErr {
+				assert.Error(t, err, "Error was expected")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+			assert.Equal(t, tc.expRestore, restoreMtu, "Unexpected MTU to restore")
+
+			mtu, _ := netHandler.GetMtu(tc.device)
+			assert.Equal(t, tc.expMtu, mtu, "Unexpected device MTU")
+		})
+	}
+}
+
+func TestLinkDownEvents(t *testing.T) {
+	fakeEvents := apiserver.NewFakeHandler()
+	SetEventRecorder(fakeEvents)
+	defer SetEventRecorder(nil)
+
+	pm := &PoolManager{Name: "myPool", DevicePrefix: "afxdp"}
+	for _, event := range []networking.LinkEvent{
+		{Device: "dev1", AdminUp: true, OperState: "down"},
+		{Device: "dev1", AdminUp: false, OperState: "down"},
+		{Device: "dev2", AdminUp: true, OperState: "up"},
+		{Device: "dev1", AdminUp: true, OperState: "up"},
+		{Device: "dev1", AdminUp: true, OperState: "down"},
+	} {
+		pm.handleLinkEvent(event)
+	}
+
+	events := fakeEvents.Events()
+	require.Len(t, events, 2, "Only changes from up to down should be reported")
+	for _, event := range events {
+		assert.Equal(t, constants.Events.DeviceLinkDown, event.Reason, "Unexpected event reason")
+		assert.Empty(t, event.Pod, "Link events should be reported on the node")
+		assert.Contains(t, event.Message, "Device dev1 of pool afxdp/myPool link is down", "Unexpected event message")
+	}
+}
+
+func TestCheckRegistered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "afxdp-dp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pm := &PoolManager{Name: "myPool", DevicePrefix: "afxdp", DpAPISocket: filepath.Join(dir, "afxdp-myPool.sock")}
+	err = pm.CheckRegistered()
+	require.Error(t, err, "Pool without a socket should not be registered")
+	assert.Contains(t, err.Error(), "pool afxdp/myPool is not registered with the kubelet", "Unexpected error")
+	assert.True(t, errors.Is(err, errdefs.ErrKubeletUnavailable), "Unexpected error kind")
+
+	require.NoError(t, ioutil.WriteFile(pm.DpAPISocket, nil, 0600))
+	assert.NoError(t, pm.CheckRegistered(), "Pool with a socket should be registered")
+}
+
+/*
+panicStream is a ListAndWatch stream that panics on the first send.
+*/
+type panicStream struct {
+	pluginapi.DevicePlugin_ListAndWatchServer
+	sent []*pluginapi.ListAndWatchResponse
+}
+
+func (s *panicStream) Send(resp *pluginapi.ListAndWatchResponse) error {
+	s.sent = append(s.sent, resp)
+	if len(s.sent) == 1 {
+		panic("send on broken stream")
+	}
+	return nil
+}
+
+func TestSendDevicesPanic(t *testing.T) {
+	pm := &PoolManager{
+		Name:    "myPool",
+		Devices: map[string]*networking.Device{"dev1": nil},
+	}
+	stream := &panicStream{}
+
+	assert.NotPanics(t, func() { pm.sendDevices(stream, pluginapi.Healthy) }, "Panics should be recovered")
+	pm.sendDevices(stream, pluginapi.Healthy)
+	require.Len(t, stream.sent, 2, "Devices should be sent on the next update")
+	assert.Equal(t, "dev1", stream.sent[1].Devices[0].ID, "Unexpected device")
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+	assert.Contains(t, out.String(), `afxdp_crashes_total{component="list and watch"} 1`, "Crash should be counted")
+}
+
+/*
+recordStream is a ListAndWatch stream that records what is sent.
+*/
+type recordStream struct {
+	pluginapi.DevicePlugin_ListAndWatchServer
+	sent []*pluginapi.ListAndWatchResponse
+	ctx  context.Context
+}
+
+func (s *recordStream) Send(resp *pluginapi.ListAndWatchResponse) error {
+	s.sent = append(s.sent, resp)
+	return nil
+}
+
+func (s *recordStream) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+func TestListAndWatchStop(t *testing.T) {
+	pm := &PoolManager{
+		Name:         "myPool",
+		Devices:      map[string]*networking.Device{"dev1": nil},
+		UpdateSignal: make(chan bool),
+		StopSignal:   make(chan bool),
+	}
+	stream := &recordStream{}
+
+	done := make(chan error)
+	go func() { done <- pm.ListAndWatch(&pluginapi.Empty{}, stream) }()
+	pm.UpdateSignal <- true
+	close(pm.StopSignal)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "Unexpected error")
+	case <-time.After(time.Second):
+		t.Fatal("ListAndWatch should return once the pool is stopped")
+	}
+	require.Len(t, stream.sent, 2, "Devices should be sent on update and on stop")
+	assert.Equal(t, pluginapi.Healthy, stream.sent[0].Devices[0].Health, "Devices should be healthy while running")
+	assert.Equal(t, pluginapi.Unhealthy, stream.sent[1].Devices[0].Health, "Devices should be unhealthy once stopped")
+}
+
+func TestListAndWatchStreamClosed(t *testing.T) {
+	pm := &PoolManager{
+		Name:         "myPool",
+		Devices:      map[string]*networking.Device{"dev1": nil},
+		UpdateSignal: make(chan bool),
+		StopSignal:   make(chan bool),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &recordStream{ctx: ctx}
+
+	done := make(chan error)
+	go func() { done <- pm.ListAndWatch(&pluginapi.Empty{}, stream) }()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err, "Unexpected error")
+	case <-time.After(time.Second):
+		t.Fatal("ListAndWatch should return once the kubelet closes the stream")
+	}
+	assert.Empty(t, stream.sent, "Devices should not be sent on a closed stream")
+
+	select {
+	case pm.UpdateSignal <- true:
+		t.Fatal("Updates should be left for the next stream")
+	default:
+	}
+}
+
+func TestRestartTerminated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "afxdp-dp")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	pm := NewPoolManager(PoolConfig{Name: "myPool"})
+	pm.DpAPISocket = filepath.Join(dir, "afxdp-myPool.sock")
+	close(pm.StopSignal)
+
+	assert.NoError(t, pm.restart(), "Unexpected error")
+	assert.Nil(t, pm.DpAPIServer, "A terminated pool should not serve again")
+	assert.Error(t, pm.CheckRegistered(), "A terminated pool should not register again")
+}
+
+func TestUpdateQueueStats(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+	require.NoError(t, netHandler.RecordAllocation(&networking.Allocation{
+		Device: "dev_1", Owner: "statsTest", Pod: "podA", Namespace: "default",
+	}))
+	defer netHandler.RemoveAllocation("dev_1", "statsTest")
+
+	pm := &PoolManager{
+		Name:       "statsPool",
+		NetHandler: netHandler,
+		Devices: map[string]*networking.Device{
+			"dev_1": networking.CreateTestDevice("dev_1", "primary", "ice", "0000:81:00.1", "68:05:ca:2d:e9:01", netHandler),
+			"dev_2": networking.CreateTestDevice("dev_2", "primary", "ice", "0000:81:00.2", "68:05:ca:2d:e9:02", netHandler),
+		},
+	}
+	pm.updateQueueStats()
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+	labels := `{pool="statsPool",device="dev_1",pci="0000:81:00.1",pod="podA",namespace="default",queue="0"`
+	assert.Contains(t, out.String(), `afxdp_device_queue_packets_total`+labels+`,direction="rx"} 100`, "Queue packets should be collected")
+	assert.Contains(t, out.String(), `afxdp_xsk_rx_ring_full_total`+labels+`} 5`, "Socket rx ring full should be collected")
+	assert.Contains(t, out.String(), `afxdp_xsk_fill_ring_empty_total`+labels+`} 3`, "Socket fill ring empty should be collected")
+	assert.NotContains(t, out.String(), `device="dev_2"`, "Devices not attached to a pod should not be collected")
 }
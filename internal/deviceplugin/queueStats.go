@@ -0,0 +1,197 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	logging "github.com/sirupsen/logrus"
+)
+
+var (
+	queueStatLabels = []string{metrics.LabelPool, metrics.LabelDevice, metrics.LabelPci, metrics.LabelPod, metrics.LabelNamespace, "queue", "direction"}
+
+	queuePackets = metrics.NewCounterVec("device_queue_packets_total",
+		"Packets received or transmitted on a queue of an allocated device, as reported by the driver.", queueStatLabels...)
+	queueDrops = metrics.NewCounterVec("device_queue_drops_total",
+		"Packets dropped on a queue of an allocated device, including XDP drops, as reported by the driver.", queueStatLabels...)
+
+	xskStatLabels = []string{metrics.LabelPool, metrics.LabelDevice, metrics.LabelPci, metrics.LabelPod, metrics.LabelNamespace, "queue"}
+
+	xskRxDropped = metrics.NewCounterVec("xsk_rx_dropped_total",
+		"Packets dropped by the kernel on the AF_XDP sockets of a queue, for reasons other than invalid descriptors or full rings.", xskStatLabels...)
+	xskRxInvalid = metrics.NewCounterVec("xsk_rx_invalid_total",
+		"Packets dropped on the AF_XDP sockets of a queue as the fill ring held an invalid descriptor.", xskStatLabels...)
+	xskRxRingFull = metrics.NewCounterVec("xsk_rx_ring_full_total",
+		"Packets dropped on the AF_XDP sockets of a queue as the rx ring was full, the pod is not consuming rx.", xskStatLabels...)
+	xskFillRingEmpty = metrics.NewCounterVec("xsk_fill_ring_empty_total",
+		"Times the fill ring of the AF_XDP sockets of a queue was empty, the pod is not refilling buffers.", xskStatLabels...)
+	xskTxInvalid = metrics.NewCounterVec("xsk_tx_invalid_total",
+		"Invalid descriptors placed on the tx ring of the AF_XDP sockets of a queue.", xskStatLabels...)
+	xskTxRingEmpty = metrics.NewCounterVec("xsk_tx_ring_empty_total",
+		"Times the tx ring of the AF_XDP sockets of a queue was empty when the kernel tried to transmit.", xskStatLabels...)
+
+	xskStatVecs = []*metrics.Vec{xskRxDropped, xskRxInvalid, xskRxRingFull, xskFillRingEmpty, xskTxInvalid, xskTxRingEmpty}
+)
+
+/*
+queueSample is a single per-queue counter value along with its label values.
+*/
+type queueSample struct {
+	vec    *metrics.Vec
+	value  uint64
+	labels []string
+}
+
+/*
+collectQueueStats periodically collects the per-queue statistics of the pool devices
+that are attached to pods. It runs until the StopSignal channel is closed.
+*/
+func (pm *PoolManager) collectQueueStats() {
+	ticker := time.NewTicker(time.Duration(constants.Metrics.QueueStatsInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-pm.StopSignal:
+			return
+		case <-ticker.C:
+			pm.updateQueueStats()
+		}
+	}
+}
+
+/*
+updateQueueStats replaces the per-queue metrics of this pool with the current device and AF_XDP
+socket counters. Only devices the CNI has recorded as attached to a pod are collected, as the pod
+labels come from the allocation record. Devices that are no longer attached drop out of the metrics.
+*/
+func (pm *PoolManager) updateQueueStats() {
+	allocations, err := pm.NetHandler.GetAllocations()
+	if err != nil {
+		logging.Warningf("Pool %s: unable to read device allocations: %v", pm.Name, err)
+		return
+	}
+
+	var samples []queueSample
+	for _, name := range pm.deviceNames() {
+		allocation, ok := allocations[name]
+		if !ok {
+			continue
+		}
+
+		pci := pm.devicePci(name)
+		stats, err := pm.getQueueStats(allocation)
+		if err != nil {
+			logging.Debugf("Pool %s: unable to get queue statistics of device %s: %v", pm.Name, name, err)
+			continue
+		}
+
+		for _, q := range stats {
+			labels := func(direction string) []string {
+				return []string{pm.Name, name, pci, allocation.Pod, allocation.Namespace, strconv.Itoa(q.Queue), direction}
+			}
+			samples = append(samples,
+				queueSample{queuePackets, q.RxPackets, labels("rx")},
+				queueSample{queueDrops, q.RxDrops, labels("rx")},
+				queueSample{queuePackets, q.TxPackets, labels("tx")},
+				queueSample{queueDrops, q.TxDrops, labels("tx")},
+			)
+		}
+
+		xskStats, err := pm.getXskStats(allocation)
+		if err != nil {
+			logging.Debugf("Pool %s: unable to get AF_XDP socket statistics of device %s: %v", pm.Name, name, err)
+			continue
+		}
+
+		for _, q := range xskStats {
+			labels := []string{pm.Name, name, pci, allocation.Pod, allocation.Namespace, strconv.Itoa(q.Queue)}
+			samples = append(samples,
+				queueSample{xskRxDropped, q.RxDropped, labels},
+				queueSample{xskRxInvalid, q.RxInvalid, labels},
+				queueSample{xskRxRingFull, q.RxRingFull, labels},
+				queueSample{xskFillRingEmpty, q.FillRingEmpty, labels},
+				queueSample{xskTxInvalid, q.TxInvalid, labels},
+				queueSample{xskTxRingEmpty, q.TxRingEmpty, labels},
+			)
+		}
+	}
+
+	queuePackets.DeleteMatching(metrics.LabelPool, pm.Name)
+	queueDrops.DeleteMatching(metrics.LabelPool, pm.Name)
+	for _, vec := range xskStatVecs {
+		vec.DeleteMatching(metrics.LabelPool, pm.Name)
+	}
+	for _, s := range samples {
+		s.vec.Set(float64(s.value), s.labels...)
+	}
+}
+
+/*
+getQueueStats reads the per-queue statistics of an allocated device. Devices are moved into
+the pod network namespace on attachment, so the statistics are read from within that namespace.
+*/
+func (pm *PoolManager) getQueueStats(allocation *networking.Allocation) ([]*networking.QueueStats, error) {
+	var stats []*networking.QueueStats
+	err := inPodNetns(allocation, func() error {
+		var err error
+		stats, err = pm.NetHandler.GetQueueStats(allocation.Device)
+		return err
+	})
+
+	return stats, err
+}
+
+/*
+getXskStats reads the counters of the AF_XDP sockets bound to an allocated device. Sockets are
+only visible from the network namespace of the pod that created them.
+*/
+func (pm *PoolManager) getXskStats(allocation *networking.Allocation) ([]*networking.XskStats, error) {
+	var stats []*networking.XskStats
+	err := inPodNetns(allocation, func() error {
+		var err error
+		stats, err = pm.NetHandler.GetXskStats(allocation.Device)
+		return err
+	})
+
+	return stats, err
+}
+
+/*
+inPodNetns runs f within the network namespace the CNI recorded for an allocation, or within
+the current namespace if none was recorded.
+*/
+func inPodNetns(allocation *networking.Allocation, f func() error) error {
+	if allocation.Netns == "" {
+		return f()
+	}
+
+	netns, err := ns.GetNS(allocation.Netns)
+	if err != nil {
+		return err
+	}
+	defer netns.Close()
+
+	return netns.Do(func(_ ns.NetNS) error {
+		return f()
+	})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+RecoverSockets removes the UDS sockets left behind by a previous instance of the device plugin.
+The XSK map file descriptors those sockets served died with the previous instance, so they cannot
+be served again and the pods using them must be restarted to get a new allocation. It is called
+once at device plugin startup, before any server is started.
+*/
+func RecoverSockets(netHandler networking.Handler) {
+	sockets, err := netHandler.GetSockets()
+	if err != nil {
+		logging.Errorf("Error reading socket registry, sockets of a previous instance cannot be removed: %v", err)
+		return
+	}
+
+	for path, socket := range sockets {
+		logging.Warningf("Removing socket %s of pool %s, created by a previous instance at %s: pods using devices %v must be restarted",
+			path, socket.Pool, socket.Created.Format(time.RFC3339), socket.Devices)
+
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			logging.Errorf("Error removing socket %s: %v", path, err)
+			continue
+		}
+		dir := filepath.Dir(path)
+		if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+			logging.Warningf("Error removing socket directory %s: %v", dir, err)
+		}
+		if err := netHandler.RemoveSocket(path); err != nil {
+			logging.Errorf("Error removing record of socket %s: %v", path, err)
+		}
+	}
+}
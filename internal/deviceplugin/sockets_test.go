@@ -0,0 +1,53 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package deviceplugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverSockets(t *testing.T) {
+	netHandler := networking.NewFakeHandler()
+
+	dir, err := ioutil.TempDir("", "sockets")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	podDir := filepath.Join(dir, "pod1")
+	require.NoError(t, os.Mkdir(podDir, 0700), "Unexpected error")
+	path := filepath.Join(podDir, "afxdp.sock")
+	require.NoError(t, ioutil.WriteFile(path, nil, 0600), "Unexpected error")
+	missing := filepath.Join(dir, "pod2", "afxdp.sock")
+
+	require.NoError(t, netHandler.RecordSocket(&networking.Socket{Path: path, Pool: "afxdp/pool1", Devices: []string{"ens1"}}), "Unexpected error")
+	require.NoError(t, netHandler.RecordSocket(&networking.Socket{Path: missing, Pool: "afxdp/pool1", Devices: []string{"ens2"}}), "Unexpected error")
+
+	RecoverSockets(netHandler)
+
+	_, err = os.Stat(podDir)
+	assert.True(t, os.IsNotExist(err), "Socket directory should be removed")
+
+	sockets, err := netHandler.GetSockets()
+	require.NoError(t, err, "Unexpected error")
+	assert.Empty(t, sockets, "Socket records should be removed")
+}
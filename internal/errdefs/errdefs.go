@@ -0,0 +1,87 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package errdefs defines the kinds of error shared by the device plugin, the CNI and the pod
+client library. Errors are marked as one of these kinds by Wrap, so callers and tests can branch
+on the kind of error with errors.Is, rather than matching the error string.
+*/
+package errdefs
+
+import "errors"
+
+/*
+ErrDeviceNotOwned is returned when a pod requests a device that was not allocated to it.
+*/
+var ErrDeviceNotOwned = errors.New("device not owned by pod")
+
+/*
+ErrFdAlreadyServed is returned when a pod requests the file descriptor of a device that was
+already passed to it on the connection, and the device plugin serves each only once.
+*/
+var ErrFdAlreadyServed = errors.New("file descriptor already served on the connection")
+
+/*
+ErrValidationFailed is returned when a config file, a network attachment definition or an
+environment variable is invalid.
+*/
+var ErrValidationFailed = errors.New("validation failed")
+
+/*
+ErrKubeletUnavailable is returned when the kubelet cannot be reached, or the device plugin is not
+registered with it.
+*/
+var ErrKubeletUnavailable = errors.New("kubelet unavailable")
+
+/*
+ErrXDPAttach is returned when an XDP program cannot be loaded onto, or removed from, a device.
+*/
+var ErrXDPAttach = errors.New("error attaching XDP program")
+
+/*
+kindError is an error of a given kind. It reads as the error it wraps, and unwraps to it, so
+wrapping an error in a kind changes neither its message nor what errors.As finds in it.
+*/
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+/*
+Is returns true if target is the kind of the error.
+*/
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+/*
+Wrap marks err as an error of the given kind, one of the errors of this package, so that
+errors.Is(err, kind) is true. The message of err is unchanged. Wrap returns nil if err is nil.
+*/
+func Wrap(kind error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &kindError{kind: kind, err: err}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package errdefs
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pathError struct {
+	path string
+}
+
+func (e *pathError) Error() string {
+	return "no such file " + e.path
+}
+
+func TestWrap(t *testing.T) {
+	cause := &pathError{path: "/var/lib/kubelet/device-plugins/kubelet.sock"}
+
+	testCases := []struct {
+		name        string
+		err         error
+		kind        error
+		expKinds    []error
+		expNotKinds []error
+	}{
+		{
+			name:        "wrapped error",
+			err:         cause,
+			kind:        ErrKubeletUnavailable,
+			expKinds:    []error{ErrKubeletUnavailable},
+			expNotKinds: []error{ErrValidationFailed, ErrDeviceNotOwned, ErrXDPAttach},
+		},
+		{
+			name:        "wrapped error wrapping an error",
+			err:         fmt.Errorf("error registering with Kubelet: %w", cause),
+			kind:        ErrKubeletUnavailable,
+			expKinds:    []error{ErrKubeletUnavailable},
+			expNotKinds: []error{ErrValidationFailed},
+		},
+		{
+			name:        "wrapped error wrapping a wrapped error",
+			err:         Wrap(ErrXDPAttach, cause),
+			kind:        ErrValidationFailed,
+			expKinds:    []error{ErrValidationFailed, ErrXDPAttach},
+			expNotKinds: []error{ErrDeviceNotOwned},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Wrap(tc.kind, tc.err)
+
+			assert.Equal(t, tc.err.Error(), err.Error(), "Wrapping should not change the message")
+			for _, kind := range tc.expKinds {
+				assert.True(t, errors.Is(err, kind), "Error should be of kind %v", kind)
+			}
+			for _, kind := range tc.expNotKinds {
+				assert.False(t, errors.Is(err, kind), "Error should not be of kind %v", kind)
+			}
+
+			var target *pathError
+			assert.True(t, errors.As(err, &target), "Wrapped errors should still be found")
+			assert.Equal(t, cause, target, "Unexpected wrapped error")
+		})
+	}
+
+	assert.Nil(t, Wrap(ErrValidationFailed, nil), "Wrapping nil should return nil")
+}
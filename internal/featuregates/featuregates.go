@@ -0,0 +1,203 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package featuregates
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Names of the feature gates.
+*/
+const (
+	DynamicResourceAllocation = "DynamicResourceAllocation" // allocating devices through Kubernetes dynamic resource allocation rather than the device plugin API
+	QueuePools                = "QueuePools"                // pools advertising individual queues of a device rather than whole devices
+	JsonUdsProtocol           = "JsonUdsProtocol"           // JSON encoded UDS handshake messages, alongside the text protocol
+)
+
+/*
+Stages of the feature gates. Alpha features are disabled by default and may change or be removed
+in any release. Beta features are enabled by default and are expected to become generally
+available.
+*/
+const (
+	Alpha = "alpha"
+	Beta  = "beta"
+)
+
+/*
+Gate is a feature gate, governing an experimental subsystem of the plugins.
+*/
+type Gate struct {
+	Name    string
+	Stage   string
+	Default bool
+}
+
+/*
+Gates are the feature gates, each enabled or disabled with Set.
+*/
+var Gates = []Gate{
+	{Name: DynamicResourceAllocation, Stage: Alpha},
+	{Name: QueuePools, Stage: Alpha},
+	{Name: JsonUdsProtocol, Stage: Alpha},
+}
+
+var featureEnabled = metrics.NewGaugeVec("feature_enabled",
+	"Whether a feature gate is enabled, 1 if enabled and 0 otherwise.", "gate", "stage")
+
+var enabled = struct {
+	sync.Mutex
+	gates map[string]bool
+}{
+	gates: defaults(),
+}
+
+func defaults() map[string]bool {
+	gates := make(map[string]bool, len(Gates))
+	for _, gate := range Gates {
+		gates[gate.Name] = gate.Default
+		featureEnabled.Set(value(gate.Default), gate.Name, gate.Stage)
+	}
+
+	return gates
+}
+
+func value(on bool) float64 {
+	if on {
+		return 1
+	}
+	return 0
+}
+
+/*
+Validate returns an error if gates names a feature gate that does not exist.
+*/
+func Validate(gates map[string]bool) error {
+	var unknown []string
+	for name := range gates {
+		if _, ok := lookup(name); !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("unknown feature gates %v, feature gates are %v", unknown, Names())
+	}
+
+	return nil
+}
+
+/*
+Parse parses feature gates given as a comma separated list of name=bool pairs, as on the command
+line, e.g. QueuePools=true,JsonUdsProtocol=false.
+*/
+func Parse(list string) (map[string]bool, error) {
+	gates := make(map[string]bool)
+	for _, pair := range strings.Split(list, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("feature gate %s must be of the form name=true or name=false", pair)
+		}
+		on, err := strconv.ParseBool(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, fmt.Errorf("feature gate %s must be of the form name=true or name=false", pair)
+		}
+		gates[strings.TrimSpace(kv[0])] = on
+	}
+
+	return gates, Validate(gates)
+}
+
+/*
+Set enables or disables the feature gates named in gates, leaving the others as they are.
+Enabling an alpha feature is logged as a warning.
+*/
+func Set(gates map[string]bool) error {
+	if err := Validate(gates); err != nil {
+		return err
+	}
+
+	enabled.Lock()
+	defer enabled.Unlock()
+
+	for name, on := range gates {
+		gate, _ := lookup(name)
+		if on && gate.Stage == Alpha {
+			logging.Warningf("Enabling alpha feature %s, it may change or be removed in any release", name)
+		} else if on != gate.Default {
+			logging.Infof("Setting feature gate %s=%t", name, on)
+		}
+		enabled.gates[name] = on
+		featureEnabled.Set(value(on), gate.Name, gate.Stage)
+	}
+
+	return nil
+}
+
+/*
+Enabled returns true if the feature gate is enabled.
+*/
+func Enabled(name string) bool {
+	enabled.Lock()
+	defer enabled.Unlock()
+
+	return enabled.gates[name]
+}
+
+/*
+Names returns the names of the feature gates.
+*/
+func Names() []string {
+	names := make([]string, len(Gates))
+	for i, gate := range Gates {
+		names[i] = gate.Name
+	}
+
+	return names
+}
+
+/*
+Reset returns every feature gate to its default.
+*/
+func Reset() {
+	enabled.Lock()
+	defer enabled.Unlock()
+
+	enabled.gates = defaults()
+}
+
+func lookup(name string) (Gate, bool) {
+	for _, gate := range Gates {
+		if gate.Name == name {
+			return gate, true
+		}
+	}
+
+	return Gate{}, false
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package featuregates
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		list     string
+		expGates map[string]bool
+		expErr   string
+	}{
+		{
+			name:     "empty",
+			list:     "",
+			expGates: map[string]bool{},
+		},
+		{
+			name:     "several gates",
+			list:     "QueuePools=true, JsonUdsProtocol=false",
+			expGates: map[string]bool{QueuePools: true, JsonUdsProtocol: false},
+		},
+		{
+			name:   "unknown gate",
+			list:   "QueuePools=true,Teleport=true",
+			expErr: "unknown feature gates [Teleport]",
+		},
+		{
+			name:   "missing value",
+			list:   "QueuePools",
+			expErr: "must be of the form name=true or name=false",
+		},
+		{
+			name:   "invalid value",
+			list:   "QueuePools=maybe",
+			expErr: "must be of the form name=true or name=false",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gates, err := Parse(tc.list)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expGates, gates, "Unexpected feature gates")
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	defer Reset()
+
+	for _, gate := range Gates {
+		assert.Equal(t, gate.Default, Enabled(gate.Name), "Gate should start at its default")
+	}
+
+	require.NoError(t, Set(map[string]bool{QueuePools: true}))
+	require.NoError(t, Set(map[string]bool{JsonUdsProtocol: true}))
+	assert.True(t, Enabled(QueuePools), "Gate set earlier should be kept")
+	assert.True(t, Enabled(JsonUdsProtocol), "Gate should be enabled")
+	assert.False(t, Enabled(DynamicResourceAllocation), "Gate should be left at its default")
+	assert.False(t, Enabled("Teleport"), "Unknown gate should be disabled")
+
+	assert.Error(t, Set(map[string]bool{"Teleport": true}), "Unknown gate should be rejected")
+
+	var out bytes.Buffer
+	require.NoError(t, metrics.WriteAll(&out), "Unexpected error")
+	assert.Contains(t, out.String(), `afxdp_feature_enabled{gate="QueuePools",stage="alpha"} 1`, "Enabled gate should be exposed")
+	assert.Contains(t, out.String(), `afxdp_feature_enabled{gate="DynamicResourceAllocation",stage="alpha"} 0`, "Disabled gate should be exposed")
+
+	Reset()
+	assert.False(t, Enabled(QueuePools), "Gate should be reset to its default")
+}
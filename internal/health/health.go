@@ -0,0 +1,168 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Check returns an error if the part of the device plugin it checks is not healthy.
+Checks are called on every probe, so must be quick.
+*/
+type Check func() error
+
+/*
+Checks is a set of named checks, all of which must pass for the device plugin to be healthy.
+*/
+type Checks struct {
+	lock   sync.Mutex
+	checks map[string]Check
+}
+
+var (
+	/* Liveness checks fail if the device plugin is stuck and must be restarted */
+	Liveness = &Checks{}
+	/* Readiness checks fail until the device plugin is able to serve pods */
+	Readiness = &Checks{}
+)
+
+/*
+Add adds a named check, replacing any check of the same name.
+*/
+func (c *Checks) Add(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.checks == nil {
+		c.checks = make(map[string]Check)
+	}
+	c.checks[name] = check
+}
+
+/*
+Result is the result of a single check, with the error of the check if it failed.
+*/
+type Result struct {
+	Name  string `json:"name"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+/*
+Results runs all checks in name order, returning the result of each check.
+*/
+func (c *Checks) Results() []Result {
+	c.lock.Lock()
+	names := make([]string, 0, len(c.checks))
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		names = append(names, name)
+		checks[name] = check
+	}
+	c.lock.Unlock()
+	sort.Strings(names)
+
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		result := Result{Name: name, Ok: true}
+		if err := checks[name](); err != nil {
+			result.Ok = false
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+/*
+Run runs all checks in name order, returning a report of the result of each check and
+whether all checks passed.
+*/
+func (c *Checks) Run() (string, bool) {
+	var report bytes.Buffer
+	healthy := true
+	for _, result := range c.Results() {
+		if !result.Ok {
+			healthy = false
+			fmt.Fprintf(&report, "[-]%s failed: %s\n", result.Name, result.Error)
+		} else {
+			fmt.Fprintf(&report, "[+]%s ok\n", result.Name)
+		}
+	}
+
+	return report.String(), healthy
+}
+
+/*
+ServeHTTP responds 200 if all checks pass, or 503 if any check fails, with the report of each check.
+*/
+func (c *Checks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report, healthy := c.Run()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if !healthy {
+		logging.Debugf("Health check %s failed:\n%s", r.URL.Path, report)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	fmt.Fprint(w, report)
+}
+
+/*
+Serve starts serving the liveness and readiness checks on addr. The listener is opened before
+returning so that address errors are reported to the caller, requests are then served on a
+Go routine.
+*/
+func Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Errorf("Error opening health listener on %s: %v", addr, err)
+		return err
+	}
+
+	ServeListener(listener)
+
+	return nil
+}
+
+/*
+ServeListener starts serving the liveness and readiness checks on an open listener, such as a
+socket passed by systemd, on a Go routine.
+*/
+func ServeListener(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle(constants.Health.LivenessPath, Liveness)
+	mux.Handle(constants.Health.ReadinessPath, Readiness)
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logging.Errorf("Health server stopped: %v", err)
+		}
+	}()
+
+	addr := listener.Addr()
+	logging.Infof("Serving health checks on %v%s and %v%s", addr, constants.Health.LivenessPath, addr, constants.Health.ReadinessPath)
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package health
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChecks(t *testing.T) {
+	testCases := []struct {
+		name      string
+		checks    map[string]Check
+		expStatus int
+		expReport string
+	}{
+		{
+			name:      "no checks",
+			expStatus: http.StatusOK,
+			expReport: "",
+		},
+		{
+			name: "all checks pass",
+			checks: map[string]Check{
+				"registration": func() error { return nil },
+				"discovery":    func() error { return nil },
+			},
+			expStatus: http.StatusOK,
+			expReport: "[+]discovery ok\n[+]registration ok\n",
+		},
+		{
+			name: "one check fails",
+			checks: map[string]Check{
+				"registration": func() error { return errors.New("pool myPool socket missing") },
+				"discovery":    func() error { return nil },
+			},
+			expStatus: http.StatusServiceUnavailable,
+			expReport: "[+]discovery ok\n[-]registration failed: pool myPool socket missing\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := &Checks{}
+			for name, check := range tc.checks {
+				checks.Add(name, check)
+			}
+
+			rec := httptest.NewRecorder()
+			checks.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, tc.expStatus, rec.Code, "Unexpected status")
+			assert.Equal(t, tc.expReport, rec.Body.String(), "Unexpected report")
+		})
+	}
+}
@@ -16,14 +16,54 @@
 package host
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
 	logging "github.com/sirupsen/logrus"
 	"io/ioutil"
+	"math"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"syscall"
+	"unsafe"
 )
 
+/*
+afXdp is the AF_XDP address family, not defined by the syscall package.
+*/
+const afXdp = 44
+
+/*
+prctl and capget/capset values for dropping capabilities, see linux/prctl.h and
+linux/capability.h.
+*/
+const (
+	prSetKeepCaps          = 8
+	prCapbsetRead          = 23
+	prCapbsetDrop          = 24
+	prCapAmbient           = 47
+	prCapAmbientClearAll   = 4
+	linuxCapabilityVersion = 0x20080522
+)
+
+/*
+capabilityBits are the bits of the capabilities checked for, in the capability sets of
+/proc/<pid>/status, see linux/capability.h.
+*/
+var capabilityBits = map[string]uint{
+	"CAP_CHOWN":     0,
+	"CAP_SETGID":    6,
+	"CAP_SETUID":    7,
+	"CAP_SETPCAP":   8,
+	"CAP_NET_ADMIN": 12,
+	"CAP_NET_RAW":   13,
+	"CAP_IPC_LOCK":  14,
+	"CAP_SYS_ADMIN": 21,
+	"CAP_BPF":       39,
+}
+
 /*
 Handler is the CNI and device plugins interface to the host.
 The interface exists for testing purposes, allowing unit tests to test
@@ -36,6 +76,12 @@ type Handler interface {
 	HasLibbpf() (bool, []string, error)
 	HasDevlink() (bool, string, error)
 	Hostname() (string, error)
+	HasAfxdp() (bool, error)
+	HasBpffs(path string) (bool, error)
+	MissingCapabilities(names ...string) ([]string, error)
+	DropThreadCapabilities(keep ...string) error
+	DropThreadIds(uid int, gid int) error
+	MemlockLimit() (uint64, error)
 }
 
 /*
@@ -197,6 +243,233 @@ func (r *handler) Hostname() (string, error) {
 	return os.Hostname()
 }
 
+/*
+HasAfxdp checks if the host kernel supports AF_XDP sockets and returns a boolean.
+It creates an AF_XDP socket and closes it again, the socket is not bound to a device.
+*/
+func (r *handler) HasAfxdp() (bool, error) {
+	fd, err := syscall.Socket(afXdp, syscall.SOCK_RAW, 0)
+	if err != nil {
+		if errors.Is(err, syscall.EAFNOSUPPORT) {
+			return false, nil
+		}
+		// the kernel only checks for CAP_NET_RAW once it has found the address family
+		if errors.Is(err, syscall.EPERM) {
+			return true, nil
+		}
+		logging.Errorf("Error creating AF_XDP socket: %v", err)
+		return false, err
+	}
+	syscall.Close(fd)
+
+	return true, nil
+}
+
+/*
+HasBpffs checks if a BPF filesystem is mounted at path and returns a boolean.
+It reads the mount table of the current mount namespace from /proc/self/mounts.
+*/
+func (r *handler) HasBpffs(path string) (bool, error) {
+	file, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		logging.Errorf("Error reading mounts: %v", err)
+		return false, err
+	}
+	defer file.Close()
+
+	path = strings.TrimSuffix(path, "/")
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 3 && fields[1] == path && fields[2] == "bpf" {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+/*
+MissingCapabilities checks the effective capabilities of the current process and returns those
+of the named capabilities, e.g. CAP_NET_ADMIN, that it does not have.
+*/
+func (r *handler) MissingCapabilities(names ...string) ([]string, error) {
+	output, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		logging.Errorf("Error reading process status: %v", err)
+		return nil, err
+	}
+
+	var effective uint64
+	found := false
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			effective, err = strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing effective capabilities: %v", err)
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, errors.New("effective capabilities not found in process status")
+	}
+
+	var missing []string
+	for _, name := range names {
+		bit, ok := capabilityBits[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %s", name)
+		}
+		if effective&(1<<bit) == 0 {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing, nil
+}
+
+/*
+capHeader and capData are the arguments of capget and capset, see linux/capability.h.
+*/
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+/*
+DropThreadCapabilities drops every capability but the named ones, e.g. CAP_NET_ADMIN, from the
+calling OS thread: from its bounding and ambient sets, so programs it runs do not regain them, and
+from its effective, permitted and inheritable sets. Named capabilities the thread does not have
+are not gained. Dropping from the bounding set needs CAP_SETPCAP. Capabilities belong to threads,
+not processes, so other threads keep theirs and the caller must lock its Go routine to the thread.
+*/
+func (r *handler) DropThreadCapabilities(keep ...string) error {
+	var kept [2]uint32
+	for _, name := range keep {
+		bit, ok := capabilityBits[name]
+		if !ok {
+			return fmt.Errorf("unknown capability %s", name)
+		}
+		kept[bit/32] |= 1 << (bit % 32)
+	}
+
+	for bit := uintptr(0); bit < 64; bit++ {
+		if kept[bit/32]&(1<<(bit%32)) != 0 {
+			continue
+		}
+		inSet, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetRead, bit, 0)
+		if errno == syscall.EINVAL {
+			break // past the last capability of the kernel
+		}
+		if errno != 0 {
+			return fmt.Errorf("error reading bounding set: %v", errno)
+		}
+		if inSet == 0 {
+			continue
+		}
+		if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapbsetDrop, bit, 0); errno != 0 {
+			return fmt.Errorf("error dropping capability %d from bounding set: %v", bit, errno)
+		}
+	}
+
+	// ambient capabilities are not supported before kernel 4.3
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 && errno != syscall.EINVAL {
+		return fmt.Errorf("error clearing ambient set: %v", errno)
+	}
+
+	header := capHeader{version: linuxCapabilityVersion}
+	var data [2]capData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("error getting capabilities: %v", errno)
+	}
+	for i := range data {
+		data[i].permitted &= kept[i]
+		data[i].effective &= data[i].permitted
+		data[i].inheritable = 0
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("error setting capabilities: %v", errno)
+	}
+
+	return nil
+}
+
+/*
+DropThreadIds switches the calling OS thread to the given user and group, with no supplementary
+groups. The IDs are changed with raw system calls, as the Go runtime and C library change them for
+every thread of the process, so other threads keep theirs and the caller must lock its Go routine to
+the thread. The capabilities of the thread are kept across the change, to be dropped afterwards by
+DropThreadCapabilities, the kernel otherwise clearing them. Changing the IDs needs CAP_SETUID and
+CAP_SETGID, so it must come before the capabilities are dropped.
+*/
+func (r *handler) DropThreadIds(uid int, gid int) error {
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetKeepCaps, 1, 0); errno != 0 {
+		return fmt.Errorf("error keeping capabilities: %v", errno)
+	}
+	defer syscall.RawSyscall(syscall.SYS_PRCTL, prSetKeepCaps, 0, 0)
+
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETGROUPS, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("error clearing supplementary groups: %v", errno)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESGID, uintptr(gid), uintptr(gid), uintptr(gid)); errno != 0 {
+		return fmt.Errorf("error setting group %d: %v", gid, errno)
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_SETRESUID, uintptr(uid), uintptr(uid), uintptr(uid)); errno != 0 {
+		return fmt.Errorf("error setting user %d: %v", uid, errno)
+	}
+
+	// the kernel clears the effective set on leaving root, even with the permitted set kept
+	header := capHeader{version: linuxCapabilityVersion}
+	var data [2]capData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPGET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("error getting capabilities: %v", errno)
+	}
+	for i := range data {
+		data[i].effective = data[i].permitted
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("error setting capabilities: %v", errno)
+	}
+
+	return nil
+}
+
+/*
+MemlockLimit returns the soft limit on locked memory of the current process in bytes,
+math.MaxUint64 if unlimited. It reads the "Max locked memory" limit from /proc/self/limits.
+*/
+func (r *handler) MemlockLimit() (uint64, error) {
+	output, err := ioutil.ReadFile("/proc/self/limits")
+	if err != nil {
+		logging.Errorf("Error reading process limits: %v", err)
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(output), "\n") {
+		if !strings.HasPrefix(line, "Max locked memory") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max locked memory"))
+		if len(fields) == 0 {
+			break
+		}
+		if fields[0] == "unlimited" {
+			return math.MaxUint64, nil
+		}
+		return strconv.ParseUint(fields[0], 10, 64)
+	}
+
+	return 0, errors.New("locked memory limit not found in process limits")
+}
+
 /*
 GivePermissions will give read/write permissions on a file to a specified user id.
 */
@@ -15,6 +15,8 @@
 
 package host
 
+import "sync"
+
 /*
 FakeHandler interface extends the Handler interface to provide additional testing methods.
 */
@@ -22,16 +24,28 @@ type FakeHandler interface {
 	Handler
 	SetKernalVersion(version string)
 	SetAllowsUnprivilegedBpf(allowed bool)
+	SetMissingCapabilities(names ...string)
+	KeptCapabilities() []string
+	ThreadIds() (int, int)
+	SetMemlockLimit(limit uint64)
+	SetError(method string, err error)
 }
 
 /*
 fakeHandler implements the FakeHandler interface.
 */
-type fakeHandler struct{}
+type fakeHandler struct {
+	lock sync.Mutex
+	errs map[string]error // errors set by SetError, keyed on method name
+}
 
 var (
 	kernelVersion        string
 	privilegedBpfAllowed bool
+	missingCapabilities  []string
+	keptCapabilities     []string
+	threadIds            [2]int
+	memlockLimit         uint64
 )
 
 /*
@@ -41,11 +55,42 @@ func NewFakeHandler() FakeHandler {
 	return &fakeHandler{}
 }
 
+/*
+SetError sets an error returned by every call of a Handler method of this fake handler, named as
+in the interface, e.g. "KernelVersion". A nil error clears it.
+*/
+func (r *fakeHandler) SetError(method string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err == nil {
+		delete(r.errs, method)
+		return
+	}
+	if r.errs == nil {
+		r.errs = make(map[string]error)
+	}
+	r.errs[method] = err
+}
+
+/*
+fail returns the error set for a method by SetError, nil if none.
+*/
+func (r *fakeHandler) fail(method string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.errs[method]
+}
+
 /*
 KernelVersion checks the host kernel version and returns it as a string.
 In this FakeHandler it returns a dummy version for testing purposes.
 */
 func (r *fakeHandler) KernelVersion() (string, error) {
+	if err := r.fail("KernelVersion"); err != nil {
+		return "", err
+	}
 	return kernelVersion, nil
 }
 
@@ -58,6 +103,9 @@ HasEthtool checks if the host has ethtool installed and returns a boolean.
 In this FakeHandler it returns a dummy version for testing purposes.
 */
 func (r *fakeHandler) HasEthtool() (bool, string, error) {
+	if err := r.fail("HasEthtool"); err != nil {
+		return false, "", err
+	}
 	return true, "ethtool version 5.4", nil
 }
 
@@ -68,6 +116,9 @@ HasLibbpf checks if the host has libbpf installed and returns a boolean.
 In this FakeHandler it returns a dummy value.
 */
 func (r *fakeHandler) HasLibbpf() (bool, []string, error) {
+	if err := r.fail("HasLibbpf"); err != nil {
+		return false, nil, err
+	}
 	return true, nil, nil
 }
 
@@ -78,6 +129,9 @@ AllowsUnprivilegedBpf checks if the host allows unpriviliged bpf calls and
 returns a boolean. In this FakeHandler it returns a dummy value.
 */
 func (r *fakeHandler) AllowsUnprivilegedBpf() (bool, error) {
+	if err := r.fail("AllowsUnprivilegedBpf"); err != nil {
+		return false, err
+	}
 	return privilegedBpfAllowed, nil
 }
 
@@ -90,6 +144,9 @@ HasDevlink checks if the host has devlink installed and returns a boolean.
 In this FakeHandler it returns a dummy value.
 */
 func (r *fakeHandler) HasDevlink() (bool, string, error) {
+	if err := r.fail("HasDevlink"); err != nil {
+		return false, "", err
+	}
 	return true, "devlink utility, iproute2-ss200127", nil
 }
 
@@ -97,7 +154,102 @@ func (r *fakeHandler) HasDevlink() (bool, string, error) {
 Hostname is a wrapper function for unit testing that calls os.Hostname.
 */
 func (r *fakeHandler) Hostname() (string, error) {
+	if err := r.fail("Hostname"); err != nil {
+		return "", err
+	}
 	return "k8sNode1", nil
 }
 
 //set setter for setDevLink
+
+/*
+HasAfxdp checks if the host kernel supports AF_XDP sockets and returns a boolean.
+In this FakeHandler it returns a dummy value.
+*/
+func (r *fakeHandler) HasAfxdp() (bool, error) {
+	if err := r.fail("HasAfxdp"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+/*
+HasBpffs checks if a BPF filesystem is mounted at path and returns a boolean.
+In this FakeHandler it returns a dummy value.
+*/
+func (r *fakeHandler) HasBpffs(path string) (bool, error) {
+	if err := r.fail("HasBpffs"); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+/*
+MissingCapabilities returns the named capabilities the current process does not have.
+In this FakeHandler it returns the capabilities removed with SetMissingCapabilities.
+*/
+func (r *fakeHandler) MissingCapabilities(names ...string) ([]string, error) {
+	if err := r.fail("MissingCapabilities"); err != nil {
+		return nil, err
+	}
+	var missing []string
+	for _, name := range names {
+		for _, removed := range missingCapabilities {
+			if name == removed {
+				missing = append(missing, name)
+			}
+		}
+	}
+	return missing, nil
+}
+
+func (r *fakeHandler) SetMissingCapabilities(names ...string) {
+	missingCapabilities = names
+}
+
+/*
+DropThreadCapabilities drops every capability but the named ones from the calling OS thread.
+In this FakeHandler it records the named capabilities, returned by KeptCapabilities.
+*/
+func (r *fakeHandler) DropThreadCapabilities(keep ...string) error {
+	if err := r.fail("DropThreadCapabilities"); err != nil {
+		return err
+	}
+	keptCapabilities = keep
+	return nil
+}
+
+func (r *fakeHandler) KeptCapabilities() []string {
+	return keptCapabilities
+}
+
+/*
+DropThreadIds switches the calling OS thread to the given user and group.
+In this FakeHandler it records the IDs, returned by ThreadIds.
+*/
+func (r *fakeHandler) DropThreadIds(uid int, gid int) error {
+	if err := r.fail("DropThreadIds"); err != nil {
+		return err
+	}
+	threadIds = [2]int{uid, gid}
+	return nil
+}
+
+func (r *fakeHandler) ThreadIds() (int, int) {
+	return threadIds[0], threadIds[1]
+}
+
+/*
+MemlockLimit returns the limit on locked memory of the current process in bytes.
+In this FakeHandler it returns the limit set with SetMemlockLimit.
+*/
+func (r *fakeHandler) MemlockLimit() (uint64, error) {
+	if err := r.fail("MemlockLimit"); err != nil {
+		return 0, err
+	}
+	return memlockLimit, nil
+}
+
+func (r *fakeHandler) SetMemlockLimit(limit uint64) {
+	memlockLimit = limit
+}
@@ -0,0 +1,246 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logfile
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+const compressedExt = ".gz"
+
+/*
+Writer writes logs to a file, rotating the file once it reaches a maximum size.
+On rotation the file is renamed <file>.1, older backups are renamed <file>.2 and so on,
+and backups beyond the maximum are removed. Backups are optionally gzip compressed.
+The CNI runs as a new process for every pod, so the file may be written and rotated by
+several processes. The file is reopened rather than rotated if another process already
+rotated it.
+*/
+type Writer struct {
+	lock       sync.Mutex
+	path       string
+	perm       os.FileMode
+	maxSize    int64
+	maxBackups int
+	compress   bool
+	file       *os.File
+	size       int64
+}
+
+/*
+Open opens the named log file in the log directory, rotated once maxSize MB in size and keeping
+backups rotated files. A maxSize or backups of 0 takes the default, a maxSize of -1 disables
+rotation and backups of -1 keeps no rotated files.
+*/
+func Open(name string, maxSize int, backups int, compress bool) (*Writer, error) {
+	return OpenPerm(name, os.FileMode(constants.Logging.FilePermissions), maxSize, backups, compress)
+}
+
+/*
+OpenPerm opens the named log file in the log directory as Open does, creating it with perm
+if it does not exist.
+*/
+func OpenPerm(name string, perm os.FileMode, maxSize int, backups int, compress bool) (*Writer, error) {
+	switch maxSize {
+	case 0:
+		maxSize = constants.Logging.FileMaxSize
+	case -1:
+		maxSize = 0
+	}
+	switch backups {
+	case 0:
+		backups = constants.Logging.FileBackups
+	case -1:
+		backups = 0
+	}
+
+	return New(constants.Logging.Directory+name, perm, int64(maxSize)*1024*1024, backups, compress)
+}
+
+/*
+New opens the log file at path for appending, creating it with perm if it does not exist.
+The file is rotated once larger than maxSize bytes, keeping maxBackups backups.
+A maxSize of 0 disables rotation.
+*/
+func New(path string, perm os.FileMode, maxSize int64, maxBackups int, compress bool) (*Writer, error) {
+	w := &Writer{
+		path:       path,
+		perm:       perm,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+/*
+Write writes p to the log file, first rotating the file if p would take it over the maximum size.
+*/
+func (w *Writer) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.maxSize > 0 && w.size+int64(len(p)) > w.maxSize && w.size > 0 {
+		// rotated from a Go routine of its own, so the files are created with the privileges of the
+		// process even if the writing thread has dropped them, see privileges.DropForHandshake
+		rotated := make(chan error)
+		go func() { rotated <- w.rotate() }()
+		if err := <-rotated; err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+
+	return n, err
+}
+
+/*
+Close closes the log file.
+*/
+func (w *Writer) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.file.Close()
+}
+
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, w.perm)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+
+	return nil
+}
+
+/*
+rotate renames the log file to the first backup, shifting older backups along, and opens a new
+log file. If the file was replaced since it was opened, it was rotated by another process and is
+only reopened.
+*/
+func (w *Writer) rotate() error {
+	opened, err := w.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(w.path)
+	if err == nil && !os.SameFile(opened, current) {
+		w.file.Close()
+		return w.open()
+	}
+
+	w.file.Close()
+
+	if w.maxBackups < 1 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return w.open()
+	}
+
+	os.Remove(w.backup(w.maxBackups, false))
+	os.Remove(w.backup(w.maxBackups, true))
+	for i := w.maxBackups - 1; i > 0; i-- {
+		for _, compressed := range []bool{false, true} {
+			if err := os.Rename(w.backup(i, compressed), w.backup(i+1, compressed)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if err := os.Rename(w.path, w.backup(1, false)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	if w.compress {
+		// a backup that cannot be compressed is kept uncompressed, logging should not fail over it
+		compressFile(w.backup(1, false), w.backup(1, true), w.perm)
+	}
+
+	return nil
+}
+
+/*
+backup returns the path of the nth backup.
+*/
+func (w *Writer) backup(n int, compressed bool) string {
+	path := w.path + "." + strconv.Itoa(n)
+	if compressed {
+		path += compressedExt
+	}
+	return path
+}
+
+/*
+compressFile gzip compresses src to dst, removing src once compressed.
+*/
+func compressFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
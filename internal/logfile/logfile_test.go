@@ -0,0 +1,164 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logfile
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterRotate(t *testing.T) {
+	testCases := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		compress   bool
+		writes     []string
+		expFile    string
+		expBackups []string
+	}{
+		{
+			name:       "no rotation below max size",
+			maxSize:    10,
+			maxBackups: 2,
+			writes:     []string{"aaaa", "bbbb"},
+			expFile:    "aaaabbbb",
+		},
+		{
+			name:       "rotation disabled",
+			maxSize:    0,
+			maxBackups: 2,
+			writes:     []string{"aaaa", "bbbb", "cccc"},
+			expFile:    "aaaabbbbcccc",
+		},
+		{
+			name:       "rotated at max size",
+			maxSize:    10,
+			maxBackups: 2,
+			writes:     []string{"aaaa", "bbbb", "cccc"},
+			expFile:    "cccc",
+			expBackups: []string{"aaaabbbb"},
+		},
+		{
+			name:       "oldest backups removed",
+			maxSize:    4,
+			maxBackups: 2,
+			writes:     []string{"aaaa", "bbbb", "cccc", "dddd"},
+			expFile:    "dddd",
+			expBackups: []string{"cccc", "bbbb"},
+		},
+		{
+			name:       "no backups kept",
+			maxSize:    4,
+			maxBackups: 0,
+			writes:     []string{"aaaa", "bbbb"},
+			expFile:    "bbbb",
+		},
+		{
+			name:       "write larger than max size",
+			maxSize:    4,
+			maxBackups: 2,
+			writes:     []string{"aaaaaaaa", "bbbb"},
+			expFile:    "bbbb",
+			expBackups: []string{"aaaaaaaa"},
+		},
+		{
+			name:       "backups compressed",
+			maxSize:    4,
+			maxBackups: 2,
+			compress:   true,
+			writes:     []string{"aaaa", "bbbb", "cccc"},
+			expFile:    "cccc",
+			expBackups: []string{"bbbb", "aaaa"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+			require.NoError(t, err, "Can't create temporary directory")
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "test.log")
+			w, err := New(path, 0644, tc.maxSize, tc.maxBackups, tc.compress)
+			require.NoError(t, err, "Unexpected error")
+			for _, write := range tc.writes {
+				_, err := w.Write([]byte(write))
+				require.NoError(t, err, "Unexpected error")
+			}
+			require.NoError(t, w.Close(), "Unexpected error")
+
+			assert.Equal(t, tc.expFile, readFile(t, path, false), "Unexpected log file contents")
+			for i, expBackup := range tc.expBackups {
+				assert.Equal(t, expBackup, readFile(t, w.backup(i+1, tc.compress), tc.compress), "Unexpected backup contents")
+			}
+			_, err = os.Stat(w.backup(len(tc.expBackups)+1, tc.compress))
+			assert.True(t, os.IsNotExist(err), "Unexpected backup")
+		})
+	}
+}
+
+func TestWriterRotatedByOtherProcess(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.log")
+	first, err := New(path, 0644, 4, 2, false)
+	require.NoError(t, err, "Unexpected error")
+	defer first.Close()
+	second, err := New(path, 0644, 4, 2, false)
+	require.NoError(t, err, "Unexpected error")
+	defer second.Close()
+
+	// first rotates the file, then second finds its file already rotated
+	for _, write := range []struct {
+		w    *Writer
+		data string
+	}{{first, "aaaa"}, {second, "bbbb"}, {first, "cccc"}, {second, "dddd"}} {
+		_, err := write.w.Write([]byte(write.data))
+		require.NoError(t, err, "Unexpected error")
+	}
+
+	assert.Equal(t, "ccccdddd", readFile(t, path, false), "Log file should be reopened, not rotated again")
+	assert.Equal(t, "aaaabbbb", readFile(t, first.backup(1, false), false), "Unexpected backup contents")
+	_, err = os.Stat(first.backup(2, false))
+	assert.True(t, os.IsNotExist(err), "Unexpected backup")
+}
+
+func readFile(t *testing.T, path string, compressed bool) string {
+	file, err := os.Open(path)
+	require.NoError(t, err, "Can't open file")
+	defer file.Close()
+
+	if !compressed {
+		contents, err := ioutil.ReadAll(file)
+		require.NoError(t, err, "Can't read file")
+		return string(contents)
+	}
+
+	gz, err := gzip.NewReader(file)
+	require.NoError(t, err, "Can't read compressed file")
+	contents, err := ioutil.ReadAll(gz)
+	require.NoError(t, err, "Can't read compressed file")
+	return string(contents)
+}
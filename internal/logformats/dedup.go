@@ -0,0 +1,197 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+repeatField marks the entries logged by flush to summarise suppressed lines.
+*/
+const repeatField = "logformats.repeat"
+
+/*
+Dedup suppresses log lines identical to a line logged within the dedup interval, so a single
+misbehaving client cannot fill the node disk with the same error. Lines are identical if they
+have the same level and message. Once the interval has passed, the number of lines suppressed
+is logged as a summary, before the next identical line or by a Go routine flushing summaries
+every interval. Panic and fatal lines are never suppressed. See SetDedup.
+*/
+type Dedup struct {
+	Formatter logging.Formatter
+}
+
+/*
+repeat records the first line logged in an interval and how many times it was repeated.
+*/
+type repeat struct {
+	first      logging.Entry
+	suppressed int
+}
+
+var dedup = struct {
+	sync.Mutex
+	interval time.Duration
+	repeats  map[string]*repeat
+	stop     chan struct{}
+}{
+	repeats: make(map[string]*repeat),
+}
+
+/*
+Format formats the entry with the wrapped Formatter, or returns nothing if the entry is suppressed.
+The entry is preceded by a summary of its repeats in the previous interval, if any.
+*/
+func (d *Dedup) Format(entry *logging.Entry) ([]byte, error) {
+	if r, ok := entry.Data[repeatField].(*repeat); ok {
+		return d.Formatter.Format(summary(r, entry.Time, entry.Buffer))
+	}
+	if entry.Level <= logging.FatalLevel {
+		return d.Formatter.Format(entry)
+	}
+
+	dedup.Lock()
+	defer dedup.Unlock()
+
+	if dedup.interval <= 0 {
+		return d.Formatter.Format(entry)
+	}
+
+	key := entry.Level.String() + "\xff" + entry.Message
+	r, ok := dedup.repeats[key]
+	if ok && entry.Time.Sub(r.first.Time) < dedup.interval {
+		r.suppressed++
+		return nil, nil
+	}
+
+	first := *entry
+	first.Buffer = nil
+	dedup.repeats[key] = &repeat{first: first}
+
+	if !ok || r.suppressed == 0 {
+		return d.Formatter.Format(entry)
+	}
+
+	prefix, err := d.Formatter.Format(summary(r, entry.Time, nil))
+	if err != nil {
+		return nil, err
+	}
+	line, err := d.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(prefix, line...), nil
+}
+
+/*
+summary returns an entry summarising the repeats of a line, logged at the level and from the
+caller of the first line.
+*/
+func summary(r *repeat, now time.Time, buffer *bytes.Buffer) *logging.Entry {
+	entry := r.first
+	entry.Time = now
+	entry.Buffer = buffer
+	entry.Message = fmt.Sprintf("%s (repeated %d times in %s)", r.first.Message, r.suppressed, now.Sub(r.first.Time).Round(time.Second))
+
+	return &entry
+}
+
+/*
+flush logs a summary of each line that was repeated in an interval that has passed, and forgets
+the lines of intervals that have passed.
+*/
+func flush(now time.Time) {
+	dedup.Lock()
+	var summaries []*repeat
+	for key, r := range dedup.repeats {
+		if now.Sub(r.first.Time) < dedup.interval {
+			continue
+		}
+		if r.suppressed > 0 {
+			summaries = append(summaries, r)
+		}
+		delete(dedup.repeats, key)
+	}
+	dedup.Unlock()
+
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].first.Time.Before(summaries[j].first.Time) })
+	for _, r := range summaries {
+		if r.first.Logger != nil {
+			r.first.Logger.WithTime(now).WithField(repeatField, r).Log(r.first.Level, r.first.Message)
+		}
+	}
+}
+
+/*
+SetDedup suppresses identical log lines logged within interval of each other, see Dedup.
+An interval of 0 stops suppression. The formatter of the standard logger is wrapped, and the
+formatters set by SetLevel are wrapped for as long as suppression is on.
+*/
+func SetDedup(interval time.Duration) {
+	dedup.Lock()
+	if dedup.stop != nil {
+		close(dedup.stop)
+		dedup.stop = nil
+	}
+	dedup.interval = interval
+	if interval > 0 {
+		dedup.stop = make(chan struct{})
+		go flushEvery(interval, dedup.stop)
+	}
+	dedup.Unlock()
+
+	formatter := logging.StandardLogger().Formatter
+	if d, ok := formatter.(*Dedup); ok {
+		formatter = d.Formatter
+	}
+	logging.SetFormatter(withDedup(formatter))
+}
+
+func flushEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			flush(now)
+		}
+	}
+}
+
+/*
+withDedup wraps formatter in a Dedup if suppression is on.
+*/
+func withDedup(formatter logging.Formatter) logging.Formatter {
+	dedup.Lock()
+	defer dedup.Unlock()
+
+	if dedup.interval <= 0 {
+		return formatter
+	}
+
+	return &Dedup{Formatter: formatter}
+}
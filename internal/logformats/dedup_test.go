@@ -0,0 +1,115 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lineFormatter struct{}
+
+func (f lineFormatter) Format(entry *logging.Entry) ([]byte, error) {
+	return []byte(entry.Level.String() + ": " + entry.Message + "\n"), nil
+}
+
+/*
+dedupLogger returns a logger suppressing repeated lines within a minute, writing to out,
+and a function that stops suppression.
+*/
+func dedupLogger(out *bytes.Buffer) (*logging.Logger, func()) {
+	dedup.Lock()
+	dedup.interval = time.Minute
+	dedup.repeats = make(map[string]*repeat)
+	dedup.Unlock()
+	stop := func() {
+		dedup.Lock()
+		dedup.interval = 0
+		dedup.Unlock()
+	}
+
+	logger := logging.New()
+	logger.Out = out
+	logger.Formatter = &Dedup{Formatter: lineFormatter{}}
+
+	return logger, stop
+}
+
+func TestDedup(t *testing.T) {
+	var out bytes.Buffer
+	logger, stop := dedupLogger(&out)
+	defer stop()
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		logger.WithTime(start.Add(time.Duration(i) * time.Second)).Error("Recvmsg failed: broken pipe")
+	}
+	logger.WithTime(start.Add(5 * time.Second)).Warn("Recvmsg failed: broken pipe")
+	logger.WithTime(start.Add(6 * time.Second)).Error("Pod podA refused")
+	logger.WithTime(start.Add(70 * time.Second)).Error("Recvmsg failed: broken pipe")
+	logger.WithTime(start.Add(71 * time.Second)).Error("Recvmsg failed: broken pipe")
+
+	assert.Equal(t, "error: Recvmsg failed: broken pipe\n"+
+		"warning: Recvmsg failed: broken pipe\n"+
+		"error: Pod podA refused\n"+
+		"error: Recvmsg failed: broken pipe (repeated 4 times in 1m10s)\n"+
+		"error: Recvmsg failed: broken pipe\n", out.String(), "Unexpected log output")
+}
+
+func TestDedupFlush(t *testing.T) {
+	var out bytes.Buffer
+	logger, stop := dedupLogger(&out)
+	defer stop()
+	start := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	logger.WithTime(start).Error("Recvmsg failed: broken pipe")
+	logger.WithTime(start.Add(time.Second)).Error("Recvmsg failed: broken pipe")
+	logger.WithTime(start.Add(2 * time.Second)).Error("Recvmsg failed: broken pipe")
+	logger.WithTime(start).Info("Pool myPool started")
+	out.Reset()
+
+	flush(start.Add(30 * time.Second))
+	assert.Empty(t, out.String(), "Nothing should be flushed within the interval")
+
+	flush(start.Add(90 * time.Second))
+	assert.Equal(t, "error: Recvmsg failed: broken pipe (repeated 2 times in 1m30s)\n", out.String(), "Unexpected summary")
+
+	dedup.Lock()
+	require.Empty(t, dedup.repeats, "Lines of passed intervals should be forgotten")
+	dedup.Unlock()
+
+	out.Reset()
+	logger.WithTime(start.Add(91 * time.Second)).Error("Recvmsg failed: broken pipe")
+	assert.Equal(t, "error: Recvmsg failed: broken pipe\n", out.String(), "Summarised line should not be summarised again")
+}
+
+func TestDedupFatal(t *testing.T) {
+	var out bytes.Buffer
+	_, stop := dedupLogger(&out)
+	defer stop()
+	d := &Dedup{Formatter: lineFormatter{}}
+
+	for i := 0; i < 2; i++ {
+		line, err := d.Format(&logging.Entry{Level: logging.PanicLevel, Message: "Out of memory", Time: time.Now()})
+		require.NoError(t, err)
+		assert.Equal(t, "panic: Out of memory\n", string(line), "Panic lines should never be suppressed")
+	}
+}
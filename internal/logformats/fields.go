@@ -0,0 +1,86 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Workload returns the fields tying a log line to the pod instance and container it is about,
+leaving out any that are unknown. The pod is logged as namespace/name.
+*/
+func Workload(namespace, pod, podUid, containerId string) logging.Fields {
+	fields := logging.Fields{}
+	if pod != "" {
+		if namespace != "" {
+			pod = namespace + "/" + pod
+		}
+		fields["pod"] = pod
+	}
+	if podUid != "" {
+		fields["podUid"] = podUid
+	}
+	if containerId != "" {
+		fields["containerId"] = containerId
+	}
+
+	return fields
+}
+
+var processFields = struct {
+	sync.Mutex
+	once   sync.Once
+	fields logging.Fields
+}{}
+
+/*
+fieldsHook adds the process fields to every entry, see SetFields.
+*/
+type fieldsHook struct{}
+
+func (fieldsHook) Levels() []logging.Level {
+	return logging.AllLevels
+}
+
+func (fieldsHook) Fire(entry *logging.Entry) error {
+	processFields.Lock()
+	defer processFields.Unlock()
+
+	for key, value := range processFields.fields {
+		if _, ok := entry.Data[key]; !ok {
+			entry.Data[key] = value
+		}
+	}
+
+	return nil
+}
+
+/*
+SetFields adds fields to every line logged by the process, replacing any fields previously set.
+Fields set on the entry itself take precedence. It suits processes that handle a single workload,
+such as a CNI invocation.
+*/
+func SetFields(fields logging.Fields) {
+	processFields.once.Do(func() { logging.AddHook(fieldsHook{}) })
+
+	processFields.Lock()
+	defer processFields.Unlock()
+
+	processFields.fields = fields
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"testing"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		namespace   string
+		pod         string
+		podUid      string
+		containerId string
+		expFields   logging.Fields
+	}{
+		{
+			name:        "all known",
+			namespace:   "default",
+			pod:         "pod-1",
+			podUid:      "1234-abcd",
+			containerId: "c0ffee",
+			expFields:   logging.Fields{"pod": "default/pod-1", "podUid": "1234-abcd", "containerId": "c0ffee"},
+		},
+		{
+			name:      "namespace unknown",
+			pod:       "pod-1",
+			expFields: logging.Fields{"pod": "pod-1"},
+		},
+		{
+			name:        "pod unknown",
+			namespace:   "default",
+			containerId: "c0ffee",
+			expFields:   logging.Fields{"containerId": "c0ffee"},
+		},
+		{
+			name:      "nothing known",
+			expFields: logging.Fields{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expFields, Workload(tc.namespace, tc.pod, tc.podUid, tc.containerId))
+		})
+	}
+}
+
+func TestSetFields(t *testing.T) {
+	defer SetFields(nil)
+
+	SetFields(logging.Fields{"pod": "default/pod-1", "containerId": "c0ffee"})
+	entry := &logging.Entry{Data: logging.Fields{"pod": "default/pod-2"}}
+	assert.NoError(t, fieldsHook{}.Fire(entry))
+	assert.Equal(t, logging.Fields{"pod": "default/pod-2", "containerId": "c0ffee"}, entry.Data,
+		"Process fields should be added without replacing the fields of the entry")
+
+	SetFields(logging.Fields{"podUid": "1234-abcd"})
+	entry = &logging.Entry{Data: logging.Fields{}}
+	assert.NoError(t, fieldsHook{}.Fire(entry))
+	assert.Equal(t, logging.Fields{"podUid": "1234-abcd"}, entry.Data, "Fields should be replaced")
+}
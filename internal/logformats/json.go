@@ -0,0 +1,74 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+JSON formats entries as one JSON object per line, for log collectors, and JSONDebug when
+debugging. Timestamps are RFC 3339. See SetJSON.
+*/
+var (
+	JSON = &logging.JSONFormatter{
+		CallerPrettyfier: Default.CallerPrettyfier,
+	}
+	JSONDebug = &logging.JSONFormatter{
+		CallerPrettyfier: Debug.CallerPrettyfier,
+	}
+)
+
+var jsonOut = struct {
+	sync.Mutex
+	on bool
+}{}
+
+/*
+SetJSON writes all further log entries as JSON, in place of text. It must be called before
+SetDedup and SetLevel, which keep writing JSON. Entries written to syslog are not affected.
+*/
+func SetJSON() {
+	jsonOut.Lock()
+	jsonOut.on = true
+	jsonOut.Unlock()
+
+	logging.SetFormatter(withJSON(withSyslog(Default)))
+}
+
+/*
+withJSON replaces formatter, Default or Debug, with JSON or JSONDebug if writing JSON. Other
+formatters, such as Syslog, are returned as they are.
+*/
+func withJSON(formatter logging.Formatter) logging.Formatter {
+	jsonOut.Lock()
+	defer jsonOut.Unlock()
+
+	if !jsonOut.on {
+		return formatter
+	}
+
+	switch formatter {
+	case Default:
+		return JSON
+	case Debug:
+		return JSONDebug
+	}
+
+	return formatter
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithJSON(t *testing.T) {
+	assert.Equal(t, Default, withJSON(Default), "Formatter should be kept when not writing JSON")
+
+	jsonOut.on = true
+	defer func() { jsonOut.on = false }()
+
+	assert.Equal(t, JSON, withJSON(Default), "Default should be replaced")
+	assert.Equal(t, JSONDebug, withJSON(Debug), "Debug should be replaced")
+
+	syslogFormatter := &Syslog{Formatter: syslogDefault, Writer: &fakeSyslog{}}
+	assert.Equal(t, syslogFormatter, withJSON(syslogFormatter), "Syslog should be kept")
+}
+
+func TestJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger := logging.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(JSON)
+	logger.SetReportCaller(true)
+
+	logger.WithField("pool", "pool1").Info("Pool started")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), "Entry should be JSON")
+	assert.Equal(t, "Pool started", entry["msg"], "Unexpected message")
+	assert.Equal(t, "info", entry["level"], "Unexpected level")
+	assert.Equal(t, "pool1", entry["pool"], "Unexpected field")
+	assert.NotContains(t, entry, "func", "Caller should not be logged outside of debug")
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"fmt"
+	"path"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+subsystemPackages maps package directories to the subsystem whose log level applies to them.
+*/
+var subsystemPackages = map[string]string{
+	"internal/uds":          "uds",
+	"internal/udsserver":    "uds",
+	"internal/deviceplugin": "deviceplugin",
+	"cmd/deviceplugin":      "deviceplugin",
+	"internal/cni":          "cni",
+	"cmd/cni":               "cni",
+	"internal/bpf":          "bpf",
+	"internal/networking":   "networking",
+}
+
+/*
+LevelFilter drops entries logged below the log level of the subsystem they are logged from,
+passing all other entries to Formatter. Entries from packages outside of any subsystem with a
+level are logged at Level. The subsystem is found from the caller of the entry, so the logger
+must report callers, and the logger level must be the most verbose level for entries to reach
+the filter.
+*/
+type LevelFilter struct {
+	Formatter  logging.Formatter
+	Level      logging.Level
+	Subsystems map[string]logging.Level
+}
+
+/*
+Format formats the entry with the wrapped Formatter, or returns nothing if the entry is dropped.
+*/
+func (f *LevelFilter) Format(entry *logging.Entry) ([]byte, error) {
+	level := f.Level
+	if entry.Caller != nil {
+		if subsystemLevel, ok := f.Subsystems[subsystem(entry.Caller.File)]; ok {
+			level = subsystemLevel
+		}
+	}
+	if entry.Level > level {
+		return nil, nil
+	}
+
+	return f.Formatter.Format(entry)
+}
+
+/*
+subsystem returns the subsystem of the source file, or an empty string if it is not part of one.
+*/
+func subsystem(file string) string {
+	dir := path.Dir(file)
+	return subsystemPackages[path.Base(path.Dir(dir))+"/"+path.Base(dir)]
+}
+
+/*
+SetLevel sets the log level, overridden for subsystems by the levels in subsystemLevels.
+The debug format is used if any level is debug or more verbose. Entries are written to syslog
+if SetSyslog was called, and as JSON if SetJSON was called.
+*/
+func SetLevel(logLevel string, subsystemLevels map[string]string) error {
+	level, err := logging.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
+	maxLevel := level
+	subsystems := make(map[string]logging.Level)
+	for name, subsystemLevel := range subsystemLevels {
+		parsed, err := logging.ParseLevel(subsystemLevel)
+		if err != nil {
+			return fmt.Errorf("subsystem %s: %w", name, err)
+		}
+		subsystems[name] = parsed
+		if parsed > maxLevel {
+			maxLevel = parsed
+		}
+	}
+
+	var formatter logging.Formatter = Default
+	if maxLevel >= logging.DebugLevel {
+		formatter = Debug
+	}
+	formatter = withJSON(withSyslog(formatter))
+	if len(subsystems) > 0 {
+		formatter = &LevelFilter{
+			Formatter:  formatter,
+			Level:      level,
+			Subsystems: subsystems,
+		}
+	}
+
+	logging.SetLevel(maxLevel)
+	logging.SetFormatter(withDedup(formatter))
+
+	return nil
+}
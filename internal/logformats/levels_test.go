@@ -0,0 +1,166 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"runtime"
+	"testing"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type messageFormatter struct{}
+
+func (f messageFormatter) Format(entry *logging.Entry) ([]byte, error) {
+	return []byte(entry.Message), nil
+}
+
+func TestLevelFilter(t *testing.T) {
+	filter := &LevelFilter{
+		Formatter: messageFormatter{},
+		Level:     logging.WarnLevel,
+		Subsystems: map[string]logging.Level{
+			"uds":        logging.DebugLevel,
+			"networking": logging.ErrorLevel,
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		file    string
+		level   logging.Level
+		expDrop bool
+	}{
+		{
+			name:  "subsystem more verbose than default",
+			file:  "/src/afxdp/internal/udsserver/udsserver.go",
+			level: logging.DebugLevel,
+		},
+		{
+			name:    "subsystem below its level",
+			file:    "/src/afxdp/internal/uds/uds.go",
+			level:   logging.TraceLevel,
+			expDrop: true,
+		},
+		{
+			name:    "subsystem less verbose than default",
+			file:    "/src/afxdp/internal/networking/networking.go",
+			level:   logging.WarnLevel,
+			expDrop: true,
+		},
+		{
+			name:  "subsystem at its level",
+			file:  "/src/afxdp/internal/networking/networking.go",
+			level: logging.ErrorLevel,
+		},
+		{
+			name:    "subsystem without a level",
+			file:    "/src/afxdp/internal/deviceplugin/poolManager.go",
+			level:   logging.InfoLevel,
+			expDrop: true,
+		},
+		{
+			name:    "package outside of subsystems",
+			file:    "/src/afxdp/internal/host/host.go",
+			level:   logging.InfoLevel,
+			expDrop: true,
+		},
+		{
+			name:  "package outside of subsystems at default level",
+			file:  "/src/afxdp/internal/host/host.go",
+			level: logging.WarnLevel,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := &logging.Entry{
+				Message: "message",
+				Level:   tc.level,
+				Caller:  &runtime.Frame{File: tc.file},
+			}
+
+			out, err := filter.Format(entry)
+			require.NoError(t, err, "Unexpected error")
+			if tc.expDrop {
+				assert.Empty(t, out, "Entry should be dropped")
+			} else {
+				assert.Equal(t, "message", string(out), "Entry should be formatted")
+			}
+		})
+	}
+}
+
+func TestSubsystem(t *testing.T) {
+	testCases := []struct {
+		file         string
+		expSubsystem string
+	}{
+		{"/usr/src/afxdp_k8s_plugins/internal/udsserver/udsserver.go", "uds"},
+		{"/usr/src/afxdp_k8s_plugins/cmd/deviceplugin/main.go", "deviceplugin"},
+		{"/usr/src/afxdp_k8s_plugins/cmd/cni/main.go", "cni"},
+		{"/usr/src/afxdp_k8s_plugins/internal/bpf/bpfWrapper.go", "bpf"},
+		{"/usr/src/afxdp_k8s_plugins/internal/resourcesapi/cache.go", ""},
+		{"main.go", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.file, func(t *testing.T) {
+			assert.Equal(t, tc.expSubsystem, subsystem(tc.file), "Unexpected subsystem")
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	testCases := []struct {
+		name            string
+		level           string
+		subsystemLevels map[string]string
+		expErr          bool
+	}{
+		{
+			name:  "level",
+			level: "warn",
+		},
+		{
+			name:            "subsystem levels",
+			level:           "info",
+			subsystemLevels: map[string]string{"uds": "trace", "bpf": "error"},
+		},
+		{
+			name:   "invalid level",
+			level:  "verbose",
+			expErr: true,
+		},
+		{
+			name:            "invalid subsystem level",
+			level:           "info",
+			subsystemLevels: map[string]string{"uds": "verbose"},
+			expErr:          true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := SetLevel(tc.level, tc.subsystemLevels)
+			assert.Equal(t, tc.expErr, err != nil, "Unexpected error: %v", err)
+		})
+	}
+	logging.SetLevel(logging.InfoLevel)
+	logging.SetFormatter(Default)
+}
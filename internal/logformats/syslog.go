@@ -0,0 +1,130 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"log/syslog"
+	"strings"
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+SyslogWriter writes messages to syslog at a priority, implemented by *syslog.Writer.
+*/
+type SyslogWriter interface {
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+}
+
+/*
+Syslog writes entries to the local syslog, at the syslog priority of their level, and returns
+nothing for the logger output. Syslog and the journal add their own timestamps, so entries are
+formatted without timestamps or colours. Syslog takes the place of Default or Debug, so that
+entries dropped by a LevelFilter or suppressed by Dedup are not written. See SetSyslog.
+*/
+type Syslog struct {
+	Formatter logging.Formatter
+	Writer    SyslogWriter
+}
+
+/*
+syslogDefault formats entries written to syslog, and syslogDebug when debugging.
+*/
+var (
+	syslogDefault = &logging.TextFormatter{
+		DisableColors:    true,
+		DisableTimestamp: true,
+		CallerPrettyfier: Default.CallerPrettyfier,
+	}
+	syslogDebug = &logging.TextFormatter{
+		DisableColors:    true,
+		DisableTimestamp: true,
+		CallerPrettyfier: Debug.CallerPrettyfier,
+	}
+)
+
+/*
+Format writes the entry to syslog at the priority of its level.
+*/
+func (s *Syslog) Format(entry *logging.Entry) ([]byte, error) {
+	line, err := s.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	message := strings.TrimSuffix(string(line), "\n")
+
+	switch entry.Level {
+	case logging.PanicLevel, logging.FatalLevel:
+		err = s.Writer.Crit(message)
+	case logging.ErrorLevel:
+		err = s.Writer.Err(message)
+	case logging.WarnLevel:
+		err = s.Writer.Warning(message)
+	case logging.InfoLevel:
+		err = s.Writer.Info(message)
+	default:
+		err = s.Writer.Debug(message)
+	}
+
+	return nil, err
+}
+
+var syslogOut = struct {
+	sync.Mutex
+	writer SyslogWriter
+}{}
+
+/*
+SetSyslog writes all further log entries to the local syslog, tagged with tag, in place of the
+logger output. On systemd nodes syslog is forwarded to the journal. It must be called before
+SetDedup and SetLevel, which keep writing to syslog.
+*/
+func SetSyslog(tag string) error {
+	writer, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return err
+	}
+
+	syslogOut.Lock()
+	syslogOut.writer = writer
+	syslogOut.Unlock()
+
+	logging.SetFormatter(withSyslog(Default))
+
+	return nil
+}
+
+/*
+withSyslog replaces formatter, Default or Debug, with a Syslog if writing to syslog.
+*/
+func withSyslog(formatter logging.Formatter) logging.Formatter {
+	syslogOut.Lock()
+	defer syslogOut.Unlock()
+
+	if syslogOut.writer == nil {
+		return formatter
+	}
+	if formatter == Debug {
+		return &Syslog{Formatter: syslogDebug, Writer: syslogOut.writer}
+	}
+
+	return &Syslog{Formatter: syslogDefault, Writer: syslogOut.writer}
+}
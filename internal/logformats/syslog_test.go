@@ -0,0 +1,80 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package logformats
+
+import (
+	"testing"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSyslog struct {
+	priority string
+	message  string
+}
+
+func (f *fakeSyslog) write(priority string, m string) error {
+	f.priority, f.message = priority, m
+	return nil
+}
+
+func (f *fakeSyslog) Crit(m string) error    { return f.write("crit", m) }
+func (f *fakeSyslog) Err(m string) error     { return f.write("err", m) }
+func (f *fakeSyslog) Warning(m string) error { return f.write("warning", m) }
+func (f *fakeSyslog) Info(m string) error    { return f.write("info", m) }
+func (f *fakeSyslog) Debug(m string) error   { return f.write("debug", m) }
+
+func TestSyslog(t *testing.T) {
+	testCases := []struct {
+		name        string
+		level       logging.Level
+		expPriority string
+	}{
+		{name: "panic", level: logging.PanicLevel, expPriority: "crit"},
+		{name: "fatal", level: logging.FatalLevel, expPriority: "crit"},
+		{name: "error", level: logging.ErrorLevel, expPriority: "err"},
+		{name: "warning", level: logging.WarnLevel, expPriority: "warning"},
+		{name: "info", level: logging.InfoLevel, expPriority: "info"},
+		{name: "debug", level: logging.DebugLevel, expPriority: "debug"},
+		{name: "trace", level: logging.TraceLevel, expPriority: "debug"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			writer := &fakeSyslog{}
+			formatter := &Syslog{Formatter: lineFormatter{}, Writer: writer}
+
+			out, err := formatter.Format(&logging.Entry{Level: tc.level, Message: "Device ens785f0 not recognised"})
+			require.NoError(t, err)
+			assert.Empty(t, out, "Nothing should be written to the logger output")
+			assert.Equal(t, tc.expPriority, writer.priority, "Unexpected priority")
+			assert.Equal(t, tc.level.String()+": Device ens785f0 not recognised", writer.message, "Unexpected message")
+		})
+	}
+}
+
+func TestWithSyslog(t *testing.T) {
+	assert.Equal(t, Default, withSyslog(Default), "Formatter should be kept when not writing to syslog")
+
+	writer := &fakeSyslog{}
+	syslogOut.writer = writer
+	defer func() { syslogOut.writer = nil }()
+
+	assert.Equal(t, &Syslog{Formatter: syslogDefault, Writer: writer}, withSyslog(Default), "Default should be replaced")
+	assert.Equal(t, &Syslog{Formatter: syslogDebug, Writer: writer}, withSyslog(Debug), "Debug should be replaced")
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"runtime"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+buildInfo is always 1, its labels identify the build of the plugins serving the metrics.
+*/
+var buildInfo = func() *Vec {
+	v := register(newVec("build_info",
+		"Version, git commit, build date and Go version of the plugins, always 1.", kindGauge,
+		[]string{"version", "commit", "build_date", "go_version"}))
+	v.Set(1, constants.Plugins.Version, constants.Plugins.Commit, constants.Plugins.BuildDate, runtime.Version())
+	return v
+}()
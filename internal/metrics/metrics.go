@@ -0,0 +1,424 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	kindGauge     = "gauge"
+	kindCounter   = "counter"
+	kindHistogram = "histogram"
+)
+
+/*
+DurationBuckets are histogram bucket upper bounds, in seconds, suited to the latency of calls
+to local services such as the kubelet.
+*/
+var DurationBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+/*
+LifetimeBuckets are histogram bucket upper bounds, in seconds, suited to the lifetime of long
+lived objects such as pod connections, from a second to a day.
+*/
+var LifetimeBuckets = []float64{1, 10, 60, 300, 900, 3600, 4 * 3600, 12 * 3600, 24 * 3600}
+
+var (
+	registry     = make(map[string]*Vec)
+	registryLock sync.Mutex
+	serving      bool
+)
+
+/*
+Vec is a family of metric samples sharing a name, help text and set of label names.
+Each sample is identified by its label values. Samples are exposed in the Prometheus
+text exposition format.
+*/
+type Vec struct {
+	name        string
+	help        string
+	kind        string
+	labelNames  []string
+	buckets     []float64
+	samples     map[string]*sample
+	unlimited   bool // exempt from the maximum number of series
+	limitWarned bool
+	lock        sync.Mutex
+}
+
+type sample struct {
+	labelValues []string
+	value       float64   // the gauge or counter value, or the sum of histogram observations
+	counts      []float64 // histogram observations per bucket, not cumulative
+	count       float64   // number of histogram observations
+}
+
+/*
+NewGaugeVec creates and registers a gauge metric family. The name is prefixed
+with the plugin metrics namespace. Label names must follow the label schema, see schema.go.
+*/
+func NewGaugeVec(name, help string, labelNames ...string) *Vec {
+	return register(newVec(name, help, kindGauge, labelNames))
+}
+
+/*
+NewCounterVec creates and registers a counter metric family. The name is prefixed
+with the plugin metrics namespace and should end in _total.
+*/
+func NewCounterVec(name, help string, labelNames ...string) *Vec {
+	return register(newVec(name, help, kindCounter, labelNames))
+}
+
+/*
+NewHistogramVec creates and registers a histogram metric family with the given bucket upper
+bounds, in increasing order. The name is prefixed with the plugin metrics namespace.
+*/
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *Vec {
+	v := newVec(name, help, kindHistogram, labelNames)
+	v.buckets = buckets
+	return register(v)
+}
+
+/*
+newVec creates a metric family. Metrics are defined at package level, so a metric that breaks
+the label schema panics on start up and is caught by the tests of the package defining it.
+*/
+func newVec(name, help, kind string, labelNames []string) *Vec {
+	if err := checkSchema(name, labelNames); err != nil {
+		panic(err)
+	}
+
+	return &Vec{
+		name:       constants.Metrics.Namespace + "_" + name,
+		help:       help,
+		kind:       kind,
+		labelNames: labelNames,
+		samples:    make(map[string]*sample),
+	}
+}
+
+func register(v *Vec) *Vec {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	if existing, ok := registry[v.name]; ok {
+		return existing
+	}
+	registry[v.name] = v
+
+	return v
+}
+
+/*
+Set sets the value of the sample identified by labelValues.
+*/
+func (v *Vec) Set(value float64, labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.getSample(labelValues).value = value
+}
+
+/*
+Add adds to the value of the sample identified by labelValues.
+*/
+func (v *Vec) Add(value float64, labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.getSample(labelValues).value += value
+}
+
+/*
+Observe records an observation in the histogram sample identified by labelValues.
+*/
+func (v *Vec) Observe(value float64, labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	s := v.getSample(labelValues)
+	if s.counts == nil {
+		s.counts = make([]float64, len(v.buckets))
+	}
+	for i, bound := range v.buckets {
+		if value <= bound {
+			s.counts[i]++
+			break
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+/*
+Dec decrements the sample identified by labelValues, removing it once it reaches zero. It suits
+gauges counting things that come and go, which then leave no samples behind.
+*/
+func (v *Vec) Dec(labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	key := sampleKey(v.labelValues(labelValues))
+	if s, ok := v.samples[key]; ok {
+		s.value--
+		if s.value <= 0 {
+			delete(v.samples, key)
+		}
+	}
+}
+
+/*
+Delete removes the sample identified by labelValues.
+*/
+func (v *Vec) Delete(labelValues ...string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	delete(v.samples, sampleKey(v.labelValues(labelValues)))
+}
+
+/*
+DeleteMatching removes all samples whose label labelName has the value labelValue.
+*/
+func (v *Vec) DeleteMatching(labelName, labelValue string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for i, name := range v.labelNames {
+		if name != labelName {
+			continue
+		}
+		for key, s := range v.samples {
+			if s.labelValues[i] == labelValue {
+				delete(v.samples, key)
+			}
+		}
+	}
+}
+
+/*
+getSample returns the sample identified by labelValues, creating it if required. A sample of
+a new series beyond the series limit is returned but not recorded.
+*/
+func (v *Vec) getSample(labelValues []string) *sample {
+	values := v.labelValues(labelValues)
+
+	key := sampleKey(values)
+	s, ok := v.samples[key]
+	if !ok {
+		s = &sample{labelValues: values}
+		if v.atLimit() {
+			return s
+		}
+		v.samples[key] = s
+	}
+
+	return s
+}
+
+func sampleKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+/*
+write writes the metric family in the Prometheus text exposition format.
+Samples are sorted so the output is stable between scrapes. Labels with an empty value are
+not written.
+*/
+func (v *Vec) write(w io.Writer) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escape(v.help, false), v.name, v.kind); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := v.samples[key]
+		labels := make([]string, 0, len(v.labelNames))
+		for i, name := range v.labelNames {
+			if s.labelValues[i] == "" {
+				continue
+			}
+			labels = append(labels, name+"=\""+escape(s.labelValues[i], true)+"\"")
+		}
+
+		if v.kind == kindHistogram {
+			if err := v.writeHistogram(w, labels, s); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeSample(w, v.name, labels, s.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+writeHistogram writes the cumulative buckets, sum and count of a histogram sample.
+*/
+func (v *Vec) writeHistogram(w io.Writer, labels []string, s *sample) error {
+	cumulative := 0.0
+	for i, bound := range v.buckets {
+		if s.counts != nil {
+			cumulative += s.counts[i]
+		}
+		le := append(append([]string{}, labels...), "le=\""+strconv.FormatFloat(bound, 'g', -1, 64)+"\"")
+		if err := writeSample(w, v.name+"_bucket", le, cumulative); err != nil {
+			return err
+		}
+	}
+
+	inf := append(append([]string{}, labels...), "le=\"+Inf\"")
+	if err := writeSample(w, v.name+"_bucket", inf, s.count); err != nil {
+		return err
+	}
+	if err := writeSample(w, v.name+"_sum", labels, s.value); err != nil {
+		return err
+	}
+
+	return writeSample(w, v.name+"_count", labels, s.count)
+}
+
+func writeSample(w io.Writer, name string, labels []string, value float64) error {
+	line := name
+	if len(labels) > 0 {
+		line += "{" + strings.Join(labels, ",") + "}"
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", line, strconv.FormatFloat(value, 'g', -1, 64))
+
+	return err
+}
+
+/*
+escape escapes help text and label values as required by the text exposition format.
+*/
+func escape(str string, quotes bool) string {
+	str = strings.ReplaceAll(str, `\`, `\\`)
+	str = strings.ReplaceAll(str, "\n", `\n`)
+	if quotes {
+		str = strings.ReplaceAll(str, `"`, `\"`)
+	}
+	return str
+}
+
+/*
+WriteAll writes every registered metric family, sorted by name.
+*/
+func WriteAll(w io.Writer) error {
+	registryLock.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	registryLock.Unlock()
+	sort.Strings(names)
+
+	buf := bufio.NewWriter(w)
+	for _, name := range names {
+		registryLock.Lock()
+		v := registry[name]
+		registryLock.Unlock()
+
+		if err := v.write(buf); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+/*
+Handler returns an http.Handler serving all registered metrics.
+*/
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := WriteAll(w); err != nil {
+			logging.Errorf("Error writing metrics: %v", err)
+		}
+	})
+}
+
+/*
+Serve starts serving metrics on addr. The listener is opened before returning
+so that address errors are reported to the caller, requests are then served on
+a Go routine.
+*/
+func Serve(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		logging.Errorf("Error opening metrics listener on %s: %v", addr, err)
+		return err
+	}
+
+	ServeListener(listener)
+
+	return nil
+}
+
+/*
+ServeListener starts serving metrics on an open listener, such as a socket passed by systemd,
+on a Go routine.
+*/
+func ServeListener(listener net.Listener) {
+	mux := http.NewServeMux()
+	mux.Handle(constants.Metrics.Path, Handler())
+
+	registryLock.Lock()
+	serving = true
+	registryLock.Unlock()
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil {
+			logging.Errorf("Metrics server stopped: %v", err)
+		}
+	}()
+
+	logging.Infof("Serving metrics on %v%s", listener.Addr(), constants.Metrics.Path)
+}
+
+/*
+Enabled returns true if metrics are being served. Subsystems can use this to
+avoid collecting statistics that nobody will read.
+*/
+func Enabled() bool {
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	return serving
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVecWrite(t *testing.T) {
+	testCases := []struct {
+		name     string
+		vec      *Vec
+		update   func(v *Vec)
+		expected string
+	}{
+		{
+			name: "gauge without labels",
+			vec:  newVec("pools", "Number of pools.", kindGauge, nil),
+			update: func(v *Vec) {
+				v.Set(2)
+			},
+			expected: "# HELP afxdp_pools Number of pools.\n" +
+				"# TYPE afxdp_pools gauge\n" +
+				"afxdp_pools 2\n",
+		},
+		{
+			name: "counter with labels sorted by value",
+			vec:  newVec("queue_packets_total", "Packets per queue.", kindCounter, []string{"device", "queue"}),
+			update: func(v *Vec) {
+				v.Set(10, "ens801f1", "0")
+				v.Set(5, "ens801f0", "1")
+				v.Add(3, "ens801f0", "1")
+			},
+			expected: "# HELP afxdp_queue_packets_total Packets per queue.\n" +
+				"# TYPE afxdp_queue_packets_total counter\n" +
+				"afxdp_queue_packets_total{device=\"ens801f0\",queue=\"1\"} 8\n" +
+				"afxdp_queue_packets_total{device=\"ens801f1\",queue=\"0\"} 10\n",
+		},
+		{
+			name: "deleted samples are not written",
+			vec:  newVec("devices", "Devices per pool.", kindGauge, []string{"pool", "device"}),
+			update: func(v *Vec) {
+				v.Set(1, "pool1", "ens801f0")
+				v.Set(1, "pool1", "ens801f1")
+				v.Set(1, "pool2", "ens801f2")
+				v.Set(1, "pool2", "ens801f3")
+				v.DeleteMatching("pool", "pool1")
+				v.Delete("pool2", "ens801f3")
+			},
+			expected: "# HELP afxdp_devices Devices per pool.\n" +
+				"# TYPE afxdp_devices gauge\n" +
+				"afxdp_devices{pool=\"pool2\",device=\"ens801f2\"} 1\n",
+		},
+		{
+			name: "empty label values are not written",
+			vec:  newVec("device_up", "Device up.", kindGauge, []string{"pool", "device", "pci"}),
+			update: func(v *Vec) {
+				v.Set(1, "pool1", "ens801f0", "0000:81:00.0")
+				v.Set(1, "pool1", "veth1", "")
+			},
+			expected: "# HELP afxdp_device_up Device up.\n" +
+				"# TYPE afxdp_device_up gauge\n" +
+				"afxdp_device_up{pool=\"pool1\",device=\"ens801f0\",pci=\"0000:81:00.0\"} 1\n" +
+				"afxdp_device_up{pool=\"pool1\",device=\"veth1\"} 1\n",
+		},
+		{
+			name: "decremented samples are removed at zero",
+			vec:  newVec("connections", "Connections.", kindGauge, []string{"pool", "pod"}),
+			update: func(v *Vec) {
+				v.Add(1, "pool1", "podA")
+				v.Add(1, "pool1", "podB")
+				v.Add(1, "pool1", "podB")
+				v.Dec("pool1", "podA")
+				v.Dec("pool1", "podB")
+				v.Dec("pool1", "podC")
+			},
+			expected: "# HELP afxdp_connections Connections.\n" +
+				"# TYPE afxdp_connections gauge\n" +
+				"afxdp_connections{pool=\"pool1\",pod=\"podB\"} 1\n",
+		},
+		{
+			name: "label values are escaped",
+			vec:  newVec("info", "Line one\nline two.", kindGauge, []string{"name"}),
+			update: func(v *Vec) {
+				v.Set(1, `a"b\c`)
+			},
+			expected: "# HELP afxdp_info Line one\\nline two.\n" +
+				"# TYPE afxdp_info gauge\n" +
+				"afxdp_info{name=\"a\\\"b\\\\c\"} 1\n",
+		},
+		{
+			name: "histogram with cumulative buckets",
+			vec: func() *Vec {
+				v := newVec("call_duration_seconds", "Call duration.", kindHistogram, []string{"call"})
+				v.buckets = []float64{0.1, 1}
+				return v
+			}(),
+			update: func(v *Vec) {
+				v.Observe(0.05, "List")
+				v.Observe(0.5, "List")
+				v.Observe(2, "List")
+			},
+			expected: "# HELP afxdp_call_duration_seconds Call duration.\n" +
+				"# TYPE afxdp_call_duration_seconds histogram\n" +
+				"afxdp_call_duration_seconds_bucket{call=\"List\",le=\"0.1\"} 1\n" +
+				"afxdp_call_duration_seconds_bucket{call=\"List\",le=\"1\"} 2\n" +
+				"afxdp_call_duration_seconds_bucket{call=\"List\",le=\"+Inf\"} 3\n" +
+				"afxdp_call_duration_seconds_sum{call=\"List\"} 2.55\n" +
+				"afxdp_call_duration_seconds_count{call=\"List\"} 3\n",
+		},
+		{
+			name: "histogram without labels",
+			vec: func() *Vec {
+				v := newVec("wait_seconds", "Wait.", kindHistogram, nil)
+				v.buckets = []float64{1}
+				return v
+			}(),
+			update: func(v *Vec) {
+				v.Observe(0.5)
+			},
+			expected: "# HELP afxdp_wait_seconds Wait.\n" +
+				"# TYPE afxdp_wait_seconds histogram\n" +
+				"afxdp_wait_seconds_bucket{le=\"1\"} 1\n" +
+				"afxdp_wait_seconds_bucket{le=\"+Inf\"} 1\n" +
+				"afxdp_wait_seconds_sum 0.5\n" +
+				"afxdp_wait_seconds_count 1\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tc.update(tc.vec)
+			require.NoError(t, tc.vec.write(&buf), "Unexpected error")
+			assert.Equal(t, tc.expected, buf.String(), "Output does not match")
+		})
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	testCases := []struct {
+		name       string
+		labelNames []string
+		expErr     string
+	}{
+		{
+			name:       "no labels",
+			labelNames: nil,
+		},
+		{
+			name:       "schema labels in order",
+			labelNames: []string{"pool", "device", "pci", "pod", "namespace"},
+		},
+		{
+			name:       "schema subset followed by own labels",
+			labelNames: []string{"pool", "pod", "namespace", "outcome", "queue"},
+		},
+		{
+			name:       "own labels only",
+			labelNames: []string{"call", "outcome"},
+		},
+		{
+			name:       "schema labels out of order",
+			labelNames: []string{"device", "pool"},
+			expErr:     "metric test: schema label pool out of order",
+		},
+		{
+			name:       "schema label after own label",
+			labelNames: []string{"pool", "outcome", "device"},
+			expErr:     "metric test: schema label device must precede label outcome",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkSchema("test", tc.labelNames)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+		})
+	}
+}
+
+func TestDropLabels(t *testing.T) {
+	defer func() { limits.dropped = make(map[string]bool) }()
+
+	assert.Error(t, DropLabels("pod", "device"), "Device should not be droppable")
+	require.NoError(t, DropLabels("pod", "namespace"))
+
+	v := newVec("queue_drops_total", "Drops.", kindCounter, []string{"pool", "device", "pod", "namespace"})
+	v.Add(2, "pool1", "ens801f0", "podA", "default")
+	v.Add(3, "pool1", "ens801f0", "podB", "default")
+
+	var buf bytes.Buffer
+	require.NoError(t, v.write(&buf), "Unexpected error")
+	assert.Contains(t, buf.String(), "afxdp_queue_drops_total{pool=\"pool1\",device=\"ens801f0\"} 5\n", "Samples should be aggregated")
+}
+
+func TestMaxSeries(t *testing.T) {
+	SetMaxSeries(2)
+	defer SetMaxSeries(constants.Metrics.MaxSeries)
+
+	v := newVec("limited", "Limited.", kindGauge, []string{"pool"})
+	v.Set(1, "pool1")
+	v.Set(1, "pool2")
+	v.Set(1, "pool3")
+	v.Set(1, "pool4")
+	v.Set(2, "pool1")
+
+	var buf bytes.Buffer
+	require.NoError(t, v.write(&buf), "Unexpected error")
+	assert.Equal(t, "# HELP afxdp_limited Limited.\n"+
+		"# TYPE afxdp_limited gauge\n"+
+		"afxdp_limited{pool=\"pool1\"} 2\n"+
+		"afxdp_limited{pool=\"pool2\"} 1\n", buf.String(), "Series beyond the limit should be dropped")
+
+	buf.Reset()
+	require.NoError(t, seriesDropped.write(&buf), "Unexpected error")
+	assert.Contains(t, buf.String(), "afxdp_metrics_series_dropped_total{metric=\"afxdp_limited\"} 2\n", "Dropped series should be counted")
+}
+
+func TestBuildInfo(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteAll(&buf), "Unexpected error")
+	assert.Contains(t, buf.String(), "afxdp_build_info{version=\""+constants.Plugins.Version+"\",commit=\""+constants.Plugins.Commit+
+		"\",build_date=\""+constants.Plugins.BuildDate+"\",go_version=\""+runtime.Version()+"\"} 1\n", "Build info should be exposed")
+}
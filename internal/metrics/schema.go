@@ -0,0 +1,178 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Labels of the common label schema. Every metric about a pool, device or pod uses the schema
+labels that apply to it, named and ordered as here, followed by any labels of its own. Metrics
+of different subsystems can then be joined on the schema labels. Labels with an empty value
+are not written, as is the Prometheus convention.
+*/
+const (
+	LabelPool      = "pool"      // the pool name, without the device prefix
+	LabelDevice    = "device"    // the netdev name
+	LabelPci       = "pci"       // the PCI address of the device, empty for devices without one
+	LabelPod       = "pod"       // the pod name
+	LabelNamespace = "namespace" // the pod namespace
+)
+
+var schemaLabels = []string{LabelPool, LabelDevice, LabelPci, LabelPod, LabelNamespace}
+
+/*
+limits holds the cardinality controls applied to every metric.
+*/
+var limits = struct {
+	sync.Mutex
+	maxSeries int
+	dropped   map[string]bool
+}{
+	maxSeries: constants.Metrics.MaxSeries,
+	dropped:   make(map[string]bool),
+}
+
+/*
+seriesDropped counts the samples not recorded because their metric reached the series limit.
+It is exempt from the limit, it has at most one series per metric.
+*/
+var seriesDropped = func() *Vec {
+	v := newVec("metrics_series_dropped_total",
+		"Number of samples not recorded as their metric had reached the maximum number of series.", kindCounter, []string{"metric"})
+	v.unlimited = true
+	return register(v)
+}()
+
+/*
+checkSchema returns an error if the schema labels of a metric are out of schema order, or
+follow labels of the metric's own.
+*/
+func checkSchema(name string, labelNames []string) error {
+	next := 0
+	own := ""
+	for _, label := range labelNames {
+		i := -1
+		for j, schemaLabel := range schemaLabels {
+			if label == schemaLabel {
+				i = j
+			}
+		}
+
+		switch {
+		case i < 0:
+			if own == "" {
+				own = label
+			}
+		case own != "":
+			return fmt.Errorf("metric %s: schema label %s must precede label %s", name, label, own)
+		case i < next:
+			return fmt.Errorf("metric %s: schema label %s out of order, schema labels are %v", name, label, schemaLabels)
+		default:
+			next = i + 1
+		}
+	}
+
+	return nil
+}
+
+/*
+SetMaxSeries sets the maximum number of series of each metric. Once a metric has this many
+series, samples of new series are dropped and counted by afxdp_metrics_series_dropped_total.
+*/
+func SetMaxSeries(maxSeries int) {
+	limits.Lock()
+	defer limits.Unlock()
+
+	limits.maxSeries = maxSeries
+}
+
+/*
+DropLabels drops schema labels from every metric, aggregating the samples that differed only
+in those labels. Only the labels in constants.Metrics.DroppableLabels may be dropped. It should
+be called before any samples are recorded.
+*/
+func DropLabels(labelNames ...string) error {
+	limits.Lock()
+	defer limits.Unlock()
+
+	for _, label := range labelNames {
+		if !tools.ArrayContains(constants.Metrics.DroppableLabels, label) {
+			return fmt.Errorf("label %s cannot be dropped, droppable labels are %v", label, constants.Metrics.DroppableLabels)
+		}
+	}
+	for _, label := range labelNames {
+		limits.dropped[label] = true
+	}
+	logging.Infof("Dropping metric labels %v", labelNames)
+
+	return nil
+}
+
+/*
+labelValues returns the label values of a sample, with the values of dropped labels cleared.
+*/
+func (v *Vec) labelValues(labelValues []string) []string {
+	if len(labelValues) != len(v.labelNames) {
+		logging.Warningf("Metric %s expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues))
+	}
+
+	values := make([]string, len(v.labelNames))
+	copy(values, labelValues)
+
+	limits.Lock()
+	defer limits.Unlock()
+
+	for i, name := range v.labelNames {
+		if limits.dropped[name] {
+			values[i] = ""
+		}
+	}
+
+	return values
+}
+
+/*
+atLimit returns true if a new series of this metric must be dropped, counting the drop.
+A warning is logged the first time the metric reaches its limit.
+*/
+func (v *Vec) atLimit() bool {
+	if v.unlimited {
+		return false
+	}
+
+	limits.Lock()
+	maxSeries := limits.maxSeries
+	limits.Unlock()
+
+	if len(v.samples) < maxSeries {
+		return false
+	}
+
+	if !v.limitWarned {
+		logging.Warningf("Metric %s reached the maximum of %d series, samples of new series are dropped", v.name, maxSeries)
+		v.limitWarned = true
+	}
+	seriesDropped.Add(1, v.name)
+
+	return true
+}
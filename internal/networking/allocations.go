@@ -0,0 +1,123 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+var allocationsFile = constants.DeviceFile.Directory + constants.Allocations.FileName
+
+/*
+Allocation records that a device has been attached to a pod by the CNI.
+Owner is the container ID of the attachment, Netns is the path of the pod
+network namespace the device was moved into. Peer is the bond peer attached
+along with the device, if any. PodUid is empty if the container runtime did
+not pass the pod UID to the CNI. Channels is the combined channel count of the
+device before the CNI changed it, restored when the device is released, 0 if
+it was not changed. RssHashKey is likewise the RSS hash key of the device
+before the CNI changed it, empty if it was not changed. Mtu is the MTU of the device
+before the device plugin lowered it to fit the UMEM frame size, 0 if it was not changed.
+*/
+type Allocation struct {
+	Device     string
+	Owner      string
+	Pod        string
+	Namespace  string
+	PodUid     string
+	Netns      string
+	Peer       string
+	Channels   int
+	RssHashKey string
+	Mtu        int
+}
+
+/*
+allocationState is the on-disk record of allocations, keyed on device name.
+*/
+type allocationState map[string]*Allocation
+
+/*
+RecordAllocation records that a device has been attached to a pod.
+An existing record for the device is replaced.
+*/
+func (r *handler) RecordAllocation(allocation *Allocation) error {
+	if allocation == nil || allocation.Device == "" {
+		return fmt.Errorf("allocation must have a device")
+	}
+
+	return withAllocationState(func(state allocationState) error {
+		state[allocation.Device] = allocation
+		return nil
+	})
+}
+
+/*
+RemoveAllocation removes the allocation record of a device, if it is owned by owner.
+*/
+func (r *handler) RemoveAllocation(device string, owner string) error {
+	return withAllocationState(func(state allocationState) error {
+		if allocation, ok := state[device]; ok && allocation.Owner == owner {
+			delete(state, device)
+		}
+		return nil
+	})
+}
+
+/*
+GetAllocations returns the allocation records of all devices, keyed on device name.
+*/
+func (r *handler) GetAllocations() (map[string]*Allocation, error) {
+	allocations := make(map[string]*Allocation)
+
+	err := withAllocationState(func(state allocationState) error {
+		for device, allocation := range state {
+			allocations[device] = allocation
+		}
+		return nil
+	})
+
+	return allocations, err
+}
+
+/*
+withAllocationState loads the allocations file under an exclusive lock, runs fn
+and writes the possibly modified state back.
+*/
+func withAllocationState(fn func(state allocationState) error) error {
+	return withStateFile(allocationsFile, constants.Allocations.FilePermissions, func(raw []byte) ([]byte, error) {
+		state := make(allocationState)
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &state); err != nil {
+				logging.Warningf("Allocations file is corrupt, allocation records are lost: %v", err)
+				state = make(allocationState)
+			}
+		}
+
+		fnErr := fn(state)
+
+		jsonStr, err := json.MarshalIndent(state, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonStr, fnErr
+	})
+}
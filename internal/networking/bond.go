@@ -0,0 +1,96 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const bondModeActiveBackup = "active-backup"
+
+/*
+Bond represents a bonding master and its slave ports.
+*/
+type Bond struct {
+	Name        string
+	Mode        string
+	Slaves      []string
+	ActiveSlave string
+}
+
+/*
+GetActiveBackupBond returns the active-backup bond that a netdev is enslaved to.
+If the netdev is not enslaved, or is enslaved to a master that is not an
+active-backup bond, nil is returned.
+*/
+func (r *handler) GetActiveBackupBond(interfaceName string) (*Bond, error) {
+	master, err := os.Readlink(filepath.Join(sysClassNet, interfaceName, "master"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	bond, err := readBond(filepath.Join(sysClassNet, filepath.Base(master), "bonding"))
+	if err != nil || bond == nil {
+		return nil, err
+	}
+	bond.Name = filepath.Base(master)
+
+	if bond.Mode != bondModeActiveBackup {
+		return nil, nil
+	}
+
+	return bond, nil
+}
+
+/*
+readBond reads the mode, slaves and active slave of a bond from its sysfs bonding directory.
+nil is returned if the directory does not exist, meaning the master is not a bond.
+*/
+func readBond(bondingDir string) (*Bond, error) {
+	mode, err := ioutil.ReadFile(filepath.Join(bondingDir, "mode"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	slaves, err := ioutil.ReadFile(filepath.Join(bondingDir, "slaves"))
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := ioutil.ReadFile(filepath.Join(bondingDir, "active_slave"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	bond := &Bond{
+		Slaves:      strings.Fields(string(slaves)),
+		ActiveSlave: strings.TrimSpace(string(active)),
+	}
+	if fields := strings.Fields(string(mode)); len(fields) > 0 { // e.g. "active-backup 1"
+		bond.Mode = fields[0]
+	}
+
+	return bond, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadBond(t *testing.T) {
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		expBond *Bond
+	}{
+		{
+			name: "active-backup bond",
+			files: map[string]string{
+				"mode":         "active-backup 1\n",
+				"slaves":       "ens801f0 ens802f0\n",
+				"active_slave": "ens801f0\n",
+			},
+			expBond: &Bond{Mode: "active-backup", Slaves: []string{"ens801f0", "ens802f0"}, ActiveSlave: "ens801f0"},
+		},
+		{
+			name: "balance-rr bond without active slave",
+			files: map[string]string{
+				"mode":   "balance-rr 0\n",
+				"slaves": "ens801f0 ens802f0\n",
+			},
+			expBond: &Bond{Mode: "balance-rr", Slaves: []string{"ens801f0", "ens802f0"}},
+		},
+		{
+			name:  "not a bond",
+			files: map[string]string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "bonding")
+			require.NoError(t, err, "Unexpected error")
+			defer os.RemoveAll(dir)
+
+			for name, contents := range tc.files {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(contents), 0600), "Unexpected error")
+			}
+
+			bond, err := readBond(dir)
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expBond, bond, "Bond does not match")
+		})
+	}
+}
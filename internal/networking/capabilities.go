@@ -0,0 +1,201 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Capabilities represents the AF_XDP related capabilities of a netdev.
+Capabilities are probed once and cached, see GetCapabilities.
+*/
+type Capabilities struct {
+	Driver    string
+	NativeXdp bool
+	ZeroCopy  bool
+	MaxQueues int
+	Offloads  map[string]bool
+}
+
+/*
+capabilityEntry is a cached set of capabilities along with the identity of the
+netdev at the time of probing. If the identity changes, the device has been
+rebound or recreated and the capabilities must be probed again.
+*/
+type capabilityEntry struct {
+	capabilities *Capabilities
+	identity     deviceIdentity
+}
+
+/*
+deviceIdentity identifies a specific instance of a netdev. A driver rebind
+recreates the netdev, giving it a new ifindex and possibly a new driver.
+*/
+type deviceIdentity struct {
+	ifindex string
+	driver  string
+}
+
+var (
+	capabilityCache     = make(map[string]*capabilityEntry)
+	capabilityCacheLock sync.Mutex
+)
+
+/*
+GetCapabilities returns the capabilities of a netdev. Capabilities are probed on first
+request and cached. Subsequent requests are served from the cache unless the device
+has been rebound to a driver, or recreated, since it was probed.
+*/
+func (r *handler) GetCapabilities(interfaceName string) (*Capabilities, error) {
+	identity, err := getDeviceIdentity(interfaceName)
+	if err != nil {
+		logging.Errorf("Error identifying device %s: %v", interfaceName, err)
+		return nil, err
+	}
+
+	capabilityCacheLock.Lock()
+	defer capabilityCacheLock.Unlock()
+
+	if entry, ok := capabilityCache[interfaceName]; ok {
+		if entry.identity == identity {
+			return entry.capabilities, nil
+		}
+		logging.Infof("Device %s has been rebound, probing capabilities again", interfaceName)
+	}
+
+	capabilities, err := r.probeCapabilities(interfaceName)
+	if err != nil {
+		logging.Errorf("Error probing capabilities of device %s: %v", interfaceName, err)
+		return nil, err
+	}
+
+	capabilityCache[interfaceName] = &capabilityEntry{capabilities: capabilities, identity: identity}
+
+	pretty, err := tools.PrettyString(capabilities)
+	if err == nil {
+		logging.Debugf("Device %s capabilities:\n%s", interfaceName, pretty)
+	}
+
+	return capabilities, nil
+}
+
+/*
+InvalidateCapabilities removes a netdev from the capability cache, forcing the
+next GetCapabilities call to probe the device again.
+*/
+func (r *handler) InvalidateCapabilities(interfaceName string) {
+	capabilityCacheLock.Lock()
+	defer capabilityCacheLock.Unlock()
+
+	delete(capabilityCache, interfaceName)
+}
+
+/*
+probeCapabilities queries the device driver, channels and offloads to build a Capabilities object.
+For virtio-net devices, MaxQueues excludes the queues the driver takes for XDP transmit.
+*/
+func (r *handler) probeCapabilities(interfaceName string) (*Capabilities, error) {
+	driver, err := r.GetDeviceDriver(interfaceName)
+	if err != nil {
+		return nil, err
+	}
+
+	capabilities := &Capabilities{
+		Driver:    driver,
+		NativeXdp: tools.ArrayContains(constants.Drivers.NativeXdp, driver),
+		ZeroCopy:  tools.ArrayContains(constants.Drivers.ZeroCopy, driver),
+		Offloads:  make(map[string]bool),
+	}
+
+	channels, err := r.GetChannels(interfaceName)
+	if err != nil {
+		logging.Warningf("Unable to determine max queues of device %s: %v", interfaceName, err)
+	} else {
+		capabilities.MaxQueues = channels.MaxCombined
+		if channels.MaxRx > capabilities.MaxQueues {
+			capabilities.MaxQueues = channels.MaxRx
+		}
+		if tools.ArrayContains(constants.Drivers.Virtio, driver) {
+			capabilities.MaxQueues = virtioXdpQueues(channels.MaxCombined, runtime.NumCPU())
+		}
+	}
+
+	stdout, err := exec.Command(ethtool, "--show-features", interfaceName).CombinedOutput()
+	if err != nil {
+		logging.Warningf("Unable to determine offloads of device %s: %s", interfaceName, string(stdout))
+	} else {
+		capabilities.Offloads = parseFeatures(string(stdout))
+	}
+
+	return capabilities, nil
+}
+
+/*
+getDeviceIdentity reads the ifindex and bound driver of a netdev from sysfs.
+These are cheap reads that do not touch the hardware.
+*/
+func getDeviceIdentity(interfaceName string) (deviceIdentity, error) {
+	var identity deviceIdentity
+
+	ifindex, err := ioutil.ReadFile(filepath.Join(sysClassNet, interfaceName, "ifindex"))
+	if err != nil {
+		return identity, err
+	}
+	identity.ifindex = strings.TrimSpace(string(ifindex))
+
+	driver, err := os.Readlink(filepath.Join(sysClassNet, interfaceName, pciLink, "driver"))
+	if err != nil && !os.IsNotExist(err) {
+		return identity, err
+	}
+	identity.driver = filepath.Base(driver)
+
+	return identity, nil
+}
+
+/*
+parseFeatures parses the output of ethtool --show-features into a map of feature name to state.
+*/
+func parseFeatures(output string) map[string]bool {
+	features := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 || strings.HasPrefix(line, "Features for") {
+			continue
+		}
+		state := strings.Fields(fields[1])
+		if len(state) == 0 || (state[0] != "on" && state[0] != "off") {
+			continue
+		}
+		features[strings.TrimSpace(fields[0])] = state[0] == "on"
+	}
+
+	return features
+}
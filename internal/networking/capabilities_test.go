@@ -0,0 +1,49 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFeatures(t *testing.T) {
+	output := `Features for ens801f0:
+rx-checksumming: on
+tx-checksumming: on
+	tx-checksum-ipv4: off [fixed]
+scatter-gather: on
+generic-receive-offload: off
+ntuple-filters: on
+hw-tc-offload: off [fixed]
+`
+	expected := map[string]bool{
+		"rx-checksumming":         true,
+		"tx-checksumming":         true,
+		"tx-checksum-ipv4":        false,
+		"scatter-gather":          true,
+		"generic-receive-offload": false,
+		"ntuple-filters":          true,
+		"hw-tc-offload":           false,
+	}
+
+	features := parseFeatures(output)
+	assert.Equal(t, expected, features, "Features do not match")
+
+	features = parseFeatures("Cannot get device feature names: Operation not supported")
+	assert.Len(t, features, 0, "Expected no features")
+}
@@ -31,10 +31,15 @@ type Device struct {
 	name           string
 	mode           string
 	driver         string
+	firmware       string
 	pci            string
 	macAddress     string
 	fullyAssigned  bool
 	ethtoolFilters []string
+	promiscuous    bool
+	peer           string
+	restoreMtu     int
+	peerRestoreMtu int
 	primary        *Device
 	secondaries    []*Device
 	netHandler     Handler
@@ -49,10 +54,15 @@ type DeviceDetails struct {
 	Name           string
 	Mode           string
 	Driver         string
+	Firmware       string
 	Pci            string
 	MacAddress     string
 	FullyAssigned  bool
 	EthtoolFilters []string
+	Promiscuous    bool
+	Peer           string
+	RestoreMtu     int
+	PeerRestoreMtu int
 	Primary        *DeviceDetails
 }
 
@@ -202,6 +212,28 @@ func (d *Device) Driver() (string, error) {
 	return d.driver, nil
 }
 
+/*
+Firmware will check Device object for its firmware version and return the result
+If firmware is not stored it will be discovered through the netHandler
+Firmware is then stored for subsequent calls
+Secondary devices share the firmware of their primary device
+*/
+func (d *Device) Firmware() (string, error) {
+	if !d.IsPrimary() {
+		return d.primary.Firmware()
+	}
+	if d.firmware != "" {
+		return d.firmware, nil
+	}
+	firmware, err := d.netHandler.GetFirmwareVersion(d.name)
+	if err != nil {
+		return "", err
+	}
+
+	d.firmware = firmware
+	return d.firmware, nil
+}
+
 /*
 Pci will check Device object for its pci and return the result
 If pci is not stored it will be discovered through the netHandler
@@ -314,6 +346,14 @@ func (d *Device) Cycle() error {
 	return nil
 }
 
+/*
+Capabilities returns the AF_XDP related capabilities of the device
+Capabilities are cached by the netHandler, so repeated calls do not re-probe the hardware
+*/
+func (d *Device) Capabilities() (*Capabilities, error) {
+	return d.netHandler.GetCapabilities(d.name)
+}
+
 /*
 GetEthtoolFilters returns a string array of ethtool filters from
 the device object
@@ -358,10 +398,15 @@ func (d *Device) Public() *DeviceDetails {
 		Name:           d.name,
 		Mode:           d.mode,
 		Driver:         d.driver,
+		Firmware:       d.firmware,
 		Pci:            d.pci,
 		MacAddress:     d.macAddress,
 		FullyAssigned:  d.fullyAssigned,
 		EthtoolFilters: d.ethtoolFilters,
+		Promiscuous:    d.promiscuous,
+		Peer:           d.peer,
+		RestoreMtu:     d.restoreMtu,
+		PeerRestoreMtu: d.peerRestoreMtu,
 
 		Primary: &DeviceDetails{
 			Name:          d.primary.name,
@@ -374,6 +419,21 @@ func (d *Device) Public() *DeviceDetails {
 	}
 }
 
+/*
+newTapDevice creates, initialises, and returns a tap device
+Tap devices are created by the device plugin for tap mode pools and are fully assigned on creation
+*/
+func newTapDevice(name string, macAddress string, netHandler Handler) (*Device, error) {
+	dev, err := newPrimaryDevice(name, tapDriver, "", macAddress, netHandler)
+	if err != nil {
+		return nil, err
+	}
+	dev.mode = "tap"
+	dev.SetFullyAssigned()
+
+	return dev, nil
+}
+
 /*
 newPrimaryDevice creates, initialises, and returns a primary device
 Primary devices must have a name and a netHandler
@@ -458,3 +518,66 @@ field in the device object.
 func (d *Device) SetEthtoolFilter(ethtool []string) {
 	d.ethtoolFilters = ethtool
 }
+
+/*
+SetPromiscuous sets whether the device should be put into promiscuous
+mode by the CNI when it is allocated to a pod.
+*/
+func (d *Device) SetPromiscuous(promiscuous bool) {
+	d.promiscuous = promiscuous
+}
+
+/*
+Promiscuous returns true if the device should be put into promiscuous mode on allocation
+*/
+func (d *Device) Promiscuous() bool {
+	return d.promiscuous
+}
+
+/*
+SetPeer pairs the device with the other port of an active-backup bond.
+The peer is allocated along with the device, as a single logical device.
+*/
+func (d *Device) SetPeer(peer string) {
+	d.peer = peer
+}
+
+/*
+Peer returns the name of the bond peer allocated along with the device,
+or an empty string if the device is not paired.
+*/
+func (d *Device) Peer() string {
+	return d.peer
+}
+
+/*
+SetRestoreMtu records the MTU the device had before it was lowered to fit the UMEM frame
+size, so the CNI can restore it when the device is released. 0 means the MTU was not changed.
+The MTU is kept across allocations, so it is still known should it not have been restored.
+*/
+func (d *Device) SetRestoreMtu(mtu int) {
+	d.restoreMtu = mtu
+}
+
+/*
+RestoreMtu returns the MTU to restore when the device is released, 0 if it was not changed.
+*/
+func (d *Device) RestoreMtu() int {
+	return d.restoreMtu
+}
+
+/*
+SetPeerRestoreMtu records the MTU the bond peer of the device had before it was lowered to fit
+the UMEM frame size. 0 means the MTU was not changed.
+*/
+func (d *Device) SetPeerRestoreMtu(mtu int) {
+	d.peerRestoreMtu = mtu
+}
+
+/*
+PeerRestoreMtu returns the MTU to restore on the bond peer of the device when it is released,
+0 if it was not changed.
+*/
+func (d *Device) PeerRestoreMtu() int {
+	return d.peerRestoreMtu
+}
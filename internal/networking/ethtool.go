@@ -16,18 +16,43 @@
 package networking
 
 import (
+	"bufio"
+	"fmt"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	logging "github.com/sirupsen/logrus"
 	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 var ethtool = "ethtool"
 
+/*
+Channels represents the channel (queue) configuration of a netdev, as reported by
+ethtool --show-channels. The Max fields are the driver pre-set maximums, the remaining
+fields are the current hardware settings. A value of zero means the driver does not
+support, or does not report, that channel type.
+*/
+type Channels struct {
+	MaxRx       int
+	MaxTx       int
+	MaxOther    int
+	MaxCombined int
+	Rx          int
+	Tx          int
+	Other       int
+	Combined    int
+}
+
 /*
 SetEthtool applies ethtool filters on the physical device during cmdAdd().
-Ethtool filters are set via the DP config.json file.
+Ethtool filters are set via the DP config.json file. Ntuple filters are installed
+through the flow rule manager on behalf of owner, so they can be tracked and removed
+on teardown. All other filters are executed as given.
 */
-func (r *handler) SetEthtool(ethtoolFilters []string, interfaceName string, ipAddr string) error {
+func (r *handler) SetEthtool(ethtoolFilters []string, interfaceName string, ipAddr string, owner string) error {
 	fd := "on"
 	err := flowDirector(interfaceName, fd)
 	if err != nil {
@@ -39,6 +64,15 @@ func (r *handler) SetEthtool(ethtoolFilters []string, interfaceName string, ipAd
 
 		ethtoolFilter = strings.Replace(ethtoolFilter, "-ip-", ipAddr, -1)
 
+		if rule, ok := ntupleRule(ethtoolFilter); ok {
+			if _, err := r.AddFlowRule(interfaceName, owner, rule); err != nil {
+				logging.Errorf("Error adding ethtool flow rule [%s]: %v", ethtoolFilter, err)
+				return err
+			}
+			logging.Debugf("Ethtool flow rule [%s] successfully added", ethtoolFilter)
+			continue
+		}
+
 		cmd := exec.Command(ethtool, strings.Split(ethtoolFilter, " ")...)
 		stdout, err := cmd.CombinedOutput()
 		if err != nil {
@@ -54,9 +88,11 @@ func (r *handler) SetEthtool(ethtoolFilters []string, interfaceName string, ipAd
 
 /*
 DeleteEthtool sets the default queue size ethtool filter.
-It also removes perfect-flow ethtool filter entries during cmdDel()
+It also removes the perfect-flow ethtool filter entries owned by owner during cmdDel().
+The flow director is only disabled once no flow rules remain on the device, leaving
+pre-existing rules and rules belonging to other allocations untouched.
 */
-func (r *handler) DeleteEthtool(interfaceName string) error {
+func (r *handler) DeleteEthtool(interfaceName string, owner string) error {
 	defaultArg := "-X"
 	fd := "off"
 
@@ -68,17 +104,516 @@ func (r *handler) DeleteEthtool(interfaceName string) error {
 		return err
 	}
 
-	err = flowDirector(interfaceName, fd)
-	if err != nil {
+	if err := r.DeleteFlowRules(interfaceName, owner); err != nil {
 		logging.Errorf("Error removing perfect flow entries: %v", err.Error())
 		return err
 	}
 
+	remaining, err := r.ListFlowRules(interfaceName)
+	if err != nil {
+		logging.Errorf("Error listing remaining flow entries: %v", err.Error())
+		return err
+	}
+
+	if len(remaining) == 0 {
+		err = flowDirector(interfaceName, fd)
+		if err != nil {
+			logging.Errorf("Error disabling flow director: %v", err.Error())
+			return err
+		}
+	} else {
+		logging.Debugf("%d flow rules remain on device %s, flow director left enabled", len(remaining), interfaceName)
+	}
+
 	logging.Debugf("Ethtool filters removed on device: %s", interfaceName)
 
 	return nil
 }
 
+/*
+ntupleRule returns the rule spec of an ethtool ntuple command, i.e. the command without
+the ntuple option and device name. It returns false if the command is not an ntuple command.
+*/
+func ntupleRule(ethtoolFilter string) (string, bool) {
+	words := strings.Fields(ethtoolFilter)
+	if len(words) < 3 {
+		return "", false
+	}
+	switch words[0] {
+	case "-N", "-U", "--config-ntuple", "--config-nfc":
+		if words[2] == "delete" {
+			return "", false
+		}
+		return strings.Join(words[2:], " "), true
+	}
+	return "", false
+}
+
+/*
+GetChannels returns the current and maximum channel counts of a netdev.
+Equivalent to 'ethtool --show-channels <interface_name>'
+*/
+func (r *handler) GetChannels(interfaceName string) (*Channels, error) {
+	cmd := exec.Command(ethtool, "--show-channels", interfaceName)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error getting channels of device %s: %s", interfaceName, string(stdout))
+		return nil, err
+	}
+
+	channels, err := parseChannels(string(stdout))
+	if err != nil {
+		logging.Errorf("Error parsing channels of device %s: %v", interfaceName, err)
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+/*
+SetChannels sets the rx, tx and combined channel counts of a netdev. Counts of zero are
+left unchanged. The requested counts are validated against the driver maximums before
+being applied. Equivalent to 'ethtool --set-channels <interface_name> combined <n> ...'
+*/
+func (r *handler) SetChannels(interfaceName string, channels *Channels) error {
+	current, err := r.GetChannels(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateChannels(current, channels); err != nil {
+		logging.Errorf("Invalid channel request for device %s: %v", interfaceName, err)
+		return err
+	}
+
+	args := []string{"--set-channels", interfaceName}
+	if channels.Combined > 0 && channels.Combined != current.Combined {
+		args = append(args, "combined", strconv.Itoa(channels.Combined))
+	}
+	if channels.Rx > 0 && channels.Rx != current.Rx {
+		args = append(args, "rx", strconv.Itoa(channels.Rx))
+	}
+	if channels.Tx > 0 && channels.Tx != current.Tx {
+		args = append(args, "tx", strconv.Itoa(channels.Tx))
+	}
+	if len(args) == 2 {
+		logging.Debugf("Channels of device %s already set as requested", interfaceName)
+		return nil
+	}
+
+	cmd := exec.Command(ethtool, args...)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error setting channels %v: %s", args, string(stdout))
+		return err
+	}
+
+	logging.Debugf("Channels of device %s set: %v", interfaceName, args[2:])
+
+	return nil
+}
+
+/*
+ValidateChannels checks a channel request against the current channel configuration
+of a device. Each requested count must not exceed the driver maximum for that channel
+type, and a device must always be left with at least one rx and one tx capable channel.
+Counts of zero in the request are ignored, meaning unchanged.
+*/
+func ValidateChannels(current *Channels, requested *Channels) error {
+	if current == nil || requested == nil {
+		return fmt.Errorf("channel configuration cannot be nil")
+	}
+
+	if requested.Combined < 0 || requested.Rx < 0 || requested.Tx < 0 {
+		return fmt.Errorf("channel counts cannot be negative")
+	}
+
+	if requested.Combined > 0 && requested.Combined > current.MaxCombined {
+		return fmt.Errorf("requested %d combined channels, driver maximum is %d", requested.Combined, current.MaxCombined)
+	}
+	if requested.Rx > 0 && requested.Rx > current.MaxRx {
+		return fmt.Errorf("requested %d rx channels, driver maximum is %d", requested.Rx, current.MaxRx)
+	}
+	if requested.Tx > 0 && requested.Tx > current.MaxTx {
+		return fmt.Errorf("requested %d tx channels, driver maximum is %d", requested.Tx, current.MaxTx)
+	}
+
+	combined, rx, tx := current.Combined, current.Rx, current.Tx
+	if requested.Combined > 0 {
+		combined = requested.Combined
+	}
+	if requested.Rx > 0 {
+		rx = requested.Rx
+	}
+	if requested.Tx > 0 {
+		tx = requested.Tx
+	}
+	if combined+rx == 0 || combined+tx == 0 {
+		return fmt.Errorf("device must have at least one rx and one tx channel")
+	}
+
+	return nil
+}
+
+/*
+parseChannels parses the output of ethtool --show-channels into a Channels object.
+Values reported as "n/a" are treated as zero.
+*/
+func parseChannels(output string) (*Channels, error) {
+	channels := &Channels{}
+	var maximums bool
+	var found bool
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "Pre-set maximums"):
+			maximums = true
+			continue
+		case strings.HasPrefix(line, "Current hardware settings"):
+			maximums = false
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		key := strings.TrimSpace(fields[0])
+		value := strings.TrimSpace(fields[1])
+		count := 0
+		if value != "n/a" {
+			var err error
+			if count, err = strconv.Atoi(value); err != nil {
+				continue
+			}
+		}
+
+		switch key {
+		case "RX":
+			if maximums {
+				channels.MaxRx = count
+			} else {
+				channels.Rx = count
+			}
+		case "TX":
+			if maximums {
+				channels.MaxTx = count
+			} else {
+				channels.Tx = count
+			}
+		case "Other":
+			if maximums {
+				channels.MaxOther = count
+			} else {
+				channels.Other = count
+			}
+		case "Combined":
+			if maximums {
+				channels.MaxCombined = count
+			} else {
+				channels.Combined = count
+			}
+		default:
+			continue
+		}
+		found = true
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no channel information found")
+	}
+
+	return channels, nil
+}
+
+/*
+Rss represents the receive side scaling configuration of a netdev, as reported by
+ethtool --show-rxfh. RxRings is the number of rx rings the indirection table spreads
+across, Table holds the queue index of each indirection table entry and HashKey is the
+RSS hash key in colon separated hex format.
+*/
+type Rss struct {
+	RxRings int
+	Table   []int
+	HashKey string
+}
+
+/*
+GetRss returns the RSS indirection table and hash key of a netdev.
+Equivalent to 'ethtool --show-rxfh <interface_name>'
+*/
+func (r *handler) GetRss(interfaceName string) (*Rss, error) {
+	cmd := exec.Command(ethtool, "--show-rxfh", interfaceName)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error getting RSS config of device %s: %s", interfaceName, string(stdout))
+		return nil, err
+	}
+
+	rss, err := parseRss(string(stdout))
+	if err != nil {
+		logging.Errorf("Error parsing RSS config of device %s: %v", interfaceName, err)
+		return nil, err
+	}
+
+	return rss, nil
+}
+
+/*
+SetRss programs the RSS indirection table of a netdev so that traffic is spread equally
+across count queues, beginning at queue start. If hashKey is not empty the RSS hash key is
+also programmed. The request is validated against the current device configuration.
+Equivalent to 'ethtool --set-rxfh <interface_name> start <start> equal <count> [hkey <key>]'
+*/
+func (r *handler) SetRss(interfaceName string, start int, count int, hashKey string) error {
+	current, err := r.GetRss(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	if err := ValidateRss(current, start, count, hashKey); err != nil {
+		logging.Errorf("Invalid RSS request for device %s: %v", interfaceName, err)
+		return err
+	}
+
+	args := []string{"--set-rxfh", interfaceName, "start", strconv.Itoa(start), "equal", strconv.Itoa(count)}
+	if hashKey != "" {
+		args = append(args, "hkey", hashKey)
+	}
+
+	cmd := exec.Command(ethtool, args...)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error setting RSS %v: %s", args, string(stdout))
+		return err
+	}
+
+	logging.Debugf("RSS of device %s set: %v", interfaceName, args[2:])
+
+	return nil
+}
+
+/*
+RestoreRss resets the RSS indirection table of a netdev to the driver default, spreading traffic
+across every rx ring, and restores the RSS hash key if hashKey is not empty.
+Equivalent to 'ethtool --set-rxfh <interface_name> default [hkey <key>]'
+*/
+func (r *handler) RestoreRss(interfaceName string, hashKey string) error {
+	args := []string{"--set-rxfh", interfaceName, "default"}
+	if hashKey != "" {
+		args = append(args, "hkey", hashKey)
+	}
+
+	cmd := exec.Command(ethtool, args...)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error restoring RSS %v: %s", args, string(stdout))
+		return err
+	}
+
+	logging.Debugf("RSS of device %s restored", interfaceName)
+
+	return nil
+}
+
+/*
+ValidateRss checks an RSS request against the current RSS configuration of a device.
+The queue range must fall within the rx rings of the device and, if a hash key is
+provided, it must be in colon separated hex format and match the length of the current key.
+*/
+func ValidateRss(current *Rss, start int, count int, hashKey string) error {
+	if current == nil {
+		return fmt.Errorf("RSS configuration cannot be nil")
+	}
+
+	if start < 0 {
+		return fmt.Errorf("RSS start queue cannot be negative")
+	}
+	if count < 1 {
+		return fmt.Errorf("RSS must spread across at least one queue")
+	}
+	if start+count > current.RxRings {
+		return fmt.Errorf("RSS queues %d-%d exceed the %d rx rings of the device", start, start+count-1, current.RxRings)
+	}
+
+	if hashKey != "" {
+		if !regexp.MustCompile(constants.EthtoolFilter.RssHashKeyRegex).MatchString(hashKey) {
+			return fmt.Errorf("RSS hash key must be colon separated hex bytes")
+		}
+		if current.HashKey != "" && len(hashKey) != len(current.HashKey) {
+			return fmt.Errorf("RSS hash key must be %d bytes", (len(current.HashKey)+1)/3)
+		}
+	}
+
+	return nil
+}
+
+/*
+parseRss parses the output of ethtool --show-rxfh into an Rss object.
+*/
+func parseRss(output string) (*Rss, error) {
+	rss := &Rss{}
+	var inTable bool
+	var inKey bool
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "RX flow hash indirection table"):
+			words := strings.Fields(line)
+			for i, word := range words {
+				if word == "with" && i+1 < len(words) {
+					rings, err := strconv.Atoi(words[i+1])
+					if err != nil {
+						return nil, fmt.Errorf("unable to parse rx rings: %v", err)
+					}
+					rss.RxRings = rings
+				}
+			}
+			inTable, inKey = true, false
+			continue
+		case strings.HasPrefix(line, "RSS hash key"):
+			inTable, inKey = false, true
+			continue
+		case strings.HasPrefix(line, "RSS hash function"):
+			inTable, inKey = false, false
+			continue
+		case line == "":
+			continue
+		}
+
+		if inTable {
+			fields := strings.SplitN(line, ":", 2)
+			if len(fields) != 2 {
+				continue
+			}
+			for _, entry := range strings.Fields(fields[1]) {
+				queue, err := strconv.Atoi(entry)
+				if err != nil {
+					return nil, fmt.Errorf("unable to parse indirection table entry %q: %v", entry, err)
+				}
+				rss.Table = append(rss.Table, queue)
+			}
+		} else if inKey {
+			if line != "Operation not supported" {
+				rss.HashKey = line
+			}
+			inKey = false
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if rss.RxRings == 0 {
+		return nil, fmt.Errorf("no RSS information found")
+	}
+
+	return rss, nil
+}
+
+/*
+QueueStats holds the packet and drop counters of a single queue of a netdev, as
+reported by ethtool --statistics. Drivers name their per-queue counters differently,
+counters are normalised here. Drops are the sum of all drop counters of the queue,
+including XDP drops.
+*/
+type QueueStats struct {
+	Queue     int
+	RxPackets uint64
+	RxDrops   uint64
+	TxPackets uint64
+	TxDrops   uint64
+}
+
+/*
+queueStatRegex matches the per-queue statistics of the common AF_XDP drivers:
+rx-0.packets (i40e), rx_queue_0_packets (ice, ixgbe, veth, virtio_net), rx0_packets (mlx5)
+*/
+var queueStatRegex = regexp.MustCompile(`^(rx|tx)(?:[-_]queue)?[-_]?(\d+)[._](.+)$`)
+
+/*
+GetQueueStats returns the per-queue packet and drop counters of a netdev, sorted by queue.
+Equivalent to 'ethtool --statistics <interface_name>'
+*/
+func (r *handler) GetQueueStats(interfaceName string) ([]*QueueStats, error) {
+	cmd := exec.Command(ethtool, "--statistics", interfaceName)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error getting statistics of device %s: %s", interfaceName, string(stdout))
+		return nil, err
+	}
+
+	return parseQueueStats(string(stdout)), nil
+}
+
+/*
+parseQueueStats parses the output of ethtool --statistics into per-queue counters.
+Counters that are not per-queue, or not packet or drop counters, are ignored.
+*/
+func parseQueueStats(output string) []*QueueStats {
+	queues := make(map[int]*QueueStats)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+
+		match := queueStatRegex.FindStringSubmatch(strings.TrimSpace(fields[0]))
+		if match == nil {
+			continue
+		}
+
+		queue, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		value, err := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		var packets, drops *uint64
+		stats, ok := queues[queue]
+		if !ok {
+			stats = &QueueStats{Queue: queue}
+		}
+		if match[1] == "rx" {
+			packets, drops = &stats.RxPackets, &stats.RxDrops
+		} else {
+			packets, drops = &stats.TxPackets, &stats.TxDrops
+		}
+
+		counter := match[3]
+		switch {
+		case counter == "packets" || counter == "xdp_packets" || counter == "xsk_packets":
+			*packets += value
+		case strings.Contains(counter, "drop"):
+			*drops += value
+		default:
+			continue
+		}
+		queues[queue] = stats
+	}
+
+	stats := make([]*QueueStats, 0, len(queues))
+	for _, q := range queues {
+		stats = append(stats, q)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Queue < stats[j].Queue })
+
+	return stats
+}
+
 /*
 flowDirector enables and disables the Ethernet Flow Director. It must be enabled
 for filter flow entries. Disabling, enables entries to be removed from device.
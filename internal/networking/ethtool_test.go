@@ -0,0 +1,368 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChannels(t *testing.T) {
+	testCases := []struct {
+		name        string
+		output      string
+		expChannels *Channels
+		expErr      bool
+	}{
+		{
+			name: "combined channels only",
+			output: `Channel parameters for ens801f0:
+Pre-set maximums:
+RX:		0
+TX:		0
+Other:		1
+Combined:	64
+Current hardware settings:
+RX:		0
+TX:		0
+Other:		1
+Combined:	8
+`,
+			expChannels: &Channels{MaxOther: 1, MaxCombined: 64, Other: 1, Combined: 8},
+		},
+		{
+			name: "newer ethtool n/a values",
+			output: `Channel parameters for ens801f0:
+Pre-set maximums:
+RX:		n/a
+TX:		n/a
+Other:		1
+Combined:	128
+Current hardware settings:
+RX:		n/a
+TX:		n/a
+Other:		1
+Combined:	32
+`,
+			expChannels: &Channels{MaxOther: 1, MaxCombined: 128, Other: 1, Combined: 32},
+		},
+		{
+			name: "separate rx and tx channels",
+			output: `Channel parameters for veth0:
+Pre-set maximums:
+RX:		4
+TX:		4
+Other:		0
+Combined:	0
+Current hardware settings:
+RX:		1
+TX:		1
+Other:		0
+Combined:	0
+`,
+			expChannels: &Channels{MaxRx: 4, MaxTx: 4, Rx: 1, Tx: 1},
+		},
+		{
+			name:   "no channel information",
+			output: "netlink error: Operation not supported",
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			channels, err := parseChannels(tc.output)
+			if tc.expErr {
+				require.Error(t, err, "Error was expected")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expChannels, channels, "Channels do not match")
+		})
+	}
+}
+
+func TestValidateChannels(t *testing.T) {
+	current := &Channels{MaxRx: 4, MaxTx: 4, MaxCombined: 64, Combined: 8}
+
+	testCases := []struct {
+		name      string
+		requested *Channels
+		expErr    string
+	}{
+		{
+			name:      "valid combined request",
+			requested: &Channels{Combined: 16},
+		},
+		{
+			name:      "combined request at driver maximum",
+			requested: &Channels{Combined: 64},
+		},
+		{
+			name:      "combined request above driver maximum",
+			requested: &Channels{Combined: 65},
+			expErr:    "requested 65 combined channels, driver maximum is 64",
+		},
+		{
+			name:      "rx request above driver maximum",
+			requested: &Channels{Rx: 5},
+			expErr:    "requested 5 rx channels, driver maximum is 4",
+		},
+		{
+			name:      "tx request above driver maximum",
+			requested: &Channels{Tx: 8},
+			expErr:    "requested 8 tx channels, driver maximum is 4",
+		},
+		{
+			name:      "negative request",
+			requested: &Channels{Combined: -1},
+			expErr:    "channel counts cannot be negative",
+		},
+		{
+			name:      "empty request leaves device unchanged",
+			requested: &Channels{},
+		},
+		{
+			name:      "nil request",
+			requested: nil,
+			expErr:    "channel configuration cannot be nil",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateChannels(current, tc.requested)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+
+	t.Run("device left without channels", func(t *testing.T) {
+		err := ValidateChannels(&Channels{MaxRx: 4, MaxTx: 4, Rx: 0, Tx: 1}, &Channels{})
+		require.Error(t, err, "Error was expected")
+		assert.Contains(t, err.Error(), "at least one rx and one tx channel", "Unexpected error")
+	})
+}
+
+func TestParseRss(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		expRss *Rss
+		expErr bool
+	}{
+		{
+			name: "indirection table and hash key",
+			output: `RX flow hash indirection table for ens801f0 with 4 RX ring(s):
+    0:      0     1     2     3     0     1     2     3
+    8:      0     1     2     3     0     1     2     3
+RSS hash key:
+7d:3c:f6:24:5a:9b:0e:11
+RSS hash function:
+    toeplitz: on
+    xor: off
+    crc32: off
+`,
+			expRss: &Rss{
+				RxRings: 4,
+				Table:   []int{0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3, 0, 1, 2, 3},
+				HashKey: "7d:3c:f6:24:5a:9b:0e:11",
+			},
+		},
+		{
+			name: "hash key not supported",
+			output: `RX flow hash indirection table for veth0 with 2 RX ring(s):
+    0:      0     1
+RSS hash key:
+Operation not supported
+`,
+			expRss: &Rss{RxRings: 2, Table: []int{0, 1}},
+		},
+		{
+			name:   "no RSS information",
+			output: "Cannot get RX ring count: Operation not supported",
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rss, err := parseRss(tc.output)
+			if tc.expErr {
+				require.Error(t, err, "Error was expected")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expRss, rss, "RSS config does not match")
+		})
+	}
+}
+
+func TestValidateRss(t *testing.T) {
+	current := &Rss{RxRings: 8, HashKey: "7d:3c:f6:24"}
+
+	testCases := []struct {
+		name    string
+		start   int
+		count   int
+		hashKey string
+		expErr  string
+	}{
+		{
+			name:  "confine to first queues",
+			start: 0,
+			count: 4,
+		},
+		{
+			name:  "spread across all queues",
+			start: 0,
+			count: 8,
+		},
+		{
+			name:    "valid hash key",
+			start:   4,
+			count:   4,
+			hashKey: "01:02:03:04",
+		},
+		{
+			name:   "queues beyond rx rings",
+			start:  6,
+			count:  4,
+			expErr: "RSS queues 6-9 exceed the 8 rx rings of the device",
+		},
+		{
+			name:   "negative start",
+			start:  -1,
+			count:  4,
+			expErr: "RSS start queue cannot be negative",
+		},
+		{
+			name:   "zero queues",
+			start:  0,
+			count:  0,
+			expErr: "RSS must spread across at least one queue",
+		},
+		{
+			name:    "malformed hash key",
+			start:   0,
+			count:   4,
+			hashKey: "01-02-03-04",
+			expErr:  "RSS hash key must be colon separated hex bytes",
+		},
+		{
+			name:    "wrong hash key length",
+			start:   0,
+			count:   4,
+			hashKey: "01:02",
+			expErr:  "RSS hash key must be 4 bytes",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateRss(current, tc.start, tc.count, tc.hashKey)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestParseQueueStats(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expStats []*QueueStats
+	}{
+		{
+			name: "i40e",
+			output: `NIC statistics:
+     rx_packets: 1200
+     tx_packets: 800
+     tx-0.packets: 500
+     tx-0.bytes: 64000
+     rx-0.packets: 700
+     rx-0.bytes: 89600
+     tx-1.packets: 300
+     rx-1.packets: 500
+`,
+			expStats: []*QueueStats{
+				{Queue: 0, RxPackets: 700, TxPackets: 500},
+				{Queue: 1, RxPackets: 500, TxPackets: 300},
+			},
+		},
+		{
+			name: "ice",
+			output: `NIC statistics:
+     rx_unicast: 10
+     tx_queue_0_packets: 4
+     tx_queue_0_bytes: 512
+     rx_queue_0_packets: 10
+     rx_queue_0_bytes: 1280
+`,
+			expStats: []*QueueStats{
+				{Queue: 0, RxPackets: 10, TxPackets: 4},
+			},
+		},
+		{
+			name: "mlx5 with xdp and xsk counters",
+			output: `NIC statistics:
+     rx_packets: 30
+     rx0_packets: 20
+     rx0_xdp_drop: 3
+     rx0_xsk_packets: 10
+     rx0_xsk_buff_alloc_err: 1
+     tx0_packets: 7
+`,
+			expStats: []*QueueStats{
+				{Queue: 0, RxPackets: 30, RxDrops: 3, TxPackets: 7},
+			},
+		},
+		{
+			name: "veth",
+			output: `NIC statistics:
+     peer_ifindex: 12
+     rx_queue_0_xdp_packets: 42
+     rx_queue_0_xdp_bytes: 5376
+     rx_queue_0_drops: 2
+     rx_queue_0_xdp_drops: 1
+`,
+			expStats: []*QueueStats{
+				{Queue: 0, RxPackets: 42, RxDrops: 3},
+			},
+		},
+		{
+			name:     "no statistics",
+			output:   "no stats available",
+			expStats: []*QueueStats{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expStats, parseQueueStats(tc.output), "Statistics do not match")
+		})
+	}
+}
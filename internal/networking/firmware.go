@@ -0,0 +1,112 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+GetFirmwareVersion returns the firmware version of a netdev, as reported by the driver.
+An empty string is returned if the driver does not report a firmware version.
+Equivalent to the firmware-version field of 'ethtool -i <interface_name>'
+*/
+func (r *handler) GetFirmwareVersion(interfaceName string) (string, error) {
+	cmd := exec.Command(ethtool, "-i", interfaceName)
+	stdout, err := cmd.CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error getting driver info of device %s: %s", interfaceName, string(stdout))
+		return "", err
+	}
+
+	return parseFirmwareVersion(string(stdout)), nil
+}
+
+/*
+parseFirmwareVersion returns the firmware-version field from the output of ethtool -i.
+*/
+func parseFirmwareVersion(output string) string {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), ":", 2)
+		if len(fields) == 2 && strings.TrimSpace(fields[0]) == "firmware-version" {
+			version := strings.TrimSpace(fields[1])
+			if version == "N/A" {
+				return ""
+			}
+			return version
+		}
+	}
+
+	return ""
+}
+
+/*
+FirmwareAtLeast returns true if a firmware version is equal to or newer than a minimum version.
+Only the leading dot separated numeric version is compared, as drivers append further detail,
+e.g. "8.30 0x8000a4ae 1.2926.0" for i40e or "16.31.1014 (MT_0000000008)" for mlx5.
+Missing components are treated as zero, so 8.3 is equal to 8.3.0.
+*/
+func FirmwareAtLeast(version string, minimum string) (bool, error) {
+	have, err := firmwareComponents(version)
+	if err != nil {
+		return false, err
+	}
+
+	want, err := firmwareComponents(minimum)
+	if err != nil {
+		return false, err
+	}
+
+	for i := 0; i < len(have) || i < len(want); i++ {
+		var h, w int
+		if i < len(have) {
+			h = have[i]
+		}
+		if i < len(want) {
+			w = want[i]
+		}
+		if h != w {
+			return h > w, nil
+		}
+	}
+
+	return true, nil
+}
+
+func firmwareComponents(version string) ([]int, error) {
+	fields := strings.Fields(version)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("firmware version is empty")
+	}
+
+	var components []int
+	for _, c := range strings.Split(fields[0], ".") {
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			return nil, fmt.Errorf("firmware version %q is not a dot separated numeric version", version)
+		}
+		components = append(components, n)
+	}
+
+	return components, nil
+}
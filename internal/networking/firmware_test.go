@@ -0,0 +1,102 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFirmwareVersion(t *testing.T) {
+	testCases := []struct {
+		name       string
+		output     string
+		expVersion string
+	}{
+		{
+			name: "i40e",
+			output: `driver: i40e
+version: 5.15.0
+firmware-version: 8.30 0x8000a4ae 1.2926.0
+expansion-rom-version:
+bus-info: 0000:18:00.0
+`,
+			expVersion: "8.30 0x8000a4ae 1.2926.0",
+		},
+		{
+			name: "mlx5",
+			output: `driver: mlx5_core
+version: 5.0-0
+firmware-version: 16.31.1014 (MT_0000000008)
+`,
+			expVersion: "16.31.1014 (MT_0000000008)",
+		},
+		{
+			name: "veth",
+			output: `driver: veth
+version: 1.0
+firmware-version:
+`,
+			expVersion: "",
+		},
+		{
+			name: "not available",
+			output: `driver: virtio_net
+firmware-version: N/A
+`,
+			expVersion: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expVersion, parseFirmwareVersion(tc.output), "Firmware version does not match")
+		})
+	}
+}
+
+func TestFirmwareAtLeast(t *testing.T) {
+	testCases := []struct {
+		name     string
+		version  string
+		minimum  string
+		expOk    bool
+		expError bool
+	}{
+		{name: "equal", version: "8.30 0x8000a4ae 1.2926.0", minimum: "8.30", expOk: true},
+		{name: "newer minor", version: "8.50 0x8000a4ae 1.2926.0", minimum: "8.30", expOk: true},
+		{name: "older minor", version: "8.15 0x8000a4ae 1.2926.0", minimum: "8.30", expOk: false},
+		{name: "newer major", version: "9.0", minimum: "8.30", expOk: true},
+		{name: "missing components", version: "8.3", minimum: "8.3.0", expOk: true},
+		{name: "older patch", version: "16.31.1014 (MT_0000000008)", minimum: "16.31.2000", expOk: false},
+		{name: "empty version", version: "", minimum: "8.30", expError: true},
+		{name: "non numeric version", version: "v8.30", minimum: "8.30", expError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := FirmwareAtLeast(tc.version, tc.minimum)
+			if tc.expError {
+				require.Error(t, err, "Error was expected")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expOk, ok, "Unexpected result")
+		})
+	}
+}
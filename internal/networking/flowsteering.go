@@ -0,0 +1,431 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+var flowRulesFile = constants.DeviceFile.Directory + constants.FlowRules.FileName
+
+/*
+FlowRule represents an ntuple (flow director) rule installed on a netdev.
+Owner identifies the allocation that installed the rule. Rules that were not
+installed by the plugins, i.e. pre-existing rules, have no owner.
+*/
+type FlowRule struct {
+	Location int
+	Owner    string
+	FlowType string
+	Match    map[string]string
+	Action   string
+}
+
+/*
+flowRuleState is the on-disk record of which flow rules are owned by which allocation.
+It is keyed on device name and is shared between CNI invocations.
+*/
+type flowRuleState map[string][]*FlowRule
+
+/*
+AddFlowRule installs an ntuple rule on a netdev on behalf of owner and records its ownership.
+The rule is given in ethtool format, without the device, e.g. "flow-type udp4 dst-ip 10.0.0.1 action 3".
+Before installing, the rule is checked for conflicts with the rules already on the device, whether
+installed by another allocation or pre-existing. The location of the installed rule is returned.
+*/
+func (r *handler) AddFlowRule(interfaceName string, owner string, rule string) (int, error) {
+	if owner == "" {
+		return -1, fmt.Errorf("flow rule requires an owner")
+	}
+
+	requested, err := parseFlowRuleSpec(rule)
+	if err != nil {
+		return -1, err
+	}
+
+	var location int
+	err = withFlowRuleState(func(state flowRuleState) error {
+		existing, err := r.listAndReconcile(interfaceName, state)
+		if err != nil {
+			return err
+		}
+
+		if conflict := findFlowRuleConflict(existing, requested); conflict != nil {
+			owner := conflict.Owner
+			if owner == "" {
+				owner = "pre-existing"
+			}
+			return fmt.Errorf("flow rule [%s] conflicts with %s rule at location %d", rule, owner, conflict.Location)
+		}
+
+		args := append([]string{"--config-ntuple", interfaceName}, strings.Fields(rule)...)
+		stdout, err := exec.Command(ethtool, args...).CombinedOutput()
+		if err != nil {
+			logging.Errorf("Error adding flow rule [%s] to device %s: %s", rule, interfaceName, string(stdout))
+			return err
+		}
+
+		location = requested.Location
+		if location < 0 {
+			if location, err = parseAddedRuleLocation(string(stdout)); err != nil {
+				return err
+			}
+		}
+
+		requested.Location = location
+		requested.Owner = owner
+		state[interfaceName] = append(state[interfaceName], requested)
+
+		return nil
+	})
+	if err != nil {
+		return -1, err
+	}
+
+	logging.Debugf("Flow rule [%s] added to device %s at location %d for %s", rule, interfaceName, location, owner)
+
+	return location, nil
+}
+
+/*
+ListFlowRules returns all ntuple rules on a netdev. Rules installed through AddFlowRule
+carry their owner, pre-existing rules are returned with an empty owner.
+*/
+func (r *handler) ListFlowRules(interfaceName string) ([]*FlowRule, error) {
+	var rules []*FlowRule
+
+	err := withFlowRuleState(func(state flowRuleState) error {
+		var err error
+		rules, err = r.listAndReconcile(interfaceName, state)
+		return err
+	})
+
+	return rules, err
+}
+
+/*
+DeleteFlowRules removes every ntuple rule on a netdev that is owned by owner.
+Rules belonging to other allocations, and pre-existing rules, are left in place.
+Rules that no longer exist on the device are dropped from the ownership record.
+*/
+func (r *handler) DeleteFlowRules(interfaceName string, owner string) error {
+	return withFlowRuleState(func(state flowRuleState) error {
+		existing, err := r.listAndReconcile(interfaceName, state)
+		if err != nil {
+			return err
+		}
+
+		var remaining []*FlowRule
+		var errs []string
+		for _, rule := range existing {
+			if rule.Owner == "" {
+				continue
+			}
+			if rule.Owner != owner {
+				remaining = append(remaining, rule)
+				continue
+			}
+
+			loc := strconv.Itoa(rule.Location)
+			stdout, err := exec.Command(ethtool, "--config-ntuple", interfaceName, "delete", loc).CombinedOutput()
+			if err != nil {
+				logging.Errorf("Error deleting flow rule %s from device %s: %s", loc, interfaceName, string(stdout))
+				errs = append(errs, loc)
+				remaining = append(remaining, rule)
+				continue
+			}
+			logging.Debugf("Flow rule %s deleted from device %s", loc, interfaceName)
+		}
+
+		if len(remaining) == 0 {
+			delete(state, interfaceName)
+		} else {
+			state[interfaceName] = remaining
+		}
+
+		if len(errs) > 0 {
+			return fmt.Errorf("failed to delete flow rules %v from device %s", errs, interfaceName)
+		}
+		return nil
+	})
+}
+
+/*
+listAndReconcile lists the ntuple rules on a device and merges in ownership from state.
+Owned rules that no longer exist on the device are removed from state.
+*/
+func (r *handler) listAndReconcile(interfaceName string, state flowRuleState) ([]*FlowRule, error) {
+	stdout, err := exec.Command(ethtool, "--show-ntuple", interfaceName).CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error listing flow rules of device %s: %s", interfaceName, string(stdout))
+		return nil, err
+	}
+
+	rules, err := parseFlowRules(string(stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	onDevice := make(map[int]*FlowRule)
+	for _, rule := range rules {
+		onDevice[rule.Location] = rule
+	}
+
+	var owned []*FlowRule
+	for _, rule := range state[interfaceName] {
+		if dev, ok := onDevice[rule.Location]; ok {
+			dev.Owner = rule.Owner
+			owned = append(owned, rule)
+		} else {
+			logging.Warningf("Flow rule %d owned by %s no longer exists on device %s", rule.Location, rule.Owner, interfaceName)
+		}
+	}
+	if len(owned) == 0 {
+		delete(state, interfaceName)
+	} else {
+		state[interfaceName] = owned
+	}
+
+	return rules, nil
+}
+
+/*
+withFlowRuleState loads the flow rule ownership file under an exclusive lock, runs fn
+and writes the possibly modified state back. The lock serialises concurrent CNI invocations.
+*/
+func withFlowRuleState(fn func(state flowRuleState) error) error {
+	return withStateFile(flowRulesFile, constants.FlowRules.FilePermissions, func(raw []byte) ([]byte, error) {
+		state := make(flowRuleState)
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &state); err != nil {
+				logging.Warningf("Flow rules file is corrupt, ownership records will be rebuilt: %v", err)
+				state = make(flowRuleState)
+			}
+		}
+
+		fnErr := fn(state)
+
+		jsonStr, err := json.MarshalIndent(state, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonStr, fnErr
+	})
+}
+
+/*
+findFlowRuleConflict returns the first existing rule that conflicts with the requested rule.
+A rule conflicts if it occupies the requested location or if it matches the same flow.
+*/
+func findFlowRuleConflict(existing []*FlowRule, requested *FlowRule) *FlowRule {
+	for _, rule := range existing {
+		if requested.Location >= 0 && rule.Location == requested.Location {
+			return rule
+		}
+		if rule.FlowType == requested.FlowType && reflect.DeepEqual(rule.Match, requested.Match) {
+			return rule
+		}
+	}
+	return nil
+}
+
+/*
+flowRuleSpecKeys maps the ethtool rule spec keywords to the match keys used internally.
+*/
+var flowRuleSpecKeys = map[string]string{
+	"src-ip":   "src-ip",
+	"dst-ip":   "dst-ip",
+	"src-port": "src-port",
+	"dst-port": "dst-port",
+	"vlan":     "vlan",
+	"dst-mac":  "dst-mac",
+	"src-mac":  "src-mac",
+	"proto":    "proto",
+}
+
+/*
+flowRuleListingKeys maps the ethtool --show-ntuple field names to the match keys used internally.
+*/
+var flowRuleListingKeys = map[string]string{
+	"Src IP addr":    "src-ip",
+	"Dest IP addr":   "dst-ip",
+	"Src port":       "src-port",
+	"Dest port":      "dst-port",
+	"VLAN EtherType": "",
+	"VLAN":           "vlan",
+	"Dest MAC addr":  "dst-mac",
+	"Src MAC addr":   "src-mac",
+	"Protocol":       "proto",
+}
+
+/*
+flowRuleTypes maps the ethtool --show-ntuple rule types to ethtool flow-type keywords.
+*/
+var flowRuleTypes = map[string]string{
+	"TCP over IPv4":  "tcp4",
+	"UDP over IPv4":  "udp4",
+	"SCTP over IPv4": "sctp4",
+	"Raw IPv4":       "ip4",
+	"TCP over IPv6":  "tcp6",
+	"UDP over IPv6":  "udp6",
+	"SCTP over IPv6": "sctp6",
+	"Raw IPv6":       "ip6",
+	"Raw Ethernet":   "ether",
+}
+
+/*
+parseFlowRuleSpec parses an ethtool ntuple rule spec into a FlowRule.
+The location is -1 if the spec does not request a specific location.
+*/
+func parseFlowRuleSpec(rule string) (*FlowRule, error) {
+	flowRule := &FlowRule{Location: -1, Match: make(map[string]string)}
+	words := strings.Fields(rule)
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if i+1 >= len(words) {
+			return nil, fmt.Errorf("flow rule [%s] is missing a value for %s", rule, word)
+		}
+		value := words[i+1]
+		i++
+
+		switch word {
+		case "flow-type":
+			flowRule.FlowType = value
+		case "action", "queue", "context":
+			flowRule.Action = value
+		case "loc":
+			loc, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("flow rule [%s] has an invalid location: %v", rule, err)
+			}
+			flowRule.Location = loc
+		case "m":
+			// masks are not considered when matching rules
+		default:
+			if key, ok := flowRuleSpecKeys[word]; ok {
+				flowRule.Match[key] = strings.ToLower(value)
+			}
+		}
+	}
+
+	if flowRule.FlowType == "" {
+		return nil, fmt.Errorf("flow rule [%s] has no flow-type", rule)
+	}
+
+	return flowRule, nil
+}
+
+/*
+parseAddedRuleLocation extracts the rule location from ethtool's "Added rule with ID <n>" output.
+*/
+func parseAddedRuleLocation(output string) (int, error) {
+	match := regexp.MustCompile(`Added rule with ID (\d+)`).FindStringSubmatch(output)
+	if match == nil {
+		return -1, fmt.Errorf("unable to determine location of added flow rule: %s", output)
+	}
+	return strconv.Atoi(match[1])
+}
+
+/*
+parseFlowRules parses the output of ethtool --show-ntuple into a list of FlowRules.
+Fields whose mask is all ones are wildcards and are not included in the rule match.
+*/
+func parseFlowRules(output string) ([]*FlowRule, error) {
+	var rules []*FlowRule
+	var current *FlowRule
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "Filter:") {
+			loc, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "Filter:")))
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse flow rule location %q: %v", line, err)
+			}
+			current = &FlowRule{Location: loc, Match: make(map[string]string)}
+			rules = append(rules, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		fields := strings.SplitN(line, ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(fields[0])
+		value := strings.TrimSpace(fields[1])
+
+		switch name {
+		case "Rule Type":
+			current.FlowType = flowRuleTypes[value]
+		case "Action":
+			current.Action = strings.TrimPrefix(value, "Direct to queue ")
+		default:
+			key, ok := flowRuleListingKeys[name]
+			if !ok || key == "" {
+				continue
+			}
+			// MAC addresses contain colons, so split value and mask on the mask keyword
+			parts := strings.SplitN(value, " mask: ", 2)
+			val := strings.TrimSpace(parts[0])
+			if len(parts) == 2 && isWildcardMask(strings.TrimSpace(parts[1])) {
+				continue
+			}
+			current.Match[key] = strings.ToLower(val)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+/*
+isWildcardMask returns true if every bit of an ethtool display mask is set, meaning the field is ignored.
+*/
+func isWildcardMask(mask string) bool {
+	switch {
+	case strings.HasPrefix(mask, "0x"):
+		value, err := strconv.ParseUint(strings.TrimPrefix(mask, "0x"), 16, 64)
+		if err != nil {
+			return false
+		}
+		return value != 0 && value&(value+1) == 0
+	case strings.Contains(mask, "."):
+		return mask == "255.255.255.255"
+	case strings.Contains(mask, ":"):
+		mask = strings.ToLower(mask)
+		return strings.Contains(mask, "f") && strings.Trim(mask, "f:") == ""
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ntupleListing = `4 RX rings available
+Total 2 rules
+
+Filter: 1022
+	Rule Type: UDP over IPv4
+	Src IP addr: 0.0.0.0 mask: 255.255.255.255
+	Dest IP addr: 192.168.1.10 mask: 0.0.0.0
+	TOS: 0x0 mask: 0xff
+	Src port: 0 mask: 0xffff
+	Dest port: 4789 mask: 0x0
+	Action: Direct to queue 3
+
+Filter: 1023
+	Rule Type: TCP over IPv4
+	Src IP addr: 0.0.0.0 mask: 255.255.255.255
+	Dest IP addr: 192.168.1.11 mask: 0.0.0.0
+	TOS: 0x0 mask: 0xff
+	Src port: 0 mask: 0xffff
+	Dest port: 0 mask: 0xffff
+	Action: Direct to queue 1
+`
+
+func TestParseFlowRules(t *testing.T) {
+	rules, err := parseFlowRules(ntupleListing)
+	require.NoError(t, err, "Unexpected error")
+	require.Len(t, rules, 2, "Unexpected number of rules")
+
+	assert.Equal(t, &FlowRule{
+		Location: 1022,
+		FlowType: "udp4",
+		Match:    map[string]string{"dst-ip": "192.168.1.10", "dst-port": "4789"},
+		Action:   "3",
+	}, rules[0], "Rule does not match")
+
+	assert.Equal(t, &FlowRule{
+		Location: 1023,
+		FlowType: "tcp4",
+		Match:    map[string]string{"dst-ip": "192.168.1.11"},
+		Action:   "1",
+	}, rules[1], "Rule does not match")
+
+	rules, err = parseFlowRules("4 RX rings available\nTotal 0 rules\n")
+	require.NoError(t, err, "Unexpected error")
+	assert.Len(t, rules, 0, "Expected no rules")
+}
+
+func TestParseFlowRuleSpec(t *testing.T) {
+	testCases := []struct {
+		name    string
+		rule    string
+		expRule *FlowRule
+		expErr  string
+	}{
+		{
+			name: "udp rule without location",
+			rule: "flow-type udp4 dst-ip 192.168.1.10 dst-port 4789 action 3",
+			expRule: &FlowRule{
+				Location: -1,
+				FlowType: "udp4",
+				Match:    map[string]string{"dst-ip": "192.168.1.10", "dst-port": "4789"},
+				Action:   "3",
+			},
+		},
+		{
+			name: "tcp rule with location and mask",
+			rule: "flow-type tcp4 dst-ip 192.168.1.11 m 0.0.0.255 action 1 loc 5",
+			expRule: &FlowRule{
+				Location: 5,
+				FlowType: "tcp4",
+				Match:    map[string]string{"dst-ip": "192.168.1.11"},
+				Action:   "1",
+			},
+		},
+		{
+			name:   "no flow type",
+			rule:   "dst-ip 192.168.1.10 action 3",
+			expErr: "has no flow-type",
+		},
+		{
+			name:   "missing value",
+			rule:   "flow-type udp4 dst-ip",
+			expErr: "is missing a value for dst-ip",
+		},
+		{
+			name:   "bad location",
+			rule:   "flow-type udp4 action 3 loc first",
+			expErr: "has an invalid location",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := parseFlowRuleSpec(tc.rule)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expRule, rule, "Rule does not match")
+		})
+	}
+}
+
+func TestFindFlowRuleConflict(t *testing.T) {
+	existing, err := parseFlowRules(ntupleListing)
+	require.NoError(t, err, "Unexpected error")
+	existing[1].Owner = "pod-a"
+
+	testCases := []struct {
+		name        string
+		rule        string
+		expLocation int
+	}{
+		{
+			name:        "same flow as pre-existing rule",
+			rule:        "flow-type udp4 dst-ip 192.168.1.10 dst-port 4789 action 2",
+			expLocation: 1022,
+		},
+		{
+			name:        "same flow as owned rule",
+			rule:        "flow-type tcp4 dst-ip 192.168.1.11 action 0",
+			expLocation: 1023,
+		},
+		{
+			name:        "location already in use",
+			rule:        "flow-type udp4 dst-ip 10.0.0.1 action 0 loc 1023",
+			expLocation: 1023,
+		},
+		{
+			name:        "different flow",
+			rule:        "flow-type udp4 dst-ip 192.168.1.12 action 0",
+			expLocation: -1,
+		},
+		{
+			name:        "same address different protocol",
+			rule:        "flow-type tcp4 dst-ip 192.168.1.10 dst-port 4789 action 0",
+			expLocation: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requested, err := parseFlowRuleSpec(tc.rule)
+			require.NoError(t, err, "Unexpected error")
+
+			conflict := findFlowRuleConflict(existing, requested)
+			if tc.expLocation < 0 {
+				assert.Nil(t, conflict, "Unexpected conflict")
+			} else {
+				require.NotNil(t, conflict, "Conflict was expected")
+				assert.Equal(t, tc.expLocation, conflict.Location, "Unexpected conflicting rule")
+			}
+		})
+	}
+}
+
+func TestNtupleRule(t *testing.T) {
+	testCases := []struct {
+		filter  string
+		expRule string
+		expOk   bool
+	}{
+		{
+			filter:  "--config-ntuple ens801f0 flow-type udp4 dst-ip 192.168.1.10 action 3",
+			expRule: "flow-type udp4 dst-ip 192.168.1.10 action 3",
+			expOk:   true,
+		},
+		{
+			filter:  "-N ens801f0 flow-type tcp4 action 1",
+			expRule: "flow-type tcp4 action 1",
+			expOk:   true,
+		},
+		{
+			filter: "-N ens801f0 delete 1023",
+		},
+		{
+			filter: "-X ens801f0 equal 5 start 3",
+		},
+	}
+
+	for i, tc := range testCases {
+		rule, ok := ntupleRule(tc.filter)
+		assert.Equal(t, tc.expOk, ok, "Unexpected result: test case %d", i)
+		assert.Equal(t, tc.expRule, rule, "Unexpected rule: test case %d", i)
+	}
+}
+
+func TestIsWildcardMask(t *testing.T) {
+	testCases := []struct {
+		mask     string
+		expected bool
+	}{
+		{mask: "255.255.255.255", expected: true},
+		{mask: "0.0.0.0", expected: false},
+		{mask: "0xffff", expected: true},
+		{mask: "0x0", expected: false},
+		{mask: "FF:FF:FF:FF:FF:FF", expected: true},
+		{mask: "00:00:00:00:00:00", expected: false},
+		{mask: "::", expected: false},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, isWildcardMask(tc.mask), "Should be equal: test case %d", i)
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	NetworkManager  = "NetworkManager"   // name reported for devices managed by NetworkManager
+	SystemdNetworkd = "systemd-networkd" // name reported for devices managed by systemd-networkd
+)
+
+var (
+	nmRunDir        = "/run/NetworkManager/devices" // NetworkManager runtime device state, one file per ifindex
+	nmConfDir       = "/etc/NetworkManager/conf.d"  // NetworkManager configuration drop-in directory
+	networkdRunDir  = "/run/systemd/netif/links"    // systemd-networkd runtime link state, one file per ifindex
+	networkdConfDir = "/etc/systemd/network"        // systemd-networkd configuration directory
+	unmanagedPrefix = "90-afxdp-unmanaged-"         // file name prefix of the drop-ins written by SetUnmanaged
+)
+
+/*
+GetHostNetworkManagers returns the host network managers, NetworkManager and systemd-networkd,
+that are currently managing a netdev. Managed devices can be reconfigured by the host network
+stack while allocated to a pod. Managers are detected through their runtime state files, so
+a manager that is not running is never reported.
+*/
+func (r *handler) GetHostNetworkManagers(interfaceName string) ([]string, error) {
+	var managers []string
+
+	ifindex, err := ioutil.ReadFile(filepath.Join(sysClassNet, interfaceName, "ifindex"))
+	if err != nil {
+		return nil, err
+	}
+	index := strings.TrimSpace(string(ifindex))
+
+	nm, err := readKeyValueFile(filepath.Join(nmRunDir, index))
+	if err != nil {
+		return nil, err
+	}
+	if nm["managed"] == "true" {
+		managers = append(managers, NetworkManager)
+	}
+
+	networkd, err := readKeyValueFile(filepath.Join(networkdRunDir, index))
+	if err != nil {
+		return nil, err
+	}
+	if state, ok := networkd["ADMIN_STATE"]; ok && state != "unmanaged" {
+		managers = append(managers, SystemdNetworkd)
+	}
+
+	return managers, nil
+}
+
+/*
+SetUnmanaged marks a netdev as unmanaged by the given host network managers, by writing a
+configuration drop-in for each and asking the manager to reload its configuration.
+*/
+func (r *handler) SetUnmanaged(interfaceName string, managers []string) error {
+	for _, manager := range managers {
+		switch manager {
+		case NetworkManager:
+			conf := fmt.Sprintf("[keyfile]\nunmanaged-devices=interface-name:%s\n", interfaceName)
+			if err := writeUnmanaged(nmConfDir, interfaceName+".conf", conf); err != nil {
+				return err
+			}
+			reloadManager(manager, "nmcli", "general", "reload", "conf")
+		case SystemdNetworkd:
+			conf := fmt.Sprintf("[Match]\nName=%s\n\n[Link]\nUnmanaged=yes\n", interfaceName)
+			if err := writeUnmanaged(networkdConfDir, interfaceName+".network", conf); err != nil {
+				return err
+			}
+			reloadManager(manager, "networkctl", "reload")
+		default:
+			return fmt.Errorf("unknown host network manager %s", manager)
+		}
+		logging.Infof("Device %s marked as unmanaged by %s", interfaceName, manager)
+	}
+
+	return nil
+}
+
+func writeUnmanaged(dir string, name string, conf string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("configuration directory %s is not available: %w", dir, err)
+	}
+	return ioutil.WriteFile(filepath.Join(dir, unmanagedPrefix+name), []byte(conf), 0644)
+}
+
+/*
+reloadManager asks a host network manager to reload its configuration. The manager CLI may not
+be available to the device plugin, in which case the drop-in takes effect on the next reload.
+*/
+func reloadManager(manager string, command string, args ...string) {
+	if _, err := exec.LookPath(command); err != nil {
+		logging.Warningf("%s not found, %s configuration will be applied on its next reload", command, manager)
+		return
+	}
+	if stdout, err := exec.Command(command, args...).CombinedOutput(); err != nil {
+		logging.Warningf("Error reloading %s configuration: %s", manager, string(stdout))
+	}
+}
+
+/*
+readKeyValueFile parses a key=value runtime state file, as written by NetworkManager and
+systemd-networkd. Section headers and comments are ignored. A missing file is not an error.
+*/
+func readKeyValueFile(path string) (map[string]string, error) {
+	state := make(map[string]string)
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		fields := strings.SplitN(line, "=", 2)
+		if len(fields) == 2 {
+			state[strings.TrimSpace(fields[0])] = strings.TrimSpace(fields[1])
+		}
+	}
+
+	return state, nil
+}
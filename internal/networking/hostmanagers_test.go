@@ -0,0 +1,91 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHostNetworkManagers(t *testing.T) {
+	testCases := []struct {
+		name        string
+		nmState     string
+		netdState   string
+		expManagers []string
+	}{
+		{
+			name: "no managers running",
+		},
+		{
+			name:        "managed by NetworkManager",
+			nmState:     "# NetworkManager runtime state\n[device]\nmanaged=true\n",
+			expManagers: []string{NetworkManager},
+		},
+		{
+			name:    "unmanaged by NetworkManager",
+			nmState: "[device]\nmanaged=false\n",
+		},
+		{
+			name:        "managed by systemd-networkd",
+			netdState:   "# This is private data. Do not parse.\nADMIN_STATE=configured\nOPER_STATE=routable\n",
+			expManagers: []string{SystemdNetworkd},
+		},
+		{
+			name:      "unmanaged by systemd-networkd",
+			netdState: "ADMIN_STATE=unmanaged\nOPER_STATE=off\n",
+		},
+		{
+			name:        "managed by both",
+			nmState:     "[device]\nmanaged=true\n",
+			netdState:   "ADMIN_STATE=configuring\n",
+			expManagers: []string{NetworkManager, SystemdNetworkd},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "hostmanagers")
+			require.NoError(t, err, "Unexpected error")
+			defer os.RemoveAll(dir)
+
+			sysClassNet, nmRunDir, networkdRunDir = dir, filepath.Join(dir, "nm"), filepath.Join(dir, "networkd")
+			defer func() {
+				sysClassNet, nmRunDir, networkdRunDir = "/sys/class/net", "/run/NetworkManager/devices", "/run/systemd/netif/links"
+			}()
+
+			require.NoError(t, os.MkdirAll(filepath.Join(dir, "ens1"), 0755), "Unexpected error")
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ens1", "ifindex"), []byte("7\n"), 0644), "Unexpected error")
+			if tc.nmState != "" {
+				require.NoError(t, os.MkdirAll(nmRunDir, 0755), "Unexpected error")
+				require.NoError(t, ioutil.WriteFile(filepath.Join(nmRunDir, "7"), []byte(tc.nmState), 0644), "Unexpected error")
+			}
+			if tc.netdState != "" {
+				require.NoError(t, os.MkdirAll(networkdRunDir, 0755), "Unexpected error")
+				require.NoError(t, ioutil.WriteFile(filepath.Join(networkdRunDir, "7"), []byte(tc.netdState), 0644), "Unexpected error")
+			}
+
+			managers, err := NewHandler().GetHostNetworkManagers("ens1")
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expManagers, managers, "Host network managers do not match")
+		})
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+var (
+	procInterrupts = "/proc/interrupts" // IRQ numbers and action names
+	procIrq        = "/proc/irq"        // per IRQ directories holding the IRQ affinity
+)
+
+/*
+nonQueueIrqs are substrings of the IRQ action names drivers give to vectors that do not
+serve a queue, such as the admin queue or link events.
+*/
+var nonQueueIrqs = []string{"misc", "async", "ctrl", "config", "fw"}
+
+/*
+SetIrqAffinity pins the IRQs of the queues of a netdev to a set of CPUs. Queue IRQs are
+assigned to the CPUs in turn, so with as many CPUs as queues each queue has a CPU of its own.
+Interrupt placement dominates AF_XDP latency, as the NAPI context serving a socket runs
+on the CPU that takes the queue interrupt. irqbalance, if running, may later move the IRQs.
+*/
+func (r *handler) SetIrqAffinity(interfaceName string, cpus []int) error {
+	if len(cpus) == 0 {
+		return fmt.Errorf("no CPUs to pin IRQs of device %s to", interfaceName)
+	}
+
+	irqs, err := r.GetQueueIrqs(interfaceName)
+	if err != nil {
+		return err
+	}
+	if len(irqs) == 0 {
+		return fmt.Errorf("no queue IRQs found for device %s", interfaceName)
+	}
+
+	for i, irq := range irqs {
+		cpu := cpus[i%len(cpus)]
+		path := filepath.Join(procIrq, strconv.Itoa(irq), "smp_affinity_list")
+		if err := ioutil.WriteFile(path, []byte(strconv.Itoa(cpu)), 0644); err != nil {
+			return fmt.Errorf("error pinning IRQ %d of device %s to CPU %d: %w", irq, interfaceName, cpu, err)
+		}
+		logging.Debugf("IRQ %d of device %s pinned to CPU %d", irq, interfaceName, cpu)
+	}
+
+	logging.Infof("Pinned %d queue IRQs of device %s to CPUs %v", len(irqs), interfaceName, cpus)
+	return nil
+}
+
+/*
+GetQueueIrqs returns the IRQs of the queues of a netdev in ascending order, which for the
+supported drivers is queue order. IRQs are found through the MSI vectors of the PCI device.
+*/
+func (r *handler) GetQueueIrqs(interfaceName string) ([]int, error) {
+	files, err := ioutil.ReadDir(filepath.Join(sysClassNet, interfaceName, pciLink, "msi_irqs"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading MSI IRQs of device %s: %w", interfaceName, err)
+	}
+
+	var msiIrqs []int
+	for _, file := range files {
+		if irq, err := strconv.Atoi(file.Name()); err == nil {
+			msiIrqs = append(msiIrqs, irq)
+		}
+	}
+
+	interrupts, err := ioutil.ReadFile(procInterrupts)
+	if err != nil {
+		return nil, err
+	}
+
+	return queueIrqs(string(interrupts), interfaceName, msiIrqs), nil
+}
+
+/*
+queueIrqs selects the queue IRQs of a netdev from its MSI IRQs, using the action names in
+/proc/interrupts. Drivers such as i40e and ice name queue vectors after the netdev, e.g.
+"i40e-ens801f0-TxRx-0". A PCI function may serve several netdevs, so when any vector is named
+after the netdev only those are returned. Otherwise, as with mlx5 "mlx5_comp0@pci:0000:81:00.0",
+all queue vectors of the PCI device are returned.
+*/
+func queueIrqs(interrupts string, interfaceName string, msiIrqs []int) []int {
+	names := make(map[int]string)
+
+	scanner := bufio.NewScanner(strings.NewReader(interrupts))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		irq, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+		names[irq] = fields[len(fields)-1]
+	}
+
+	var named, unnamed []int
+	for _, irq := range msiIrqs {
+		name, ok := names[irq]
+		if !ok || !isQueueIrq(name) {
+			continue
+		}
+		if strings.Contains(name, interfaceName) {
+			named = append(named, irq)
+		} else {
+			unnamed = append(unnamed, irq)
+		}
+	}
+
+	irqs := unnamed
+	if len(named) > 0 {
+		irqs = named
+	}
+	sort.Ints(irqs)
+
+	return irqs
+}
+
+func isQueueIrq(name string) bool {
+	for _, n := range nonQueueIrqs {
+		if strings.Contains(name, n) {
+			return false
+		}
+	}
+	return true
+}
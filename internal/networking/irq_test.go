@@ -0,0 +1,73 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueIrqs(t *testing.T) {
+	interrupts := `            CPU0       CPU1
+  0:         22          0   IO-APIC   2-edge      timer
+ 98:          1          0  IR-PCI-MSI 42467328-edge      i40e-0000:81:00.0:misc
+ 99:        120          3  IR-PCI-MSI 42467329-edge      i40e-ens801f0-TxRx-0
+100:         15          9  IR-PCI-MSI 42467330-edge      i40e-ens801f0-TxRx-1
+102:          7          0  IR-PCI-MSI 42467332-edge      i40e-ens801f0-TxRx-10
+101:          0          2  IR-PCI-MSI 42467331-edge      i40e-vf0-TxRx-0
+140:          0          0  IR-PCI-MSI 45088768-edge      mlx5_async0@pci:0000:86:00.0
+141:       1000          0  IR-PCI-MSI 45088769-edge      mlx5_comp0@pci:0000:86:00.0
+142:        200          0  IR-PCI-MSI 45088770-edge      mlx5_comp1@pci:0000:86:00.0
+NMI:          0          0   Non-maskable interrupts
+`
+
+	testCases := []struct {
+		name          string
+		interfaceName string
+		msiIrqs       []int
+		expIrqs       []int
+	}{
+		{
+			name:          "vectors named after netdev",
+			interfaceName: "ens801f0",
+			msiIrqs:       []int{102, 98, 99, 100, 101},
+			expIrqs:       []int{99, 100, 102},
+		},
+		{
+			name:          "vectors named after pci device",
+			interfaceName: "ens2f0np0",
+			msiIrqs:       []int{140, 141, 142},
+			expIrqs:       []int{141, 142},
+		},
+		{
+			name:          "no msi irqs",
+			interfaceName: "veth0",
+		},
+		{
+			name:          "msi irqs not in interrupts",
+			interfaceName: "ens801f0",
+			msiIrqs:       []int{200, 201},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			irqs := queueIrqs(interrupts, tc.interfaceName, tc.msiIrqs)
+			assert.Equal(t, tc.expIrqs, irqs, "Queue IRQs do not match")
+		})
+	}
+}
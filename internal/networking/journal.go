@@ -0,0 +1,253 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+var journalFile = constants.DeviceFile.Directory + constants.Journal.FileName
+
+const (
+	JournalNetnsMove   = "netns_move"  // device moved into a pod network namespace
+	JournalEthtool     = "ethtool"     // ethtool filters applied to a device
+	JournalChannels    = "channels"    // combined channel count of a device changed
+	JournalRss         = "rss"         // RSS indirection table and hash key of a device changed
+	JournalMtu         = "mtu"         // MTU of a device lowered to fit the UMEM frame size
+	JournalPromiscuous = "promiscuous" // promiscuous mode enabled on a device
+	JournalXdpAttach   = "xdp_attach"  // XDP program attached to a device
+
+	JournalSourceCni          = "cni"          // entry written by the CNI
+	JournalSourceDevicePlugin = "deviceplugin" // entry written by the device plugin
+)
+
+/*
+JournalEntry records a host networking change that is about to be made.
+Entries are written before the change and removed once the operation making it has
+finished, so any entry left in the journal belongs to an operation that crashed part
+way through. Channels holds the combined channel count prior to a channels change,
+RssHashKey the RSS hash key prior to an RSS change, Mtu the MTU prior to an MTU change.
+Netns holds the network namespace a device is moved into.
+*/
+type JournalEntry struct {
+	Id         int
+	Op         string
+	Source     string
+	Device     string
+	Owner      string
+	Netns      string
+	Channels   int
+	RssHashKey string
+	Mtu        int
+	Started    time.Time
+}
+
+/*
+journalState is the on-disk journal.
+*/
+type journalState struct {
+	NextId  int
+	Entries []*JournalEntry
+}
+
+/*
+JournalBegin writes an entry to the journal ahead of a host networking change and
+returns the entry ID, to be passed to JournalEnd once the operation has finished.
+*/
+func (r *handler) JournalBegin(entry *JournalEntry) (int, error) {
+	if entry == nil || entry.Op == "" || entry.Device == "" {
+		return 0, fmt.Errorf("journal entry must have an operation and a device")
+	}
+
+	err := withJournalState(func(state *journalState) error {
+		state.NextId++
+		entry.Id = state.NextId
+		entry.Started = time.Now()
+		state.Entries = append(state.Entries, entry)
+		return nil
+	})
+
+	return entry.Id, err
+}
+
+/*
+JournalEnd removes finished entries from the journal.
+*/
+func (r *handler) JournalEnd(ids ...int) error {
+	return withJournalState(func(state *journalState) error {
+		state.Entries = removeJournalEntries(state.Entries, ids)
+		return nil
+	})
+}
+
+/*
+GetJournal returns the unfinished journal entries, most recent first, the order in
+which they must be rolled back.
+*/
+func (r *handler) GetJournal() ([]*JournalEntry, error) {
+	var entries []*JournalEntry
+
+	err := withJournalState(func(state *journalState) error {
+		entries = append(entries, state.Entries...)
+		return nil
+	})
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Id > entries[j].Id })
+
+	return entries, err
+}
+
+/*
+RollbackJournalEntry undoes the host networking change recorded by a journal entry. cleanBpf
+detaches the XDP program of a device, undoing an XDP attach.
+*/
+func RollbackJournalEntry(entry *JournalEntry, netHandler Handler, cleanBpf func(ifname string) error) error {
+	switch entry.Op {
+	case JournalNetnsMove:
+		return netHandler.MoveToHostNs(entry.Device, entry.Netns)
+	case JournalEthtool:
+		return netHandler.DeleteEthtool(entry.Device, entry.Owner)
+	case JournalChannels:
+		if entry.Channels == 0 {
+			return nil
+		}
+		return netHandler.SetChannels(entry.Device, &Channels{Combined: entry.Channels})
+	case JournalRss:
+		return netHandler.RestoreRss(entry.Device, entry.RssHashKey)
+	case JournalMtu:
+		return netHandler.SetMtu(entry.Device, entry.Mtu)
+	case JournalPromiscuous:
+		return netHandler.RestorePromiscuous(entry.Device, entry.Owner)
+	case JournalXdpAttach:
+		return cleanBpf(entry.Device)
+	default:
+		return fmt.Errorf("unknown journal operation %s", entry.Op)
+	}
+}
+
+/*
+RollbackJournalEntries undoes the changes of an operation that failed part way through, most
+recent first, so they are not left behind, and removes their entries from the journal. entries
+are those begun by the operation, in the order the changes were made. An entry that could not be
+written to the journal, with an ID of 0, is still undone. Failing to undo a change is logged and
+the remaining changes are still undone.
+*/
+func RollbackJournalEntries(entries []*JournalEntry, netHandler Handler, cleanBpf func(ifname string) error) {
+	var ids []int
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		logging.Infof("Rolling back %s of device %s", entry.Op, entry.Device)
+		if err := RollbackJournalEntry(entry, netHandler, cleanBpf); err != nil {
+			logging.Errorf("Error rolling back %s of device %s, manual intervention may be required: %v", entry.Op, entry.Device, err)
+		}
+		if entry.Id != 0 {
+			ids = append(ids, entry.Id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return
+	}
+	if err := netHandler.JournalEnd(ids...); err != nil {
+		logging.Warningf("Error removing rolled back entries from journal: %v", err)
+	}
+}
+
+/*
+MoveToHostNs moves a device from a network namespace back to the host network namespace.
+If the namespace no longer exists the kernel has already returned any physical device it
+held, and nothing is done.
+*/
+func (r *handler) MoveToHostNs(interfaceName string, netnsPath string) error {
+	if _, err := os.Stat(netnsPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	hostNs, err := ns.GetCurrentNS()
+	if err != nil {
+		return err
+	}
+	defer hostNs.Close()
+
+	podNs, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return err
+	}
+	defer podNs.Close()
+
+	return podNs.Do(func(_ ns.NetNS) error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return err
+		}
+		for _, link := range links {
+			if link.Attrs().Name == interfaceName {
+				return netlink.LinkSetNsFd(link, int(hostNs.Fd()))
+			}
+		}
+		return nil
+	})
+}
+
+func removeJournalEntries(entries []*JournalEntry, ids []int) []*JournalEntry {
+	var remaining []*JournalEntry
+	for _, entry := range entries {
+		finished := false
+		for _, id := range ids {
+			if entry.Id == id {
+				finished = true
+				break
+			}
+		}
+		if !finished {
+			remaining = append(remaining, entry)
+		}
+	}
+	return remaining
+}
+
+/*
+withJournalState loads the journal file under an exclusive lock, runs fn
+and writes the possibly modified state back.
+*/
+func withJournalState(fn func(state *journalState) error) error {
+	return withStateFile(journalFile, constants.Journal.FilePermissions, func(raw []byte) ([]byte, error) {
+		state := &journalState{}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, state); err != nil {
+				logging.Warningf("Journal file is corrupt, unfinished host changes cannot be rolled back: %v", err)
+				state = &journalState{}
+			}
+		}
+
+		fnErr := fn(state)
+
+		jsonStr, err := json.MarshalIndent(state, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonStr, fnErr
+	})
+}
@@ -0,0 +1,96 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	defaultJournalFile := journalFile
+	journalFile = filepath.Join(dir, "journal.json")
+	defer func() { journalFile = defaultJournalFile }()
+
+	r := &handler{}
+
+	move, err := r.JournalBegin(&JournalEntry{Op: JournalNetnsMove, Device: "ens1", Netns: "/var/run/netns/cni-1"})
+	require.NoError(t, err, "Unexpected error")
+	channels, err := r.JournalBegin(&JournalEntry{Op: JournalChannels, Device: "ens1", Channels: 8})
+	require.NoError(t, err, "Unexpected error")
+	ethtool, err := r.JournalBegin(&JournalEntry{Op: JournalEthtool, Device: "ens2", Owner: "abc"})
+	require.NoError(t, err, "Unexpected error")
+
+	_, err = r.JournalBegin(&JournalEntry{Op: JournalEthtool})
+	assert.Error(t, err, "Entry without a device should be refused")
+
+	entries, err := r.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	require.Len(t, entries, 3, "Unexpected number of journal entries")
+	assert.Equal(t, []int{ethtool, channels, move}, []int{entries[0].Id, entries[1].Id, entries[2].Id}, "Entries should be most recent first")
+	assert.Equal(t, 8, entries[1].Channels, "Prior channel count not recorded")
+	assert.False(t, entries[2].Started.IsZero(), "Start time not recorded")
+
+	require.NoError(t, r.JournalEnd(move, ethtool), "Unexpected error")
+
+	entries, err = r.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	require.Len(t, entries, 1, "Unexpected number of journal entries")
+	assert.Equal(t, channels, entries[0].Id, "Wrong entry ended")
+
+	next, err := r.JournalBegin(&JournalEntry{Op: JournalXdpAttach, Device: "ens1"})
+	require.NoError(t, err, "Unexpected error")
+	assert.Greater(t, next, ethtool, "Entry IDs must not be reused")
+}
+
+func TestRollbackJournalEntries(t *testing.T) {
+	fake := NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"ens1"}})
+	require.NoError(t, fake.SetChannels("ens1", &Channels{Combined: 4}), "Unexpected error")
+
+	entries := []*JournalEntry{
+		{Op: JournalXdpAttach, Device: "ens1"},
+		{Op: JournalChannels, Device: "ens1", Channels: 16},
+		{Op: JournalXdpAttach, Device: "ens2"},
+	}
+	for _, entry := range entries[:2] {
+		_, err := fake.JournalBegin(entry)
+		require.NoError(t, err, "Unexpected error")
+	}
+
+	var cleaned []string
+	RollbackJournalEntries(entries, fake, func(ifname string) error {
+		cleaned = append(cleaned, ifname)
+		return nil
+	})
+
+	assert.Equal(t, []string{"ens2", "ens1"}, cleaned, "Changes should be rolled back most recent first, including unwritten entries")
+	channels, err := fake.GetChannels("ens1")
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 16, channels.Combined, "Channels not restored")
+	pending, err := fake.GetJournal()
+	require.NoError(t, err, "Unexpected error")
+	assert.Empty(t, pending, "Rolled back entries left in the journal")
+}
@@ -84,6 +84,20 @@ func CreateKindNetwork(numVeths, offset int) error {
 	return nil
 }
 
+/*
+CreateKindNetwork creates the kind secondary network, see the package function.
+*/
+func (r *handler) CreateKindNetwork(numVeths, offset int) error {
+	return CreateKindNetwork(numVeths, offset)
+}
+
+/*
+KindNetworkExists returns true if the bridge of the kind secondary network exists.
+*/
+func (r *handler) KindNetworkExists() (bool, error) {
+	return CheckKindNetworkExists()
+}
+
 // CheckKindNetworkExists
 func CheckKindNetworkExists() (bool, error) {
 	return CheckBridgeExists(BridgeName)
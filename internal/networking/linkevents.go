@@ -0,0 +1,181 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"sync"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+const linkEventBuffer = 64 // events buffered per subscriber before events are dropped
+
+/*
+LinkEvent describes the state of a netdev following a link change.
+Deleted is set when the netdev is removed from the host network namespace,
+this includes devices moved into a pod network namespace.
+*/
+type LinkEvent struct {
+	Device    string
+	Index     int
+	AdminUp   bool
+	OperState string
+	Deleted   bool
+}
+
+/*
+Up returns true if the link is administratively up and operationally up.
+Devices that do not report an operational state are considered up when
+administratively up.
+*/
+func (e LinkEvent) Up() bool {
+	return !e.Deleted && e.AdminUp && (e.OperState == "up" || e.OperState == "unknown")
+}
+
+/*
+LinkSubscription delivers link events to a single subscriber. On subscription the
+last known state of each matching device is delivered, followed by state changes.
+Events are only delivered when the state of a device changes. A subscriber that does
+not keep up with its Events channel will miss events rather than block other subscribers.
+*/
+type LinkSubscription struct {
+	Events      <-chan LinkEvent
+	events      chan LinkEvent
+	devices     map[string]bool
+	broadcaster *linkBroadcaster
+}
+
+/*
+Close ends the subscription and closes the Events channel.
+*/
+func (s *LinkSubscription) Close() {
+	s.broadcaster.unsubscribe(s)
+}
+
+func (s *LinkSubscription) matches(device string) bool {
+	return len(s.devices) == 0 || s.devices[device]
+}
+
+/*
+linkBroadcaster fans link events from a single source out to any number of subscribers.
+The source is started with the first subscription and stopped after the last is closed,
+so a single rtnetlink subscription serves every subsystem.
+*/
+type linkBroadcaster struct {
+	lock        sync.Mutex
+	subscribers map[*LinkSubscription]bool
+	last        map[string]LinkEvent
+	start       func(b *linkBroadcaster) (func(), error)
+	stop        func()
+}
+
+func newLinkBroadcaster(start func(b *linkBroadcaster) (func(), error)) *linkBroadcaster {
+	return &linkBroadcaster{
+		subscribers: make(map[*LinkSubscription]bool),
+		last:        make(map[string]LinkEvent),
+		start:       start,
+	}
+}
+
+/*
+subscribe registers a new subscriber for events on the named devices, or all devices if none are named.
+*/
+func (b *linkBroadcaster) subscribe(interfaceNames []string) (*LinkSubscription, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.subscribers) == 0 && b.start != nil {
+		stop, err := b.start(b)
+		if err != nil {
+			logging.Errorf("Error starting link event source: %v", err)
+			return nil, err
+		}
+		b.stop = stop
+	}
+
+	events := make(chan LinkEvent, linkEventBuffer)
+	sub := &LinkSubscription{
+		Events:      events,
+		events:      events,
+		devices:     make(map[string]bool),
+		broadcaster: b,
+	}
+	for _, name := range interfaceNames {
+		sub.devices[name] = true
+	}
+	b.subscribers[sub] = true
+
+	for device, event := range b.last {
+		if sub.matches(device) {
+			b.send(sub, event)
+		}
+	}
+
+	return sub, nil
+}
+
+/*
+unsubscribe removes a subscriber and closes its channel, stopping the source if it was the last.
+*/
+func (b *linkBroadcaster) unsubscribe(sub *LinkSubscription) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if !b.subscribers[sub] {
+		return
+	}
+	delete(b.subscribers, sub)
+	close(sub.events)
+
+	if len(b.subscribers) == 0 {
+		if b.stop != nil {
+			b.stop()
+			b.stop = nil
+		}
+		b.last = make(map[string]LinkEvent)
+	}
+}
+
+/*
+publish delivers an event to every matching subscriber, if it changes the known state of the device.
+*/
+func (b *linkBroadcaster) publish(event LinkEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if last, ok := b.last[event.Device]; ok && last == event {
+		return
+	}
+	if event.Deleted {
+		delete(b.last, event.Device)
+	} else {
+		b.last[event.Device] = event
+	}
+
+	for sub := range b.subscribers {
+		if sub.matches(event.Device) {
+			b.send(sub, event)
+		}
+	}
+}
+
+func (b *linkBroadcaster) send(sub *LinkSubscription, event LinkEvent) {
+	select {
+	case sub.events <- event:
+	default:
+		logging.Warningf("Link event subscriber is not keeping up, dropped event for device %s", event.Device)
+	}
+}
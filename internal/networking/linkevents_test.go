@@ -0,0 +1,106 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(sub *LinkSubscription) []LinkEvent {
+	var events []LinkEvent
+	for {
+		select {
+		case event := <-sub.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func TestLinkBroadcaster(t *testing.T) {
+	starts, stops := 0, 0
+	b := newLinkBroadcaster(func(b *linkBroadcaster) (func(), error) {
+		starts++
+		return func() { stops++ }, nil
+	})
+
+	up := LinkEvent{Device: "ens801f0", Index: 4, AdminUp: true, OperState: "up"}
+	down := LinkEvent{Device: "ens801f0", Index: 4, AdminUp: true, OperState: "down"}
+	other := LinkEvent{Device: "ens801f1", Index: 5, AdminUp: true, OperState: "up"}
+
+	all, err := b.subscribe(nil)
+	require.NoError(t, err, "Unexpected error")
+	one, err := b.subscribe([]string{"ens801f0"})
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1, starts, "Source should start once")
+
+	b.publish(up)
+	b.publish(up)
+	b.publish(other)
+	b.publish(down)
+
+	assert.Equal(t, []LinkEvent{up, other, down}, receive(all), "Unexpected events for all devices")
+	assert.Equal(t, []LinkEvent{up, down}, receive(one), "Unexpected events for single device")
+
+	late, err := b.subscribe([]string{"ens801f0"})
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, []LinkEvent{down}, receive(late), "Late subscriber should receive last known state")
+
+	all.Close()
+	one.Close()
+	assert.Equal(t, 0, stops, "Source should run while subscribed")
+	late.Close()
+	late.Close()
+	assert.Equal(t, 1, stops, "Source should stop after last subscriber")
+
+	_, open := <-late.Events
+	assert.False(t, open, "Events channel should be closed")
+}
+
+func TestLinkBroadcasterSlowSubscriber(t *testing.T) {
+	b := newLinkBroadcaster(nil)
+
+	sub, err := b.subscribe(nil)
+	require.NoError(t, err, "Unexpected error")
+	defer sub.Close()
+
+	for i := 0; i < linkEventBuffer*2; i++ {
+		b.publish(LinkEvent{Device: "veth0", Index: i})
+	}
+
+	assert.Len(t, receive(sub), linkEventBuffer, "Events beyond the buffer should be dropped")
+}
+
+func TestLinkEventUp(t *testing.T) {
+	testCases := []struct {
+		event    LinkEvent
+		expected bool
+	}{
+		{event: LinkEvent{AdminUp: true, OperState: "up"}, expected: true},
+		{event: LinkEvent{AdminUp: true, OperState: "unknown"}, expected: true},
+		{event: LinkEvent{AdminUp: true, OperState: "down"}, expected: false},
+		{event: LinkEvent{AdminUp: false, OperState: "up"}, expected: false},
+		{event: LinkEvent{AdminUp: true, OperState: "up", Deleted: true}, expected: false},
+	}
+
+	for i, tc := range testCases {
+		assert.Equal(t, tc.expected, tc.event.Up(), "Should be equal: test case %d", i)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"net"
+	"syscall"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+/*
+linkEvents is shared by all handlers, so every subsystem is served by the same rtnetlink subscription.
+*/
+var linkEvents = newLinkBroadcaster(startLinkSource)
+
+/*
+SubscribeLinkEvents subscribes to link state changes of the named netdevs, or of all netdevs if
+none are named. Events are sourced from the rtnetlink link multicast group rather than polling.
+The subscription must be closed when no longer required.
+*/
+func (r *handler) SubscribeLinkEvents(interfaceNames ...string) (*LinkSubscription, error) {
+	return linkEvents.subscribe(interfaceNames)
+}
+
+/*
+startLinkSource subscribes to rtnetlink link updates and publishes them on the broadcaster.
+Existing links are listed first so the broadcaster learns the current state of every device.
+The returned function ends the rtnetlink subscription.
+*/
+func startLinkSource(b *linkBroadcaster) (func(), error) {
+	updates := make(chan netlink.LinkUpdate, linkEventBuffer)
+	done := make(chan struct{})
+
+	err := netlink.LinkSubscribeWithOptions(updates, done, netlink.LinkSubscribeOptions{
+		ListExisting: true,
+		ErrorCallback: func(err error) {
+			logging.Errorf("Link subscription error: %v", err)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for update := range updates {
+			b.publish(linkEventFromUpdate(update))
+		}
+		logging.Debugf("Link subscription closed")
+	}()
+
+	return func() { close(done) }, nil
+}
+
+func linkEventFromUpdate(update netlink.LinkUpdate) LinkEvent {
+	attrs := update.Link.Attrs()
+
+	return LinkEvent{
+		Device:    attrs.Name,
+		Index:     attrs.Index,
+		AdminUp:   attrs.Flags&net.FlagUp != 0,
+		OperState: attrs.OperState.String(),
+		Deleted:   update.Header.Type == syscall.RTM_DELLINK,
+	}
+}
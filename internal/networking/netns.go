@@ -0,0 +1,92 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/vishvananda/netlink"
+)
+
+/*
+CheckNetns returns an error if the network namespace at netnsPath cannot be opened.
+*/
+func (r *handler) CheckNetns(netnsPath string) error {
+	netns, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return err
+	}
+
+	return netns.Close()
+}
+
+/*
+MoveToNetns moves a device from the current network namespace into the network namespace at
+netnsPath and sets it up there.
+*/
+func (r *handler) MoveToNetns(interfaceName string, netnsPath string) error {
+	link, err := netlink.LinkByName(interfaceName)
+	if err != nil {
+		return fmt.Errorf("failed to find device %q: %w", interfaceName, err)
+	}
+
+	netns, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return err
+	}
+	defer netns.Close()
+
+	if err := netlink.LinkSetNsFd(link, int(netns.Fd())); err != nil {
+		return fmt.Errorf("failed to move device %q to netns %q: %w", interfaceName, netnsPath, err)
+	}
+
+	return netns.Do(func(_ ns.NetNS) error {
+		if err := netlink.LinkSetUp(link); err != nil {
+			return fmt.Errorf("failed to set device %q to UP state: %w", interfaceName, err)
+		}
+		return nil
+	})
+}
+
+/*
+MoveFromNetns moves a device from the network namespace at netnsPath back to the current network
+namespace. Unlike MoveToHostNs, it fails if the network namespace or the device in it is gone.
+*/
+func (r *handler) MoveFromNetns(interfaceName string, netnsPath string) error {
+	currentNs, err := ns.GetCurrentNS()
+	if err != nil {
+		return err
+	}
+	defer currentNs.Close()
+
+	netns, err := ns.GetNS(netnsPath)
+	if err != nil {
+		return err
+	}
+	defer netns.Close()
+
+	return netns.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(interfaceName)
+		if err != nil {
+			return fmt.Errorf("failed to find device %q in netns %q: %w", interfaceName, netnsPath, err)
+		}
+		if err := netlink.LinkSetNsFd(link, int(currentNs.Fd())); err != nil {
+			return fmt.Errorf("failed to move device %q out of netns %q: %w", interfaceName, netnsPath, err)
+		}
+		return nil
+	})
+}
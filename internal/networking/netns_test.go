@@ -0,0 +1,55 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeNetns(t *testing.T) {
+	fake := NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+
+	assert.Error(t, fake.MoveToNetns("dev2", "/var/run/netns/pod-1"), "moved a device not on the host")
+	assert.Error(t, fake.MoveFromNetns("dev1", "/var/run/netns/pod-1"), "moved a device not in the netns")
+
+	require.NoError(t, fake.MoveToNetns("dev1", "/var/run/netns/pod-1"))
+	assert.Equal(t, "/var/run/netns/pod-1", fake.GetNetns("dev1"))
+	assert.Error(t, fake.MoveToNetns("dev1", "/var/run/netns/pod-2"), "moved a device already in a netns")
+	assert.Error(t, fake.MoveFromNetns("dev1", "/var/run/netns/pod-2"), "moved a device from the wrong netns")
+
+	require.NoError(t, fake.MoveFromNetns("dev1", "/var/run/netns/pod-1"))
+	assert.Empty(t, fake.GetNetns("dev1"))
+}
+
+func TestFakeSetError(t *testing.T) {
+	fake := NewFakeHandler()
+	fake.SetHostDevices(map[string][]string{"i40e": {"dev1"}})
+	injected := errors.New("netlink failure")
+
+	fake.SetError("MoveToNetns", injected)
+	assert.Equal(t, injected, fake.MoveToNetns("dev1", "/var/run/netns/pod-1"))
+	assert.Empty(t, fake.GetNetns("dev1"), "device moved despite the error")
+	_, err := fake.GetMacAddress("dev1")
+	assert.NoError(t, err, "error set on another method")
+
+	fake.SetError("MoveToNetns", nil)
+	assert.NoError(t, fake.MoveToNetns("dev1", "/var/run/netns/pod-1"))
+}
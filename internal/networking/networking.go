@@ -23,6 +23,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
@@ -40,29 +41,72 @@ var (
 
 /*
 Handler is the CNI and device plugins interface to the host networking.
-The interface exists for testing purposes, allowing unit tests to test
-against a fake API.
+Every netlink, ethtool, devlink and sysfs operation of the plugins goes through it, so that unit
+tests can run against the in-memory fake of NewFakeHandler, including the error paths of each
+operation, without root or real devices.
 */
 type Handler interface {
 	GetHostDevices() (map[string]*Device, error)
 	GetDeviceDriver(interfaceName string) (string, error)
 	GetDevicePci(interfaceName string) (string, error)
+	GetDevicePciId(interfaceName string) (string, error)
 	GetIPAddresses(interfaceName string) ([]string, error)
 	GetMacAddress(device string) (string, error)
 	GetDeviceByMAC(mac string) (string, error)
 	GetDeviceByPCI(pci string) (string, error)
 	CycleDevice(interfaceName string) error
+	GetMtu(interfaceName string) (int, error)
+	SetMtu(interfaceName string, mtu int) error
 	NetDevExists(device string) (bool, error)
 	GetDeviceFromFile(deviceName string, filepath string) (*Device, error)
 	WriteDeviceFile(device *Device, filepath string) error
-	CreateCdqSubfunction(parentPci string, pfnum string, sfnum string) error     // see subfunction package
-	DeleteCdqSubfunction(portIndex string) error                                 // see subfunction package
-	IsCdqSubfunction(name string) (bool, error)                                  // see subfunction package
-	NumAvailableCdqSubfunctions(interfaceName string) (int, error)               // see subfunction package
-	GetCdqPortIndex(netdev string) (string, error)                               // see subfucntions package
-	GetCdqPfnum(netdev string) (string, error)                                   // see subfucntions package
-	SetEthtool(ethtoolCmd []string, interfaceName string, ipResult string) error // see ethtool.go
-	DeleteEthtool(interfaceName string) error                                    // see ethtool.go
+	CreateCdqSubfunction(parentPci string, pfnum string, sfnum string) error                   // see subfunction package
+	DeleteCdqSubfunction(portIndex string) error                                               // see subfunction package
+	IsCdqSubfunction(name string) (bool, error)                                                // see subfunction package
+	NumAvailableCdqSubfunctions(interfaceName string) (int, error)                             // see subfunction package
+	GetCdqPortIndex(netdev string) (string, error)                                             // see subfucntions package
+	GetCdqPfnum(netdev string) (string, error)                                                 // see subfucntions package
+	SetEthtool(ethtoolCmd []string, interfaceName string, ipResult string, owner string) error // see ethtool.go
+	DeleteEthtool(interfaceName string, owner string) error                                    // see ethtool.go
+	GetFirmwareVersion(interfaceName string) (string, error)                                   // see firmware.go
+	GetChannels(interfaceName string) (*Channels, error)                                       // see ethtool.go
+	SetChannels(interfaceName string, channels *Channels) error                                // see ethtool.go
+	GetQueueStats(interfaceName string) ([]*QueueStats, error)                                 // see ethtool.go
+	GetXskStats(interfaceName string) ([]*XskStats, error)                                     // see xdpdiag.go
+	GetRss(interfaceName string) (*Rss, error)                                                 // see ethtool.go
+	SetRss(interfaceName string, start int, count int, hashKey string) error                   // see ethtool.go
+	RestoreRss(interfaceName string, hashKey string) error                                     // see ethtool.go
+	AddFlowRule(interfaceName string, owner string, rule string) (int, error)                  // see flowsteering.go
+	ListFlowRules(interfaceName string) ([]*FlowRule, error)                                   // see flowsteering.go
+	DeleteFlowRules(interfaceName string, owner string) error                                  // see flowsteering.go
+	GetCapabilities(interfaceName string) (*Capabilities, error)                               // see capabilities.go
+	InvalidateCapabilities(interfaceName string)                                               // see capabilities.go
+	SetPromiscuous(interfaceName string, owner string) error                                   // see promiscuous.go
+	RestorePromiscuous(interfaceName string, owner string) error                               // see promiscuous.go
+	RecordAllocation(allocation *Allocation) error                                             // see allocations.go
+	RemoveAllocation(device string, owner string) error                                        // see allocations.go
+	GetAllocations() (map[string]*Allocation, error)                                           // see allocations.go
+	RecordSocket(socket *Socket) error                                                         // see sockets.go
+	RemoveSocket(path string) error                                                            // see sockets.go
+	GetSockets() (map[string]*Socket, error)                                                   // see sockets.go
+	SubscribeLinkEvents(interfaceNames ...string) (*LinkSubscription, error)                   // see linkwatch.go
+	GetActiveBackupBond(interfaceName string) (*Bond, error)                                   // see bond.go
+	ConfigureVirtio(interfaceName string) error                                                // see virtio.go
+	CreateTap(name string) (*Device, error)                                                    // see tap.go
+	DeleteTap(name string) error                                                               // see tap.go
+	GetHostNetworkManagers(interfaceName string) ([]string, error)                             // see hostmanagers.go
+	SetUnmanaged(interfaceName string, managers []string) error                                // see hostmanagers.go
+	JournalBegin(entry *JournalEntry) (int, error)                                             // see journal.go
+	JournalEnd(ids ...int) error                                                               // see journal.go
+	GetJournal() ([]*JournalEntry, error)                                                      // see journal.go
+	MoveToHostNs(interfaceName string, netnsPath string) error                                 // see journal.go
+	CheckNetns(netnsPath string) error                                                         // see netns.go
+	MoveToNetns(interfaceName string, netnsPath string) error                                  // see netns.go
+	MoveFromNetns(interfaceName string, netnsPath string) error                                // see netns.go
+	CreateKindNetwork(numVeths, offset int) error                                              // see kind.go
+	KindNetworkExists() (bool, error)                                                          // see kind.go
+	SetIrqAffinity(interfaceName string, cpus []int) error                                     // see irq.go
+	GetQueueIrqs(interfaceName string) ([]int, error)                                          // see irq.go
 	IsPhysicalPort(name string) (bool, error)
 }
 
@@ -158,6 +202,32 @@ func (r *handler) CycleDevice(interfaceName string) error {
 	return nil
 }
 
+/*
+GetMtu returns the MTU of a netdev
+Equivalent to 'ip link show <interface_name>'
+*/
+func (r *handler) GetMtu(interfaceName string) (int, error) {
+	device, err := netlink.LinkByName(interfaceName)
+	if err != nil {
+		return 0, err
+	}
+
+	return device.Attrs().MTU, nil
+}
+
+/*
+SetMtu sets the MTU of a netdev
+Equivalent to 'ip link set <interface_name> mtu <mtu>'
+*/
+func (r *handler) SetMtu(interfaceName string, mtu int) error {
+	device, err := netlink.LinkByName(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	return netlink.LinkSetMTU(device, mtu)
+}
+
 /*
 GetDeviceDriver takes a netdev name and returns the driver type.
 */
@@ -189,6 +259,35 @@ func (r *handler) GetDevicePci(interfaceName string) (string, error) {
 	return filepath.Base(pciInfo), nil
 }
 
+/*
+GetDevicePciId takes a device name and returns the PCI vendor and device ID in the form vendor:device, e.g. 8086:158b.
+An empty string is returned for devices that are not PCI devices.
+*/
+func (r *handler) GetDevicePciId(interfaceName string) (string, error) {
+	dir := filepath.Join(sysClassNet, interfaceName, pciLink)
+
+	vendor, err := ioutil.ReadFile(filepath.Join(dir, "vendor"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		logging.Errorf("Error getting PCI vendor of device %s: %v", interfaceName, err)
+		return "", err
+	}
+
+	device, err := ioutil.ReadFile(filepath.Join(dir, "device"))
+	if err != nil {
+		logging.Errorf("Error getting PCI device ID of device %s: %v", interfaceName, err)
+		return "", err
+	}
+
+	trim := func(id []byte) string {
+		return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(string(id))), "0x")
+	}
+
+	return trim(vendor) + ":" + trim(device), nil
+}
+
 /*
 MacAddress takes a device name and returns the MAC-address.
 */
@@ -243,6 +342,10 @@ func (r *handler) GetDeviceFromFile(deviceName string, filepath string) (*Device
 			macAddress:     deviceDetails.MacAddress,
 			fullyAssigned:  deviceDetails.FullyAssigned,
 			ethtoolFilters: deviceDetails.EthtoolFilters,
+			promiscuous:    deviceDetails.Promiscuous,
+			peer:           deviceDetails.Peer,
+			restoreMtu:     deviceDetails.RestoreMtu,
+			peerRestoreMtu: deviceDetails.PeerRestoreMtu,
 			netHandler:     r,
 			primary: &Device{
 				name:          deviceDetails.Primary.Name,
@@ -15,24 +15,97 @@
 
 package networking
 
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+)
+
 /*
 FakeHandler interface extends the Handler interface to provide additional testing methods.
 */
 type FakeHandler interface {
 	Handler
 	SetHostDevices(interfaceNames map[string][]string)
+	SendLinkEvent(event LinkEvent)
+	SetBond(bond *Bond)
+	SetHostNetworkManagers(interfaceName string, managers []string)
+	SetError(method string, err error)
+	GetNetns(interfaceName string) string
 }
 
 /*
 fakeHandler implements the FakeHandler interface.
 */
-type fakeHandler struct{}
+type fakeHandler struct {
+	lock sync.Mutex
+	errs map[string]error // errors set by SetError, keyed on method name
+}
 
 /*
 interfaceList holds a map of drivers and net.Interface objects, representing fake netdev objects.
 */
 var interfaceList map[string]*Device
 
+/*
+fakeAllocations holds the allocation records of the fake handler.
+*/
+var fakeAllocations = make(map[string]*Allocation)
+
+/*
+fakeSockets holds the socket records of the fake handler.
+*/
+var fakeSockets = make(map[string]*Socket)
+
+/*
+fakeLinkEvents delivers the link events sent via SendLinkEvent to subscribers of the fake handler.
+*/
+var fakeLinkEvents = newLinkBroadcaster(nil)
+
+/*
+fakeBonds holds the fake bonds set by SetBond, keyed on slave name.
+*/
+var fakeBonds = make(map[string]*Bond)
+
+/*
+fakeManagers holds the host network managers set by SetHostNetworkManagers, keyed on netdev name.
+*/
+var fakeManagers = make(map[string][]string)
+
+/*
+fakeJournal holds the journal entries of the fake handler.
+*/
+var fakeJournal = &journalState{}
+
+/*
+fakeMtus holds the MTUs set on fake netdevs.
+*/
+var fakeMtus = make(map[string]int)
+
+/*
+fakeNetns holds the network namespace each fake netdev has been moved to, keyed on netdev name.
+Netdevs not in the map are in the host network namespace.
+*/
+var fakeNetns = make(map[string]string)
+
+/*
+fakeChannels holds the combined channel counts set on fake netdevs.
+*/
+var fakeChannels = make(map[string]int)
+
+/*
+fakeRssKeys holds the RSS hash keys set on fake netdevs.
+*/
+var fakeRssKeys = make(map[string]string)
+
+/*
+fakeKindNetwork is true once the kind secondary network has been created.
+*/
+var fakeKindNetwork bool
+
 /*
 NewFakeHandler returns an implementation of the FakeHandler interface.
 */
@@ -40,11 +113,42 @@ func NewFakeHandler() FakeHandler {
 	return &fakeHandler{}
 }
 
+/*
+SetError sets an error returned by every call of a Handler method of this fake handler, named as
+in the interface, e.g. "SetChannels", so the error paths of its callers can be tested. Methods
+returning no error cannot fail. A nil error clears it.
+*/
+func (r *fakeHandler) SetError(method string, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if err == nil {
+		delete(r.errs, method)
+		return
+	}
+	if r.errs == nil {
+		r.errs = make(map[string]error)
+	}
+	r.errs[method] = err
+}
+
+/*
+fail returns the error set for a method by SetError, nil if none.
+*/
+func (r *fakeHandler) fail(method string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.errs[method]
+}
+
 /*
 GetHostDevices returns a map of devices on the host
 */
 func (r *fakeHandler) GetHostDevices() (map[string]*Device, error) {
-
+	if err := r.fail("GetHostDevices"); err != nil {
+		return nil, err
+	}
 	return interfaceList, nil
 }
 
@@ -53,6 +157,9 @@ SetHostDevices is a function used to dynamically setup mock devices and drivers
 */
 func (r *fakeHandler) SetHostDevices(interfaceMap map[string][]string) {
 	interfaceList = make(map[string]*Device)
+	fakeNetns = make(map[string]string)
+	fakeChannels = make(map[string]int)
+	fakeRssKeys = make(map[string]string)
 
 	for driver, interfaceNames := range interfaceMap {
 		for _, name := range interfaceNames {
@@ -67,14 +174,42 @@ GetDeviceDriver takes a device name and returns the driver type.
 In this fakeHandler it returns the driver of the fake netdev.
 */
 func (r *fakeHandler) GetDeviceDriver(interfaceName string) (string, error) {
+	if err := r.fail("GetDeviceDriver"); err != nil {
+		return "", err
+	}
 	return interfaceList[interfaceName].Driver()
 }
 
+/*
+GetFirmwareVersion returns the firmware version of a netdev.
+In this fakeHandler it returns a dummy version.
+*/
+func (r *fakeHandler) GetFirmwareVersion(interfaceName string) (string, error) {
+	if err := r.fail("GetFirmwareVersion"); err != nil {
+		return "", err
+	}
+	return "8.30 0x8000a4ae 1.2926.0", nil
+}
+
+/*
+GetDevicePciId takes a device name and returns the PCI vendor and device ID.
+In this fakeHandler it returns a dummy ID.
+*/
+func (r *fakeHandler) GetDevicePciId(interfaceName string) (string, error) {
+	if err := r.fail("GetDevicePciId"); err != nil {
+		return "", err
+	}
+	return "8086:158b", nil
+}
+
 /*
 GetDevicePci takes a device name and returns the pci address.
 In this fakeHandler it returns a dummy pci address.
 */
 func (r *fakeHandler) GetDevicePci(interfaceName string) (string, error) {
+	if err := r.fail("GetDevicePci"); err != nil {
+		return "", err
+	}
 	return "0000:18:00.3", nil
 }
 
@@ -83,6 +218,9 @@ IPAddresses takes a netdev name and returns its IP addresses
 In this fakeHandler it returns the IP of the fake netdev.
 */
 func (r *fakeHandler) GetIPAddresses(interfaceName string) ([]string, error) {
+	if err := r.fail("GetIPAddresses"); err != nil {
+		return nil, err
+	}
 	var addrs []string
 	return addrs, nil
 }
@@ -92,6 +230,9 @@ CycleDevice takes a netdave name and sets the device 'UP', then 'DOWN'
 In this fake handler it does nothing.
 */
 func (r *fakeHandler) CycleDevice(interfaceName string) error {
+	if err := r.fail("CycleDevice"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -100,6 +241,9 @@ SetQueueSize sets the queue size for the netdev.
 In this fake handler it does nothing.
 */
 func (r *fakeHandler) SetQueueSize(interfaceName string, size string) error {
+	if err := r.fail("SetQueueSize"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -108,6 +252,9 @@ SetDefaultQueueSize sets the netdev queue size back to default.
 In this fake handler it does nothing.
 */
 func (r *fakeHandler) SetDefaultQueueSize(interfaceName string) error {
+	if err := r.fail("SetDefaultQueueSize"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -116,6 +263,9 @@ GetMacAddress takes a device name and returns the MAC-address.
 This function uses fake handler, its purpose is for unit-testing only
 */
 func (r *fakeHandler) GetMacAddress(device string) (string, error) {
+	if err := r.fail("GetMacAddress"); err != nil {
+		return "", err
+	}
 	return "", nil
 }
 
@@ -124,6 +274,9 @@ NetDevExists takes a device name and verifies if device exists on host.
 This function uses fake handler, its purpose is for unit-testing
 */
 func (r *fakeHandler) NetDevExists(device string) (bool, error) {
+	if err := r.fail("NetDevExists"); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
@@ -133,6 +286,9 @@ It creates that subfunction on top of that port and activates it
 In this fake handler it does nothing
 */
 func (r *fakeHandler) CreateCdqSubfunction(parentPci string, pfnum string, sfnum string) error {
+	if err := r.fail("CreateCdqSubfunction"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -141,6 +297,9 @@ DeleteCdqSubfunction takes the port index of a subfunction, deactivates and dele
 In this fake handler it does nothing
 */
 func (r *fakeHandler) DeleteCdqSubfunction(portIndex string) error {
+	if err := r.fail("DeleteCdqSubfunction"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -149,6 +308,9 @@ IsCdqSubfunction takes a netdev name and returns true if is a CDQ subfunction
 In this fake handler it currently always returns true
 */
 func (r *fakeHandler) IsCdqSubfunction(name string) (bool, error) {
+	if err := r.fail("IsCdqSubfunction"); err != nil {
+		return false, err
+	}
 	return true, nil
 }
 
@@ -159,6 +321,9 @@ Other netdevs will return a "device not found by devlink" error
 In this fake handler it currently returns an empty string
 */
 func (r *fakeHandler) GetCdqPortIndex(netdev string) (string, error) {
+	if err := r.fail("GetCdqPortIndex"); err != nil {
+		return "", err
+	}
 	return "", nil
 }
 
@@ -169,6 +334,9 @@ Other netdevs will return a "device not found by devlink" error
 In this fake handler it currently returns an empty string
 */
 func (r *fakeHandler) GetCdqPfnum(netdev string) (string, error) {
+	if err := r.fail("GetCdqPfnum"); err != nil {
+		return "", err
+	}
 	return "", nil
 }
 
@@ -178,6 +346,9 @@ many unused CDQ subfunctions are available
 In this fake handler it currently returns 0
 */
 func (r *fakeHandler) NumAvailableCdqSubfunctions(interfaceName string) (int, error) {
+	if err := r.fail("NumAvailableCdqSubfunctions"); err != nil {
+		return 0, err
+	}
 	return 0, nil
 }
 
@@ -186,7 +357,10 @@ SetEthtool applies ethtool filters on the physical device during cmdAdd().
 Ethtool filters are set via the DP config.json file. This function uses fake handler,
 its purpose is for unit-testing only.
 */
-func (r *fakeHandler) SetEthtool(ethtoolCmd []string, interfaceName string, ipResult string) error {
+func (r *fakeHandler) SetEthtool(ethtoolCmd []string, interfaceName string, ipResult string, owner string) error {
+	if err := r.fail("SetEthtool"); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -195,7 +369,585 @@ DeleteEthtool sets the default queue size ethtool filter.
 It also removes perfect-flow ethtool filter entries during cmdDel()
 This function uses fake handler, its purpose is for unit-testing
 */
-func (r *fakeHandler) DeleteEthtool(interfaceName string) error {
+func (r *fakeHandler) DeleteEthtool(interfaceName string, owner string) error {
+	if err := r.fail("DeleteEthtool"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+AddFlowRule installs an ntuple rule on a netdev on behalf of owner.
+In this fake handler it only parses the rule and returns its requested location, or 0.
+*/
+func (r *fakeHandler) AddFlowRule(interfaceName string, owner string, rule string) (int, error) {
+	if err := r.fail("AddFlowRule"); err != nil {
+		return 0, err
+	}
+	flowRule, err := parseFlowRuleSpec(rule)
+	if err != nil {
+		return -1, err
+	}
+	if flowRule.Location < 0 {
+		return 0, nil
+	}
+	return flowRule.Location, nil
+}
+
+/*
+ListFlowRules returns all ntuple rules on a netdev.
+In this fake handler it returns no rules.
+*/
+func (r *fakeHandler) ListFlowRules(interfaceName string) ([]*FlowRule, error) {
+	if err := r.fail("ListFlowRules"); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+/*
+DeleteFlowRules removes every ntuple rule on a netdev that is owned by owner.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) DeleteFlowRules(interfaceName string, owner string) error {
+	if err := r.fail("DeleteFlowRules"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+GetChannels returns the current and maximum channel counts of a netdev.
+In this fake handler it returns a fixed set of channels, with the combined count last set.
+*/
+func (r *fakeHandler) GetChannels(interfaceName string) (*Channels, error) {
+	if err := r.fail("GetChannels"); err != nil {
+		return nil, err
+	}
+	if combined, ok := fakeChannels[interfaceName]; ok {
+		return &Channels{MaxCombined: 64, Combined: combined}, nil
+	}
+	return &Channels{MaxCombined: 64, Combined: 8}, nil
+}
+
+/*
+SetChannels sets the rx, tx and combined channel counts of a netdev.
+In this fake handler the request is validated and the combined count held in memory.
+*/
+func (r *fakeHandler) SetChannels(interfaceName string, channels *Channels) error {
+	if err := r.fail("SetChannels"); err != nil {
+		return err
+	}
+	current, _ := r.GetChannels(interfaceName)
+	if err := ValidateChannels(current, channels); err != nil {
+		return err
+	}
+	if channels.Combined > 0 {
+		fakeChannels[interfaceName] = channels.Combined
+	}
+	return nil
+}
+
+/*
+GetRss returns the RSS indirection table and hash key of a netdev.
+In this fake handler it returns a fixed RSS configuration spread across 8 rx rings, with the
+hash key last set.
+*/
+func (r *fakeHandler) GetRss(interfaceName string) (*Rss, error) {
+	if err := r.fail("GetRss"); err != nil {
+		return nil, err
+	}
+	hashKey, ok := fakeRssKeys[interfaceName]
+	if !ok {
+		hashKey = "7d:3c:f6:24:5a:9b:0e:11"
+	}
+	return &Rss{
+		RxRings: 8,
+		Table:   []int{0, 1, 2, 3, 4, 5, 6, 7},
+		HashKey: hashKey,
+	}, nil
+}
+
+/*
+SetRss programs the RSS indirection table and hash key of a netdev.
+In this fake handler the request is validated and the hash key held in memory.
+*/
+func (r *fakeHandler) SetRss(interfaceName string, start int, count int, hashKey string) error {
+	if err := r.fail("SetRss"); err != nil {
+		return err
+	}
+	current, _ := r.GetRss(interfaceName)
+	if err := ValidateRss(current, start, count, hashKey); err != nil {
+		return err
+	}
+	if hashKey != "" {
+		fakeRssKeys[interfaceName] = hashKey
+	}
+	return nil
+}
+
+/*
+RestoreRss resets the RSS indirection table of a netdev and restores its hash key.
+In this fake handler the hash key is held in memory.
+*/
+func (r *fakeHandler) RestoreRss(interfaceName string, hashKey string) error {
+	if err := r.fail("RestoreRss"); err != nil {
+		return err
+	}
+	if hashKey != "" {
+		fakeRssKeys[interfaceName] = hashKey
+	}
+	return nil
+}
+
+/*
+GetCapabilities returns the capabilities of a netdev.
+In this fake handler the capabilities are derived from the driver of the fake netdev.
+*/
+func (r *fakeHandler) GetCapabilities(interfaceName string) (*Capabilities, error) {
+	if err := r.fail("GetCapabilities"); err != nil {
+		return nil, err
+	}
+	driver := ""
+	if dev, ok := interfaceList[interfaceName]; ok {
+		driver, _ = dev.Driver()
+	}
+	return &Capabilities{
+		Driver:    driver,
+		NativeXdp: tools.ArrayContains(constants.Drivers.NativeXdp, driver),
+		ZeroCopy:  tools.ArrayContains(constants.Drivers.ZeroCopy, driver),
+		MaxQueues: 64,
+		Offloads:  map[string]bool{"ntuple-filters": true},
+	}, nil
+}
+
+/*
+InvalidateCapabilities removes a netdev from the capability cache.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) InvalidateCapabilities(interfaceName string) {
+}
+
+/*
+SetPromiscuous enables promiscuous mode on a netdev.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) SetPromiscuous(interfaceName string, owner string) error {
+	if err := r.fail("SetPromiscuous"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+RestorePromiscuous restores the promiscuous state of a netdev.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) RestorePromiscuous(interfaceName string, owner string) error {
+	if err := r.fail("RestorePromiscuous"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+GetQueueStats returns the per-queue counters of a netdev.
+In this fake handler it returns a single queue with fixed counters.
+*/
+func (r *fakeHandler) GetQueueStats(interfaceName string) ([]*QueueStats, error) {
+	if err := r.fail("GetQueueStats"); err != nil {
+		return nil, err
+	}
+	return []*QueueStats{{Queue: 0, RxPackets: 100, TxPackets: 50}}, nil
+}
+
+/*
+GetXskStats returns the per-queue counters of the AF_XDP sockets bound to a netdev.
+In this fake handler it returns a single queue with fixed counters.
+*/
+func (r *fakeHandler) GetXskStats(interfaceName string) ([]*XskStats, error) {
+	if err := r.fail("GetXskStats"); err != nil {
+		return nil, err
+	}
+	return []*XskStats{{Queue: 0, RxRingFull: 5, FillRingEmpty: 3}}, nil
+}
+
+/*
+RecordAllocation records that a device has been attached to a pod.
+In this fake handler allocations are held in memory.
+*/
+func (r *fakeHandler) RecordAllocation(allocation *Allocation) error {
+	if err := r.fail("RecordAllocation"); err != nil {
+		return err
+	}
+	fakeAllocations[allocation.Device] = allocation
+	return nil
+}
+
+/*
+RemoveAllocation removes the allocation record of a device.
+In this fake handler allocations are held in memory.
+*/
+func (r *fakeHandler) RemoveAllocation(device string, owner string) error {
+	if err := r.fail("RemoveAllocation"); err != nil {
+		return err
+	}
+	if allocation, ok := fakeAllocations[device]; ok && allocation.Owner == owner {
+		delete(fakeAllocations, device)
+	}
+	return nil
+}
+
+/*
+GetAllocations returns the allocation records of all devices.
+In this fake handler allocations are held in memory.
+*/
+func (r *fakeHandler) GetAllocations() (map[string]*Allocation, error) {
+	if err := r.fail("GetAllocations"); err != nil {
+		return nil, err
+	}
+	allocations := make(map[string]*Allocation)
+	for device, allocation := range fakeAllocations {
+		allocations[device] = allocation
+	}
+	return allocations, nil
+}
+
+/*
+RecordSocket records that a UDS socket is being served.
+In this fake handler sockets are held in memory.
+*/
+func (r *fakeHandler) RecordSocket(socket *Socket) error {
+	if err := r.fail("RecordSocket"); err != nil {
+		return err
+	}
+	fakeSockets[socket.Path] = socket
+	return nil
+}
+
+/*
+RemoveSocket removes the record of a UDS socket.
+In this fake handler sockets are held in memory.
+*/
+func (r *fakeHandler) RemoveSocket(path string) error {
+	if err := r.fail("RemoveSocket"); err != nil {
+		return err
+	}
+	delete(fakeSockets, path)
+	return nil
+}
+
+/*
+GetSockets returns the records of all UDS sockets.
+In this fake handler sockets are held in memory.
+*/
+func (r *fakeHandler) GetSockets() (map[string]*Socket, error) {
+	if err := r.fail("GetSockets"); err != nil {
+		return nil, err
+	}
+	sockets := make(map[string]*Socket)
+	for path, socket := range fakeSockets {
+		sockets[path] = socket
+	}
+	return sockets, nil
+}
+
+/*
+SubscribeLinkEvents subscribes to link state changes of the named netdevs.
+In this fake handler events are only generated by SendLinkEvent.
+*/
+func (r *fakeHandler) SubscribeLinkEvents(interfaceNames ...string) (*LinkSubscription, error) {
+	if err := r.fail("SubscribeLinkEvents"); err != nil {
+		return nil, err
+	}
+	return fakeLinkEvents.subscribe(interfaceNames)
+}
+
+/*
+SendLinkEvent delivers a link event to the subscribers of the fake handler.
+*/
+func (r *fakeHandler) SendLinkEvent(event LinkEvent) {
+	fakeLinkEvents.publish(event)
+}
+
+/*
+GetActiveBackupBond returns the active-backup bond that a netdev is enslaved to.
+In this fake handler netdevs are only enslaved by SetBond.
+*/
+func (r *fakeHandler) GetActiveBackupBond(interfaceName string) (*Bond, error) {
+	if err := r.fail("GetActiveBackupBond"); err != nil {
+		return nil, err
+	}
+	if bond, ok := fakeBonds[interfaceName]; ok && bond.Mode == bondModeActiveBackup {
+		return bond, nil
+	}
+	return nil, nil
+}
+
+/*
+ConfigureVirtio prepares a virtio-net device for AF_XDP.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) ConfigureVirtio(interfaceName string) error {
+	if err := r.fail("ConfigureVirtio"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+CreateTap creates a tap netdev and returns it as a device in tap mode.
+In this fake handler no netdev is created.
+*/
+func (r *fakeHandler) CreateTap(name string) (*Device, error) {
+	if err := r.fail("CreateTap"); err != nil {
+		return nil, err
+	}
+	return newTapDevice(name, "1234", r)
+}
+
+/*
+DeleteTap deletes a tap netdev.
+In this fake handler it does nothing.
+*/
+func (r *fakeHandler) DeleteTap(name string) error {
+	if err := r.fail("DeleteTap"); err != nil {
+		return err
+	}
+	return nil
+}
+
+/*
+GetHostNetworkManagers returns the host network managers that are managing a netdev.
+In this fake handler netdevs are only managed if set by SetHostNetworkManagers.
+*/
+func (r *fakeHandler) GetHostNetworkManagers(interfaceName string) ([]string, error) {
+	if err := r.fail("GetHostNetworkManagers"); err != nil {
+		return nil, err
+	}
+	return fakeManagers[interfaceName], nil
+}
+
+/*
+SetUnmanaged marks a netdev as unmanaged by the given host network managers.
+*/
+func (r *fakeHandler) SetUnmanaged(interfaceName string, managers []string) error {
+	if err := r.fail("SetUnmanaged"); err != nil {
+		return err
+	}
+	delete(fakeManagers, interfaceName)
+	return nil
+}
+
+/*
+SetHostNetworkManagers sets the host network managers of a fake netdev. No managers marks it unmanaged.
+*/
+func (r *fakeHandler) SetHostNetworkManagers(interfaceName string, managers []string) {
+	if len(managers) == 0 {
+		delete(fakeManagers, interfaceName)
+		return
+	}
+	fakeManagers[interfaceName] = managers
+}
+
+/*
+JournalBegin writes an entry to the journal ahead of a host networking change.
+In this fake handler the journal is held in memory.
+*/
+func (r *fakeHandler) JournalBegin(entry *JournalEntry) (int, error) {
+	if err := r.fail("JournalBegin"); err != nil {
+		return 0, err
+	}
+	fakeJournal.NextId++
+	entry.Id = fakeJournal.NextId
+	if entry.Started.IsZero() {
+		entry.Started = time.Now()
+	}
+	fakeJournal.Entries = append(fakeJournal.Entries, entry)
+	return entry.Id, nil
+}
+
+/*
+JournalEnd removes finished entries from the journal.
+*/
+func (r *fakeHandler) JournalEnd(ids ...int) error {
+	if err := r.fail("JournalEnd"); err != nil {
+		return err
+	}
+	fakeJournal.Entries = removeJournalEntries(fakeJournal.Entries, ids)
+	return nil
+}
+
+/*
+GetJournal returns the unfinished journal entries, most recent first.
+*/
+func (r *fakeHandler) GetJournal() ([]*JournalEntry, error) {
+	if err := r.fail("GetJournal"); err != nil {
+		return nil, err
+	}
+	var entries []*JournalEntry
+	for i := len(fakeJournal.Entries) - 1; i >= 0; i-- {
+		entries = append(entries, fakeJournal.Entries[i])
+	}
+	return entries, nil
+}
+
+/*
+MoveToHostNs moves a device from a network namespace back to the host network namespace.
+In this fake handler the device is no longer held to be in any network namespace.
+*/
+func (r *fakeHandler) MoveToHostNs(interfaceName string, netnsPath string) error {
+	if err := r.fail("MoveToHostNs"); err != nil {
+		return err
+	}
+	delete(fakeNetns, interfaceName)
+	return nil
+}
+
+/*
+CheckNetns returns an error if the network namespace at netnsPath cannot be opened.
+In this fake handler every network namespace but an empty path exists.
+*/
+func (r *fakeHandler) CheckNetns(netnsPath string) error {
+	if err := r.fail("CheckNetns"); err != nil {
+		return err
+	}
+	if netnsPath == "" {
+		return fmt.Errorf("network namespace path is empty")
+	}
+	return nil
+}
+
+/*
+MoveToNetns moves a device from the host network namespace into a network namespace.
+In this fake handler the device must be a fake netdev in the host network namespace, and the
+network namespace it is moved to is held in memory, returned by GetNetns.
+*/
+func (r *fakeHandler) MoveToNetns(interfaceName string, netnsPath string) error {
+	if err := r.fail("MoveToNetns"); err != nil {
+		return err
+	}
+	if _, ok := interfaceList[interfaceName]; !ok {
+		return fmt.Errorf("failed to find device %q: Link not found", interfaceName)
+	}
+	if fakeNetns[interfaceName] != "" {
+		return fmt.Errorf("failed to find device %q: Link not found", interfaceName)
+	}
+	if err := r.CheckNetns(netnsPath); err != nil {
+		return err
+	}
+	fakeNetns[interfaceName] = netnsPath
+	return nil
+}
+
+/*
+MoveFromNetns moves a device from a network namespace back to the host network namespace.
+In this fake handler the device must have been moved to the network namespace by MoveToNetns.
+*/
+func (r *fakeHandler) MoveFromNetns(interfaceName string, netnsPath string) error {
+	if err := r.fail("MoveFromNetns"); err != nil {
+		return err
+	}
+	if netnsPath == "" || fakeNetns[interfaceName] != netnsPath {
+		return fmt.Errorf("failed to find device %q in netns %q: Link not found", interfaceName, netnsPath)
+	}
+	delete(fakeNetns, interfaceName)
+	return nil
+}
+
+/*
+GetNetns returns the network namespace a fake netdev has been moved to, empty if it is in the
+host network namespace.
+*/
+func (r *fakeHandler) GetNetns(interfaceName string) string {
+	return fakeNetns[interfaceName]
+}
+
+/*
+CreateKindNetwork creates the kind secondary network.
+In this fake handler no netdev is created, the network only exists from then on.
+*/
+func (r *fakeHandler) CreateKindNetwork(numVeths, offset int) error {
+	if err := r.fail("CreateKindNetwork"); err != nil {
+		return err
+	}
+	fakeKindNetwork = true
+	return nil
+}
+
+/*
+KindNetworkExists returns true if the kind secondary network exists.
+In this fake handler it exists once created by CreateKindNetwork.
+*/
+func (r *fakeHandler) KindNetworkExists() (bool, error) {
+	if err := r.fail("KindNetworkExists"); err != nil {
+		return false, err
+	}
+	return fakeKindNetwork, nil
+}
+
+/*
+SetIrqAffinity pins the IRQs of the queues of a netdev to a set of CPUs.
+In this fake handler it only validates the request.
+*/
+func (r *fakeHandler) SetIrqAffinity(interfaceName string, cpus []int) error {
+	if err := r.fail("SetIrqAffinity"); err != nil {
+		return err
+	}
+	if len(cpus) == 0 {
+		return fmt.Errorf("no CPUs to pin IRQs of device %s to", interfaceName)
+	}
+	return nil
+}
+
+/*
+GetQueueIrqs returns the IRQs of the queues of a netdev.
+In this fake handler devices have four queue IRQs.
+*/
+func (r *fakeHandler) GetQueueIrqs(interfaceName string) ([]int, error) {
+	if err := r.fail("GetQueueIrqs"); err != nil {
+		return nil, err
+	}
+	return []int{100, 101, 102, 103}, nil
+}
+
+/*
+SetBond enslaves the slaves of a fake bond. A bond with no slaves releases all slaves of the named bond.
+*/
+func (r *fakeHandler) SetBond(bond *Bond) {
+	for slave, b := range fakeBonds {
+		if b.Name == bond.Name {
+			delete(fakeBonds, slave)
+		}
+	}
+	for _, slave := range bond.Slaves {
+		fakeBonds[slave] = bond
+	}
+}
+
+/*
+GetMtu returns the MTU of a netdev.
+In this fake handler devices have the default ethernet MTU unless set by SetMtu.
+*/
+func (r *fakeHandler) GetMtu(interfaceName string) (int, error) {
+	if err := r.fail("GetMtu"); err != nil {
+		return 0, err
+	}
+	if mtu, ok := fakeMtus[interfaceName]; ok {
+		return mtu, nil
+	}
+	return 1500, nil
+}
+
+/*
+SetMtu sets the MTU of a netdev.
+In this fake handler the MTU is held in memory.
+*/
+func (r *fakeHandler) SetMtu(interfaceName string, mtu int) error {
+	if err := r.fail("SetMtu"); err != nil {
+		return err
+	}
+	fakeMtus[interfaceName] = mtu
 	return nil
 }
 
@@ -205,6 +957,9 @@ It creates and populates a new instance of the device map with the device file f
 and returns the device object.This function uses fake handler, its purpose is for unit-testing
 */
 func (r *fakeHandler) GetDeviceFromFile(deviceName string, filepath string) (*Device, error) {
+	if err := r.fail("GetDeviceFromFile"); err != nil {
+		return nil, err
+	}
 	return &Device{name: "fakeDevice", netHandler: r}, nil
 }
 
@@ -213,17 +968,29 @@ WriteDeviceFile creates and writes the device map fields to file, enabling the
 CNI to read device information.This function uses fake handler, its purpose is for unit-testing
 */
 func (r *fakeHandler) WriteDeviceFile(device *Device, filepath string) error {
+	if err := r.fail("WriteDeviceFile"); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (r *fakeHandler) GetDeviceByMAC(mac string) (string, error) {
+	if err := r.fail("GetDeviceByMAC"); err != nil {
+		return "", err
+	}
 	return "", nil
 }
 
 func (r *fakeHandler) GetDeviceByPCI(pci string) (string, error) {
+	if err := r.fail("GetDeviceByPCI"); err != nil {
+		return "", err
+	}
 	return "", nil
 }
 
 func (r *fakeHandler) IsPhysicalPort(name string) (bool, error) {
+	if err := r.fail("IsPhysicalPort"); err != nil {
+		return false, err
+	}
 	return false, nil
 }
@@ -0,0 +1,134 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+var promiscuousFile = constants.DeviceFile.Directory + constants.Promiscuous.FileName
+
+/*
+promiscuousRecord records the allocation that enabled promiscuous mode on a
+device and whether the device was already promiscuous before that allocation.
+*/
+type promiscuousRecord struct {
+	Owner    string
+	Previous bool
+}
+
+/*
+promiscuousState is the on-disk record of promiscuous mode changes, keyed on device name.
+*/
+type promiscuousState map[string]*promiscuousRecord
+
+/*
+SetPromiscuous enables promiscuous mode on a netdev on behalf of owner.
+The previous promiscuous state of the device is recorded so that it can
+be restored by RestorePromiscuous when the allocation is released.
+*/
+func (r *handler) SetPromiscuous(interfaceName string, owner string) error {
+	if owner == "" {
+		return fmt.Errorf("promiscuous mode requires an owner")
+	}
+
+	link, err := netlink.LinkByName(interfaceName)
+	if err != nil {
+		logging.Errorf("Error getting link %s: %v", interfaceName, err)
+		return err
+	}
+
+	return withPromiscuousState(func(state promiscuousState) error {
+		previous := link.Attrs().Promisc != 0
+		if record, ok := state[interfaceName]; ok {
+			if record.Owner != owner {
+				logging.Warningf("Device %s has a stale promiscuous record from %s, replacing", interfaceName, record.Owner)
+			}
+			previous = record.Previous
+		}
+
+		if err := netlink.SetPromiscOn(link); err != nil {
+			logging.Errorf("Error enabling promiscuous mode on device %s: %v", interfaceName, err)
+			return err
+		}
+
+		state[interfaceName] = &promiscuousRecord{Owner: owner, Previous: previous}
+		logging.Debugf("Promiscuous mode enabled on device %s for %s, previous state: %t", interfaceName, owner, previous)
+
+		return nil
+	})
+}
+
+/*
+RestorePromiscuous restores the promiscuous state a netdev had before SetPromiscuous
+was called by owner. Devices that owner did not set promiscuous are left untouched.
+*/
+func (r *handler) RestorePromiscuous(interfaceName string, owner string) error {
+	return withPromiscuousState(func(state promiscuousState) error {
+		record, ok := state[interfaceName]
+		if !ok || record.Owner != owner {
+			return nil
+		}
+
+		if !record.Previous {
+			link, err := netlink.LinkByName(interfaceName)
+			if err != nil {
+				logging.Errorf("Error getting link %s: %v", interfaceName, err)
+				return err
+			}
+
+			if err := netlink.SetPromiscOff(link); err != nil {
+				logging.Errorf("Error disabling promiscuous mode on device %s: %v", interfaceName, err)
+				return err
+			}
+		}
+
+		delete(state, interfaceName)
+		logging.Debugf("Promiscuous state of device %s restored to %t", interfaceName, record.Previous)
+
+		return nil
+	})
+}
+
+/*
+withPromiscuousState loads the promiscuous state file under an exclusive lock, runs fn
+and writes the possibly modified state back.
+*/
+func withPromiscuousState(fn func(state promiscuousState) error) error {
+	return withStateFile(promiscuousFile, constants.Promiscuous.FilePermissions, func(raw []byte) ([]byte, error) {
+		state := make(promiscuousState)
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &state); err != nil {
+				logging.Warningf("Promiscuous state file is corrupt, previous states are lost: %v", err)
+				state = make(promiscuousState)
+			}
+		}
+
+		fnErr := fn(state)
+
+		jsonStr, err := json.MarshalIndent(state, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonStr, fnErr
+	})
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+var socketsFile = constants.DeviceFile.Directory + constants.Sockets.FileName
+
+/*
+Socket records a UDS socket served by the device plugin. Path is the path of the
+socket on the host, Pool the pool it was allocated from and Devices the devices
+whose XSK map file descriptors it serves.
+*/
+type Socket struct {
+	Path    string
+	Pool    string
+	Devices []string
+	Created time.Time
+}
+
+/*
+socketState is the on-disk registry of sockets, keyed on socket path.
+*/
+type socketState map[string]*Socket
+
+/*
+RecordSocket records that a UDS socket is being served.
+An existing record for the socket path is replaced.
+*/
+func (r *handler) RecordSocket(socket *Socket) error {
+	if socket == nil || socket.Path == "" {
+		return fmt.Errorf("socket must have a path")
+	}
+	if socket.Created.IsZero() {
+		socket.Created = time.Now()
+	}
+
+	return withSocketState(func(state socketState) error {
+		state[socket.Path] = socket
+		return nil
+	})
+}
+
+/*
+RemoveSocket removes the record of a UDS socket.
+*/
+func (r *handler) RemoveSocket(path string) error {
+	return withSocketState(func(state socketState) error {
+		delete(state, path)
+		return nil
+	})
+}
+
+/*
+GetSockets returns the records of all UDS sockets, keyed on socket path.
+*/
+func (r *handler) GetSockets() (map[string]*Socket, error) {
+	sockets := make(map[string]*Socket)
+
+	err := withSocketState(func(state socketState) error {
+		for path, socket := range state {
+			sockets[path] = socket
+		}
+		return nil
+	})
+
+	return sockets, err
+}
+
+/*
+withSocketState loads the socket registry under an exclusive lock, runs fn
+and writes the possibly modified state back.
+*/
+func withSocketState(fn func(state socketState) error) error {
+	return withStateFile(socketsFile, constants.Sockets.FilePermissions, func(raw []byte) ([]byte, error) {
+		state := make(socketState)
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &state); err != nil {
+				logging.Warningf("Socket registry is corrupt, socket records are lost: %v", err)
+				state = make(socketState)
+			}
+		}
+
+		fnErr := fn(state)
+
+		jsonStr, err := json.MarshalIndent(state, "", " ")
+		if err != nil {
+			return nil, err
+		}
+
+		return jsonStr, fnErr
+	})
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sockets")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	defaultSocketsFile := socketsFile
+	socketsFile = filepath.Join(dir, "sockets.json")
+	defer func() { socketsFile = defaultSocketsFile }()
+
+	r := &handler{}
+
+	require.NoError(t, r.RecordSocket(&Socket{Path: "/tmp/afxdp_dp/pod1/afxdp.sock", Pool: "pool1", Devices: []string{"ens1"}}), "Unexpected error")
+	require.NoError(t, r.RecordSocket(&Socket{Path: "/tmp/afxdp_dp/pod2/afxdp.sock", Pool: "pool1", Devices: []string{"ens2", "ens3"}}), "Unexpected error")
+	assert.Error(t, r.RecordSocket(&Socket{Pool: "pool1"}), "Socket without a path should be refused")
+
+	sockets, err := r.GetSockets()
+	require.NoError(t, err, "Unexpected error")
+	require.Len(t, sockets, 2, "Unexpected number of sockets")
+	assert.Equal(t, []string{"ens2", "ens3"}, sockets["/tmp/afxdp_dp/pod2/afxdp.sock"].Devices, "Devices not recorded")
+	assert.False(t, sockets["/tmp/afxdp_dp/pod1/afxdp.sock"].Created.IsZero(), "Creation time not recorded")
+
+	require.NoError(t, r.RemoveSocket("/tmp/afxdp_dp/pod1/afxdp.sock"), "Unexpected error")
+	require.NoError(t, r.RemoveSocket("/tmp/afxdp_dp/pod3/afxdp.sock"), "Removing an unknown socket should not fail")
+
+	sockets, err = r.GetSockets()
+	require.NoError(t, err, "Unexpected error")
+	require.Len(t, sockets, 1, "Unexpected number of sockets")
+	assert.Contains(t, sockets, "/tmp/afxdp_dp/pod2/afxdp.sock", "Wrong socket removed")
+}
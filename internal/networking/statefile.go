@@ -0,0 +1,172 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	stateStagedSuffix = ".staged" // suffix of the copy of new state file contents, kept until written in place
+	stateTempSuffix   = ".tmp-"   // prefix of the random suffix of a staged copy being written
+)
+
+/*
+withStateFile opens, creating if needed, a host state file and holds an exclusive lock on it
+while fn runs. fn is passed the current file contents and returns the new contents. If fn returns
+nil contents the file is left unchanged. The lock is held on the state file itself, as by earlier
+releases, and serialises concurrent CNI invocations and the device plugin sharing the file, even
+across an upgrade. New contents are written in place, so the locked file is never replaced, and
+staged beforehand so a crash part way through is recovered from, see writeStateFile.
+*/
+func withStateFile(path string, permissions int, fn func(raw []byte) ([]byte, error)) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logging.Errorf("Error creating state directory for %s: %v", path, err)
+		return err
+	}
+
+	fp, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, os.FileMode(permissions))
+	if err != nil {
+		logging.Errorf("Error opening state file %s: %v", path, err)
+		return err
+	}
+	defer fp.Close()
+
+	if err := syscall.Flock(int(fp.Fd()), syscall.LOCK_EX); err != nil {
+		logging.Errorf("Error locking state file %s: %v", path, err)
+		return err
+	}
+	defer syscall.Flock(int(fp.Fd()), syscall.LOCK_UN) //nolint:errcheck
+
+	if err := recoverStateFile(fp, path); err != nil {
+		logging.Errorf("Error recovering state file %s: %v", path, err)
+		return err
+	}
+
+	raw, err := ioutil.ReadAll(fp)
+	if err != nil {
+		logging.Errorf("Error reading state file %s: %v", path, err)
+		return err
+	}
+
+	contents, fnErr := fn(raw)
+	if contents == nil {
+		return fnErr
+	}
+
+	if err := writeStateFile(fp, path, contents, permissions); err != nil {
+		logging.Errorf("Error writing state file %s: %v", path, err)
+		return err
+	}
+
+	return fnErr
+}
+
+/*
+writeStateFile writes contents to the open state file at path. The contents are first staged, in
+a copy alongside the state file that is synced and renamed into place, and then written over the
+state file in place and synced, after which the staged copy is removed. A crash while the state
+file is written leaves the staged copy, which recoverStateFile writes again, so the state file
+ends up with either the old or the new contents and never partly written ones.
+*/
+func writeStateFile(fp *os.File, path string, contents []byte, permissions int) error {
+	if err := stageStateFile(path, contents, permissions); err != nil {
+		return err
+	}
+	if err := overwriteStateFile(fp, contents); err != nil {
+		return err
+	}
+	return os.Remove(path + stateStagedSuffix)
+}
+
+/*
+recoverStateFile completes a write of the state file at path interrupted by a crash, if a staged
+copy of the new contents was left behind. It is called with the state file locked.
+*/
+func recoverStateFile(fp *os.File, path string) error {
+	staged, err := ioutil.ReadFile(path + stateStagedSuffix)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logging.Warningf("State file %s was not fully written, completing the interrupted write", path)
+	if err := overwriteStateFile(fp, staged); err != nil {
+		return err
+	}
+	return os.Remove(path + stateStagedSuffix)
+}
+
+/*
+stageStateFile writes contents to the staged copy of the state file at path. The copy is written
+to a temporary file and synced before it is renamed into place, and the directory is then synced,
+so a staged copy only exists once complete.
+*/
+func stageStateFile(path string, contents []byte, permissions int) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+stateTempSuffix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(os.FileMode(permissions)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path+stateStagedSuffix); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+
+	return dir.Sync()
+}
+
+/*
+overwriteStateFile replaces the contents of the open state file in place and syncs it to disk.
+*/
+func overwriteStateFile(fp *os.File, contents []byte) error {
+	if err := fp.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := fp.WriteAt(contents, 0); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
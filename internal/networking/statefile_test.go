@@ -0,0 +1,139 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	increment := func(raw []byte) ([]byte, error) {
+		count := 0
+		if len(raw) > 0 {
+			var err error
+			if count, err = strconv.Atoi(string(raw)); err != nil {
+				return nil, err
+			}
+		}
+		return []byte(strconv.Itoa(count + 1)), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, withStateFile(path, 0600, increment), "Unexpected error")
+		}()
+	}
+	wg.Wait()
+
+	raw, err := ioutil.ReadFile(path)
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "20", string(raw), "Concurrent updates should be serialised")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Unexpected permissions")
+
+	fnErr := errors.New("fn error")
+	err = withStateFile(path, 0600, func(raw []byte) ([]byte, error) { return nil, fnErr })
+	assert.Equal(t, fnErr, err, "Error of fn should be returned")
+	raw, err = ioutil.ReadFile(path)
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, "20", string(raw), "File should be unchanged without new contents")
+
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err, "Unexpected error")
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	assert.ElementsMatch(t, []string{"state.json"}, names, "Temporary files should not be left behind")
+}
+
+func TestWithStateFileLocksStateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte("old"), 0600), "Unexpected error")
+
+	// earlier releases lock the state file itself, an update must wait for them
+	fp, err := os.OpenFile(path, os.O_RDWR, 0600)
+	require.NoError(t, err, "Unexpected error")
+	defer fp.Close()
+	require.NoError(t, syscall.Flock(int(fp.Fd()), syscall.LOCK_EX), "Unexpected error")
+
+	done := make(chan error)
+	go func() {
+		done <- withStateFile(path, 0600, func(raw []byte) ([]byte, error) { return []byte("new"), nil })
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Update should wait for the lock on the state file")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, syscall.Flock(int(fp.Fd()), syscall.LOCK_UN), "Unexpected error")
+	assert.NoError(t, <-done, "Unexpected error")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err, "Unexpected error")
+	before, err := fp.Stat()
+	require.NoError(t, err, "Unexpected error")
+	assert.True(t, os.SameFile(before, info), "State file should be written in place, keeping the locked inode")
+}
+
+func TestWithStateFileRecovers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statefile")
+	require.NoError(t, err, "Unexpected error")
+	defer os.RemoveAll(dir)
+
+	// a crash while writing the state file in place leaves it partly written, along with the staged copy
+	path := filepath.Join(dir, "state.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{"dev`), 0600), "Unexpected error")
+	require.NoError(t, ioutil.WriteFile(path+stateStagedSuffix, []byte(`{"dev1":{}}`), 0600), "Unexpected error")
+
+	var raw []byte
+	err = withStateFile(path, 0600, func(r []byte) ([]byte, error) {
+		raw = r
+		return nil, nil
+	})
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, `{"dev1":{}}`, string(raw), "Interrupted write should be completed from the staged copy")
+
+	_, err = os.Stat(path + stateStagedSuffix)
+	assert.True(t, os.IsNotExist(err), "Staged copy should be removed once recovered")
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"fmt"
+
+	logging "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+const tapDriver = "tun"
+
+/*
+CreateTap creates a persistent tap netdev and returns it as a device in tap mode.
+Tap devices need no NIC hardware, allowing the full allocation and UDS handshake flow
+to be exercised on any host. If a tap of the same name already exists, such as one
+left behind by a previous run of the device plugin, it is reused.
+*/
+func (r *handler) CreateTap(name string) (*Device, error) {
+	exists, err := r.NetDevExists(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		link, err := netlink.LinkByName(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := link.(*netlink.Tuntap); !ok {
+			return nil, fmt.Errorf("device %s already exists and is not a tap device", name)
+		}
+		logging.Infof("Tap device %s already exists, reusing", name)
+	} else {
+		tap := &netlink.Tuntap{
+			LinkAttrs: netlink.LinkAttrs{Name: name},
+			Mode:      netlink.TUNTAP_MODE_TAP,
+			Flags:     netlink.TUNTAP_DEFAULTS,
+		}
+		if err := netlink.LinkAdd(tap); err != nil {
+			logging.Errorf("Error creating tap device %s: %v", name, err)
+			return nil, err
+		}
+		logging.Infof("Tap device %s created", name)
+	}
+
+	macAddress, err := r.GetMacAddress(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newTapDevice(name, macAddress, r)
+}
+
+/*
+DeleteTap deletes a tap netdev created by CreateTap. Taps that no longer exist in the
+host network namespace, including taps currently attached to pods, are ignored.
+*/
+func (r *handler) DeleteTap(name string) error {
+	exists, err := r.NetDevExists(name)
+	if err != nil || !exists {
+		return err
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := link.(*netlink.Tuntap); !ok {
+		return fmt.Errorf("device %s is not a tap device", name)
+	}
+
+	return netlink.LinkDel(link)
+}
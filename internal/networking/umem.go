@@ -0,0 +1,54 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+MaxMtu returns the largest MTU whose packets fit in a single UMEM frame of frameSize bytes.
+Each frame reserves XDP headroom and must also hold the L2 header, which is not counted in the MTU.
+*/
+func MaxMtu(frameSize int) int {
+	return frameSize - constants.Afxdp.FrameHeadroom - constants.Afxdp.FrameL2Overhead
+}
+
+/*
+ValidateMtu checks that packets of a device with the given MTU fit in a single UMEM frame.
+Without multi-buffer support a packet cannot span frames, so larger packets would be dropped
+or truncated.
+*/
+func ValidateMtu(mtu int, frameSize int) error {
+	valid := false
+	for _, size := range constants.Afxdp.FrameSizes {
+		if frameSize == size {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("UMEM frame size %d is not supported, must be one of %v", frameSize, constants.Afxdp.FrameSizes)
+	}
+
+	if max := MaxMtu(frameSize); mtu > max {
+		return fmt.Errorf("MTU %d exceeds the maximum of %d supported by a %d byte UMEM frame", mtu, max, frameSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMtu(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mtu       int
+		frameSize int
+		expErr    string
+	}{
+		{
+			name:      "standard MTU in 4K frame",
+			mtu:       1500,
+			frameSize: 4096,
+		},
+		{
+			name:      "standard MTU in 2K frame",
+			mtu:       1500,
+			frameSize: 2048,
+		},
+		{
+			name:      "maximum MTU in 4K frame",
+			mtu:       3814,
+			frameSize: 4096,
+		},
+		{
+			name:      "jumbo MTU in 4K frame",
+			mtu:       9000,
+			frameSize: 4096,
+			expErr:    "MTU 9000 exceeds the maximum of 3814 supported by a 4096 byte UMEM frame",
+		},
+		{
+			name:      "MTU just above 2K frame limit",
+			mtu:       1767,
+			frameSize: 2048,
+			expErr:    "MTU 1767 exceeds the maximum of 1766 supported by a 2048 byte UMEM frame",
+		},
+		{
+			name:      "frame size above 4K",
+			mtu:       1500,
+			frameSize: 8192,
+			expErr:    "UMEM frame size 8192 is not supported",
+		},
+		{
+			name:      "frame size not a power of two",
+			mtu:       1500,
+			frameSize: 3000,
+			expErr:    "UMEM frame size 3000 is not supported",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateMtu(tc.mtu, tc.frameSize)
+			if tc.expErr != "" {
+				require.Error(t, err, "Error was expected")
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+		})
+	}
+}
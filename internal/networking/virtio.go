@@ -0,0 +1,98 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+ConfigureVirtio prepares a virtio-net device for AF_XDP.
+virtio-net takes a transmit queue pair per CPU from the device for XDP, so the combined channels
+are reduced to leave room for them, avoiding the slower shared transmit queues the driver otherwise
+falls back to. Devices whose hypervisor implements receive offloads that the guest cannot disable
+are refused, as the driver will not attach an XDP program to them.
+*/
+func (r *handler) ConfigureVirtio(interfaceName string) error {
+	stdout, err := exec.Command(ethtool, "--show-features", interfaceName).CombinedOutput()
+	if err != nil {
+		logging.Errorf("Error getting features of device %s: %s", interfaceName, string(stdout))
+		return err
+	}
+	if fixed := fixedOnFeatures(string(stdout), virtioGuestOffloads); len(fixed) > 0 {
+		return fmt.Errorf("device %s has guest offloads %v enabled by the hypervisor, XDP requires them to be disabled or controllable by the guest (ctrl_guest_offloads)", interfaceName, fixed)
+	}
+
+	channels, err := r.GetChannels(interfaceName)
+	if err != nil {
+		return err
+	}
+
+	queues := virtioXdpQueues(channels.MaxCombined, runtime.NumCPU())
+	if channels.Combined <= queues {
+		return nil
+	}
+
+	logging.Infof("Reducing combined channels of virtio device %s from %d to %d, leaving queues for XDP transmit", interfaceName, channels.Combined, queues)
+	return r.SetChannels(interfaceName, &Channels{Combined: queues})
+}
+
+/*
+virtioGuestOffloads are the receive offloads that virtio-net must disable before attaching XDP.
+*/
+var virtioGuestOffloads = []string{"rx-gro-hw"}
+
+/*
+virtioXdpQueues returns the number of queue pairs of a virtio-net device that can be used for
+receive while leaving a dedicated XDP transmit queue pair per CPU. If the device does not have
+enough queue pairs for that, all queue pairs remain in use and transmit queues are shared.
+*/
+func virtioXdpQueues(maxQueuePairs int, cpus int) int {
+	if maxQueuePairs-cpus < 1 {
+		return maxQueuePairs
+	}
+	return maxQueuePairs - cpus
+}
+
+/*
+fixedOnFeatures parses the output of ethtool --show-features and returns which of the named
+features are on and fixed, meaning they cannot be turned off.
+*/
+func fixedOnFeatures(output string, names []string) []string {
+	var fixed []string
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimSpace(fields[0])
+		for _, n := range names {
+			if name == n && strings.TrimSpace(fields[1]) == "on [fixed]" {
+				fixed = append(fixed, name)
+			}
+		}
+	}
+
+	return fixed
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtioXdpQueues(t *testing.T) {
+	testCases := []struct {
+		name      string
+		maxQueues int
+		cpus      int
+		expQueues int
+	}{
+		{name: "enough queue pairs", maxQueues: 8, cpus: 4, expQueues: 4},
+		{name: "one spare queue pair", maxQueues: 5, cpus: 4, expQueues: 1},
+		{name: "queue pairs equal to CPUs", maxQueues: 4, cpus: 4, expQueues: 4},
+		{name: "single queue pair", maxQueues: 1, cpus: 2, expQueues: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expQueues, virtioXdpQueues(tc.maxQueues, tc.cpus), "Queue count does not match")
+		})
+	}
+}
+
+func TestFixedOnFeatures(t *testing.T) {
+	testCases := []struct {
+		name     string
+		output   string
+		expFixed []string
+	}{
+		{
+			name: "guest offloads fixed on",
+			output: `Features for eth0:
+rx-checksumming: on [fixed]
+generic-receive-offload: on
+rx-gro-hw: on [fixed]
+`,
+			expFixed: []string{"rx-gro-hw"},
+		},
+		{
+			name: "guest offloads controllable",
+			output: `Features for eth0:
+rx-checksumming: on [fixed]
+rx-gro-hw: on
+`,
+		},
+		{
+			name: "guest offloads fixed off",
+			output: `Features for eth0:
+rx-gro-hw: off [fixed]
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expFixed, fixedOnFeatures(tc.output, virtioGuestOffloads), "Fixed features do not match")
+		})
+	}
+}
@@ -0,0 +1,170 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+
+	"github.com/vishvananda/netlink/nl"
+)
+
+/*
+xdp_diag constants, see linux/xdp_diag.h. AF_XDP and NETLINK_SOCK_DIAG are not defined by the
+syscall package, NETLINK_SOCK_DIAG shares its protocol number with NETLINK_INET_DIAG.
+*/
+const (
+	afXdp            = 44
+	netlinkSockDiag  = syscall.NETLINK_INET_DIAG
+	sockDiagByFamily = 20
+
+	xdpShowInfo  = 1 << 0
+	xdpShowStats = 1 << 4
+
+	xdpDiagInfo  = 1
+	xdpDiagStats = 9
+
+	xdpDiagReqLen   = 20
+	xdpDiagMsgLen   = 16
+	xdpDiagInfoLen  = 8
+	xdpDiagStatsLen = 48
+)
+
+/*
+ErrXskStatsUnsupported is returned when the kernel does not report AF_XDP socket statistics,
+added to xdp_diag in Linux 5.9.
+*/
+var ErrXskStatsUnsupported = errors.New("kernel does not report AF_XDP socket statistics")
+
+/*
+XskStats holds the counters of the AF_XDP sockets bound to a single queue of a netdev, as
+reported by the kernel through xdp_diag. Sockets sharing a queue are summed.
+*/
+type XskStats struct {
+	Queue         int
+	RxDropped     uint64 // packets dropped by the kernel for reasons other than invalid descriptors or full rings
+	RxInvalid     uint64 // rx packets dropped as the fill ring held an invalid descriptor
+	RxRingFull    uint64 // rx packets dropped as the rx ring was full, the application is not consuming rx
+	FillRingEmpty uint64 // times the fill ring was empty, the application is not refilling buffers
+	TxInvalid     uint64 // invalid descriptors the application placed on the tx ring
+	TxRingEmpty   uint64 // times the tx ring was empty when the kernel tried to transmit
+}
+
+/*
+xdpDiagReq is the xdp_diag dump request of all AF_XDP sockets in the network namespace.
+*/
+type xdpDiagReq struct {
+	show uint32
+}
+
+func (r *xdpDiagReq) Len() int {
+	return xdpDiagReqLen
+}
+
+func (r *xdpDiagReq) Serialize() []byte {
+	b := make([]byte, xdpDiagReqLen)
+	b[0] = afXdp
+	nl.NativeEndian().PutUint32(b[8:12], r.show)
+	return b
+}
+
+/*
+GetXskStats returns the counters of the AF_XDP sockets bound to a netdev, per queue and sorted
+by queue. Sockets are only visible from the network namespace they were created in, so this must
+be called from within the namespace of the pod using the netdev.
+*/
+func (r *handler) GetXskStats(interfaceName string) ([]*XskStats, error) {
+	iface, err := net.InterfaceByName(interfaceName)
+	if err != nil {
+		return nil, fmt.Errorf("error getting index of device %s: %w", interfaceName, err)
+	}
+
+	req := nl.NewNetlinkRequest(sockDiagByFamily, syscall.NLM_F_DUMP)
+	req.AddData(&xdpDiagReq{show: xdpShowInfo | xdpShowStats})
+	msgs, err := req.Execute(netlinkSockDiag, sockDiagByFamily)
+	if err != nil {
+		return nil, fmt.Errorf("error dumping AF_XDP sockets: %w", err)
+	}
+
+	return parseXskStats(msgs, iface.Index)
+}
+
+/*
+parseXskStats parses xdp_diag messages into the per-queue counters of the sockets bound to the
+netdev of the given index. Messages of sockets bound elsewhere, or not bound, are ignored.
+*/
+func parseXskStats(msgs [][]byte, ifindex int) ([]*XskStats, error) {
+	native := nl.NativeEndian()
+	queues := make(map[int]*XskStats)
+
+	for _, msg := range msgs {
+		if len(msg) < xdpDiagMsgLen {
+			continue
+		}
+
+		var info, stats []byte
+		attrs := msg[xdpDiagMsgLen:]
+		for len(attrs) >= syscall.SizeofRtAttr {
+			attrLen := int(native.Uint16(attrs[0:2]))
+			attrType := native.Uint16(attrs[2:4])
+			if attrLen < syscall.SizeofRtAttr || attrLen > len(attrs) {
+				break
+			}
+			switch attrType {
+			case xdpDiagInfo:
+				info = attrs[syscall.SizeofRtAttr:attrLen]
+			case xdpDiagStats:
+				stats = attrs[syscall.SizeofRtAttr:attrLen]
+			}
+			next := (attrLen + syscall.NLMSG_ALIGNTO - 1) &^ (syscall.NLMSG_ALIGNTO - 1)
+			if next >= len(attrs) {
+				break
+			}
+			attrs = attrs[next:]
+		}
+
+		if len(info) < xdpDiagInfoLen || int(native.Uint32(info[0:4])) != ifindex {
+			continue
+		}
+		if len(stats) < xdpDiagStatsLen {
+			return nil, ErrXskStatsUnsupported
+		}
+
+		queue := int(native.Uint32(info[4:8]))
+		q, ok := queues[queue]
+		if !ok {
+			q = &XskStats{Queue: queue}
+			queues[queue] = q
+		}
+		q.RxDropped += native.Uint64(stats[0:8])
+		q.RxInvalid += native.Uint64(stats[8:16])
+		q.RxRingFull += native.Uint64(stats[16:24])
+		q.FillRingEmpty += native.Uint64(stats[24:32])
+		q.TxInvalid += native.Uint64(stats[32:40])
+		q.TxRingEmpty += native.Uint64(stats[40:48])
+	}
+
+	xskStats := make([]*XskStats, 0, len(queues))
+	for _, q := range queues {
+		xskStats = append(xskStats, q)
+	}
+	sort.Slice(xskStats, func(i, j int) bool { return xskStats[i].Queue < xskStats[j].Queue })
+
+	return xskStats, nil
+}
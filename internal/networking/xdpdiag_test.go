@@ -0,0 +1,122 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink/nl"
+)
+
+/*
+xdpDiagMsg builds an xdp_diag message of a socket bound to queue of ifindex, with the given
+statistics, or without statistics if none are given.
+*/
+func xdpDiagMsg(ifindex uint32, queue uint32, stats ...uint64) []byte {
+	native := nl.NativeEndian()
+	msg := make([]byte, xdpDiagMsgLen)
+	msg[0] = afXdp
+
+	attr := func(attrType uint16, data []byte) {
+		header := make([]byte, 4)
+		native.PutUint16(header[0:2], uint16(4+len(data)))
+		native.PutUint16(header[2:4], attrType)
+		msg = append(msg, header...)
+		msg = append(msg, data...)
+	}
+
+	info := make([]byte, xdpDiagInfoLen)
+	native.PutUint32(info[0:4], ifindex)
+	native.PutUint32(info[4:8], queue)
+	attr(xdpDiagInfo, info)
+
+	if len(stats) > 0 {
+		data := make([]byte, xdpDiagStatsLen)
+		for i, value := range stats {
+			native.PutUint64(data[i*8:], value)
+		}
+		attr(xdpDiagStats, data)
+	}
+
+	return msg
+}
+
+func TestParseXskStats(t *testing.T) {
+	testCases := []struct {
+		name     string
+		msgs     [][]byte
+		expStats []*XskStats
+		expErr   error
+	}{
+		{
+			name:     "no sockets",
+			expStats: []*XskStats{},
+		},
+		{
+			name: "sockets on two queues",
+			msgs: [][]byte{
+				xdpDiagMsg(7, 1, 1, 2, 3, 4, 5, 6),
+				xdpDiagMsg(7, 0, 10, 20, 30, 40, 50, 60),
+			},
+			expStats: []*XskStats{
+				{Queue: 0, RxDropped: 10, RxInvalid: 20, RxRingFull: 30, FillRingEmpty: 40, TxInvalid: 50, TxRingEmpty: 60},
+				{Queue: 1, RxDropped: 1, RxInvalid: 2, RxRingFull: 3, FillRingEmpty: 4, TxInvalid: 5, TxRingEmpty: 6},
+			},
+		},
+		{
+			name: "sockets sharing a queue are summed",
+			msgs: [][]byte{
+				xdpDiagMsg(7, 0, 1, 0, 5, 0, 0, 2),
+				xdpDiagMsg(7, 0, 1, 0, 5, 0, 0, 2),
+			},
+			expStats: []*XskStats{
+				{Queue: 0, RxDropped: 2, RxRingFull: 10, TxRingEmpty: 4},
+			},
+		},
+		{
+			name: "sockets of other devices are ignored",
+			msgs: [][]byte{
+				xdpDiagMsg(8, 0, 1, 1, 1, 1, 1, 1),
+				xdpDiagMsg(7, 3, 0, 0, 0, 9, 0, 0),
+				xdpDiagMsg(8, 0),
+			},
+			expStats: []*XskStats{
+				{Queue: 3, FillRingEmpty: 9},
+			},
+		},
+		{
+			name: "kernel without socket statistics",
+			msgs: [][]byte{
+				xdpDiagMsg(7, 0),
+			},
+			expErr: ErrXskStatsUnsupported,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, err := parseXskStats(tc.msgs, 7)
+			if tc.expErr != nil {
+				assert.Equal(t, tc.expErr, err, "Unexpected error")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expStats, stats, "Statistics do not match")
+		})
+	}
+}
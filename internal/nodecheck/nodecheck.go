@@ -0,0 +1,308 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nodecheck
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tools"
+)
+
+/*
+Result is the result of a single check of the node, with what was found on the node.
+*/
+type Result struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+/*
+Run checks the node can run the device plugin and returns the result of each check: the kernel
+version, AF_XDP support, the BPF filesystem, the capabilities and locked memory limit of the
+process, and the devices of each driver in drivers, a map of driver names to the minimum
+firmware version of their devices, empty if not set.
+*/
+func Run(host host.Handler, net networking.Handler, drivers map[string]string) []Result {
+	results := []Result{
+		checkKernel(host),
+		checkAfxdp(host),
+		checkBpffs(host),
+		checkCapabilities(host),
+		checkMemlock(host),
+	}
+
+	return append(results, checkDrivers(net, drivers)...)
+}
+
+/*
+Print prints a report of the results and returns true if all checks passed.
+*/
+func Print(w io.Writer, results []Result) bool {
+	passed := true
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+			passed = false
+		}
+		fmt.Fprintf(w, "[%s] %s: %s\n", status, result.Name, result.Detail)
+	}
+
+	if passed {
+		fmt.Fprintf(w, "Node is suitable for AF_XDP\n")
+	} else {
+		fmt.Fprintf(w, "Node is not suitable for AF_XDP\n")
+	}
+
+	return passed
+}
+
+func checkKernel(host host.Handler) Result {
+	result := Result{Name: "kernel"}
+
+	version, err := host.KernelVersion()
+	if err != nil {
+		result.Detail = fmt.Sprintf("error getting kernel version: %v", err)
+		return result
+	}
+
+	atLeast, err := kernelAtLeast(version, constants.Afxdp.MinumumKernel)
+	if err != nil {
+		result.Detail = fmt.Sprintf("error comparing kernel version %s: %v", version, err)
+		return result
+	}
+
+	result.Passed = atLeast
+	if atLeast {
+		result.Detail = fmt.Sprintf("version %s", version)
+	} else {
+		result.Detail = fmt.Sprintf("version %s is below the minimum %s", version, constants.Afxdp.MinumumKernel)
+	}
+
+	return result
+}
+
+func checkAfxdp(host host.Handler) Result {
+	result := Result{Name: "af_xdp"}
+
+	supported, err := host.HasAfxdp()
+	switch {
+	case err != nil:
+		result.Detail = fmt.Sprintf("error creating AF_XDP socket: %v", err)
+	case !supported:
+		result.Detail = "AF_XDP sockets are not supported by the kernel, CONFIG_XDP_SOCKETS is not set"
+	default:
+		result.Passed = true
+		result.Detail = "AF_XDP sockets are supported"
+	}
+
+	return result
+}
+
+func checkBpffs(host host.Handler) Result {
+	result := Result{Name: "bpffs"}
+
+	mounted, err := host.HasBpffs(constants.Afxdp.BpffsPath)
+	switch {
+	case err != nil:
+		result.Detail = fmt.Sprintf("error checking mounts: %v", err)
+	case !mounted:
+		result.Detail = fmt.Sprintf("no BPF filesystem mounted at %s", constants.Afxdp.BpffsPath)
+	default:
+		result.Passed = true
+		result.Detail = fmt.Sprintf("mounted at %s", constants.Afxdp.BpffsPath)
+	}
+
+	return result
+}
+
+/*
+checkCapabilities checks the process has the capabilities of the required features, see the
+privileges package. Optional features missing capabilities are reported as disabled.
+*/
+func checkCapabilities(host host.Handler) Result {
+	result := Result{Name: "capabilities"}
+
+	missing, err := privileges.Missing(host)
+	if err != nil {
+		result.Detail = err.Error()
+		return result
+	}
+
+	var required, disabled []string
+	for _, feature := range privileges.Features {
+		lacking, ok := missing[feature.Name]
+		switch {
+		case !ok:
+		case feature.Required:
+			required = append(required, fmt.Sprintf("missing %s for %s", strings.Join(lacking, ", "), feature.Name))
+		default:
+			disabled = append(disabled, fmt.Sprintf("%s disabled without %s", feature.Name, strings.Join(lacking, ", ")))
+		}
+	}
+
+	switch {
+	case len(required) > 0:
+		result.Detail = strings.Join(append(required, disabled...), ", ")
+	case len(disabled) > 0:
+		result.Passed = true
+		result.Detail = strings.Join(disabled, ", ")
+	default:
+		result.Passed = true
+		result.Detail = "all features enabled"
+	}
+
+	return result
+}
+
+/*
+checkMemlock checks the locked memory limit is high enough for BPF maps. Kernels from
+constants.Afxdp.MemcgKernel charge BPF memory to the memory cgroup instead, so any limit passes.
+*/
+func checkMemlock(host host.Handler) Result {
+	result := Result{Name: "memlock"}
+
+	limit, err := host.MemlockLimit()
+	if err != nil {
+		result.Detail = fmt.Sprintf("error getting locked memory limit: %v", err)
+		return result
+	}
+
+	if limit == math.MaxUint64 {
+		result.Passed = true
+		result.Detail = "unlimited"
+		return result
+	}
+
+	if limit >= constants.Afxdp.MemlockMin {
+		result.Passed = true
+		result.Detail = fmt.Sprintf("%d bytes", limit)
+		return result
+	}
+
+	version, err := host.KernelVersion()
+	if err == nil {
+		if memcg, err := kernelAtLeast(version, constants.Afxdp.MemcgKernel); err == nil && memcg {
+			result.Passed = true
+			result.Detail = fmt.Sprintf("%d bytes, BPF memory is charged to the memory cgroup", limit)
+			return result
+		}
+	}
+
+	result.Detail = fmt.Sprintf("%d bytes is below the minimum %d bytes, raise the limit with ulimit -l or LimitMEMLOCK", limit, constants.Afxdp.MemlockMin)
+	return result
+}
+
+/*
+checkDrivers checks each driver has devices on the node, with firmware at least the minimum
+version if one is set.
+*/
+func checkDrivers(net networking.Handler, drivers map[string]string) []Result {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 {
+		return nil
+	}
+
+	devices, err := net.GetHostDevices()
+	if err != nil {
+		results := make([]Result, len(names))
+		for i, name := range names {
+			results[i] = Result{Name: "driver " + name, Detail: fmt.Sprintf("error getting host devices: %v", err)}
+		}
+		return results
+	}
+
+	byDriver := make(map[string][]*networking.Device)
+	for _, device := range devices {
+		driver, err := device.Driver()
+		if err != nil {
+			continue
+		}
+		byDriver[driver] = append(byDriver[driver], device)
+	}
+
+	var results []Result
+	for _, name := range names {
+		results = append(results, checkDriver(name, drivers[name], byDriver[name]))
+	}
+
+	return results
+}
+
+func checkDriver(name string, minFirmware string, devices []*networking.Device) Result {
+	result := Result{Name: "driver " + name}
+
+	if len(devices) == 0 {
+		result.Detail = "no devices on the node use this driver"
+		return result
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Name() < devices[j].Name() })
+
+	result.Passed = true
+	details := make([]string, len(devices))
+	for i, device := range devices {
+		firmware, err := device.Firmware()
+		if err != nil {
+			result.Passed = false
+			details[i] = fmt.Sprintf("%s error getting firmware: %v", device.Name(), err)
+			continue
+		}
+		details[i] = fmt.Sprintf("%s firmware %s", device.Name(), firmware)
+		if minFirmware == "" {
+			continue
+		}
+
+		atLeast, err := networking.FirmwareAtLeast(firmware, minFirmware)
+		if err != nil || !atLeast {
+			result.Passed = false
+			details[i] += fmt.Sprintf(" is below the minimum %s", minFirmware)
+		}
+	}
+	result.Detail = strings.Join(details, ", ")
+
+	return result
+}
+
+/*
+kernelAtLeast returns true if a kernel version is equal to or newer than a minimum version.
+*/
+func kernelAtLeast(version string, minimum string) (bool, error) {
+	have, err := tools.KernelVersionInt(version)
+	if err != nil {
+		return false, err
+	}
+
+	want, err := tools.KernelVersionInt(minimum)
+	if err != nil {
+		return false, err
+	}
+
+	return have >= want, nil
+}
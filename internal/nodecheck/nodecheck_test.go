@@ -0,0 +1,99 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package nodecheck
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name      string
+		kernel    string
+		memlock   uint64
+		missing   []string
+		drivers   map[string]string
+		expPassed bool
+		expReport string
+	}{
+		{
+			name:      "suitable node",
+			kernel:    "5.4.0-89-generic",
+			memlock:   64 << 20,
+			drivers:   map[string]string{"i40e": "8.0"},
+			expPassed: true,
+			expReport: "[PASS] kernel: version 5.4.0-89-generic\n" +
+				"[PASS] af_xdp: AF_XDP sockets are supported\n" +
+				"[PASS] bpffs: mounted at /sys/fs/bpf\n" +
+				"[PASS] capabilities: all features enabled\n" +
+				"[PASS] memlock: 67108864 bytes\n" +
+				"[PASS] driver i40e: dev1 firmware 8.30 0x8000a4ae 1.2926.0, dev2 firmware 8.30 0x8000a4ae 1.2926.0\n" +
+				"Node is suitable for AF_XDP\n",
+		},
+		{
+			name:      "low memlock on memcg kernel",
+			kernel:    "5.15.0",
+			memlock:   64 << 10,
+			missing:   []string{"CAP_SYS_ADMIN"},
+			expPassed: true,
+			expReport: "[PASS] kernel: version 5.15.0\n" +
+				"[PASS] af_xdp: AF_XDP sockets are supported\n" +
+				"[PASS] bpffs: mounted at /sys/fs/bpf\n" +
+				"[PASS] capabilities: podNetns disabled without CAP_SYS_ADMIN\n" +
+				"[PASS] memlock: 65536 bytes, BPF memory is charged to the memory cgroup\n" +
+				"Node is suitable for AF_XDP\n",
+		},
+		{
+			name:      "unsuitable node",
+			kernel:    "4.15.0",
+			memlock:   64 << 10,
+			missing:   []string{"CAP_NET_ADMIN", "CAP_SYS_ADMIN"},
+			drivers:   map[string]string{"i40e": "9.0", "ice": ""},
+			expPassed: false,
+			expReport: "[FAIL] kernel: version 4.15.0 is below the minimum 4.18.0\n" +
+				"[PASS] af_xdp: AF_XDP sockets are supported\n" +
+				"[PASS] bpffs: mounted at /sys/fs/bpf\n" +
+				"[FAIL] capabilities: missing CAP_NET_ADMIN for devices, bpf disabled without CAP_NET_ADMIN, podNetns disabled without CAP_SYS_ADMIN\n" +
+				"[FAIL] memlock: 65536 bytes is below the minimum 16777216 bytes, raise the limit with ulimit -l or LimitMEMLOCK\n" +
+				"[FAIL] driver i40e: dev1 firmware 8.30 0x8000a4ae 1.2926.0 is below the minimum 9.0, dev2 firmware 8.30 0x8000a4ae 1.2926.0 is below the minimum 9.0\n" +
+				"[FAIL] driver ice: no devices on the node use this driver\n" +
+				"Node is not suitable for AF_XDP\n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeHost := host.NewFakeHandler()
+			fakeHost.SetKernalVersion(tc.kernel)
+			fakeHost.SetMemlockLimit(tc.memlock)
+			fakeHost.SetMissingCapabilities(tc.missing...)
+
+			fakeNet := networking.NewFakeHandler()
+			fakeNet.SetHostDevices(map[string][]string{"i40e": {"dev1", "dev2"}})
+
+			var report bytes.Buffer
+			passed := Print(&report, Run(fakeHost, fakeNet, tc.drivers))
+
+			assert.Equal(t, tc.expPassed, passed, "Unexpected result")
+			assert.Equal(t, tc.expReport, report.String(), "Unexpected report")
+		})
+	}
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prereq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Check is a prerequisite of the device plugin, something it must be able to do to serve pods.
+Guidance tells the user how to fix the node or the deployment if the check fails. Feature names
+the feature of the privileges package the check exercises, if any.
+*/
+type Check struct {
+	Name     string
+	Run      func() error
+	Guidance string
+	Feature  string
+}
+
+/*
+Checks returns the prerequisites of the device plugin: creating an AF_XDP socket, attaching and
+detaching an XDP program on a test tap device, and calling the kubelet pod resources api with
+podResources.
+*/
+func Checks(host host.Handler, net networking.Handler, bpf bpf.Handler, podResources func() error) []Check {
+	return []Check{
+		{
+			Name: "xsk socket",
+			Run: func() error {
+				supported, err := host.HasAfxdp()
+				if err != nil {
+					return err
+				}
+				if !supported {
+					return errors.New("AF_XDP sockets are not supported by the kernel")
+				}
+				return nil
+			},
+			Guidance: "The kernel must be built with CONFIG_XDP_SOCKETS. Run afxdp-dp --check-node on the node for details.",
+		},
+		{
+			Name: "xdp attach",
+			Run: func() error {
+				return attachXdp(net, bpf, constants.Tap.ProbeName)
+			},
+			Guidance: "The device plugin needs CAP_NET_ADMIN and CAP_BPF, or CAP_SYS_ADMIN on kernels before 5.8, a BPF filesystem mounted at " + constants.Afxdp.BpffsPath +
+				", a locked memory limit of at least 16 MiB on kernels before " + constants.Afxdp.MemcgKernel +
+				" and the tun module for the test device. Run afxdp-dp --check-node on the node for details.",
+			Feature: privileges.Bpf,
+		},
+		{
+			Name: "pod resources",
+			Run:  podResources,
+			Guidance: "Mount the directory of the kubelet pod resources socket, " + constants.PodResources.DefaultSocket +
+				" by default, into the device plugin container, or set podResourcesSocket for a kubelet with another root directory.",
+		},
+	}
+}
+
+/*
+Enabled returns the checks exercising no feature or a feature that is enabled, keyed on feature
+name as returned by privileges.Check.
+*/
+func Enabled(checks []Check, features map[string]bool) []Check {
+	var enabled []Check
+	for _, check := range checks {
+		if check.Feature == "" || features[check.Feature] {
+			enabled = append(enabled, check)
+		}
+	}
+
+	return enabled
+}
+
+/*
+Verify runs the checks in order, returning an error with the guidance of the first check that
+fails.
+*/
+func Verify(checks []Check) error {
+	for _, check := range checks {
+		logging.Debugf("Checking prerequisite: %s", check.Name)
+		if err := check.Run(); err != nil {
+			return fmt.Errorf("prerequisite %s failed: %v. %s", check.Name, err, check.Guidance)
+		}
+	}
+
+	return nil
+}
+
+/*
+attachXdp creates a tap device, attaches the XDP pass program to it and detaches it again,
+deleting the tap device whatever the outcome.
+*/
+func attachXdp(net networking.Handler, bpf bpf.Handler, name string) error {
+	if _, err := net.CreateTap(name); err != nil {
+		return fmt.Errorf("error creating test device %s: %v", name, err)
+	}
+	defer func() {
+		if err := net.DeleteTap(name); err != nil {
+			logging.Warningf("Error deleting test device %s: %v", name, err)
+		}
+	}()
+
+	if err := bpf.LoadAttachBpfXdpPass(name); err != nil {
+		return fmt.Errorf("error attaching XDP program to test device %s: %v", name, err)
+	}
+	if err := bpf.Cleanbpf(name); err != nil {
+		return fmt.Errorf("error detaching XDP program from test device %s: %v", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package prereq
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	podResErr := errors.New("dial unix /var/lib/kubelet/pod-resources/kubelet.sock: connect: no such file or directory")
+
+	testCases := []struct {
+		name         string
+		podResources func() error
+		expErr       string
+	}{
+		{
+			name:         "all prerequisites met",
+			podResources: func() error { return nil },
+		},
+		{
+			name:         "pod resources socket missing",
+			podResources: func() error { return podResErr },
+			expErr:       "prerequisite pod resources failed: " + podResErr.Error() + ". Mount the directory of the kubelet pod resources socket",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			checks := Checks(host.NewFakeHandler(), networking.NewFakeHandler(), bpf.NewFakeHandler(), tc.podResources)
+
+			err := Verify(checks)
+			if tc.expErr == "" {
+				assert.NoError(t, err, "Unexpected error")
+				return
+			}
+			if assert.Error(t, err, "Error was expected") {
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+			}
+		})
+	}
+}
+
+func TestVerifyStopsAtFirstFailure(t *testing.T) {
+	var ran []string
+	check := func(name string, err error) Check {
+		return Check{Name: name, Run: func() error {
+			ran = append(ran, name)
+			return err
+		}}
+	}
+
+	err := Verify([]Check{check("first", nil), check("second", errors.New("failed")), check("third", nil)})
+
+	assert.EqualError(t, err, "prerequisite second failed: failed. ", "Unexpected error")
+	assert.Equal(t, []string{"first", "second"}, ran, "Checks after the first failure should not run")
+}
+
+func TestEnabled(t *testing.T) {
+	checks := []Check{{Name: "always"}, {Name: "bpf", Feature: "bpf"}, {Name: "netns", Feature: "podNetns"}}
+
+	enabled := Enabled(checks, map[string]bool{"bpf": true, "podNetns": false})
+
+	names := make([]string, len(enabled))
+	for i, check := range enabled {
+		names[i] = check.Name
+	}
+	assert.Equal(t, []string{"always", "bpf"}, names, "Checks of disabled features should be left out")
+}
@@ -0,0 +1,198 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package privileges
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Names of the features of the device plugin that need capabilities.
+*/
+const (
+	Devices  = "devices"  // configuring pool devices and creating tap and kind devices
+	Bpf      = "bpf"      // loading XDP programs and XSK maps for pools with a UDS server
+	PodNetns = "podNetns" // entering pod network namespaces
+	Chown    = "chown"    // giving runtime directories another owner
+)
+
+/*
+Feature is a feature of the device plugin and the capabilities its code paths need. Needs is a
+list of alternatives: the feature needs one capability of every set. Required features cannot
+be disabled, the device plugin does not start without them.
+*/
+type Feature struct {
+	Name     string
+	Required bool
+	Needs    [][]string
+	Uses     string
+}
+
+/*
+Features are the features of the device plugin that need capabilities, the device plugin
+otherwise running with none. Reading the kubelet sockets and checkpoint needs only the root user.
+*/
+var Features = []Feature{
+	{
+		Name:     Devices,
+		Required: true,
+		Needs:    [][]string{{"CAP_NET_ADMIN"}},
+		Uses:     "ethtool filters, MTU, promiscuous mode and XDP detach of pool devices, tap devices and the kind secondary network",
+	},
+	{
+		Name:  Bpf,
+		Needs: [][]string{{"CAP_NET_ADMIN"}, {"CAP_BPF", "CAP_SYS_ADMIN"}},
+		Uses:  "loading the XDP program and XSK map of devices allocated to pods of pools with a UDS server, CAP_SYS_ADMIN on kernels before 5.8",
+	},
+	{
+		Name:  PodNetns,
+		Needs: [][]string{{"CAP_SYS_ADMIN"}},
+		Uses:  "queue and AF_XDP socket metrics of allocated devices, and crash recovery of devices left in pod network namespaces",
+	},
+	{
+		Name:  Chown,
+		Needs: [][]string{{"CAP_CHOWN"}},
+		Uses:  "giving the runtime directories an owner or group other than the device plugin user",
+	},
+}
+
+/*
+Missing returns the capabilities the current process is missing for each feature, keyed on
+feature name. Features with no missing capabilities are left out. Where any of a set of
+capabilities would do, the set is named as alternatives, e.g. CAP_BPF|CAP_SYS_ADMIN.
+*/
+func Missing(host host.Handler) (map[string][]string, error) {
+	missing := make(map[string][]string)
+
+	for _, feature := range Features {
+		for _, set := range feature.Needs {
+			lacking, err := host.MissingCapabilities(set...)
+			if err != nil {
+				return nil, fmt.Errorf("error checking capabilities: %v", err)
+			}
+			if len(lacking) == len(set) {
+				missing[feature.Name] = append(missing[feature.Name], strings.Join(set, "|"))
+			}
+		}
+	}
+
+	return missing, nil
+}
+
+/*
+delegated are the features performed by another process on behalf of the device plugin, keyed on
+feature name.
+*/
+var delegated = make(map[string]bool)
+
+/*
+Delegate marks a feature as performed by another process, such as the BPF helper, so Check
+enables it whatever the capabilities of the current process. It must be called before Check.
+*/
+func Delegate(name string) {
+	delegated[name] = true
+}
+
+/*
+Check checks the capabilities of the current process and returns which features are enabled,
+keyed on feature name. A warning is logged for each feature that is disabled as its capabilities
+are missing. An error is returned if a required feature is missing capabilities.
+*/
+func Check(host host.Handler) (map[string]bool, error) {
+	missing, err := Missing(host)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(Features))
+	for _, feature := range Features {
+		if delegated[feature.Name] {
+			enabled[feature.Name] = true
+			continue
+		}
+		lacking, disabled := missing[feature.Name]
+		if disabled && feature.Required {
+			return nil, fmt.Errorf("missing %s, required for %s", strings.Join(lacking, ", "), feature.Uses)
+		}
+		if disabled {
+			logging.Warningf("Missing %s, disabling %s", strings.Join(lacking, ", "), feature.Uses)
+		}
+		enabled[feature.Name] = !disabled
+	}
+
+	return enabled, nil
+}
+
+/*
+HandshakeNeeds are the capabilities the UDS handshake keeps when its privileges are dropped: none,
+as configuring busy poll, which needs CAP_NET_ADMIN for settings above the system default, is one of
+the calls the handshake makes through RunPrivileged.
+*/
+var HandshakeNeeds []string
+
+/*
+CheckDrop returns an error if the current process cannot drop the privileges of the UDS handshake,
+as it lacks CAP_SETUID and CAP_SETGID to switch the handshake to an unprivileged user, or
+CAP_SETPCAP to remove capabilities from the bounding set.
+*/
+func CheckDrop(host host.Handler) error {
+	lacking, err := host.MissingCapabilities("CAP_SETUID", "CAP_SETGID", "CAP_SETPCAP")
+	if err != nil {
+		return fmt.Errorf("error checking capabilities: %v", err)
+	}
+	if len(lacking) > 0 {
+		return fmt.Errorf("missing %s, required to switch user and drop capabilities from the bounding set", strings.Join(lacking, ", "))
+	}
+	return nil
+}
+
+/*
+DropForHandshake locks the calling Go routine to its OS thread, switches the thread to the
+unprivileged uid and gid, and drops every capability but HandshakeNeeds from the thread, including
+its ambient and inheritable sets, for a long running UDS handshake. The thread exits with the Go
+routine, as it is never unlocked, so no other Go routine runs on it. Go routines started by the
+caller, such as the pod watch of the UDS server, run on other threads with the user and capabilities
+of the process, as do calls the caller makes through RunPrivileged.
+*/
+func DropForHandshake(host host.Handler, uid int, gid int) error {
+	runtime.LockOSThread()
+	if err := host.DropThreadIds(uid, gid); err != nil {
+		return fmt.Errorf("error switching to user %d, group %d: %v", uid, gid, err)
+	}
+	if err := host.DropThreadCapabilities(HandshakeNeeds...); err != nil {
+		return fmt.Errorf("error dropping capabilities: %v", err)
+	}
+	return nil
+}
+
+/*
+RunPrivileged runs fn with the user and capabilities of the process, from a Go routine whose thread
+may have dropped them with DropForHandshake, and waits for it to return. Go routines are never
+scheduled on the locked thread of another, so fn runs on a Go routine of its own.
+*/
+func RunPrivileged(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+	<-done
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package privileges
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		name       string
+		missing    []string
+		expEnabled map[string]bool
+		expErr     string
+	}{
+		{
+			name:       "all capabilities",
+			expEnabled: map[string]bool{Devices: true, Bpf: true, PodNetns: true, Chown: true},
+		},
+		{
+			name:       "cap bpf without cap sys admin",
+			missing:    []string{"CAP_SYS_ADMIN", "CAP_CHOWN"},
+			expEnabled: map[string]bool{Devices: true, Bpf: true, PodNetns: false, Chown: false},
+		},
+		{
+			name:       "cap sys admin without cap bpf",
+			missing:    []string{"CAP_BPF"},
+			expEnabled: map[string]bool{Devices: true, Bpf: true, PodNetns: true, Chown: true},
+		},
+		{
+			name:       "neither cap bpf nor cap sys admin",
+			missing:    []string{"CAP_BPF", "CAP_SYS_ADMIN"},
+			expEnabled: map[string]bool{Devices: true, Bpf: false, PodNetns: false, Chown: true},
+		},
+		{
+			name:    "no cap net admin",
+			missing: []string{"CAP_NET_ADMIN"},
+			expErr:  "missing CAP_NET_ADMIN, required for ethtool filters",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeHost := host.NewFakeHandler()
+			fakeHost.SetMissingCapabilities(tc.missing...)
+
+			enabled, err := Check(fakeHost)
+			if tc.expErr != "" {
+				if assert.Error(t, err, "Error was expected") {
+					assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+				}
+				return
+			}
+			assert.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expEnabled, enabled, "Unexpected features enabled")
+		})
+	}
+}
+
+func TestMissingAlternatives(t *testing.T) {
+	fakeHost := host.NewFakeHandler()
+	fakeHost.SetMissingCapabilities("CAP_BPF", "CAP_SYS_ADMIN")
+	defer fakeHost.SetMissingCapabilities()
+
+	missing, err := Missing(fakeHost)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, map[string][]string{Bpf: {"CAP_BPF|CAP_SYS_ADMIN"}, PodNetns: {"CAP_SYS_ADMIN"}}, missing, "Unexpected missing capabilities")
+}
+
+func TestDelegate(t *testing.T) {
+	fakeHost := host.NewFakeHandler()
+	fakeHost.SetMissingCapabilities("CAP_BPF", "CAP_SYS_ADMIN")
+	defer fakeHost.SetMissingCapabilities()
+
+	Delegate(Bpf)
+	defer delete(delegated, Bpf)
+	enabled, err := Check(fakeHost)
+
+	assert.NoError(t, err, "Unexpected error")
+	assert.Equal(t, map[string]bool{Devices: true, Bpf: true, PodNetns: false, Chown: true}, enabled, "Unexpected features enabled")
+}
+
+func TestCheckDrop(t *testing.T) {
+	fakeHost := host.NewFakeHandler()
+	assert.NoError(t, CheckDrop(fakeHost), "Unexpected error")
+
+	fakeHost.SetMissingCapabilities("CAP_SETPCAP")
+	defer fakeHost.SetMissingCapabilities()
+	err := CheckDrop(fakeHost)
+	if assert.Error(t, err, "Error was expected") {
+		assert.Contains(t, err.Error(), "missing CAP_SETPCAP", "Unexpected error")
+	}
+
+	fakeHost.SetMissingCapabilities("CAP_SETUID", "CAP_SETGID")
+	err = CheckDrop(fakeHost)
+	if assert.Error(t, err, "Error was expected") {
+		assert.Contains(t, err.Error(), "missing CAP_SETUID, CAP_SETGID", "Unexpected error")
+	}
+}
+
+func TestDropForHandshake(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fakeHost := host.NewFakeHandler()
+		assert.NoError(t, DropForHandshake(fakeHost, 65534, 65534), "Unexpected error")
+		uid, gid := fakeHost.ThreadIds()
+		assert.Equal(t, 65534, uid, "Unexpected user")
+		assert.Equal(t, 65534, gid, "Unexpected group")
+		assert.Empty(t, fakeHost.KeptCapabilities(), "Unexpected capabilities kept")
+
+		fakeHost.SetError("DropThreadIds", errors.New("fake error"))
+		assert.Error(t, DropForHandshake(fakeHost, 65534, 65534), "Error was expected")
+	}()
+	<-done
+}
+
+func TestRunPrivileged(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runtime.LockOSThread()
+		ran := false
+		RunPrivileged(func() { ran = true })
+		assert.True(t, ran, "Function should have run before returning")
+	}()
+	<-done
+}
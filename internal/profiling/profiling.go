@@ -0,0 +1,112 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profiling
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+listenAddr returns the network and address to listen on for addr, which is either the absolute
+path of a UDS or a loopback host:port. Profiles reveal the internals of the device plugin and
+capturing a CPU profile has a cost, so they are never served on a routable address.
+*/
+func listenAddr(addr string) (string, string, error) {
+	if filepath.IsAbs(addr) {
+		return "unix", addr, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid pprof address %s, must be a UDS path or host:port: %w", addr, err)
+	}
+	if host == "localhost" {
+		return "tcp", addr, nil
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip == nil || !ip.IsLoopback() {
+		return "", "", fmt.Errorf("invalid pprof address %s, host must be localhost or a loopback address", addr)
+	}
+
+	return "tcp", addr, nil
+}
+
+/*
+Serve starts serving pprof profiles on addr, the absolute path of a UDS or a loopback host:port.
+Any stale UDS left at the path is removed, and the new UDS is only accessible to root.
+The listener is opened before returning so that address errors are reported to the caller,
+requests are then served on a Go routine.
+*/
+func Serve(addr string) error {
+	network, address, err := listenAddr(addr)
+	if err != nil {
+		return err
+	}
+
+	if network == "unix" {
+		if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error removing stale pprof socket %s: %w", address, err)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		logging.Errorf("Error opening pprof listener on %s: %v", addr, err)
+		return err
+	}
+
+	if network == "unix" {
+		if err := os.Chmod(address, os.FileMode(constants.Profiling.SocketPermissions)); err != nil {
+			listener.Close()
+			return fmt.Errorf("error setting permissions of pprof socket %s: %w", address, err)
+		}
+	}
+
+	go func() {
+		if err := http.Serve(listener, handler()); err != nil {
+			logging.Errorf("pprof server stopped: %v", err)
+		}
+	}()
+
+	logging.Infof("Serving pprof profiles on %s%s", addr, constants.Profiling.Path)
+
+	return nil
+}
+
+/*
+handler returns the pprof handlers, registered on a new mux rather than the default mux that
+importing net/http/pprof registers them on.
+*/
+func handler() http.Handler {
+	path := constants.Profiling.Path
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, pprof.Index)
+	mux.HandleFunc(path+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(path+"profile", pprof.Profile)
+	mux.HandleFunc(path+"symbol", pprof.Symbol)
+	mux.HandleFunc(path+"trace", pprof.Trace)
+
+	return mux
+}
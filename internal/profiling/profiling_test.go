@@ -0,0 +1,118 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package profiling
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenAddr(t *testing.T) {
+	testCases := []struct {
+		name       string
+		addr       string
+		expNetwork string
+		expErr     string
+	}{
+		{
+			name:       "uds",
+			addr:       "/tmp/afxdp_dp/pprof.sock",
+			expNetwork: "unix",
+		},
+		{
+			name:       "localhost",
+			addr:       "localhost:6060",
+			expNetwork: "tcp",
+		},
+		{
+			name:       "ipv4 loopback",
+			addr:       "127.0.0.1:6060",
+			expNetwork: "tcp",
+		},
+		{
+			name:       "ipv6 loopback",
+			addr:       "[::1]:6060",
+			expNetwork: "tcp",
+		},
+		{
+			name:   "all interfaces",
+			addr:   ":6060",
+			expErr: "host must be localhost or a loopback address",
+		},
+		{
+			name:   "routable address",
+			addr:   "10.0.0.1:6060",
+			expErr: "host must be localhost or a loopback address",
+		},
+		{
+			name:   "relative path",
+			addr:   "pprof.sock",
+			expErr: "must be a UDS path or host:port",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			network, address, err := listenAddr(tc.addr)
+			if tc.expErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expErr, "Unexpected error")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expNetwork, network, "Unexpected network")
+			assert.Equal(t, tc.addr, address, "Unexpected address")
+		})
+	}
+}
+
+func TestServeUDS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "afxdp-pprof")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sock := filepath.Join(dir, "pprof.sock")
+	require.NoError(t, ioutil.WriteFile(sock, nil, 0600), "Error creating stale socket")
+	require.NoError(t, Serve(sock))
+
+	info, err := os.Stat(sock)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Socket should only be accessible to root")
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+			},
+		},
+	}
+	resp, err := client.Get("http://pprof/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode, "Unexpected status")
+	assert.Contains(t, string(body), "goroutine profile:", "Unexpected profile")
+}
@@ -0,0 +1,183 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+var podResCacheLookups = metrics.NewCounterVec("pod_resources_cache_lookups_total",
+	"Number of pod resources lookups served from memory (hit) or requiring a call to the kubelet (miss).", "result")
+
+/*
+sharedCache caches the pod resources for all handlers.
+*/
+var sharedCache = newPodResourcesCache(time.Duration(constants.PodResources.CacheTTL)*time.Second, listPodResources)
+
+/*
+SetCacheTTL sets how long pod resources are cached for when pods are not tracked.
+*/
+func SetCacheTTL(ttl time.Duration) {
+	sharedCache.lock.Lock()
+	defer sharedCache.lock.Unlock()
+
+	sharedCache.ttl = ttl
+}
+
+/*
+podResourcesCache holds the most recent pod resources for up to ttl. Callers arriving while the
+pod resources are being fetched wait for that fetch rather than starting their own. Errors are
+not cached. While tracking, pod resources are kept current by refresh and do not expire.
+*/
+type podResourcesCache struct {
+	lock     sync.Mutex
+	ttl      time.Duration
+	fetch    func() (map[string]api.PodResources, error)
+	pods     map[string]api.PodResources
+	fetched  time.Time
+	tracking bool
+}
+
+func newPodResourcesCache(ttl time.Duration, fetch func() (map[string]api.PodResources, error)) *podResourcesCache {
+	return &podResourcesCache{
+		ttl:   ttl,
+		fetch: fetch,
+	}
+}
+
+/*
+get returns a copy of the cached pod resources, fetching them if the cache is empty or expired.
+*/
+func (c *podResourcesCache) get() (map[string]api.PodResources, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.pods == nil || (!c.tracking && time.Since(c.fetched) > c.ttl) {
+		podResCacheLookups.Add(1, "miss")
+		pods, err := c.fetch()
+		if err != nil {
+			return pods, err
+		}
+		c.pods = pods
+		c.fetched = time.Now()
+	} else {
+		podResCacheLookups.Add(1, "hit")
+		logging.Debugf("Using pod resources cached %v ago", time.Since(c.fetched).Round(time.Millisecond))
+	}
+
+	pods := make(map[string]api.PodResources, len(c.pods))
+	for name, pod := range c.pods {
+		pods[name] = pod
+	}
+
+	return pods, nil
+}
+
+/*
+invalidate discards the cached pod resources.
+*/
+func (c *podResourcesCache) invalidate() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.pods = nil
+}
+
+/*
+refresh fetches the pod resources, replacing the cached pod resources. On error the cached pod
+resources are discarded, so callers fetch for themselves rather than use a stale view.
+*/
+func (c *podResourcesCache) refresh() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pods, err := c.fetch()
+	if err != nil {
+		c.pods = nil
+		return err
+	}
+	c.pods = pods
+	c.fetched = time.Now()
+
+	return nil
+}
+
+/*
+setTracking sets whether the pod resources are kept current by refresh.
+*/
+func (c *podResourcesCache) setTracking(tracking bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.tracking = tracking
+}
+
+/*
+CachedPod is the pod resources of a pod held by the cache, with the device IDs the pod was
+allocated of each resource.
+*/
+type CachedPod struct {
+	Name      string              `json:"name"`
+	Namespace string              `json:"namespace"`
+	Devices   map[string][]string `json:"devices"`
+}
+
+/*
+CachedPods returns the pod resources held by the shared cache, sorted by pod, and when they were
+fetched, without fetching them. It is for diagnostics, nothing is returned if nothing is cached.
+*/
+func CachedPods() ([]CachedPod, time.Time) {
+	return sharedCache.cached()
+}
+
+func (c *podResourcesCache) cached() ([]CachedPod, time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	pods := make([]CachedPod, 0, len(c.pods))
+	for _, pod := range c.pods {
+		cached := CachedPod{
+			Name:      pod.GetName(),
+			Namespace: pod.GetNamespace(),
+			Devices:   make(map[string][]string),
+		}
+		for _, container := range pod.GetContainers() {
+			for _, devices := range container.GetDevices() {
+				resource := devices.GetResourceName()
+				cached.Devices[resource] = append(cached.Devices[resource], devices.GetDeviceIds()...)
+			}
+		}
+		pods = append(pods, cached)
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+
+	if c.pods == nil {
+		return pods, time.Time{}
+	}
+	return pods, c.fetched
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+func TestPodResourcesCache(t *testing.T) {
+	var fetches int
+	var fetchErr error
+	fetch := func() (map[string]api.PodResources, error) {
+		fetches++
+		if fetchErr != nil {
+			return map[string]api.PodResources{}, fetchErr
+		}
+		return map[string]api.PodResources{"pod-1": {Name: "pod-1", Namespace: "default"}}, nil
+	}
+
+	cache := newPodResourcesCache(time.Hour, fetch)
+
+	pods, err := cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Contains(t, pods, "pod-1", "Pod missing from pod resources")
+	assert.Equal(t, 1, fetches, "First get should fetch")
+
+	delete(pods, "pod-1")
+	pods, err = cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Contains(t, pods, "pod-1", "Modifying a returned map must not modify the cache")
+	assert.Equal(t, 1, fetches, "Get within the TTL should use the cache")
+
+	cache.invalidate()
+	fetchErr = errors.New("kubelet unavailable")
+	_, err = cache.get()
+	assert.Error(t, err, "Fetch error should be returned")
+	assert.Equal(t, 2, fetches, "Get after invalidation should fetch")
+
+	fetchErr = nil
+	_, err = cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3, fetches, "Errors must not be cached")
+
+	expired := newPodResourcesCache(0, fetch)
+	_, err = expired.get()
+	require.NoError(t, err, "Unexpected error")
+	time.Sleep(time.Millisecond)
+	_, err = expired.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 5, fetches, "Get after the TTL should fetch")
+}
+
+func TestPodResourcesCacheConcurrent(t *testing.T) {
+	var lock sync.Mutex
+	var fetches int
+	fetch := func() (map[string]api.PodResources, error) {
+		lock.Lock()
+		fetches++
+		lock.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		return map[string]api.PodResources{}, nil
+	}
+
+	cache := newPodResourcesCache(time.Hour, fetch)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := cache.get()
+			assert.NoError(t, err, "Unexpected error")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, fetches, "Concurrent gets should share a single fetch")
+}
+
+func TestPodResourcesCacheCached(t *testing.T) {
+	fetch := func() (map[string]api.PodResources, error) {
+		return map[string]api.PodResources{
+			"pod-2": {Name: "pod-2", Namespace: "default"},
+			"pod-1": {Name: "pod-1", Namespace: "default", Containers: []*api.ContainerResources{
+				{Name: "ctr-1", Devices: []*api.ContainerDevices{{ResourceName: "afxdp/myPool", DeviceIds: []string{"dev1"}}}},
+				{Name: "ctr-2", Devices: []*api.ContainerDevices{{ResourceName: "afxdp/myPool", DeviceIds: []string{"dev2", "dev3"}}}},
+			}},
+		}, nil
+	}
+	cache := newPodResourcesCache(time.Hour, fetch)
+
+	pods, fetched := cache.cached()
+	assert.Empty(t, pods, "Nothing should be cached before a fetch")
+	assert.True(t, fetched.IsZero(), "Nothing should have been fetched")
+
+	_, err := cache.get()
+	require.NoError(t, err)
+
+	pods, fetched = cache.cached()
+	assert.Equal(t, []CachedPod{
+		{Name: "pod-1", Namespace: "default", Devices: map[string][]string{"afxdp/myPool": {"dev1", "dev2", "dev3"}}},
+		{Name: "pod-2", Namespace: "default", Devices: map[string][]string{}},
+	}, pods, "Unexpected cached pods")
+	assert.False(t, fetched.IsZero(), "Fetch time should be returned")
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	podResCalls = metrics.NewCounterVec("pod_resources_calls_total",
+		"Number of calls to the kubelet pod resources API, by call and outcome.", "call", "outcome")
+	podResCallDuration = metrics.NewHistogramVec("pod_resources_call_duration_seconds",
+		"Duration of calls to the kubelet pod resources API, including any wait for the kubelet socket, by call and outcome.",
+		metrics.DurationBuckets, "call", "outcome")
+)
+
+/*
+sharedConn is the connection to the pod resources api used by all handlers, and so shared by
+all UDS servers and the pool allocatable device checks.
+*/
+var sharedConn = newPodResConn(func() string { return podResSockPath }, dial)
+
+/*
+podResConn holds a long lived connection to the pod resources api. The connection is opened on
+first use and reopened on the next use after it is found broken, either by a call failing as
+unavailable, by a health check, or by the socket being recreated by a restarted kubelet.
+*/
+type podResConn struct {
+	lock       sync.Mutex
+	socket     func() string
+	dial       func(ctx context.Context, socket string) (*grpc.ClientConn, error)
+	conn       *grpc.ClientConn
+	socketInfo os.FileInfo   // the socket the open connection was opened on
+	seen       bool          // the socket has existed, so if missing the kubelet is restarting
+	socketWait time.Duration // how long to wait for a missing socket to reappear
+	socketPoll time.Duration // how often to check for a missing socket
+}
+
+func newPodResConn(socket func() string, dial func(ctx context.Context, socket string) (*grpc.ClientConn, error)) *podResConn {
+	return &podResConn{
+		socket:     socket,
+		dial:       dial,
+		socketWait: time.Duration(constants.PodResources.RestartWait) * time.Second,
+		socketPoll: time.Duration(constants.PodResources.RestartPoll) * time.Millisecond,
+	}
+}
+
+/*
+invoke calls the pod resources api over the shared connection, opening it if needed. A call
+failing as unavailable means the kubelet went away, so the connection is dropped and the next
+call, such as a retry, opens a new one. While the kubelet restarts, its socket is missing, and
+calls wait for the socket to reappear rather than fail. Each call is counted and timed, by name.
+*/
+func (c *podResConn) invoke(name string, call func(ctx context.Context, conn *grpc.ClientConn) error) (err error) {
+	start := time.Now()
+	defer func() {
+		outcome := callOutcome(err)
+		podResCalls.Add(1, name, outcome)
+		podResCallDuration.Observe(time.Since(start).Seconds(), name, outcome)
+	}()
+
+	if err := c.awaitSocket(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+
+	conn, err := c.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = call(ctx, conn)
+	if status.Code(err) == codes.Unavailable {
+		c.reset(conn)
+	}
+
+	return err
+}
+
+/*
+callOutcome returns the outcome label of a pod resources api call.
+*/
+func callOutcome(err error) string {
+	switch {
+	case err == nil:
+		return "success"
+	case IsUnimplemented(err):
+		return "unimplemented"
+	default:
+		return "error"
+	}
+}
+
+/*
+awaitSocket waits up to socketWait for the socket to reappear if it is missing, having existed before.
+A socket that has never existed, such as one that is not mounted, is not waited for, so callers
+fail fast and can fall back to other means of validating pods.
+*/
+func (c *podResConn) awaitSocket() error {
+	c.lock.Lock()
+	seen := c.seen
+	c.lock.Unlock()
+
+	_, err := os.Stat(c.socket())
+	if err == nil || !seen || !os.IsNotExist(err) {
+		return nil
+	}
+
+	logging.Warningf("Pod Resource API socket %s is missing, waiting up to %v for the kubelet to restart", c.socket(), c.socketWait)
+	deadline := time.Now().Add(c.socketWait)
+	for time.Now().Before(deadline) {
+		time.Sleep(c.socketPoll)
+		if _, err = os.Stat(c.socket()); err == nil {
+			logging.Infof("Pod Resource API socket %s is back", c.socket())
+			return nil
+		}
+	}
+
+	logging.Errorf("Pod Resource API socket %s did not reappear: %v", c.socket(), err)
+	return err
+}
+
+/*
+get returns the open connection, opening a new one if there is none or if the socket it was
+opened on has since been removed or replaced.
+*/
+func (c *podResConn) get(ctx context.Context) (*grpc.ClientConn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != nil && c.socketReplaced() {
+		logging.Infof("Pod Resource API socket was recreated, the kubelet restarted, reconnecting")
+		c.closeConn()
+	}
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	info, err := os.Stat(c.socket())
+	if err == nil {
+		c.seen = true
+	}
+
+	conn, err := c.dial(ctx, c.socket())
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.socketInfo = info
+
+	return conn, nil
+}
+
+/*
+socketReplaced returns true if the socket the open connection was opened on is missing or is
+no longer the same file. The kubelet removes and recreates its socket when it restarts.
+*/
+func (c *podResConn) socketReplaced() bool {
+	info, err := os.Stat(c.socket())
+	if err != nil {
+		return true
+	}
+	if c.socketInfo == nil {
+		return false
+	}
+
+	return !os.SameFile(info, c.socketInfo) || !info.ModTime().Equal(c.socketInfo.ModTime())
+}
+
+/*
+reset closes the connection if it is still the open connection. Callers that found a connection
+broken at the same time close it once.
+*/
+func (c *podResConn) reset(conn *grpc.ClientConn) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn != conn || conn == nil {
+		return
+	}
+	c.closeConn()
+}
+
+func (c *podResConn) closeConn() {
+	logging.Debugf("Closing Pod Resource API connection")
+	c.conn.Close()
+	c.conn = nil
+	c.socketInfo = nil
+}
+
+/*
+close closes the open connection, if any.
+*/
+func (c *podResConn) close() {
+	c.lock.Lock()
+	conn := c.conn
+	c.lock.Unlock()
+
+	c.reset(conn)
+}
+
+/*
+Close closes the connection to the pod resources api shared by all handlers, on shutdown.
+A handler used afterwards opens a new connection.
+*/
+func Close() {
+	sharedConn.close()
+}
+
+/*
+checkHealth checks the state of the open connection. A connection in transient failure or shut
+down, or opened on a socket since recreated by a restarted kubelet, is reopened, so a broken
+connection is found by the health check rather than by a pod handshake. An idle connection is
+asked to connect, keeping it ready for the next call.
+Returns false if the connection is broken and could not be reopened.
+*/
+func (c *podResConn) checkHealth() bool {
+	c.lock.Lock()
+	conn := c.conn
+	replaced := conn != nil && c.socketReplaced()
+	c.lock.Unlock()
+
+	if conn == nil {
+		return true
+	}
+
+	state := conn.GetState()
+	if replaced || state == connectivity.TransientFailure || state == connectivity.Shutdown {
+		logging.Warningf("Pod Resource API connection is %v (socket recreated: %v), reconnecting", state, replaced)
+		c.reset(conn)
+
+		ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+		defer cancel()
+		if _, err := c.get(ctx); err != nil {
+			logging.Warningf("Unable to reconnect to the Pod Resource API: %v", err)
+			return false
+		}
+	} else if state == connectivity.Idle {
+		conn.Connect()
+	}
+
+	return true
+}
+
+/*
+monitor checks the health of the connection every interval, closing it once the stop channel is closed.
+*/
+func (c *podResConn) monitor(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	defer c.close()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			progress.checked(time.Now(), c.checkHealth())
+		}
+	}
+}
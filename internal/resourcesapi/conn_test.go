@@ -0,0 +1,312 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+/*
+testSocket creates a file standing in for the kubelet socket, returning a function returning its path.
+*/
+func testSocket(t *testing.T) func() string {
+	dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Can't create temporary directory")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "kubelet.sock")
+	require.NoError(t, ioutil.WriteFile(socket, nil, 0600), "Can't create socket file")
+
+	return func() string { return socket }
+}
+
+/*
+recreateSocket replaces the socket file with a new file, as a restarted kubelet does.
+*/
+func recreateSocket(t *testing.T, socket string) {
+	require.NoError(t, ioutil.WriteFile(socket+".new", nil, 0600), "Can't create socket file")
+	require.NoError(t, os.Rename(socket+".new", socket), "Can't replace socket file")
+}
+
+/*
+countingDial returns a dial function opening non blocking connections to a socket that does not
+exist, and a function returning the number of connections opened.
+*/
+func countingDial() (func(ctx context.Context, socket string) (*grpc.ClientConn, error), func() int) {
+	var lock sync.Mutex
+	dials := 0
+
+	dial := func(ctx context.Context, socket string) (*grpc.ClientConn, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		dials++
+		return grpc.Dial("unix:///tmp/afxdp-test-missing.sock", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+	count := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return dials
+	}
+
+	return dial, count
+}
+
+func TestPodResConnInvoke(t *testing.T) {
+	testCases := []struct {
+		name      string
+		callErrs  []error
+		expDials  int
+		expClosed bool
+	}{
+		{
+			name:     "connection reused",
+			callErrs: []error{nil, nil, nil},
+			expDials: 1,
+		},
+		{
+			name:     "connection kept after call error",
+			callErrs: []error{status.Error(codes.NotFound, "pod not found"), nil},
+			expDials: 1,
+		},
+		{
+			name:     "connection reopened after unavailable",
+			callErrs: []error{status.Error(codes.Unavailable, "connection refused"), nil},
+			expDials: 2,
+		},
+		{
+			name:      "connection dropped after unavailable",
+			callErrs:  []error{nil, status.Error(codes.Unavailable, "connection refused")},
+			expDials:  1,
+			expClosed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dial, dials := countingDial()
+			conn := newPodResConn(testSocket(t), dial)
+			defer conn.close()
+
+			for _, callErr := range tc.callErrs {
+				err := conn.invoke("List", func(ctx context.Context, cc *grpc.ClientConn) error {
+					require.NotNil(t, cc, "Expected a connection")
+					return callErr
+				})
+				assert.Equal(t, callErr, err, "Unexpected error")
+			}
+
+			assert.Equal(t, tc.expDials, dials(), "Unexpected number of connections opened")
+			assert.Equal(t, tc.expClosed, conn.conn == nil, "Unexpected connection state")
+		})
+	}
+}
+
+func TestPodResConnDialError(t *testing.T) {
+	dialErr := errors.New("no such file or directory")
+	conn := newPodResConn(testSocket(t), func(ctx context.Context, socket string) (*grpc.ClientConn, error) {
+		return nil, dialErr
+	})
+
+	called := false
+	err := conn.invoke("List", func(ctx context.Context, cc *grpc.ClientConn) error {
+		called = true
+		return nil
+	})
+
+	assert.Equal(t, dialErr, err, "Expected the dial error")
+	assert.False(t, called, "Call should not be made without a connection")
+	assert.True(t, conn.checkHealth(), "No connection should be reported healthy")
+}
+
+func TestPodResConnReset(t *testing.T) {
+	dial, dials := countingDial()
+	conn := newPodResConn(testSocket(t), dial)
+	defer conn.close()
+
+	first, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+	conn.reset(first)
+	second, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+
+	// a caller resetting the first connection late must not close the second
+	conn.reset(first)
+	current, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+	assert.Same(t, second, current, "Stale reset should not close the open connection")
+	assert.Equal(t, 2, dials(), "Unexpected number of connections opened")
+}
+
+func TestPodResConnHealthCheck(t *testing.T) {
+	dial, dials := countingDial()
+	conn := newPodResConn(testSocket(t), dial)
+	defer conn.close()
+
+	_, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+
+	// the socket does not exist, so the connection fails once asked to connect
+	require.Eventually(t, func() bool {
+		conn.checkHealth()
+		return dials() == 2
+	}, 5*time.Second, 10*time.Millisecond, "Broken connection should be reopened by the health check")
+}
+
+func TestPodResConnMonitor(t *testing.T) {
+	dial, _ := countingDial()
+	conn := newPodResConn(testSocket(t), dial)
+
+	_, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		conn.monitor(stop, time.Hour)
+		close(done)
+	}()
+	close(stop)
+	<-done
+
+	assert.Nil(t, conn.conn, "Connection should be closed once monitoring stops")
+}
+
+func TestPodResConnSocketRecreated(t *testing.T) {
+	socket := testSocket(t)
+	dial, dials := countingDial()
+	conn := newPodResConn(socket, dial)
+	defer conn.close()
+
+	first, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+	recreateSocket(t, socket())
+
+	second, err := conn.get(context.Background())
+	require.NoError(t, err, "Unexpected error")
+	assert.NotSame(t, first, second, "Connection should be reopened on the recreated socket")
+	assert.Equal(t, 2, dials(), "Unexpected number of connections opened")
+
+	recreateSocket(t, socket())
+	assert.True(t, conn.checkHealth(), "Health check should reconnect on the recreated socket")
+	assert.Equal(t, 3, dials(), "Health check should reopen the connection")
+}
+
+func TestPodResConnAwaitSocket(t *testing.T) {
+	testCases := []struct {
+		name     string
+		seen     bool
+		recreate bool
+		expErr   bool
+		expWait  bool
+	}{
+		{
+			name:     "socket recreated while waiting",
+			seen:     true,
+			recreate: true,
+			expWait:  true,
+		},
+		{
+			name:    "socket not recreated",
+			seen:    true,
+			expErr:  true,
+			expWait: true,
+		},
+		{
+			name: "socket never seen",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			socket := testSocket(t)
+			dial, _ := countingDial()
+			conn := newPodResConn(socket, dial)
+			conn.socketWait = 200 * time.Millisecond
+			conn.socketPoll = 5 * time.Millisecond
+			defer conn.close()
+
+			if tc.seen {
+				_, err := conn.get(context.Background())
+				require.NoError(t, err, "Unexpected error")
+			}
+			require.NoError(t, os.Remove(socket()), "Can't remove socket file")
+			if tc.recreate {
+				go func() {
+					time.Sleep(20 * time.Millisecond)
+					ioutil.WriteFile(socket(), nil, 0600)
+				}()
+			}
+
+			start := time.Now()
+			err := conn.invoke("List", func(ctx context.Context, cc *grpc.ClientConn) error {
+				return nil
+			})
+			waited := time.Since(start) >= 20*time.Millisecond
+
+			assert.Equal(t, tc.expErr, err != nil, "Unexpected error: %v", err)
+			assert.Equal(t, tc.expWait, waited, "Unexpected wait for the socket")
+		})
+	}
+}
+
+func TestCallOutcome(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		expOutcome string
+	}{
+		{
+			name:       "success",
+			err:        nil,
+			expOutcome: "success",
+		},
+		{
+			name:       "unimplemented",
+			err:        status.Error(codes.Unimplemented, "unknown method Get"),
+			expOutcome: "unimplemented",
+		},
+		{
+			name:       "unavailable",
+			err:        status.Error(codes.Unavailable, "connection refused"),
+			expOutcome: "error",
+		},
+		{
+			name:       "socket missing",
+			err:        errors.New("no such file or directory"),
+			expOutcome: "error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expOutcome, callOutcome(tc.err), "Unexpected outcome")
+		})
+	}
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	logging "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+/*
+podResourcesGetMethod is the pod scoped Get endpoint of the pod resources api, added in
+Kubernetes 1.27 behind the KubeletPodResourcesGet feature gate. It is newer than the
+podresources client in use, so requests and responses are encoded by getCodec.
+*/
+const podResourcesGetMethod = "/v1.PodResourcesLister/Get"
+
+/*
+getUnimplemented is set once the kubelet has reported Get as unimplemented, so it is not called again.
+*/
+var getUnimplemented int32
+
+/*
+GetPodResource calls the pod scoped Get endpoint of the pod resources api, returning the resources
+of a single pod. This avoids listing every pod on the node. Kubelets without the Get endpoint
+return an error for which IsUnimplemented is true, as do all later calls.
+*/
+func (r *handler) GetPodResource(podName string, namespace string) (*api.PodResources, error) {
+	if atomic.LoadInt32(&getUnimplemented) == 1 {
+		return nil, status.Error(codes.Unimplemented, "pod resources Get is not implemented by the kubelet")
+	}
+
+	pod, err := getPodResource(sharedConn, podName, namespace)
+	if IsUnimplemented(err) {
+		logging.Infof("Kubelet does not implement pod resources Get, pods will be validated using List")
+		atomic.StoreInt32(&getUnimplemented, 1)
+	}
+
+	return pod, err
+}
+
+func getPodResource(conn *podResConn, podName string, namespace string) (*api.PodResources, error) {
+	req := &getPodResourcesRequest{podName: podName, podNamespace: namespace}
+	resp := &getPodResourcesResponse{}
+
+	err := conn.invoke("Get", func(ctx context.Context, cc *grpc.ClientConn) error {
+		logging.Debugf("Requesting pod resources of pod %s/%s", namespace, podName)
+		return cc.Invoke(ctx, podResourcesGetMethod, req, resp, grpc.ForceCodec(getCodec{}))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.pod == nil {
+		return nil, fmt.Errorf("pod %s/%s not found in pod resources", namespace, podName)
+	}
+
+	return resp.pod, nil
+}
+
+/*
+getPodResourcesRequest is the GetPodResourcesRequest message: pod_name = 1, pod_namespace = 2.
+*/
+type getPodResourcesRequest struct {
+	podName      string
+	podNamespace string
+}
+
+/*
+getPodResourcesResponse is the GetPodResourcesResponse message: pod_resources = 1.
+*/
+type getPodResourcesResponse struct {
+	pod *api.PodResources
+}
+
+/*
+getCodec encodes the Get request and decodes the Get response in the protobuf wire format.
+The PodResources message within the response is decoded by the podresources client.
+*/
+type getCodec struct{}
+
+func (getCodec) Name() string {
+	return "proto"
+}
+
+func (getCodec) Marshal(v interface{}) ([]byte, error) {
+	req, ok := v.(*getPodResourcesRequest)
+	if !ok {
+		return nil, fmt.Errorf("unexpected pod resources Get request type %T", v)
+	}
+
+	var data []byte
+	data = appendStringField(data, 1, req.podName)
+	data = appendStringField(data, 2, req.podNamespace)
+
+	return data, nil
+}
+
+func (getCodec) Unmarshal(data []byte, v interface{}) error {
+	resp, ok := v.(*getPodResourcesResponse)
+	if !ok {
+		return fmt.Errorf("unexpected pod resources Get response type %T", v)
+	}
+
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid field key in pod resources Get response")
+		}
+		data = data[n:]
+
+		field, wireType := key>>3, key&7
+		var value []byte
+		switch wireType {
+		case 0: // varint
+			if _, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid varint in pod resources Get response")
+			}
+		case 1: // 64 bit
+			n = 8
+		case 2: // length delimited
+			length, ln := binary.Uvarint(data)
+			if ln <= 0 || length > uint64(len(data)-ln) {
+				return fmt.Errorf("invalid length in pod resources Get response")
+			}
+			value = data[ln : ln+int(length)]
+			n = ln + int(length)
+		case 5: // 32 bit
+			n = 4
+		default:
+			return fmt.Errorf("unsupported wire type %d in pod resources Get response", wireType)
+		}
+		if n > len(data) {
+			return fmt.Errorf("truncated pod resources Get response")
+		}
+		data = data[n:]
+
+		if field == 1 && wireType == 2 {
+			pod := &api.PodResources{}
+			if err := pod.Unmarshal(value); err != nil {
+				return fmt.Errorf("error decoding pod resources: %w", err)
+			}
+			resp.pod = pod
+		}
+	}
+
+	return nil
+}
+
+func appendStringField(data []byte, field uint64, value string) []byte {
+	if value == "" {
+		return data
+	}
+	data = appendUvarint(data, field<<3|2)
+	data = appendUvarint(data, uint64(len(value)))
+	return append(data, value...)
+}
+
+func appendUvarint(data []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(data, buf[:n]...)
+}
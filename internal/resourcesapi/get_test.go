@@ -0,0 +1,120 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+func TestGetCodecMarshal(t *testing.T) {
+	testCases := []struct {
+		name    string
+		req     *getPodResourcesRequest
+		expData []byte
+	}{
+		{
+			name:    "name and namespace",
+			req:     &getPodResourcesRequest{podName: "podA", podNamespace: "default"},
+			expData: append([]byte{0x0a, 4, 'p', 'o', 'd', 'A', 0x12, 7}, "default"...),
+		},
+		{
+			name:    "empty namespace omitted",
+			req:     &getPodResourcesRequest{podName: "podA"},
+			expData: []byte{0x0a, 4, 'p', 'o', 'd', 'A'},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := getCodec{}.Marshal(tc.req)
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expData, data, "Unexpected encoding")
+		})
+	}
+
+	_, err := getCodec{}.Marshal("podA")
+	assert.Error(t, err, "Expected an error for an unexpected type")
+}
+
+func TestGetCodecUnmarshal(t *testing.T) {
+	pod := &api.PodResources{Name: "podA", Namespace: "default"}
+	podData, err := pod.Marshal()
+	require.NoError(t, err, "Can't encode pod resources")
+
+	podField := append([]byte{0x0a, byte(len(podData))}, podData...)
+	unknownFields := []byte{0x10, 0x96, 0x01, 0x1d, 1, 2, 3, 4, 0x22, 2, 'h', 'i'}
+
+	testCases := []struct {
+		name   string
+		data   []byte
+		expPod *api.PodResources
+		expErr bool
+	}{
+		{
+			name:   "pod resources",
+			data:   podField,
+			expPod: pod,
+		},
+		{
+			name:   "unknown fields skipped",
+			data:   append(append([]byte{}, unknownFields...), podField...),
+			expPod: pod,
+		},
+		{
+			name:   "empty response",
+			data:   []byte{},
+			expPod: nil,
+		},
+		{
+			name:   "truncated length",
+			data:   []byte{0x0a, 10, 'p'},
+			expErr: true,
+		},
+		{
+			name:   "truncated fixed field",
+			data:   []byte{0x1d, 1, 2},
+			expErr: true,
+		},
+		{
+			name:   "unsupported wire type",
+			data:   []byte{0x0b},
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := &getPodResourcesResponse{}
+			err := getCodec{}.Unmarshal(tc.data, resp)
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			if tc.expPod == nil {
+				assert.Nil(t, resp.pod, "Expected no pod")
+				return
+			}
+			require.NotNil(t, resp.pod, "Expected a pod")
+			assert.Equal(t, tc.expPod.Name, resp.pod.Name, "Unexpected pod name")
+			assert.Equal(t, tc.expPod.Namespace, resp.pod.Namespace, "Unexpected pod namespace")
+		})
+	}
+}
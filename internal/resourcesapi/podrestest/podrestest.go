@@ -0,0 +1,431 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package podrestest provides a mock kubelet serving the pod resources api on a temporary socket,
+for unit tests of the code calling the kubelet, such as pod validation and the pod resources
+health checks, without a real kubelet. Pods, allocatable devices and errors are programmed by the
+test, and the calls made are counted.
+*/
+package podrestest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+/*
+Methods of the pod resources api served, as passed to SetError and Calls.
+*/
+const (
+	MethodList        = "List"
+	MethodGet         = "Get"
+	MethodAllocatable = "GetAllocatableResources"
+)
+
+/*
+Server is a mock kubelet serving the pod resources api on a unix socket.
+*/
+type Server struct {
+	Socket string // path of the socket, passed to resourcesapi.SetSocketPath
+
+	lock        sync.Mutex
+	dir         string
+	grpcServer  *grpc.Server
+	pods        []*api.PodResources
+	allocatable []*api.ContainerDevices
+	errs        map[string]error
+	calls       map[string]int
+	delay       time.Duration
+}
+
+/*
+NewServer starts a mock kubelet on a socket in a new temporary directory. The server has no pods
+and no allocatable devices until they are added. Close stops it and removes the directory.
+*/
+func NewServer() (*Server, error) {
+	dir, err := ioutil.TempDir("", "afxdp-kubelet-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating socket directory: %v", err)
+	}
+
+	s := &Server{
+		Socket: filepath.Join(dir, "kubelet.sock"),
+		dir:    dir,
+		errs:   make(map[string]error),
+		calls:  make(map[string]int),
+	}
+	if err := s.serve(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return s, nil
+}
+
+/*
+serve listens on the socket and serves the pod resources api until the server is stopped.
+*/
+func (s *Server) serve() error {
+	listener, err := net.Listen("unix", s.Socket)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %v", s.Socket, err)
+	}
+
+	grpcServer := grpc.NewServer(grpc.ForceServerCodec(codec{}))
+	grpcServer.RegisterService(&serviceDesc, s)
+	go grpcServer.Serve(listener)
+
+	s.lock.Lock()
+	s.grpcServer = grpcServer
+	s.lock.Unlock()
+
+	return nil
+}
+
+/*
+Restart stops the server, removes the socket and serves on a new socket at the same path, as a
+restarted kubelet does. Open connections are closed.
+*/
+func (s *Server) Restart() error {
+	s.stop()
+	os.Remove(s.Socket)
+	return s.serve()
+}
+
+/*
+Close stops the server and removes the socket directory.
+*/
+func (s *Server) Close() {
+	s.stop()
+	os.RemoveAll(s.dir)
+}
+
+func (s *Server) stop() {
+	s.lock.Lock()
+	grpcServer := s.grpcServer
+	s.grpcServer = nil
+	s.lock.Unlock()
+
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+}
+
+/*
+AddPod adds a pod with a single container holding the devices of a resource. A pod added again
+is replaced.
+*/
+func (s *Server) AddPod(podName string, namespace string, resourceName string, deviceIds []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pod := &api.PodResources{
+		Name:      podName,
+		Namespace: namespace,
+		Containers: []*api.ContainerResources{
+			{
+				Name: "container-01",
+				Devices: []*api.ContainerDevices{
+					{
+						ResourceName: resourceName,
+						DeviceIds:    deviceIds,
+					},
+				},
+			},
+		},
+	}
+
+	for i, existing := range s.pods {
+		if existing.Name == podName && existing.Namespace == namespace {
+			s.pods[i] = pod
+			return
+		}
+	}
+	s.pods = append(s.pods, pod)
+}
+
+/*
+RemovePod removes a pod, as when it is deleted.
+*/
+func (s *Server) RemovePod(podName string, namespace string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i, existing := range s.pods {
+		if existing.Name == podName && existing.Namespace == namespace {
+			s.pods = append(s.pods[:i], s.pods[i+1:]...)
+			return
+		}
+	}
+}
+
+/*
+SetAllocatable sets the allocatable device IDs of a resource, replacing those set before.
+*/
+func (s *Server) SetAllocatable(resourceName string, deviceIds []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, devices := range s.allocatable {
+		if devices.ResourceName == resourceName {
+			devices.DeviceIds = deviceIds
+			return
+		}
+	}
+	s.allocatable = append(s.allocatable, &api.ContainerDevices{ResourceName: resourceName, DeviceIds: deviceIds})
+}
+
+/*
+SetError sets an error returned by every call of a method, such as a status with the Unimplemented
+code to mock a kubelet without the Get endpoint or feature gate. A nil error clears it.
+*/
+func (s *Server) SetError(method string, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err == nil {
+		delete(s.errs, method)
+		return
+	}
+	s.errs[method] = err
+}
+
+/*
+SetDelay sets how long every call takes before it is answered, to mock a loaded kubelet.
+*/
+func (s *Server) SetDelay(delay time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.delay = delay
+}
+
+/*
+Calls returns the number of calls made to a method, including those that returned an error.
+*/
+func (s *Server) Calls(method string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.calls[method]
+}
+
+/*
+call counts a call to a method and returns the error set for it, once the delay has passed.
+*/
+func (s *Server) call(method string) error {
+	s.lock.Lock()
+	s.calls[method]++
+	err := s.errs[method]
+	delay := s.delay
+	s.lock.Unlock()
+
+	time.Sleep(delay)
+	return err
+}
+
+/*
+List returns every pod.
+*/
+func (s *Server) List(ctx context.Context, req *api.ListPodResourcesRequest) (*api.ListPodResourcesResponse, error) {
+	if err := s.call(MethodList); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pods := make([]*api.PodResources, len(s.pods))
+	copy(pods, s.pods)
+	return &api.ListPodResourcesResponse{PodResources: pods}, nil
+}
+
+/*
+get returns a single pod, or a NotFound status if there is no such pod.
+*/
+func (s *Server) get(ctx context.Context, req *getRequest) (*getResponse, error) {
+	if err := s.call(MethodGet); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, pod := range s.pods {
+		if pod.Name == req.podName && pod.Namespace == req.podNamespace {
+			return &getResponse{pod: pod}, nil
+		}
+	}
+	return nil, status.Errorf(codes.NotFound, "pod %s in namespace %s not found", req.podName, req.podNamespace)
+}
+
+/*
+GetAllocatableResources returns the allocatable devices.
+*/
+func (s *Server) GetAllocatableResources(ctx context.Context, req *api.AllocatableResourcesRequest) (*api.AllocatableResourcesResponse, error) {
+	if err := s.call(MethodAllocatable); err != nil {
+		return nil, err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	devices := make([]*api.ContainerDevices, len(s.allocatable))
+	copy(devices, s.allocatable)
+	return &api.AllocatableResourcesResponse{Devices: devices}, nil
+}
+
+/*
+serviceDesc describes the pod resources api, including the Get endpoint missing from the
+podresources package in use.
+*/
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: "v1.PodResourcesLister",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: MethodList,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &api.ListPodResourcesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return srv.(*Server).List(ctx, req)
+			},
+		},
+		{
+			MethodName: MethodGet,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &getRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return srv.(*Server).get(ctx, req)
+			},
+		},
+		{
+			MethodName: MethodAllocatable,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := &api.AllocatableResourcesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return srv.(*Server).GetAllocatableResources(ctx, req)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{},
+}
+
+/*
+getRequest is the GetPodResourcesRequest message: pod_name = 1, pod_namespace = 2.
+*/
+type getRequest struct {
+	podName      string
+	podNamespace string
+}
+
+/*
+getResponse is the GetPodResourcesResponse message: pod_resources = 1.
+*/
+type getResponse struct {
+	pod *api.PodResources
+}
+
+/*
+message is implemented by the messages of the podresources package.
+*/
+type message interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+/*
+codec encodes the messages of the podresources package with their own methods, and the Get
+messages in the protobuf wire format.
+*/
+type codec struct{}
+
+func (codec) Name() string {
+	return "proto"
+}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	switch msg := v.(type) {
+	case message:
+		return msg.Marshal()
+	case *getResponse:
+		pod, err := msg.pod.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		data := appendUvarint(nil, 1<<3|2)
+		data = appendUvarint(data, uint64(len(pod)))
+		return append(data, pod...), nil
+	default:
+		return nil, fmt.Errorf("unexpected pod resources message type %T", v)
+	}
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	switch msg := v.(type) {
+	case message:
+		return msg.Unmarshal(data)
+	case *getRequest:
+		for len(data) > 0 {
+			key, n := binary.Uvarint(data)
+			if n <= 0 || key&7 != 2 {
+				return errors.New("invalid field in pod resources Get request")
+			}
+			data = data[n:]
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errors.New("invalid length in pod resources Get request")
+			}
+			value := string(data[n : n+int(length)])
+			data = data[n+int(length):]
+
+			switch key >> 3 {
+			case 1:
+				msg.podName = value
+			case 2:
+				msg.podNamespace = value
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unexpected pod resources message type %T", v)
+	}
+}
+
+func appendUvarint(data []byte, v uint64) []byte {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, v)
+	return append(data, buf[:n]...)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package podrestest
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+func dialServer(t *testing.T, s *Server) api.PodResourcesListerClient {
+	conn, err := grpc.Dial(s.Socket, grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return api.NewPodResourcesListerClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+	client := dialServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	s.AddPod("pod-1", "default", "afxdp/myPool", []string{"ens801f0"})
+	s.AddPod("pod-2", "default", "afxdp/myPool", []string{"ens801f1"})
+	s.AddPod("pod-1", "default", "afxdp/myPool", []string{"ens801f2"})
+	s.RemovePod("pod-2", "default")
+	s.SetAllocatable("afxdp/myPool", []string{"ens801f0", "ens801f1"})
+	s.SetAllocatable("afxdp/myPool", []string{"ens801f0", "ens801f1", "ens801f2"})
+
+	list, err := client.List(ctx, &api.ListPodResourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetPodResources(), 1)
+	pod := list.GetPodResources()[0]
+	assert.Equal(t, "pod-1", pod.GetName())
+	assert.Equal(t, "default", pod.GetNamespace())
+	assert.Equal(t, []string{"ens801f2"}, pod.GetContainers()[0].GetDevices()[0].GetDeviceIds())
+
+	allocatable, err := client.GetAllocatableResources(ctx, &api.AllocatableResourcesRequest{})
+	require.NoError(t, err)
+	require.Len(t, allocatable.GetDevices(), 1)
+	assert.Equal(t, []string{"ens801f0", "ens801f1", "ens801f2"}, allocatable.GetDevices()[0].GetDeviceIds())
+
+	s.SetError(MethodAllocatable, status.Error(codes.Unimplemented, "feature gate disabled"))
+	_, err = client.GetAllocatableResources(ctx, &api.AllocatableResourcesRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	s.SetError(MethodAllocatable, nil)
+	_, err = client.GetAllocatableResources(ctx, &api.AllocatableResourcesRequest{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, s.Calls(MethodList))
+	assert.Equal(t, 3, s.Calls(MethodAllocatable))
+	assert.Equal(t, 0, s.Calls(MethodGet))
+}
+
+func TestServerRestart(t *testing.T) {
+	s, err := NewServer()
+	require.NoError(t, err)
+	defer s.Close()
+	client := dialServer(t, s)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.List(ctx, &api.ListPodResourcesRequest{}, grpc.WaitForReady(true))
+	require.NoError(t, err)
+
+	require.NoError(t, s.Restart())
+	_, err = client.List(ctx, &api.ListPodResourcesRequest{}, grpc.WaitForReady(true))
+	assert.NoError(t, err, "server not reachable after restart")
+	assert.Equal(t, 2, s.Calls(MethodList))
+}
+
+func TestCodecGet(t *testing.T) {
+	var req getRequest
+	data := []byte{1<<3 | 2, 5, 'p', 'o', 'd', '-', '1', 2<<3 | 2, 7, 'd', 'e', 'f', 'a', 'u', 'l', 't'}
+	require.NoError(t, codec{}.Unmarshal(data, &req))
+	assert.Equal(t, getRequest{podName: "pod-1", podNamespace: "default"}, req)
+
+	assert.Error(t, codec{}.Unmarshal([]byte{1<<3 | 2, 9, 'p'}, &req), "truncated field accepted")
+	assert.Error(t, codec{}.Unmarshal([]byte{1 << 3, 1}, &req), "varint field accepted")
+
+	pod := &api.PodResources{Name: "pod-1", Namespace: "default"}
+	data, err := codec{}.Marshal(&getResponse{pod: pod})
+	require.NoError(t, err)
+	encoded, err := pod.Marshal()
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{1<<3 | 2, byte(len(encoded))}, encoded...), data)
+}
@@ -16,20 +16,25 @@
 package resourcesapi
 
 import (
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	logging "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
 	api "k8s.io/kubelet/pkg/apis/podresources/v1"
 	"net"
+	"os"
 	"time"
 )
 
-const (
-	podResSockDir  = "/var/lib/kubelet/pod-resources"
-	podResSockPath = podResSockDir + "/kubelet.sock"
-	grpcTimeout    = 5 * time.Second
-)
+/*
+grpcTimeout is how long to wait for a pod resources API call, including connecting to the kubelet.
+*/
+var grpcTimeout = time.Duration(constants.PodResources.Timeout) * time.Second
+
+var podResSockPath = constants.PodResources.DefaultSocket
 
 /*
 Handler is the device plugins interface to the K8s pod resources API.
@@ -38,6 +43,9 @@ against a fake API.
 */
 type Handler interface {
 	GetPodResources() (map[string]api.PodResources, error)
+	GetPodResource(podName string, namespace string) (*api.PodResources, error)
+	GetAllocatableDevices() (map[string][]string, error)
+	InvalidatePodResources()
 }
 
 /*
@@ -53,27 +61,128 @@ func NewHandler() Handler {
 }
 
 /*
-GetPodResources calls the pod resources api and returns a map of pods and associated devices
+SetSocketPath sets the path of the kubelet pod resources socket, for kubelets with a root
+directory other than /var/lib/kubelet. It must be called before any handler is used.
+*/
+func SetSocketPath(path string) {
+	podResSockPath = path
+}
+
+/*
+SetTimeout sets how long to wait for a pod resources API call, including connecting to the
+kubelet. It must be called before any handler is used.
+*/
+func SetTimeout(t time.Duration) {
+	grpcTimeout = t
+}
+
+/*
+GetPodResources returns a map of pods and associated devices, keyed on namespace/name as pod
+names are only unique within a namespace. Responses from the pod resources api are shared by all
+handlers. While pods are tracked, see StartPodTracking, they are kept current in memory and no
+call to the kubelet is made. Otherwise they are cached for a short time, so a burst of UDS
+handshakes, such as during a deployment rollout, results in a single call to the kubelet.
 */
 func (r *handler) GetPodResources() (map[string]api.PodResources, error) {
+	return sharedCache.get()
+}
+
+/*
+InvalidatePodResources discards cached pod resources, so the next GetPodResources calls the
+pod resources api. Used when the cache may predate a pod, such as a pod that was not found.
+*/
+func (r *handler) InvalidatePodResources() {
+	sharedCache.invalidate()
+}
+
+/*
+listPodResources calls the pod resources api, retrying with backoff, and returns a map of pods
+and associated devices, keyed on namespace/name
+*/
+func listPodResources() (map[string]api.PodResources, error) {
 	podResourceMap := make(map[string]api.PodResources)
 
-	resp, err := getPodResources(podResSockPath)
+	var resp *api.ListPodResourcesResponse
+	err := podResBackoff.retry("List", func() (err error) {
+		resp, err = getPodResources(sharedConn)
+		return err
+	})
 	if err != nil {
 		logging.Errorf("Error Getting pod resources: %v", err)
 		return podResourceMap, err
 	}
 
 	for _, pod := range resp.GetPodResources() {
-		podResourceMap[pod.GetName()] = *pod
+		podResourceMap[pod.GetNamespace()+"/"+pod.GetName()] = *pod
 	}
 
 	return podResourceMap, nil
 }
 
-func getPodResources(socket string) (*api.ListPodResourcesResponse, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
-	defer cancel()
+/*
+Probe calls the pod resources api once, without retrying or caching, returning an error if the
+kubelet pod resources socket cannot be used.
+*/
+func Probe() error {
+	_, err := getPodResources(sharedConn)
+	return err
+}
+
+/*
+GetAllocatableDevices calls the pod resources api, retrying with backoff, and returns a map of resource names and the
+IDs of the devices the kubelet considers allocatable for each, whether allocated or not.
+*/
+func (r *handler) GetAllocatableDevices() (map[string][]string, error) {
+	allocatable := make(map[string][]string)
+
+	var resp *api.AllocatableResourcesResponse
+	err := podResBackoff.retry("GetAllocatableResources", func() (err error) {
+		resp, err = getAllocatableResources(sharedConn)
+		return err
+	})
+	if err != nil {
+		return allocatable, err
+	}
+
+	for _, devices := range resp.GetDevices() {
+		allocatable[devices.GetResourceName()] = append(allocatable[devices.GetResourceName()], devices.GetDeviceIds()...)
+	}
+
+	return allocatable, nil
+}
+
+/*
+IsUnimplemented returns true if an error from the pod resources api means the endpoint is not
+implemented by the kubelet, for example GetAllocatableResources without its feature gate enabled.
+*/
+func IsUnimplemented(err error) bool {
+	return status.Code(err) == codes.Unimplemented
+}
+
+func getAllocatableResources(conn *podResConn) (*api.AllocatableResourcesResponse, error) {
+	var resp *api.AllocatableResourcesResponse
+
+	err := conn.invoke("GetAllocatableResources", func(ctx context.Context, cc *grpc.ClientConn) (err error) {
+		logging.Debugf("Requesting allocatable resources")
+		resp, err = api.NewPodResourcesListerClient(cc).GetAllocatableResources(ctx, &api.AllocatableResourcesRequest{})
+		return err
+	})
+	if err != nil {
+		if !IsUnimplemented(err) {
+			logging.Errorf("Error getting allocatable resources: %v", err)
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func dial(ctx context.Context, socket string) (*grpc.ClientConn, error) {
+	// fail fast rather than block until the timeout when the kubelet socket is missing or not mounted
+	if _, err := os.Stat(socket); err != nil {
+		logging.Errorf("Error connecting to Pod Resource API: %v", err)
+		return nil, err
+	}
 
 	logging.Debugf("Opening Pod Resource API connection")
 	conn, err := grpc.DialContext(ctx, socket, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock(),
@@ -83,18 +192,20 @@ func getPodResources(socket string) (*api.ListPodResourcesResponse, error) {
 	)
 	if err != nil {
 		logging.Errorf("Error connecting to Pod Resource API: %v", err)
-		conn.Close()
 		return nil, err
 	}
-	defer func() {
-		logging.Debugf("Closing Pod Resource API connection")
-		conn.Close()
-	}()
 
-	logging.Debugf("Requesting pod resource list")
-	client := api.NewPodResourcesListerClient(conn)
+	return conn, nil
+}
+
+func getPodResources(conn *podResConn) (*api.ListPodResourcesResponse, error) {
+	var resp *api.ListPodResourcesResponse
 
-	resp, err := client.List(ctx, &api.ListPodResourcesRequest{})
+	err := conn.invoke("List", func(ctx context.Context, cc *grpc.ClientConn) (err error) {
+		logging.Debugf("Requesting pod resource list")
+		resp, err = api.NewPodResourcesListerClient(cc).List(ctx, &api.ListPodResourcesRequest{})
+		return err
+	})
 	if err != nil {
 		logging.Errorf("Error getting Pod Resource list: %v", err)
 		return nil, err
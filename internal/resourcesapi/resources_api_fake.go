@@ -16,6 +16,8 @@
 package resourcesapi
 
 import (
+	"fmt"
+
 	api "k8s.io/kubelet/pkg/apis/podresources/v1"
 )
 
@@ -25,6 +27,9 @@ FakeHandler interface extends the Handler interface to provide additional testin
 type FakeHandler interface {
 	Handler
 	CreateFakePod(podName string, namespace string, resourceName string, deviceIds []string)
+	SetAllocatableDevices(resourceName string, deviceIds []string)
+	SetPodResourcesError(err error)
+	SetGetPodResourceError(err error)
 }
 
 /*
@@ -35,6 +40,9 @@ type fakeHandler struct {
 	namespace    string
 	resourceName string
 	deviceIds    []string
+	allocatable  map[string][]string
+	podResErr    error
+	getErr       error
 }
 
 /*
@@ -51,6 +59,10 @@ This pod does not come from the pod resources API, but instead is configurable t
 CreateFakePod function to give a predetermined response.
 */
 func (f *fakeHandler) GetPodResources() (map[string]api.PodResources, error) {
+	if f.podResErr != nil {
+		return make(map[string]api.PodResources), f.podResErr
+	}
+
 	fakePod := api.PodResources{
 		Name:      f.podName,
 		Namespace: f.namespace,
@@ -68,7 +80,7 @@ func (f *fakeHandler) GetPodResources() (map[string]api.PodResources, error) {
 	}
 
 	podResourceMap := make(map[string]api.PodResources)
-	podResourceMap[f.podName] = fakePod
+	podResourceMap[f.namespace+"/"+f.podName] = fakePod
 
 	return podResourceMap, nil
 }
@@ -84,3 +96,70 @@ func (f *fakeHandler) CreateFakePod(podName string, namespace string, resourceNa
 	f.resourceName = resourceName
 	f.deviceIds = deviceIds
 }
+
+/*
+GetPodResource returns the resources of a single pod.
+In this FakeHandler, it returns the fake pod if the name and namespace match, or the error set by
+SetGetPodResourceError.
+*/
+func (f *fakeHandler) GetPodResource(podName string, namespace string) (*api.PodResources, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+
+	pods, err := f.GetPodResources()
+	if err != nil {
+		return nil, err
+	}
+	pod, ok := pods[namespace+"/"+podName]
+	if !ok {
+		return nil, fmt.Errorf("pod %s/%s not found in pod resources", namespace, podName)
+	}
+
+	return &pod, nil
+}
+
+/*
+SetGetPodResourceError sets an error to be returned by GetPodResource, such as an Unimplemented
+status for kubelets without the Get endpoint.
+*/
+func (f *fakeHandler) SetGetPodResourceError(err error) {
+	f.getErr = err
+}
+
+/*
+SetPodResourcesError sets an error to be returned by GetPodResources, simulating an unavailable
+pod resources API. A nil error restores the fake pod.
+*/
+func (f *fakeHandler) SetPodResourcesError(err error) {
+	f.podResErr = err
+}
+
+/*
+GetAllocatableDevices returns a map of resource names and their allocatable device IDs.
+In this FakeHandler, the allocatable devices are those set by SetAllocatableDevices.
+*/
+func (f *fakeHandler) GetAllocatableDevices() (map[string][]string, error) {
+	allocatable := make(map[string][]string)
+	for resourceName, deviceIds := range f.allocatable {
+		allocatable[resourceName] = deviceIds
+	}
+	return allocatable, nil
+}
+
+/*
+SetAllocatableDevices sets the allocatable device IDs of a resource, as returned by GetAllocatableDevices.
+*/
+func (f *fakeHandler) SetAllocatableDevices(resourceName string, deviceIds []string) {
+	if f.allocatable == nil {
+		f.allocatable = make(map[string][]string)
+	}
+	f.allocatable[resourceName] = deviceIds
+}
+
+/*
+InvalidatePodResources discards cached pod resources.
+In this FakeHandler there is no cache, so it does nothing.
+*/
+func (f *fakeHandler) InvalidatePodResources() {
+}
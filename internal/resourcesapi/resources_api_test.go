@@ -0,0 +1,101 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi/podrestest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+startKubelet starts a mock kubelet and points the shared connection at it until the test ends.
+*/
+func startKubelet(t *testing.T) *podrestest.Server {
+	kubelet, err := podrestest.NewServer()
+	require.NoError(t, err, "Can't start mock kubelet")
+
+	SetSocketPath(kubelet.Socket)
+	t.Cleanup(func() {
+		Close()
+		sharedCache.invalidate()
+		atomic.StoreInt32(&getUnimplemented, 0)
+		SetSocketPath(constants.PodResources.DefaultSocket)
+		kubelet.Close()
+	})
+
+	return kubelet
+}
+
+func TestHandlerMockKubelet(t *testing.T) {
+	kubelet := startKubelet(t)
+	kubelet.AddPod("pod-1", "default", "afxdp/myPool", []string{"ens801f0"})
+	kubelet.SetAllocatable("afxdp/myPool", []string{"ens801f0", "ens801f1"})
+	handler := NewHandler()
+
+	assert.NoError(t, Probe())
+
+	pods, err := handler.GetPodResources()
+	require.NoError(t, err)
+	require.Contains(t, pods, "default/pod-1")
+	assert.Equal(t, []string{"ens801f0"}, pods["default/pod-1"].GetContainers()[0].GetDevices()[0].GetDeviceIds())
+
+	pod, err := handler.GetPodResource("pod-1", "default")
+	require.NoError(t, err)
+	assert.Equal(t, "pod-1", pod.GetName())
+	_, err = handler.GetPodResource("pod-2", "default")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	allocatable, err := handler.GetAllocatableDevices()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"afxdp/myPool": {"ens801f0", "ens801f1"}}, allocatable)
+
+	kubelet.SetError(podrestest.MethodList, status.Error(codes.PermissionDenied, "socket not readable"))
+	handler.InvalidatePodResources()
+	_, err = handler.GetPodResources()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Error(t, Probe())
+}
+
+func TestHandlerGetUnimplemented(t *testing.T) {
+	kubelet := startKubelet(t)
+	kubelet.AddPod("pod-1", "default", "afxdp/myPool", []string{"ens801f0"})
+	kubelet.SetError(podrestest.MethodGet, status.Error(codes.Unimplemented, "unknown method Get"))
+	handler := NewHandler()
+
+	for i := 0; i < 2; i++ {
+		_, err := handler.GetPodResource("pod-1", "default")
+		assert.True(t, IsUnimplemented(err), "Expected Get to be unimplemented")
+	}
+	assert.Equal(t, 1, kubelet.Calls(podrestest.MethodGet), "Get called again after it was unimplemented")
+}
+
+func TestPodResConnKubeletRestart(t *testing.T) {
+	kubelet := startKubelet(t)
+
+	require.NoError(t, Probe())
+	require.NoError(t, kubelet.Restart())
+
+	assert.True(t, sharedConn.checkHealth(), "Connection not reopened after kubelet restart")
+	assert.NoError(t, Probe())
+	assert.Equal(t, 2, kubelet.Calls(podrestest.MethodList))
+}
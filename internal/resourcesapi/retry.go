@@ -0,0 +1,89 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var retrySleep = time.Sleep // replaced in unit tests
+
+/*
+backoff describes how calls to the pod resources api are retried.
+*/
+type backoff struct {
+	attempts  int
+	baseDelay time.Duration
+	maxDelay  time.Duration
+}
+
+var podResBackoff = backoff{
+	attempts:  constants.PodResources.RetryAttempts,
+	baseDelay: time.Duration(constants.PodResources.RetryBaseDelay) * time.Millisecond,
+	maxDelay:  time.Duration(constants.PodResources.RetryMaxDelay) * time.Millisecond,
+}
+
+/*
+retry calls fn until it succeeds, returns an error that cannot be resolved by retrying, or the
+attempts run out. A kubelet restart or a slow kubelet briefly makes the pod resources api
+unavailable, and retrying keeps such a blip from failing a pod handshake.
+*/
+func (b backoff) retry(call string, fn func() error) error {
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) || attempt >= b.attempts {
+			return err
+		}
+
+		delay := b.delay(attempt, rand.Float64())
+		logging.Warningf("Pod resources %s failed, attempt %d of %d, retrying in %v: %v", call, attempt, b.attempts, delay, err)
+		retrySleep(delay)
+	}
+}
+
+/*
+delay returns the time to wait after a failed attempt, counting from 1. The delay doubles with
+each attempt up to maxDelay, and jitter, between 0 and 1, randomises the upper half of the delay
+so that UDS servers failing together do not retry together.
+*/
+func (b backoff) delay(attempt int, jitter float64) time.Duration {
+	delay := b.maxDelay
+	if shift := attempt - 1; shift < 32 && b.baseDelay<<shift < b.maxDelay {
+		delay = b.baseDelay << shift
+	}
+
+	return delay/2 + time.Duration(jitter*float64(delay/2))
+}
+
+/*
+isRetryable returns false for errors from the pod resources api that will not change on retry.
+*/
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unimplemented, codes.InvalidArgument, codes.PermissionDenied, codes.Unauthenticated:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestBackoffRetry(t *testing.T) {
+	testCases := []struct {
+		name      string
+		errs      []error
+		expCalls  int
+		expSleeps int
+		expErr    bool
+	}{
+		{
+			name:      "success first attempt",
+			errs:      []error{nil},
+			expCalls:  1,
+			expSleeps: 0,
+			expErr:    false,
+		},
+		{
+			name:      "success after transient errors",
+			errs:      []error{status.Error(codes.Unavailable, "kubelet restarting"), errors.New("connection refused"), nil},
+			expCalls:  3,
+			expSleeps: 2,
+			expErr:    false,
+		},
+		{
+			name:      "attempts exhausted",
+			errs:      []error{status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down"), status.Error(codes.Unavailable, "down")},
+			expCalls:  3,
+			expSleeps: 2,
+			expErr:    true,
+		},
+		{
+			name:      "unimplemented not retried",
+			errs:      []error{status.Error(codes.Unimplemented, "not implemented"), nil},
+			expCalls:  1,
+			expSleeps: 0,
+			expErr:    true,
+		},
+		{
+			name:      "permission denied not retried",
+			errs:      []error{status.Error(codes.PermissionDenied, "denied"), nil},
+			expCalls:  1,
+			expSleeps: 0,
+			expErr:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sleeps int
+			retrySleep = func(time.Duration) { sleeps++ }
+			defer func() { retrySleep = time.Sleep }()
+
+			b := backoff{attempts: 3, baseDelay: time.Millisecond, maxDelay: 10 * time.Millisecond}
+
+			var calls int
+			err := b.retry("List", func() error {
+				err := tc.errs[calls]
+				calls++
+				return err
+			})
+
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+			} else {
+				assert.NoError(t, err, "Unexpected error")
+			}
+			assert.Equal(t, tc.expCalls, calls, "Unexpected number of calls")
+			assert.Equal(t, tc.expSleeps, sleeps, "Unexpected number of retries")
+		})
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := backoff{attempts: 10, baseDelay: 100 * time.Millisecond, maxDelay: time.Second}
+
+	testCases := []struct {
+		name     string
+		attempt  int
+		jitter   float64
+		expDelay time.Duration
+	}{
+		{name: "first attempt no jitter", attempt: 1, jitter: 0, expDelay: 50 * time.Millisecond},
+		{name: "first attempt full jitter", attempt: 1, jitter: 1, expDelay: 100 * time.Millisecond},
+		{name: "second attempt doubles", attempt: 2, jitter: 1, expDelay: 200 * time.Millisecond},
+		{name: "third attempt half jitter", attempt: 3, jitter: 0.5, expDelay: 300 * time.Millisecond},
+		{name: "capped at max delay", attempt: 5, jitter: 1, expDelay: time.Second},
+		{name: "large attempt does not overflow", attempt: 100, jitter: 0, expDelay: 500 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expDelay, b.delay(tc.attempt, tc.jitter), "Unexpected delay")
+		})
+	}
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+StartPodTracking keeps the pod resources shared by all handlers current until the stop channel
+is closed, so validating a pod is an in memory lookup rather than a call to the kubelet.
+The pod resources api has no watch, so changes to the kubelet device checkpoint, rewritten
+whenever devices are assigned to or released from pods, are watched instead, and the pod
+resources are reconciled periodically to pick up anything missed. The health of the shared
+connection to the kubelet is also checked periodically, and the connection closed on stop.
+*/
+func StartPodTracking(stop <-chan struct{}) {
+	progress.start(time.Now())
+	tracker := &podTracker{
+		cache:      sharedCache,
+		checkpoint: constants.PodResources.CheckpointFile,
+	}
+	go tracker.run(stop,
+		time.Duration(constants.PodResources.ReconcileInterval)*time.Second,
+		time.Duration(constants.PodResources.CheckpointPoll)*time.Second)
+	go sharedConn.monitor(stop, time.Duration(constants.PodResources.HealthCheckInterval)*time.Second)
+}
+
+/*
+podTracker refreshes a podResourcesCache on device assignment changes and periodically.
+*/
+type podTracker struct {
+	cache      *podResourcesCache
+	checkpoint string
+	modTime    time.Time
+}
+
+func (t *podTracker) run(stop <-chan struct{}, reconcileInterval time.Duration, pollInterval time.Duration) {
+	t.cache.setTracking(true)
+	defer t.cache.setTracking(false)
+
+	t.checkpointChanged()
+	t.refresh("initial sync")
+
+	reconcile := time.NewTicker(reconcileInterval)
+	defer reconcile.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-reconcile.C:
+			t.refresh("reconcile")
+		case <-poll.C:
+			if t.checkpointChanged() {
+				t.refresh("device assignment change")
+			}
+		}
+		progress.tracked(time.Now())
+	}
+}
+
+func (t *podTracker) refresh(reason string) {
+	if err := t.cache.refresh(); err != nil {
+		logging.Warningf("Error refreshing tracked pod resources (%s): %v", reason, err)
+		return
+	}
+	logging.Debugf("Refreshed tracked pod resources (%s)", reason)
+}
+
+/*
+checkpointChanged returns true if the modification time of the kubelet device checkpoint
+differs from when last checked. A checkpoint that cannot be read is reported unchanged,
+leaving the pod resources to be reconciled periodically.
+*/
+func (t *podTracker) checkpointChanged() bool {
+	info, err := os.Stat(t.checkpoint)
+	if err != nil {
+		return false
+	}
+
+	changed := !info.ModTime().Equal(t.modTime)
+	t.modTime = info.ModTime()
+
+	return changed
+}
+
+/*
+CheckHealth returns an error if pod tracking has stalled, or if the last health check of the
+shared connection found it broken and could not reopen it. It is checked by the liveness probe
+of the device plugin, once StartPodTracking has been called.
+*/
+func CheckHealth() error {
+	return progress.check(time.Now(), time.Duration(constants.PodResources.StallAfter)*time.Second)
+}
+
+/*
+progress records the progress of the pod tracking Go routines.
+*/
+var progress = &trackingProgress{}
+
+/*
+trackingProgress records when the pod tracker and the connection health checks last ran, and
+the result of the last connection health check.
+*/
+type trackingProgress struct {
+	lock        sync.Mutex
+	lastTracked time.Time
+	lastChecked time.Time
+	connHealthy bool
+}
+
+func (p *trackingProgress) start(now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.lastTracked = now
+	p.lastChecked = now
+	p.connHealthy = true
+}
+
+func (p *trackingProgress) tracked(now time.Time) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.lastTracked = now
+}
+
+func (p *trackingProgress) checked(now time.Time, healthy bool) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.lastChecked = now
+	p.connHealthy = healthy
+}
+
+func (p *trackingProgress) check(now time.Time, stallAfter time.Duration) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if now.Sub(p.lastTracked) > stallAfter {
+		return fmt.Errorf("pod tracking stalled, last ran %s ago", now.Sub(p.lastTracked).Round(time.Second))
+	}
+	if now.Sub(p.lastChecked) > stallAfter {
+		return fmt.Errorf("pod resources API health checks stalled, last ran %s ago", now.Sub(p.lastChecked).Round(time.Second))
+	}
+	if !p.connHealthy {
+		return fmt.Errorf("pod resources API unreachable at %s", podResSockPath)
+	}
+
+	return nil
+}
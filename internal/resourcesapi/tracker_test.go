@@ -0,0 +1,174 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resourcesapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+func TestPodTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "kubelet_internal_checkpoint")
+	require.NoError(t, ioutil.WriteFile(checkpoint, []byte("{}"), 0600), "Can't create checkpoint")
+
+	var lock sync.Mutex
+	fetches := 0
+	pods := map[string]api.PodResources{"default/pod-1": {Name: "pod-1", Namespace: "default"}}
+	fetch := func() (map[string]api.PodResources, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		fetches++
+		copied := make(map[string]api.PodResources)
+		for name, pod := range pods {
+			copied[name] = pod
+		}
+		return copied, nil
+	}
+	fetchCount := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return fetches
+	}
+
+	// a short TTL, which tracking must override
+	cache := newPodResourcesCache(time.Millisecond, fetch)
+	tracker := &podTracker{cache: cache, checkpoint: checkpoint}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		tracker.run(stop, time.Hour, 10*time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool { return fetchCount() == 1 }, time.Second, 5*time.Millisecond, "Tracker should sync on start")
+
+	time.Sleep(20 * time.Millisecond)
+	got, err := cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Contains(t, got, "default/pod-1", "Tracked pod missing")
+	assert.Equal(t, 1, fetchCount(), "Tracked pod resources should not expire")
+
+	lock.Lock()
+	pods["default/pod-2"] = api.PodResources{Name: "pod-2", Namespace: "default"}
+	lock.Unlock()
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(checkpoint, later, later), "Can't touch checkpoint")
+
+	require.Eventually(t, func() bool { return fetchCount() == 2 }, time.Second, 5*time.Millisecond, "Checkpoint change should refresh")
+	got, err = cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Contains(t, got, "default/pod-2", "New pod missing after checkpoint change")
+
+	close(stop)
+	<-done
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 3, fetchCount(), "Pod resources should expire once tracking stops")
+}
+
+func TestPodResourcesCacheRefresh(t *testing.T) {
+	var fetchErr error
+	fetches := 0
+	fetch := func() (map[string]api.PodResources, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return map[string]api.PodResources{"default/pod-1": {Name: "pod-1", Namespace: "default"}}, nil
+	}
+
+	cache := newPodResourcesCache(time.Hour, fetch)
+	cache.setTracking(true)
+
+	require.NoError(t, cache.refresh(), "Unexpected error")
+	_, err := cache.get()
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, 1, fetches, "Get after refresh should use the refreshed pod resources")
+
+	fetchErr = errors.New("kubelet unavailable")
+	assert.Error(t, cache.refresh(), "Refresh error should be returned")
+	_, err = cache.get()
+	assert.Error(t, err, "Failed refresh should discard the pod resources, so get fetches")
+	assert.Equal(t, 3, fetches, "Unexpected number of fetches")
+}
+
+func TestTrackingProgressCheck(t *testing.T) {
+	start := time.Now()
+	stallAfter := time.Minute
+
+	testCases := []struct {
+		name        string
+		tracked     time.Time
+		checked     time.Time
+		connHealthy bool
+		expErr      bool
+	}{
+		{
+			name:        "healthy",
+			tracked:     start.Add(50 * time.Second),
+			checked:     start.Add(40 * time.Second),
+			connHealthy: true,
+		},
+		{
+			name:        "tracker stalled",
+			tracked:     start,
+			checked:     start.Add(80 * time.Second),
+			connHealthy: true,
+			expErr:      true,
+		},
+		{
+			name:        "health checks stalled",
+			tracked:     start.Add(80 * time.Second),
+			checked:     start,
+			connHealthy: true,
+			expErr:      true,
+		},
+		{
+			name:        "kubelet unreachable",
+			tracked:     start.Add(80 * time.Second),
+			checked:     start.Add(80 * time.Second),
+			connHealthy: false,
+			expErr:      true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &trackingProgress{}
+			p.start(start)
+			p.tracked(tc.tracked)
+			p.checked(tc.checked, tc.connHealthy)
+
+			err := p.check(start.Add(90*time.Second), stallAfter)
+			assert.Equal(t, tc.expErr, err != nil, "Unexpected error: %v", err)
+		})
+	}
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selinux
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+fsPath is where the SELinux filesystem is mounted, changed by tests.
+*/
+var fsPath = constants.Selinux.FsPath
+
+/*
+label is the SELinux context given to the sockets and directories created for pods, empty if
+they are left with the context inherited from their parent directory.
+*/
+var label string
+
+/*
+Enabled returns true if SELinux is enabled on the host, in either enforcing or permissive mode.
+*/
+func Enabled() bool {
+	_, err := ioutil.ReadFile(filepath.Join(fsPath, "enforce"))
+	return err == nil
+}
+
+/*
+Enforcing returns true if SELinux is enabled on the host and enforcing its policy.
+*/
+func Enforcing() bool {
+	enforce, err := ioutil.ReadFile(filepath.Join(fsPath, "enforce"))
+	return err == nil && strings.TrimSpace(string(enforce)) == "1"
+}
+
+/*
+SetLabel sets the SELinux context given to the sockets and directories created for pods.
+It must be called before any socket is created.
+*/
+func SetLabel(context string) {
+	label = context
+}
+
+/*
+Label returns the SELinux context given to the sockets and directories created for pods, or an
+empty string if none is set.
+*/
+func Label() string {
+	return label
+}
+
+/*
+Apply gives path the configured SELinux context, so that confined container processes are
+allowed to use it. It does nothing if no context is set.
+*/
+func Apply(path string) error {
+	if label == "" {
+		return nil
+	}
+	return SetFileLabel(path, label)
+}
+
+/*
+SetFileLabel sets the SELinux context of path.
+*/
+func SetFileLabel(path string, context string) error {
+	// the kernel expects the context NUL terminated, as written by libselinux
+	if err := syscall.Setxattr(path, constants.Selinux.Xattr, []byte(context+"\x00"), 0); err != nil {
+		return fmt.Errorf("error setting SELinux context %s on %s: %w", context, path, err)
+	}
+	return nil
+}
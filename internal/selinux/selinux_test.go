@@ -0,0 +1,80 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package selinux
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnforcing(t *testing.T) {
+	testCases := []struct {
+		name         string
+		enforce      string
+		expEnabled   bool
+		expEnforcing bool
+	}{
+		{
+			name:         "enforcing",
+			enforce:      "1",
+			expEnabled:   true,
+			expEnforcing: true,
+		},
+		{
+			name:         "permissive",
+			enforce:      "0",
+			expEnabled:   true,
+			expEnforcing: false,
+		},
+		{
+			name:         "disabled",
+			expEnabled:   false,
+			expEnforcing: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "selinuxfs")
+			require.NoError(t, err, "Unexpected error")
+			defer os.RemoveAll(dir)
+			if tc.enforce != "" {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "enforce"), []byte(tc.enforce), 0644))
+			}
+
+			fsPath = dir
+			defer func() { fsPath = "/sys/fs/selinux" }()
+
+			assert.Equal(t, tc.expEnabled, Enabled(), "Unexpected enabled")
+			assert.Equal(t, tc.expEnforcing, Enforcing(), "Unexpected enforcing")
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	SetLabel("")
+	assert.NoError(t, Apply("/nonexistent"), "No label should leave the file untouched")
+
+	SetLabel("system_u:object_r:container_file_t:s0")
+	defer SetLabel("")
+	assert.Equal(t, "system_u:object_r:container_file_t:s0", Label(), "Unexpected label")
+	assert.Error(t, Apply("/nonexistent"), "Labelling a missing file should fail")
+}
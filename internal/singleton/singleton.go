@@ -0,0 +1,97 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package singleton
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+/*
+Lock is a node-level lock held by the running device plugin, an exclusive flock on a lock file.
+The lock is released by the kernel when the process exits, so a crashed device plugin never
+leaves the node locked.
+*/
+type Lock struct {
+	file *os.File
+}
+
+/*
+Acquire takes the lock on the file at path, creating it if needed, without blocking. If another
+process holds the lock, an error naming that process is returned. The lock file records the
+holder, the hostname and pid of this process, for the error of the next process to try.
+*/
+func Acquire(path string) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error opening lock file %s", path)
+	}
+
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		file.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, fmt.Errorf("another device plugin is already running on this node, %s holds lock file %s", holder(path), path)
+		}
+		return nil, errors.Wrapf(err, "error locking lock file %s", path)
+	}
+
+	hostname, _ := os.Hostname()
+	if err := file.Truncate(0); err == nil {
+		file.WriteAt([]byte(fmt.Sprintf("%s %d\n", hostname, os.Getpid())), 0)
+	}
+
+	return &Lock{file: file}, nil
+}
+
+/*
+Release releases the lock and closes the lock file. The lock file is left in place, as removing
+it could let two processes lock different files of the same path.
+*/
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	defer func() { l.file = nil }()
+
+	if err := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN); err != nil {
+		l.file.Close()
+		return errors.Wrapf(err, "error unlocking lock file %s", l.file.Name())
+	}
+
+	return l.file.Close()
+}
+
+/*
+holder returns a description of the process holding the lock file at path, as recorded in it.
+*/
+func holder(path string) string {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "an unknown process"
+	}
+
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return "an unknown process"
+	}
+
+	return fmt.Sprintf("pid %s on %s", fields[1], fields[0])
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package singleton
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquire(t *testing.T) {
+	dir, err := ioutil.TempDir("", "singleton")
+	require.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "afxdp-dp.lock")
+
+	// first instance holds the lock
+	first, err := Acquire(path)
+	require.NoError(t, err, "Unexpected error acquiring lock")
+
+	// second instance is refused, naming the holder
+	second, err := Acquire(path)
+	assert.Nil(t, second, "Second lock should not be acquired")
+	require.Error(t, err, "Error was expected acquiring a held lock")
+	assert.Contains(t, err.Error(), fmt.Sprintf("pid %d", os.Getpid()), "Error should name the holder")
+
+	// released lock can be acquired again
+	require.NoError(t, first.Release(), "Unexpected error releasing lock")
+	third, err := Acquire(path)
+	require.NoError(t, err, "Unexpected error acquiring released lock")
+	assert.NoError(t, third.Release(), "Unexpected error releasing lock")
+	assert.NoError(t, third.Release(), "Releasing twice should not error")
+}
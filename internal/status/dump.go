@@ -0,0 +1,100 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+State is the full internal state of the device plugin, dumped on SIGUSR1. It extends the
+status with the goroutine count and the contents of the pod resources cache.
+*/
+type State struct {
+	Time              time.Time         `json:"time"`
+	Status            *Status           `json:"status"`
+	Goroutines        int               `json:"goroutines"`
+	PodResourcesCache PodResourcesCache `json:"podResourcesCache"`
+}
+
+/*
+PodResourcesCache is the contents of the pod resources cache and when it was last fetched.
+*/
+type PodResourcesCache struct {
+	Fetched time.Time                `json:"fetched"`
+	Pods    []resourcesapi.CachedPod `json:"pods"`
+}
+
+/*
+Dump writes the internal state of the device plugin, followed by the stacks of all goroutines,
+to a timestamped file in dir and returns the path of the file. If the file cannot be written the
+dump is written to the log instead, so that it is not lost.
+*/
+func Dump(source Source, dir string) (string, error) {
+	now := time.Now()
+	pods, fetched := resourcesapi.CachedPods()
+	state := &State{
+		Time:       now,
+		Status:     collect(source),
+		Goroutines: runtime.NumGoroutine(),
+		PodResourcesCache: PodResourcesCache{
+			Fetched: fetched,
+			Pods:    pods,
+		},
+	}
+
+	out, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding state: %w", err)
+	}
+
+	var dump bytes.Buffer
+	fmt.Fprintf(&dump, "%s\n\n%s\n", out, stacks())
+
+	path := filepath.Join(dir, constants.Status.DumpFilePrefix+now.Format("20060102-150405")+".txt")
+	if err := ioutil.WriteFile(path, dump.Bytes(), os.FileMode(constants.Status.DumpPermissions)); err != nil {
+		logging.Warningf("Error writing state dump to %s, dumping to the log instead: %v", path, err)
+		logging.Infof("State dump:\n%s", dump.String())
+		return "", err
+	}
+
+	return path, nil
+}
+
+/*
+stacks returns the stacks of all goroutines, growing the buffer until they fit.
+*/
+func stacks() []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "afxdp-dump")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path, err := Dump(Source{Pools: func() []Pool { return testStatus.Pools }}, dir)
+	require.NoError(t, err, "Unexpected error")
+	assert.Equal(t, dir, filepath.Dir(path), "Dump should be written to the given directory")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Dump should only be accessible to root")
+
+	out, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	parts := strings.SplitN(string(out), "\n\n", 2)
+	require.Len(t, parts, 2, "Dump should contain the state followed by the goroutine stacks")
+
+	state := &State{}
+	require.NoError(t, json.Unmarshal([]byte(parts[0]), state), "State should be valid JSON")
+	assert.Equal(t, testStatus.Pools, state.Status.Pools, "Unexpected pools")
+	assert.True(t, state.Goroutines > 0, "Goroutines should be counted")
+	assert.Contains(t, parts[1], "goroutine ", "Goroutine stacks should be dumped")
+
+	_, err = Dump(Source{}, filepath.Join(dir, "missing"))
+	assert.Error(t, err, "Dumping to a missing directory should fail")
+}
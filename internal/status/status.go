@@ -0,0 +1,339 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/health"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+Status is a snapshot of the state of the running device plugin, served on the control socket
+for on-node debugging.
+*/
+type Status struct {
+	Pools       []Pool          `json:"pools"`
+	Liveness    []health.Result `json:"liveness"`
+	Readiness   []health.Result `json:"readiness"`
+	Allocations []Allocation    `json:"allocations"`
+	Connections []Connection    `json:"connections"`
+	Errors      []string        `json:"errors,omitempty"`
+}
+
+/*
+Pool is a device pool and the devices it advertises to the kubelet.
+*/
+type Pool struct {
+	Name     string   `json:"name"`
+	Mode     string   `json:"mode"`
+	Resource string   `json:"resource"`
+	Devices  []Device `json:"devices"`
+}
+
+/*
+Device is a device advertised by a pool. Pci and Driver are empty if unknown.
+*/
+type Device struct {
+	Name   string `json:"name"`
+	Pci    string `json:"pci,omitempty"`
+	Driver string `json:"driver,omitempty"`
+	Peer   string `json:"peer,omitempty"`
+}
+
+/*
+Allocation is a device attached to a pod by the CNI.
+*/
+type Allocation struct {
+	Device    string `json:"device"`
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Peer      string `json:"peer,omitempty"`
+}
+
+/*
+Connection is a pod connected to the UDS of a pool, with the devices it was allocated.
+*/
+type Connection struct {
+	Pool      string    `json:"pool"`
+	Pod       string    `json:"pod"`
+	Namespace string    `json:"namespace"`
+	Devices   []string  `json:"devices"`
+	Since     time.Time `json:"since"`
+}
+
+/*
+Source provides the parts of the status owned by other packages. Health checks are read from
+the health package.
+*/
+type Source struct {
+	Pools       func() []Pool
+	Connections func() []Connection
+	Net         networking.Handler
+}
+
+/*
+collect takes a snapshot of the status from source.
+*/
+func collect(source Source) *Status {
+	st := &Status{
+		Pools:       []Pool{},
+		Liveness:    health.Liveness.Results(),
+		Readiness:   health.Readiness.Results(),
+		Allocations: []Allocation{},
+		Connections: []Connection{},
+	}
+
+	if source.Pools != nil {
+		st.Pools = append(st.Pools, source.Pools()...)
+	}
+	if source.Connections != nil {
+		st.Connections = append(st.Connections, source.Connections()...)
+	}
+
+	if source.Net != nil {
+		allocations, err := source.Net.GetAllocations()
+		if err != nil {
+			st.Errors = append(st.Errors, fmt.Sprintf("error reading allocations: %v", err))
+		}
+		for _, allocation := range allocations {
+			st.Allocations = append(st.Allocations, Allocation{
+				Device:    allocation.Device,
+				Pod:       allocation.Pod,
+				Namespace: allocation.Namespace,
+				Peer:      allocation.Peer,
+			})
+		}
+		sort.Slice(st.Allocations, func(i, j int) bool { return st.Allocations[i].Device < st.Allocations[j].Device })
+	}
+
+	return st
+}
+
+/*
+control is the listener of the control socket being served, nil if not serving.
+*/
+var control = struct {
+	sync.Mutex
+	listener net.Listener
+}{}
+
+/*
+Serve starts serving the status on the control socket at path. Any stale socket left at the
+path is removed, and the new socket is only accessible to root. The listener is opened before
+returning so that errors are reported to the caller, requests are then served on a Go routine.
+*/
+func Serve(path string, source Source) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing stale control socket %s: %w", path, err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		logging.Errorf("Error opening control socket %s: %v", path, err)
+		return err
+	}
+
+	if err := os.Chmod(path, os.FileMode(constants.Status.SocketPermissions)); err != nil {
+		listener.Close()
+		return fmt.Errorf("error setting permissions of control socket %s: %w", path, err)
+	}
+
+	ServeListener(listener, source)
+
+	return nil
+}
+
+/*
+ServeListener starts serving the status on an open control socket, such as a socket passed by
+systemd, on a Go routine.
+*/
+func ServeListener(listener net.Listener, source Source) {
+	control.Lock()
+	control.listener = listener
+	control.Unlock()
+
+	go func() {
+		if err := http.Serve(listener, handler(source)); err != nil && !stopped(listener) {
+			logging.Errorf("Control socket server stopped: %v", err)
+		}
+	}()
+
+	logging.Infof("Serving status on control socket %v", listener.Addr())
+}
+
+/*
+Stop stops serving the status, closing the control socket and removing its file.
+*/
+func Stop() {
+	control.Lock()
+	defer control.Unlock()
+
+	if control.listener == nil {
+		return
+	}
+	if err := control.listener.Close(); err != nil {
+		logging.Warningf("Error closing control socket: %v", err)
+	}
+	control.listener = nil
+}
+
+/*
+stopped returns true if serving on listener was stopped by Stop.
+*/
+func stopped(listener net.Listener) bool {
+	control.Lock()
+	defer control.Unlock()
+
+	return control.listener != listener
+}
+
+func handler(source Source) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(constants.Status.Path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(collect(source)); err != nil {
+			logging.Errorf("Error writing status: %v", err)
+		}
+	})
+
+	return mux
+}
+
+/*
+Get gets the status of the device plugin serving the control socket at path.
+*/
+func Get(path string) (*Status, error) {
+	client := &http.Client{
+		Timeout: time.Duration(constants.Status.Timeout) * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://afxdp-dp" + constants.Status.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to the device plugin on %s, is it running? %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device plugin responded %s", resp.Status)
+	}
+
+	st := &Status{}
+	if err := json.NewDecoder(resp.Body).Decode(st); err != nil {
+		return nil, fmt.Errorf("error decoding status: %w", err)
+	}
+
+	return st, nil
+}
+
+/*
+Formats are the formats Print can print the status in.
+*/
+var Formats = []string{"table", "json"}
+
+/*
+Print writes the status to w, as indented JSON or as a table per section.
+*/
+func Print(w io.Writer, st *Status, format string) error {
+	switch format {
+	case "json":
+		out, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", out)
+		return err
+	case "table":
+		return printTables(w, st)
+	default:
+		return fmt.Errorf("unknown format %s, must be one of %v", format, Formats)
+	}
+}
+
+func printTables(w io.Writer, st *Status) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "POOL\tMODE\tRESOURCE\tDEVICE\tPCI\tDRIVER\tPEER")
+	for _, pool := range st.Pools {
+		if len(pool.Devices) == 0 {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t-\t-\t-\t-\n", pool.Name, pool.Mode, pool.Resource)
+		}
+		for _, device := range pool.Devices {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", pool.Name, pool.Mode, pool.Resource,
+				device.Name, orDash(device.Pci), orDash(device.Driver), orDash(device.Peer))
+		}
+	}
+
+	fmt.Fprintln(tw, "\nPROBE\tCHECK\tSTATUS")
+	for _, probe := range []struct {
+		name    string
+		results []health.Result
+	}{
+		{"liveness", st.Liveness},
+		{"readiness", st.Readiness},
+	} {
+		for _, result := range probe.results {
+			state := "ok"
+			if !result.Ok {
+				state = "failed: " + result.Error
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", probe.name, result.Name, state)
+		}
+	}
+
+	fmt.Fprintln(tw, "\nDEVICE\tPOD\tNAMESPACE\tPEER")
+	for _, allocation := range st.Allocations {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", allocation.Device, allocation.Pod, allocation.Namespace, orDash(allocation.Peer))
+	}
+
+	fmt.Fprintln(tw, "\nCONNECTED POD\tNAMESPACE\tPOOL\tDEVICES\tSINCE")
+	for _, conn := range st.Connections {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", conn.Pod, orDash(conn.Namespace), conn.Pool,
+			strings.Join(conn.Devices, ","), conn.Since.Format(time.RFC3339))
+	}
+
+	for _, err := range st.Errors {
+		fmt.Fprintf(tw, "\nerror: %s\n", err)
+	}
+
+	return tw.Flush()
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package status
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/health"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testStatus = &Status{
+	Pools: []Pool{
+		{
+			Name:     "myPool",
+			Mode:     "primary",
+			Resource: "afxdp/myPool",
+			Devices: []Device{
+				{Name: "ens785f0", Pci: "0000:81:00.0", Driver: "ice"},
+				{Name: "ens785f1", Pci: "0000:81:00.1", Driver: "ice", Peer: "ens786f1"},
+			},
+		},
+		{
+			Name:     "emptyPool",
+			Mode:     "cdq",
+			Resource: "afxdp/emptyPool",
+			Devices:  []Device{},
+		},
+	},
+	Liveness:  []health.Result{{Name: "pod-resources", Ok: true}},
+	Readiness: []health.Result{{Name: "pools", Ok: false, Error: "device pools not yet discovered"}},
+	Allocations: []Allocation{
+		{Device: "ens785f0", Pod: "podA", Namespace: "default"},
+	},
+	Connections: []Connection{
+		{Pool: "afxdp/myPool", Pod: "podA", Namespace: "default", Devices: []string{"ens785f0"}, Since: time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)},
+	},
+}
+
+func TestPrint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		format string
+		expOut string
+		expErr bool
+	}{
+		{
+			name:   "table",
+			format: "table",
+			expOut: "POOL       MODE     RESOURCE         DEVICE    PCI           DRIVER  PEER\n" +
+				"myPool     primary  afxdp/myPool     ens785f0  0000:81:00.0  ice     -\n" +
+				"myPool     primary  afxdp/myPool     ens785f1  0000:81:00.1  ice     ens786f1\n" +
+				"emptyPool  cdq      afxdp/emptyPool  -         -             -       -\n" +
+				"\n" +
+				"PROBE      CHECK          STATUS\n" +
+				"liveness   pod-resources  ok\n" +
+				"readiness  pools          failed: device pools not yet discovered\n" +
+				"\n" +
+				"DEVICE    POD   NAMESPACE  PEER\n" +
+				"ens785f0  podA  default    -\n" +
+				"\n" +
+				"CONNECTED POD  NAMESPACE  POOL          DEVICES   SINCE\n" +
+				"podA           default    afxdp/myPool  ens785f0  2022-06-01T12:00:00Z\n",
+		},
+		{
+			name:   "unknown format",
+			format: "yaml",
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := Print(&out, testStatus, tc.format)
+			if tc.expErr {
+				assert.Error(t, err, "Expected an error")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expOut, out.String(), "Unexpected output")
+		})
+	}
+}
+
+func TestServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "afxdp-status")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "control.sock")
+
+	netHandler := networking.NewFakeHandler()
+	require.NoError(t, netHandler.RecordAllocation(&networking.Allocation{Device: "ens785f0", Owner: "ctr", Pod: "podA", Namespace: "default"}))
+	defer netHandler.RemoveAllocation("ens785f0", "ctr")
+
+	health.Readiness.Add("pools", func() error { return errors.New("device pools not yet discovered") })
+	health.Liveness.Add("pod-resources", func() error { return nil })
+
+	require.NoError(t, ioutil.WriteFile(path, nil, 0644), "Unexpected error")
+	require.NoError(t, Serve(path, Source{
+		Pools:       func() []Pool { return testStatus.Pools },
+		Connections: func() []Connection { return testStatus.Connections },
+		Net:         netHandler,
+	}), "Stale sockets should be removed")
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "Control socket should only be accessible to root")
+
+	st, err := Get(path)
+	require.NoError(t, err)
+	assert.Equal(t, testStatus, st, "Unexpected status")
+
+	_, err = Get(filepath.Join(dir, "missing.sock"))
+	assert.Error(t, err, "Getting the status without a device plugin running should fail")
+
+	Stop()
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "Control socket should be removed on stop")
+	_, err = Get(path)
+	assert.Error(t, err, "Getting the status once stopped should fail")
+}
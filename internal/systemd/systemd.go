@@ -0,0 +1,174 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+States sent to the service manager by Notify.
+*/
+const (
+	Ready    = "READY=1"    // startup is complete
+	Stopping = "STOPPING=1" // shutdown has begun
+	watchdog = "WATCHDOG=1" // the service is alive, sent by Watchdog
+)
+
+/*
+Listeners returns the sockets passed by the service manager through socket activation, keyed on
+their FileDescriptorName= in the socket unit, or on their position if unnamed. It returns no
+sockets if the device plugin was not socket activated. The socket activation env vars are unset,
+so that they are not inherited by child processes.
+*/
+func Listeners() (map[string]net.Listener, error) {
+	return listeners(constants.Systemd.ListenFdsStart)
+}
+
+/*
+listeners returns the sockets passed through socket activation, numbered from start.
+*/
+func listeners(start int) (map[string]net.Listener, error) {
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	activated := make(map[string]net.Listener, count)
+	for i := 0; i < count; i++ {
+		fd := start + i
+		syscall.CloseOnExec(fd)
+
+		name := strconv.Itoa(i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+
+		file := os.NewFile(uintptr(fd), name)
+		listener, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			for _, l := range activated {
+				l.Close()
+			}
+			return nil, fmt.Errorf("error using socket %s passed by systemd: %w", name, err)
+		}
+		logging.Debugf("Using socket %s passed by systemd, listening on %v", name, listener.Addr())
+		activated[name] = listener
+	}
+
+	return activated, nil
+}
+
+/*
+Notify sends a state, such as Ready, to the service manager, if the device plugin runs as a
+systemd service of Type=notify. It returns false, without error, if the device plugin is not
+run by systemd or its service is of another type.
+*/
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// a leading @ is an abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("error connecting to systemd notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("error notifying systemd: %w", err)
+	}
+
+	return true, nil
+}
+
+/*
+WatchdogInterval returns the interval within which the service manager expects to be notified
+that the device plugin is alive, set by WatchdogSec= in the service unit, or 0 if the watchdog
+is disabled.
+*/
+func WatchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+
+	return time.Duration(usec) * time.Microsecond
+}
+
+/*
+Watchdog notifies the service manager that the device plugin is alive at half the watchdog
+interval, until the stop channel is closed, as long as the check passes. Once the check fails,
+such as the liveness checks of a stuck device plugin, notifications stop, and systemd restarts
+the service as configured by Restart= in the service unit. It does nothing if the watchdog is
+disabled.
+*/
+func Watchdog(stop <-chan struct{}, check func() error) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+	logging.Infof("Notifying the systemd watchdog every %v", interval/2)
+
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			if err := check(); err != nil {
+				logging.Warningf("Not notifying the systemd watchdog, device plugin unhealthy: %v", err)
+				continue
+			}
+			if _, err := Notify(watchdog); err != nil {
+				logging.Warningf("Error notifying the systemd watchdog: %v", err)
+			}
+		}
+	}()
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package systemd
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+/*
+notifySocket listens on a systemd notify socket in a temporary directory, returning the
+socket and a function reading the next state sent to it.
+*/
+func notifySocket(t *testing.T) (string, func() string) {
+	dir, err := ioutil.TempDir("", "afxdp-systemd")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return path, func() string {
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			return ""
+		}
+		return string(buf[:n])
+	}
+}
+
+func TestNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := Notify(Ready)
+	assert.NoError(t, err, "Unexpected error")
+	assert.False(t, sent, "Nothing should be sent when not run by systemd")
+
+	path, next := notifySocket(t)
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	sent, err = Notify(Ready)
+	require.NoError(t, err, "Unexpected error")
+	assert.True(t, sent, "State should be sent")
+	assert.Equal(t, Ready, next(), "Unexpected state")
+
+	os.Setenv("NOTIFY_SOCKET", filepath.Join(filepath.Dir(path), "missing.sock"))
+	_, err = Notify(Ready)
+	assert.Error(t, err, "Notifying a missing socket should fail")
+}
+
+func TestListeners(t *testing.T) {
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	require.NoError(t, err)
+	defer file.Close()
+
+	testCases := []struct {
+		name    string
+		pid     int
+		fds     string
+		names   string
+		expName string
+	}{
+		{
+			name: "not activated",
+			pid:  os.Getpid(),
+		},
+		{
+			name: "activated for another process",
+			pid:  os.Getpid() + 1,
+			fds:  "1",
+		},
+		{
+			name:    "named socket",
+			pid:     os.Getpid(),
+			fds:     "1",
+			names:   "metrics",
+			expName: "metrics",
+		},
+		{
+			name:    "unnamed socket",
+			pid:     os.Getpid(),
+			fds:     "1",
+			expName: "0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// each activated socket is closed once used, so pass a duplicate
+			fd, err := syscall.Dup(int(file.Fd()))
+			require.NoError(t, err)
+
+			os.Setenv("LISTEN_PID", strconv.Itoa(tc.pid))
+			os.Setenv("LISTEN_FDS", tc.fds)
+			os.Setenv("LISTEN_FDNAMES", tc.names)
+
+			activated, err := listeners(fd)
+			require.NoError(t, err, "Unexpected error")
+			assert.Empty(t, os.Getenv("LISTEN_FDS"), "Socket activation env vars should be unset")
+			if tc.expName == "" {
+				assert.Empty(t, activated, "No sockets should be returned")
+				return
+			}
+			require.Contains(t, activated, tc.expName, "Socket not returned by name")
+			defer activated[tc.expName].Close()
+			assert.Equal(t, tcp.Addr().String(), activated[tc.expName].Addr().String(), "Unexpected socket")
+		})
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	path, next := notifySocket(t)
+	os.Setenv("NOTIFY_SOCKET", path)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	assert.Equal(t, time.Duration(0), WatchdogInterval(), "Watchdog should be disabled")
+
+	os.Setenv("WATCHDOG_USEC", "40000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	assert.Equal(t, time.Duration(0), WatchdogInterval(), "Watchdog of another process should be disabled")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	defer os.Unsetenv("WATCHDOG_PID")
+	require.Equal(t, 40*time.Millisecond, WatchdogInterval(), "Unexpected watchdog interval")
+
+	stop := make(chan struct{})
+	Watchdog(stop, func() error { return errors.New("stuck") })
+	assert.Equal(t, "", next(), "Watchdog should not be notified while unhealthy")
+	close(stop)
+
+	stop = make(chan struct{})
+	defer close(stop)
+	Watchdog(stop, func() error { return nil })
+	assert.Equal(t, "WATCHDOG=1", next(), "Watchdog should be notified while healthy")
+}
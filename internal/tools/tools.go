@@ -19,8 +19,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 /*
@@ -61,6 +63,40 @@ func FilePathExists(path string) (bool, error) {
 	return false, err
 }
 
+/*
+EnsureDirectory creates a directory and any missing parents if needed, and sets the owner, group
+and permissions of the directory, so that a directory left by a previous run or prepared on the
+host is corrected. An error is returned if path exists and is not a directory.
+*/
+func EnsureDirectory(path string, owner int, group int, mode os.FileMode) error {
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok || int(stat.Uid) != owner || int(stat.Gid) != group {
+		if err := os.Chown(path, owner, group); err != nil {
+			return err
+		}
+	}
+
+	// the mode of a new directory is masked by the umask
+	if info.Mode().Perm() != mode.Perm() {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 /*
 RemoveFromArray returns array without the element rem if it is present.
 */
@@ -104,3 +140,35 @@ func KernelVersionInt(version string) (int64, error) { // example "5.4.0-89-gene
 
 	return value, nil
 }
+
+/*
+ParseCpuList takes a Linux CPU list, e.g. "2-5,8", and returns the CPUs it contains in ascending order.
+*/
+func ParseCpuList(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	var cpus []int
+
+	for _, part := range strings.Split(list, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil || first < 0 {
+			return nil, fmt.Errorf("invalid CPU list %q", list)
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil || last < first {
+				return nil, fmt.Errorf("invalid CPU list %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			if !seen[cpu] {
+				seen[cpu] = true
+				cpus = append(cpus, cpu)
+			}
+		}
+	}
+
+	sort.Ints(cpus)
+	return cpus, nil
+}
@@ -18,6 +18,9 @@ package tools
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -292,3 +295,81 @@ func TestKernelVersionInt(t *testing.T) {
 	}
 
 }
+
+func TestParseCpuList(t *testing.T) {
+	testCases := []struct {
+		name    string
+		list    string
+		expCpus []int
+		expErr  bool
+	}{
+		{
+			name:    "single cpu",
+			list:    "3",
+			expCpus: []int{3},
+		},
+		{
+			name:    "range and single cpu",
+			list:    "2-5,8",
+			expCpus: []int{2, 3, 4, 5, 8},
+		},
+		{
+			name:    "unordered and overlapping",
+			list:    "8, 4-5,5-6",
+			expCpus: []int{4, 5, 6, 8},
+		},
+		{
+			name:   "reversed range",
+			list:   "5-2",
+			expErr: true,
+		},
+		{
+			name:   "not a number",
+			list:   "2-five",
+			expErr: true,
+		},
+		{
+			name:   "empty",
+			list:   "",
+			expErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cpus, err := ParseCpuList(tc.list)
+			if tc.expErr {
+				assert.Error(t, err, "Error was expected")
+				return
+			}
+			require.NoError(t, err, "Unexpected error")
+			assert.Equal(t, tc.expCpus, cpus, "Returned CPUs do not match expected CPUs")
+		})
+	}
+}
+
+func TestEnsureDirectory(t *testing.T) {
+	root, err := ioutil.TempDir("", "tools")
+	require.NoError(t, err, "Unexpected error creating temp dir")
+	defer os.RemoveAll(root)
+	owner, group := os.Getuid(), os.Getgid()
+
+	// created with missing parents
+	dir := filepath.Join(root, "afxdp", "sockets")
+	require.NoError(t, EnsureDirectory(dir, owner, group, 0750), "Unexpected error creating directory")
+	info, err := os.Stat(dir)
+	require.NoError(t, err, "Directory should exist")
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm(), "Unexpected permissions")
+
+	// existing directory corrected
+	require.NoError(t, os.Chmod(dir, 0777), "Unexpected error changing permissions")
+	require.NoError(t, EnsureDirectory(dir, owner, group, 0700), "Unexpected error correcting directory")
+	info, err = os.Stat(dir)
+	require.NoError(t, err, "Directory should exist")
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm(), "Unexpected permissions")
+
+	// existing file
+	file := filepath.Join(root, "file")
+	require.NoError(t, ioutil.WriteFile(file, nil, 0600), "Unexpected error creating file")
+	assert.Error(t, EnsureDirectory(file, owner, group, 0700), "Error was expected for a file")
+}
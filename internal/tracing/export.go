@@ -0,0 +1,309 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	logging "github.com/sirupsen/logrus"
+)
+
+const (
+	scopeName        = "github.com/intel/afxdp-plugins-for-kubernetes"
+	spanKindInternal = 1
+	spanKindServer   = 2
+	statusCodeError  = 2
+)
+
+var (
+	exp          *exporter
+	exporterLock sync.Mutex
+)
+
+/*
+exporter batches finished spans and exports them to an OTLP/HTTP endpoint, JSON encoded.
+*/
+type exporter struct {
+	url     string
+	host    string
+	client  *http.Client
+	spans   chan *Span
+	stop    chan struct{}
+	done    chan struct{}
+	dropped int
+}
+
+/*
+Enable starts exporting spans to the OTLP/HTTP endpoint, such as http://otel-collector:4318,
+with spans reported as coming from host. Spans are batched and exported on a Go routine.
+*/
+func Enable(endpoint string, host string) error {
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return fmt.Errorf("invalid OTLP endpoint %s, must be an http or https URL", endpoint)
+	}
+
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	if exp != nil {
+		return fmt.Errorf("tracing already enabled, exporting to %s", exp.url)
+	}
+
+	exp = &exporter{
+		url:    strings.TrimSuffix(endpoint, "/") + constants.Tracing.TracesPath,
+		host:   host,
+		client: &http.Client{Timeout: time.Duration(constants.Tracing.Timeout) * time.Second},
+		spans:  make(chan *Span, constants.Tracing.QueueSize),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go exp.run()
+
+	logging.Infof("Exporting traces to %s", exp.url)
+
+	return nil
+}
+
+/*
+Enabled returns true if spans are being exported.
+*/
+func Enabled() bool {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	return exp != nil
+}
+
+/*
+Shutdown stops exporting spans, first exporting any spans already finished.
+*/
+func Shutdown() {
+	exporterLock.Lock()
+	e := exp
+	exp = nil
+	exporterLock.Unlock()
+
+	if e == nil {
+		return
+	}
+	close(e.stop)
+	<-e.done
+}
+
+/*
+queue queues a finished span for export, dropping it if the queue is full so that tracing
+never blocks the operation traced.
+*/
+func queue(span *Span) {
+	exporterLock.Lock()
+	defer exporterLock.Unlock()
+
+	if exp == nil {
+		return
+	}
+	select {
+	case exp.spans <- span:
+	default:
+		exp.dropped++
+	}
+}
+
+func (e *exporter) run() {
+	ticker := time.NewTicker(time.Duration(constants.Tracing.ExportInterval) * time.Second)
+	defer ticker.Stop()
+	defer close(e.done)
+
+	var batch []*Span
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= constants.Tracing.BatchSize {
+				e.export(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			e.export(batch)
+			batch = nil
+		case <-e.stop:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					e.export(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+/*
+export sends a batch of spans to the OTLP endpoint. Spans that fail to export are logged
+and discarded.
+*/
+func (e *exporter) export(batch []*Span) {
+	exporterLock.Lock()
+	dropped := e.dropped
+	e.dropped = 0
+	exporterLock.Unlock()
+	if dropped > 0 {
+		logging.Warningf("Dropped %d spans, the trace export queue was full", dropped)
+	}
+
+	if len(batch) == 0 {
+		return
+	}
+
+	body, err := exportBody(batch, e.host)
+	if err != nil {
+		logging.Warningf("Error encoding %d spans for export: %v", len(batch), err)
+		return
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		logging.Warningf("Error exporting %d spans: %v", len(batch), err)
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		logging.Warningf("Error exporting %d spans: OTLP endpoint responded %s", len(batch), resp.Status)
+		return
+	}
+	logging.Tracef("Exported %d spans", len(batch))
+}
+
+/*
+exportBody returns the OTLP/HTTP JSON export request for a batch of spans.
+*/
+func exportBody(batch []*Span, host string) ([]byte, error) {
+	var spans []spanData
+	for _, span := range batch {
+		span.lock.Lock()
+		data := spanData{
+			TraceID:           hex.EncodeToString(span.traceID[:]),
+			SpanID:            hex.EncodeToString(span.spanID[:]),
+			Name:              span.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+		}
+		if span.parentID != [8]byte{} {
+			data.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		} else {
+			data.Kind = spanKindServer
+		}
+		for _, key := range span.sortedAttributes() {
+			data.Attributes = append(data.Attributes, stringAttribute(key, span.attributes[key]))
+		}
+		if span.err != nil {
+			data.Status = status{Code: statusCodeError, Message: span.err.Error()}
+		}
+		span.lock.Unlock()
+		spans = append(spans, data)
+	}
+
+	request := exportRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: resource{
+					Attributes: []keyValue{
+						stringAttribute("service.name", constants.Tracing.ServiceName),
+						stringAttribute("host.name", host),
+					},
+				},
+				ScopeSpans: []scopeSpans{
+					{
+						Scope: scope{Name: scopeName},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	}
+
+	return json.Marshal(request)
+}
+
+func stringAttribute(key string, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: value}}
+}
+
+/*
+exportRequest holds the fields of an OTLP ExportTraceServiceRequest that the exporter sets.
+*/
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope      `json:"scope"`
+	Spans []spanData `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type spanData struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
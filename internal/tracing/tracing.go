@@ -0,0 +1,125 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Span is a timed operation within a trace, exported in the OpenTelemetry format once ended.
+All methods are safe to call on a nil Span, which is returned by Start when tracing is disabled,
+so that callers need not check whether tracing is enabled.
+*/
+type Span struct {
+	lock       sync.Mutex
+	traceID    [16]byte
+	spanID     [8]byte
+	parentID   [8]byte
+	name       string
+	start      time.Time
+	end        time.Time
+	attributes map[string]string
+	err        error
+	ended      bool
+}
+
+/*
+Start starts a span as a child of parent, or as the root span of a new trace if parent is nil.
+Start returns nil if tracing is disabled.
+*/
+func Start(name string, parent *Span) *Span {
+	if !Enabled() {
+		return nil
+	}
+
+	span := &Span{
+		name:       name,
+		start:      time.Now(),
+		attributes: make(map[string]string),
+	}
+	rand.Read(span.spanID[:])
+	if parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else {
+		rand.Read(span.traceID[:])
+	}
+
+	return span
+}
+
+/*
+SetAttribute sets an attribute of the span, replacing any previous value of the attribute.
+*/
+func (s *Span) SetAttribute(key string, value string) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attributes[key] = value
+}
+
+/*
+End ends the span and queues it for export. A non-nil err marks the span as failed.
+Only the first call to End has an effect.
+*/
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.err = err
+	s.lock.Unlock()
+
+	queue(s)
+}
+
+/*
+TraceID returns the hex encoded ID of the trace of the span, or an empty string for a nil Span.
+*/
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+
+	return hex.EncodeToString(s.traceID[:])
+}
+
+/*
+sortedAttributes returns the attribute keys of the span in order, so exports are repeatable.
+*/
+func (s *Span) sortedAttributes() []string {
+	keys := make([]string, 0, len(s.attributes))
+	for key := range s.attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
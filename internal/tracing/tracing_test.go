@@ -0,0 +1,89 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisabled(t *testing.T) {
+	span := Start("disabled", nil)
+	assert.Nil(t, span, "Span should be nil when tracing is disabled")
+
+	span.SetAttribute("key", "value")
+	span.End(nil)
+	assert.Equal(t, "", span.TraceID(), "Nil span should have no trace ID")
+	assert.Nil(t, Start("child", span), "Span should be nil when tracing is disabled")
+}
+
+func TestExport(t *testing.T) {
+	var requests []exportRequest
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path, "Unexpected export path")
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err, "Can't read export request")
+		var request exportRequest
+		require.NoError(t, json.Unmarshal(body, &request), "Can't parse export request")
+		requests = append(requests, request)
+	}))
+	defer endpoint.Close()
+
+	require.NoError(t, Enable(endpoint.URL+"/", "node1"), "Unexpected error")
+	assert.Error(t, Enable(endpoint.URL, "node1"), "Tracing should only be enabled once")
+
+	parent := Start("Allocate", nil)
+	parent.SetAttribute("pool", "afxdp/myPool")
+	child := Start("Load BPF program", parent)
+	child.End(errors.New("no such device"))
+	child.End(nil)
+	parent.End(nil)
+	Shutdown()
+
+	assert.False(t, Enabled(), "Tracing should be disabled after shutdown")
+	require.Len(t, requests, 1, "Spans should be exported in one request")
+	require.Len(t, requests[0].ResourceSpans, 1, "Unexpected resource spans")
+	resourceSpans := requests[0].ResourceSpans[0]
+	assert.Equal(t, []keyValue{stringAttribute("service.name", "afxdp-device-plugin"), stringAttribute("host.name", "node1")},
+		resourceSpans.Resource.Attributes, "Unexpected resource attributes")
+	require.Len(t, resourceSpans.ScopeSpans, 1, "Unexpected scope spans")
+	spans := resourceSpans.ScopeSpans[0].Spans
+	require.Len(t, spans, 2, "Each span should be exported once")
+
+	exportedChild, exportedParent := spans[0], spans[1]
+	assert.Equal(t, "Load BPF program", exportedChild.Name, "Unexpected span order")
+	assert.Equal(t, parent.TraceID(), exportedParent.TraceID, "Unexpected trace ID")
+	assert.Equal(t, parent.TraceID(), exportedChild.TraceID, "Child should share the trace of its parent")
+	assert.Equal(t, exportedParent.SpanID, exportedChild.ParentSpanID, "Unexpected parent span")
+	assert.Equal(t, "", exportedParent.ParentSpanID, "Root span should have no parent")
+	assert.Equal(t, spanKindServer, exportedParent.Kind, "Unexpected root span kind")
+	assert.Equal(t, spanKindInternal, exportedChild.Kind, "Unexpected child span kind")
+	assert.Equal(t, []keyValue{stringAttribute("pool", "afxdp/myPool")}, exportedParent.Attributes, "Unexpected attributes")
+	assert.Equal(t, status{}, exportedParent.Status, "Unexpected status")
+	assert.Equal(t, status{Code: statusCodeError, Message: "no such device"}, exportedChild.Status, "Unexpected error status")
+}
+
+func TestEnableInvalidEndpoint(t *testing.T) {
+	assert.Error(t, Enable("otel-collector:4318", "node1"), "Endpoint without a scheme should be refused")
+	assert.False(t, Enabled(), "Tracing should not be enabled")
+}
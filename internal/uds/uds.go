@@ -16,17 +16,31 @@
 package uds
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/selinux"
 	logging "github.com/sirupsen/logrus"
 	"net"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 )
 
+/*
+ErrClosed is returned by Listen and Read once the Handler is closed by Close.
+*/
+var ErrClosed = errors.New("Unix domain socket closed")
+
 /*
 Handler is the device plugins interface for reading and writing to a Unix domain socket.
 The interface exists for testing purposes, allowing unit tests to run without making calls
@@ -38,6 +52,8 @@ type Handler interface {
 	Dial() (CleanupFunc, error)
 	Read() (string, int, error)
 	Write(response string, fd int) error
+	PeerCred() (*syscall.Ucred, error)
+	Close()
 }
 
 /*
@@ -51,9 +67,13 @@ type handler struct {
 	conn       *net.UnixConn
 	msgBufSize int
 	ctlBufSize int
+	msgBuf     []byte // buffer messages are read into, reused by each Read
+	ctrlBuf    []byte // buffer control messages are read into, reused by each Read
 	timeout    time.Duration
 	protocol   string
 	uid        string
+	lock       sync.Mutex // guards the listener and connection against Close
+	closed     bool
 }
 
 /*
@@ -83,6 +103,8 @@ func (h *handler) Init(socketPath string, protocol string, msgBufSize int, ctlBu
 	h.ctlBufSize = ctlBufSize
 	h.timeout = timeout
 	h.uid = uid
+	h.msgBuf = make([]byte, h.msgBufSize)
+	h.ctrlBuf = make([]byte, syscall.CmsgSpace(h.ctlBufSize))
 
 	// resolve UDS address
 	h.addr, err = net.ResolveUnixAddr(h.protocol, h.socketPath)
@@ -103,11 +125,24 @@ func (h *handler) Listen() (CleanupFunc, error) {
 	var err error
 
 	// create UDS listener
-	h.listener, err = net.ListenUnix(h.protocol, h.addr)
+	listener, err := net.ListenUnix(h.protocol, h.addr)
 	if err != nil {
 		logging.Errorf("Error creating Unix listener for %s: %v", h.socketPath, err)
 		return func() { h.cleanup() }, err
 	}
+	h.lock.Lock()
+	h.listener = listener
+	closed := h.closed
+	h.lock.Unlock()
+	if closed {
+		return func() { h.cleanup() }, ErrClosed
+	}
+
+	//SELinux context, so confined pod processes can connect
+	if err := selinux.Apply(h.socketPath); err != nil {
+		logging.Errorf("Error labelling socket file: %v", err)
+		return func() { h.cleanup() }, err
+	}
 
 	//ACL Permissions
 	if h.uid != "0" {
@@ -128,7 +163,14 @@ func (h *handler) Listen() (CleanupFunc, error) {
 		}
 	}
 
-	h.conn, err = h.listener.AcceptUnix()
+	conn, err := listener.AcceptUnix()
+	h.lock.Lock()
+	h.conn = conn
+	closed = h.closed
+	h.lock.Unlock()
+	if closed {
+		return func() { h.cleanup() }, ErrClosed
+	}
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			logging.Errorf("Listener timed out: %v", err)
@@ -163,12 +205,11 @@ func (h *handler) Dial() (CleanupFunc, error) {
 Read will read the incoming message from the UDS.
 Message byte array is converted and returned as a string.
 The control messages are also checked and returns the FD as an int, if present.
+The buffers are allocated once by Init and reused, as Read is called for every request.
 */
 func (h *handler) Read() (string, int, error) {
 	var request = ""
 	var fd int = 0
-	msgBuf := make([]byte, h.msgBufSize)
-	ctrlBuf := make([]byte, syscall.CmsgSpace(h.ctlBufSize))
 
 	if h.timeout > 0 {
 		if err := h.conn.SetDeadline(time.Now().Add(h.timeout)); err != nil {
@@ -177,7 +218,10 @@ func (h *handler) Read() (string, int, error) {
 		}
 	}
 
-	n, _, _, _, err := h.conn.ReadMsgUnix(msgBuf, ctrlBuf)
+	n, oobn, _, _, err := h.conn.ReadMsgUnix(h.msgBuf, h.ctrlBuf)
+	if err != nil && h.isClosed() {
+		return request, fd, ErrClosed
+	}
 	if err != nil {
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			logging.Errorf("Connection timed out: %v", err)
@@ -187,11 +231,11 @@ func (h *handler) Read() (string, int, error) {
 		return request, fd, err
 	}
 
-	request = string(msgBuf[0:n])
-	logging.Debugf("Read: %s", request)
+	request = string(h.msgBuf[0:n])
+	logging.Tracef("Read: %s", request)
 
-	if ctrlBufHasValue(ctrlBuf) {
-		ctrlMsgs, err := syscall.ParseSocketControlMessage(ctrlBuf)
+	if ctrlBufHasValue(h.ctrlBuf[:oobn]) {
+		ctrlMsgs, err := syscall.ParseSocketControlMessage(h.ctrlBuf[:oobn])
 		if err != nil {
 			logging.Errorf("Control messages parse error: %v", err)
 			return request, fd, err
@@ -202,10 +246,10 @@ func (h *handler) Read() (string, int, error) {
 			//We're handling a single msg and single fd here, so it's msg[0] fds[0]
 			fds, _ := syscall.ParseUnixRights(&ctrlMsgs[0])
 			fd = fds[0]
-			logging.Debugf("Request contains file descriptor: %s", strconv.Itoa(fd))
+			logging.Tracef("Request contains file descriptor: %s", strconv.Itoa(fd))
 		}
 	} else {
-		logging.Debugf("Request contains no file descriptor")
+		logging.Tracef("Request contains no file descriptor")
 	}
 
 	return request, fd, err
@@ -218,7 +262,7 @@ If a file descriptor is included, Write will configure and include it
 func (h *handler) Write(response string, fd int) error {
 
 	if fd > 0 {
-		logging.Debugf("Write: %s, FD: %s", response, strconv.Itoa(fd))
+		logging.Tracef("Write: %s, FD: %s", response, strconv.Itoa(fd))
 		rights := syscall.UnixRights(fd)
 
 		if _, _, err := h.conn.WriteMsgUnix([]byte(response), rights, nil); err != nil {
@@ -226,7 +270,7 @@ func (h *handler) Write(response string, fd int) error {
 			return err
 		}
 	} else {
-		logging.Debugf("Write: %s", response)
+		logging.Tracef("Write: %s", response)
 
 		if _, _, err := h.conn.WriteMsgUnix([]byte(response), nil, nil); err != nil {
 			logging.Errorf("WriteMsgUnix error: %v", err)
@@ -236,6 +280,87 @@ func (h *handler) Write(response string, fd int) error {
 	return nil
 }
 
+/*
+PeerCred returns the credentials of the process connected to the UDS, as recorded by the kernel
+when the connection was made. The PID is 0 if the process is not visible in the PID namespace
+of the caller.
+*/
+func (h *handler) PeerCred() (*syscall.Ucred, error) {
+	if h.conn == nil {
+		return nil, fmt.Errorf("no connection on %s", h.socketPath)
+	}
+
+	raw, err := h.conn.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+
+	return cred, credErr
+}
+
+/*
+ChallengeProof returns the proof answering a challenge, the hex encoded HMAC-SHA256 of the nonce
+keyed with the handshake token. Sending the proof rather than the token proves the pod holds the
+token without a captured connection request being of any use on another connection.
+*/
+func ChallengeProof(token string, nonce string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(nonce))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/*
+Directions of a signed message, signed differently so a request cannot be reflected back as a
+response.
+*/
+const (
+	SignRequest  = "request"
+	SignResponse = "response"
+)
+
+/*
+Sign returns the message with its signature appended, the hex encoded HMAC-SHA256 keyed with the
+handshake token of the direction, the sequence number and the message. Requests are numbered
+from 0 on each connection, and a response is signed with the number of the request it answers,
+so a message injected, replayed or reordered on the connection does not verify.
+*/
+func Sign(token string, direction string, seq int, message string) string {
+	return message + ", " + constants.Uds.Handshake.Signature + signature(token, direction, seq, message)
+}
+
+/*
+SplitSignature returns the message with its signature removed, and the signature, empty if the
+message is not signed.
+*/
+func SplitSignature(message string) (string, string) {
+	i := strings.LastIndex(message, ", "+constants.Uds.Handshake.Signature)
+	if i < 0 {
+		return message, ""
+	}
+	return message[:i], message[i+len(", "+constants.Uds.Handshake.Signature):]
+}
+
+/*
+VerifySignature returns true if sig is the signature of the message, see Sign.
+*/
+func VerifySignature(token string, direction string, seq int, message string, sig string) bool {
+	return hmac.Equal([]byte(sig), []byte(signature(token, direction, seq, message)))
+}
+
+func signature(token string, direction string, seq int, message string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(direction + " " + strconv.Itoa(seq) + " " + message))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
 /*
 GenerateRandomSocketName will take the file directory path, and apply a unique name per each
 UDS socket file created.
@@ -268,6 +393,12 @@ func GenerateRandomSocketName(directory string, udsDirFileMode os.FileMode) (str
 		return "", err
 	}
 
+	//SELinux context, so confined pod processes can reach the socket
+	if err := selinux.Apply(directory); err != nil {
+		logging.Errorf(err.Error())
+		return "", err
+	}
+
 	var sockPath string
 	var count int = 0
 	for {
@@ -294,7 +425,79 @@ func GenerateRandomSocketName(directory string, udsDirFileMode os.FileMode) (str
 	return sockPath, nil
 }
 
+/*
+GenerateSocketDir creates a directory with a unique name in the file directory path, to hold the
+UDS socket file of a single pod, and returns the path of the socket file within it. The directory
+is mounted into the pod, so the pod sees no other socket, and sees the socket again if it is
+recreated. A non-zero uid is given access to the directory, as it is to the socket file.
+*/
+func GenerateSocketDir(directory string, udsDirFileMode os.FileMode, sockName string, uid string) (string, error) {
+	path, err := GenerateRandomSocketName(directory, udsDirFileMode)
+	if err != nil {
+		return "", err
+	}
+	podDir := strings.TrimSuffix(path, ".sock")
+
+	if err := os.Mkdir(podDir, udsDirFileMode); err != nil {
+		logging.Errorf("Error creating socket directory %s: %v", podDir, err)
+		return "", err
+	}
+
+	// the mode of a new directory is masked by the umask
+	if err := os.Chmod(podDir, udsDirFileMode); err != nil {
+		logging.Errorf("Error setting permissions on socket directory %s: %v", podDir, err)
+		os.Remove(podDir)
+		return "", err
+	}
+
+	if err := selinux.Apply(podDir); err != nil {
+		logging.Errorf(err.Error())
+		os.Remove(podDir)
+		return "", err
+	}
+
+	if uid != "" && uid != "0" {
+		if err := host.GivePermissions(podDir, uid, "rx"); err != nil {
+			logging.Errorf("Error giving permissions to socket directory %s: %v", podDir, err)
+			os.Remove(podDir)
+			return "", err
+		}
+	}
+
+	return filepath.Join(podDir, sockName), nil
+}
+
+/*
+Close closes the listener and any connection, so that a Listen or Read in progress returns
+ErrClosed, and removes the socket file. It can be called from any Go routine, and before Listen.
+*/
+func (h *handler) Close() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.closed = true
+	if h.listener != nil {
+		h.listener.Close()
+	}
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	if h.socketPath != "" {
+		os.Remove(h.socketPath)
+	}
+}
+
+func (h *handler) isClosed() bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	return h.closed
+}
+
 func (h *handler) cleanup() {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
 	logging.Debugf("Closing Unix listener")
 	h.listener.Close()
 	if h.conn != nil {
@@ -19,6 +19,10 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
@@ -96,3 +100,81 @@ func TestInit(t *testing.T) {
 		})
 	}
 }
+
+func TestCloseUnblocksListen(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "test.sock")
+
+	h := NewHandler()
+	require.NoError(t, h.Init(socketPath, "unixpacket", 64, 4, 0, "0"), "Unexpected error")
+
+	listened := make(chan error)
+	go func() {
+		cleanup, err := h.Listen()
+		cleanup()
+		listened <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(socketPath)
+		return err == nil
+	}, time.Second, 10*time.Millisecond, "Socket file not created")
+
+	h.Close()
+	select {
+	case err := <-listened:
+		assert.True(t, errors.Is(err, ErrClosed), "Unexpected error: %v", err)
+	case <-time.After(time.Second):
+		t.Fatal("Listen not unblocked by Close")
+	}
+
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "Socket file not removed")
+}
+
+func TestGenerateSocketDir(t *testing.T) {
+	dir, err := ioutil.TempDir("/tmp", "test-afxdp-")
+	require.NoError(t, err, "Can't create temporary directory")
+	defer os.RemoveAll(dir)
+	poolDir := filepath.Join(dir, "pool") + "/"
+
+	first, err := GenerateSocketDir(poolDir, 0700, "afxdp.sock", "0")
+	require.NoError(t, err, "Unexpected error")
+	second, err := GenerateSocketDir(poolDir, 0700, "afxdp.sock", "0")
+	require.NoError(t, err, "Unexpected error")
+
+	assert.Equal(t, "afxdp.sock", filepath.Base(first), "Unexpected socket name")
+	assert.Equal(t, poolDir, filepath.Dir(filepath.Dir(first))+"/", "Socket directory should be in the pool directory")
+	assert.NotEqual(t, filepath.Dir(first), filepath.Dir(second), "Each socket should have its own directory")
+
+	info, err := os.Stat(filepath.Dir(first))
+	require.NoError(t, err, "Socket directory should exist")
+	assert.True(t, info.IsDir(), "Socket directory should be a directory")
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm(), "Unexpected socket directory permissions")
+}
+
+func TestChallengeProof(t *testing.T) {
+	// RFC 4231 test case 2
+	assert.Equal(t, "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+		ChallengeProof("Jefe", "what do ya want for nothing?"), "Unexpected proof")
+	assert.NotEqual(t, ChallengeProof("token", "nonce1"), ChallengeProof("token", "nonce2"), "Proof should depend on the nonce")
+}
+
+func TestSign(t *testing.T) {
+	signed := Sign("0123abcd", SignRequest, 1, "/xsk_map_fd, devA")
+	assert.True(t, strings.HasPrefix(signed, "/xsk_map_fd, devA, sig="), "Signature should be appended")
+
+	message, sig := SplitSignature(signed)
+	assert.Equal(t, "/xsk_map_fd, devA", message, "Unexpected message")
+	assert.True(t, VerifySignature("0123abcd", SignRequest, 1, message, sig), "Signature should verify")
+	assert.False(t, VerifySignature("4567ef01", SignRequest, 1, message, sig), "Signature of another token should not verify")
+	assert.False(t, VerifySignature("0123abcd", SignRequest, 2, message, sig), "Signature of another sequence number should not verify")
+	assert.False(t, VerifySignature("0123abcd", SignResponse, 1, message, sig), "Request signature should not verify as a response")
+	assert.False(t, VerifySignature("0123abcd", SignRequest, 1, "/xsk_map_fd, devB", sig), "Signature of another message should not verify")
+
+	message, sig = SplitSignature("/xsk_map_fd, devA")
+	assert.Equal(t, "/xsk_map_fd, devA", message, "Unexpected message")
+	assert.Equal(t, "", sig, "Unsigned message should have no signature")
+}
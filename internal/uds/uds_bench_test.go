@@ -0,0 +1,128 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package uds
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+/*
+connectedPair returns a handler listening on a socket in a temporary directory and a handler
+connected to it, with the message and control buffer sizes of the device plugin.
+*/
+func connectedPair(b *testing.B) (Handler, Handler, func()) {
+	dir, err := ioutil.TempDir("/tmp", "bench-afxdp-")
+	if err != nil {
+		b.Fatalf("Can't create temporary directory: %v", err)
+	}
+	socketPath := filepath.Join(dir, "bench.sock")
+
+	server := NewHandler()
+	if err := server.Init(socketPath, "unixpacket", 512, 4, 0, "0"); err != nil {
+		b.Fatalf("Error initialising server: %v", err)
+	}
+	type listened struct {
+		cleanup CleanupFunc
+		err     error
+	}
+	accepted := make(chan listened)
+	go func() {
+		cleanup, err := server.Listen()
+		accepted <- listened{cleanup, err}
+	}()
+
+	client := NewHandler()
+	if err := client.Init(socketPath, "unixpacket", 512, 4, 0, "0"); err != nil {
+		b.Fatalf("Error initialising client: %v", err)
+	}
+	var clientCleanup CleanupFunc
+	for {
+		if _, err := os.Stat(socketPath); err == nil {
+			if clientCleanup, err = client.Dial(); err == nil {
+				break
+			}
+		}
+	}
+	result := <-accepted
+	if result.err != nil {
+		b.Fatalf("Error accepting connection: %v", result.err)
+	}
+
+	return server, client, func() {
+		clientCleanup()
+		result.cleanup()
+		os.RemoveAll(dir)
+	}
+}
+
+func BenchmarkWriteRead(b *testing.B) {
+	server, client, cleanup := connectedPair(b)
+	defer cleanup()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.Write("/xsk_map_fd, ens801f2", -1); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := server.Read(); err != nil {
+			b.Fatal(err)
+		}
+		if err := server.Write("/fd_nak", -1); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := client.Read(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkWriteReadFd(b *testing.B) {
+	server, client, cleanup := connectedPair(b)
+	defer cleanup()
+
+	file, err := os.Open(os.DevNull)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer file.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := client.Write("/xsk_map_fd, ens801f2", -1); err != nil {
+			b.Fatal(err)
+		}
+		if _, _, err := server.Read(); err != nil {
+			b.Fatal(err)
+		}
+		if err := server.Write("/fd_ack", int(file.Fd())); err != nil {
+			b.Fatal(err)
+		}
+		_, fd, err := client.Read()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if fd <= 0 {
+			b.Fatal("file descriptor not received")
+		}
+		syscall.Close(fd)
+	}
+}
@@ -15,7 +15,11 @@
 
 package uds
 
-import "time"
+import (
+	"sync/atomic"
+	"syscall"
+	"time"
+)
 
 /*
 FakeHandler interface extends the Handler interface to provide additional testing methods.
@@ -24,6 +28,7 @@ type FakeHandler interface {
 	Handler
 	SetRequests(requests map[int]string)
 	GetResponses() map[int]string
+	SetPeerCred(cred *syscall.Ucred)
 }
 
 /*
@@ -33,6 +38,8 @@ type fakeHandler struct {
 	counter         int
 	fakeRequests    map[int]string
 	actualResponses map[int]string
+	peerCred        *syscall.Ucred
+	closed          int32
 }
 
 /*
@@ -73,6 +80,9 @@ Read should read the incoming message from the UDS.
 In this fakeHandler it will sequentially return a set of predetermined strings.
 */
 func (f *fakeHandler) Read() (string, int, error) {
+	if atomic.LoadInt32(&f.closed) == 1 {
+		return "", 0, ErrClosed
+	}
 	request := f.fakeRequests[f.counter]
 	return request, 0, nil
 }
@@ -87,6 +97,32 @@ func (f *fakeHandler) Write(response string, fd int) error {
 	return nil
 }
 
+/*
+PeerCred returns the credentials of the process connected to the UDS.
+In this fakeHandler it returns the credentials set by SetPeerCred, or root credentials if unset.
+*/
+func (f *fakeHandler) PeerCred() (*syscall.Ucred, error) {
+	if f.peerCred == nil {
+		return &syscall.Ucred{}, nil
+	}
+	return f.peerCred, nil
+}
+
+/*
+Close closes the Unix domain socket.
+In this fakeHandler, further calls to Read return ErrClosed.
+*/
+func (f *fakeHandler) Close() {
+	atomic.StoreInt32(&f.closed, 1)
+}
+
+/*
+SetPeerCred sets the credentials returned by PeerCred.
+*/
+func (f *fakeHandler) SetPeerCred(cred *syscall.Ucred) {
+	f.peerCred = cred
+}
+
 /*
 SetRequests takes a map of strings. These strings will be sequentially returned
 each time the Read function is called. This allows us to build a list of fake
@@ -22,6 +22,7 @@ import (
 	logging "github.com/sirupsen/logrus"
 	"io"
 	"os"
+	"syscall"
 	"time"
 )
 
@@ -108,6 +109,21 @@ func (f *fuzzHandler) Write(response string, fd int) error {
 	return nil
 }
 
+/*
+PeerCred returns the credentials of the process connected to the UDS.
+fuzzHandler returns root credentials as it's functionality isn't required for fuzz testing.
+*/
+func (f *fuzzHandler) PeerCred() (*syscall.Ucred, error) {
+	return &syscall.Ucred{}, nil
+}
+
+/*
+Close closes the Unix domain socket.
+fuzzHandler does nothing as it's functionality isn't required for fuzz testing.
+*/
+func (f *fuzzHandler) Close() {
+}
+
 func fuzzLogging() error {
 
 	logging.SetReportCaller(true)
@@ -0,0 +1,144 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+Client libraries sending their version in the version request.
+*/
+const (
+	clientCndp     = "cndp"     // the CNDP library, versioned by release, e.g. 22.08.0
+	clientGoclient = "goclient" // the Go client library of this repository, versioned by the handshake version it speaks
+)
+
+/*
+compatibility is the range of versions of a client library a handshake version supports.
+*/
+type compatibility struct {
+	handshake string // handshake version of the device plugin
+	client    string // client library
+	min       string // oldest supported version of the client library
+	max       string // newest supported version of the client library, any if empty
+}
+
+/*
+compatibilityTable holds the client library versions each handshake version is known to support.
+An entry is added for each client library whenever the handshake version is increased. Clients
+outside the range of their library are refused at the version request, rather than failing later
+on a request the device plugin or the client does not know. Libraries and handshake versions not
+in the table are not checked.
+*/
+var compatibilityTable = []compatibility{
+	// CNDP releases before 22.08 predate the device plugin UDS
+	{handshake: "0.4", client: clientCndp, min: "22.08.0"},
+	// the Go client library may send requests added after the handshake version of the device plugin
+	{handshake: "0.4", client: clientGoclient, min: "0.1", max: "0.4"},
+}
+
+/*
+checkClient checks a client library, sent as <library>/<version>, against the compatibility
+table for the handshake version of the device plugin. It returns the reason the client is
+refused, or an empty string if it is supported or not known. It returns false if the client
+cannot be parsed.
+*/
+func checkClient(client string) (string, bool) {
+	library, version, found := cut(client, "/")
+	if !found || library == "" {
+		return "", false
+	}
+	if _, ok := parseVersion(version); !ok {
+		return "", false
+	}
+
+	for _, entry := range compatibilityTable {
+		if entry.handshake != constants.Uds.Handshake.Version || entry.client != library {
+			continue
+		}
+
+		supported := entry.min + " and later"
+		if entry.max != "" {
+			supported = entry.min + " to " + entry.max
+		}
+		if compareVersions(version, entry.min) < 0 || (entry.max != "" && compareVersions(version, entry.max) > 0) {
+			return fmt.Sprintf("%s %s is not supported by handshake version %s, which supports %s %s",
+				library, version, constants.Uds.Handshake.Version, library, supported), true
+		}
+	}
+
+	return "", true
+}
+
+/*
+parseVersion parses a dotted version, such as 22.08.0 or v22.08.0, into its numbers.
+*/
+func parseVersion(version string) ([]int, bool) {
+	fields := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	numbers := make([]int, len(fields))
+	for i, field := range fields {
+		number, err := strconv.Atoi(field)
+		if err != nil || number < 0 {
+			return nil, false
+		}
+		numbers[i] = number
+	}
+
+	return numbers, true
+}
+
+/*
+compareVersions returns -1, 0 or 1 if version a is older than, the same as or newer than version
+b. Missing trailing numbers are taken as 0, so 22.08 is the same as 22.08.0. Versions are parsed
+before being compared.
+*/
+func compareVersions(a string, b string) int {
+	va, _ := parseVersion(a)
+	vb, _ := parseVersion(b)
+
+	for i := 0; i < len(va) || i < len(vb); i++ {
+		var na, nb int
+		if i < len(va) {
+			na = va[i]
+		}
+		if i < len(vb) {
+			nb = vb[i]
+		}
+		switch {
+		case na < nb:
+			return -1
+		case na > nb:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+/*
+cut slices s around the first instance of sep, as strings.Cut in later Go releases.
+*/
+func cut(s string, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	"gotest.tools/assert"
+)
+
+func TestCheckClient(t *testing.T) {
+	defer func(table []compatibility) { compatibilityTable = table }(compatibilityTable)
+	compatibilityTable = []compatibility{
+		{handshake: constants.Uds.Handshake.Version, client: "cndp", min: "22.08.0"},
+		{handshake: constants.Uds.Handshake.Version, client: "goclient", min: "0.1", max: "0.4"},
+		{handshake: "0.0", client: "cndp", min: "99.0.0"},
+	}
+
+	testCases := []struct {
+		testName   string
+		client     string
+		expRefused bool
+		expOk      bool
+	}{
+		{testName: "Oldest supported", client: "cndp/22.08.0", expOk: true},
+		{testName: "Newer release", client: "cndp/23.3.1", expOk: true},
+		{testName: "Release with a v prefix", client: "cndp/v22.08", expOk: true},
+		{testName: "Older release", client: "cndp/22.04.0", expRefused: true, expOk: true},
+		{testName: "Within a bounded range", client: "goclient/0.4", expOk: true},
+		{testName: "Above a bounded range", client: "goclient/0.5", expRefused: true, expOk: true},
+		{testName: "Unknown library", client: "vpp/1.0", expOk: true},
+		{testName: "No version", client: "cndp", expOk: false},
+		{testName: "No library", client: "/22.08.0", expOk: false},
+		{testName: "Version not a number", client: "cndp/latest", expOk: false},
+		{testName: "Empty version field", client: "cndp/22..0", expOk: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			refusal, ok := checkClient(tc.client)
+
+			assert.Equal(t, ok, tc.expOk)
+			assert.Equal(t, refusal != "", tc.expRefused)
+		})
+	}
+
+	refusal, _ := checkClient("cndp/22.04.0")
+	assert.Equal(t, refusal, "cndp 22.04.0 is not supported by handshake version "+constants.Uds.Handshake.Version+", which supports cndp 22.08.0 and later")
+	refusal, _ = checkClient("goclient/0.5")
+	assert.Equal(t, refusal, "goclient 0.5 is not supported by handshake version "+constants.Uds.Handshake.Version+", which supports goclient 0.1 to 0.4")
+}
+
+func TestCompareVersions(t *testing.T) {
+	testCases := []struct {
+		a   string
+		b   string
+		exp int
+	}{
+		{a: "22.08.0", b: "22.08.0", exp: 0},
+		{a: "22.08", b: "22.08.0", exp: 0},
+		{a: "22.04.0", b: "22.08.0", exp: -1},
+		{a: "22.10.0", b: "22.9.0", exp: 1},
+		{a: "23.03.0", b: "22.08.1", exp: 1},
+		{a: "v0.4", b: "0.4", exp: 0},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.a+" "+tc.b, func(t *testing.T) {
+			assert.Equal(t, compareVersions(tc.a, tc.b), tc.exp)
+		})
+	}
+}
+
+func TestVersionRequestClient(t *testing.T) {
+	defer func(table []compatibility) { compatibilityTable = table }(compatibilityTable)
+	compatibilityTable = []compatibility{
+		{handshake: constants.Uds.Handshake.Version, client: "cndp", min: "22.08.0"},
+	}
+
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/compatPool", []string{"devA"})
+	connect := constants.Uds.Handshake.RequestConnect + ", podA"
+	version := constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient
+
+	testCases := []struct {
+		testName     string
+		requests     map[int]string
+		expResponses map[int]string
+	}{
+		{
+			testName: "Supported client",
+			requests: map[int]string{
+				0: connect,
+				1: version + "cndp/22.08.0",
+				2: constants.Uds.Handshake.RequestFd + ", devA",
+				3: constants.Uds.Handshake.RequestFin,
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.Version,
+				2: constants.Uds.Handshake.ResponseFdAck,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Incompatible client, connection closed",
+			requests: map[int]string{
+				0: connect,
+				1: version + "cndp/22.04.0",
+				2: constants.Uds.Handshake.RequestFd + ", devA",
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseVersionNak + ", cndp 22.04.0 is not supported by handshake version " +
+					constants.Uds.Handshake.Version + ", which supports cndp 22.08.0 and later",
+			},
+		},
+		{
+			testName: "Unparsable client",
+			requests: map[int]string{
+				0: connect,
+				1: version + "cndp",
+				2: constants.Uds.Handshake.RequestFin,
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseBadRequest,
+				2: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/compatPool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+			}
+			fakeUDS.SetRequests(tc.requests)
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+Faults are the faults a Server injects into its responses, so authors of dataplane applications
+can test their reconnect and retry logic against a misbehaving device plugin. Each rate is the
+probability, from 0 to 1, of the fault being injected into a response. If several are drawn for a
+response, a dropped connection takes the place of a NAK, and a NAK of a truncated response. A
+delay is drawn separately and may precede any of them.
+*/
+type Faults struct {
+	Delay        time.Duration // how long a delayed response is held back
+	DelayRate    float64       // probability of a response being delayed
+	NakRate      float64       // probability of a NAK being sent in place of a response
+	DropRate     float64       // probability of the connection being dropped in place of a response
+	TruncateRate float64       // probability of only the first half of a response being sent
+	Seed         int64         // seed of the faults drawn, so a run can be repeated, random if 0
+}
+
+/*
+fault is a fault injected in place of a response.
+*/
+type fault int
+
+const (
+	faultNone fault = iota
+	faultDrop
+	faultNak
+	faultTruncate
+)
+
+/*
+errFaultDrop ends a connection dropped by fault injection. The Server then listens for the pod
+to reconnect.
+*/
+var errFaultDrop = errors.New("connection dropped by fault injection")
+
+/*
+faultNaks are the NAKs injected in place of acknowledgements, so the application sees the
+refusal it would get from the request. Other responses are replaced by the general NAK.
+*/
+var faultNaks = map[string]string{
+	constants.Uds.Handshake.ResponseHostOk:      constants.Uds.Handshake.ResponseHostNak,
+	constants.Uds.Handshake.ResponseFdAck:       constants.Uds.Handshake.ResponseFdNak,
+	constants.Uds.Handshake.ResponseBusyPollAck: constants.Uds.Handshake.ResponseBusyPollNak,
+	constants.Uds.Handshake.ResponseConfigAck:   constants.Uds.Handshake.ResponseConfigNak,
+}
+
+/*
+faultInjector draws the faults injected into the responses of a Server.
+*/
+type faultInjector struct {
+	faults Faults
+	random *rand.Rand
+}
+
+func newFaultInjector(faults Faults) *faultInjector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &faultInjector{faults: faults, random: rand.New(rand.NewSource(seed))}
+}
+
+/*
+next draws the faults injected into the next response, the fault in place of the response, if
+any, and whether it is delayed. A nil faultInjector injects no faults.
+*/
+func (f *faultInjector) next() (fault, bool) {
+	if f == nil {
+		return faultNone, false
+	}
+
+	delayed := f.random.Float64() < f.faults.DelayRate
+	drop := f.random.Float64() < f.faults.DropRate
+	nak := f.random.Float64() < f.faults.NakRate
+	truncate := f.random.Float64() < f.faults.TruncateRate
+
+	switch {
+	case drop:
+		return faultDrop, delayed
+	case nak:
+		return faultNak, delayed
+	case truncate:
+		return faultTruncate, delayed
+	default:
+		return faultNone, delayed
+	}
+}
+
+/*
+nakFor returns the NAK injected in place of a response.
+*/
+func nakFor(response string) string {
+	kind := strings.SplitN(response, ",", 2)[0]
+	if nak, ok := faultNaks[kind]; ok {
+		return nak
+	}
+	return constants.Uds.Handshake.ResponseBadRequest
+}
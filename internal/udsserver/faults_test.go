@@ -0,0 +1,153 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	"gotest.tools/assert"
+)
+
+func TestFaults(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/faultPool", []string{"devA"})
+
+	requests := map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestFd + ", devA",
+		2: constants.Uds.Handshake.RequestFin,
+	}
+
+	testCases := []struct {
+		testName     string
+		faults       Faults
+		expResponses map[int]string
+		expDropped   bool
+		expMinTime   time.Duration
+	}{
+		{
+			testName: "No faults",
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFdAck,
+				2: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Delayed responses",
+			faults:   Faults{Delay: 20 * time.Millisecond, DelayRate: 1},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFdAck,
+				2: constants.Uds.Handshake.ResponseFinAck,
+			},
+			expMinTime: 60 * time.Millisecond,
+		},
+		{
+			testName: "Spurious NAK",
+			faults:   Faults{NakRate: 1},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+		{
+			testName:     "Dropped connection",
+			faults:       Faults{DropRate: 1},
+			expResponses: map[int]string{},
+			expDropped:   true,
+		},
+		{
+			testName:     "Dropped connection takes the place of a NAK",
+			faults:       Faults{DropRate: 1, NakRate: 1, TruncateRate: 1},
+			expResponses: map[int]string{},
+			expDropped:   true,
+		},
+		{
+			testName: "Truncated responses",
+			faults:   Faults{TruncateRate: 1},
+			expResponses: map[int]string{
+				0: "/hos",
+				1: "/fd",
+				2: "/fin",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/faultPool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+			}
+			if tc.faults != (Faults{}) {
+				server.SetFaults(tc.faults)
+			}
+			fakeUDS.SetRequests(requests)
+
+			started := time.Now()
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+			assert.Equal(t, server.dropped, tc.expDropped)
+			assert.Assert(t, time.Since(started) >= tc.expMinTime)
+		})
+	}
+}
+
+func TestFaultsSeed(t *testing.T) {
+	faults := Faults{DelayRate: 0.5, NakRate: 0.2, DropRate: 0.1, TruncateRate: 0.2, Seed: 42}
+	first := newFaultInjector(faults)
+	second := newFaultInjector(faults)
+
+	for i := 0; i < 100; i++ {
+		firstFault, firstDelayed := first.next()
+		secondFault, secondDelayed := second.next()
+		assert.Equal(t, firstFault, secondFault)
+		assert.Equal(t, firstDelayed, secondDelayed)
+	}
+
+	var none *faultInjector
+	fault, delayed := none.next()
+	assert.Equal(t, fault, faultNone)
+	assert.Equal(t, delayed, false)
+}
+
+func TestNakFor(t *testing.T) {
+	testCases := []struct {
+		response string
+		expNak   string
+	}{
+		{constants.Uds.Handshake.ResponseHostOk, constants.Uds.Handshake.ResponseHostNak},
+		{constants.Uds.Handshake.ResponseFdAck, constants.Uds.Handshake.ResponseFdNak},
+		{constants.Uds.Handshake.ResponseBusyPollAck, constants.Uds.Handshake.ResponseBusyPollNak},
+		{constants.Uds.Handshake.ResponseConfigAck + ", peer=devB", constants.Uds.Handshake.ResponseConfigNak},
+		{constants.Uds.Handshake.Version, constants.Uds.Handshake.ResponseBadRequest},
+		{constants.Uds.Handshake.ResponseFinAck, constants.Uds.Handshake.ResponseBadRequest},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.response, func(t *testing.T) {
+			assert.Equal(t, nakFor(tc.response), tc.expNak)
+		})
+	}
+}
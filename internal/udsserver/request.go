@@ -0,0 +1,216 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+)
+
+/*
+request is a request from a pod on an established connection, as parsed by parseRequest.
+Requests come from containers, so parsing makes no assumption about their content.
+*/
+type request struct {
+	kind        string // the request, such as constants.Uds.Handshake.RequestFd, empty if not recognised
+	malformed   bool   // the request was recognised, but not its arguments
+	device      string // the device of a file descriptor or config request
+	build       bool   // the version request asks for the build of the device plugin
+	client      string // the client library and its version sent with the version request, e.g. cndp/22.08.0
+	busyTimeout int    // the timeout of a busy poll request
+	busyBudget  int    // the budget of a busy poll request
+	err         error  // error converting the timeout or budget of a busy poll request
+}
+
+/*
+parseRequest parses a request on an established connection. Requests are recognised in the
+order the handshake has always matched them, so a request holding the name of several requests
+is taken as the first of them. Arguments are split on commas and have spaces removed.
+*/
+func parseRequest(msg string) request {
+	words := strings.Split(msg, ",")
+
+	switch {
+	case strings.Contains(msg, constants.Uds.Handshake.RequestFd):
+		req := request{kind: constants.Uds.Handshake.RequestFd}
+		if len(words) != 2 || words[0] != constants.Uds.Handshake.RequestFd {
+			req.malformed = true
+			return req
+		}
+		req.device = strings.ReplaceAll(words[1], " ", "")
+		return req
+
+	case msg == constants.Uds.Handshake.RequestVersion:
+		return request{kind: constants.Uds.Handshake.RequestVersion}
+
+	case strings.HasPrefix(msg, constants.Uds.Handshake.RequestVersion+","):
+		req := request{kind: constants.Uds.Handshake.RequestVersion}
+		for _, word := range words[1:] {
+			word = strings.TrimSpace(word)
+			switch {
+			case word == constants.Uds.Handshake.VersionBuild && !req.build:
+				req.build = true
+			case strings.HasPrefix(word, constants.Uds.Handshake.VersionClient) && req.client == "":
+				req.client = strings.TrimPrefix(word, constants.Uds.Handshake.VersionClient)
+				if req.client == "" {
+					req.malformed = true
+				}
+			default:
+				req.malformed = true
+			}
+		}
+		return req
+
+	case strings.Contains(msg, constants.Uds.Handshake.RequestBusyPoll):
+		req := request{kind: constants.Uds.Handshake.RequestBusyPoll}
+		if len(words) != 3 || words[0] != constants.Uds.Handshake.RequestBusyPoll {
+			req.malformed = true
+			return req
+		}
+		if req.busyTimeout, req.err = strconv.Atoi(strings.ReplaceAll(words[1], " ", "")); req.err != nil {
+			return req
+		}
+		req.busyBudget, req.err = strconv.Atoi(strings.ReplaceAll(words[2], " ", ""))
+		return req
+
+	case strings.Contains(msg, constants.Uds.Handshake.RequestConfig):
+		req := request{kind: constants.Uds.Handshake.RequestConfig}
+		if len(words) != 2 || words[0] != constants.Uds.Handshake.RequestConfig {
+			req.malformed = true
+			return req
+		}
+		req.device = strings.ReplaceAll(words[1], " ", "")
+		return req
+
+	case msg == constants.Uds.Handshake.RequestFin:
+		return request{kind: constants.Uds.Handshake.RequestFin}
+
+	default:
+		return request{}
+	}
+}
+
+/*
+isConnectRequest returns true if the first request of a connection is meant as a connection
+request, well formed or not, and so is answered.
+*/
+func isConnectRequest(msg string) bool {
+	return strings.Contains(msg, constants.Uds.Handshake.RequestConnect)
+}
+
+/*
+parseConnectRequest parses a connection request, returning the hostname of the pod, with spaces
+removed, and the identity sent with it. It returns false if the request is malformed, see
+parsePodIdentity.
+*/
+func parseConnectRequest(msg string) (string, podIdentity, bool) {
+	words := strings.Split(msg, ",")
+	identity, ok := parsePodIdentity(words)
+	if !ok || words[0] != constants.Uds.Handshake.RequestConnect {
+		return "", identity, false
+	}
+
+	return strings.ReplaceAll(words[1], " ", ""), identity, true
+}
+
+/*
+redactRequest returns the request with the handshake token and the proof of a challenge removed,
+so requests can be logged and traced without the secret given to the pod. Malformed requests are
+redacted too, as they may still hold the token.
+*/
+func redactRequest(msg string) string {
+	words := strings.Split(msg, ",")
+	kept := make([]string, 0, len(words))
+	for _, word := range words {
+		field := strings.TrimSpace(word)
+		if strings.HasPrefix(field, constants.Uds.Handshake.ConnectToken) || strings.HasPrefix(field, constants.Uds.Handshake.ConnectProof) {
+			continue
+		}
+		kept = append(kept, word)
+	}
+
+	return strings.Join(kept, ",")
+}
+
+/*
+podIdentity holds the optional pod name, namespace and UID sent in a connection request,
+in addition to the pod hostname. Pods set these through the downward API.
+*/
+type podIdentity struct {
+	name      string
+	namespace string
+	uid       string
+	token     string // handshake token sent by the pod, not part of its identity
+	nonce     string // nonce of the challenge echoed by the pod
+	proof     string // proof of the challenge sent by the pod, in place of the token
+}
+
+/*
+parsePodIdentity parses a connection request split on commas, of the form
+"/connect, <hostname>[, name=<name>][, namespace=<namespace>][, uid=<uid>][, token=<token>]
+[, nonce=<nonce>][, proof=<proof>]".
+It returns false if the request does not hold exactly one hostname, or holds unknown, repeated or
+empty fields.
+*/
+func parsePodIdentity(words []string) (podIdentity, bool) {
+	var identity podIdentity
+
+	if len(words) < 2 || strings.Contains(strings.TrimSpace(words[1]), "=") {
+		return identity, false
+	}
+
+	for _, word := range words[2:] {
+		word = strings.TrimSpace(word)
+		switch {
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectName) && identity.name == "":
+			identity.name = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectName)
+			if identity.name == "" {
+				return identity, false
+			}
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectNamespace) && identity.namespace == "":
+			identity.namespace = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectNamespace)
+			if identity.namespace == "" {
+				return identity, false
+			}
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectUid) && identity.uid == "":
+			identity.uid = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectUid)
+			if identity.uid == "" {
+				return identity, false
+			}
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectToken) && identity.token == "":
+			identity.token = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectToken)
+			if identity.token == "" {
+				return identity, false
+			}
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectNonce) && identity.nonce == "":
+			identity.nonce = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectNonce)
+			if identity.nonce == "" {
+				return identity, false
+			}
+		case strings.HasPrefix(word, constants.Uds.Handshake.ConnectProof) && identity.proof == "":
+			identity.proof = strings.TrimPrefix(word, constants.Uds.Handshake.ConnectProof)
+			if identity.proof == "" {
+				return identity, false
+			}
+		default:
+			return identity, false
+		}
+	}
+
+	return identity, true
+}
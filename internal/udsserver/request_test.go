@@ -0,0 +1,355 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	"gotest.tools/assert"
+)
+
+func TestParseRequest(t *testing.T) {
+	testCases := []struct {
+		testName string
+		request  string
+		expReq   request
+		expErr   bool
+	}{
+		{
+			testName: "Fd request",
+			request:  constants.Uds.Handshake.RequestFd + ", dev 1",
+			expReq:   request{kind: constants.Uds.Handshake.RequestFd, device: "dev1"},
+		},
+		{
+			testName: "Fd request without a device",
+			request:  constants.Uds.Handshake.RequestFd,
+			expReq:   request{kind: constants.Uds.Handshake.RequestFd, malformed: true},
+		},
+		{
+			testName: "Fd request with two devices",
+			request:  constants.Uds.Handshake.RequestFd + ", devA, devB",
+			expReq:   request{kind: constants.Uds.Handshake.RequestFd, malformed: true},
+		},
+		{
+			testName: "Fd request not leading",
+			request:  "x" + constants.Uds.Handshake.RequestFd + ", devA",
+			expReq:   request{kind: constants.Uds.Handshake.RequestFd, malformed: true},
+		},
+		{
+			testName: "Version request",
+			request:  constants.Uds.Handshake.RequestVersion,
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion},
+		},
+		{
+			testName: "Extended version request",
+			request:  constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionBuild,
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, build: true},
+		},
+		{
+			testName: "Extended version request, unknown argument",
+			request:  constants.Uds.Handshake.RequestVersion + ", commit",
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, malformed: true},
+		},
+		{
+			testName: "Version request with a client",
+			request:  constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient + "cndp/22.08.0",
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, client: "cndp/22.08.0"},
+		},
+		{
+			testName: "Extended version request with a client",
+			request:  constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient + "cndp/22.08.0, " + constants.Uds.Handshake.VersionBuild,
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, build: true, client: "cndp/22.08.0"},
+		},
+		{
+			testName: "Version request with an empty client",
+			request:  constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient,
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, malformed: true},
+		},
+		{
+			testName: "Version request with two clients",
+			request:  constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient + "cndp/22.08.0, " + constants.Uds.Handshake.VersionClient + "goclient/0.4",
+			expReq:   request{kind: constants.Uds.Handshake.RequestVersion, client: "cndp/22.08.0", malformed: true},
+		},
+		{
+			testName: "Busy poll request",
+			request:  constants.Uds.Handshake.RequestBusyPoll + ", 20, 64",
+			expReq:   request{kind: constants.Uds.Handshake.RequestBusyPoll, busyTimeout: 20, busyBudget: 64},
+		},
+		{
+			testName: "Busy poll request without a budget",
+			request:  constants.Uds.Handshake.RequestBusyPoll + ", 20",
+			expReq:   request{kind: constants.Uds.Handshake.RequestBusyPoll, malformed: true},
+		},
+		{
+			testName: "Busy poll request, budget not a number",
+			request:  constants.Uds.Handshake.RequestBusyPoll + ", 20, lots",
+			expReq:   request{kind: constants.Uds.Handshake.RequestBusyPoll, busyTimeout: 20},
+			expErr:   true,
+		},
+		{
+			testName: "Config request",
+			request:  constants.Uds.Handshake.RequestConfig + ", devA",
+			expReq:   request{kind: constants.Uds.Handshake.RequestConfig, device: "devA"},
+		},
+		{
+			testName: "Config request with two devices",
+			request:  constants.Uds.Handshake.RequestConfig + ", devA, devB",
+			expReq:   request{kind: constants.Uds.Handshake.RequestConfig, malformed: true},
+		},
+		{
+			testName: "Fin request",
+			request:  constants.Uds.Handshake.RequestFin,
+			expReq:   request{kind: constants.Uds.Handshake.RequestFin},
+		},
+		{
+			testName: "Fin request with an argument",
+			request:  constants.Uds.Handshake.RequestFin + ", now",
+			expReq:   request{},
+		},
+		{
+			testName: "Unknown request",
+			request:  "/reboot",
+			expReq:   request{},
+		},
+		{
+			testName: "Empty request",
+			request:  "",
+			expReq:   request{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			req := parseRequest(tc.request)
+			assert.Equal(t, req.err != nil, tc.expErr)
+			req.err = nil
+			assert.Equal(t, req, tc.expReq)
+		})
+	}
+}
+
+func TestParseConnectRequest(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		request     string
+		expConnect  bool
+		expOk       bool
+		expHostname string
+		expIdentity podIdentity
+	}{
+		{
+			testName:    "Hostname",
+			request:     constants.Uds.Handshake.RequestConnect + ", pod A",
+			expConnect:  true,
+			expOk:       true,
+			expHostname: "podA",
+		},
+		{
+			testName:    "Hostname and identity",
+			request:     constants.Uds.Handshake.RequestConnect + ", podA, name=podA, namespace=default, token=abc",
+			expConnect:  true,
+			expOk:       true,
+			expHostname: "podA",
+			expIdentity: podIdentity{name: "podA", namespace: "default", token: "abc"},
+		},
+		{
+			testName:   "No hostname",
+			request:    constants.Uds.Handshake.RequestConnect,
+			expConnect: true,
+		},
+		{
+			testName:   "Connect not leading",
+			request:    "x" + constants.Uds.Handshake.RequestConnect + ", podA",
+			expConnect: true,
+		},
+		{
+			testName: "Not a connection request",
+			request:  constants.Uds.Handshake.RequestFd + ", devA",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert.Equal(t, isConnectRequest(tc.request), tc.expConnect)
+			hostname, identity, ok := parseConnectRequest(tc.request)
+			assert.Equal(t, ok, tc.expOk)
+			if tc.expOk {
+				assert.Equal(t, hostname, tc.expHostname)
+				assert.Equal(t, identity, tc.expIdentity)
+			}
+		})
+	}
+}
+
+/*
+requestSeeds are well formed and malformed requests the fuzz targets start from.
+*/
+var requestSeeds = []string{
+	constants.Uds.Handshake.RequestConnect + ", podA",
+	constants.Uds.Handshake.RequestConnect + ", podA, name=podA, namespace=default, uid=1234, token=abc",
+	constants.Uds.Handshake.RequestConnect + ", podA, nonce=abc, proof=def",
+	constants.Uds.Handshake.RequestConnect + ",,,",
+	constants.Uds.Handshake.RequestChallenge,
+	constants.Uds.Handshake.RequestFd + ", devA",
+	constants.Uds.Handshake.RequestFd + ",",
+	constants.Uds.Handshake.RequestVersion,
+	constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionBuild,
+	constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionClient + "cndp/22.08.0",
+	constants.Uds.Handshake.RequestBusyPoll + ", 20, 64",
+	constants.Uds.Handshake.RequestBusyPoll + ", -1, 99999999999999999999",
+	constants.Uds.Handshake.RequestConfig + ", devA",
+	constants.Uds.Handshake.RequestFin,
+	"",
+	"\x00\xff,",
+}
+
+/*
+FuzzParseRequest checks that any request from a container is parsed without panicking, and that
+devices parsed from it cannot carry the separators of the handshake.
+*/
+func FuzzParseRequest(f *testing.F) {
+	for _, seed := range requestSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, msg string) {
+		req := parseRequest(msg)
+
+		if req.malformed || req.kind == "" {
+			assert.Equal(t, req.device, "")
+		}
+		assert.Assert(t, !strings.ContainsAny(req.device, ", "), "device %q holds a separator", req.device)
+		if req.err != nil {
+			assert.Equal(t, req.kind, constants.Uds.Handshake.RequestBusyPoll)
+		}
+	})
+}
+
+func TestRedactRequest(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		request    string
+		expRequest string
+	}{
+		{
+			testName:   "Connection request with a token",
+			request:    constants.Uds.Handshake.RequestConnect + ", podA, name=podA, token=0123abcd, uid=1234",
+			expRequest: constants.Uds.Handshake.RequestConnect + ", podA, name=podA, uid=1234",
+		},
+		{
+			testName:   "Connection request with a proof",
+			request:    constants.Uds.Handshake.RequestConnect + ", podA, nonce=5678, proof=9abc",
+			expRequest: constants.Uds.Handshake.RequestConnect + ", podA, nonce=5678",
+		},
+		{
+			testName:   "Malformed connection request with a repeated token",
+			request:    constants.Uds.Handshake.RequestConnect + ", podA, token=0123abcd,token=0123abcd",
+			expRequest: constants.Uds.Handshake.RequestConnect + ", podA",
+		},
+		{
+			testName:   "Connection request without a token",
+			request:    constants.Uds.Handshake.RequestConnect + ", podA",
+			expRequest: constants.Uds.Handshake.RequestConnect + ", podA",
+		},
+		{
+			testName:   "Fd request",
+			request:    constants.Uds.Handshake.RequestFd + ", devA",
+			expRequest: constants.Uds.Handshake.RequestFd + ", devA",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			assert.Equal(t, redactRequest(tc.request), tc.expRequest)
+		})
+	}
+}
+
+/*
+FuzzParseConnectRequest checks that any connection request is parsed without panicking, and that
+the hostname and identity parsed from it cannot carry the separators of the handshake.
+*/
+func FuzzParseConnectRequest(f *testing.F) {
+	for _, seed := range requestSeeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, msg string) {
+		hostname, identity, ok := parseConnectRequest(msg)
+		if !ok {
+			assert.Equal(t, hostname, "")
+			return
+		}
+
+		assert.Assert(t, isConnectRequest(msg))
+		assert.Assert(t, !strings.ContainsAny(hostname, ", "), "hostname %q holds a separator", hostname)
+		for _, field := range []string{identity.name, identity.namespace, identity.uid, identity.token, identity.nonce, identity.proof} {
+			assert.Assert(t, !strings.Contains(field, ","), "identity field %q holds a separator", field)
+		}
+	})
+}
+
+/*
+FuzzHandshake runs the handshake of a Server with a fuzzed first request, such as a connection
+request, followed by a fuzzed request, checking the Server goroutine neither panics nor hangs.
+The requests are followed by enough fin requests to end any connection.
+*/
+func FuzzHandshake(f *testing.F) {
+	for _, seed := range requestSeeds {
+		f.Add(constants.Uds.Handshake.RequestConnect+", podA", seed)
+		f.Add(seed, constants.Uds.Handshake.RequestFin)
+	}
+
+	f.Fuzz(func(t *testing.T, first string, next string) {
+		fakeUDS := uds.NewFakeHandler()
+		fakeResAPI := resourcesapi.NewFakeHandler()
+		fakeResAPI.CreateFakePod("podA", "default", "uds/testing", []string{"devA"})
+
+		server := &server{
+			deviceType: "uds/testing",
+			devices:    make(map[string]int),
+			uds:        fakeUDS,
+			bpf:        bpf.NewFakeHandler(),
+			podRes:     fakeResAPI,
+			net:        networking.NewFakeHandler(),
+		}
+		server.AddDevice("devA", 100)
+
+		requests := map[int]string{0: first, 1: next}
+		for i := 2; i < 8; i++ {
+			requests[i] = constants.Uds.Handshake.RequestFin
+		}
+		fakeUDS.SetRequests(requests)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			server.start()
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("handshake did not end, requests %q and %q", first, next)
+		}
+	})
+}
@@ -16,17 +16,48 @@
 package udsserver
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"net"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/audit"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/cgroups"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/crash"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/logformats"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/privileges"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/status"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tracing"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
 	logging "github.com/sirupsen/logrus"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+var (
+	udsHandshakes = metrics.NewCounterVec("uds_handshakes_total",
+		"Number of pod handshakes on the UDS, by pool and outcome.", metrics.LabelPool, "outcome")
+	udsConnections = metrics.NewGaugeVec("uds_connections",
+		"Number of pods connected to the UDS.", metrics.LabelPool, metrics.LabelPod, metrics.LabelNamespace)
+	udsConnectionLifetime = metrics.NewHistogramVec("uds_connection_lifetime_seconds",
+		"Lifetime of pod connections to the UDS, by pool and outcome.", metrics.LifetimeBuckets, metrics.LabelPool, "outcome")
 )
 
 /*
@@ -35,6 +66,13 @@ Implementations of this interface are the main type of this UDSServer package.
 */
 type Server interface {
 	AddDevice(dev string, fd int)
+	AddDevicePeer(dev string, peer string, fd int)
+	SetPodIrqAffinity()
+	SetAllowedPeers(uids []int, gids []int)
+	SetDpdkProfile()
+	SetFaults(faults Faults)
+	SetTrace(parent *tracing.Span)
+	Token() string
 	Start()
 }
 
@@ -53,14 +91,336 @@ server implements the Server interface. It is the main type for this package.
 */
 type server struct {
 	podName        string
+	podNamespace   string
+	podUid         string // UID of the connected pod, empty if unknown
+	containerId    string // ID of the container the CNI attached the devices to, empty if unknown
+	podDeleted     int32  // set once the connected pod is found deleted
 	deviceType     string
 	devices        map[string]int
+	peers          map[string]string
+	peerFds        map[string]int
+	served         map[string]bool // devices whose file descriptor was passed on the connection, under the single use policy
 	udsPath        string
 	uds            uds.Handler
 	bpf            bpf.Handler
 	podRes         resourcesapi.Handler
+	apiServer      apiserver.Handler
+	events         apiserver.Handler
+	refusal        string // why the pod could not be validated, reported in the refusal event
+	net            networking.Handler
 	udsIdleTimeout time.Duration
 	uid            string
+	podIrqAffinity bool
+	allowedUids    []int                    // UIDs the connected process may run as, any if empty
+	allowedGids    []int                    // GIDs the connected process may run as, any if empty
+	dpdk           bool                     // set if the pod runs the DPDK af_xdp PMD, which cannot send a token, challenge or signature
+	faults         *faultInjector           // faults injected into the responses, nil if none are
+	dropped        bool                     // set once the connection is dropped by fault injection
+	token          string                   // secret given to the container at allocation, binding the UDS to the allocation
+	nonce          string                   // nonce of the challenge issued on the connection, empty if none was requested
+	signed         bool                     // set if the first request of the connection was signed, every message then being signed
+	requests       int                      // number of requests read on the connection, the sequence number of the next
+	failures       map[string]*peerFailures // failed handshakes on the UDS keyed on the peer, see peerKey, kept across connections
+	lockedOut      bool                     // set once the failed handshakes reach the lockout threshold
+	podCpus        []int
+	trace          *tracing.Span // span of the allocation that created the server, parent of the server span
+	span           *tracing.Span // span of the server lifetime, parent of the request spans
+	request        *tracing.Span // span of the request being handled
+	peer           *audit.Peer   // credentials of the connected process, nil if unknown, recorded in the audit file
+	peerPid        int32         // PID of the connected process, 0 if not visible from this PID namespace
+	handshake      string        // outcome of the pod handshake, empty until the handshake completes
+	fdsGranted     int           // number of file descriptors passed to the pod
+	fdsDenied      int           // number of file descriptor requests refused
+}
+
+/*
+connections are the pods connected to the UDS of any Server, for the device plugin status.
+*/
+var connections = struct {
+	sync.Mutex
+	pods map[*server]status.Connection
+}{
+	pods: make(map[*server]status.Connection),
+}
+
+/*
+Connections returns the pods connected to the UDS of any Server, sorted by pool and pod.
+*/
+func Connections() []status.Connection {
+	connections.Lock()
+	defer connections.Unlock()
+
+	conns := make([]status.Connection, 0, len(connections.pods))
+	for _, conn := range connections.pods {
+		conns = append(conns, conn)
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		if conns[i].Pool != conns[j].Pool {
+			return conns[i].Pool < conns[j].Pool
+		}
+		return conns[i].Pod < conns[j].Pod
+	})
+
+	return conns
+}
+
+/*
+addConnection records that the pod of the Server is connected, until removeConnection is called.
+*/
+func (s *server) addConnection() {
+	devices := make([]string, 0, len(s.devices))
+	for device := range s.devices {
+		devices = append(devices, device)
+	}
+	sort.Strings(devices)
+
+	connections.Lock()
+	defer connections.Unlock()
+
+	connections.pods[s] = status.Connection{
+		Pool:      s.deviceType,
+		Pod:       s.podName,
+		Namespace: s.podNamespace,
+		Devices:   devices,
+		Since:     time.Now(),
+	}
+}
+
+func (s *server) removeConnection() {
+	connections.Lock()
+	defer connections.Unlock()
+
+	delete(connections.pods, s)
+}
+
+/*
+servers are the Servers started and not yet stopped, so they can be stopped on shutdown.
+Once stopping, Servers started or restarted after a panic stop immediately.
+*/
+var servers = struct {
+	sync.Mutex
+	running  map[*server]bool
+	stopping bool
+}{
+	running: make(map[*server]bool),
+}
+
+/*
+StopAll stops every Server on shutdown, closing its UDS so that it stops listening for or
+serving its pod, and removing its socket file. Pods keep the file descriptors already passed
+to them, so their AF_XDP sockets keep working until they exit.
+*/
+func StopAll() {
+	servers.Lock()
+	defer servers.Unlock()
+
+	servers.stopping = true
+	for s := range servers.running {
+		s.uds.Close()
+	}
+}
+
+/*
+register records that the Server is running, until deregister is called. It returns false if
+the Servers are being stopped, in which case the Server must not start.
+*/
+func (s *server) register() bool {
+	servers.Lock()
+	defer servers.Unlock()
+
+	if servers.stopping {
+		return false
+	}
+	servers.running[s] = true
+	return true
+}
+
+func (s *server) deregister() {
+	servers.Lock()
+	defer servers.Unlock()
+
+	delete(servers.running, s)
+}
+
+/*
+apiServerFallback validates pods when the pod resources API is unavailable, nil if disabled.
+*/
+var apiServerFallback apiserver.Handler
+
+/*
+SetApiServerFallback enables validating pods through the Kubernetes API server when the kubelet
+pod resources API is unavailable. It must be called before any Server is created.
+*/
+func SetApiServerFallback(handler apiserver.Handler) {
+	apiServerFallback = handler
+}
+
+/*
+podCheckInterval is the interval at which a connected pod is checked to still exist, accessed
+atomically as it can be changed while pods are connected.
+*/
+var podCheckInterval = int64(time.Duration(constants.Uds.PodCheckInterval) * time.Second)
+
+/*
+SetPodCheckInterval sets the interval at which a connected pod is checked to still exist.
+Pods connecting after the call are checked at the new interval.
+*/
+func SetPodCheckInterval(interval time.Duration) {
+	atomic.StoreInt64(&podCheckInterval, int64(interval))
+}
+
+/*
+requireToken is set if pods are refused when they do not send the handshake token of the
+allocation in their connection request.
+*/
+var requireToken bool
+
+/*
+SetRequireToken sets whether pods are refused when they do not send the handshake token of the
+allocation in their connection request, rather than allowed with a warning.
+It must be called before any Server is created.
+*/
+func SetRequireToken(require bool) {
+	requireToken = require
+}
+
+/*
+requireChallenge is set if pods are refused when they do not request a challenge before their
+connection request.
+*/
+var requireChallenge bool
+
+/*
+SetRequireChallenge sets whether pods are refused when they do not request a challenge before
+their connection request, rather than allowed.
+It must be called before any Server is created.
+*/
+func SetRequireChallenge(require bool) {
+	requireChallenge = require
+}
+
+/*
+requireSigned is set if pods are refused when their messages are not signed with the handshake
+token.
+*/
+var requireSigned bool
+
+/*
+SetRequireSigned sets whether pods are refused when their messages are not signed with the
+handshake token, rather than allowed.
+It must be called before any Server is created.
+*/
+func SetRequireSigned(require bool) {
+	requireSigned = require
+}
+
+/*
+revalidateFds is set if the pod is checked to still hold the device named in each request for a
+file descriptor.
+*/
+var revalidateFds bool
+
+/*
+SetRevalidateFdRequests sets whether the pod is checked to still hold the device named in each
+request for a file descriptor, rather than only when it connects.
+It must be called before any Server is created.
+*/
+func SetRevalidateFdRequests(revalidate bool) {
+	revalidateFds = revalidate
+}
+
+/*
+singleUseFds is set if the file descriptor of each device is passed at most once per connection.
+*/
+var singleUseFds bool
+
+/*
+SetSingleUseFds sets whether the file descriptor of each device is passed at most once per
+connection, further requests being refused, so a compromised process cannot keep obtaining file
+descriptors to hand to other processes.
+It must be called before any Server is created.
+*/
+func SetSingleUseFds(singleUse bool) {
+	singleUseFds = singleUse
+}
+
+/*
+Errors reading a request whose signature is missing or does not verify, see read.
+*/
+var (
+	errUnsigned  = errors.New("message not signed with the handshake token")
+	errSignature = errors.New("message signature does not verify")
+)
+
+/*
+errIncompatibleClient ends a connection whose client library is known to be incompatible with the
+handshake version, see checkClient.
+*/
+var errIncompatibleClient = errors.New("client library incompatible with the handshake version")
+
+/*
+procRoot is the proc filesystem the cgroups of processes connected to the UDS are read from.
+*/
+var procRoot = "/proc"
+
+/*
+requirePeerCgroup is set if pods are refused when the process connected to the UDS cannot be
+verified to run in the validated pod.
+*/
+var requirePeerCgroup bool
+
+/*
+SetRequirePeerCgroup sets whether pods are refused when the process connected to the UDS cannot
+be verified to run in the validated pod, rather than allowed with a warning.
+It must be called before any Server is created.
+*/
+func SetRequirePeerCgroup(require bool) {
+	requirePeerCgroup = require
+}
+
+/*
+minimalSyscalls is set if Servers avoid optional syscalls, not getting the credentials of the
+connected process.
+*/
+var minimalSyscalls bool
+
+/*
+SetMinimalSyscalls sets whether Servers avoid optional syscalls, for seccomp profiles that do not
+allow them. The connected process is then not verified to run in the validated pod, nor audited.
+It must be called before any Server is created.
+*/
+func SetMinimalSyscalls(minimal bool) {
+	minimalSyscalls = minimal
+}
+
+/*
+dropPrivileges is set if Servers switch the thread serving the handshake to handshakeUid and
+handshakeGid, and drop every capability but privileges.HandshakeNeeds from it.
+*/
+var dropPrivileges bool
+var handshakeUid, handshakeGid int
+
+/*
+SetDropPrivileges sets whether Servers switch the thread serving the handshake to the unprivileged
+uid and gid, and drop every capability but privileges.HandshakeNeeds from it, limiting what a
+compromise of the handshake can do. It must be called before any Server is created.
+*/
+func SetDropPrivileges(drop bool, uid int, gid int) {
+	dropPrivileges = drop
+	handshakeUid, handshakeGid = uid, gid
+}
+
+/*
+eventRecorder reports refused handshakes as Kubernetes Events, nil if disabled.
+*/
+var eventRecorder apiserver.Handler
+
+/*
+SetEventRecorder enables reporting refused handshakes as Kubernetes Events on the pod.
+It must be called before any Server is created.
+*/
+func SetEventRecorder(handler apiserver.Handler) {
+	eventRecorder = handler
 }
 
 /*
@@ -79,7 +439,7 @@ func NewServerFactory() ServerFactory {
 
 /*
 CreateServer creates, initialises, and returns an implementation of the Server interface.
-It also returns the filepath of the UDS being served.
+It also returns the filepath of the UDS being served, in a directory of its own.
 */
 func (f *serverFactory) CreateServer(deviceType, user string, timeout int, udsFuzz bool) (Server, string, error) {
 	var udsHandler uds.Handler
@@ -92,35 +452,165 @@ func (f *serverFactory) CreateServer(deviceType, user string, timeout int, udsFu
 	}
 
 	subDir := strings.ReplaceAll(deviceType, "/", "_")
-	udsPath, err := uds.GenerateRandomSocketName(constants.Uds.SockDir+subDir+"/", os.FileMode(constants.Uds.DirFileMode))
+	udsPath, err := uds.GenerateSocketDir(constants.Uds.SockDir+subDir+"/", os.FileMode(constants.Uds.DirFileMode), constants.Uds.SockName, user)
 	if err != nil {
 		logging.Errorf("Error generating socket file path: %v", err)
 		return &server{}, "", err
 	}
 
+	token, err := newToken()
+	if err != nil {
+		logging.Errorf("Error generating handshake token: %v", err)
+		return &server{}, "", err
+	}
+
 	timeoutUds := time.Duration(timeout) * time.Second
 
 	server := &server{
 		podName:        "unvalidated",
 		deviceType:     deviceType,
 		devices:        make(map[string]int),
+		peers:          make(map[string]string),
+		peerFds:        make(map[string]int),
 		udsPath:        udsPath,
 		uds:            udsHandler,
 		bpf:            bpf.NewHandler(),
 		podRes:         resourcesapi.NewHandler(),
+		apiServer:      apiServerFallback,
+		events:         eventRecorder,
+		net:            networking.NewHandler(),
 		udsIdleTimeout: timeoutUds,
 		uid:            user,
+		token:          token,
 	}
 
 	return server, udsPath, nil
 }
 
+/*
+newToken returns a random hex encoded handshake token.
+*/
+func newToken() (string, error) {
+	return randomHex(constants.Uds.TokenBytes)
+}
+
+/*
+newNonce returns a random hex encoded challenge nonce, changed by tests.
+*/
+var newNonce = func() (string, error) {
+	return randomHex(constants.Uds.NonceBytes)
+}
+
+/*
+randomHex returns size random bytes, hex encoded.
+*/
+func randomHex(size int) (string, error) {
+	random := make([]byte, size)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(random), nil
+}
+
 /*
 Start is the public facing method for starting a Server.
-It runs the servers private start method on a Go routine.
+It runs the servers private start method on a Go routine. If the Server panics, it is restarted
+to listen for a new connection from the pod, see crash.Go. If the handshake is refused, the Server
+listens for the pod to retry, until it is locked out, see recordFailure. It also listens for the pod
+to reconnect after dropping the connection by fault injection, see SetFaults. If privileges are
+dropped, the Go routine runs on a thread of its own, as the unprivileged handshake user with only
+the capabilities it needs. The host and API calls of the handshake, see privileged, and the Go
+routines it starts, such as the pod watch, keep the privileges of the process.
 */
 func (s *server) Start() {
-	go s.start()
+	started := false
+	crash.Go("uds server", func() {
+		if started {
+			s.reset()
+		}
+		started = true
+		if dropPrivileges {
+			if err := privileges.DropForHandshake(host.NewHandler(), handshakeUid, handshakeGid); err != nil {
+				logging.Errorf("Not starting UDS server %s: %v", s.udsPath, err)
+				s.privileged(s.removeSocketDir)
+				return
+			}
+		}
+		if !s.register() {
+			logging.Infof("Device plugin shutting down, not starting UDS server: " + s.udsPath)
+			s.privileged(s.removeSocketDir)
+			return
+		}
+		defer s.deregister()
+		for {
+			s.start()
+			if (s.handshake != "refused" && !s.dropped) || s.lockedOut {
+				break
+			}
+			s.reset()
+		}
+		s.privileged(s.removeSocketDir)
+	})
+}
+
+/*
+privileged runs fn with the user and capabilities of the process if the Server dropped them, see
+privileges.RunPrivileged. The host and API calls of the handshake are made through it: creating and
+removing the socket, validating the pod, configuring busy poll, pinning IRQs, reporting events and
+writing the audit file need root, capabilities, or files and connections the handshake user has no
+access to.
+*/
+func (s *server) privileged(fn func()) {
+	if !dropPrivileges {
+		fn()
+		return
+	}
+	privileges.RunPrivileged(fn)
+}
+
+/*
+removeSocketDir removes the directory created to hold the socket of the pod, once the Server has
+stopped and removed the socket, along with the record of the socket. It is not removed after a
+panic, as the Server is restarted.
+*/
+func (s *server) removeSocketDir() {
+	if s.udsPath == "" {
+		return
+	}
+	dir := filepath.Dir(s.udsPath)
+	if err := os.Remove(dir); err != nil && !os.IsNotExist(err) {
+		logging.Warningf("Error removing socket directory %s: %v", dir, err)
+	}
+	if s.net != nil {
+		if err := s.net.RemoveSocket(s.udsPath); err != nil {
+			logging.Warningf("Error removing record of socket %s: %v", s.udsPath, err)
+		}
+	}
+}
+
+/*
+reset clears the state of the connection of a Server that panicked, so the pod is validated again
+when it reconnects.
+*/
+func (s *server) reset() {
+	s.podName = "unvalidated"
+	s.podNamespace = ""
+	s.podUid = ""
+	s.containerId = ""
+	s.podCpus = nil
+	s.refusal = ""
+	s.request = nil
+	s.peer = nil
+	s.peerPid = 0
+	s.nonce = ""
+	s.signed = false
+	s.requests = 0
+	s.served = nil
+	s.handshake = ""
+	s.fdsGranted = 0
+	s.fdsDenied = 0
+	s.dropped = false
+	atomic.StoreInt32(&s.podDeleted, 0)
 }
 
 /*
@@ -130,6 +620,77 @@ func (s *server) AddDevice(dev string, fd int) {
 	s.devices[dev] = fd
 }
 
+/*
+AddDevicePeer adds the bond peer of a netdev, and the peers associated XSK file descriptor.
+The peer is allocated along with the netdev as a single logical device, so it is not counted
+as a device of its own when validating the pod.
+*/
+func (s *server) AddDevicePeer(dev string, peer string, fd int) {
+	if s.peers == nil {
+		s.peers = make(map[string]string)
+	}
+	if s.peerFds == nil {
+		s.peerFds = make(map[string]int)
+	}
+	s.peers[dev] = peer
+	s.peerFds[peer] = fd
+}
+
+/*
+SetPodIrqAffinity sets the Server to pin the queue IRQs of its devices to the exclusive CPUs of
+the pod, once the pod is validated. The CPUs are only known once the pod is running, as the
+kubelet CPU manager assigns them at container creation.
+*/
+func (s *server) SetPodIrqAffinity() {
+	s.podIrqAffinity = true
+}
+
+/*
+SetAllowedPeers restricts the processes that can complete the handshake to those running as one
+of the given UIDs and one of the given GIDs, as reported by the kernel for the connection. An
+empty list allows any ID. This keeps sidecars sharing the mount of the UDS from connecting in
+place of the application.
+*/
+func (s *server) SetAllowedPeers(uids []int, gids []int) {
+	s.allowedUids = uids
+	s.allowedGids = gids
+}
+
+/*
+SetDpdkProfile serves the handshake as the DPDK af_xdp PMD expects it. The PMD sends no handshake
+token, does not request a challenge and does not sign its messages, so they are not required of
+the pod even where the device plugin requires them of other pools. A pod sending them anyway is
+still checked.
+*/
+func (s *server) SetDpdkProfile() {
+	s.dpdk = true
+}
+
+/*
+SetFaults injects faults into the responses of the Server, to test how applications cope with a
+misbehaving device plugin. Once a connection is dropped by fault injection, the Server listens for
+the pod to reconnect. It has no use outside of development and testing.
+*/
+func (s *server) SetFaults(faults Faults) {
+	s.faults = newFaultInjector(faults)
+}
+
+/*
+Token returns the handshake token of the Server, given to the container at allocation. The pod
+sends it in its connection request, proving it holds the allocation the Server was created for.
+*/
+func (s *server) Token() string {
+	return s.token
+}
+
+/*
+SetTrace sets the span of the allocation that created the Server. The Server lifetime, and each
+request it handles, are traced as children of this span.
+*/
+func (s *server) SetTrace(parent *tracing.Span) {
+	s.trace = parent
+}
+
 /*
 start is a private method and the main loop of the Server.
 It listens for and serves a single connection. Across this connection it validates the pod hostname
@@ -138,6 +699,13 @@ and serves XSK file descriptors to the UDS Server app within the pod.
 func (s *server) start() {
 	logging.Debugf("Initialising Unix domain socket: " + s.udsPath)
 
+	s.span = tracing.Start("UDS server", s.trace)
+	s.span.SetAttribute("pool", s.deviceType)
+	defer func() {
+		s.endRequest(nil)
+		s.span.End(nil)
+	}()
+
 	// init
 	if err := s.uds.Init(s.udsPath, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, s.udsIdleTimeout, s.uid); err != nil {
 		logging.Errorf("Error Initialising UDS: %v", err)
@@ -146,42 +714,94 @@ func (s *server) start() {
 
 	logging.Infof("Unix domain socket initialised. Listening for new connection.")
 
-	cleanup, err := s.uds.Listen()
+	listenSpan := tracing.Start("UDS listen", s.span)
+	var listenCleanup uds.CleanupFunc
+	var err error
+	s.privileged(func() { listenCleanup, err = s.uds.Listen() })
+	cleanup := func() { s.privileged(listenCleanup) }
+	listenSpan.End(err)
 	if err != nil {
+		if errors.Is(err, uds.ErrClosed) {
+			logging.Infof("Unix domain socket closed, no longer listening: " + s.udsPath)
+			cleanup()
+			return
+		}
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			logging.Errorf("Listener timed out: %v", err)
+			s.handshakeOutcome("timeout")
 			cleanup()
 			return
 		}
 		logging.Errorf("Listener Accept error: %v", err)
+		s.handshakeOutcome("error")
 		cleanup()
 		return
 	}
 	defer cleanup()
 
+	accepted := time.Now()
+	defer func() {
+		udsConnectionLifetime.Observe(time.Since(accepted).Seconds(), s.pool(), s.connectionOutcome())
+	}()
+
 	logging.Infof("New connection accepted. Waiting for requests.")
 
-	// read incoming request
+	if minimalSyscalls {
+		logging.Debugf("Minimal syscalls, not getting credentials of the connected process")
+	} else if cred, err := s.uds.PeerCred(); err != nil {
+		logging.Warningf("Unable to get credentials of the connected process: %v", err)
+	} else {
+		s.peerPid = cred.Pid
+		s.peer = &audit.Peer{Pid: cred.Pid, Uid: cred.Uid, Gid: cred.Gid}
+	}
+
+	// read incoming request, preceded by a challenge if the pod requests one
 	request, _, err := s.read()
+	if err == nil && request == constants.Uds.Handshake.RequestChallenge {
+		request, err = s.challenge()
+	}
 	if err != nil {
+		if errors.Is(err, uds.ErrClosed) {
+			logging.Infof("Unix domain socket closed before the handshake: " + s.udsPath)
+			return
+		}
 		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 			logging.Errorf("Connection timed out: %v", err)
+			s.handshakeOutcome("timeout")
+			return
+		}
+		if errors.Is(err, errUnsigned) || errors.Is(err, errSignature) {
+			s.handshakeOutcome("refused")
+			s.privileged(func() { s.recordFailure(s.peerKey("", podIdentity{}), "", podIdentity{}) })
 			return
 		}
 		logging.Errorf("Connection read error: %v", err)
+		s.handshakeOutcome("error")
 		return
 	}
 
-	// first request should validate hostname/podname
+	// first request should validate hostname/podname, and optionally the pod name, namespace and UID
 	connected := false
 	var podName string
-	if strings.Contains(request, constants.Uds.Handshake.RequestConnect) {
-		words := strings.Split(request, ",")
-		if len(words) == 2 && words[0] == constants.Uds.Handshake.RequestConnect {
-			podName = strings.ReplaceAll(words[1], " ", "")
-			connected, err = s.validatePod(podName)
+	var hostname string
+	var identity podIdentity
+	backedOff := false
+	if isConnectRequest(request) {
+		var connectOk bool
+		hostname, identity, connectOk = parseConnectRequest(request)
+		if s.signed && identity.token == "" {
+			identity.token = s.token // the signature proves the pod holds the token
+		}
+		if connectOk {
+			backedOff = s.backingOff(s.peerKey(hostname, identity), hostname)
+			if !backedOff && s.checkChallenge(hostname, &identity) && s.checkToken(hostname, identity.token) && s.checkPeerIds(hostname) {
+				s.privileged(func() { podName, connected, err = s.validatePod(hostname, identity) })
+			}
+			if connected {
+				s.privileged(func() { connected = s.checkPeerCgroup(podName) })
+			}
 			if err != nil {
-				logging.Errorf("Error validating host %s: %v", podName, err)
+				logging.Errorf("Error validating host %s: %v", hostname, err)
 				if err := s.write(constants.Uds.Handshake.ResponseError); err != nil {
 					logging.Errorf("Connection write error: %v", err)
 				}
@@ -189,6 +809,11 @@ func (s *server) start() {
 		}
 		if connected {
 			s.podName = podName
+			s.privileged(func() { s.identify(identity) })
+			s.span.SetAttribute("pod", podName)
+			if s.podIrqAffinity {
+				s.privileged(s.pinIrqs)
+			}
 			if err := s.write(constants.Uds.Handshake.ResponseHostOk); err != nil {
 				logging.Errorf("Connection write error: %v", err)
 			}
@@ -196,34 +821,76 @@ func (s *server) start() {
 			if err := s.write(constants.Uds.Handshake.ResponseHostNak); err != nil {
 				logging.Errorf("Connection write error: %v", err)
 			}
+			if hostname != "" {
+				s.privileged(func() { s.recordRefusal(hostname, identity, err) })
+			}
 		}
 	}
 
+	switch {
+	case connected:
+		s.handshakeOutcome("connected")
+	case err != nil:
+		s.handshakeOutcome("error")
+	case backedOff:
+		// refused without validation, so it is not a further failed attempt and the backoff is not extended
+		s.handshakeOutcome("refused")
+	default:
+		s.handshakeOutcome("refused")
+		s.privileged(func() { s.recordFailure(s.peerKey(hostname, identity), hostname, identity) })
+	}
+
+	// the validation holds for the lifetime of the connection, unless the pod is deleted
+	if connected {
+		udsConnections.Add(1, s.pool(), s.podName, s.podNamespace)
+		defer udsConnections.Dec(s.pool(), s.podName, s.podNamespace)
+		s.addConnection()
+		defer s.removeConnection()
+
+		stopWatch := make(chan struct{})
+		defer close(stopWatch)
+		crash.Go("uds pod watch", func() {
+			s.watchPod(stopWatch, time.Duration(atomic.LoadInt64(&podCheckInterval)), cleanup)
+		})
+	}
+
 	// once valid, maintain connection and loop for remaining requests
 	for connected {
 		// read incoming request
 		request, fd, err := s.read()
+		if s.isPodDeleted() {
+			s.logger().Warningf("Pod " + s.podName + " - Pod deleted, connection dropped")
+			return
+		}
 		if err != nil {
+			if errors.Is(err, uds.ErrClosed) {
+				s.logger().Infof("Pod " + s.podName + " - Device plugin shutting down, connection closed")
+				return
+			}
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-				logging.Errorf("Pod "+s.podName+" - Connection timed out: %v", err)
+				s.logger().Errorf("Pod "+s.podName+" - Connection timed out: %v", err)
 				return
 			}
-			logging.Errorf("Pod "+s.podName+" - Connection read error: %v", err)
+			s.logger().Errorf("Pod "+s.podName+" - Connection read error: %v", err)
 			return
 		}
 
 		// process request
-		switch {
-		case strings.Contains(request, constants.Uds.Handshake.RequestFd):
-			err = s.handleFdRequest(request)
+		req := parseRequest(request)
+		switch req.kind {
+		case constants.Uds.Handshake.RequestFd:
+			err = s.handleFdRequest(req)
+
+		case constants.Uds.Handshake.RequestVersion:
+			err = s.handleVersionRequest(req)
 
-		case request == constants.Uds.Handshake.RequestVersion:
-			err = s.write(constants.Uds.Handshake.Version)
+		case constants.Uds.Handshake.RequestBusyPoll:
+			err = s.handleBusyPollRequest(req, fd)
 
-		case strings.Contains(request, constants.Uds.Handshake.RequestBusyPoll):
-			err = s.handleBusyPollRequest(request, fd)
+		case constants.Uds.Handshake.RequestConfig:
+			err = s.handleConfigRequest(req)
 
-		case request == constants.Uds.Handshake.RequestFin:
+		case constants.Uds.Handshake.RequestFin:
 			err = s.write(constants.Uds.Handshake.ResponseFinAck)
 			connected = false
 
@@ -231,58 +898,225 @@ func (s *server) start() {
 			err = s.write(constants.Uds.Handshake.ResponseBadRequest)
 		}
 
+		if errors.Is(err, errIncompatibleClient) {
+			return
+		}
 		if err != nil {
-			logging.Errorf("Pod "+s.podName+" - Error handling request: %v", err)
+			s.logger().Errorf("Pod "+s.podName+" - Error handling request: %v", err)
 			return
 		}
 	}
 }
 
+/*
+read reads the next request on the connection. If the first request of the connection is signed,
+every request must be, and every response is, see uds.Sign. A request whose signature is missing or
+does not verify, possibly injected by another process holding the connection, is an error, as is
+an unsigned first request if signing is required.
+*/
 func (s *server) read() (string, int, error) {
+	if s.dropped {
+		return "", 0, errFaultDrop
+	}
+
 	request, fd, err := s.uds.Read()
+	if errors.Is(err, uds.ErrClosed) {
+		return "", 0, err
+	}
+	if err != nil {
+		s.logger().Errorf("Pod "+s.podName+" - Read error: %v", err)
+		return "", 0, err
+	}
+
+	message, sig := uds.SplitSignature(request)
+	if s.requests == 0 {
+		s.signed = sig != ""
+	}
+	switch {
+	case s.signed && !uds.VerifySignature(s.token, uds.SignRequest, s.requests, message, sig):
+		err = errSignature
+	case !s.signed && sig != "":
+		err = errSignature
+	case !s.signed && requireSigned && !s.dpdk:
+		err = errUnsigned
+	}
 	if err != nil {
-		logging.Errorf("Pod "+s.podName+" - Read error: %v", err)
+		s.logger().Warningf("Pod "+s.podName+" - Request %d refused: %v", s.requests, err)
 		return "", 0, err
 	}
+	request = message
+	s.requests++
 
-	logging.Infof("Pod " + s.podName + " - Request: " + request)
+	redacted := redactRequest(request)
+	s.logger().Infof("Pod " + s.podName + " - Request: " + redacted)
+	s.endRequest(nil)
+	s.request = tracing.Start("UDS request", s.span)
+	s.request.SetAttribute("request", redacted)
 	return request, fd, nil
 }
 
 func (s *server) write(response string) error {
-	logging.Infof("Pod " + s.podName + " - Response: " + response)
-	if err := s.uds.Write(response, -1); err != nil {
+	s.logger().Infof("Pod " + s.podName + " - Response: " + response)
+	s.request.SetAttribute("response", response)
+	if err := s.send(response, -1); err != nil {
+		s.endRequest(err)
 		return err
 	}
+	s.endRequest(nil)
 	return nil
 }
 
 func (s *server) writeWithFD(response string, fd int) error {
-	logging.Infof("Pod " + s.podName + " - Response: " + response + ", FD: " + strconv.Itoa(fd))
-	if err := s.uds.Write(response, fd); err != nil {
+	s.logger().Infof("Pod " + s.podName + " - Response: " + response + ", FD: " + strconv.Itoa(fd))
+	s.request.SetAttribute("response", response)
+	if err := s.send(response, fd); err != nil {
+		s.endRequest(err)
 		return err
 	}
+	s.endRequest(nil)
 	return nil
 }
 
-func (s *server) handleFdRequest(request string) error {
-	words := strings.Split(request, ",")
-	if len(words) != 2 || words[0] != constants.Uds.Handshake.RequestFd {
-		if err := s.write(constants.Uds.Handshake.ResponseBadRequest); err != nil {
-			return err
-		}
-		return nil
+/*
+send writes a response, signed if the connection is signed, with the faults drawn for it injected
+if fault injection is set. A NAK is signed as the response it replaces would be, so it is
+indistinguishable from a real refusal, while a truncated response is cut after signing.
+*/
+func (s *server) send(response string, fd int) error {
+	if s.dropped {
+		return errFaultDrop
 	}
 
-	iface := strings.ReplaceAll(words[1], " ", "")
+	fault, delayed := s.faults.next()
+	if delayed {
+		s.logger().Warningf("Pod "+s.podName+" - Fault injected, response delayed by %v", s.faults.faults.Delay)
+		time.Sleep(s.faults.faults.Delay)
+	}
+	switch fault {
+	case faultDrop:
+		s.logger().Warningf("Pod " + s.podName + " - Fault injected, connection dropped in place of the response")
+		s.dropped = true
+		return errFaultDrop
+	case faultNak:
+		s.logger().Warningf("Pod " + s.podName + " - Fault injected, NAK sent in place of the response")
+		response = nakFor(response)
+		fd = -1
+	}
 
-	if fd, ok := s.devices[iface]; ok {
-		logging.Debugf("Pod " + s.podName + " - Device " + iface + " recognised")
+	message := s.sign(response)
+	if fault == faultTruncate {
+		s.logger().Warningf("Pod " + s.podName + " - Fault injected, response truncated")
+		message = message[:len(message)/2]
+	}
+
+	return s.uds.Write(message, fd)
+}
+
+/*
+sign signs a response to the last request read, if the connection is signed.
+*/
+func (s *server) sign(response string) string {
+	if !s.signed {
+		return response
+	}
+	return uds.Sign(s.token, uds.SignResponse, s.requests-1, response)
+}
+
+/*
+endRequest ends the span of the request being handled, if any. A request is handled once
+responded to, or once the next request is read if it was not responded to.
+*/
+func (s *server) endRequest(err error) {
+	s.request.End(err)
+	s.request = nil
+}
+
+/*
+handshakeOutcome counts the outcome of the pod handshake, and records it on the server span.
+*/
+func (s *server) handshakeOutcome(outcome string) {
+	s.handshake = outcome
+	udsHandshakes.Add(1, s.pool(), outcome)
+	s.span.SetAttribute("outcome", outcome)
+}
+
+/*
+connectionOutcome returns the outcome of a connection, for its lifetime:
+  - ok: the pod was validated and was passed a file descriptor.
+  - fd_nak: the pod was validated, but every file descriptor it requested was refused.
+  - no_fd: the pod was validated, but requested no file descriptor.
+  - host_nak: the pod could not be validated.
+  - timeout: the pod sent no request within the UDS timeout.
+  - error: the handshake failed.
+*/
+func (s *server) connectionOutcome() string {
+	switch s.handshake {
+	case "connected":
+		switch {
+		case s.fdsGranted > 0:
+			return "ok"
+		case s.fdsDenied > 0:
+			return "fd_nak"
+		default:
+			return "no_fd"
+		}
+	case "refused":
+		return "host_nak"
+	case "timeout":
+		return "timeout"
+	default:
+		return "error"
+	}
+}
+
+func (s *server) handleFdRequest(req request) error {
+	if req.malformed {
+		if err := s.write(constants.Uds.Handshake.ResponseBadRequest); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	iface := req.device
+
+	fd, ok := s.devices[iface]
+	if !ok {
+		fd, ok = s.peerFds[iface]
+	}
+	held := true
+	if ok && revalidateFds {
+		s.privileged(func() { held = s.holdsDevice(iface) })
+	}
+	if !held {
+		s.fdsDenied++
+		s.auditFd(iface, audit.Denied, nil)
+		return s.write(constants.Uds.Handshake.ResponseFdNak)
+	}
+	if ok && singleUseFds && s.served[iface] {
+		s.logger().Warningf("Pod " + s.podName + " - Device " + iface + " file descriptor already passed on the connection")
+		s.fdsDenied++
+		s.auditFd(iface, audit.Denied, errdefs.ErrFdAlreadyServed)
+		return s.write(constants.Uds.Handshake.ResponseFdServed)
+	}
+
+	if ok {
+		s.logger().Debugf("Pod " + s.podName + " - Device " + iface + " recognised")
 		if err := s.writeWithFD(constants.Uds.Handshake.ResponseFdAck, fd); err != nil {
+			s.auditFd(iface, audit.Failed, err)
 			return err
 		}
+		s.fdsGranted++
+		s.auditFd(iface, audit.Granted, nil)
+		if singleUseFds {
+			if s.served == nil {
+				s.served = make(map[string]bool)
+			}
+			s.served[iface] = true
+		}
 	} else {
-		logging.Warningf("Pod " + s.podName + " - Device " + iface + " not recognised")
+		s.logger().Warningf("Pod " + s.podName + " - Device " + iface + " not recognised")
+		s.fdsDenied++
+		s.auditFd(iface, audit.Denied, nil)
 		if err := s.write(constants.Uds.Handshake.ResponseFdNak); err != nil {
 			return err
 		}
@@ -290,41 +1124,137 @@ func (s *server) handleFdRequest(request string) error {
 	return nil
 }
 
-func (s *server) handleBusyPollRequest(request string, fd int) error {
-	if fd <= 0 {
-		logging.Errorf("Pod " + s.podName + " - Invalid file descriptor")
-		if err := s.write(constants.Uds.Handshake.ResponseBusyPollNak); err != nil {
-			return err
+/*
+holdsDevice checks the connected pod still holds the device, or the device the bond peer is paired
+with, in the cached pod resources, so a file descriptor is not passed for a device allocated to
+another pod since the pod connected. If the pod resources cannot be read, the pod is taken not to.
+*/
+func (s *server) holdsDevice(iface string) bool {
+	device := iface
+	if _, ok := s.devices[iface]; !ok {
+		for dev, peer := range s.peers {
+			if peer == iface {
+				device = dev
+			}
 		}
 	}
 
-	words := strings.Split(request, ",")
-	if len(words) != 3 || words[0] != constants.Uds.Handshake.RequestBusyPoll {
+	pods, err := s.podRes.GetPodResources()
+	if err != nil {
+		s.logger().Warningf("Pod "+s.podName+" - Unable to check the pod still holds device %s: %v", device, err)
+		return false
+	}
+	for _, pod := range pods {
+		if pod.GetName() != s.podName || (s.podNamespace != "" && pod.GetNamespace() != s.podNamespace) {
+			continue
+		}
+		if s.podDevices(pod)[device] {
+			return true
+		}
+	}
+
+	s.logger().Warningf("Pod " + s.podName + " - Device " + device + " no longer allocated to the pod")
+	return false
+}
+
+/*
+auditFd records a request of the pod for the file descriptor of a device in the audit file.
+*/
+func (s *server) auditFd(device string, outcome string, err error) {
+	record := audit.Record{
+		Pool:      s.deviceType,
+		Pod:       s.podName,
+		Namespace: s.podNamespace,
+		Device:    device,
+		Peer:      s.peer,
+		Outcome:   outcome,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	s.privileged(func() { audit.Write(record) })
+}
+
+func (s *server) handleConfigRequest(req request) error {
+	if req.malformed {
 		if err := s.write(constants.Uds.Handshake.ResponseBadRequest); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	timeoutString := strings.ReplaceAll(words[1], " ", "")
-	budgetString := strings.ReplaceAll(words[2], " ", "")
+	iface := req.device
 
-	timeout, err := strconv.Atoi(timeoutString)
-	if err != nil {
-		logging.Errorf("Pod "+s.podName+" - Error converting busy timeout to int: %v", err)
-		return err
+	if _, ok := s.devices[iface]; !ok {
+		s.logger().Warningf("Pod " + s.podName + " - Device " + iface + " not recognised")
+		return s.write(constants.Uds.Handshake.ResponseConfigNak)
 	}
 
-	budget, err := strconv.Atoi(budgetString)
-	if err != nil {
-		logging.Errorf("Pod "+s.podName+" - Error converting busy budget to int: %v", err)
-		return err
+	response := constants.Uds.Handshake.ResponseConfigAck
+	if peer, ok := s.peers[iface]; ok {
+		response += ", peer=" + peer
+	}
+
+	return s.write(response)
+}
+
+/*
+handleVersionRequest handles the version request. The extended version request, "/version, build",
+is answered with the handshake version, as a plain version request is, followed by the version,
+git commit and build date of the device plugin, to aid client side diagnostics.
+*/
+func (s *server) handleVersionRequest(req request) error {
+	if req.malformed {
+		return s.write(constants.Uds.Handshake.ResponseBadRequest)
+	}
+	if req.client != "" {
+		refusal, ok := checkClient(req.client)
+		if !ok {
+			return s.write(constants.Uds.Handshake.ResponseBadRequest)
+		}
+		if refusal != "" {
+			s.logger().Warningf("Pod " + s.podName + " - Client library refused: " + refusal)
+			if err := s.write(constants.Uds.Handshake.ResponseVersionNak + ", " + refusal); err != nil {
+				return err
+			}
+			return errIncompatibleClient
+		}
+		s.logger().Infof("Pod " + s.podName + " - Client library " + req.client)
+	}
+	if !req.build {
+		return s.write(constants.Uds.Handshake.Version)
 	}
 
-	logging.Infof("Pod " + s.podName + " - Configuring busy poll, FD: " + strconv.Itoa(fd) + ", Timeout: " + timeoutString + ", Budget: " + budgetString)
+	return s.write(constants.Uds.Handshake.Version + ", version=" + constants.Plugins.Version +
+		", commit=" + constants.Plugins.Commit + ", built=" + constants.Plugins.BuildDate)
+}
 
-	if err := s.bpf.ConfigureBusyPoll(fd, timeout, budget); err != nil {
-		logging.Errorf("Error configuring busy poll: %v", err)
+func (s *server) handleBusyPollRequest(req request, fd int) error {
+	if fd <= 0 {
+		s.logger().Errorf("Pod " + s.podName + " - Invalid file descriptor")
+		if err := s.write(constants.Uds.Handshake.ResponseBusyPollNak); err != nil {
+			return err
+		}
+	}
+
+	if req.malformed {
+		if err := s.write(constants.Uds.Handshake.ResponseBadRequest); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if req.err != nil {
+		s.logger().Errorf("Pod "+s.podName+" - Error converting busy timeout or budget to int: %v", req.err)
+		return req.err
+	}
+
+	s.logger().Infof("Pod " + s.podName + " - Configuring busy poll, FD: " + strconv.Itoa(fd) + ", Timeout: " + strconv.Itoa(req.busyTimeout) + ", Budget: " + strconv.Itoa(req.busyBudget))
+
+	var err error
+	s.privileged(func() { err = s.bpf.ConfigureBusyPoll(fd, req.busyTimeout, req.busyBudget) })
+	if err != nil {
+		s.logger().Errorf("Error configuring busy poll: %v", err)
 		if err := s.write(constants.Uds.Handshake.ResponseBusyPollNak); err != nil {
 			logging.Errorf("Connection write error: %v", err)
 		}
@@ -337,53 +1267,905 @@ func (s *server) handleBusyPollRequest(request string, fd int) error {
 	return nil
 }
 
-func (s *server) validatePod(podName string) (bool, error) {
-	logging.Debugf("Pod " + podName + " - Validating pod hostname")
+/*
+podCandidate is a pod name the connecting pod may have, and the identity field it was taken from.
+*/
+type podCandidate struct {
+	field string
+	name  string
+}
+
+/*
+podCandidates returns the names the connecting pod may have, most specific first. The hostname
+differs from the pod name if the pod spec sets a hostname, and may be qualified by a subdomain,
+so the pod name sent by the pod is tried first, then the hostname and the hostname without its
+domain, and finally the pod the CNI recorded attaching the devices of this Server to, if its UID
+matches the UID sent by the pod.
+*/
+func (s *server) podCandidates(hostname string, identity podIdentity) []podCandidate {
+	var candidates []podCandidate
+	add := func(field string, name string) {
+		if name == "" {
+			return
+		}
+		for _, candidate := range candidates {
+			if candidate.name == name {
+				return
+			}
+		}
+		candidates = append(candidates, podCandidate{field: field, name: name})
+	}
+
+	add("name", identity.name)
+	add("hostname", hostname)
+	add("hostname without domain", strings.SplitN(hostname, ".", 2)[0])
+	if identity.uid != "" {
+		add("uid", s.podWithUid(identity))
+	}
+
+	return candidates
+}
+
+/*
+identify records the UID of the connected pod and the ID of the container the CNI attached the
+devices of this Server to, so log lines can be tied to the exact pod instance. The UID sent by the
+pod is used if any, otherwise the UID recorded by the CNI.
+*/
+func (s *server) identify(identity podIdentity) {
+	s.podUid = identity.uid
+
+	allocations, err := s.net.GetAllocations()
+	if err != nil {
+		logging.Warningf("Pod "+s.podName+" - Unable to identify the pod container: %v", err)
+		return
+	}
+
+	for dev := range s.devices {
+		allocation, ok := allocations[dev]
+		if !ok || allocation.Pod != s.podName || (s.podNamespace != "" && allocation.Namespace != s.podNamespace) {
+			continue
+		}
+		if s.podUid == "" {
+			s.podUid = allocation.PodUid
+		}
+		s.containerId = allocation.Owner
+		return
+	}
+}
+
+/*
+logger returns a log entry tagged with the connected pod, its UID and container ID, where known.
+*/
+func (s *server) logger() *logging.Entry {
+	return logging.WithFields(logformats.Workload(s.podNamespace, s.podName, s.podUid, s.containerId))
+}
+
+/*
+podWithUid returns the name of the pod the CNI recorded attaching the devices of this Server to,
+if it recorded the pod UID sent by the pod, and the pod namespace matches where sent.
+*/
+func (s *server) podWithUid(identity podIdentity) string {
+	allocations, err := s.net.GetAllocations()
+	if err != nil {
+		logging.Warningf("Error getting device allocations: %v", err)
+		return ""
+	}
+
+	for dev := range s.devices {
+		allocation, ok := allocations[dev]
+		if !ok || allocation.PodUid != identity.uid {
+			continue
+		}
+		if identity.namespace == "" || allocation.Namespace == identity.namespace {
+			return allocation.Pod
+		}
+	}
+
+	return ""
+}
+
+/*
+validatePod validates that the connecting pod is the pod the devices of this Server were allocated
+to, returning the pod name. Hostnames are not unique across namespaces and can be overridden in
+the pod spec, so pods may also send their name, tried before the hostname, their namespace, matched
+against the pod resources, and their UID, matched against the pod UID the CNI recorded when
+attaching the devices. See podCandidates for the names tried.
+Pod resources are cached, and a newly created pod may be missing from resources cached just
+before it was created, so a pod that cannot be validated is checked again with fresh resources.
+If the pod resources API is unavailable and the API server fallback is enabled, the pod is
+validated through the API server instead. If the pod is not valid, the reason each name did not
+match is logged.
+*/
+func (s *server) validatePod(hostname string, identity podIdentity) (string, bool, error) {
+	candidates := s.podCandidates(hostname, identity)
+
+	podName, valid, mismatches, err := s.checkCandidates(candidates, identity.namespace)
+	if err == nil && !valid {
+		podName, valid, mismatches, err = s.revalidatePod(candidates, identity.namespace)
+	}
+	if err != nil && s.apiServer != nil {
+		logging.Warningf("Pod "+hostname+" - Pod resources API unavailable, validating through the API server: %v", err)
+		for _, candidate := range candidates {
+			podName = candidate.name
+			if valid, err = s.checkApiServerPod(podName, identity); err != nil || valid {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return hostname, false, err
+	}
+	if !valid {
+		if len(mismatches) > 0 {
+			logging.Warningf("Pod " + hostname + " could not be validated for this UDS connection: " + strings.Join(mismatches, "; "))
+			s.refusal = strings.Join(mismatches, "; ")
+		}
+		return hostname, false, nil
+	}
+	if podName != hostname {
+		logging.Infof("Pod "+hostname+" - Validated as pod %s, matched on %s", podName, candidateField(candidates, podName))
+	}
+	if identity.uid == "" {
+		return podName, true, nil
+	}
+
+	valid, err = s.checkPodUid(podName, identity.uid)
+	if err == nil && !valid {
+		s.refusal = "the pod UID does not match the UID of the pod the devices were attached to"
+	}
+	return podName, valid, err
+}
+
+/*
+recordRefusal reports a refused handshake as a Kubernetes Event on the pod, so that the reason
+shows in kubectl describe rather than only in the device plugin logs.
+*/
+func (s *server) recordRefusal(hostname string, identity podIdentity, err error) {
+	if s.events == nil {
+		return
+	}
+
+	pod := identity.name
+	if pod == "" {
+		pod = hostname
+	}
+
+	message := "UDS handshake refused for " + s.deviceType + " devices " + strings.Join(s.sortedDevices(), ", ") + ": "
+	switch {
+	case err != nil:
+		message += "the pod could not be validated: " + err.Error()
+	case s.refusal != "":
+		message += s.refusal
+	default:
+		message += "no pod named " + pod + " on this node was allocated the devices, check the pod hostname or set " + constants.Uds.PodNameEnvVar
+	}
+
+	event := &apiserver.Event{
+		Pod:       pod,
+		Namespace: identity.namespace,
+		Uid:       identity.uid,
+		Reason:    constants.Events.HandshakeRefused,
+		Message:   message,
+	}
+	if err := s.events.CreateEvent(event); err != nil {
+		logging.Warningf("Pod "+pod+" - Unable to report refused handshake as an event: %v", err)
+	}
+}
+
+/*
+sortedDevices returns the devices of the Server, sorted by name.
+*/
+func (s *server) sortedDevices() []string {
+	devices := make([]string, 0, len(s.devices))
+	for dev := range s.devices {
+		devices = append(devices, dev)
+	}
+	sort.Strings(devices)
+	return devices
+}
+
+/*
+peerFailures counts the failed handshakes of a peer of the UDS.
+*/
+type peerFailures struct {
+	failures int       // failed handshakes of the peer
+	retryAt  time.Time // time before which a further handshake of the peer is refused without validation
+}
+
+/*
+peerKey identifies the peer of a handshake, so that failed handshakes are counted against the peer
+making them. The credentials of the connected process are used where known, as the process cannot
+forge them, otherwise the pod it claims to be, falling back to the hostname it sent.
+*/
+func (s *server) peerKey(hostname string, identity podIdentity) string {
+	switch {
+	case s.peer != nil:
+		return "uid " + strconv.Itoa(int(s.peer.Uid)) + " gid " + strconv.Itoa(int(s.peer.Gid))
+	case identity.name != "":
+		return "pod " + identity.namespace + "/" + identity.name
+	default:
+		return "host " + hostname
+	}
+}
+
+/*
+backingOff returns true if a handshake arrives before the backoff after the last failed handshake
+of the peer has passed, in which case it is refused without being validated.
+*/
+func (s *server) backingOff(key string, hostname string) bool {
+	peer, ok := s.failures[key]
+	if ok && time.Now().Before(peer.retryAt) {
+		logging.Warningf("Pod "+hostname+" - Handshake refused, retried within %v of %d failed handshakes by %s", time.Until(peer.retryAt).Round(time.Millisecond), peer.failures, key)
+		s.refusal = "the handshake was retried too soon after a failed handshake"
+		return true
+	}
+	return false
+}
+
+/*
+recordFailure records a failed handshake of a peer on the UDS, limiting attempts to brute force the
+pod validation. Each failure refuses further handshakes of the peer without validation for a backoff,
+doubling with each failure. Handshakes refused during the backoff are not failures, so retrying too
+soon does not extend the backoff. Once the failures of a peer reach the lockout threshold, the Server
+stops responding and the lockout is reported as an event, as the pod then has to be restarted to be
+allocated a new UDS.
+*/
+func (s *server) recordFailure(key string, hostname string, identity podIdentity) {
+	if s.failures == nil {
+		s.failures = make(map[string]*peerFailures)
+	}
+	peer, ok := s.failures[key]
+	if !ok {
+		peer = &peerFailures{}
+		s.failures[key] = peer
+	}
+	peer.failures++
+
+	backoff := time.Duration(constants.Uds.LockoutBackoff) * time.Second << uint(peer.failures-1)
+	if max := time.Duration(constants.Uds.LockoutBackoffMax) * time.Second; backoff > max || backoff <= 0 {
+		backoff = max
+	}
+	peer.retryAt = time.Now().Add(backoff)
+
+	if peer.failures < constants.Uds.LockoutThreshold {
+		return
+	}
+
+	s.lockedOut = true
+	logging.Warningf("UDS %s locked out after %d failed handshakes by %s, no longer responding", s.udsPath, peer.failures, key)
+
+	if s.events == nil {
+		return
+	}
+	pod := identity.name
+	if pod == "" {
+		pod = hostname
+	}
+	if pod == "" {
+		return
+	}
+	event := &apiserver.Event{
+		Pod:       pod,
+		Namespace: identity.namespace,
+		Uid:       identity.uid,
+		Reason:    constants.Events.HandshakeLockout,
+		Message: "UDS for " + s.deviceType + " devices " + strings.Join(s.sortedDevices(), ", ") + " stopped responding after " +
+			strconv.Itoa(peer.failures) + " failed handshakes, restart the pod to retry",
+	}
+	if err := s.events.CreateEvent(event); err != nil {
+		logging.Warningf("Pod "+pod+" - Unable to report handshake lockout as an event: %v", err)
+	}
+}
+
+func candidateField(candidates []podCandidate, name string) string {
+	for _, candidate := range candidates {
+		if candidate.name == name {
+			return candidate.field
+		}
+	}
+	return ""
+}
+
+/*
+checkCandidates checks each candidate name against the pod resources, returning the first valid
+pod name, or the reason each candidate did not match. The pod resources are fetched once for all
+the candidates, as each fetch copies the resources of every pod on the node.
+*/
+func (s *server) checkCandidates(candidates []podCandidate, namespace string) (string, bool, []string, error) {
+	var mismatches []string
 
 	podResourceMap, err := s.podRes.GetPodResources()
 	if err != nil {
 		logging.Errorf("Error getting pod resources: %v", err)
-		return false, err
+		return "", false, nil, err
 	}
 
-	if _, ok := podResourceMap[podName]; ok {
-		logging.Debugf("Pod " + podName + " - Found on node")
-	} else {
-		logging.Warningf("Pod " + podName + " - Not found on node")
-		return false, nil
+	for _, candidate := range candidates {
+		valid, mismatch := s.checkPodResources(podResourceMap, candidate.name, namespace)
+		if valid {
+			return candidate.name, true, nil, nil
+		}
+		mismatches = append(mismatches, candidate.field+" "+candidate.name+": "+mismatch)
 	}
 
-	pod := podResourceMap[podName]
-	valid := false
+	return "", false, mismatches, nil
+}
 
-	for _, container := range pod.GetContainers() {
-		var contDevs []string
+/*
+revalidatePod checks the candidates again with fresh resources. If the pod namespace is known and the
+kubelet implements it, only the resources of each candidate pod are fetched using Get, rather than
+listing every pod.
+*/
+func (s *server) revalidatePod(candidates []podCandidate, namespace string) (string, bool, []string, error) {
+	if namespace != "" {
+		var mismatches []string
+		for _, candidate := range candidates {
+			pod, err := s.podRes.GetPodResource(candidate.name, namespace)
+			if err != nil {
+				if !resourcesapi.IsUnimplemented(err) {
+					logging.Debugf("Pod "+candidate.name+" - Pod resources Get failed, revalidating with List: %v", err)
+				}
+				mismatches = nil
+				break
+			}
+			logging.Debugf("Pod " + candidate.name + " - Revalidating with pod resources Get")
+			if s.checkPodDevices(candidate.name, *pod) {
+				return candidate.name, true, nil, nil
+			}
+			mismatches = append(mismatches, candidate.field+" "+candidate.name+": "+s.devicesMismatch(namespace, candidate.name))
+		}
+		if mismatches != nil {
+			return "", false, mismatches, nil
+		}
+	}
+
+	logging.Debugf("Revalidating with fresh pod resources")
+	s.podRes.InvalidatePodResources()
+
+	return s.checkCandidates(candidates, namespace)
+}
+
+/*
+checkPodResources checks podName against the pod resources, keyed on namespace/name, returning the
+reason it did not match if the pod is not valid. If the namespace is known the pod is looked up
+directly, otherwise every pod is searched for one of the name.
+*/
+func (s *server) checkPodResources(podResourceMap map[string]api.PodResources, podName string, namespace string) (bool, string) {
+	logging.Debugf("Pod " + podName + " - Validating pod name")
 
+	if pod, ok := podResourceMap[namespace+"/"+podName]; ok && namespace != "" {
+		logging.Debugf("Pod " + podName + " - Found on node in namespace " + namespace)
+		if s.checkPodDevices(podName, pod) {
+			return true, ""
+		}
+		return false, s.devicesMismatch(namespace, podName)
+	}
+
+	var namespaces []string
+	found := false
+	foundNamespace := ""
+	for _, pod := range podResourceMap {
+		if pod.GetName() != podName {
+			continue
+		}
+		if namespace != "" && pod.GetNamespace() != namespace {
+			namespaces = append(namespaces, pod.GetNamespace())
+			continue
+		}
+		found = true
+		foundNamespace = pod.GetNamespace()
+		logging.Debugf("Pod " + podName + " - Found on node in namespace " + pod.GetNamespace())
+
+		if s.checkPodDevices(podName, pod) {
+			return true, ""
+		}
+	}
+
+	switch {
+	case found:
+		return false, s.devicesMismatch(foundNamespace, podName)
+	case len(namespaces) > 0:
+		sort.Strings(namespaces)
+		return false, "no pod of this name in namespace " + namespace + ", found in namespace " + strings.Join(namespaces, ", ")
+	default:
+		return false, "no pod of this name on node"
+	}
+}
+
+/*
+devicesMismatch describes a pod that was found but was not allocated the devices of this Server.
+*/
+func (s *server) devicesMismatch(namespace string, podName string) string {
+	var devices []string
+	for dev := range s.devices {
+		devices = append(devices, dev)
+	}
+	sort.Strings(devices)
+
+	return "pod " + namespace + "/" + podName + " was not allocated devices " + strings.Join(devices, ", ") + " of " + s.deviceType
+}
+
+/*
+checkPodDevices returns true if the devices of this Server are a subset of the devices of this
+Server type allocated to the pod, recording the pod namespace and the exclusive CPUs of the
+container they belong to.
+A pod may request devices in several containers, or in several resource requests of one container,
+and the Server may hold only some of them. Devices split across containers are valid, taking the
+CPUs of the first container holding one of them.
+*/
+func (s *server) checkPodDevices(podName string, pod api.PodResources) bool {
+	if len(s.devices) == 0 {
+		return false
+	}
+
+	podDevs := make(map[string]bool)
+	var owner *api.ContainerResources
+
+	for _, container := range pod.GetContainers() {
+		contDevs := make(map[string]bool)
 		for _, devType := range container.GetDevices() {
 			if devType.GetResourceName() == s.deviceType {
-				contDevs = append(contDevs, devType.GetDeviceIds()...)
+				for _, dev := range devType.GetDeviceIds() {
+					contDevs[dev] = true
+					podDevs[dev] = true
+				}
+			}
+		}
 
+		// compare known devices (from Allocate) vs devices from resource api
+		if owner == nil && s.devicesIn(contDevs) {
+			owner = container
+		}
+	}
+
+	if owner == nil {
+		if !s.devicesIn(podDevs) {
+			return false
+		}
+		for _, container := range pod.GetContainers() {
+			if owner == nil && s.holdsAnyDevice(container) {
+				owner = container
 			}
 		}
+		logging.Debugf("Pod " + podName + " - Devices are split across containers")
+	}
 
-		if len(contDevs) == len(s.devices) {
-			// compare known devices (from Allocate) vs devices from resource api
-			for _, dev := range contDevs {
-				if _, exists := s.devices[dev]; exists {
-					valid = true // valid while devices match
-				} else {
-					valid = false
-					break // not valid if any device does not match
-				}
+	s.podNamespace = pod.GetNamespace()
+	s.podCpus = nil
+	for _, cpu := range owner.GetCpuIds() {
+		s.podCpus = append(s.podCpus, int(cpu))
+	}
+	logging.Infof("Pod " + podName + " is valid for this UDS connection")
+
+	return true
+}
+
+/*
+devicesIn returns true if every device of this Server is in the set.
+*/
+func (s *server) devicesIn(set map[string]bool) bool {
+	for dev := range s.devices {
+		if !set[dev] {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+holdsAnyDevice returns true if any device of this Server is allocated to the container.
+*/
+func (s *server) holdsAnyDevice(container *api.ContainerResources) bool {
+	for _, devType := range container.GetDevices() {
+		if devType.GetResourceName() != s.deviceType {
+			continue
+		}
+		for _, dev := range devType.GetDeviceIds() {
+			if _, exists := s.devices[dev]; exists {
+				return true
 			}
 		}
+	}
+	return false
+}
 
-		if valid {
-			logging.Infof("Pod " + podName + " is valid for this UDS connection")
+/*
+checkApiServerPod validates the pod through the API server. The API server does not know which
+devices were allocated to a pod, only how many each container requested, so the pod containers
+must together request at least as many devices of this Server type as the Server has, and the pod
+must be the one the CNI recorded attaching the devices to, where recorded. The pod UID, if sent, is matched directly.
+Exclusive CPUs are not known, so queue IRQs are not pinned to pod CPUs.
+*/
+func (s *server) checkApiServerPod(podName string, identity podIdentity) (bool, error) {
+	pods, err := s.apiServer.GetNodePods()
+	if err != nil {
+		logging.Errorf("Error getting pods from the API server: %v", err)
+		return false, err
+	}
+
+	for _, pod := range pods {
+		if pod.Name != podName || (identity.namespace != "" && pod.Namespace != identity.namespace) {
+			continue
+		}
+		if identity.uid != "" && pod.Uid != identity.uid {
+			logging.Warningf("Pod "+podName+" - UID %s does not match UID %s known to the API server", identity.uid, pod.Uid)
+			continue
+		}
+
+		requested := 0
+		for _, container := range pod.Containers {
+			requested += container.Resources[s.deviceType]
+		}
+		if len(s.devices) == 0 || requested < len(s.devices) {
+			continue
+		}
+
+		attached, err := s.attachedTo(pod.Name, pod.Namespace)
+		if err != nil {
+			return false, err
+		}
+		if attached {
+			s.podNamespace = pod.Namespace
+			s.podCpus = nil
+			logging.Infof("Pod " + podName + " is valid for this UDS connection, validated through the API server")
 			return true, nil
 		}
 	}
 
-	logging.Warningf("Pod " + podName + " could not be validated for this UDS connection")
+	logging.Warningf("Pod " + podName + " could not be validated for this UDS connection through the API server")
 	return false, nil
 }
+
+/*
+attachedTo returns false if the CNI recorded attaching any device of this Server to a pod other
+than the named pod.
+*/
+func (s *server) attachedTo(podName string, namespace string) (bool, error) {
+	allocations, err := s.net.GetAllocations()
+	if err != nil {
+		logging.Errorf("Error getting device allocations: %v", err)
+		return false, err
+	}
+
+	for dev := range s.devices {
+		allocation, ok := allocations[dev]
+		if !ok || allocation.Pod == "" {
+			continue
+		}
+		if allocation.Pod != podName || allocation.Namespace != namespace {
+			logging.Warningf("Pod "+podName+" - Device %s was attached to pod %s/%s", dev, allocation.Namespace, allocation.Pod)
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+/*
+checkPodUid validates the pod UID against the allocation records the CNI wrote when attaching
+the devices of this Server. Container runtimes that do not pass the pod UID to the CNI leave it
+unrecorded, in which case the UID cannot be checked and the pod is validated without it.
+*/
+func (s *server) checkPodUid(podName string, uid string) (bool, error) {
+	allocations, err := s.net.GetAllocations()
+	if err != nil {
+		logging.Errorf("Error getting device allocations: %v", err)
+		return false, err
+	}
+
+	checked := false
+	for dev := range s.devices {
+		allocation, ok := allocations[dev]
+		if !ok || allocation.PodUid == "" {
+			continue
+		}
+		if allocation.PodUid != uid {
+			logging.Warningf("Pod "+podName+" - UID %s does not match UID %s of the pod device %s was attached to", uid, allocation.PodUid, dev)
+			return false, nil
+		}
+		checked = true
+	}
+
+	if !checked {
+		logging.Warningf("Pod " + podName + " - UID not recorded by the CNI, the pod UID could not be validated")
+	}
+
+	return true, nil
+}
+
+/*
+challenge answers a challenge request with a new nonce, which the connection request must then
+answer, and returns the connection request.
+*/
+func (s *server) challenge() (string, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return "", err
+	}
+	s.nonce = nonce
+
+	if err := s.write(constants.Uds.Handshake.ResponseChallenge + ", " + s.nonce); err != nil {
+		return "", err
+	}
+
+	request, _, err := s.read()
+	return request, err
+}
+
+/*
+checkChallenge checks the connection request answers the challenge issued on the connection, so
+a connection request captured from another connection cannot be replayed. The pod either echoes
+the nonce or, if it was given a handshake token, sends the proof of the nonce in place of the
+token, in which case the pod is taken to have sent the token. A nonce answers a single request.
+A pod that did not request a challenge is refused if challenges are required, and otherwise
+allowed, as applications predating challenges do not request one.
+*/
+func (s *server) checkChallenge(hostname string, identity *podIdentity) bool {
+	nonce := s.nonce
+	s.nonce = ""
+
+	refuse := func(reason string) bool {
+		logging.Warningf("Pod "+hostname+" - Connection request refused, %s", reason)
+		s.refusal = reason
+		return false
+	}
+
+	switch {
+	case nonce == "" && (identity.nonce != "" || identity.proof != ""):
+		return refuse("the connection request answers a challenge that was not issued on this connection")
+	case nonce == "" && requireChallenge && !s.dpdk:
+		return refuse("a challenge is required, send " + constants.Uds.Handshake.RequestChallenge + " before the connection request")
+	case nonce == "":
+		return true
+	case identity.proof != "":
+		if s.token == "" || !hmac.Equal([]byte(identity.proof), []byte(uds.ChallengeProof(s.token, nonce))) {
+			return refuse("the connection request does not answer the challenge")
+		}
+		identity.token = s.token
+		return true
+	case subtle.ConstantTimeCompare([]byte(identity.nonce), []byte(nonce)) == 1:
+		return true
+	default:
+		return refuse("the connection request does not answer the challenge")
+	}
+}
+
+/*
+checkToken checks the handshake token sent by the pod against the token given to the container
+at allocation, binding access to the UDS to the allocation rather than to the socket path.
+A wrong token is refused. A missing token, as sent by applications predating handshake tokens,
+is refused if the token is required, and otherwise allowed with a warning.
+*/
+func (s *server) checkToken(hostname string, token string) bool {
+	switch {
+	case token == "" && requireToken && !s.dpdk:
+		logging.Warningf("Pod " + hostname + " - Handshake token missing from the connection request")
+		s.refusal = "the handshake token is missing, set " + constants.Uds.TokenEnvVar + " in the connection request"
+		return false
+	case token == "" && s.dpdk:
+		logging.Debugf("Pod " + hostname + " - No handshake token in the connection request, as expected of DPDK")
+		return true
+	case token == "":
+		logging.Warningf("Pod " + hostname + " - Handshake token missing from the connection request, the allocation cannot be verified")
+		return true
+	case s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1:
+		logging.Warningf("Pod " + hostname + " - Handshake token does not match the token of the allocation")
+		s.refusal = "the handshake token does not match the token of the allocation"
+		return false
+	}
+	return true
+}
+
+/*
+checkPeerIds checks the UID and GID of the process connected to the UDS are allowed for the pool.
+A process whose credentials could not be read is refused when either is restricted.
+*/
+func (s *server) checkPeerIds(hostname string) bool {
+	if len(s.allowedUids) == 0 && len(s.allowedGids) == 0 {
+		return true
+	}
+
+	refuse := func(reason string) bool {
+		logging.Warningf("Pod "+hostname+" - Connection request refused, %s", reason)
+		s.refusal = reason
+		return false
+	}
+
+	switch {
+	case s.peer == nil:
+		return refuse("the credentials of the connected process are unknown")
+	case !containsId(s.allowedUids, s.peer.Uid):
+		return refuse("the connected process runs as UID " + strconv.FormatUint(uint64(s.peer.Uid), 10) + ", which is not allowed for the pool")
+	case !containsId(s.allowedGids, s.peer.Gid):
+		return refuse("the connected process runs as GID " + strconv.FormatUint(uint64(s.peer.Gid), 10) + ", which is not allowed for the pool")
+	}
+	return true
+}
+
+/*
+containsId returns true if the ID is in the list, or the list is empty.
+*/
+func containsId(ids []int, id uint32) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for _, allowed := range ids {
+		if uint32(allowed) == id {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+checkPeerCgroup verifies the process connected to the UDS runs in the validated pod, by matching
+the pod UID in the cgroups of the process against the pod UID the CNI recorded when attaching the
+devices of this Server. The pod name, namespace and UID are sent by the pod, so this ties the
+connection to the pod the devices were attached to rather than the pod it claims to be.
+A process in another pod, or in no pod, is refused. A process that cannot be verified, as it is
+not visible from the PID namespace of the device plugin or the CNI did not record the pod UID, is
+refused if peer cgroup verification is required, and otherwise allowed with a warning.
+*/
+func (s *server) checkPeerCgroup(podName string) bool {
+	unverified := func(reason string) bool {
+		if requirePeerCgroup {
+			logging.Warningf("Pod "+podName+" - Connected process refused, it could not be verified to run in the pod: %s", reason)
+			s.refusal = "the connected process could not be verified to run in the pod: " + reason
+			return false
+		}
+		logging.Warningf("Pod "+podName+" - Connected process could not be verified to run in the pod: %s", reason)
+		return true
+	}
+
+	expUid, err := s.recordedPodUid()
+	if err != nil {
+		return unverified(err.Error())
+	}
+	if expUid == "" {
+		return unverified("pod UID not recorded by the CNI")
+	}
+
+	uid, err := cgroups.PodUid(procRoot, s.peerPid)
+	if err != nil {
+		return unverified(err.Error())
+	}
+
+	switch uid {
+	case expUid:
+		return true
+	case "":
+		logging.Warningf("Pod "+podName+" - Connected process %d does not run in a pod", s.peerPid)
+		s.refusal = "the connected process does not run in a pod"
+	default:
+		logging.Warningf("Pod "+podName+" - Connected process %d runs in pod %s, not pod %s the devices were attached to", s.peerPid, uid, expUid)
+		s.refusal = "the connected process runs in another pod than the one the devices were attached to"
+	}
+	return false
+}
+
+/*
+recordedPodUid returns the pod UID the CNI recorded when attaching the devices of this Server,
+or an empty string if it was not recorded.
+*/
+func (s *server) recordedPodUid() (string, error) {
+	allocations, err := s.net.GetAllocations()
+	if err != nil {
+		return "", err
+	}
+
+	for dev := range s.devices {
+		if allocation, ok := allocations[dev]; ok && allocation.PodUid != "" {
+			return allocation.PodUid, nil
+		}
+	}
+
+	return "", nil
+}
+
+/*
+watchPod checks the connected pod still exists every interval until the stop channel is closed.
+A process may outlive its pod, so once the pod is deleted the connection is marked as such and
+dropped, by calling drop, so no further file descriptors can be requested over it.
+*/
+func (s *server) watchPod(stop <-chan struct{}, interval time.Duration, drop func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if s.podExists() {
+				continue
+			}
+			s.logger().Warningf("Pod " + s.podName + " - Pod deleted or no longer allocated the devices, dropping connection")
+			atomic.StoreInt32(&s.podDeleted, 1)
+			drop()
+			return
+		}
+	}
+}
+
+/*
+podExists returns true if the connected pod is still allocated the devices of this Server.
+A pod missing from the pod resources is looked up again with fresh resources before being
+considered deleted, as the pod resources may predate the pod. If the pod resources cannot be
+read, the pod is assumed to exist.
+*/
+func (s *server) podExists() bool {
+	for attempt := 0; attempt < 2; attempt++ {
+		if attempt > 0 {
+			s.podRes.InvalidatePodResources()
+		}
+
+		pods, err := s.podRes.GetPodResources()
+		if err != nil {
+			s.logger().Debugf("Pod "+s.podName+" - Unable to check the pod exists: %v", err)
+			return true
+		}
+		for _, pod := range pods {
+			if pod.GetName() != s.podName || (s.podNamespace != "" && pod.GetNamespace() != s.podNamespace) {
+				continue
+			}
+			if s.devicesIn(s.podDevices(pod)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+podDevices returns the set of devices of this Server type allocated to the pod.
+*/
+func (s *server) podDevices(pod api.PodResources) map[string]bool {
+	devices := make(map[string]bool)
+	for _, container := range pod.GetContainers() {
+		for _, devType := range container.GetDevices() {
+			if devType.GetResourceName() != s.deviceType {
+				continue
+			}
+			for _, dev := range devType.GetDeviceIds() {
+				devices[dev] = true
+			}
+		}
+	}
+	return devices
+}
+
+/*
+pool returns the name of the pool of this Server, its device type without the device prefix.
+*/
+func (s *server) pool() string {
+	return s.deviceType[strings.LastIndex(s.deviceType, "/")+1:]
+}
+
+func (s *server) isPodDeleted() bool {
+	return atomic.LoadInt32(&s.podDeleted) == 1
+}
+
+/*
+pinIrqs pins the queue IRQs of the devices, and their bond peers, to the exclusive CPUs of the
+validated pod container. Failing to pin IRQs affects latency rather than function, so failures
+are logged and the connection continues.
+*/
+func (s *server) pinIrqs() {
+	if len(s.podCpus) == 0 {
+		s.logger().Warningf("Pod " + s.podName + " - No exclusive CPUs, queue IRQs not pinned. The pod must be Guaranteed QoS with integer CPU requests and the node must use the static CPU manager policy")
+		return
+	}
+
+	for dev := range s.devices {
+		names := []string{dev}
+		if peer, ok := s.peers[dev]; ok {
+			names = append(names, peer)
+		}
+		for _, name := range names {
+			if err := s.net.SetIrqAffinity(name, s.podCpus); err != nil {
+				s.logger().Warningf("Pod "+s.podName+" - Unable to pin queue IRQs of device %s: %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package udsserver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	logging "github.com/sirupsen/logrus"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+/*
+podList serves the resources of many pods, copied on each call as the pod resources cache does.
+*/
+type podList struct {
+	resourcesapi.Handler
+	pods map[string]api.PodResources
+}
+
+func newPodList(count int, resourceName string) *podList {
+	pods := make(map[string]api.PodResources, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("pod-%d", i)
+		namespace := fmt.Sprintf("namespace-%d", i%10)
+		pods[namespace+"/"+name] = api.PodResources{
+			Name:      name,
+			Namespace: namespace,
+			Containers: []*api.ContainerResources{
+				{
+					Name: "container-01",
+					Devices: []*api.ContainerDevices{
+						{
+							ResourceName: resourceName,
+							DeviceIds:    []string{fmt.Sprintf("dev-%d", i)},
+						},
+					},
+				},
+			},
+		}
+	}
+	return &podList{Handler: resourcesapi.NewFakeHandler(), pods: pods}
+}
+
+func (p *podList) GetPodResources() (map[string]api.PodResources, error) {
+	pods := make(map[string]api.PodResources, len(p.pods))
+	for name, pod := range p.pods {
+		pods[name] = pod
+	}
+	return pods, nil
+}
+
+func (p *podList) InvalidatePodResources() {
+}
+
+func BenchmarkValidatePod(b *testing.B) {
+	defer logging.SetLevel(logging.GetLevel())
+	logging.SetLevel(logging.ErrorLevel)
+
+	for _, count := range []int{10, 1000, 10000} {
+		podRes := newPodList(count, "afxdp/benchPool")
+		last := count - 1
+		hostname := fmt.Sprintf("pod-%d", last)
+
+		for _, identity := range []struct {
+			name     string
+			identity podIdentity
+		}{
+			{"hostname", podIdentity{}},
+			{"namespace", podIdentity{namespace: fmt.Sprintf("namespace-%d", last%10)}},
+		} {
+			b.Run(fmt.Sprintf("pods=%d/%s", count, identity.name), func(b *testing.B) {
+				server := &server{
+					deviceType: "afxdp/benchPool",
+					devices:    map[string]int{fmt.Sprintf("dev-%d", last): 1},
+					podRes:     podRes,
+					net:        networking.NewFakeHandler(),
+				}
+
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if _, valid, err := server.validatePod(hostname, identity.identity); err != nil || !valid {
+						b.Fatalf("pod not validated: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkHandshake(b *testing.B) {
+	defer logging.SetLevel(logging.GetLevel())
+	logging.SetLevel(logging.ErrorLevel)
+
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/benchPool", []string{"devA"})
+	requests := map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestVersion,
+		2: constants.Uds.Handshake.RequestFd + ", devA",
+		3: constants.Uds.Handshake.RequestFin,
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fakeUDS := uds.NewFakeHandler()
+		server := &server{
+			deviceType: "afxdp/benchPool",
+			devices:    map[string]int{"devA": 1},
+			uds:        fakeUDS,
+			podRes:     fakeResAPI,
+			net:        networking.NewFakeHandler(),
+		}
+		fakeUDS.SetRequests(requests)
+		server.start()
+
+		if fakeUDS.GetResponses()[3] != constants.Uds.Handshake.ResponseFinAck {
+			b.Fatalf("handshake not completed: %v", fakeUDS.GetResponses())
+		}
+	}
+}
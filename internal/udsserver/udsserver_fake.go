@@ -15,6 +15,8 @@
 
 package udsserver
 
+import "github.com/intel/afxdp-plugins-for-kubernetes/internal/tracing"
+
 /*
 fakeServer is a fake implementation the Server interface.
 */
@@ -38,7 +40,7 @@ In this fakeServerFactory it returnss an empty fakeServer implementation and a h
 fake UDS filepath.
 */
 func (f *fakeServerFactory) CreateServer(deviceType, user string, timeout int, udsFuzz bool) (Server, string, error) {
-	return &fakeServer{}, "/tmp/fake-socket.sock", nil
+	return &fakeServer{}, "/tmp/fake-socket/afxdp.sock", nil
 }
 
 /*
@@ -54,3 +56,53 @@ In this fakeServer it does nothing.
 */
 func (s *fakeServer) AddDevice(dev string, fd int) {
 }
+
+/*
+AddDevicePeer adds the bond peer of a netdev, and the peers associated XSK file descriptor.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) AddDevicePeer(dev string, peer string, fd int) {
+}
+
+/*
+SetPodIrqAffinity pins the queue IRQs of the devices to the exclusive CPUs of the pod once validated.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) SetPodIrqAffinity() {
+}
+
+/*
+SetAllowedPeers restricts the UIDs and GIDs of the processes that can complete the handshake.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) SetAllowedPeers(uids []int, gids []int) {
+}
+
+/*
+SetDpdkProfile serves the handshake as the DPDK af_xdp PMD expects it.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) SetDpdkProfile() {
+}
+
+/*
+SetFaults injects faults into the responses of the Server.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) SetFaults(faults Faults) {
+}
+
+/*
+Token returns the handshake token of the Server, given to the container at allocation.
+In this fakeServer it returns a hardcoded fake token.
+*/
+func (s *fakeServer) Token() string {
+	return "fake-token"
+}
+
+/*
+SetTrace sets the span of the allocation that created the Server.
+In this fakeServer it does nothing.
+*/
+func (s *fakeServer) SetTrace(parent *tracing.Span) {
+}
@@ -16,12 +16,33 @@
 package udsserver
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/apiserver"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/audit"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/metrics"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/tracing"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	logging "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"gotest.tools/assert"
+	api "k8s.io/kubelet/pkg/apis/podresources/v1"
 )
 
 func TestCreateNewServer(t *testing.T) {
@@ -101,6 +122,7 @@ func TestStart(t *testing.T) {
 		udsServerDevType string
 		fakePodDevices   []string
 		udsServerDevices []string
+		udsServerPeers   map[string]string
 		fakeRequests     map[int]string
 		expectedResponse map[int]string
 	}{
@@ -179,6 +201,60 @@ func TestStart(t *testing.T) {
 				1: constants.Uds.Handshake.ResponseFinAck,
 			},
 		},
+		{
+			//Try connect good podA, the server holds a subset of the pod devices - devA to devC of devA to devD
+			testName:         "Connect with a subset of the pod devices",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA", "devB", "devC", "devD"},
+			udsServerDevices: []string{"devA", "devB", "devC"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			//Try connect good podA, sending its namespace and UID
+			testName:         "Connect with namespace and UID",
+			fakePodName:      "podA",
+			fakePodNamespace: "someOtherNamespace",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA", "devB"},
+			udsServerDevices: []string{"devA", "devB"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA, namespace=someOtherNamespace, uid=1234-abcd",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			//Try connect good podA with a hostname set in the pod spec, sending its pod name
+			testName:         "Connect with hostname differing from pod name",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA"},
+			udsServerDevices: []string{"devA"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", my-host, name=podA, namespace=default",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
 		/*********************************************************
 		Positive Tests - validate, request good FDs and disconnect
 		*********************************************************/
@@ -246,6 +322,29 @@ func TestStart(t *testing.T) {
 				2: constants.Uds.Handshake.ResponseFinAck,
 			},
 		},
+		{
+			//Connect podA, request version with build info and disconnect
+			testName:         "Connect and request version with build info",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA", "devB"},
+			udsServerDevices: []string{"devA", "devB"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestVersion + ", " + constants.Uds.Handshake.VersionBuild,
+				2: constants.Uds.Handshake.RequestVersion + ", other",
+				3: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.Version + ", version=" + constants.Plugins.Version +
+					", commit=" + constants.Plugins.Commit + ", built=" + constants.Plugins.BuildDate,
+				2: constants.Uds.Handshake.ResponseBadRequest,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
 		{
 			//Connect and test full handshake
 			testName:         "Full handshake",
@@ -272,6 +371,55 @@ func TestStart(t *testing.T) {
 				5: constants.Uds.Handshake.ResponseFinAck,
 			},
 		},
+		{
+			//Connect podA, request config and FDs for a bond pair - devA with peer devB
+			testName:         "Connect and request config and FDs, bond pair",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA"},
+			udsServerDevices: []string{"devA"},
+			udsServerPeers:   map[string]string{"devA": "devB"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestConfig + ", devA",
+				2: constants.Uds.Handshake.RequestFd + ", devA",
+				3: constants.Uds.Handshake.RequestFd + ", devB",
+				4: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseConfigAck + ", peer=devB",
+				2: constants.Uds.Handshake.ResponseFdAck,
+				3: constants.Uds.Handshake.ResponseFdAck,
+				4: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			//Connect podA, request config for an unpaired device and an unknown device
+			testName:         "Connect and request config, unpaired and unknown devices",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA"},
+			udsServerDevices: []string{"devA"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestConfig + ", devA",
+				2: constants.Uds.Handshake.RequestConfig + ", devZ",
+				3: constants.Uds.Handshake.RequestConfig,
+				4: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseConfigAck,
+				2: constants.Uds.Handshake.ResponseConfigNak,
+				3: constants.Uds.Handshake.ResponseBadRequest,
+				4: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
 		/*************************************************************************************
 		Negative Tests - do not validate
 		NOTE: we shouldn't need to call /fin in any of these as we should never connect
@@ -350,6 +498,42 @@ func TestStart(t *testing.T) {
 				1: "should not get " + constants.Uds.Handshake.ResponseFinAck + " as should not have connected",
 			},
 		},
+		{
+			//Try connect podA, sending the wrong namespace
+			testName:         "Wrong namespace",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA", "devB"},
+			udsServerDevices: []string{"devA", "devB"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA, namespace=someOtherNamespace",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+				1: "should not get " + constants.Uds.Handshake.ResponseFinAck + " as should not have connected",
+			},
+		},
+		{
+			//Try connect podA, sending an empty namespace
+			testName:         "Empty namespace",
+			fakePodName:      "podA",
+			fakePodNamespace: "default",
+			fakeResourceName: "uds/testing",
+			udsServerDevType: "uds/testing",
+			fakePodDevices:   []string{"devA", "devB"},
+			udsServerDevices: []string{"devA", "devB"},
+			fakeRequests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA, namespace=",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expectedResponse: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+				1: "should not get " + constants.Uds.Handshake.ResponseFinAck + " as should not have connected",
+			},
+		},
 		{
 			//Put the podname before connect request
 			testName:         "Hostname before request",
@@ -1034,24 +1218,6 @@ func TestStart(t *testing.T) {
 				1: "should not get " + constants.Uds.Handshake.ResponseFinAck + " as should not have connected",
 			},
 		},
-		{
-			//Try connect good podA, all devices are good, but we are missing one - devD
-			testName:         "Good hostname, good devices, 1 missing device",
-			fakePodName:      "podA",
-			fakePodNamespace: "default",
-			fakeResourceName: "uds/testing",
-			udsServerDevType: "uds/testing",
-			fakePodDevices:   []string{"devA", "devB", "devC", "devD"},
-			udsServerDevices: []string{"devA", "devB", "devC"},
-			fakeRequests: map[int]string{
-				0: constants.Uds.Handshake.RequestConnect + ", podA",
-				1: constants.Uds.Handshake.RequestFin,
-			},
-			expectedResponse: map[int]string{
-				0: constants.Uds.Handshake.ResponseHostNak,
-				1: "should not get " + constants.Uds.Handshake.ResponseFinAck + " as should not have connected",
-			},
-		},
 		{
 			//Try connect good podA, both devices are good, but they are of the wrong type - uds/badType
 			testName:         "Good hostname, good device, bad device type",
@@ -1872,6 +2038,7 @@ func TestStart(t *testing.T) {
 				devices:    make(map[string]int),
 				uds:        fakeUDS,
 				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
 			}
 
 			fakeResAPI.CreateFakePod(tc.fakePodName, tc.fakePodNamespace, tc.fakeResourceName, tc.fakePodDevices)
@@ -1881,6 +2048,10 @@ func TestStart(t *testing.T) {
 				server.AddDevice(device, fd)
 			}
 
+			for device, peer := range tc.udsServerPeers {
+				server.AddDevicePeer(device, peer, 100)
+			}
+
 			server.start()
 
 			responses := fakeUDS.GetResponses()
@@ -1972,3 +2143,1977 @@ func TestRead(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePodIdentity(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		request     string
+		expIdentity podIdentity
+		expOk       bool
+	}{
+		{
+			testName: "Hostname only",
+			request:  "/connect, podA",
+			expOk:    true,
+		},
+		{
+			testName:    "Namespace",
+			request:     "/connect, podA, namespace=default",
+			expIdentity: podIdentity{namespace: "default"},
+			expOk:       true,
+		},
+		{
+			testName:    "Namespace and UID",
+			request:     "/connect, podA, namespace=default, uid=1234-abcd",
+			expIdentity: podIdentity{namespace: "default", uid: "1234-abcd"},
+			expOk:       true,
+		},
+		{
+			testName:    "UID before namespace",
+			request:     "/connect, podA, uid=1234-abcd, namespace=default",
+			expIdentity: podIdentity{namespace: "default", uid: "1234-abcd"},
+			expOk:       true,
+		},
+		{
+			testName:    "Name, namespace and UID",
+			request:     "/connect, my-host, name=podA, namespace=default, uid=1234-abcd",
+			expIdentity: podIdentity{name: "podA", namespace: "default", uid: "1234-abcd"},
+			expOk:       true,
+		},
+		{
+			testName:    "Token",
+			request:     "/connect, podA, namespace=default, token=0123abcd",
+			expIdentity: podIdentity{namespace: "default", token: "0123abcd"},
+			expOk:       true,
+		},
+		{
+			testName: "Empty token",
+			request:  "/connect, podA, token=",
+			expOk:    false,
+		},
+		{
+			testName: "Empty name",
+			request:  "/connect, my-host, name=",
+			expOk:    false,
+		},
+		{
+			testName: "No hostname",
+			request:  "/connect",
+			expOk:    false,
+		},
+		{
+			testName: "Namespace in place of hostname",
+			request:  "/connect, namespace=default",
+			expOk:    false,
+		},
+		{
+			testName: "Repeated namespace",
+			request:  "/connect, podA, namespace=default, namespace=other",
+			expOk:    false,
+		},
+		{
+			testName: "Empty UID",
+			request:  "/connect, podA, uid=",
+			expOk:    false,
+		},
+		{
+			testName: "Unknown field",
+			request:  "/connect, podA, node=node1",
+			expOk:    false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			identity, ok := parsePodIdentity(strings.Split(tc.request, ","))
+			assert.Equal(t, ok, tc.expOk)
+			if tc.expOk {
+				assert.Equal(t, identity, tc.expIdentity)
+			}
+		})
+	}
+}
+
+func TestCheckPodUid(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		allocations []*networking.Allocation
+		uid         string
+		expValid    bool
+	}{
+		{
+			testName: "UID matches",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default", PodUid: "1234-abcd"},
+				{Device: "devB", Pod: "podA", Namespace: "default", PodUid: "1234-abcd"},
+			},
+			uid:      "1234-abcd",
+			expValid: true,
+		},
+		{
+			testName: "UID does not match",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default", PodUid: "5678-efgh"},
+			},
+			uid:      "1234-abcd",
+			expValid: false,
+		},
+		{
+			testName: "UID of one device does not match",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default", PodUid: "1234-abcd"},
+				{Device: "devB", Pod: "podB", Namespace: "default", PodUid: "5678-efgh"},
+			},
+			uid:      "1234-abcd",
+			expValid: false,
+		},
+		{
+			testName: "UID not recorded",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default"},
+			},
+			uid:      "1234-abcd",
+			expValid: true,
+		},
+		{
+			testName:    "No allocations",
+			allocations: nil,
+			uid:         "1234-abcd",
+			expValid:    true,
+		},
+		{
+			testName: "Allocation of another device",
+			allocations: []*networking.Allocation{
+				{Device: "devC", Pod: "podC", Namespace: "default", PodUid: "5678-efgh"},
+			},
+			uid:      "1234-abcd",
+			expValid: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeNet := networking.NewFakeHandler()
+			for _, allocation := range tc.allocations {
+				err := fakeNet.RecordAllocation(allocation)
+				assert.NilError(t, err)
+			}
+			defer func() {
+				for _, allocation := range tc.allocations {
+					fakeNet.RemoveAllocation(allocation.Device, allocation.Owner)
+				}
+			}()
+
+			server := &server{
+				devices: map[string]int{"devA": 1, "devB": 2},
+				net:     fakeNet,
+			}
+
+			valid, err := server.checkPodUid("podA", tc.uid)
+			assert.NilError(t, err)
+			assert.Equal(t, valid, tc.expValid)
+		})
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	testCases := []struct {
+		testName   string
+		token      string
+		require    bool
+		expValid   bool
+		expRefusal bool
+	}{
+		{
+			testName: "Token matches",
+			token:    "0123abcd",
+			expValid: true,
+		},
+		{
+			testName:   "Token does not match",
+			token:      "4567ef01",
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName:   "Token prefix",
+			token:      "0123",
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName: "Token missing",
+			expValid: true,
+		},
+		{
+			testName:   "Token missing, token required",
+			require:    true,
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName: "Token matches, token required",
+			token:    "0123abcd",
+			require:  true,
+			expValid: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetRequireToken(tc.require)
+			defer SetRequireToken(false)
+
+			server := &server{token: "0123abcd"}
+
+			assert.Equal(t, server.checkToken("podA", tc.token), tc.expValid)
+			assert.Equal(t, server.refusal != "", tc.expRefusal)
+		})
+	}
+}
+
+func TestCheckPeerIds(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		allowedUids []int
+		allowedGids []int
+		peer        *audit.Peer
+		expValid    bool
+	}{
+		{
+			testName: "No restriction",
+			peer:     &audit.Peer{Pid: 4321, Uid: 1500, Gid: 1500},
+			expValid: true,
+		},
+		{
+			testName: "No restriction, credentials unknown",
+			expValid: true,
+		},
+		{
+			testName:    "UID allowed",
+			allowedUids: []int{1000, 1500},
+			peer:        &audit.Peer{Pid: 4321, Uid: 1500, Gid: 1500},
+			expValid:    true,
+		},
+		{
+			testName:    "UID not allowed",
+			allowedUids: []int{1000},
+			peer:        &audit.Peer{Pid: 4321, Uid: 1500, Gid: 1500},
+			expValid:    false,
+		},
+		{
+			testName:    "UID and GID allowed",
+			allowedUids: []int{1500},
+			allowedGids: []int{2000},
+			peer:        &audit.Peer{Pid: 4321, Uid: 1500, Gid: 2000},
+			expValid:    true,
+		},
+		{
+			testName:    "UID allowed, GID not allowed",
+			allowedUids: []int{1500},
+			allowedGids: []int{2000},
+			peer:        &audit.Peer{Pid: 4321, Uid: 1500, Gid: 1500},
+			expValid:    false,
+		},
+		{
+			testName:    "Root not allowed",
+			allowedUids: []int{1500},
+			peer:        &audit.Peer{},
+			expValid:    false,
+		},
+		{
+			testName:    "Credentials unknown",
+			allowedGids: []int{1500},
+			expValid:    false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			server := &server{peer: tc.peer}
+			server.SetAllowedPeers(tc.allowedUids, tc.allowedGids)
+
+			assert.Equal(t, tc.expValid, server.checkPeerIds("podA"))
+			assert.Equal(t, !tc.expValid, server.refusal != "")
+		})
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	newNonce = func() (string, error) { return "0a1b2c3d", nil }
+	defer func() { newNonce = func() (string, error) { return randomHex(constants.Uds.NonceBytes) } }()
+
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/challengePool", []string{"devA"})
+	challengeAck := constants.Uds.Handshake.ResponseChallenge + ", 0a1b2c3d"
+	proof := uds.ChallengeProof("0123abcd", "0a1b2c3d")
+
+	testCases := []struct {
+		testName     string
+		require      bool
+		requests     map[int]string
+		expResponses map[int]string
+	}{
+		{
+			testName: "Nonce echoed",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestChallenge,
+				1: constants.Uds.Handshake.RequestConnect + ", podA, nonce=0a1b2c3d",
+				2: constants.Uds.Handshake.RequestFin,
+			},
+			expResponses: map[int]string{
+				0: challengeAck,
+				1: constants.Uds.Handshake.ResponseHostOk,
+				2: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Proof in place of the token",
+			require:  true,
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestChallenge,
+				1: constants.Uds.Handshake.RequestConnect + ", podA, proof=" + proof,
+				2: constants.Uds.Handshake.RequestFin,
+			},
+			expResponses: map[int]string{
+				0: challengeAck,
+				1: constants.Uds.Handshake.ResponseHostOk,
+				2: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Wrong nonce",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestChallenge,
+				1: constants.Uds.Handshake.RequestConnect + ", podA, nonce=ffffffff",
+			},
+			expResponses: map[int]string{
+				0: challengeAck,
+				1: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+		{
+			testName: "Proof of another token",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestChallenge,
+				1: constants.Uds.Handshake.RequestConnect + ", podA, proof=" + uds.ChallengeProof("4567ef01", "0a1b2c3d"),
+			},
+			expResponses: map[int]string{
+				0: challengeAck,
+				1: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+		{
+			testName: "Replayed without a challenge",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA, proof=" + proof,
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+		{
+			testName: "No challenge",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestFin,
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "No challenge, challenge required",
+			require:  true,
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetRequireChallenge(tc.require)
+			defer SetRequireChallenge(false)
+
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/challengePool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+				token:      "0123abcd",
+			}
+			fakeUDS.SetRequests(tc.requests)
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+		})
+	}
+}
+
+func TestDpdkProfile(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/dpdkPool", []string{"devA"})
+
+	// requests as sent by the DPDK af_xdp PMD, without spaces after the commas
+	dpdkRequests := map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ",podA",
+		1: constants.Uds.Handshake.RequestVersion,
+		2: constants.Uds.Handshake.RequestFd + ",devA",
+		3: constants.Uds.Handshake.RequestFin,
+	}
+
+	testCases := []struct {
+		testName     string
+		dpdk         bool
+		require      bool
+		requests     map[int]string
+		expResponses map[int]string
+	}{
+		{
+			testName: "DPDK handshake",
+			dpdk:     true,
+			requests: dpdkRequests,
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.Version,
+				2: constants.Uds.Handshake.ResponseFdAck,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "DPDK handshake, token, challenge and signatures required",
+			dpdk:     true,
+			require:  true,
+			requests: dpdkRequests,
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.Version,
+				2: constants.Uds.Handshake.ResponseFdAck,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "DPDK handshake, wrong token",
+			dpdk:     true,
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ",podA,token=4567ef01",
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostNak,
+			},
+		},
+		{
+			testName: "CNDP profile, token, challenge and signatures required",
+			require:  true,
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ",podA",
+			},
+			expResponses: map[int]string{}, // the unsigned request ends the connection
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetRequireToken(tc.require)
+			defer SetRequireToken(false)
+			SetRequireChallenge(tc.require)
+			defer SetRequireChallenge(false)
+			SetRequireSigned(tc.require)
+			defer SetRequireSigned(false)
+
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/dpdkPool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+				token:      "0123abcd",
+			}
+			if tc.dpdk {
+				server.SetDpdkProfile()
+			}
+			fakeUDS.SetRequests(tc.requests)
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+		})
+	}
+}
+
+func TestSignedMessages(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/signedPool", []string{"devA"})
+	connect := constants.Uds.Handshake.RequestConnect + ", podA"
+	fin := constants.Uds.Handshake.RequestFin
+	version := constants.Uds.Handshake.RequestVersion
+
+	testCases := []struct {
+		testName     string
+		require      bool
+		requests     map[int]string
+		expResponses map[int]string
+	}{
+		{
+			testName: "Signed",
+			require:  true,
+			requests: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignRequest, 0, connect),
+				1: uds.Sign("0123abcd", uds.SignRequest, 1, version),
+				2: uds.Sign("0123abcd", uds.SignRequest, 2, fin),
+			},
+			expResponses: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignResponse, 0, constants.Uds.Handshake.ResponseHostOk),
+				1: uds.Sign("0123abcd", uds.SignResponse, 1, constants.Uds.Handshake.Version),
+				2: uds.Sign("0123abcd", uds.SignResponse, 2, constants.Uds.Handshake.ResponseFinAck),
+			},
+		},
+		{
+			testName: "Unsigned",
+			requests: map[int]string{
+				0: connect,
+				1: fin,
+			},
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Unsigned, signing required",
+			require:  true,
+			requests: map[int]string{
+				0: connect,
+			},
+			expResponses: map[int]string{},
+		},
+		{
+			testName: "Signed with another token",
+			requests: map[int]string{
+				0: uds.Sign("4567ef01", uds.SignRequest, 0, connect),
+			},
+			expResponses: map[int]string{},
+		},
+		{
+			testName: "Unsigned request injected",
+			requests: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignRequest, 0, connect),
+				1: constants.Uds.Handshake.RequestFd + ", devA",
+			},
+			expResponses: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignResponse, 0, constants.Uds.Handshake.ResponseHostOk),
+			},
+		},
+		{
+			testName: "Signed request replayed",
+			requests: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignRequest, 0, connect),
+				1: uds.Sign("0123abcd", uds.SignRequest, 1, version),
+				2: uds.Sign("0123abcd", uds.SignRequest, 1, version),
+			},
+			expResponses: map[int]string{
+				0: uds.Sign("0123abcd", uds.SignResponse, 0, constants.Uds.Handshake.ResponseHostOk),
+				1: uds.Sign("0123abcd", uds.SignResponse, 1, constants.Uds.Handshake.Version),
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetRequireSigned(tc.require)
+			defer SetRequireSigned(false)
+
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/signedPool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+				token:      "0123abcd",
+			}
+			fakeUDS.SetRequests(tc.requests)
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+		})
+	}
+}
+
+func TestRevalidateFdRequest(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		revalidate  bool
+		podName     string
+		podResErr   error
+		device      string
+		expResponse string
+	}{
+		{
+			testName:    "Device held",
+			revalidate:  true,
+			podName:     "podA",
+			device:      "devA",
+			expResponse: constants.Uds.Handshake.ResponseFdAck,
+		},
+		{
+			testName:    "Bond peer of a device held",
+			revalidate:  true,
+			podName:     "podA",
+			device:      "peerA",
+			expResponse: constants.Uds.Handshake.ResponseFdAck,
+		},
+		{
+			testName:    "Device reallocated",
+			revalidate:  true,
+			podName:     "podA",
+			device:      "devB",
+			expResponse: constants.Uds.Handshake.ResponseFdNak,
+		},
+		{
+			testName:    "Device reallocated, not revalidated",
+			podName:     "podA",
+			device:      "devB",
+			expResponse: constants.Uds.Handshake.ResponseFdAck,
+		},
+		{
+			testName:    "Pod gone",
+			revalidate:  true,
+			podName:     "podB",
+			device:      "devA",
+			expResponse: constants.Uds.Handshake.ResponseFdNak,
+		},
+		{
+			testName:    "Pod resources unavailable",
+			revalidate:  true,
+			podName:     "podA",
+			podResErr:   errors.New("kubelet unavailable"),
+			device:      "devA",
+			expResponse: constants.Uds.Handshake.ResponseFdNak,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetRevalidateFdRequests(tc.revalidate)
+			defer SetRevalidateFdRequests(false)
+
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod("podA", "default", "afxdp/revalidatePool", []string{"devA"})
+			fakeResAPI.SetPodResourcesError(tc.podResErr)
+			fakeUDS := uds.NewFakeHandler()
+			assert.NilError(t, fakeUDS.Init("", "", 0, 0, 0, ""))
+			server := &server{
+				deviceType:   "afxdp/revalidatePool",
+				devices:      map[string]int{"devA": 1, "devB": 2},
+				peers:        map[string]string{"devA": "peerA"},
+				peerFds:      map[string]int{"peerA": 3},
+				uds:          fakeUDS,
+				podRes:       fakeResAPI,
+				podName:      tc.podName,
+				podNamespace: "default",
+			}
+
+			assert.NilError(t, server.handleFdRequest(parseRequest(constants.Uds.Handshake.RequestFd+", "+tc.device)))
+			assert.DeepEqual(t, fakeUDS.GetResponses(), map[int]string{0: tc.expResponse})
+		})
+	}
+}
+
+func TestSingleUseFds(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/singleUsePool", []string{"devA"})
+	requests := map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestFd + ", devA",
+		2: constants.Uds.Handshake.RequestFd + ", devA",
+		3: constants.Uds.Handshake.RequestFin,
+	}
+
+	testCases := []struct {
+		testName     string
+		singleUse    bool
+		expResponses map[int]string
+	}{
+		{
+			testName:  "Single use",
+			singleUse: true,
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFdAck,
+				2: constants.Uds.Handshake.ResponseFdServed,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+		{
+			testName: "Reusable",
+			expResponses: map[int]string{
+				0: constants.Uds.Handshake.ResponseHostOk,
+				1: constants.Uds.Handshake.ResponseFdAck,
+				2: constants.Uds.Handshake.ResponseFdAck,
+				3: constants.Uds.Handshake.ResponseFinAck,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			SetSingleUseFds(tc.singleUse)
+			defer SetSingleUseFds(false)
+
+			fakeUDS := uds.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/singleUsePool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+			}
+			fakeUDS.SetRequests(requests)
+			server.start()
+
+			assert.DeepEqual(t, fakeUDS.GetResponses(), tc.expResponses)
+		})
+	}
+}
+
+func TestCheckPeerCgroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "proc")
+	assert.NilError(t, err)
+	defer os.RemoveAll(dir)
+	for pid, cgroup := range map[string]string{
+		"100": "0::/kubepods/besteffort/pod1234abcd-0000-0000-0000-000000000001/ctr\n",
+		"200": "0::/kubepods/besteffort/pod5678ef01-0000-0000-0000-000000000002/ctr\n",
+		"300": "0::/system.slice/sshd.service\n",
+	} {
+		assert.NilError(t, os.MkdirAll(filepath.Join(dir, pid), 0700))
+		assert.NilError(t, ioutil.WriteFile(filepath.Join(dir, pid, "cgroup"), []byte(cgroup), 0600))
+	}
+	procRoot = dir
+	defer func() { procRoot = "/proc" }()
+
+	testCases := []struct {
+		testName   string
+		podUid     string
+		pid        int32
+		require    bool
+		expValid   bool
+		expRefusal bool
+	}{
+		{
+			testName: "Process in the pod",
+			podUid:   "1234abcd-0000-0000-0000-000000000001",
+			pid:      100,
+			expValid: true,
+		},
+		{
+			testName:   "Process in another pod",
+			podUid:     "1234abcd-0000-0000-0000-000000000001",
+			pid:        200,
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName:   "Process in no pod",
+			podUid:     "1234abcd-0000-0000-0000-000000000001",
+			pid:        300,
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName: "Process not visible",
+			podUid:   "1234abcd-0000-0000-0000-000000000001",
+			pid:      0,
+			expValid: true,
+		},
+		{
+			testName:   "Process not visible, verification required",
+			podUid:     "1234abcd-0000-0000-0000-000000000001",
+			pid:        0,
+			require:    true,
+			expValid:   false,
+			expRefusal: true,
+		},
+		{
+			testName: "UID not recorded",
+			pid:      200,
+			expValid: true,
+		},
+		{
+			testName:   "UID not recorded, verification required",
+			pid:        100,
+			require:    true,
+			expValid:   false,
+			expRefusal: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeNet := networking.NewFakeHandler()
+			allocation := &networking.Allocation{Device: "devA", Pod: "podA", Namespace: "default", PodUid: tc.podUid}
+			assert.NilError(t, fakeNet.RecordAllocation(allocation))
+			defer fakeNet.RemoveAllocation(allocation.Device, allocation.Owner)
+
+			SetRequirePeerCgroup(tc.require)
+			defer SetRequirePeerCgroup(false)
+
+			server := &server{
+				devices: map[string]int{"devA": 1},
+				net:     fakeNet,
+				peerPid: tc.pid,
+			}
+
+			assert.Equal(t, server.checkPeerCgroup("podA"), tc.expValid)
+			assert.Equal(t, server.refusal != "", tc.expRefusal)
+		})
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	testCases := []struct {
+		testName       string
+		allocations    []*networking.Allocation
+		uid            string
+		expUid         string
+		expContainerId string
+	}{
+		{
+			testName: "UID recorded by the CNI",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Owner: "ctr-1", Pod: "podA", Namespace: "default", PodUid: "1234-abcd"},
+			},
+			expUid:         "1234-abcd",
+			expContainerId: "ctr-1",
+		},
+		{
+			testName: "UID sent by the pod",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Owner: "ctr-1", Pod: "podA", Namespace: "default"},
+			},
+			uid:            "1234-abcd",
+			expUid:         "1234-abcd",
+			expContainerId: "ctr-1",
+		},
+		{
+			testName: "Allocated to a pod in another namespace",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Owner: "ctr-2", Pod: "podA", Namespace: "other", PodUid: "5678-efgh"},
+			},
+		},
+		{
+			testName: "Allocated to another pod",
+			allocations: []*networking.Allocation{
+				{Device: "devA", Owner: "ctr-2", Pod: "podB", Namespace: "default", PodUid: "5678-efgh"},
+			},
+			uid:    "1234-abcd",
+			expUid: "1234-abcd",
+		},
+		{
+			testName:    "No allocations",
+			allocations: nil,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeNet := networking.NewFakeHandler()
+			for _, allocation := range tc.allocations {
+				err := fakeNet.RecordAllocation(allocation)
+				assert.NilError(t, err)
+			}
+			defer func() {
+				for _, allocation := range tc.allocations {
+					fakeNet.RemoveAllocation(allocation.Device, allocation.Owner)
+				}
+			}()
+
+			server := &server{
+				podName:      "podA",
+				podNamespace: "default",
+				devices:      map[string]int{"devA": 1},
+				net:          fakeNet,
+			}
+
+			server.identify(podIdentity{uid: tc.uid})
+			assert.Equal(t, server.podUid, tc.expUid)
+			assert.Equal(t, server.containerId, tc.expContainerId)
+
+			fields := server.logger().Data
+			assert.Equal(t, fields["pod"], "default/podA")
+			if tc.expContainerId != "" {
+				assert.Equal(t, fields["containerId"], tc.expContainerId)
+			}
+		})
+	}
+}
+
+func TestValidatePodApiServerFallback(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		fallback    bool
+		apiPods     []*apiserver.Pod
+		apiErr      error
+		allocations []*networking.Allocation
+		identity    podIdentity
+		expValid    bool
+		expErr      bool
+	}{
+		{
+			testName: "Fallback disabled",
+			fallback: false,
+			expValid: false,
+			expErr:   true,
+		},
+		{
+			testName: "Pod requests matching devices",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Uid: "1234-abcd", Containers: []*apiserver.Container{
+					{Name: "sidecar", Resources: map[string]int{}},
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 2}},
+				}},
+			},
+			expValid: true,
+		},
+		{
+			testName: "Pod requests fewer devices",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Containers: []*apiserver.Container{
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 1}},
+				}},
+			},
+			expValid: false,
+		},
+		{
+			testName: "Pod requests devices across containers",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Containers: []*apiserver.Container{
+					{Name: "afxdp-1", Resources: map[string]int{"uds/testing": 1}},
+					{Name: "afxdp-2", Resources: map[string]int{"uds/testing": 1}},
+				}},
+			},
+			expValid: true,
+		},
+		{
+			testName: "Pod in another namespace",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Containers: []*apiserver.Container{
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 2}},
+				}},
+			},
+			identity: podIdentity{namespace: "other"},
+			expValid: false,
+		},
+		{
+			testName: "Pod UID does not match",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Uid: "5678-efgh", Containers: []*apiserver.Container{
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 2}},
+				}},
+			},
+			identity: podIdentity{namespace: "default", uid: "1234-abcd"},
+			expValid: false,
+		},
+		{
+			testName: "Devices attached to another pod",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Containers: []*apiserver.Container{
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 2}},
+				}},
+			},
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podB", Namespace: "default"},
+			},
+			expValid: false,
+		},
+		{
+			testName: "Devices attached to the pod",
+			fallback: true,
+			apiPods: []*apiserver.Pod{
+				{Name: "podA", Namespace: "default", Containers: []*apiserver.Container{
+					{Name: "afxdp", Resources: map[string]int{"uds/testing": 2}},
+				}},
+			},
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default"},
+				{Device: "devB", Pod: "podA", Namespace: "default"},
+			},
+			expValid: true,
+		},
+		{
+			testName: "API server unavailable",
+			fallback: true,
+			apiErr:   errors.New("connection refused"),
+			expValid: false,
+			expErr:   true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.SetPodResourcesError(errors.New("pod resources unavailable"))
+
+			fakeNet := networking.NewFakeHandler()
+			for _, allocation := range tc.allocations {
+				err := fakeNet.RecordAllocation(allocation)
+				assert.NilError(t, err)
+			}
+			defer func() {
+				for _, allocation := range tc.allocations {
+					fakeNet.RemoveAllocation(allocation.Device, allocation.Owner)
+				}
+			}()
+
+			server := &server{
+				deviceType: "uds/testing",
+				devices:    map[string]int{"devA": 1, "devB": 2},
+				podRes:     fakeResAPI,
+				net:        fakeNet,
+			}
+			if tc.fallback {
+				fakeApi := apiserver.NewFakeHandler()
+				for _, pod := range tc.apiPods {
+					fakeApi.AddFakePod(pod)
+				}
+				fakeApi.SetError(tc.apiErr)
+				server.apiServer = fakeApi
+			}
+
+			_, valid, err := server.validatePod("podA", tc.identity)
+			assert.Equal(t, err != nil, tc.expErr)
+			assert.Equal(t, valid, tc.expValid)
+		})
+	}
+}
+
+func TestRevalidatePod(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		podNamespace string
+		podDevices   []string
+		getErr       error
+		namespace    string
+		expValid     bool
+	}{
+		{
+			testName:     "Get finds pod",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			namespace:    "default",
+			expValid:     true,
+		},
+		{
+			testName:     "Get finds pod with other devices",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devC"},
+			namespace:    "default",
+			expValid:     false,
+		},
+		{
+			testName:     "Get unimplemented, List finds pod",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			getErr:       status.Error(codes.Unimplemented, "unknown method Get"),
+			namespace:    "default",
+			expValid:     true,
+		},
+		{
+			testName:     "Get fails, List finds pod",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			getErr:       errors.New("connection refused"),
+			namespace:    "default",
+			expValid:     true,
+		},
+		{
+			testName:     "Namespace unknown, List finds pod",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			getErr:       errors.New("Get should not be called without a namespace"),
+			expValid:     true,
+		},
+		{
+			testName:     "Pod in another namespace",
+			podNamespace: "other",
+			podDevices:   []string{"devA", "devB"},
+			namespace:    "default",
+			expValid:     false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod("podA", tc.podNamespace, "uds/testing", tc.podDevices)
+			fakeResAPI.SetGetPodResourceError(tc.getErr)
+
+			server := &server{
+				deviceType: "uds/testing",
+				devices:    map[string]int{"devA": 1, "devB": 2},
+				podRes:     fakeResAPI,
+			}
+
+			_, valid, _, err := server.revalidatePod([]podCandidate{{field: "hostname", name: "podA"}}, tc.namespace)
+			assert.NilError(t, err)
+			assert.Equal(t, valid, tc.expValid)
+		})
+	}
+}
+
+func TestCheckPodDevices(t *testing.T) {
+	container := func(name string, cpus []int64, devices map[string][]string) *api.ContainerResources {
+		c := &api.ContainerResources{Name: name, CpuIds: cpus}
+		for resource, ids := range devices {
+			c.Devices = append(c.Devices, &api.ContainerDevices{ResourceName: resource, DeviceIds: ids})
+		}
+		return c
+	}
+
+	testCases := []struct {
+		testName      string
+		containers    []*api.ContainerResources
+		serverDevices []string
+		expValid      bool
+		expCpus       []int
+	}{
+		{
+			testName: "Exact match",
+			containers: []*api.ContainerResources{
+				container("afxdp", []int64{2, 3}, map[string][]string{"uds/testing": {"devA", "devB"}}),
+			},
+			serverDevices: []string{"devA", "devB"},
+			expValid:      true,
+			expCpus:       []int{2, 3},
+		},
+		{
+			testName: "Subset of one container",
+			containers: []*api.ContainerResources{
+				container("afxdp", []int64{2}, map[string][]string{"uds/testing": {"devA", "devB", "devC"}}),
+			},
+			serverDevices: []string{"devA", "devC"},
+			expValid:      true,
+			expCpus:       []int{2},
+		},
+		{
+			testName: "Devices of the second container",
+			containers: []*api.ContainerResources{
+				container("sidecar", []int64{1}, map[string][]string{"uds/testing": {"devA"}}),
+				container("afxdp", []int64{4, 5}, map[string][]string{"uds/testing": {"devB", "devC"}}),
+			},
+			serverDevices: []string{"devB", "devC"},
+			expValid:      true,
+			expCpus:       []int{4, 5},
+		},
+		{
+			testName: "Devices split across containers",
+			containers: []*api.ContainerResources{
+				container("afxdp-1", []int64{1}, map[string][]string{"uds/testing": {"devA"}}),
+				container("afxdp-2", []int64{2}, map[string][]string{"uds/testing": {"devB"}}),
+			},
+			serverDevices: []string{"devA", "devB"},
+			expValid:      true,
+			expCpus:       []int{1},
+		},
+		{
+			testName: "Device of another resource",
+			containers: []*api.ContainerResources{
+				container("afxdp", nil, map[string][]string{"uds/testing": {"devA"}, "uds/other": {"devB"}}),
+			},
+			serverDevices: []string{"devA", "devB"},
+			expValid:      false,
+		},
+		{
+			testName: "Device not allocated to the pod",
+			containers: []*api.ContainerResources{
+				container("afxdp", nil, map[string][]string{"uds/testing": {"devA", "devB"}}),
+			},
+			serverDevices: []string{"devA", "devX"},
+			expValid:      false,
+		},
+		{
+			testName: "Server without devices",
+			containers: []*api.ContainerResources{
+				container("afxdp", nil, map[string][]string{"uds/testing": {"devA"}}),
+			},
+			serverDevices: nil,
+			expValid:      false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			server := &server{
+				deviceType: "uds/testing",
+				devices:    make(map[string]int),
+			}
+			for fd, device := range tc.serverDevices {
+				server.AddDevice(device, fd)
+			}
+
+			pod := api.PodResources{Name: "podA", Namespace: "default", Containers: tc.containers}
+
+			assert.Equal(t, server.checkPodDevices("podA", pod), tc.expValid)
+			if tc.expValid {
+				assert.DeepEqual(t, server.podCpus, tc.expCpus)
+			}
+		})
+	}
+}
+
+func TestValidatePodCandidates(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		hostname    string
+		identity    podIdentity
+		allocations []*networking.Allocation
+		expPodName  string
+		expValid    bool
+	}{
+		{
+			testName:   "Hostname is the pod name",
+			hostname:   "podA",
+			expPodName: "podA",
+			expValid:   true,
+		},
+		{
+			testName:   "Hostname set in pod spec, name sent",
+			hostname:   "my-host",
+			identity:   podIdentity{name: "podA", namespace: "default"},
+			expPodName: "podA",
+			expValid:   true,
+		},
+		{
+			testName:   "Hostname qualified by subdomain",
+			hostname:   "podA.my-subdomain.default.svc.cluster.local",
+			expPodName: "podA",
+			expValid:   true,
+		},
+		{
+			testName: "Hostname set in pod spec, UID recorded by CNI",
+			hostname: "my-host",
+			identity: podIdentity{namespace: "default", uid: "1234-abcd"},
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default", PodUid: "1234-abcd"},
+			},
+			expPodName: "podA",
+			expValid:   true,
+		},
+		{
+			testName: "Hostname set in pod spec, UID of another pod recorded by CNI",
+			hostname: "my-host",
+			identity: podIdentity{uid: "1234-abcd"},
+			allocations: []*networking.Allocation{
+				{Device: "devA", Pod: "podA", Namespace: "default", PodUid: "5678-efgh"},
+			},
+			expPodName: "my-host",
+			expValid:   false,
+		},
+		{
+			testName:   "Hostname set in pod spec, wrong name sent",
+			hostname:   "my-host",
+			identity:   podIdentity{name: "podB"},
+			expPodName: "my-host",
+			expValid:   false,
+		},
+		{
+			testName:   "Name sent, wrong namespace",
+			hostname:   "my-host",
+			identity:   podIdentity{name: "podA", namespace: "other"},
+			expPodName: "my-host",
+			expValid:   false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod("podA", "default", "uds/testing", []string{"devA"})
+			fakeResAPI.SetGetPodResourceError(status.Error(codes.Unimplemented, "unknown method Get"))
+
+			fakeNet := networking.NewFakeHandler()
+			for _, allocation := range tc.allocations {
+				err := fakeNet.RecordAllocation(allocation)
+				assert.NilError(t, err)
+			}
+			defer func() {
+				for _, allocation := range tc.allocations {
+					fakeNet.RemoveAllocation(allocation.Device, allocation.Owner)
+				}
+			}()
+
+			server := &server{
+				deviceType: "uds/testing",
+				devices:    map[string]int{"devA": 1},
+				podRes:     fakeResAPI,
+				net:        fakeNet,
+			}
+
+			podName, valid, err := server.validatePod(tc.hostname, tc.identity)
+			assert.NilError(t, err)
+			assert.Equal(t, valid, tc.expValid)
+			assert.Equal(t, podName, tc.expPodName)
+		})
+	}
+}
+
+func TestCheckPodResourcesMismatch(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		podName     string
+		namespace   string
+		podDevices  []string
+		expValid    bool
+		expMismatch string
+	}{
+		{
+			testName:   "Pod valid",
+			podName:    "podA",
+			namespace:  "default",
+			podDevices: []string{"devA", "devB"},
+			expValid:   true,
+		},
+		{
+			testName:    "No pod of the name",
+			podName:     "podB",
+			podDevices:  []string{"devA", "devB"},
+			expMismatch: "no pod of this name on node",
+		},
+		{
+			testName:    "Pod in another namespace",
+			podName:     "podA",
+			namespace:   "other",
+			podDevices:  []string{"devA", "devB"},
+			expMismatch: "no pod of this name in namespace other, found in namespace default",
+		},
+		{
+			testName:    "Pod without the devices",
+			podName:     "podA",
+			podDevices:  []string{"devA", "devC"},
+			expMismatch: "pod default/podA was not allocated devices devA, devB of uds/testing",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod("podA", "default", "uds/testing", tc.podDevices)
+
+			server := &server{
+				deviceType: "uds/testing",
+				devices:    map[string]int{"devA": 1, "devB": 2},
+				podRes:     fakeResAPI,
+			}
+
+			pods, err := fakeResAPI.GetPodResources()
+			assert.NilError(t, err)
+			valid, mismatch := server.checkPodResources(pods, tc.podName, tc.namespace)
+			assert.Equal(t, valid, tc.expValid)
+			assert.Equal(t, mismatch, tc.expMismatch)
+		})
+	}
+}
+
+func TestWatchPod(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		podName      string
+		podNamespace string
+		podDevices   []string
+		podResErr    error
+		expDropped   bool
+	}{
+		{
+			testName:     "Pod exists",
+			podName:      "podA",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			expDropped:   false,
+		},
+		{
+			testName:     "Pod deleted",
+			podName:      "podB",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			expDropped:   true,
+		},
+		{
+			testName:     "Pod of the same name in another namespace",
+			podName:      "podA",
+			podNamespace: "other",
+			podDevices:   []string{"devA", "devB"},
+			expDropped:   true,
+		},
+		{
+			testName:     "Pod recreated with other devices",
+			podName:      "podA",
+			podNamespace: "default",
+			podDevices:   []string{"devC"},
+			expDropped:   true,
+		},
+		{
+			testName:     "Pod resources unavailable",
+			podName:      "podB",
+			podNamespace: "default",
+			podDevices:   []string{"devA", "devB"},
+			podResErr:    errors.New("pod resources unavailable"),
+			expDropped:   false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod(tc.podName, tc.podNamespace, "uds/testing", tc.podDevices)
+			fakeResAPI.SetPodResourcesError(tc.podResErr)
+
+			server := &server{
+				podName:      "podA",
+				podNamespace: "default",
+				deviceType:   "uds/testing",
+				devices:      map[string]int{"devA": 1, "devB": 2},
+				podRes:       fakeResAPI,
+			}
+
+			stop := make(chan struct{})
+			dropped := make(chan struct{})
+			go server.watchPod(stop, time.Millisecond, func() { close(dropped) })
+
+			select {
+			case <-dropped:
+				assert.Equal(t, tc.expDropped, true)
+			case <-time.After(50 * time.Millisecond):
+				assert.Equal(t, tc.expDropped, false)
+			}
+			close(stop)
+
+			assert.Equal(t, server.isPodDeleted(), tc.expDropped)
+		})
+	}
+}
+
+func TestHandshakeMetrics(t *testing.T) {
+	fakeUDS := uds.NewFakeHandler()
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "uds/metricsPool", []string{"devA"})
+
+	for _, hostname := range []string{"podA", "podB", "podA"} {
+		server := &server{
+			deviceType: "uds/metricsPool",
+			devices:    map[string]int{"devA": 1},
+			uds:        fakeUDS,
+			podRes:     fakeResAPI,
+			net:        networking.NewFakeHandler(),
+		}
+		fakeUDS.SetRequests(map[int]string{
+			0: constants.Uds.Handshake.RequestConnect + ", " + hostname,
+			1: constants.Uds.Handshake.RequestFin,
+		})
+		server.start()
+	}
+
+	var out bytes.Buffer
+	assert.NilError(t, metrics.WriteAll(&out))
+	assert.Assert(t, strings.Contains(out.String(), `afxdp_uds_handshakes_total{pool="metricsPool",outcome="connected"} 2`), out.String())
+	assert.Assert(t, strings.Contains(out.String(), `afxdp_uds_handshakes_total{pool="metricsPool",outcome="refused"} 1`), out.String())
+	assert.Assert(t, !strings.Contains(out.String(), `afxdp_uds_connections{pool="metricsPool"`), "Connections should be removed on disconnect")
+}
+
+func TestConnectionLifetime(t *testing.T) {
+	testCases := []struct {
+		name       string
+		pool       string
+		requests   map[int]string
+		expOutcome string
+	}{
+		{
+			name: "fd passed",
+			pool: "okPool",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestFd + ", devB",
+				2: constants.Uds.Handshake.RequestFd + ", devA",
+				3: constants.Uds.Handshake.RequestFin,
+			},
+			expOutcome: "ok",
+		},
+		{
+			name: "fd refused",
+			pool: "fdNakPool",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestFd + ", devB",
+				2: constants.Uds.Handshake.RequestFin,
+			},
+			expOutcome: "fd_nak",
+		},
+		{
+			name: "no fd requested",
+			pool: "noFdPool",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podA",
+				1: constants.Uds.Handshake.RequestVersion,
+				2: constants.Uds.Handshake.RequestFin,
+			},
+			expOutcome: "no_fd",
+		},
+		{
+			name: "host refused",
+			pool: "hostNakPool",
+			requests: map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", podB",
+			},
+			expOutcome: "host_nak",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeUDS := uds.NewFakeHandler()
+			fakeResAPI := resourcesapi.NewFakeHandler()
+			fakeResAPI.CreateFakePod("podA", "default", "uds/"+tc.pool, []string{"devA"})
+
+			server := &server{
+				deviceType: "uds/" + tc.pool,
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+			}
+			fakeUDS.SetRequests(tc.requests)
+			server.start()
+
+			var out bytes.Buffer
+			assert.NilError(t, metrics.WriteAll(&out))
+			expCount := `afxdp_uds_connection_lifetime_seconds_count{pool="` + tc.pool + `",outcome="` + tc.expOutcome + `"} 1`
+			assert.Assert(t, strings.Contains(out.String(), expCount), out.String())
+			assert.Assert(t, strings.Count(out.String(), `afxdp_uds_connection_lifetime_seconds_count{pool="`+tc.pool+`"`) == 1,
+				"Connection should be observed once")
+		})
+	}
+}
+
+func TestConnections(t *testing.T) {
+	servers := []*server{
+		{deviceType: "afxdp/poolB", podName: "podA", podNamespace: "default", devices: map[string]int{"devB": 1}},
+		{deviceType: "afxdp/poolA", podName: "podB", podNamespace: "default", devices: map[string]int{"devC": 1}},
+		{deviceType: "afxdp/poolA", podName: "podA", podNamespace: "test", devices: map[string]int{"devA2": 1, "devA1": 2}},
+	}
+	for _, s := range servers {
+		s.addConnection()
+	}
+
+	conns := Connections()
+	assert.Equal(t, len(conns), 3)
+	for i, exp := range []struct {
+		pool    string
+		pod     string
+		devices []string
+	}{
+		{"afxdp/poolA", "podA", []string{"devA1", "devA2"}},
+		{"afxdp/poolA", "podB", []string{"devC"}},
+		{"afxdp/poolB", "podA", []string{"devB"}},
+	} {
+		assert.Equal(t, conns[i].Pool, exp.pool)
+		assert.Equal(t, conns[i].Pod, exp.pod)
+		assert.DeepEqual(t, conns[i].Devices, exp.devices)
+		assert.Assert(t, !conns[i].Since.IsZero(), "Connection time should be recorded")
+	}
+
+	for _, s := range servers {
+		s.removeConnection()
+	}
+	assert.Equal(t, len(Connections()), 0, "Connections should be removed on disconnect")
+}
+
+func TestStopAll(t *testing.T) {
+	running := func() int {
+		servers.Lock()
+		defer servers.Unlock()
+		return len(servers.running)
+	}
+	waitFor := func(cond func() bool) bool {
+		for i := 0; i < 100 && !cond(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		return cond()
+	}
+	defer func() {
+		servers.Lock()
+		servers.stopping = false
+		servers.Unlock()
+	}()
+
+	fakeUDS := uds.NewFakeHandler()
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/stopPool", []string{"devA"})
+
+	// the pod connects, then keeps sending requests until the server is stopped
+	stopped := &server{
+		deviceType: "afxdp/stopPool",
+		devices:    map[string]int{"devA": 1},
+		uds:        fakeUDS,
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+	}
+	fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podA"})
+	stopped.Start()
+	assert.Assert(t, waitFor(func() bool { return running() == 1 }), "Server should be running")
+
+	StopAll()
+	assert.Assert(t, waitFor(func() bool { return running() == 0 }), "Server should be stopped")
+	assert.Equal(t, len(Connections()), 0, "Connection should be removed on stop")
+
+	late := &server{deviceType: "afxdp/stopPool", uds: uds.NewFakeHandler(), podRes: fakeResAPI}
+	late.Start()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, running(), 0, "Server started while stopping should not run")
+}
+
+func TestRefusalEvents(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/eventPool", []string{"devA"})
+
+	testCases := []struct {
+		name       string
+		hostname   string
+		expEvent   bool
+		expMessage string
+	}{
+		{
+			name:     "valid pod",
+			hostname: "podA",
+			expEvent: false,
+		},
+		{
+			name:       "unknown pod",
+			hostname:   "podB",
+			expEvent:   true,
+			expMessage: "UDS handshake refused for afxdp/eventPool devices devA: hostname podB: no pod of this name on node",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeUDS := uds.NewFakeHandler()
+			fakeEvents := apiserver.NewFakeHandler()
+			server := &server{
+				deviceType: "afxdp/eventPool",
+				devices:    map[string]int{"devA": 1},
+				uds:        fakeUDS,
+				podRes:     fakeResAPI,
+				net:        networking.NewFakeHandler(),
+				events:     fakeEvents,
+			}
+			fakeUDS.SetRequests(map[int]string{
+				0: constants.Uds.Handshake.RequestConnect + ", " + tc.hostname,
+				1: constants.Uds.Handshake.RequestFin,
+			})
+			server.start()
+
+			events := fakeEvents.Events()
+			if !tc.expEvent {
+				assert.Equal(t, len(events), 0)
+				return
+			}
+			assert.Equal(t, len(events), 1)
+			assert.Equal(t, events[0].Pod, tc.hostname)
+			assert.Equal(t, events[0].Reason, constants.Events.HandshakeRefused)
+			assert.Equal(t, events[0].Message, tc.expMessage)
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeEvents := apiserver.NewFakeHandler()
+	server := &server{
+		deviceType: "afxdp/lockoutPool",
+		devices:    map[string]int{"devA": 1},
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+		events:     fakeEvents,
+	}
+
+	key := "uid 0 gid 0"
+	for i := 1; i < constants.Uds.LockoutThreshold; i++ {
+		fakeUDS := uds.NewFakeHandler()
+		fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podB"})
+		server.uds = fakeUDS
+		if peer, ok := server.failures[key]; ok {
+			peer.retryAt = time.Time{}
+		}
+		server.start()
+
+		assert.Equal(t, server.handshake, "refused")
+		assert.Equal(t, server.failures[key].failures, i)
+		assert.Assert(t, !server.lockedOut, "Server should not be locked out before the threshold")
+		backoff := time.Until(server.failures[key].retryAt)
+		assert.Assert(t, backoff > time.Duration(constants.Uds.LockoutBackoff)*time.Second<<uint(i-1)-time.Second, "Backoff should double with each failure")
+		assert.Assert(t, backoff <= time.Duration(constants.Uds.LockoutBackoffMax)*time.Second, "Backoff should be capped")
+		server.reset()
+	}
+
+	// a handshake within the backoff is refused without validation, and does not count as a failure
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/lockoutPool", []string{"devA"})
+	retryAt := server.failures[key].retryAt
+	fakeUDS := uds.NewFakeHandler()
+	fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podA"})
+	server.uds = fakeUDS
+	server.start()
+
+	assert.DeepEqual(t, fakeUDS.GetResponses(), map[int]string{0: constants.Uds.Handshake.ResponseHostNak})
+	assert.Equal(t, server.failures[key].failures, constants.Uds.LockoutThreshold-1)
+	assert.Equal(t, server.failures[key].retryAt, retryAt, "Backoff should not be extended")
+	assert.Assert(t, !server.lockedOut, "Server should not be locked out by a backoff refusal")
+	server.reset()
+
+	// a further failed handshake once the backoff has passed locks the server out
+	server.failures[key].retryAt = time.Time{}
+	fakeUDS = uds.NewFakeHandler()
+	fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podC"})
+	server.uds = fakeUDS
+	server.start()
+
+	assert.DeepEqual(t, fakeUDS.GetResponses(), map[int]string{0: constants.Uds.Handshake.ResponseHostNak})
+	assert.Equal(t, server.failures[key].failures, constants.Uds.LockoutThreshold)
+	assert.Assert(t, server.lockedOut, "Server should be locked out at the threshold")
+
+	var lockouts []*apiserver.Event
+	for _, event := range fakeEvents.Events() {
+		if event.Reason == constants.Events.HandshakeLockout {
+			lockouts = append(lockouts, event)
+		}
+	}
+	assert.Equal(t, len(lockouts), 1)
+	assert.Equal(t, lockouts[0].Pod, "podC")
+	assert.Equal(t, lockouts[0].Message, "UDS for afxdp/lockoutPool devices devA stopped responding after 5 failed handshakes, restart the pod to retry")
+}
+
+func TestBackoffPerPeer(t *testing.T) {
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/backoffPool", []string{"devA"})
+	server := &server{
+		deviceType: "afxdp/backoffPool",
+		devices:    map[string]int{"devA": 1},
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+	}
+
+	// a failed handshake by one process backs off that process only
+	fakeUDS := uds.NewFakeHandler()
+	fakeUDS.SetPeerCred(&syscall.Ucred{Pid: 100, Uid: 1000, Gid: 1000})
+	fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podB"})
+	server.uds = fakeUDS
+	server.start()
+	assert.Equal(t, server.handshake, "refused")
+	server.reset()
+
+	fakeUDS = uds.NewFakeHandler()
+	fakeUDS.SetPeerCred(&syscall.Ucred{Pid: 101, Uid: 1000, Gid: 1000})
+	fakeUDS.SetRequests(map[int]string{0: constants.Uds.Handshake.RequestConnect + ", podA"})
+	server.uds = fakeUDS
+	server.start()
+	assert.Equal(t, server.handshake, "refused")
+	assert.Equal(t, server.refusal, "the handshake was retried too soon after a failed handshake")
+	server.reset()
+
+	fakeUDS = uds.NewFakeHandler()
+	fakeUDS.SetPeerCred(&syscall.Ucred{Pid: 102, Uid: 2000, Gid: 2000})
+	fakeUDS.SetRequests(map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestFin,
+	})
+	server.uds = fakeUDS
+	server.start()
+	assert.Equal(t, server.handshake, "connected")
+
+	assert.Equal(t, server.failures["uid 1000 gid 1000"].failures, 1)
+	_, ok := server.failures["uid 2000 gid 2000"]
+	assert.Assert(t, !ok, "Other peers should not be counted")
+}
+
+func TestHandshakeTrace(t *testing.T) {
+	type exportedSpan struct {
+		TraceId      string `json:"traceId"`
+		SpanId       string `json:"spanId"`
+		ParentSpanId string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Attributes   []struct {
+			Key   string `json:"key"`
+			Value struct {
+				StringValue string `json:"stringValue"`
+			} `json:"value"`
+		} `json:"attributes"`
+	}
+	var lock sync.Mutex
+	spans := make(map[string]exportedSpan)
+	var requests []exportedSpan
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var export struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		assert.NilError(t, json.NewDecoder(r.Body).Decode(&export))
+		lock.Lock()
+		defer lock.Unlock()
+		for _, resourceSpans := range export.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				for _, span := range scopeSpans.Spans {
+					spans[span.Name] = span
+					if span.Name == "UDS request" {
+						requests = append(requests, span)
+					}
+				}
+			}
+		}
+	}))
+	defer endpoint.Close()
+
+	fakeUDS := uds.NewFakeHandler()
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/tracePool", []string{"devA"})
+
+	assert.NilError(t, tracing.Enable(endpoint.URL, "node1"))
+	allocate := tracing.Start("Allocate", nil)
+	server := &server{
+		deviceType: "afxdp/tracePool",
+		devices:    map[string]int{"devA": 1},
+		uds:        fakeUDS,
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+	}
+	server.SetTrace(allocate)
+	fakeUDS.SetRequests(map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestFin,
+	})
+	server.start()
+	allocate.End(nil)
+	tracing.Shutdown()
+
+	attribute := func(span exportedSpan, key string) string {
+		for _, attr := range span.Attributes {
+			if attr.Key == key {
+				return attr.Value.StringValue
+			}
+		}
+		return ""
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	for _, name := range []string{"Allocate", "UDS server", "UDS listen"} {
+		_, ok := spans[name]
+		assert.Assert(t, ok, "Span %s not exported", name)
+		assert.Equal(t, spans[name].TraceId, allocate.TraceID())
+	}
+	assert.Equal(t, spans["UDS server"].ParentSpanId, spans["Allocate"].SpanId)
+	assert.Equal(t, spans["UDS listen"].ParentSpanId, spans["UDS server"].SpanId)
+	assert.Equal(t, attribute(spans["UDS server"], "outcome"), "connected")
+	assert.Equal(t, attribute(spans["UDS server"], "pod"), "podA")
+
+	assert.Equal(t, len(requests), 2)
+	for i, expResponse := range []string{constants.Uds.Handshake.ResponseHostOk, constants.Uds.Handshake.ResponseFinAck} {
+		assert.Equal(t, requests[i].ParentSpanId, spans["UDS server"].SpanId)
+		assert.Equal(t, attribute(requests[i], "response"), expResponse)
+	}
+}
+
+func TestHandshakeTokenRedacted(t *testing.T) {
+	var lock sync.Mutex
+	var exported []byte
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NilError(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		exported = append(exported, body...)
+	}))
+	defer endpoint.Close()
+
+	var logs bytes.Buffer
+	logging.SetOutput(&logs)
+	defer logging.SetOutput(os.Stderr)
+	defer logging.SetLevel(logging.GetLevel())
+	logging.SetLevel(logging.InfoLevel)
+
+	fakeUDS := uds.NewFakeHandler()
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/tokenPool", []string{"devA"})
+
+	assert.NilError(t, tracing.Enable(endpoint.URL, "node1"))
+	allocate := tracing.Start("Allocate", nil)
+	server := &server{
+		deviceType: "afxdp/tokenPool",
+		devices:    map[string]int{"devA": 1},
+		uds:        fakeUDS,
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+		token:      "0123abcd",
+	}
+	server.SetTrace(allocate)
+	fakeUDS.SetRequests(map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA, " + constants.Uds.Handshake.ConnectToken + "0123abcd",
+		1: constants.Uds.Handshake.RequestFin,
+	})
+	server.start()
+	allocate.End(nil)
+	tracing.Shutdown()
+
+	assert.Assert(t, strings.Contains(logs.String(), "Request: "+constants.Uds.Handshake.RequestConnect+", podA"), "connection request not logged")
+	assert.Assert(t, !strings.Contains(logs.String(), "0123abcd"), "token in the logs")
+
+	lock.Lock()
+	defer lock.Unlock()
+	assert.Assert(t, len(exported) > 0, "no spans exported")
+	assert.Assert(t, !strings.Contains(string(exported), "0123abcd"), "token in the span attributes")
+}
+
+type auditBuffer struct {
+	bytes.Buffer
+}
+
+func (b *auditBuffer) Close() error {
+	return nil
+}
+
+func TestFdAudit(t *testing.T) {
+	buf := &auditBuffer{}
+	audit.SetWriter(buf)
+	defer audit.SetWriter(nil)
+
+	fakeUDS := uds.NewFakeHandler()
+	fakeUDS.SetPeerCred(&syscall.Ucred{Pid: 4321, Uid: 1500, Gid: 1500})
+	fakeResAPI := resourcesapi.NewFakeHandler()
+	fakeResAPI.CreateFakePod("podA", "default", "afxdp/auditPool", []string{"devA"})
+
+	server := &server{
+		deviceType: "afxdp/auditPool",
+		devices:    map[string]int{"devA": 1},
+		uds:        fakeUDS,
+		podRes:     fakeResAPI,
+		net:        networking.NewFakeHandler(),
+	}
+	fakeUDS.SetRequests(map[int]string{
+		0: constants.Uds.Handshake.RequestConnect + ", podA",
+		1: constants.Uds.Handshake.RequestFd + ", devA",
+		2: constants.Uds.Handshake.RequestFd + ", devB",
+		3: constants.Uds.Handshake.RequestFin,
+	})
+	server.start()
+
+	var records []audit.Record
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record audit.Record
+		assert.NilError(t, json.Unmarshal([]byte(line), &record))
+		assert.Assert(t, record.Time != "", "Record should be timestamped")
+		record.Time = ""
+		records = append(records, record)
+	}
+
+	peer := &audit.Peer{Pid: 4321, Uid: 1500, Gid: 1500}
+	assert.DeepEqual(t, records, []audit.Record{
+		{Pool: "afxdp/auditPool", Pod: "podA", Namespace: "default", Device: "devA", Peer: peer, Outcome: audit.Granted},
+		{Pool: "afxdp/auditPool", Pod: "podA", Namespace: "default", Device: "devB", Peer: peer, Outcome: audit.Denied},
+	})
+}
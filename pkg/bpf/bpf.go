@@ -0,0 +1,58 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package bpf is the public API of the BPF handler of the plugins, loading the XDP program
+redirecting to AF_XDP sockets onto a device and returning its XSK map, to be passed to the pod
+over the Unix domain socket. It is covered by the semantic versioning of package version.
+*/
+package bpf
+
+import (
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+)
+
+/*
+Handler loads, attaches and removes the BPF programs of devices.
+*/
+type Handler interface {
+	LoadBpfSendXskMap(ifname string) (int, error)
+	LoadAttachBpfXdpPass(ifname string) error
+	ConfigureBusyPoll(fd int, busyTimeout int, busyBudget int) error
+	Cleanbpf(ifname string) error
+}
+
+/*
+NewHandler returns an implementation of the Handler interface, loading BPF programs through the
+privileged helper if one is set by UseHelper.
+*/
+func NewHandler() Handler {
+	return bpf.NewHandler()
+}
+
+/*
+NewFakeHandler returns a fake implementation of the Handler interface, for unit tests.
+*/
+func NewFakeHandler() Handler {
+	return bpf.NewFakeHandler()
+}
+
+/*
+UseHelper sets Handlers returned by NewHandler to load BPF programs through the privileged helper
+listening on the socket. It must be called before any Handler is created.
+*/
+func UseHelper(socket string) {
+	bpf.UseHelper(socket)
+}
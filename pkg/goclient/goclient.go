@@ -2,9 +2,12 @@ package goclient
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/errdefs"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
 	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
 )
@@ -16,6 +19,28 @@ var (
 	connected     bool = false
 )
 
+/*
+signingToken is the handshake token messages are signed with, empty if not signing, and sequence
+the number of requests sent on the connection, the sequence number of the next.
+*/
+var (
+	signingToken string
+	sequence     int
+)
+
+/*
+ErrDeviceNotOwned is returned when the device plugin refuses a request for a device that was not
+allocated to the pod. Check for it with errors.Is.
+*/
+var ErrDeviceNotOwned = errdefs.ErrDeviceNotOwned
+
+/*
+ErrFdAlreadyServed is returned when the device plugin refuses a request for the file descriptor
+of a device that was already passed on the connection, as it serves each only once. Check for it
+with errors.Is.
+*/
+var ErrFdAlreadyServed = errdefs.ErrFdAlreadyServed
+
 /*
 GetClientVersion returns the version of our Handshake from the client
 */
@@ -34,11 +59,11 @@ func GetServerVersion() (string, uds.CleanupFunc, error) {
 		}
 	}
 
-	if err := hostUds.Write(constants.Uds.Handshake.RequestVersion, -1); err != nil {
+	if err := write(constants.Uds.Handshake.RequestVersion, -1); err != nil {
 		return "", cleanupGlobal, fmt.Errorf("Library Error: Writing Error: %v", err)
 	}
 
-	response, _, err := hostUds.Read()
+	response, _, err := read()
 	if err != nil {
 		return "", cleanupGlobal, fmt.Errorf("Library Error: Reading Error: %v", err)
 	}
@@ -60,12 +85,12 @@ func RequestXSKmapFD(device string) (int, uds.CleanupFunc, error) {
 		}
 	}
 
-	if err := hostUds.Write(constants.Uds.Handshake.RequestFd+", "+device, -1); err != nil {
+	if err := write(constants.Uds.Handshake.RequestFd+", "+device, -1); err != nil {
 		return 0, cleanupGlobal, fmt.Errorf("Library Error: UDS Write error: %v", err)
 
 	}
 
-	response, fd, err := hostUds.Read()
+	response, fd, err := read()
 	if err != nil {
 		return 0, cleanupGlobal, fmt.Errorf("Library Error: UDS Read error: %v", err)
 
@@ -73,8 +98,10 @@ func RequestXSKmapFD(device string) (int, uds.CleanupFunc, error) {
 
 	if response == constants.Uds.Handshake.ResponseFdAck {
 		return fd, cleanupGlobal, nil
+	} else if response == constants.Uds.Handshake.ResponseFdServed {
+		return 0, cleanupGlobal, errdefs.Wrap(ErrFdAlreadyServed, fmt.Errorf("Library Error: File descriptor already served on the connection"))
 	} else {
-		return 0, cleanupGlobal, fmt.Errorf("Library Error: Request for FD was not acknowledged")
+		return 0, cleanupGlobal, errdefs.Wrap(ErrDeviceNotOwned, fmt.Errorf("Library Error: Request for FD was not acknowledged"))
 	}
 
 }
@@ -92,11 +119,11 @@ func RequestBusyPoll(busyTimeout, busyBudget, fd int) (uds.CleanupFunc, error) {
 
 	pollString := fmt.Sprintf("%s, %d, %d", constants.Uds.Handshake.RequestBusyPoll, busyTimeout, busyBudget)
 
-	if err := hostUds.Write(pollString, fd); err != nil {
+	if err := write(pollString, fd); err != nil {
 		return cleanupGlobal, fmt.Errorf("Library Error: Failed to write to UDS error: %v", err)
 	}
 
-	response, _, err := hostUds.Read()
+	response, _, err := read()
 	if err != nil {
 		return cleanupGlobal, fmt.Errorf("Library Error: Failed to read UDS error: %v", err)
 	}
@@ -108,6 +135,54 @@ func RequestBusyPoll(busyTimeout, busyBudget, fd int) (uds.CleanupFunc, error) {
 	return cleanupGlobal, nil
 }
 
+/*
+RequestDevicePeer requires a device name and returns the name of its bond peer, or an empty string if the device is not paired, a cleanup function to close the connection, and an error
+*/
+func RequestDevicePeer(device string) (string, uds.CleanupFunc, error) {
+	if !connected {
+		err := initFunc()
+		if err != nil {
+			return "", cleanupGlobal, fmt.Errorf("Library Error: Initializing Error: %v", err)
+		}
+	}
+
+	if err := write(constants.Uds.Handshake.RequestConfig+", "+device, -1); err != nil {
+		return "", cleanupGlobal, fmt.Errorf("Library Error: UDS Write error: %v", err)
+	}
+
+	response, _, err := read()
+	if err != nil {
+		return "", cleanupGlobal, fmt.Errorf("Library Error: UDS Read error: %v", err)
+	}
+
+	words := strings.Split(response, ",")
+	if words[0] != constants.Uds.Handshake.ResponseConfigAck {
+		return "", cleanupGlobal, errdefs.Wrap(ErrDeviceNotOwned, fmt.Errorf("Library Error: Request for device config was not acknowledged"))
+	}
+
+	for _, word := range words[1:] {
+		word = strings.TrimSpace(word)
+		if strings.HasPrefix(word, "peer=") {
+			return strings.TrimPrefix(word, "peer="), cleanupGlobal, nil
+		}
+	}
+
+	return "", cleanupGlobal, nil
+}
+
+/*
+socketPath returns the path of the UDS in the pod, preferring the socket directory mounted by the
+device plugin, which still holds the socket if it is recreated, over the socket file mounted by
+older device plugins, or for pools setting udsLegacyPath.
+*/
+func socketPath() string {
+	path := constants.Uds.PodDir + constants.Uds.SockName
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return constants.Uds.PodPath
+}
+
 /*
 initFunc initializes the library, returns a cleanup function and an error
 */
@@ -117,7 +192,7 @@ func initFunc() error {
 	var response string
 
 	// init uds Handler for reading and writing
-	if err := hostUds.Init(constants.Uds.PodPath, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, 0*time.Second, ""); err != nil {
+	if err := hostUds.Init(socketPath(), constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, 0*time.Second, ""); err != nil {
 		return fmt.Errorf("Library Error: Error Initialising UDS server: %v", err)
 	}
 
@@ -132,11 +207,22 @@ func initFunc() error {
 		return fmt.Errorf("Library Error: Failed to initialize host: %v", err)
 	}
 
-	if err = hostUds.Write(constants.Uds.Handshake.RequestConnect+", "+hostname, -1); err != nil {
+	// with a handshake token, every message is signed, and the connection request answers a
+	// challenge so it cannot be replayed
+	nonce := ""
+	signingToken, sequence = "", 0
+	if token, exists := os.LookupEnv(constants.Uds.TokenEnvVar); exists && token != "" {
+		signingToken = token
+		if nonce, err = challenge(); err != nil {
+			return err
+		}
+	}
+
+	if err = write(connectRequest(hostname, nonce), -1); err != nil {
 		return fmt.Errorf("Library Error: UDS Write error: %v", err)
 	}
 
-	if response, _, err = hostUds.Read(); err != nil {
+	if response, _, err = read(); err != nil {
 		return fmt.Errorf("Library Error: UDS Read error : %v", err)
 	}
 
@@ -146,3 +232,85 @@ func initFunc() error {
 
 	return nil
 }
+
+/*
+write writes a request to the UDS, signed if the library has a handshake token.
+*/
+func write(request string, fd int) error {
+	if signingToken != "" {
+		request = uds.Sign(signingToken, uds.SignRequest, sequence, request)
+	}
+	if err := hostUds.Write(request, fd); err != nil {
+		return err
+	}
+	sequence++
+	return nil
+}
+
+/*
+read reads the response to the last request from the UDS, verifying its signature if the library
+has a handshake token, so a response injected by another process is not trusted.
+*/
+func read() (string, int, error) {
+	response, fd, err := hostUds.Read()
+	if err != nil || signingToken == "" {
+		return response, fd, err
+	}
+
+	message, sig := uds.SplitSignature(response)
+	if !uds.VerifySignature(signingToken, uds.SignResponse, sequence-1, message, sig) {
+		return "", 0, fmt.Errorf("Library Error: Response signature does not verify: %s", response)
+	}
+	return message, fd, nil
+}
+
+/*
+challenge requests a challenge from the device plugin and returns its nonce.
+*/
+func challenge() (string, error) {
+	if err := write(constants.Uds.Handshake.RequestChallenge, -1); err != nil {
+		return "", fmt.Errorf("Library Error: UDS Write error: %v", err)
+	}
+
+	response, _, err := read()
+	if err != nil {
+		return "", fmt.Errorf("Library Error: UDS Read error : %v", err)
+	}
+
+	prefix := constants.Uds.Handshake.ResponseChallenge + ", "
+	if !strings.HasPrefix(response, prefix) {
+		return "", fmt.Errorf("Library Error: Unexpected challenge response: %s", response)
+	}
+
+	return strings.TrimPrefix(response, prefix), nil
+}
+
+/*
+connectRequest returns the connection request for the pod hostname. The pod name, namespace and
+UID are added if the pod sets them through the downward API, allowing the device plugin to validate
+the pod by more than its hostname, which may differ from the pod name. The handshake token is
+added if the device plugin gave the container one at allocation, as the proof of the challenge
+nonce if a challenge was requested.
+*/
+func connectRequest(hostname string, nonce string) string {
+	request := constants.Uds.Handshake.RequestConnect + ", " + hostname
+
+	if name, exists := os.LookupEnv(constants.Uds.PodNameEnvVar); exists && name != "" {
+		request += ", " + constants.Uds.Handshake.ConnectName + name
+	}
+	if namespace, exists := os.LookupEnv(constants.Uds.PodNamespaceEnvVar); exists && namespace != "" {
+		request += ", " + constants.Uds.Handshake.ConnectNamespace + namespace
+	}
+	if uid, exists := os.LookupEnv(constants.Uds.PodUidEnvVar); exists && uid != "" {
+		request += ", " + constants.Uds.Handshake.ConnectUid + uid
+	}
+	if token, exists := os.LookupEnv(constants.Uds.TokenEnvVar); exists && token != "" {
+		if nonce != "" {
+			request += ", " + constants.Uds.Handshake.ConnectProof + uds.ChallengeProof(token, nonce)
+		} else {
+			request += ", " + constants.Uds.Handshake.ConnectToken + token
+		}
+	}
+
+	return request
+}
@@ -0,0 +1,225 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package poolmanager is the public API of the device pools of the device plugin. Each pool is
+registered with the kubelet as a resource, advertises its devices and, on allocation, loads the
+BPF program onto the devices and serves the handshake to the pod. A vendor specific device plugin
+reads the pools of a config file, adjusting their config as needed, and runs a PoolManager for
+each. It is covered by the semantic versioning of package version.
+*/
+package poolmanager
+
+import (
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/deviceplugin"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/host"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
+	pkgudsserver "github.com/intel/afxdp-plugins-for-kubernetes/pkg/udsserver"
+)
+
+/*
+PoolManager manages a pool of devices, registered with the kubelet as a device type.
+*/
+type PoolManager struct {
+	pm deviceplugin.PoolManager
+}
+
+/*
+PoolConfig is the config and the devices of a pool.
+*/
+type PoolConfig struct {
+	Name                    string               // the name of the pool, advertised to the kubelet as a resource requested by pods
+	Mode                    string               // the mode the pool operates in
+	Devices                 map[string]*Device   // the devices of the pool, keyed on name
+	UdsServerDisable        bool                 // a boolean to say if pods in this pool are served without BPF loading and a UDS server
+	UdsTimeout              int                  // timeout value in seconds for the UDS sockets
+	UdsFuzz                 bool                 // a boolean to turn on fuzz testing within the UDS server, has no use outside of development and testing
+	RequiresUnprivilegedBpf bool                 // a boolean to say if this pool requires unprivileged BPF
+	UID                     int                  // the id of the pod user, given ACL access to the UDS socket
+	EthtoolCmds             []string             // list of ethtool filters to apply to the devices
+	Promiscuous             bool                 // a boolean to say if devices from this pool are put into promiscuous mode on allocation
+	UmemFrameSize           int                  // the UMEM frame size pods of this pool use, device MTUs are validated against it
+	AdjustMtu               bool                 // a boolean to say if device MTUs too large for the UMEM frame size are lowered rather than refused
+	IrqCpus                 []int                // the CPUs the queue IRQs of allocated devices are pinned to
+	IrqPodCpus              bool                 // a boolean to say if the queue IRQs of allocated devices are pinned to the exclusive CPUs of the pod
+	AllowedUids             []int                // the UIDs a process connecting to the UDS may run as, any if empty
+	AllowedGids             []int                // the GIDs a process connecting to the UDS may run as, any if empty
+	UdsProfile              string               // the UDS protocol profile of the applications of the pool, CNDP or DPDK
+	UdsFaults               *pkgudsserver.Faults // faults injected into the UDS responses, nil if none are, has no use outside of development and testing
+}
+
+/*
+Device is a netdev of a pool, as selected for the pool by GetPoolConfigs.
+*/
+type Device struct {
+	device *networking.Device
+}
+
+/*
+Name returns the name of the device.
+*/
+func (d *Device) Name() string {
+	return d.device.Name()
+}
+
+/*
+Mode returns the mode of the pool the device is assigned to.
+*/
+func (d *Device) Mode() string {
+	return d.device.Mode()
+}
+
+/*
+Driver returns the driver of the device.
+*/
+func (d *Device) Driver() (string, error) {
+	return d.device.Driver()
+}
+
+/*
+Pci returns the PCI address of the device.
+*/
+func (d *Device) Pci() (string, error) {
+	return d.device.Pci()
+}
+
+/*
+Mac returns the MAC address of the device.
+*/
+func (d *Device) Mac() (string, error) {
+	return d.device.Mac()
+}
+
+/*
+NewPoolManager returns a PoolManager for the pool, started by its Init method and stopped by its
+Terminate method.
+*/
+func NewPoolManager(config PoolConfig) PoolManager {
+	return PoolManager{pm: deviceplugin.NewPoolManager(config.internal())}
+}
+
+/*
+Init starts the pool, serving it and registering it with the kubelet.
+*/
+func (pm *PoolManager) Init(config PoolConfig) error {
+	return pm.pm.Init(config.internal())
+}
+
+/*
+Terminate stops the pool. Its devices are reported unhealthy to the kubelet and allocations in
+progress are given time to finish.
+*/
+func (pm *PoolManager) Terminate() error {
+	return pm.pm.Terminate()
+}
+
+/*
+CheckRegistered returns an error if the pool is no longer registered with the kubelet.
+*/
+func (pm *PoolManager) CheckRegistered() error {
+	return pm.pm.CheckRegistered()
+}
+
+/*
+GetPoolConfigs reads the config file and returns the config of each pool, holding the devices of
+this node selected for it.
+*/
+func GetPoolConfigs(configFile string) ([]PoolConfig, error) {
+	configs, err := deviceplugin.GetPoolConfigs(configFile, networking.NewHandler(), host.NewHandler())
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make([]PoolConfig, 0, len(configs))
+	for _, config := range configs {
+		pools = append(pools, poolConfigOf(config))
+	}
+	return pools, nil
+}
+
+/*
+poolConfigOf returns the pool config of the device plugin as a PoolConfig.
+*/
+func poolConfigOf(config deviceplugin.PoolConfig) PoolConfig {
+	devices := make(map[string]*Device, len(config.Devices))
+	for name, device := range config.Devices {
+		devices[name] = &Device{device}
+	}
+
+	var faults *pkgudsserver.Faults
+	if config.UdsFaults != nil {
+		f := pkgudsserver.Faults(*config.UdsFaults)
+		faults = &f
+	}
+
+	return PoolConfig{
+		Name:                    config.Name,
+		Mode:                    config.Mode,
+		Devices:                 devices,
+		UdsServerDisable:        config.UdsServerDisable,
+		UdsTimeout:              config.UdsTimeout,
+		UdsFuzz:                 config.UdsFuzz,
+		RequiresUnprivilegedBpf: config.RequiresUnprivilegedBpf,
+		UID:                     config.UID,
+		EthtoolCmds:             config.EthtoolCmds,
+		Promiscuous:             config.Promiscuous,
+		UmemFrameSize:           config.UmemFrameSize,
+		AdjustMtu:               config.AdjustMtu,
+		IrqCpus:                 config.IrqCpus,
+		IrqPodCpus:              config.IrqPodCpus,
+		AllowedUids:             config.AllowedUids,
+		AllowedGids:             config.AllowedGids,
+		UdsProfile:              config.UdsProfile,
+		UdsFaults:               faults,
+	}
+}
+
+/*
+internal returns the PoolConfig as the pool config of the device plugin.
+*/
+func (config PoolConfig) internal() deviceplugin.PoolConfig {
+	devices := make(map[string]*networking.Device, len(config.Devices))
+	for name, device := range config.Devices {
+		devices[name] = device.device
+	}
+
+	var faults *udsserver.Faults
+	if config.UdsFaults != nil {
+		f := udsserver.Faults(*config.UdsFaults)
+		faults = &f
+	}
+
+	return deviceplugin.PoolConfig{
+		Name:                    config.Name,
+		Mode:                    config.Mode,
+		Devices:                 devices,
+		UdsServerDisable:        config.UdsServerDisable,
+		UdsTimeout:              config.UdsTimeout,
+		UdsFuzz:                 config.UdsFuzz,
+		RequiresUnprivilegedBpf: config.RequiresUnprivilegedBpf,
+		UID:                     config.UID,
+		EthtoolCmds:             config.EthtoolCmds,
+		Promiscuous:             config.Promiscuous,
+		UmemFrameSize:           config.UmemFrameSize,
+		AdjustMtu:               config.AdjustMtu,
+		IrqCpus:                 config.IrqCpus,
+		IrqPodCpus:              config.IrqPodCpus,
+		AllowedUids:             config.AllowedUids,
+		AllowedGids:             config.AllowedGids,
+		UdsProfile:              config.UdsProfile,
+		UdsFaults:               faults,
+	}
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package uds is the public API of the Unix domain socket connection between the device plugin and
+the pods of a pool, over which the handshake is made and the XSK map file descriptors passed.
+Clients, such as applications in a pod or a vendor specific device plugin, dial the socket and
+sign their messages with this package. It is covered by the semantic versioning of package
+version.
+*/
+package uds
+
+import (
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+)
+
+/*
+Handler is a Unix domain socket, either end of the connection.
+*/
+type Handler interface {
+	Init(socketPath string, protocol string, msgBufSize int, ctlBufSize int, timeout time.Duration, uid string) error
+	Listen() (CleanupFunc, error)
+	Dial() (CleanupFunc, error)
+	Read() (string, int, error)
+	Write(response string, fd int) error
+	PeerCred() (*syscall.Ucred, error)
+	Close()
+}
+
+/*
+FakeHandler is a Handler recording the writes made and replaying responses, for unit tests.
+*/
+type FakeHandler interface {
+	Handler
+	SetRequests(requests map[int]string)
+	GetResponses() map[int]string
+	SetPeerCred(cred *syscall.Ucred)
+}
+
+/*
+CleanupFunc closes the socket once the connection is finished.
+*/
+type CleanupFunc func()
+
+/*
+ErrClosed is returned when reading from a socket closed by the other end.
+*/
+var ErrClosed = uds.ErrClosed
+
+/*
+Directions of a signed message, see Sign.
+*/
+const (
+	SignRequest  = uds.SignRequest
+	SignResponse = uds.SignResponse
+)
+
+/*
+NewHandler returns an implementation of the Handler interface.
+*/
+func NewHandler() Handler {
+	return &handler{uds.NewHandler()}
+}
+
+/*
+NewFakeHandler returns a fake implementation of the Handler interface, for unit tests.
+*/
+func NewFakeHandler() FakeHandler {
+	fake := uds.NewFakeHandler()
+	return &fakeHandler{handler{fake}, fake}
+}
+
+/*
+ChallengeProof returns the proof answering a challenge of the device plugin, computed from the
+handshake token given to the container.
+*/
+func ChallengeProof(token string, nonce string) string {
+	return uds.ChallengeProof(token, nonce)
+}
+
+/*
+Sign returns the message with its signature appended, keyed with the handshake token. Requests
+are numbered from 0 on each connection and a response is signed with the number of the request
+it answers.
+*/
+func Sign(token string, direction string, seq int, message string) string {
+	return uds.Sign(token, direction, seq, message)
+}
+
+/*
+SplitSignature returns the message with its signature removed, and the signature, empty if the
+message is not signed.
+*/
+func SplitSignature(message string) (string, string) {
+	return uds.SplitSignature(message)
+}
+
+/*
+VerifySignature returns true if sig is the signature of the message, see Sign.
+*/
+func VerifySignature(token string, direction string, seq int, message string, sig string) bool {
+	return uds.VerifySignature(token, direction, seq, message, sig)
+}
+
+/*
+handler implements the Handler interface over the Handler of the plugins.
+*/
+type handler struct {
+	uds uds.Handler
+}
+
+func (h *handler) Init(socketPath string, protocol string, msgBufSize int, ctlBufSize int, timeout time.Duration, uid string) error {
+	return h.uds.Init(socketPath, protocol, msgBufSize, ctlBufSize, timeout, uid)
+}
+
+func (h *handler) Listen() (CleanupFunc, error) {
+	cleanup, err := h.uds.Listen()
+	return CleanupFunc(cleanup), err
+}
+
+func (h *handler) Dial() (CleanupFunc, error) {
+	cleanup, err := h.uds.Dial()
+	return CleanupFunc(cleanup), err
+}
+
+func (h *handler) Read() (string, int, error) {
+	return h.uds.Read()
+}
+
+func (h *handler) Write(response string, fd int) error {
+	return h.uds.Write(response, fd)
+}
+
+func (h *handler) PeerCred() (*syscall.Ucred, error) {
+	return h.uds.PeerCred()
+}
+
+func (h *handler) Close() {
+	h.uds.Close()
+}
+
+/*
+fakeHandler implements the FakeHandler interface over the fake Handler of the plugins.
+*/
+type fakeHandler struct {
+	handler
+	fake uds.FakeHandler
+}
+
+func (h *fakeHandler) SetRequests(requests map[int]string) {
+	h.fake.SetRequests(requests)
+}
+
+func (h *fakeHandler) GetResponses() map[int]string {
+	return h.fake.GetResponses()
+}
+
+func (h *fakeHandler) SetPeerCred(cred *syscall.Ucred) {
+	h.fake.SetPeerCred(cred)
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package udsserver is the public API of the Unix domain socket server of the device plugin, serving
+the handshake to the pods a device was allocated to. A vendor specific device plugin creates a
+server for each allocation with a ServerFactory, adds the devices and their XSK map file
+descriptors, and starts it. It is covered by the semantic versioning of package version.
+*/
+package udsserver
+
+import (
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
+)
+
+/*
+Server is the Unix domain socket server of a single allocation.
+*/
+type Server interface {
+	AddDevice(dev string, fd int)
+	AddDevicePeer(dev string, peer string, fd int)
+	SetPodIrqAffinity()
+	SetAllowedPeers(uids []int, gids []int)
+	SetDpdkProfile()
+	SetFaults(faults Faults)
+	Token() string
+	Start()
+}
+
+/*
+ServerFactory creates Servers. CreateServer returns the Server of an allocation along with the
+path of the socket it serves, in a directory of its own.
+*/
+type ServerFactory interface {
+	CreateServer(deviceType, user string, timeout int, udsFuzz bool) (Server, string, error)
+}
+
+/*
+Faults are faults injected into the responses of a Server, for testing clients.
+*/
+type Faults struct {
+	Delay        time.Duration // how long a delayed response is held back
+	DelayRate    float64       // probability of a response being delayed
+	NakRate      float64       // probability of a NAK being sent in place of a response
+	DropRate     float64       // probability of the connection being dropped in place of a response
+	TruncateRate float64       // probability of only the first half of a response being sent
+	Seed         int64         // seed of the faults drawn, so a run can be repeated, random if 0
+}
+
+/*
+NewServerFactory returns an implementation of the ServerFactory interface.
+*/
+func NewServerFactory() ServerFactory {
+	return &serverFactory{udsserver.NewServerFactory()}
+}
+
+/*
+NewFakeServerFactory returns a fake implementation of the ServerFactory interface, for unit tests.
+*/
+func NewFakeServerFactory() ServerFactory {
+	return &serverFactory{udsserver.NewFakeServerFactory()}
+}
+
+/*
+SetPodCheckInterval sets the interval at which a connected pod is checked to still exist.
+*/
+func SetPodCheckInterval(interval time.Duration) {
+	udsserver.SetPodCheckInterval(interval)
+}
+
+/*
+SetRequireToken sets whether pods are refused when they do not send the handshake token of the
+allocation in their connection request. It must be called before any Server is created.
+*/
+func SetRequireToken(require bool) {
+	udsserver.SetRequireToken(require)
+}
+
+/*
+SetRequireChallenge sets whether pods are refused when they do not request a challenge before
+their connection request. It must be called before any Server is created.
+*/
+func SetRequireChallenge(require bool) {
+	udsserver.SetRequireChallenge(require)
+}
+
+/*
+SetRequireSigned sets whether pods are refused when their messages are not signed with the
+handshake token. It must be called before any Server is created.
+*/
+func SetRequireSigned(require bool) {
+	udsserver.SetRequireSigned(require)
+}
+
+/*
+StopAll stops every Server, closing the connections of their pods.
+*/
+func StopAll() {
+	udsserver.StopAll()
+}
+
+/*
+serverFactory implements the ServerFactory interface over the ServerFactory of the plugins.
+*/
+type serverFactory struct {
+	factory udsserver.ServerFactory
+}
+
+func (f *serverFactory) CreateServer(deviceType, user string, timeout int, udsFuzz bool) (Server, string, error) {
+	s, udsPath, err := f.factory.CreateServer(deviceType, user, timeout, udsFuzz)
+	if err != nil {
+		return nil, "", err
+	}
+	return &server{s}, udsPath, nil
+}
+
+/*
+server implements the Server interface over the Server of the plugins.
+*/
+type server struct {
+	udsserver.Server
+}
+
+func (s *server) SetFaults(faults Faults) {
+	s.Server.SetFaults(udsserver.Faults(faults))
+}
@@ -0,0 +1,38 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package version holds the version of the public Go API of the plugins, the packages under pkg/.
+The API follows semantic versioning: within a major version, packages, types and functions are
+only added, never removed or changed incompatibly. Packages under internal/ are not part of the
+API and change without notice.
+*/
+package version
+
+import "github.com/intel/afxdp-plugins-for-kubernetes/constants"
+
+/*
+API is the semantic version of the public Go API. The major version is increased on a breaking
+change, the minor version when the API is extended and the patch version on a fix. Releases of
+the module are tagged with the same version.
+*/
+const API = "v1.0.0"
+
+/*
+Plugins returns the version of the plugins the API is built into, set at build time.
+*/
+func Plugins() string {
+	return constants.Plugins.Version
+}
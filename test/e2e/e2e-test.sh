@@ -22,6 +22,9 @@ full_run=false
 daemonset=false
 soak=false
 ci_run=false
+kind_run=false
+kind_cluster="${KIND_CLUSTER:-af-xdp-deployment}"
+traffic_iface=""
 pids=( )
 container_tool=""
 
@@ -49,6 +52,9 @@ cleanup() {
 	kubectl delete pods -l app=afxdp-e2e -n default --grace-period=0 --ignore-not-found=true &> /dev/null
 	echo "Delete Test App"
 	rm -f ./udsTest &> /dev/null
+	rm -f ./testClient &> /dev/null
+	rm -f ./afxdpEcho &> /dev/null
+	rm -f ./afxdpTraffic &> /dev/null
 	echo "Delete CNI"
 	rm -f /opt/cni/bin/afxdp &> /dev/null
 	echo "Delete Network Attachment Definition"
@@ -64,6 +70,9 @@ cleanup() {
 	fi
 	echo "Stop Daemonset Device Plugin (if running)"
 	kubectl delete --ignore-not-found=true -f $workdir/daemonset.yml
+	if [ "$kind_run" = true ]; then
+		kubectl delete --ignore-not-found=true -f $workdir/daemonset-kind.yml
+	fi
 }
 
 build() {
@@ -72,14 +81,26 @@ build() {
 	echo "*               Build and Install                   *"
 	echo "*****************************************************"
 	echo
-	echo "***** CNI Install *****"
-	cp ./../../bin/afxdp /opt/cni/bin/afxdp
+	if [ "$kind_run" = false ]; then
+		echo "***** CNI Install *****"
+		cp ./../../bin/afxdp /opt/cni/bin/afxdp
+	fi
 	echo "***** Network Attachment Definition *****"
 	kubectl create -f $workdir/nad.yaml
 	echo "***** Test App *****"
 	go build -tags netgo -o udsTest ./udsTest.go
+	go build -tags netgo -o testClient ./../../cmd/test-client
+	go build -tags netgo -o afxdpEcho ./../../examples/afxdp-echo
+	if [ -n "$traffic_iface" ]; then
+		(cd traffic && go mod tidy && go build -o ../afxdpTraffic .)
+	fi
 	echo "***** Docker Image *****"
 	$container_tool build -t afxdp-e2e-test -f Dockerfile .
+	if [ "$kind_run" = true ]; then
+		echo "***** Load Images into Kind *****"
+		kind load docker-image afxdp-e2e-test --name "$kind_cluster"
+		kind load docker-image afxdp-device-plugin --name "$kind_cluster"
+	fi
 }
 
 run() {
@@ -88,7 +109,15 @@ run() {
 	echo "*              Run Device Plugin                    *"
 	echo "*****************************************************"
 	if [ "$daemonset" = true ]; then
-		if [ "$ci_run" = true ]; then
+		if [ "$kind_run" = true ]; then
+			echo "***** Deploying Device Plugin as daemonset on Kind *****"
+			echo
+			echo "The device plugin runs with the ci profile, creating a veth secondary network on each node"
+			echo "Logs can be viewed in /var/log/afxdp-k8s-plugins/afxdp-dp-e2e.log on the Kind nodes"
+			echo
+			kubectl create -f $workdir/daemonset-kind.yml
+			kubectl -n kube-system rollout status daemonset/kube-afxdp-device-plugin-e2e-kind --timeout=120s
+		elif [ "$ci_run" = true ]; then
 			echo "*****   Pushing image to registry    *****"
 			echo
 			$container_tool tag afxdp-device-plugin "$DOCKER_REG"/test/afxdp-device-plugin-e2e:latest
@@ -151,6 +180,10 @@ run_local_pods() {
 	echo "***** UDS Test *****"
 	echo
 	kubectl exec -i afxdp-e2e-test --container afxdp -- udsTest
+	echo
+	echo "***** Test Client *****"
+	echo
+	kubectl exec -i afxdp-e2e-test --container afxdp -- test-client -bind
 	echo "***** Delete Pod *****"
 	kubectl delete pod --grace-period 0 --ignore-not-found=true afxdp-e2e-test &> /dev/null
 	if [ "$full_run" = true ]; then
@@ -248,6 +281,39 @@ run_local_pods() {
 		echo
 		echo "***** Delete Pod *****"
 		kubectl delete pod --grace-period 0 --ignore-not-found=true afxdp-e2e-test &> /dev/null
+		sleep 5
+		echo
+		echo "*****************************************************"
+		echo "*          Run Pod: AF_XDP echo application         *"
+		echo "*****************************************************"
+		kubectl create -f $workdir/pod-echo.yaml
+		traffic_passed=true
+		if [ -n "$traffic_iface" ]; then
+			kubectl wait --for=condition=Ready pod/afxdp-e2e-test --timeout=60s
+			sleep 2
+			echo
+			echo "***** Traffic Test *****"
+			echo
+			mac=$(kubectl exec -i afxdp-e2e-test -- sh -c 'set -- $AFXDP_DEVICES; cat /sys/class/net/$1/address')
+			./afxdpTraffic -iface "$traffic_iface" -dst-mac "$mac" || traffic_passed=false
+		fi
+		sleep 30
+		echo
+		echo "***** Echo Application Logs *****"
+		echo
+		kubectl logs afxdp-e2e-test
+		phase=$(kubectl get pod afxdp-e2e-test -o jsonpath='{.status.phase}')
+		echo
+		echo "***** Delete Pod *****"
+		kubectl delete pod --grace-period 0 --ignore-not-found=true afxdp-e2e-test &> /dev/null
+		if [ "$phase" != "Succeeded" ]; then
+			echo "Echo application did not succeed, pod phase $phase"
+			exit 1
+		fi
+		if [ "$traffic_passed" != true ]; then
+			echo "Traffic was not echoed through the AF_XDP path"
+			exit 1
+		fi
 	fi
 }
 
@@ -358,6 +424,8 @@ display_help() {
 	echo "  -d, --daemonset     Deploy the device plugin in a daemonset"
 	echo "  -s, --soak          Continue to create and delete test pods until manually stopped"
 	echo "  -c, --ci            Deploy as daemonset and deploy a large number of various test pods"
+	echo "  -k, --kind          Deploy as daemonset on a Kind cluster, with veths in place of AF_XDP capable NICs"
+	echo "  -t, --traffic IFACE Full run, also sending traffic from IFACE, a peer of the e2e devices, through the echo pod"
 	echo
 	exit 0
 }
@@ -383,6 +451,19 @@ then
 			-s|--soak)
 				soak=true
 			;;
+			-k|--kind)
+				kind_run=true
+				daemonset=true
+			;;
+			-t|--traffic)
+				if [ -z "${2-}" ]; then
+					echo "Argument $1 requires a peer interface"
+					exit 1
+				fi
+				traffic_iface=$2
+				full_run=true
+				shift
+			;;
 			-?*)
 				echo "Unknown argument $1"
 				exit 1
@@ -0,0 +1,8 @@
+module github.com/intel/afxdp-plugins-for-kubernetes/test/e2e/traffic
+
+go 1.13
+
+require (
+	github.com/google/gopacket v1.1.19
+	github.com/stretchr/testify v1.8.1
+)
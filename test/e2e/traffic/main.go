@@ -0,0 +1,141 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+The traffic test sends UDP frames toward an AF_XDP device in a pod from a peer interface connected
+to it, and verifies they come back through the AF_XDP echo application running in the pod. It
+reports the frames sent and echoed as JSON on stdout and exits with 0 if enough frames were echoed
+intact, and 1 otherwise. It runs on the host, with CAP_NET_RAW, during the e2e tests.
+*/
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/test/e2e/traffic/packet"
+)
+
+/*
+maxMissing is the most sequences of missing frames reported.
+*/
+const maxMissing = 20
+
+/*
+result is the report of a traffic test, printed as JSON.
+*/
+type result struct {
+	Interface string `json:"interface"`
+	DstMac    string `json:"dstMac"`
+	packet.Result
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+func main() {
+	var iface string
+	var dstMac string
+	var srcIP string
+	var dstIP string
+	var port int
+	var opts packet.Options
+	var loss float64
+	flag.StringVar(&iface, "iface", "", "Peer interface the frames are sent from, connected to the device in the pod")
+	flag.StringVar(&dstMac, "dst-mac", "", "MAC address of the device in the pod")
+	flag.StringVar(&srcIP, "src-ip", "192.168.250.1", "Source IPv4 address of the frames")
+	flag.StringVar(&dstIP, "dst-ip", "192.168.250.2", "Destination IPv4 address of the frames")
+	flag.IntVar(&port, "port", 4789, "Source and destination UDP port of the frames")
+	flag.IntVar(&opts.Count, "count", 100, "Number of frames sent")
+	flag.IntVar(&opts.Size, "size", 128, "Size of each frame in bytes")
+	flag.DurationVar(&opts.Interval, "interval", time.Millisecond, "Interval between frames sent")
+	flag.DurationVar(&opts.Timeout, "timeout", 5*time.Second, "How long to wait for echoes once every frame is sent")
+	flag.Float64Var(&loss, "loss", 0, "Percentage of frames allowed not to be echoed")
+	flag.Parse()
+
+	res := run(iface, dstMac, srcIP, dstIP, uint16(port), opts, loss)
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	if !res.Passed {
+		os.Exit(1)
+	}
+}
+
+/*
+run sends the frames and returns the result of the test.
+*/
+func run(iface string, dstMac string, srcIP string, dstIP string, port uint16, opts packet.Options, loss float64) result {
+	res := result{Interface: iface, DstMac: dstMac}
+	fail := func(err error) result {
+		res.Error = err.Error()
+		return res
+	}
+
+	if iface == "" {
+		return fail(errors.New("no peer interface, -iface is not set"))
+	}
+	mac, err := net.ParseMAC(dstMac)
+	if err != nil {
+		return fail(fmt.Errorf("invalid device MAC address: %v", err))
+	}
+	if opts.Count <= 0 {
+		return fail(errors.New("count must be positive"))
+	}
+
+	conn, err := packet.Open(iface)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+
+	flow := packet.Flow{
+		SrcMac:  conn.HardwareAddr(),
+		DstMac:  mac,
+		SrcIP:   net.ParseIP(srcIP),
+		DstIP:   net.ParseIP(dstIP),
+		SrcPort: port,
+		DstPort: port,
+		Run:     rand.New(rand.NewSource(time.Now().UnixNano())).Uint32(),
+	}
+	res.Result, err = packet.Exchange(conn, flow, opts)
+	if len(res.Missing) > maxMissing {
+		res.Missing = res.Missing[:maxMissing]
+	}
+	if err != nil {
+		return fail(err)
+	}
+
+	lost := float64(res.Sent-res.Echoed) * 100 / float64(res.Sent)
+	switch {
+	case res.Corrupt > 0:
+		return fail(fmt.Errorf("%d frames echoed corrupt", res.Corrupt))
+	case lost > loss:
+		return fail(fmt.Errorf("%.1f%% of frames not echoed, %.1f%% allowed", lost, loss))
+	}
+
+	res.Passed = true
+	return res
+}
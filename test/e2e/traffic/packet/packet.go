@@ -0,0 +1,268 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package packet builds, sends and verifies the test traffic of the e2e tests, asserting packets
+reach an AF_XDP socket in a pod rather than only that the handshake succeeds. Frames are sent
+toward an allocated device from a peer interface connected to it, and are expected back with their
+Ethernet addresses swapped, as the AF_XDP echo application sends them. The kernel stack never
+returns a frame that way, so an echoed frame proves the datapath through the XDP program and the
+AF_XDP socket of the pod works.
+
+Every test frame is a UDP packet whose payload starts with the magic, the run and the sequence of
+the frame, so frames of other traffic and of earlier runs on the same link are told apart.
+*/
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/*
+Magic starts the payload of every test frame.
+*/
+const Magic = "AFXDPE2E"
+
+/*
+Sizes of test frames, without the frame check sequence.
+*/
+const (
+	MinSize    = 64
+	MaxSize    = 1514
+	headerSize = 14 + 20 + 8 // Ethernet, IPv4 and UDP headers
+	markerSize = len(Magic) + 4 + 4
+)
+
+/*
+Flow is the addressing of the test frames sent toward a device.
+*/
+type Flow struct {
+	SrcMac  net.HardwareAddr // the peer interface frames are sent from
+	DstMac  net.HardwareAddr // the device in the pod
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort uint16
+	DstPort uint16
+	Run     uint32 // identifies the run, frames of other runs are ignored
+}
+
+/*
+Probe is a decoded test frame.
+*/
+type Probe struct {
+	SrcMac  net.HardwareAddr
+	DstMac  net.HardwareAddr
+	SrcIP   net.IP
+	DstIP   net.IP
+	SrcPort uint16
+	DstPort uint16
+	Run     uint32
+	Seq     uint32
+	Payload []byte
+}
+
+/*
+Build returns the test frame of the flow with a sequence, of size bytes. The payload following the
+marker is filled with a pattern derived from the sequence, so corruption is detected.
+*/
+func (f Flow) Build(seq uint32, size int) ([]byte, error) {
+	if size < MinSize || size > MaxSize {
+		return nil, fmt.Errorf("frame size %d is not between %d and %d", size, MinSize, MaxSize)
+	}
+	srcIP, dstIP := f.SrcIP.To4(), f.DstIP.To4()
+	if srcIP == nil || dstIP == nil {
+		return nil, errors.New("flow addresses must be IPv4")
+	}
+
+	payload := make([]byte, size-headerSize)
+	copy(payload, Magic)
+	binary.BigEndian.PutUint32(payload[len(Magic):], f.Run)
+	binary.BigEndian.PutUint32(payload[len(Magic)+4:], seq)
+	for i := markerSize; i < len(payload); i++ {
+		payload[i] = byte(seq) + byte(i)
+	}
+
+	eth := &layers.Ethernet{
+		SrcMAC:       f.SrcMac,
+		DstMAC:       f.DstMac,
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		IHL:      5,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    srcIP,
+		DstIP:    dstIP,
+	}
+	udp := &layers.UDP{
+		SrcPort: layers.UDPPort(f.SrcPort),
+		DstPort: layers.UDPPort(f.DstPort),
+	}
+	if err := udp.SetNetworkLayerForChecksum(ip); err != nil {
+		return nil, fmt.Errorf("error building UDP header: %v", err)
+	}
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload(payload)); err != nil {
+		return nil, fmt.Errorf("error building frame: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+/*
+Decode decodes a frame, returning false if it is not a test frame.
+*/
+func Decode(frame []byte) (Probe, bool) {
+	packet := gopacket.NewPacket(frame, layers.LayerTypeEthernet, gopacket.DecodeOptions{Lazy: true, NoCopy: true})
+	eth, _ := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	ip, _ := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	udp, _ := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	if eth == nil || ip == nil || udp == nil {
+		return Probe{}, false
+	}
+	payload := udp.Payload
+	if len(payload) < markerSize || !bytes.HasPrefix(payload, []byte(Magic)) {
+		return Probe{}, false
+	}
+
+	return Probe{
+		SrcMac:  eth.SrcMAC,
+		DstMac:  eth.DstMAC,
+		SrcIP:   ip.SrcIP,
+		DstIP:   ip.DstIP,
+		SrcPort: uint16(udp.SrcPort),
+		DstPort: uint16(udp.DstPort),
+		Run:     binary.BigEndian.Uint32(payload[len(Magic):]),
+		Seq:     binary.BigEndian.Uint32(payload[len(Magic)+4:]),
+		Payload: payload,
+	}, true
+}
+
+/*
+Verifier tallies the frames echoed back for the frames sent in a run.
+*/
+type Verifier struct {
+	flow       Flow
+	sent       map[uint32][]byte // payloads of the frames sent, by sequence
+	echoed     map[uint32]bool
+	duplicates int
+	corrupt    int
+}
+
+/*
+NewVerifier returns a verifier of the frames echoed for a flow.
+*/
+func NewVerifier(flow Flow) *Verifier {
+	return &Verifier{
+		flow:   flow,
+		sent:   make(map[uint32][]byte),
+		echoed: make(map[uint32]bool),
+	}
+}
+
+/*
+Sent records a frame sent, so its echo is expected.
+*/
+func (v *Verifier) Sent(frame []byte) error {
+	probe, ok := Decode(frame)
+	if !ok || probe.Run != v.flow.Run {
+		return errors.New("frame is not a test frame of the run")
+	}
+	v.sent[probe.Seq] = append([]byte(nil), probe.Payload...)
+
+	return nil
+}
+
+/*
+Check tallies a frame received on the peer interface, returning true if it is the echo of a frame
+of the run. An echo must come from the device to the peer interface, with the IP header, UDP header
+and payload of the frame sent unchanged. Frames of other traffic and other runs, and the frames
+sent themselves, are ignored. Frames of the run that are not a faithful echo are counted corrupt.
+*/
+func (v *Verifier) Check(frame []byte) bool {
+	probe, ok := Decode(frame)
+	if !ok || probe.Run != v.flow.Run {
+		return false
+	}
+	if bytes.Equal(probe.SrcMac, v.flow.SrcMac) && bytes.Equal(probe.DstMac, v.flow.DstMac) {
+		return false
+	}
+
+	payload, sent := v.sent[probe.Seq]
+	if !sent ||
+		!bytes.Equal(probe.SrcMac, v.flow.DstMac) || !bytes.Equal(probe.DstMac, v.flow.SrcMac) ||
+		!probe.SrcIP.Equal(v.flow.SrcIP) || !probe.DstIP.Equal(v.flow.DstIP) ||
+		probe.SrcPort != v.flow.SrcPort || probe.DstPort != v.flow.DstPort ||
+		!bytes.Equal(probe.Payload, payload) {
+		v.corrupt++
+		return false
+	}
+	if v.echoed[probe.Seq] {
+		v.duplicates++
+		return true
+	}
+	v.echoed[probe.Seq] = true
+
+	return true
+}
+
+/*
+Done returns true once every frame sent has been echoed.
+*/
+func (v *Verifier) Done() bool {
+	return len(v.echoed) == len(v.sent)
+}
+
+/*
+Result is the outcome of a run, from the frames sent and echoed so far.
+*/
+type Result struct {
+	Sent       int      `json:"sent"`
+	Echoed     int      `json:"echoed"`
+	Duplicates int      `json:"duplicates"`
+	Corrupt    int      `json:"corrupt"`
+	Missing    []uint32 `json:"missing,omitempty"` // sequences of frames not echoed, in order
+}
+
+/*
+Result returns the outcome of the run so far.
+*/
+func (v *Verifier) Result() Result {
+	res := Result{
+		Sent:       len(v.sent),
+		Echoed:     len(v.echoed),
+		Duplicates: v.duplicates,
+		Corrupt:    v.corrupt,
+	}
+	for seq := range v.sent {
+		if !v.echoed[seq] {
+			res.Missing = append(res.Missing, seq)
+		}
+	}
+	sort.Slice(res.Missing, func(i, j int) bool { return res.Missing[i] < res.Missing[j] })
+
+	return res
+}
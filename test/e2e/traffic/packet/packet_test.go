@@ -0,0 +1,156 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFlow = Flow{
+	SrcMac:  net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+	DstMac:  net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+	SrcIP:   net.IPv4(192, 168, 250, 1),
+	DstIP:   net.IPv4(192, 168, 250, 2),
+	SrcPort: 4789,
+	DstPort: 4789,
+	Run:     7,
+}
+
+/*
+echo returns a frame with its Ethernet addresses swapped, as the AF_XDP echo application sends it.
+*/
+func echo(frame []byte) []byte {
+	echoed := append([]byte(nil), frame...)
+	copy(echoed[0:6], frame[6:12])
+	copy(echoed[6:12], frame[0:6])
+	return echoed
+}
+
+func TestBuild(t *testing.T) {
+	testCases := []struct {
+		name   string
+		flow   Flow
+		size   int
+		expErr string
+	}{
+		{name: "minimum size", flow: testFlow, size: MinSize},
+		{name: "maximum size", flow: testFlow, size: MaxSize},
+		{name: "too small", flow: testFlow, size: MinSize - 1, expErr: "frame size 63 is not between"},
+		{name: "too large", flow: testFlow, size: MaxSize + 1, expErr: "frame size 1515 is not between"},
+		{
+			name:   "ipv6 flow",
+			flow:   Flow{SrcMac: testFlow.SrcMac, DstMac: testFlow.DstMac, SrcIP: net.ParseIP("fd00::1"), DstIP: net.ParseIP("fd00::2")},
+			size:   MinSize,
+			expErr: "flow addresses must be IPv4",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			frame, err := tc.flow.Build(42, tc.size)
+			if tc.expErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), tc.expErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, frame, tc.size)
+
+			probe, ok := Decode(frame)
+			require.True(t, ok, "built frame is not a test frame")
+			assert.Equal(t, testFlow.SrcMac, probe.SrcMac)
+			assert.Equal(t, testFlow.DstMac, probe.DstMac)
+			assert.True(t, testFlow.SrcIP.Equal(probe.SrcIP))
+			assert.True(t, testFlow.DstIP.Equal(probe.DstIP))
+			assert.Equal(t, testFlow.SrcPort, probe.SrcPort)
+			assert.Equal(t, testFlow.DstPort, probe.DstPort)
+			assert.Equal(t, testFlow.Run, probe.Run)
+			assert.Equal(t, uint32(42), probe.Seq)
+			assert.Len(t, probe.Payload, tc.size-headerSize)
+		})
+	}
+}
+
+func TestDecodeOtherTraffic(t *testing.T) {
+	frame, err := testFlow.Build(0, MinSize)
+	require.NoError(t, err)
+
+	notMagic := append([]byte(nil), frame...)
+	notMagic[headerSize] = 'X'
+
+	testCases := []struct {
+		name  string
+		frame []byte
+	}{
+		{name: "empty", frame: nil},
+		{name: "truncated", frame: frame[:20]},
+		{name: "no magic", frame: notMagic},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := Decode(tc.frame)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	frames := make([][]byte, 4)
+	for i := range frames {
+		frame, err := testFlow.Build(uint32(i), 128)
+		require.NoError(t, err)
+		frames[i] = frame
+	}
+	otherRun := testFlow
+	otherRun.Run = 8
+	otherFrame, err := otherRun.Build(0, 128)
+	require.NoError(t, err)
+	corrupted := echo(frames[3])
+	corrupted[len(corrupted)-1] ^= 0xff
+
+	v := NewVerifier(testFlow)
+	for _, frame := range frames {
+		require.NoError(t, v.Sent(frame))
+	}
+	assert.Error(t, v.Sent(otherFrame), "frame of another run recorded")
+
+	assert.True(t, v.Check(echo(frames[0])), "echo not recognised")
+	assert.True(t, v.Check(echo(frames[0])), "duplicate echo not recognised")
+	assert.True(t, v.Check(echo(frames[2])), "echo not recognised")
+	assert.False(t, v.Check(frames[1]), "frame sent counted as echoed")
+	assert.False(t, v.Check(echo(otherFrame)), "echo of another run counted")
+	assert.False(t, v.Check(corrupted), "corrupt echo counted")
+	assert.False(t, v.Done())
+
+	assert.Equal(t, Result{
+		Sent:       4,
+		Echoed:     2,
+		Duplicates: 1,
+		Corrupt:    1,
+		Missing:    []uint32{1, 3},
+	}, v.Result())
+
+	v.Check(echo(frames[1]))
+	v.Check(echo(frames[3]))
+	assert.True(t, v.Done())
+	assert.Empty(t, v.Result().Missing)
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package packet
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+/*
+pollInterval is how long a receive waits before the deadline of an exchange is checked again.
+*/
+const pollInterval = 100 * time.Millisecond
+
+/*
+Conn is a raw AF_PACKET socket on an interface, sending frames as they are and receiving every
+frame arriving on the interface. It needs CAP_NET_RAW.
+*/
+type Conn struct {
+	fd    int
+	iface *net.Interface
+}
+
+/*
+Open opens a raw socket on an interface.
+*/
+func Open(name string) (*Conn, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("error finding interface %s: %v", name, err)
+	}
+
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(htons(syscall.ETH_P_ALL)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating packet socket: %v", err)
+	}
+	addr := &syscall.SockaddrLinklayer{Protocol: htons(syscall.ETH_P_ALL), Ifindex: iface.Index}
+	if err := syscall.Bind(fd, addr); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("error binding packet socket to %s: %v", name, err)
+	}
+	timeout := syscall.NsecToTimeval(pollInterval.Nanoseconds())
+	if err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &timeout); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("error setting packet socket timeout: %v", err)
+	}
+
+	return &Conn{fd: fd, iface: iface}, nil
+}
+
+/*
+HardwareAddr returns the MAC address of the interface.
+*/
+func (c *Conn) HardwareAddr() net.HardwareAddr {
+	return c.iface.HardwareAddr
+}
+
+/*
+Send sends a frame out of the interface.
+*/
+func (c *Conn) Send(frame []byte) error {
+	if _, err := syscall.Write(c.fd, frame); err != nil {
+		return fmt.Errorf("error sending frame on %s: %v", c.iface.Name, err)
+	}
+	return nil
+}
+
+/*
+Receive reads the next frame arriving on the interface into buf and returns its length. Frames
+leaving the interface are skipped. It returns 0 and no error if no frame arrived before the poll
+interval passed.
+*/
+func (c *Conn) Receive(buf []byte) (int, error) {
+	for {
+		n, from, err := syscall.Recvfrom(c.fd, buf, 0)
+		if err == syscall.EAGAIN || err == syscall.EINTR {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("error receiving frame on %s: %v", c.iface.Name, err)
+		}
+		if link, ok := from.(*syscall.SockaddrLinklayer); ok && link.Pkttype == syscall.PACKET_OUTGOING {
+			continue
+		}
+		return n, nil
+	}
+}
+
+/*
+Close closes the socket.
+*/
+func (c *Conn) Close() error {
+	return syscall.Close(c.fd)
+}
+
+/*
+Options are the traffic of an exchange.
+*/
+type Options struct {
+	Count    int           // frames sent
+	Size     int           // bytes of each frame
+	Interval time.Duration // between frames sent
+	Timeout  time.Duration // wait for echoes once every frame is sent
+}
+
+/*
+Exchange sends the frames of a flow on a connection and receives their echoes, until every frame
+is echoed or the timeout passes after the last frame is sent. The result tallies the frames sent
+and echoed, it is for the caller to judge.
+*/
+func Exchange(conn *Conn, flow Flow, opts Options) (Result, error) {
+	verifier := NewVerifier(flow)
+	frames := make([][]byte, opts.Count)
+	for i := range frames {
+		frame, err := flow.Build(uint32(i), opts.Size)
+		if err != nil {
+			return Result{}, err
+		}
+		if err := verifier.Sent(frame); err != nil {
+			return Result{}, err
+		}
+		frames[i] = frame
+	}
+
+	sent := make(chan error, 1)
+	go func() {
+		for _, frame := range frames {
+			if err := conn.Send(frame); err != nil {
+				sent <- err
+				return
+			}
+			time.Sleep(opts.Interval)
+		}
+		sent <- nil
+	}()
+
+	buf := make([]byte, MaxSize+4)
+	var deadline time.Time
+	for !verifier.Done() {
+		select {
+		case err := <-sent:
+			if err != nil {
+				return verifier.Result(), err
+			}
+			deadline = time.Now().Add(opts.Timeout)
+		default:
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			break
+		}
+
+		n, err := conn.Receive(buf)
+		if err != nil {
+			return verifier.Result(), err
+		}
+		if n > 0 {
+			verifier.Check(buf[:n])
+		}
+	}
+
+	return verifier.Result(), nil
+}
+
+/*
+htons converts a short from host to network byte order.
+*/
+func htons(i uint16) uint16 {
+	return i<<8 | i>>8
+}
@@ -63,7 +63,7 @@ func main() {
 	udsHandler = uds.NewHandler()
 
 	// init
-	if err := udsHandler.Init(constants.Uds.PodPath, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, udsIdleTimeout, ""); err != nil {
+	if err := udsHandler.Init(constants.Uds.PodDir+constants.Uds.SockName, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, udsIdleTimeout, ""); err != nil {
 		println("Test App Error: Error Initialising UDS server: ", err)
 		os.Exit(1)
 	}
@@ -82,8 +82,21 @@ func main() {
 	}
 	defer cleanup()
 
-	// connect and verify pod hostname
-	makeRequest("/connect, " + hostname)
+	// connect and verify pod hostname, name, namespace and UID
+	connectRequest := "/connect, " + hostname
+	if name, exists := os.LookupEnv(constants.Uds.PodNameEnvVar); exists {
+		connectRequest += ", name=" + name
+	}
+	if namespace, exists := os.LookupEnv(constants.Uds.PodNamespaceEnvVar); exists {
+		connectRequest += ", namespace=" + namespace
+	}
+	if uid, exists := os.LookupEnv(constants.Uds.PodUidEnvVar); exists {
+		connectRequest += ", uid=" + uid
+	}
+	if token, exists := os.LookupEnv(constants.Uds.TokenEnvVar); exists {
+		connectRequest += ", token=" + token
+	}
+	makeRequest(connectRequest)
 	time.Sleep(requestDelay)
 
 	// Execute timeoutAfterConnect when set to true
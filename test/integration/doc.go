@@ -0,0 +1,25 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+Package integration holds the integration tests of the plugins, run against network namespaces and
+veth pairs rather than physical NICs and a Kubernetes cluster. The tests allocate a veth to a pod
+through the pool manager, attach it to the pod network namespace through the CNI, perform the UDS
+handshake, and assert on the state of the kernel at each step. They need root and libbpf, and are
+built only with the integration build tag:
+
+	sudo go test -tags integration -v ./test/integration/
+*/
+package integration
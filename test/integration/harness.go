@@ -0,0 +1,332 @@
+//go:build integration
+// +build integration
+
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integration
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/containernetworking/cni/pkg/skel"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/containernetworking/plugins/pkg/testutils"
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/cni"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/deviceplugin"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/networking"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi/podrestest"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+	pluginapi "k8s.io/kubelet/pkg/apis/deviceplugin/v1beta1"
+)
+
+/*
+bpfMapTypeXskMap is BPF_MAP_TYPE_XSKMAP, as reported in the fdinfo of a map.
+*/
+const bpfMapTypeXskMap = 17
+
+/*
+Harness is the environment of an integration test: a network namespace standing in for a pod,
+veth pairs standing in for NICs and a mock kubelet. Everything created is removed when the test
+ends.
+*/
+type Harness struct {
+	t       *testing.T
+	PodName string // name of the pod, and its hostname in the handshake
+	PodNs   ns.NetNS
+	Kubelet *podrestest.Server
+}
+
+/*
+Pod is a pod allocated devices of a pool, as started by the kubelet.
+*/
+type Pod struct {
+	Devices []string
+	Socket  string // host path of the UDS of the pod
+	Token   string // handshake token given to the pod
+}
+
+/*
+New creates the environment of an integration test, skipping the test unless run as root.
+*/
+func New(t *testing.T) *Harness {
+	if os.Geteuid() != 0 {
+		t.Skip("integration tests need root")
+	}
+
+	podNs, err := testutils.NewNS()
+	require.NoError(t, err, "Can't create pod network namespace")
+	t.Cleanup(func() {
+		podNs.Close()
+		testutils.UnmountNS(podNs)
+	})
+
+	kubelet, err := podrestest.NewServer()
+	require.NoError(t, err, "Can't start mock kubelet")
+	resourcesapi.SetSocketPath(kubelet.Socket)
+	t.Cleanup(func() {
+		resourcesapi.Close()
+		resourcesapi.SetSocketPath(constants.PodResources.DefaultSocket)
+		kubelet.Close()
+	})
+
+	return &Harness{
+		t:       t,
+		PodName: "afxdp-it-" + randomHex(t, 4),
+		PodNs:   podNs,
+		Kubelet: kubelet,
+	}
+}
+
+/*
+AddVeth creates a veth pair in the host network namespace and returns the name of the end given
+to pods. The other end stays on the host to carry traffic to the pod.
+*/
+func (h *Harness) AddVeth() string {
+	name := "afxit" + randomHex(h.t, 4)
+	veth := &netlink.Veth{
+		LinkAttrs: netlink.LinkAttrs{Name: name, MTU: 1500},
+		PeerName:  name + "p",
+	}
+	require.NoError(h.t, netlink.LinkAdd(veth), "Can't create veth pair %s", name)
+	h.t.Cleanup(func() {
+		// the pair is removed with either end, wherever the pod end is
+		if link, err := netlink.LinkByName(name + "p"); err == nil {
+			netlink.LinkDel(link)
+		}
+	})
+
+	for _, end := range []string{name, name + "p"} {
+		link, err := netlink.LinkByName(end)
+		require.NoError(h.t, err)
+		require.NoError(h.t, netlink.LinkSetUp(link), "Can't set %s up", end)
+	}
+
+	return name
+}
+
+/*
+Allocate allocates devices to the pod through the pool manager of a primary mode pool holding
+them, as the kubelet does when the pod is scheduled, and registers the pod with the mock kubelet.
+*/
+func (h *Harness) Allocate(pool string, devices ...string) *Pod {
+	netHandler := networking.NewHandler()
+	poolDevices := make(map[string]*networking.Device)
+	for _, name := range devices {
+		mac, err := netHandler.GetMacAddress(name)
+		require.NoError(h.t, err)
+		poolDevices[name] = networking.CreateTestDevice(name, "primary", "veth", "", mac, netHandler)
+	}
+
+	pm := deviceplugin.NewPoolManager(deviceplugin.PoolConfig{
+		Name:       pool,
+		Mode:       "primary",
+		Devices:    poolDevices,
+		UdsTimeout: 30,
+	})
+	pm.ServerFactory = udsserver.NewServerFactory()
+	pm.BpfHandler = bpf.NewHandler()
+	pm.NetHandler = netHandler
+	pm.PodResHandler = resourcesapi.NewHandler()
+
+	resp, err := pm.Allocate(context.Background(), &pluginapi.AllocateRequest{
+		ContainerRequests: []*pluginapi.ContainerAllocateRequest{{DevicesIDs: devices}},
+	})
+	require.NoError(h.t, err, "Allocate failed")
+	require.Len(h.t, resp.ContainerResponses, 1)
+	cresp := resp.ContainerResponses[0]
+
+	pod := &Pod{Devices: devices, Token: cresp.Envs[constants.Uds.TokenEnvVar]}
+	for _, mount := range cresp.Mounts {
+		if mount.ContainerPath == constants.Uds.PodDir {
+			pod.Socket = filepath.Join(mount.HostPath, constants.Uds.SockName)
+		}
+	}
+	require.NotEmpty(h.t, pod.Socket, "No UDS mounted into the pod")
+
+	h.Kubelet.AddPod(h.PodName, "default", pm.DevicePrefix+"/"+pool, devices)
+
+	return pod
+}
+
+/*
+CniAdd attaches a device to the pod network namespace through the CNI.
+*/
+func (h *Harness) CniAdd(device string) error {
+	return cni.CmdAdd(h.cniArgs(device))
+}
+
+/*
+CniDel returns a device to the host network namespace through the CNI.
+*/
+func (h *Harness) CniDel(device string) error {
+	return cni.CmdDel(h.cniArgs(device))
+}
+
+func (h *Harness) cniArgs(device string) *skel.CmdArgs {
+	return &skel.CmdArgs{
+		ContainerID: h.PodName,
+		Netns:       h.PodNs.Path(),
+		IfName:      device,
+		Args:        "K8S_POD_NAME=" + h.PodName + ";K8S_POD_NAMESPACE=default",
+		StdinData:   []byte(`{"cniVersion":"0.3.0","name":"afxdp-it","type":"afxdp","mode":"primary","deviceID":"` + device + `"}`),
+	}
+}
+
+/*
+Handshake performs the UDS handshake of the pod, returning the XSK map file descriptor received
+for each device. The file descriptors are closed when the test ends.
+*/
+func (h *Harness) Handshake(pod *Pod) (map[string]int, error) {
+	handler := uds.NewHandler()
+	if err := handler.Init(pod.Socket, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, 10*time.Second, ""); err != nil {
+		return nil, err
+	}
+	cleanup, err := handler.Dial()
+	defer cleanup()
+	if err != nil {
+		return nil, err
+	}
+
+	call := func(request string) (string, int, error) {
+		if err := handler.Write(request, -1); err != nil {
+			return "", 0, err
+		}
+		return handler.Read()
+	}
+
+	response, _, err := call(constants.Uds.Handshake.RequestConnect + ", " + h.PodName + ", " + constants.Uds.Handshake.ConnectToken + pod.Token)
+	if err != nil {
+		return nil, err
+	}
+	if response != constants.Uds.Handshake.ResponseHostOk {
+		return nil, fmt.Errorf("connection refused: %s", response)
+	}
+
+	fds := make(map[string]int)
+	for _, device := range pod.Devices {
+		response, fd, err := call(constants.Uds.Handshake.RequestFd + ", " + device)
+		if err != nil {
+			return fds, err
+		}
+		if response != constants.Uds.Handshake.ResponseFdAck || fd <= 0 {
+			return fds, fmt.Errorf("no file descriptor for device %s: %s", device, response)
+		}
+		h.t.Cleanup(func() { closeFd(fd) })
+		fds[device] = fd
+	}
+
+	if _, _, err := call(constants.Uds.Handshake.RequestFin); err != nil {
+		return fds, err
+	}
+
+	return fds, nil
+}
+
+/*
+link returns the device in the pod network namespace if inPod is set, otherwise in the host
+network namespace, or nil if it is not there.
+*/
+func (h *Harness) link(device string, inPod bool) netlink.Link {
+	var link netlink.Link
+	find := func(ns.NetNS) error {
+		link, _ = netlink.LinkByName(device)
+		return nil
+	}
+
+	if inPod {
+		require.NoError(h.t, h.PodNs.Do(find))
+	} else {
+		find(nil)
+	}
+
+	return link
+}
+
+/*
+AssertInPod asserts the device is in the pod network namespace and not in the host network
+namespace.
+*/
+func (h *Harness) AssertInPod(device string) {
+	assert.NotNil(h.t, h.link(device, true), "Device %s not in the pod network namespace", device)
+	assert.Nil(h.t, h.link(device, false), "Device %s still in the host network namespace", device)
+}
+
+/*
+AssertOnHost asserts the device is in the host network namespace and not in the pod network
+namespace.
+*/
+func (h *Harness) AssertOnHost(device string) {
+	assert.NotNil(h.t, h.link(device, false), "Device %s not in the host network namespace", device)
+	assert.Nil(h.t, h.link(device, true), "Device %s still in the pod network namespace", device)
+}
+
+/*
+AssertXdp asserts whether an XDP program is attached to the device, in the pod network namespace
+if inPod is set. Veth devices take XDP programs without driver or hardware support.
+*/
+func (h *Harness) AssertXdp(device string, inPod bool, attached bool) {
+	link := h.link(device, inPod)
+	if !assert.NotNil(h.t, link, "Device %s not found", device) {
+		return
+	}
+	xdp := link.Attrs().Xdp
+	assert.Equal(h.t, attached, xdp != nil && xdp.Attached, "Unexpected XDP state of device %s", device)
+}
+
+/*
+AssertXskMap asserts the file descriptor is of an XSK map.
+*/
+func (h *Harness) AssertXskMap(fd int) {
+	info, err := ioutil.ReadFile("/proc/self/fdinfo/" + strconv.Itoa(fd))
+	require.NoError(h.t, err)
+
+	for _, line := range strings.Split(string(info), "\n") {
+		if strings.HasPrefix(line, "map_type:") {
+			assert.Equal(h.t, strconv.Itoa(bpfMapTypeXskMap), strings.TrimSpace(strings.TrimPrefix(line, "map_type:")),
+				"File descriptor %d is not an XSK map", fd)
+			return
+		}
+	}
+	assert.Fail(h.t, "File descriptor is not a BPF map", "fd %d", fd)
+}
+
+func randomHex(t *testing.T, size int) string {
+	random := make([]byte, size/2)
+	_, err := rand.Read(random)
+	require.NoError(t, err)
+	return hex.EncodeToString(random)
+}
+
+func closeFd(fd int) {
+	os.NewFile(uintptr(fd), "").Close()
+}
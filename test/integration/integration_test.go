@@ -0,0 +1,88 @@
+//go:build integration
+// +build integration
+
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package integration
+
+import (
+	"testing"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/bpf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAllocateAttachHandshake(t *testing.T) {
+	h := New(t)
+	devices := []string{h.AddVeth(), h.AddVeth()}
+
+	pod := h.Allocate("it", devices...)
+	for _, device := range devices {
+		h.AssertXdp(device, false, true)
+	}
+
+	for _, device := range devices {
+		require.NoError(t, h.CniAdd(device), "CNI add failed")
+		h.AssertInPod(device)
+		h.AssertXdp(device, true, true)
+	}
+
+	fds, err := h.Handshake(pod)
+	require.NoError(t, err, "Handshake failed")
+	for _, device := range devices {
+		if assert.Contains(t, fds, device) {
+			h.AssertXskMap(fds[device])
+		}
+	}
+
+	for _, device := range devices {
+		require.NoError(t, h.CniDel(device), "CNI del failed")
+		h.AssertOnHost(device)
+		h.AssertXdp(device, false, false)
+	}
+}
+
+func TestHandshakeUnknownPod(t *testing.T) {
+	h := New(t)
+	device := h.AddVeth()
+
+	pod := h.Allocate("it", device)
+	h.Kubelet.RemovePod(h.PodName, "default")
+
+	_, err := h.Handshake(pod)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection refused")
+	}
+
+	assert.NoError(t, bpf.NewHandler().Cleanbpf(device))
+	h.AssertXdp(device, false, false)
+}
+
+func TestHandshakeWrongToken(t *testing.T) {
+	h := New(t)
+	device := h.AddVeth()
+
+	pod := h.Allocate("it", device)
+	pod.Token = "not-the-token"
+
+	_, err := h.Handshake(pod)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "connection refused")
+	}
+
+	assert.NoError(t, bpf.NewHandler().Cleanbpf(device))
+}
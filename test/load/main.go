@@ -0,0 +1,499 @@
+/*
+ * Copyright(c) 2022 Intel Corporation.
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+The handshake load test runs hundreds of fake pods performing the UDS handshake concurrently
+against UDS servers of the device plugin, validating pods against a mock kubelet, and reports the
+handshake latency, the calls made to the kubelet and the resources used, as JSON on stdout. Pods
+are allocated, connect, and are deleted in rounds, as during a deployment rollout, so the UDS
+server concurrency and the pod resources cache are exercised as they are under pod churn. It runs
+in process, needing no NICs or cluster, and exits with 1 if any handshake failed.
+
+	go run ./test/load/ -pods 500 -rounds 5 -kubelet-delay 50
+
+In soak mode, rounds run until the soak duration has passed, some pods never connecting and some
+dropping the connection mid-handshake, and the goroutines and open file descriptors of the process
+are sampled after each round. A UDS server serves a single connection, so each of these ends it
+differently, and a server leaking on any of them leaves goroutines or file descriptors behind.
+The test also exits with 1 if either keeps growing once warmed up.
+
+	go run ./test/load/ -soak 30m -pods 50 -abandon 10 -drop 10
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/intel/afxdp-plugins-for-kubernetes/constants"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/resourcesapi/podrestest"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/uds"
+	"github.com/intel/afxdp-plugins-for-kubernetes/internal/udsserver"
+	logging "github.com/sirupsen/logrus"
+)
+
+/*
+pool is the resource name the fake pods are allocated devices of.
+*/
+const pool = "afxdp/load"
+
+/*
+settle is how long servers are given to end once their pods are done, before resources are sampled.
+*/
+const settle = time.Second
+
+/*
+options are the parameters of a run.
+*/
+type options struct {
+	pods         int
+	rounds       int
+	devices      int
+	cacheTTL     time.Duration
+	kubeletDelay time.Duration
+	track        bool
+	soak         time.Duration // how long rounds run for in soak mode, 0 to run the rounds given
+	abandon      int           // percentage of pods never connecting to their UDS
+	drop         int           // percentage of pods dropping the connection mid-handshake
+	udsTimeout   int           // seconds a UDS server waits for its pod to connect
+	tolerance    int           // growth of goroutines or file descriptors tolerated in soak mode
+}
+
+/*
+result is the report of a load test run, printed as JSON.
+*/
+type result struct {
+	Pods         int            `json:"pods"`
+	Rounds       int            `json:"rounds"`
+	Handshakes   int            `json:"handshakes"`
+	Abandoned    int            `json:"abandoned,omitempty"`
+	Dropped      int            `json:"dropped,omitempty"`
+	Failures     int            `json:"failures"`
+	Errors       map[string]int `json:"errors,omitempty"`
+	Latency      latency        `json:"latencyMs"`
+	Duration     float64        `json:"durationSeconds"`
+	KubeletCalls map[string]int `json:"kubeletCalls"`
+	Resources    resources      `json:"resources"`
+	Soak         *soak          `json:"soak,omitempty"`
+}
+
+/*
+soak is the report of a soak, the resources sampled after each round and whether they leaked.
+*/
+type soak struct {
+	WarmupSeconds float64  `json:"warmupSeconds"`
+	Samples       []sample `json:"samples"`
+	GoroutineLeak bool     `json:"goroutineLeak"`
+	FdLeak        bool     `json:"fdLeak"`
+}
+
+/*
+sample is the goroutines and open file descriptors of the process after a round.
+*/
+type sample struct {
+	Seconds    float64 `json:"seconds"`
+	Round      int     `json:"round"`
+	Goroutines int     `json:"goroutines"`
+	Fds        int     `json:"fds"`
+}
+
+/*
+latency summarises the latency of the handshakes, from dialling the UDS to the fin response.
+*/
+type latency struct {
+	Min float64 `json:"min"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+	Max float64 `json:"max"`
+}
+
+/*
+resources are the resources used by the run.
+*/
+type resources struct {
+	CpuUserSeconds   float64 `json:"cpuUserSeconds"`
+	CpuSystemSeconds float64 `json:"cpuSystemSeconds"`
+	MaxRssKb         int64   `json:"maxRssKb"`
+	HeapInUseKb      uint64  `json:"heapInUseKb"`
+	GoroutinesBefore int     `json:"goroutinesBefore"`
+	GoroutinesAfter  int     `json:"goroutinesAfter"`
+}
+
+/*
+pod is a fake pod and the UDS server allocated to it.
+*/
+type pod struct {
+	name    string
+	devices []string
+	socket  string
+	token   string
+	drop    bool // set if the pod drops the connection after the connection request
+}
+
+func main() {
+	var opts options
+	var cacheTTL int
+	var kubeletDelay int
+	flag.IntVar(&opts.pods, "pods", 200, "Number of fake pods performing the handshake concurrently in each round")
+	flag.IntVar(&opts.rounds, "rounds", 1, "Number of rounds of pods allocated, connecting and deleted")
+	flag.IntVar(&opts.devices, "devices", 1, "Number of devices allocated to each pod")
+	flag.IntVar(&cacheTTL, "cache-ttl", constants.PodResources.CacheTTL, "Seconds pod resources are cached for, 0 to call the kubelet for every handshake")
+	flag.IntVar(&kubeletDelay, "kubelet-delay", 0, "Milliseconds the mock kubelet takes to answer each call")
+	flag.BoolVar(&opts.track, "track", false, "Track pods, keeping pod resources current in memory, as the device plugin does by default")
+	flag.DurationVar(&opts.soak, "soak", 0, "Run rounds for this long, e.g. 30m, sampling goroutines and open file descriptors after each round, in place of -rounds")
+	flag.IntVar(&opts.abandon, "abandon", 0, "Percentage of pods never connecting to their UDS, left for the UDS timeout")
+	flag.IntVar(&opts.drop, "drop", 0, "Percentage of pods dropping the connection after the connection request, without fin")
+	flag.IntVar(&opts.udsTimeout, "uds-timeout", constants.Uds.MinTimeout, "Seconds a UDS server waits for its pod to connect")
+	flag.IntVar(&opts.tolerance, "leak-tolerance", 20, "Growth of goroutines or open file descriptors tolerated once warmed up, in soak mode")
+	flag.Parse()
+	opts.cacheTTL = time.Duration(cacheTTL) * time.Second
+	opts.kubeletDelay = time.Duration(kubeletDelay) * time.Millisecond
+
+	if opts.abandon < 0 || opts.drop < 0 || opts.abandon+opts.drop > 100 {
+		fmt.Fprintln(os.Stderr, "The percentages of pods abandoning and dropping the connection must add up to at most 100")
+		os.Exit(1)
+	}
+	if opts.soak > 0 && opts.soak < 2*warmup(opts) {
+		fmt.Fprintf(os.Stderr, "The soak must last at least %v, twice the warmup, to detect leaks\n", 2*warmup(opts))
+		os.Exit(1)
+	}
+
+	logging.SetOutput(ioutil.Discard)
+
+	res, err := run(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running load test: %v\n", err)
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding result: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(string(out))
+
+	if res.Failures > 0 || (res.Soak != nil && (res.Soak.GoroutineLeak || res.Soak.FdLeak)) {
+		os.Exit(1)
+	}
+}
+
+/*
+warmup returns how long a soak runs before its samples are judged, long enough for the servers of
+pods that never connect to time out, so they are in steady state.
+*/
+func warmup(opts options) time.Duration {
+	return time.Duration(opts.udsTimeout)*time.Second + 2*settle
+}
+
+/*
+run runs the rounds of handshakes and returns their result.
+*/
+func run(opts options) (*result, error) {
+	kubelet, err := podrestest.NewServer()
+	if err != nil {
+		return nil, err
+	}
+	defer kubelet.Close()
+	kubelet.SetDelay(opts.kubeletDelay)
+
+	resourcesapi.SetSocketPath(kubelet.Socket)
+	resourcesapi.SetCacheTTL(opts.cacheTTL)
+	defer resourcesapi.Close()
+	if opts.track {
+		stop := make(chan struct{})
+		defer close(stop)
+		resourcesapi.StartPodTracking(stop)
+	}
+
+	// every device is given the same file descriptor, the pods only check one is received
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		return nil, err
+	}
+	defer devNull.Close()
+
+	res := &result{Pods: opts.pods, Errors: make(map[string]int), KubeletCalls: make(map[string]int)}
+	if opts.soak > 0 {
+		res.Soak = &soak{WarmupSeconds: warmup(opts).Seconds(), Samples: []sample{}}
+	}
+	res.Resources.GoroutinesBefore = runtime.NumGoroutine()
+	var latencies []time.Duration
+	start := time.Now()
+
+	factory := udsserver.NewServerFactory()
+	for round := 0; more(opts, round, start); round++ {
+		allocated := make([]*pod, 0, opts.pods)
+		for i := 0; i < opts.pods; i++ {
+			p, err := allocate(factory, kubelet, round, i, opts.devices, opts.udsTimeout, int(devNull.Fd()))
+			if err != nil {
+				return nil, err
+			}
+			allocated = append(allocated, p)
+		}
+
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		for i, p := range allocated {
+			switch share := i * 100 / opts.pods; {
+			case share < opts.abandon:
+				res.Abandoned++
+				continue
+			case share < opts.abandon+opts.drop:
+				p.drop = true
+			}
+
+			wg.Add(1)
+			go func(p *pod) {
+				defer wg.Done()
+				took, err := handshake(p)
+
+				lock.Lock()
+				defer lock.Unlock()
+				if p.drop && err == nil {
+					res.Dropped++
+					return
+				}
+				res.Handshakes++
+				if err != nil {
+					res.Failures++
+					res.Errors[err.Error()]++
+					return
+				}
+				latencies = append(latencies, took)
+			}(p)
+		}
+		wg.Wait()
+
+		// pods are deleted, ending the round
+		for _, p := range allocated {
+			kubelet.RemovePod(p.name, "default")
+		}
+		res.Rounds++
+
+		if res.Soak != nil {
+			time.Sleep(settle)
+			res.Soak.Samples = append(res.Soak.Samples, sample{
+				Seconds:    time.Since(start).Seconds(),
+				Round:      round,
+				Goroutines: runtime.NumGoroutine(),
+				Fds:        openFds(),
+			})
+		}
+	}
+	if res.Soak != nil {
+		res.Soak.GoroutineLeak, res.Soak.FdLeak = leaks(res.Soak.Samples, warmup(opts).Seconds(), opts.tolerance)
+	}
+
+	res.Duration = time.Since(start).Seconds()
+	res.Latency = summarise(latencies)
+	for _, method := range []string{podrestest.MethodList, podrestest.MethodGet, podrestest.MethodAllocatable} {
+		res.KubeletCalls[method] = kubelet.Calls(method)
+	}
+
+	// servers end once their pod sends fin, give them time to remove their sockets
+	time.Sleep(time.Second)
+	res.Resources.GoroutinesAfter = runtime.NumGoroutine()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	res.Resources.HeapInUseKb = mem.HeapInuse / 1024
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err == nil {
+		res.Resources.CpuUserSeconds = time.Duration(usage.Utime.Nano()).Seconds()
+		res.Resources.CpuSystemSeconds = time.Duration(usage.Stime.Nano()).Seconds()
+		res.Resources.MaxRssKb = usage.Maxrss
+	}
+
+	return res, nil
+}
+
+/*
+more returns true while rounds remain, the rounds given or, in soak mode, until the soak is over.
+*/
+func more(opts options, round int, start time.Time) bool {
+	if opts.soak > 0 {
+		return time.Since(start) < opts.soak
+	}
+	return round < opts.rounds
+}
+
+/*
+openFds returns the number of file descriptors open in the process.
+*/
+func openFds() int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	return len(fds)
+}
+
+/*
+leaks judges the samples of a soak taken after the warmup, returning whether goroutines and open
+file descriptors leaked. A resource leaked if its lowest count in the last third of the samples
+exceeds its lowest count in the first third by more than the tolerance. The lowest counts are
+compared, so pods still being served when a sample is taken do not count as growth.
+*/
+func leaks(samples []sample, warmup float64, tolerance int) (bool, bool) {
+	var steady []sample
+	for _, s := range samples {
+		if s.Seconds >= warmup {
+			steady = append(steady, s)
+		}
+	}
+	if len(steady) < 3 {
+		return false, false
+	}
+
+	third := len(steady) / 3
+	first, last := steady[:third], steady[len(steady)-third:]
+	lowest := func(samples []sample, count func(sample) int) int {
+		low := count(samples[0])
+		for _, s := range samples {
+			if count(s) < low {
+				low = count(s)
+			}
+		}
+		return low
+	}
+	grew := func(count func(sample) int) bool {
+		return lowest(last, count) > lowest(first, count)+tolerance
+	}
+
+	return grew(func(s sample) int { return s.Goroutines }), grew(func(s sample) int { return s.Fds })
+}
+
+/*
+allocate starts a UDS server for a fake pod, as the device plugin does on allocation, and
+registers the pod with the mock kubelet.
+*/
+func allocate(factory udsserver.ServerFactory, kubelet *podrestest.Server, round int, index int, devices int, udsTimeout int, fd int) (*pod, error) {
+	p := &pod{name: "load-pod-" + strconv.Itoa(round) + "-" + strconv.Itoa(index)}
+
+	server, socket, err := factory.CreateServer(pool, "", udsTimeout, false)
+	if err != nil {
+		return nil, fmt.Errorf("error creating UDS server: %v", err)
+	}
+	for d := 0; d < devices; d++ {
+		device := "load" + strconv.Itoa(index) + "d" + strconv.Itoa(d)
+		server.AddDevice(device, fd)
+		p.devices = append(p.devices, device)
+	}
+	server.Start()
+
+	p.socket = socket
+	p.token = server.Token()
+	kubelet.AddPod(p.name, "default", pool, p.devices)
+
+	return p, nil
+}
+
+/*
+handshake performs the handshake of a fake pod, returning how long it took. A pod dropping the
+connection closes it once connected.
+*/
+func handshake(p *pod) (time.Duration, error) {
+	handler := uds.NewHandler()
+	if err := handler.Init(p.socket, constants.Uds.Protocol, constants.Uds.MsgBufSize, constants.Uds.CtlBufSize, 30*time.Second, ""); err != nil {
+		return 0, err
+	}
+
+	// the server may still be starting to listen
+	start := time.Now()
+	var cleanup uds.CleanupFunc
+	var err error
+	for attempt := 0; attempt < 50; attempt++ {
+		if cleanup, err = handler.Dial(); err == nil {
+			break
+		}
+		cleanup()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("dial: %v", err)
+	}
+	defer cleanup()
+
+	call := func(request string) (string, int, error) {
+		if err := handler.Write(request, -1); err != nil {
+			return "", 0, err
+		}
+		return handler.Read()
+	}
+
+	response, _, err := call(constants.Uds.Handshake.RequestConnect + ", " + p.name + ", " + constants.Uds.Handshake.ConnectToken + p.token)
+	if err != nil {
+		return 0, fmt.Errorf("connect: %v", err)
+	}
+	if response != constants.Uds.Handshake.ResponseHostOk {
+		return 0, fmt.Errorf("connect refused: %s", response)
+	}
+	if p.drop {
+		return time.Since(start), nil
+	}
+
+	for _, device := range p.devices {
+		response, fd, err := call(constants.Uds.Handshake.RequestFd + ", " + device)
+		if err != nil {
+			return 0, fmt.Errorf("fd request: %v", err)
+		}
+		if fd > 0 {
+			syscall.Close(fd)
+		}
+		if response != constants.Uds.Handshake.ResponseFdAck {
+			return 0, fmt.Errorf("fd refused: %s", response)
+		}
+	}
+
+	if response, _, err := call(constants.Uds.Handshake.RequestFin); err != nil {
+		return 0, fmt.Errorf("fin: %v", err)
+	} else if response != constants.Uds.Handshake.ResponseFinAck {
+		return 0, fmt.Errorf("fin refused: %s", response)
+	}
+
+	return time.Since(start), nil
+}
+
+/*
+summarise returns the latency percentiles of the handshakes, in milliseconds.
+*/
+func summarise(latencies []time.Duration) latency {
+	if len(latencies) == 0 {
+		return latency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	percentile := func(p int) float64 { return ms(latencies[(len(latencies)-1)*p/100]) }
+
+	return latency{
+		Min: ms(latencies[0]),
+		P50: percentile(50),
+		P90: percentile(90),
+		P99: percentile(99),
+		Max: ms(latencies[len(latencies)-1]),
+	}
+}